@@ -0,0 +1,98 @@
+package broker
+
+import (
+	"sync"
+	"time"
+)
+
+// Batcher is implemented by brokers which publish batches natively
+type Batcher interface {
+	PublishBatch(topic string, msgs []*Message, opts ...PublishOption) error
+}
+
+// PublishBatch publishes the messages to the topic in a single batch
+// where the broker supports it, otherwise one by one in order. The first
+// error stops a fallback publish and is returned.
+func PublishBatch(b Broker, topic string, msgs []*Message, opts ...PublishOption) error {
+	var options PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// delayed messages are published individually
+	if bb, ok := b.(Batcher); ok && !options.DeliverAt.After(time.Now()) {
+		if len(b.Options().PublishWrappers) == 0 {
+			return bb.PublishBatch(topic, msgs, opts...)
+		}
+		return publishWrapped(b.Options(), bb, topic, msgs, opts...)
+	}
+
+	for _, m := range msgs {
+		if err := b.Publish(topic, m, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publishWrapped runs each message through the publish wrappers and
+// publishes the messages which reach the broker in a single batch. The
+// wrappers see the result of the batch as the result of their message, the
+// messages are published to the topic with the options of the batch.
+func publishWrapped(options Options, bb Batcher, topic string, msgs []*Message, opts ...PublishOption) error {
+	batch := make([]*Message, len(msgs))
+	errs := make([]error, len(msgs))
+
+	// closed once the batch is published
+	done := make(chan bool)
+	var berr error
+
+	var reached, finished sync.WaitGroup
+	reached.Add(len(msgs))
+	finished.Add(len(msgs))
+
+	for i, m := range msgs {
+		var once sync.Once
+		arrive := func() { once.Do(reached.Done) }
+
+		i := i
+		fn := WrapPublishFunc(options, func(_ string, m *Message, _ ...PublishOption) error {
+			// the message as the wrappers left it
+			batch[i] = m
+			arrive()
+			<-done
+			return berr
+		})
+
+		go func(m *Message) {
+			defer finished.Done()
+			errs[i] = fn(topic, m, opts...)
+			// the wrappers may fail before publishing
+			arrive()
+		}(m)
+	}
+
+	reached.Wait()
+
+	pub := make([]*Message, 0, len(batch))
+	for _, m := range batch {
+		if m != nil {
+			pub = append(pub, m)
+		}
+	}
+	if len(pub) > 0 {
+		berr = bb.PublishBatch(topic, pub, opts...)
+	}
+	close(done)
+
+	finished.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
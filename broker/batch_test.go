@@ -0,0 +1,69 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+)
+
+// batchBroker records the batches published
+type batchBroker struct {
+	Broker
+	opts    Options
+	batches [][]*Message
+	err     error
+}
+
+func (b *batchBroker) Options() Options {
+	return b.opts
+}
+
+func (b *batchBroker) PublishBatch(topic string, msgs []*Message, opts ...PublishOption) error {
+	b.batches = append(b.batches, msgs)
+	return b.err
+}
+
+func TestPublishBatchWrapped(t *testing.T) {
+	m := NewMetrics()
+
+	// a wrapper rejecting a message and setting a header on the others
+	reject := func(fn PublishFunc) PublishFunc {
+		return func(topic string, msg *Message, opts ...PublishOption) error {
+			if string(msg.Body) == "invalid" {
+				return errors.New("invalid message")
+			}
+			return fn(topic, &Message{Header: map[string]string{"Wrapped": "true"}, Body: msg.Body}, opts...)
+		}
+	}
+
+	b := &batchBroker{}
+	for _, o := range []Option{WithMetrics(m), WrapPublish(reject)} {
+		o(&b.opts)
+	}
+
+	msgs := []*Message{{Body: []byte("a")}, {Body: []byte("invalid")}, {Body: []byte("b")}}
+	if err := PublishBatch(b, "test", msgs); err == nil || err.Error() != "invalid message" {
+		t.Fatalf("Expected the wrapper error got %v", err)
+	}
+
+	// published natively in order without the rejected message
+	if len(b.batches) != 1 || len(b.batches[0]) != 2 {
+		t.Fatalf("Expected a single batch of 2 messages got %v", b.batches)
+	}
+	for i, body := range []string{"a", "b"} {
+		msg := b.batches[0][i]
+		if string(msg.Body) != body || msg.Header["Wrapped"] != "true" {
+			t.Fatalf("Unexpected message %d %+v", i, msg)
+		}
+	}
+
+	// the wrappers see the result of the batch
+	b.err = errors.New("batch failed")
+	if err := PublishBatch(b, "test", []*Message{{Body: []byte("c")}}); err != b.err {
+		t.Fatalf("Expected the batch error got %v", err)
+	}
+
+	stats := m.Stats()
+	if len(stats) != 1 || stats[0].Published != 4 || stats[0].PublishErrors != 2 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
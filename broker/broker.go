@@ -2,6 +2,11 @@
 package broker
 
 // Broker is an interface used for asynchronous messaging.
+//
+// Messages published with the same key, see WithKey, are delivered to a
+// subscriber in the order they were published. Brokers with partitions
+// write messages with the same key to the same partition, messages
+// without a key may be spread across partitions in any order.
 type Broker interface {
 	Init(...Option) error
 	Options() Options
@@ -18,6 +23,20 @@ type Handler func(*Message) error
 
 type ErrorHandler func(*Message, error)
 
+// NackError is returned when a message published with Ack is rejected
+type NackError struct {
+	Topic string
+	// Reason the message was rejected
+	Reason string
+	// Retry redelivers the message to the subscribers which rejected it,
+	// it's set by brokers delivering to the subscribers directly
+	Retry func() error
+}
+
+func (e *NackError) Error() string {
+	return "message to " + e.Topic + " rejected: " + e.Reason
+}
+
 type Message struct {
 	Header map[string]string
 	Body   []byte
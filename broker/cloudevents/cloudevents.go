@@ -0,0 +1,237 @@
+// Package cloudevents wraps broker messages in the CloudEvents v1.0 format
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/google/uuid"
+)
+
+const (
+	// SpecVersion of the events
+	SpecVersion = "1.0"
+	// ContentType of structured events
+	ContentType = "application/cloudevents+json"
+)
+
+// Mode events are encoded in
+type Mode int
+
+const (
+	// Binary mode sets the attributes as headers and keeps the body
+	Binary Mode = iota
+	// Structured mode encodes the attributes and data as a json body
+	Structured
+)
+
+// Options of the envelope
+type Options struct {
+	// Mode events are published in, both are accepted
+	Mode Mode
+	// Source attribute of published events
+	Source string
+	// HeaderPrefix of binary mode attributes e.g ce- for
+	// http or ce_ for kafka
+	HeaderPrefix string
+}
+
+// Option sets values in Options
+type Option func(*Options)
+
+// WithMode sets the mode events are published in
+func WithMode(m Mode) Option {
+	return func(o *Options) {
+		o.Mode = m
+	}
+}
+
+// Source sets the source attribute e.g the service name
+func Source(s string) Option {
+	return func(o *Options) {
+		o.Source = s
+	}
+}
+
+// HeaderPrefix sets the prefix of binary mode headers
+func HeaderPrefix(p string) Option {
+	return func(o *Options) {
+		o.HeaderPrefix = p
+	}
+}
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		Mode:         Binary,
+		Source:       "micro",
+		HeaderPrefix: "ce-",
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// event is the structured json form of an event
+type event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      string          `json:"data_base64,omitempty"`
+}
+
+// the micro headers mapped to attributes
+const (
+	idHeader          = "Micro-Id"
+	topicHeader       = "Micro-Topic"
+	contentTypeHeader = "Content-Type"
+)
+
+func isJSON(ct string) bool {
+	return ct == "application/json" || strings.HasSuffix(ct, "+json")
+}
+
+// Encode wraps the message published to the topic in an event. The id
+// and content type are taken from the micro headers, the type is the topic.
+func Encode(topic string, m *broker.Message, opts ...Option) (*broker.Message, error) {
+	options := newOptions(opts...)
+
+	header := make(map[string]string, len(m.Header)+5)
+	for k, v := range m.Header {
+		header[k] = v
+	}
+
+	id := header[idHeader]
+	if len(id) == 0 {
+		id = uuid.New().String()
+	}
+	ct := header[contentTypeHeader]
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	if options.Mode == Binary {
+		p := options.HeaderPrefix
+		header[p+"specversion"] = SpecVersion
+		header[p+"id"] = id
+		header[p+"source"] = options.Source
+		header[p+"type"] = topic
+		header[p+"time"] = now
+		return &broker.Message{Header: header, Body: m.Body}, nil
+	}
+
+	e := &event{
+		SpecVersion:     SpecVersion,
+		ID:              id,
+		Source:          options.Source,
+		Type:            topic,
+		Time:            now,
+		DataContentType: ct,
+	}
+
+	if len(m.Body) > 0 {
+		if isJSON(ct) && json.Valid(m.Body) {
+			e.Data = m.Body
+		} else {
+			e.DataBase64 = base64.StdEncoding.EncodeToString(m.Body)
+		}
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	header[contentTypeHeader] = ContentType
+
+	return &broker.Message{Header: header, Body: b}, nil
+}
+
+// Decode unwraps the event in the message, structured or binary. The
+// attributes are set as micro headers so subscribers decode the data as
+// usual. Messages which aren't events are returned as is.
+func Decode(m *broker.Message, opts ...Option) (*broker.Message, error) {
+	options := newOptions(opts...)
+
+	header := make(map[string]string, len(m.Header))
+	for k, v := range m.Header {
+		header[k] = v
+	}
+
+	if header[contentTypeHeader] == ContentType {
+		var e event
+		if err := json.Unmarshal(m.Body, &e); err != nil {
+			return nil, err
+		}
+
+		body := []byte(e.Data)
+		if len(e.DataBase64) > 0 {
+			b, err := base64.StdEncoding.DecodeString(e.DataBase64)
+			if err != nil {
+				return nil, err
+			}
+			body = b
+		}
+
+		header[idHeader] = e.ID
+		header[topicHeader] = e.Type
+		header[contentTypeHeader] = e.DataContentType
+
+		return &broker.Message{Header: header, Body: body}, nil
+	}
+
+	p := options.HeaderPrefix
+	if _, ok := header[p+"specversion"]; !ok {
+		return m, nil
+	}
+
+	if id := header[p+"id"]; len(id) > 0 {
+		header[idHeader] = id
+	}
+	if typ := header[p+"type"]; len(typ) > 0 {
+		header[topicHeader] = typ
+	}
+
+	return &broker.Message{Header: header, Body: m.Body}, nil
+}
+
+// NewPublishWrapper wraps published messages in events
+func NewPublishWrapper(opts ...Option) broker.PublishWrapper {
+	return func(fn broker.PublishFunc) broker.PublishFunc {
+		return func(topic string, m *broker.Message, popts ...broker.PublishOption) error {
+			e, err := Encode(topic, m, opts...)
+			if err != nil {
+				return err
+			}
+			return fn(topic, e, popts...)
+		}
+	}
+}
+
+// NewSubscribeWrapper unwraps the events delivered to subscribers
+func NewSubscribeWrapper(opts ...Option) broker.SubscribeWrapper {
+	return func(fn broker.SubscribeFunc) broker.SubscribeFunc {
+		return func(topic string, h broker.Handler, sopts ...broker.SubscribeOption) (broker.Subscriber, error) {
+			return fn(topic, func(m *broker.Message) error {
+				msg, err := Decode(m, opts...)
+				if err != nil {
+					return err
+				}
+				return h(msg)
+			}, sopts...)
+		}
+	}
+}
+
+// Wrap sets both wrappers on the broker
+func Wrap(opts ...Option) broker.Option {
+	return func(o *broker.Options) {
+		broker.WrapPublish(NewPublishWrapper(opts...))(o)
+		broker.WrapSubscribe(NewSubscribeWrapper(opts...))(o)
+	}
+}
@@ -0,0 +1,99 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/broker/memory"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	msg := &broker.Message{
+		Header: map[string]string{
+			"Micro-Id":     "1",
+			"Content-Type": "application/json",
+		},
+		Body: []byte(`{"name":"john"}`),
+	}
+
+	// structured events embed json data
+	e, err := Encode("user.created", msg, WithMode(Structured), Source("users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header["Content-Type"] != ContentType {
+		t.Fatalf("expected the structured content type got %s", e.Header["Content-Type"])
+	}
+
+	var ev map[string]interface{}
+	if err := json.Unmarshal(e.Body, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev["specversion"] != "1.0" || ev["id"] != "1" || ev["type"] != "user.created" || ev["source"] != "users" {
+		t.Fatalf("unexpected event %s", e.Body)
+	}
+	if data, ok := ev["data"].(map[string]interface{}); !ok || data["name"] != "john" {
+		t.Fatalf("expected json data got %s", e.Body)
+	}
+
+	d, err := Decode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(d.Body) != string(msg.Body) || d.Header["Content-Type"] != "application/json" || d.Header["Micro-Topic"] != "user.created" {
+		t.Fatalf("unexpected decoded message %+v %s", d.Header, d.Body)
+	}
+
+	// other data is base64 encoded
+	raw := &broker.Message{Header: map[string]string{"Content-Type": "application/protobuf"}, Body: []byte{0x0a, 0x01}}
+	e, err = Encode("user.created", raw, WithMode(Structured))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err = Decode(e); err != nil || string(d.Body) != string(raw.Body) {
+		t.Fatalf("expected the binary data to round trip got %v %v", d, err)
+	}
+
+	// binary events set the attributes as headers
+	e, err = Encode("user.created", msg, HeaderPrefix("ce_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header["ce_specversion"] != "1.0" || e.Header["ce_id"] != "1" || string(e.Body) != string(msg.Body) {
+		t.Fatalf("unexpected binary event %+v", e.Header)
+	}
+	if d, err = Decode(e, HeaderPrefix("ce_")); err != nil || d.Header["Micro-Topic"] != "user.created" {
+		t.Fatalf("unexpected decoded message %+v %v", d, err)
+	}
+
+	// messages which aren't events are returned as is
+	if d, err = Decode(msg); err != nil || d != msg {
+		t.Fatal("expected the message to be returned as is")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	b := memory.NewBroker(Wrap(WithMode(Structured)))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	var got *broker.Message
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		got = m
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &broker.Message{Header: map[string]string{"Content-Type": "application/json"}, Body: []byte(`{}`)}
+	if err := b.Publish("test", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil || string(got.Body) != "{}" || len(got.Header["Micro-Id"]) == 0 {
+		t.Fatalf("expected the event to be unwrapped got %+v", got)
+	}
+}
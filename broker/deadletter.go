@@ -0,0 +1,79 @@
+package broker
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	// DeadLetterTopicHeader is the topic the message failed on
+	DeadLetterTopicHeader = "Micro-Dead-Letter-Topic"
+	// DeadLetterErrorHeader is the last error of the handler
+	DeadLetterErrorHeader = "Micro-Dead-Letter-Error"
+	// DeadLetterRetriesHeader is the number of failed attempts
+	DeadLetterRetriesHeader = "Micro-Dead-Letter-Retries"
+	// DeadLetterTimeHeader is when the message was dead lettered
+	DeadLetterTimeHeader = "Micro-Dead-Letter-Time"
+)
+
+// PublishDeadLetter publishes a copy of the message which failed on
+// the topic to the dead letter topic of the options
+func PublishDeadLetter(b Broker, topic string, m *Message, err error, attempts int, opts SubscribeOptions) error {
+	header := make(map[string]string, len(m.Header)+4)
+	for k, v := range m.Header {
+		header[k] = v
+	}
+
+	header[DeadLetterTopicHeader] = topic
+	header[DeadLetterErrorHeader] = err.Error()
+	header[DeadLetterRetriesHeader] = strconv.Itoa(attempts)
+	header[DeadLetterTimeHeader] = time.Now().Format(time.RFC3339)
+
+	return b.Publish(opts.DeadLetter, &Message{Header: header, Body: m.Body})
+}
+
+// DeadLetterHandler retries the handler for brokers which don't redeliver
+// failed messages. Once it failed MaxRetries times the message is
+// published to the dead letter topic and nil is returned. Without a dead
+// letter topic the handler is returned as is.
+func DeadLetterHandler(b Broker, topic string, h Handler, opts SubscribeOptions) Handler {
+	if len(opts.DeadLetter) == 0 {
+		return h
+	}
+
+	return func(m *Message) error {
+		var err error
+
+		attempts := opts.MaxRetries
+		if attempts < 1 {
+			attempts = 1
+		}
+
+		for i := 0; i < attempts; i++ {
+			msg := m
+			if i > 0 {
+				msg = redelivery(m, i+1)
+			}
+			if err = h(msg); err == nil {
+				return nil
+			}
+		}
+
+		// dead letter failed, the broker handles the error
+		if perr := PublishDeadLetter(b, topic, m, err, attempts, opts); perr != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// redelivery returns a copy of the message with the delivery count set
+func redelivery(m *Message, count int) *Message {
+	header := make(map[string]string, len(m.Header)+1)
+	for k, v := range m.Header {
+		header[k] = v
+	}
+	header[DeliveryCountHeader] = strconv.Itoa(count)
+	return &Message{Header: header, Body: m.Body}
+}
@@ -0,0 +1,212 @@
+// Package dedup skips messages redelivered to subscribers once they were
+// handled. The ids of handled messages are kept in the store, so
+// duplicates are skipped across restarts until the ttl expires.
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/store"
+	"github.com/asim/go-micro/v3/store/memory"
+)
+
+var (
+	// DefaultTTL the ids of handled messages are kept for
+	DefaultTTL = time.Hour * 24
+)
+
+var (
+	// ErrNoName is returned subscribing without a queue or a name
+	ErrNoName = errors.New("dedup: subscribers without a queue require a name")
+)
+
+// keyPrefix of the handled message ids
+const keyPrefix = "broker/dedup/"
+
+type nameKey struct{}
+
+// Options of the dedup wrapper
+type Options struct {
+	// Store keeps the ids of handled messages, in memory if nil
+	Store store.Store
+	// TTL the ids are kept for, it should exceed the redelivery window
+	TTL time.Duration
+	// ID returns the id of the message, Micro-Id by default. Messages
+	// without an id aren't deduplicated.
+	ID func(*broker.Message) string
+}
+
+// Option sets values in Options
+type Option func(*Options)
+
+// Store sets the store keeping the ids of handled messages
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// TTL sets how long the ids of handled messages are kept
+func TTL(d time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = d
+	}
+}
+
+// ID sets the func returning the id of a message
+func ID(fn func(*broker.Message) string) Option {
+	return func(o *Options) {
+		o.ID = fn
+	}
+}
+
+// Name sets the name the ids handled by a subscriber without a queue are
+// kept under. It should be stable across restarts and unique per topic.
+func Name(name string) broker.SubscribeOption {
+	return func(o *broker.SubscribeOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, nameKey{}, name)
+	}
+}
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		TTL: DefaultTTL,
+		ID: func(m *broker.Message) string {
+			return m.Header["Micro-Id"]
+		},
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Store == nil {
+		options.Store = memory.NewStore()
+	}
+	return options
+}
+
+// dedup runs the handler once per message id
+type dedup struct {
+	opts Options
+
+	sync.Mutex
+	// keys being handled, duplicates wait for the first delivery
+	inflight map[string]*inflight
+}
+
+type inflight struct {
+	sync.Mutex
+	waiting int
+}
+
+// lock serialises the deliveries of the key in the process
+func (d *dedup) lock(key string) func() {
+	d.Lock()
+	f, ok := d.inflight[key]
+	if !ok {
+		f = new(inflight)
+		d.inflight[key] = f
+	}
+	f.waiting++
+	d.Unlock()
+
+	f.Lock()
+
+	return func() {
+		f.Unlock()
+
+		d.Lock()
+		if f.waiting--; f.waiting == 0 {
+			delete(d.inflight, key)
+		}
+		d.Unlock()
+	}
+}
+
+// scope returns the scope of the ids handled by the subscription. Each
+// queue handles a message once while subscribers without a queue each
+// receive it, they're told apart by their name.
+func scope(opts broker.SubscribeOptions) (string, error) {
+	if len(opts.Queue) > 0 {
+		return opts.Queue, nil
+	}
+	if name, ok := opts.Context.Value(nameKey{}).(string); ok && len(name) > 0 {
+		return "_" + name, nil
+	}
+	return "", ErrNoName
+}
+
+func (d *dedup) handler(topic, scope string, h broker.Handler) broker.Handler {
+	return func(m *broker.Message) error {
+		id := d.opts.ID(m)
+		if len(id) == 0 {
+			return h(m)
+		}
+
+		key := fmt.Sprintf("%s%s/%s/%s", keyPrefix, topic, scope, id)
+
+		unlock := d.lock(key)
+		defer unlock()
+
+		recs, err := d.opts.Store.Read(key)
+		if err == nil && len(recs) > 0 {
+			// handled before, acknowledge the duplicate
+			return nil
+		}
+		if err != nil && err != store.ErrNotFound {
+			return err
+		}
+
+		if err := h(m); err != nil {
+			// failed messages are handled again when redelivered
+			return err
+		}
+
+		return d.opts.Store.Write(&store.Record{
+			Key:    key,
+			Value:  []byte(time.Now().Format(time.RFC3339)),
+			Expiry: d.opts.TTL,
+		})
+	}
+}
+
+// NewSubscribeWrapper returns a wrapper skipping the messages handled
+// before by the subscriber. Subscribers without a queue must set a Name.
+// Duplicates delivered concurrently are
+// serialised within the process, across processes the store should be
+// shared and the handler idempotent for the window between handling a
+// message and recording its id.
+func NewSubscribeWrapper(opts ...Option) broker.SubscribeWrapper {
+	d := &dedup{
+		opts:     newOptions(opts...),
+		inflight: make(map[string]*inflight),
+	}
+
+	return func(fn broker.SubscribeFunc) broker.SubscribeFunc {
+		return func(topic string, h broker.Handler, sopts ...broker.SubscribeOption) (broker.Subscriber, error) {
+			options := broker.SubscribeOptions{
+				Context: context.Background(),
+			}
+			for _, o := range sopts {
+				o(&options)
+			}
+			s, err := scope(options)
+			if err != nil {
+				return nil, err
+			}
+			return fn(topic, d.handler(topic, s, h), sopts...)
+		}
+	}
+}
+
+// Wrap sets the subscribe wrapper on the broker
+func Wrap(opts ...Option) broker.Option {
+	return broker.WrapSubscribe(NewSubscribeWrapper(opts...))
+}
@@ -0,0 +1,118 @@
+package dedup
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	mbroker "github.com/asim/go-micro/v3/broker/memory"
+	"github.com/asim/go-micro/v3/store/memory"
+)
+
+func msg(id string) *broker.Message {
+	return &broker.Message{Header: map[string]string{"Micro-Id": id}, Body: []byte(id)}
+}
+
+func TestDedup(t *testing.T) {
+	st := memory.NewStore()
+
+	b := mbroker.NewBroker(Wrap(Store(st)))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var handled []string
+	fail := true
+
+	if _, err := b.Subscribe("orders", func(m *broker.Message) error {
+		if string(m.Body) == "2" && fail {
+			fail = false
+			return errors.New("failed")
+		}
+		handled = append(handled, string(m.Body))
+		return nil
+	}, Name("orders"), broker.HandleError(func(*broker.Message, error) {})); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 fails the first time and is handled when redelivered
+	for _, id := range []string{"1", "1", "2", "2", "2", ""} {
+		if err := b.Publish("orders", msg(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fmt.Sprint(handled) != "[1 2 ]" {
+		t.Fatalf("unexpected messages handled %v", handled)
+	}
+
+	// the ids are kept in the store across restarts
+	b = mbroker.NewBroker(Wrap(Store(st)))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	handled = nil
+
+	if _, err := b.Subscribe("orders", func(m *broker.Message) error {
+		handled = append(handled, string(m.Body))
+		return nil
+	}, Name("orders")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "3"} {
+		if err := b.Publish("orders", msg(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fmt.Sprint(handled) != "[3]" {
+		t.Fatalf("expected the duplicate to be skipped after a restart got %v", handled)
+	}
+}
+
+func TestDedupScope(t *testing.T) {
+	b := mbroker.NewBroker(Wrap(TTL(time.Minute)))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+
+	if _, err := b.Subscribe("orders", func(m *broker.Message) error {
+		return nil
+	}); err != ErrNoName {
+		t.Fatalf("expected %v subscribing without a name got %v", ErrNoName, err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		name := name
+		if _, err := b.Subscribe("orders", func(m *broker.Message) error {
+			counts[name]++
+			return nil
+		}, Name(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := b.Subscribe("orders", func(m *broker.Message) error {
+		counts["queue"]++
+		return nil
+	}, broker.Queue("billing")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := b.Publish("orders", msg("1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every subscriber handles the message once
+	if counts["a"] != 1 || counts["b"] != 1 || counts["queue"] != 1 {
+		t.Fatalf("unexpected deliveries %v", counts)
+	}
+}
@@ -0,0 +1,492 @@
+// Package jetstream provides a nats jetstream broker with persistent streams
+package jetstream
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/util/backoff"
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	// DefaultBatchTimeout is how long a batch waits for the acks
+	DefaultBatchTimeout = time.Second * 10
+	// DefaultMaxDeliver limits how often a failed message is delivered
+	DefaultMaxDeliver = 10
+	// DefaultRedeliveryBackoff before redelivering a message which failed
+	DefaultRedeliveryBackoff = backoff.ExpJitter(time.Millisecond*100, time.Second*30)
+)
+
+type jsBroker struct {
+	opts broker.Options
+
+	sync.RWMutex
+	addrs     []string
+	conn      *nats.Conn
+	js        nats.JetStreamContext
+	streams   map[string]bool
+	connected bool
+	// jetstream has no delayed delivery
+	scheduler *broker.Scheduler
+}
+
+type subscriber struct {
+	topic string
+	opts  broker.SubscribeOptions
+	sub   *nats.Subscription
+}
+
+func (j *jsBroker) Options() broker.Options {
+	return j.opts
+}
+
+func (j *jsBroker) Address() string {
+	j.RLock()
+	defer j.RUnlock()
+
+	if j.conn != nil && j.conn.IsConnected() {
+		return j.conn.ConnectedUrl()
+	}
+	if len(j.addrs) > 0 {
+		return j.addrs[0]
+	}
+	return nats.DefaultURL
+}
+
+func (j *jsBroker) Connect() error {
+	j.Lock()
+	defer j.Unlock()
+
+	if j.connected {
+		return nil
+	}
+
+	opts := nats.GetDefaultOptions()
+	opts.Servers = j.addrs
+	opts.Secure = j.opts.Secure
+	opts.TLSConfig = j.opts.TLSConfig
+	if opts.TLSConfig != nil {
+		opts.Secure = true
+	}
+
+	if co, ok := j.opts.Context.Value(connectKey{}).([]nats.Option); ok {
+		for _, o := range co {
+			if err := o(&opts); err != nil {
+				return err
+			}
+		}
+	}
+
+	// nats reconnects and resumes the subscriptions, messages published
+	// meanwhile are buffered and flushed once reconnected
+	if j.opts.Buffer != nil {
+		opts.MaxReconnect = -1
+
+		reconnected := opts.ReconnectedCB
+		opts.ReconnectedCB = func(c *nats.Conn) {
+			if reconnected != nil {
+				reconnected(c)
+			}
+			go j.opts.Buffer.Flush(j.send)
+		}
+	}
+
+	conn, err := opts.Connect()
+	if err != nil {
+		return err
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	j.conn = conn
+	j.js = js
+	j.streams = make(map[string]bool)
+	j.connected = true
+
+	j.scheduler = broker.NewScheduler(j.opts.Store, func(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+		return j.publish(topic, msg, opts...)
+	})
+	j.scheduler.Start()
+
+	return nil
+}
+
+func (j *jsBroker) Disconnect() error {
+	j.Lock()
+	defer j.Unlock()
+
+	if !j.connected {
+		return nil
+	}
+
+	j.connected = false
+	j.scheduler.Stop()
+
+	return j.conn.Drain()
+}
+
+func (j *jsBroker) Init(opts ...broker.Option) error {
+	j.Lock()
+	defer j.Unlock()
+
+	for _, o := range opts {
+		o(&j.opts)
+	}
+
+	j.addrs = addrs(j.opts.Addrs)
+
+	return nil
+}
+
+// stream creates the stream of the topic if it doesn't exist
+func (j *jsBroker) stream(topic string) (nats.JetStreamContext, error) {
+	j.RLock()
+	if !j.connected {
+		j.RUnlock()
+		return nil, errors.New("not connected")
+	}
+	js := j.js
+	exists := j.streams[topic]
+	j.RUnlock()
+
+	if exists {
+		return js, nil
+	}
+
+	name := validName(topic)
+
+	if _, err := js.StreamInfo(name); err != nil {
+		var cfg nats.StreamConfig
+		if c, ok := j.opts.Context.Value(streamKey{}).(nats.StreamConfig); ok {
+			cfg = c
+		}
+		cfg.Name = name
+		cfg.Subjects = []string{topic}
+
+		if _, err := js.AddStream(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	j.Lock()
+	j.streams[topic] = true
+	j.Unlock()
+
+	return js, nil
+}
+
+func (j *jsBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return broker.WrapPublishFunc(j.opts, j.publish)(topic, msg, opts...)
+}
+
+func (j *jsBroker) publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	options := broker.PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	j.RLock()
+	if !j.connected {
+		j.RUnlock()
+		return errors.New("not connected")
+	}
+	conn := j.conn
+	scheduler := j.scheduler
+	j.RUnlock()
+
+	if options.DeliverAt.After(time.Now()) {
+		return scheduler.Schedule(topic, msg, options)
+	}
+
+	b := j.opts.Buffer
+	if b == nil {
+		return j.send(topic, msg, opts...)
+	}
+
+	// buffered until reconnected
+	if conn.IsReconnecting() {
+		return j.buffer(conn, topic, msg, opts...)
+	}
+
+	err := b.Publish(j.send, topic, msg, opts...)
+
+	// the connection dropped before it was noticed
+	if err != nil && conn.IsReconnecting() {
+		return j.buffer(conn, topic, msg, opts...)
+	}
+
+	return err
+}
+
+// buffer adds the message to the buffer, it's flushed right away if the
+// connection was restored meanwhile
+func (j *jsBroker) buffer(conn *nats.Conn, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	b := j.opts.Buffer
+
+	if err := b.Add(topic, msg, opts...); err != nil {
+		return err
+	}
+
+	if !conn.IsReconnecting() {
+		b.Flush(j.send)
+	}
+
+	return nil
+}
+
+// send publishes the message to the stream of the topic
+func (j *jsBroker) send(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	js, err := j.stream(topic)
+	if err != nil {
+		return err
+	}
+
+	options := broker.PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// the message is persisted once the stream acks it
+	if _, err := js.PublishMsg(natsMsg(topic, msg)); err != nil {
+		if options.Ack {
+			return &broker.NackError{Topic: topic, Reason: err.Error()}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// PublishBatch publishes the messages without waiting for each ack
+// and returns once the stream acked all of them
+func (j *jsBroker) PublishBatch(topic string, msgs []*broker.Message, opts ...broker.PublishOption) error {
+	js, err := j.stream(topic)
+	if err != nil {
+		return err
+	}
+
+	options := broker.PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	futures := make([]nats.PubAckFuture, 0, len(msgs))
+	for _, msg := range msgs {
+		f, err := js.PublishMsgAsync(natsMsg(topic, msg))
+		if err != nil {
+			return err
+		}
+		futures = append(futures, f)
+	}
+
+	timeout := time.After(DefaultBatchTimeout)
+
+	for _, f := range futures {
+		select {
+		case <-timeout:
+			return errors.New("timed out waiting for the batch to be acked")
+		case <-options.Context.Done():
+			return options.Context.Err()
+		case <-f.Ok():
+		case err := <-f.Err():
+			if options.Ack {
+				return &broker.NackError{Topic: topic, Reason: err.Error()}
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func natsMsg(topic string, msg *broker.Message) *nats.Msg {
+	m := nats.NewMsg(topic)
+	m.Data = msg.Body
+	for k, v := range msg.Header {
+		m.Header[k] = []string{v}
+	}
+	return m
+}
+
+func (j *jsBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	return broker.WrapSubscribeFunc(j.opts, j.subscribe)(topic, handler, opts...)
+}
+
+func (j *jsBroker) subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	js, err := j.stream(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	options := broker.SubscribeOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// messages are acked explicitly once handled, only the messages
+	// published after subscribing are delivered unless set otherwise
+	subOpts := []nats.SubOpt{nats.ManualAck(), nats.AckExplicit(), nats.DeliverNew()}
+
+	// the dead letter topic is published to before the deliveries run out
+	maxDeliver := DefaultMaxDeliver
+	if len(options.DeadLetter) > 0 && options.MaxRetries > maxDeliver {
+		maxDeliver = options.MaxRetries
+	}
+	subOpts = append(subOpts, nats.MaxDeliver(maxDeliver))
+
+	// queue subscribers share a durable consumer
+	if len(options.Queue) > 0 {
+		subOpts = append(subOpts, nats.Durable(validName(options.Queue)))
+	}
+
+	// messages delivered and not yet acked
+	if options.Prefetch > 0 {
+		subOpts = append(subOpts, nats.MaxAckPending(options.Prefetch))
+	}
+
+	if so, ok := options.Context.Value(subOptsKey{}).([]nats.SubOpt); ok {
+		subOpts = append(subOpts, so...)
+	}
+
+	fn := func(m *nats.Msg) {
+		msg := &broker.Message{
+			Header: make(map[string]string, len(m.Header)),
+			Body:   m.Data,
+		}
+		for k, v := range m.Header {
+			if len(v) > 0 {
+				msg.Header[k] = v[0]
+			}
+		}
+
+		meta, merr := m.Metadata()
+		if merr == nil {
+			msg.Header[broker.DeliveryCountHeader] = strconv.FormatUint(meta.NumDelivered, 10)
+		}
+
+		// failed messages are redelivered
+		if err := handler(msg); err != nil {
+			// dead letter once delivered max retries times
+			if merr == nil && len(options.DeadLetter) > 0 && int(meta.NumDelivered) >= options.MaxRetries {
+				if err := broker.PublishDeadLetter(j, topic, msg, err, int(meta.NumDelivered), options); err == nil {
+					m.Ack()
+					return
+				}
+			}
+
+			if eh := options.ErrorHandler; eh != nil {
+				eh(msg, err)
+			} else if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[jetstream] subscriber %s error: %v", topic, err)
+			}
+			// back off before the message is redelivered
+			if merr == nil {
+				m.NakWithDelay(DefaultRedeliveryBackoff(int(meta.NumDelivered)))
+			} else {
+				m.Nak()
+			}
+			return
+		}
+
+		m.Ack()
+	}
+
+	var sub *nats.Subscription
+
+	if len(options.Queue) > 0 {
+		sub, err = js.QueueSubscribe(topic, options.Queue, fn, subOpts...)
+	} else {
+		sub, err = js.Subscribe(topic, fn, subOpts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &subscriber{topic: topic, opts: options, sub: sub}, nil
+}
+
+func (j *jsBroker) String() string {
+	return "jetstream"
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *subscriber) Topic() string {
+	return s.topic
+}
+
+// Lag returns the messages pending for the consumer
+func (s *subscriber) Lag() (int64, error) {
+	info, err := s.sub.ConsumerInfo()
+	if err != nil {
+		return 0, err
+	}
+	return int64(info.NumPending), nil
+}
+
+func (s *subscriber) Unsubscribe() error {
+	return s.sub.Unsubscribe()
+}
+
+// validName replaces the characters not allowed in stream and consumer names
+func validName(name string) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_").Replace(name)
+}
+
+// addrs prefixes the addresses with the nats scheme
+func addrs(a []string) []string {
+	var addrs []string
+
+	for _, addr := range a {
+		if len(addr) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(addr, "nats://") && !strings.HasPrefix(addr, "tls://") {
+			addr = "nats://" + addr
+		}
+		addrs = append(addrs, addr)
+	}
+
+	if len(addrs) == 0 {
+		addrs = []string{nats.DefaultURL}
+	}
+
+	return addrs
+}
+
+// NewBroker returns a jetstream broker
+func NewBroker(opts ...broker.Option) broker.Broker {
+	options := broker.Options{
+		Context: context.Background(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &jsBroker{
+		opts:  options,
+		addrs: addrs(options.Addrs),
+	}
+}
@@ -0,0 +1,333 @@
+package jetstream
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func runServer(t *testing.T) (*server.Server, func()) {
+	dir, err := ioutil.TempDir("", "jetstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+
+	if !s.ReadyForConnections(time.Second * 5) {
+		t.Fatal("nats server not ready")
+	}
+
+	return s, func() {
+		s.Shutdown()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestJetStream(t *testing.T) {
+	s, stop := runServer(t)
+	defer stop()
+
+	b := NewBroker(broker.Addrs(s.ClientURL()))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	// messages published before subscribing are persisted
+	for _, body := range []string{"1", "2", "3"} {
+		msg := &broker.Message{Header: map[string]string{"Micro-Id": body}, Body: []byte(body)}
+		if err := b.Publish("test.topic", msg, broker.Ack()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recv := make(chan *broker.Message, 10)
+	failed := false
+
+	sub, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		// fail the second message once so it's redelivered
+		if string(m.Body) == "2" && !failed {
+			failed = true
+			return errors.New("failed")
+		}
+		recv <- m
+		return nil
+	}, StartSequence(2), broker.HandleError(func(*broker.Message, error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	got := map[string]bool{}
+
+	for len(got) < 2 {
+		select {
+		case m := <-recv:
+			if m.Header["Micro-Id"] != string(m.Body) {
+				t.Fatalf("unexpected header %+v", m.Header)
+			}
+			got[string(m.Body)] = true
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expected messages 2 and 3 got %v", got)
+		}
+	}
+
+	if got["1"] || !failed {
+		t.Fatalf("expected replay from sequence 2 with a redelivery got %v", got)
+	}
+
+	// queue subscribers share a durable consumer
+	qrecv := make(chan *broker.Message, 10)
+	qsub, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		qrecv <- m
+		return nil
+	}, broker.Queue("test.workers"), DeliverNew())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qsub.Unsubscribe()
+
+	if err := b.Publish("test.topic", &broker.Message{Body: []byte("4")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case m := <-qrecv:
+		if string(m.Body) != "4" {
+			t.Fatalf("expected message 4 got %s", m.Body)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the queue subscriber to receive the message")
+	}
+}
+
+func TestJetStreamDeadLetter(t *testing.T) {
+	s, stop := runServer(t)
+	defer stop()
+
+	b := NewBroker(broker.Addrs(s.ClientURL()))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	dead := make(chan *broker.Message, 1)
+
+	if _, err := b.Subscribe("test.dlq", func(m *broker.Message) error {
+		dead <- m
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		return errors.New("failed")
+	}, broker.DeadLetter("test.dlq", 2), broker.HandleError(func(*broker.Message, error) {})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Publish("test.topic", &broker.Message{Body: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case m := <-dead:
+		if m.Header[broker.DeadLetterRetriesHeader] != "2" || m.Header[broker.DeadLetterTopicHeader] != "test.topic" {
+			t.Fatalf("unexpected dead letter headers %+v", m.Header)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the message to be dead lettered")
+	}
+}
+
+func TestJetStreamDefaults(t *testing.T) {
+	s, stop := runServer(t)
+	defer stop()
+
+	maxDeliver := DefaultMaxDeliver
+	DefaultMaxDeliver = 2
+	defer func() {
+		DefaultMaxDeliver = maxDeliver
+	}()
+
+	b := NewBroker(broker.Addrs(s.ClientURL()))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	if err := b.Publish("test.topic", &broker.Message{Body: []byte("1")}, broker.Ack()); err != nil {
+		t.Fatal(err)
+	}
+
+	recv := make(chan string, 10)
+	if _, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		recv <- string(m.Body)
+		return errors.New("failed")
+	}, broker.HandleError(func(*broker.Message, error) {})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Publish("test.topic", &broker.Message{Body: []byte("2")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the new message is delivered, at most max deliver times
+	var got []string
+
+	for {
+		select {
+		case m := <-recv:
+			got = append(got, m)
+			continue
+		case <-time.After(time.Second):
+		}
+		break
+	}
+
+	if len(got) != 2 || got[0] != "2" || got[1] != "2" {
+		t.Fatalf("expected message 2 delivered twice got %v", got)
+	}
+}
+
+func TestJetStreamPublishBatch(t *testing.T) {
+	s, stop := runServer(t)
+	defer stop()
+
+	b := NewBroker(broker.Addrs(s.ClientURL()))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	var msgs []*broker.Message
+	for _, body := range []string{"1", "2", "3"} {
+		msgs = append(msgs, &broker.Message{Header: map[string]string{}, Body: []byte(body)})
+	}
+
+	if err := broker.PublishBatch(b, "test.batch", msgs, broker.Ack()); err != nil {
+		t.Fatal(err)
+	}
+
+	recv := make(chan string, 3)
+	if _, err := b.Subscribe("test.batch", func(m *broker.Message) error {
+		recv <- string(m.Body)
+		return nil
+	}, DeliverAll()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expect := range []string{"1", "2", "3"} {
+		select {
+		case got := <-recv:
+			if got != expect {
+				t.Fatalf("expected message %s got %s", expect, got)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expected message %s", expect)
+		}
+	}
+}
+
+func TestJetStreamResilience(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jetstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := &server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  dir,
+	}
+
+	s, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(time.Second * 5) {
+		t.Fatal("nats server not ready")
+	}
+
+	b := NewBroker(
+		broker.Addrs(s.ClientURL()),
+		broker.Resilience(10, broker.DropNewest),
+		ConnectOptions(nats.ReconnectWait(time.Millisecond*50)),
+	)
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	recv := make(chan string, 10)
+	if _, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		recv <- string(m.Body)
+		return nil
+	}, broker.Queue("test.workers")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Publish("test.topic", &broker.Message{Body: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart the server on the same port and store
+	opts.Port = s.Addr().(*net.TCPAddr).Port
+	s.Shutdown()
+	s.WaitForShutdown()
+
+	for b.(*jsBroker).conn.IsConnected() {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// published while reconnecting
+	for _, body := range []string{"2", "3"} {
+		if err := b.Publish("test.topic", &broker.Message{Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if l := b.Options().Buffer.Len(); l != 2 {
+		t.Fatalf("expected 2 buffered messages got %d", l)
+	}
+
+	s, err = server.NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Shutdown()
+
+	// the subscription resumes and receives the buffered messages in order
+	for _, expect := range []string{"1", "2", "3"} {
+		select {
+		case got := <-recv:
+			if got != expect {
+				t.Fatalf("expected message %s got %s", expect, got)
+			}
+		case <-time.After(time.Second * 10):
+			t.Fatalf("expected message %s", expect)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package jetstream
+
+import (
+	"context"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/nats-io/nats.go"
+)
+
+type connectKey struct{}
+type streamKey struct{}
+type subOptsKey struct{}
+
+func setBrokerOption(k, v interface{}) broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// subOpt appends the nats option to the subscribe options
+func subOpt(opt nats.SubOpt) broker.SubscribeOption {
+	return func(o *broker.SubscribeOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		prev, _ := o.Context.Value(subOptsKey{}).([]nats.SubOpt)
+		opts := append(append([]nats.SubOpt{}, prev...), opt)
+		o.Context = context.WithValue(o.Context, subOptsKey{}, opts)
+	}
+}
+
+// ConnectOptions are applied to the nats connection
+func ConnectOptions(opts ...nats.Option) broker.Option {
+	return setBrokerOption(connectKey{}, opts)
+}
+
+// StreamConfig is the template of the streams created for topics. The
+// name and subjects are set per topic, everything else e.g storage,
+// retention and max age is taken from the config.
+func StreamConfig(cfg nats.StreamConfig) broker.Option {
+	return setBrokerOption(streamKey{}, cfg)
+}
+
+// Durable names the consumer so it resumes from its last acked message
+// after a restart. Subscribers with a queue are durable by default.
+func Durable(name string) broker.SubscribeOption {
+	return subOpt(nats.Durable(validName(name)))
+}
+
+// DeliverAll replays the stream from the first message
+func DeliverAll() broker.SubscribeOption {
+	return subOpt(nats.DeliverAll())
+}
+
+// DeliverNew only delivers messages published after subscribing, the
+// default
+func DeliverNew() broker.SubscribeOption {
+	return subOpt(nats.DeliverNew())
+}
+
+// StartSequence replays the stream from the sequence
+func StartSequence(seq uint64) broker.SubscribeOption {
+	return subOpt(nats.StartSequence(seq))
+}
+
+// StartTime replays the stream from the messages published at or after t
+func StartTime(t time.Time) broker.SubscribeOption {
+	return subOpt(nats.StartTime(t))
+}
+
+// MaxDeliver limits how often a message which isn't acked is delivered,
+// DefaultMaxDeliver by default
+func MaxDeliver(n int) broker.SubscribeOption {
+	return subOpt(nats.MaxDeliver(n))
+}
+
+// MaxAckPending limits the messages delivered but not yet acked
+func MaxAckPending(n int) broker.SubscribeOption {
+	return subOpt(nats.MaxAckPending(n))
+}
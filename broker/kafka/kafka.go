@@ -0,0 +1,506 @@
+// Package kafka provides a kafka broker using consumer groups
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/util/backoff"
+	"github.com/google/uuid"
+)
+
+var (
+	// DefaultAddress of the kafka cluster
+	DefaultAddress = "127.0.0.1:9092"
+	// DefaultRedeliveryBackoff before redelivering a message which failed
+	DefaultRedeliveryBackoff = backoff.ExpJitter(time.Millisecond*100, time.Second*30)
+)
+
+type kafkaBroker struct {
+	opts broker.Options
+
+	sync.RWMutex
+	addrs     []string
+	config    *sarama.Config
+	client    sarama.Client
+	producer  sarama.SyncProducer
+	connected bool
+	subs      []*subscriber
+	// kafka has no delayed delivery
+	scheduler *broker.Scheduler
+}
+
+type subscriber struct {
+	topic  string
+	opts   broker.SubscribeOptions
+	group  sarama.ConsumerGroup
+	cancel context.CancelFunc
+	done   chan bool
+	once   sync.Once
+	c      *consumer
+}
+
+// consumer handles the claims of a consumer group session
+type consumer struct {
+	handler    broker.Handler
+	opts       broker.SubscribeOptions
+	autoCommit bool
+
+	sync.Mutex
+	// lag of the claimed partitions
+	lag map[int32]int64
+	// messages failed in a row without auto commit
+	failures int
+}
+
+func (k *kafkaBroker) Options() broker.Options {
+	return k.opts
+}
+
+func (k *kafkaBroker) Address() string {
+	k.RLock()
+	defer k.RUnlock()
+
+	if len(k.addrs) > 0 {
+		return k.addrs[0]
+	}
+	return DefaultAddress
+}
+
+// saramaConfig returns the sarama config with the broker options applied
+func (k *kafkaBroker) saramaConfig() *sarama.Config {
+	cfg := sarama.NewConfig()
+	if c, ok := k.opts.Context.Value(configKey{}).(*sarama.Config); ok && c != nil {
+		cp := *c
+		cfg = &cp
+	}
+
+	// headers need at least kafka 0.11
+	if !cfg.Version.IsAtLeast(sarama.V0_11_0_0) {
+		cfg.Version = sarama.V0_11_0_0
+	}
+
+	// the sync producer needs both set
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Return.Errors = true
+	cfg.Consumer.Return.Errors = true
+
+	if k.opts.Secure || k.opts.TLSConfig != nil {
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = k.opts.TLSConfig
+	}
+
+	if s, ok := k.opts.Context.Value(saslKey{}).(sasl); ok {
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		cfg.Net.SASL.User = s.user
+		cfg.Net.SASL.Password = s.password
+	}
+
+	return cfg
+}
+
+func (k *kafkaBroker) Connect() error {
+	k.Lock()
+	defer k.Unlock()
+
+	if k.connected {
+		return nil
+	}
+
+	cfg := k.saramaConfig()
+
+	client, err := sarama.NewClient(k.addrs, cfg)
+	if err != nil {
+		return err
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	k.config = cfg
+	k.client = client
+	k.producer = producer
+	k.connected = true
+
+	k.scheduler = broker.NewScheduler(k.opts.Store, func(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+		return k.publish(topic, msg, opts...)
+	})
+	k.scheduler.Start()
+
+	return nil
+}
+
+func (k *kafkaBroker) Disconnect() error {
+	k.Lock()
+	if !k.connected {
+		k.Unlock()
+		return nil
+	}
+
+	subs := k.subs
+	k.subs = nil
+	k.connected = false
+	producer := k.producer
+	client := k.client
+	k.scheduler.Stop()
+	k.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+
+	if err := producer.Close(); err != nil {
+		return err
+	}
+
+	return client.Close()
+}
+
+func (k *kafkaBroker) Init(opts ...broker.Option) error {
+	k.Lock()
+	defer k.Unlock()
+
+	for _, o := range opts {
+		o(&k.opts)
+	}
+
+	k.addrs = addrs(k.opts.Addrs)
+
+	return nil
+}
+
+func (k *kafkaBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return broker.WrapPublishFunc(k.opts, k.publish)(topic, msg, opts...)
+}
+
+func (k *kafkaBroker) publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	k.RLock()
+	if !k.connected {
+		k.RUnlock()
+		return errors.New("not connected")
+	}
+	producer := k.producer
+	scheduler := k.scheduler
+	k.RUnlock()
+
+	options := broker.PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.DeliverAt.After(time.Now()) {
+		return scheduler.Schedule(topic, msg, options)
+	}
+
+	_, _, err := producer.SendMessage(producerMessage(topic, msg, options))
+	if err != nil && options.Ack {
+		return &broker.NackError{Topic: topic, Reason: err.Error()}
+	}
+
+	return err
+}
+
+// PublishBatch sends the messages in a single request
+func (k *kafkaBroker) PublishBatch(topic string, msgs []*broker.Message, opts ...broker.PublishOption) error {
+	k.RLock()
+	if !k.connected {
+		k.RUnlock()
+		return errors.New("not connected")
+	}
+	producer := k.producer
+	k.RUnlock()
+
+	options := broker.PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	pms := make([]*sarama.ProducerMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		pms = append(pms, producerMessage(topic, msg, options))
+	}
+
+	err := producer.SendMessages(pms)
+	if err != nil && options.Ack {
+		return &broker.NackError{Topic: topic, Reason: err.Error()}
+	}
+
+	return err
+}
+
+func producerMessage(topic string, msg *broker.Message, options broker.PublishOptions) *sarama.ProducerMessage {
+	pm := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(msg.Body),
+	}
+
+	// messages with the same key are written to the same partition
+	if len(options.Key) > 0 {
+		pm.Key = sarama.StringEncoder(options.Key)
+	}
+
+	for hk, hv := range msg.Header {
+		pm.Headers = append(pm.Headers, sarama.RecordHeader{Key: []byte(hk), Value: []byte(hv)})
+	}
+
+	return pm
+}
+
+func (k *kafkaBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	return broker.WrapSubscribeFunc(k.opts, k.subscribe)(topic, handler, opts...)
+}
+
+func (k *kafkaBroker) subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	k.RLock()
+	if !k.connected {
+		k.RUnlock()
+		return nil, errors.New("not connected")
+	}
+	cfg := *k.config
+	addrs := k.addrs
+	k.RUnlock()
+
+	options := broker.SubscribeOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// subscribers without a queue each receive every message
+	group := options.Queue
+	if len(group) == 0 {
+		group = uuid.New().String()
+	}
+
+	// messages buffered per partition
+	if options.Prefetch > 0 {
+		cfg.ChannelBufferSize = options.Prefetch
+	}
+
+	if offset, ok := options.Context.Value(offsetKey{}).(int64); ok {
+		cfg.Consumer.Offsets.Initial = offset
+	}
+
+	autoCommit := true
+	if b, ok := options.Context.Value(autoCommitKey{}).(bool); ok {
+		autoCommit = b
+	}
+
+	cg, err := sarama.NewConsumerGroup(addrs, group, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &consumer{
+		handler:    broker.DeadLetterHandler(k, topic, handler, options),
+		opts:       options,
+		autoCommit: autoCommit,
+		lag:        make(map[int32]int64),
+	}
+
+	sub := &subscriber{
+		topic:  topic,
+		opts:   options,
+		group:  cg,
+		cancel: cancel,
+		done:   make(chan bool),
+		c:      c,
+	}
+
+	go func() {
+		for err := range cg.Errors() {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[kafka] consumer group %s error: %v", group, err)
+			}
+		}
+	}()
+
+	go func() {
+		defer close(sub.done)
+
+		for {
+			// consume returns when the group rebalances
+			if err := cg.Consume(ctx, []string{topic}, c); err != nil {
+				if err == sarama.ErrClosedConsumerGroup {
+					return
+				}
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("[kafka] consume %s error: %v", topic, err)
+				}
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			// back off redelivering a message which failed
+			c.Lock()
+			failures := c.failures
+			c.Unlock()
+			if failures == 0 {
+				continue
+			}
+
+			select {
+			case <-time.After(DefaultRedeliveryBackoff(failures)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	k.Lock()
+	k.subs = append(k.subs, sub)
+	k.Unlock()
+
+	return sub, nil
+}
+
+func (k *kafkaBroker) String() string {
+	return "kafka"
+}
+
+func (c *consumer) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (c *consumer) Cleanup(sess sarama.ConsumerGroupSession) error {
+	// partitions may be claimed by another member after a rebalance
+	c.Lock()
+	for _, partitions := range sess.Claims() {
+		for _, p := range partitions {
+			delete(c.lag, p)
+		}
+	}
+	c.Unlock()
+	return nil
+}
+
+func (c *consumer) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		// messages after this one up to the high water mark
+		c.Lock()
+		c.lag[msg.Partition] = claim.HighWaterMarkOffset() - msg.Offset - 1
+		c.Unlock()
+
+		m := &broker.Message{
+			Header: make(map[string]string, len(msg.Headers)),
+			Body:   msg.Value,
+		}
+		for _, h := range msg.Headers {
+			m.Header[string(h.Key)] = string(h.Value)
+		}
+
+		err := c.handler(m)
+		if err != nil {
+			if eh := c.opts.ErrorHandler; eh != nil {
+				eh(m, err)
+			} else if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[kafka] subscriber %s error: %v", msg.Topic, err)
+			}
+		}
+
+		if err != nil && !c.autoCommit {
+			// stop consuming so the session ends and the partition is
+			// consumed again from the failed offset, marking the later
+			// messages would commit past it
+			c.Lock()
+			c.failures++
+			c.Unlock()
+			return nil
+		}
+
+		c.Lock()
+		c.failures = 0
+		c.Unlock()
+
+		sess.MarkMessage(msg, "")
+	}
+
+	return nil
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *subscriber) Topic() string {
+	return s.topic
+}
+
+// Lag returns the offsets behind the high water mark of the partitions
+// claimed by the subscriber
+func (s *subscriber) Lag() (int64, error) {
+	s.c.Lock()
+	defer s.c.Unlock()
+
+	var lag int64
+	for _, l := range s.c.lag {
+		lag += l
+	}
+	return lag, nil
+}
+
+func (s *subscriber) Unsubscribe() error {
+	var err error
+
+	s.once.Do(func() {
+		s.cancel()
+		err = s.group.Close()
+		<-s.done
+	})
+
+	return err
+}
+
+// addrs strips the scheme of the addresses
+func addrs(a []string) []string {
+	var addrs []string
+
+	for _, addr := range a {
+		addr = strings.TrimPrefix(addr, "kafka://")
+		if len(addr) == 0 {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+
+	if len(addrs) == 0 {
+		addrs = []string{DefaultAddress}
+	}
+
+	return addrs
+}
+
+// NewBroker returns a kafka broker
+func NewBroker(opts ...broker.Option) broker.Broker {
+	options := broker.Options{
+		Context: context.Background(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &kafkaBroker{
+		opts:  options,
+		addrs: addrs(options.Addrs),
+	}
+}
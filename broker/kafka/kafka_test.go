@@ -0,0 +1,134 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/asim/go-micro/v3/broker"
+)
+
+func TestPublish(t *testing.T) {
+	seed := sarama.NewMockBroker(t, 1)
+	defer seed.Close()
+
+	seed.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(seed.Addr(), seed.BrokerID()).
+			SetLeader("test", 0, seed.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3),
+	})
+
+	b := NewBroker(broker.Addrs(seed.Addr()))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	msg := &broker.Message{
+		Header: map[string]string{"Micro-Topic": "test"},
+		Body:   []byte(`{"id":1}`),
+	}
+
+	if err := b.Publish("test", msg, broker.WithKey("customer-123")); err != nil {
+		t.Fatal(err)
+	}
+
+	// batches are sent natively
+	if err := broker.PublishBatch(b, "test", []*broker.Message{msg, msg}); err != nil {
+		t.Fatal(err)
+	}
+
+	var produced int
+	for _, r := range seed.History() {
+		if _, ok := r.Request.(*sarama.ProduceRequest); ok {
+			produced++
+		}
+	}
+	if produced < 2 {
+		t.Fatalf("expected the messages to be produced got %d requests", produced)
+	}
+}
+
+type testSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type testClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (c *testClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.msgs
+}
+
+func (c *testClaim) HighWaterMarkOffset() int64 {
+	return 5
+}
+
+func TestConsumeClaim(t *testing.T) {
+	for _, autoCommit := range []bool{true, false} {
+		claim := &testClaim{msgs: make(chan *sarama.ConsumerMessage, 3)}
+		claim.msgs <- &sarama.ConsumerMessage{
+			Topic:   "test",
+			Offset:  1,
+			Value:   []byte("ok"),
+			Headers: []*sarama.RecordHeader{{Key: []byte("Micro-Id"), Value: []byte("1")}},
+		}
+		claim.msgs <- &sarama.ConsumerMessage{Topic: "test", Offset: 2, Value: []byte("fail")}
+		claim.msgs <- &sarama.ConsumerMessage{Topic: "test", Offset: 3, Value: []byte("ok")}
+		close(claim.msgs)
+
+		var handled []*broker.Message
+
+		c := &consumer{
+			lag:        make(map[int32]int64),
+			autoCommit: autoCommit,
+			handler: func(m *broker.Message) error {
+				handled = append(handled, m)
+				if string(m.Body) == "fail" {
+					return errors.New("failed")
+				}
+				return nil
+			},
+		}
+
+		sess := new(testSession)
+		if err := c.ConsumeClaim(sess, claim); err != nil {
+			t.Fatal(err)
+		}
+
+		// without auto commit the claim stops at the failed message so the
+		// later ones don't commit past it
+		expect, failures := 3, 0
+		if !autoCommit {
+			expect, failures = 2, 1
+		}
+		if len(handled) != expect || handled[0].Header["Micro-Id"] != "1" {
+			t.Fatalf("auto commit %v: unexpected messages %+v", autoCommit, handled)
+		}
+		if c.failures != failures {
+			t.Fatalf("auto commit %v: expected %d failures got %d", autoCommit, failures, c.failures)
+		}
+
+		marked := []int64{1, 2, 3}
+		if !autoCommit {
+			marked = []int64{1}
+		}
+		if fmt.Sprint(sess.marked) != fmt.Sprint(marked) {
+			t.Fatalf("auto commit %v: expected offsets %v marked got %v", autoCommit, marked, sess.marked)
+		}
+
+		// the offsets after the last handled are behind the high water mark
+		if lag, _ := (&subscriber{c: c}).Lag(); lag != int64(4-expect) {
+			t.Fatalf("expected a lag of %d got %d", 4-expect, lag)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"github.com/asim/go-micro/v3/broker"
+)
+
+type configKey struct{}
+type saslKey struct{}
+type autoCommitKey struct{}
+type offsetKey struct{}
+
+type sasl struct {
+	user     string
+	password string
+}
+
+func setBrokerOption(k, v interface{}) broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+func setSubscribeOption(k, v interface{}) broker.SubscribeOption {
+	return func(o *broker.SubscribeOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// Config sets the sarama config the producer and consumers are created
+// with. The broker TLS and SASL options are applied on top of it.
+func Config(c *sarama.Config) broker.Option {
+	return setBrokerOption(configKey{}, c)
+}
+
+// SASL authenticates with the username and password using SASL/PLAIN.
+// Other mechanisms can be set in the Config.
+func SASL(user, password string) broker.Option {
+	return setBrokerOption(saslKey{}, sasl{user: user, password: password})
+}
+
+// AutoCommit marks the offset of every message once the handler returns.
+// When false a failed message stops the consumption of its partition, the
+// group session is restarted with backoff and the partition is consumed
+// again from the failed message. The default is true.
+func AutoCommit(b bool) broker.SubscribeOption {
+	return setSubscribeOption(autoCommitKey{}, b)
+}
+
+// InitialOffset sets where a group without a committed offset starts
+// consuming, sarama.OffsetNewest or sarama.OffsetOldest
+func InitialOffset(offset int64) broker.SubscribeOption {
+	return setSubscribeOption(offsetKey{}, offset)
+}
@@ -20,6 +20,7 @@ type memoryBroker struct {
 	addr string
 	sync.RWMutex
 	connected   bool
+	scheduler   *broker.Scheduler
 	Subscribers map[string][]*memorySubscriber
 }
 
@@ -41,15 +42,16 @@ func (m *memoryBroker) Address() string {
 
 func (m *memoryBroker) Connect() error {
 	m.Lock()
-	defer m.Unlock()
 
 	if m.connected {
+		m.Unlock()
 		return nil
 	}
 
 	// use 127.0.0.1 to avoid scan of all network interfaces
 	addr, err := maddr.Extract("127.0.0.1")
 	if err != nil {
+		m.Unlock()
 		return err
 	}
 	i := rand.Intn(20000)
@@ -59,6 +61,18 @@ func (m *memoryBroker) Connect() error {
 	m.addr = addr
 	m.connected = true
 
+	// deliver delayed messages
+	m.scheduler = broker.NewScheduler(m.opts.Store, func(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+		return m.publish(topic, msg, opts...)
+	})
+	m.scheduler.Start()
+	m.Unlock()
+
+	// publish the messages buffered while disconnected
+	if b := m.opts.Buffer; b != nil {
+		return b.Flush(m.send)
+	}
+
 	return nil
 }
 
@@ -71,6 +85,7 @@ func (m *memoryBroker) Disconnect() error {
 	}
 
 	m.connected = false
+	m.scheduler.Stop()
 
 	return nil
 }
@@ -83,6 +98,29 @@ func (m *memoryBroker) Init(opts ...broker.Option) error {
 }
 
 func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return broker.WrapPublishFunc(m.opts, m.publish)(topic, msg, opts...)
+}
+
+func (m *memoryBroker) publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	m.RLock()
+	connected := m.connected
+	m.RUnlock()
+
+	b := m.opts.Buffer
+	if b == nil {
+		return m.send(topic, msg, opts...)
+	}
+
+	if !connected {
+		return m.buffer(b, topic, msg, opts...)
+	}
+
+	return b.Publish(m.send, topic, msg, opts...)
+}
+
+// send delivers the message to the subscribers, it's never buffered so the
+// buffer is flushed with it
+func (m *memoryBroker) send(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
 	m.RLock()
 	if !m.connected {
 		m.RUnlock()
@@ -90,24 +128,82 @@ func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker
 	}
 
 	subs, ok := m.Subscribers[topic]
+	scheduler := m.scheduler
 	m.RUnlock()
+
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.DeliverAt.After(time.Now()) {
+		return scheduler.Schedule(topic, msg, options)
+	}
+
 	if !ok {
 		return nil
 	}
 
+	return deliver(topic, msg, subs, options)
+}
+
+// deliver sends the message to the subscribers. With Ack the message is
+// rejected if any subscriber fails, retrying delivers it to those which
+// failed alone.
+func deliver(topic string, msg *broker.Message, subs []*memorySubscriber, options broker.PublishOptions) error {
+	var (
+		failed []*memorySubscriber
+		reason string
+	)
+
 	for _, sub := range subs {
 		if err := sub.handler(msg); err != nil {
 			if eh := sub.opts.ErrorHandler; eh != nil {
 				eh(msg, err)
 			}
-			continue
+			if len(failed) == 0 {
+				reason = err.Error()
+			}
+			failed = append(failed, sub)
 		}
 	}
 
+	if !options.Ack || len(failed) == 0 {
+		return nil
+	}
+
+	return &broker.NackError{
+		Topic:  topic,
+		Reason: reason,
+		Retry: func() error {
+			return deliver(topic, msg, failed, options)
+		},
+	}
+}
+
+// buffer adds the message to the buffer, it's flushed right away if the
+// broker connected meanwhile
+func (m *memoryBroker) buffer(b *broker.Buffer, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	if err := b.Add(topic, msg, opts...); err != nil {
+		return err
+	}
+
+	m.RLock()
+	connected := m.connected
+	m.RUnlock()
+
+	if connected {
+		return b.Flush(m.send)
+	}
+
 	return nil
 }
 
 func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	return broker.WrapSubscribeFunc(m.opts, m.subscribe)(topic, handler, opts...)
+}
+
+func (m *memoryBroker) subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
 	m.RLock()
 	if !m.connected {
 		m.RUnlock()
@@ -120,6 +216,13 @@ func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...b
 		o(&options)
 	}
 
+	handler = broker.DeadLetterHandler(m, topic, handler, options)
+
+	// publishers block while the subscriber has prefetch messages in flight
+	if options.Prefetch > 0 {
+		handler = prefetch(options.Prefetch, handler)
+	}
+
 	sub := &memorySubscriber{
 		exit:    make(chan bool, 1),
 		id:      uuid.New().String(),
@@ -149,6 +252,18 @@ func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...b
 	return sub, nil
 }
 
+func prefetch(n int, h broker.Handler) broker.Handler {
+	sem := make(chan bool, n)
+
+	return func(msg *broker.Message) error {
+		sem <- true
+		defer func() {
+			<-sem
+		}()
+		return h(msg)
+	}
+}
+
 func (m *memoryBroker) String() string {
 	return "memory"
 }
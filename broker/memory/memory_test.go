@@ -1,10 +1,14 @@
 package memory
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/asim/go-micro/v3/broker"
+	mstore "github.com/asim/go-micro/v3/store/memory"
 )
 
 func TestMemoryBroker(t *testing.T) {
@@ -48,3 +52,393 @@ func TestMemoryBroker(t *testing.T) {
 		t.Fatalf("Unexpected connect error %v", err)
 	}
 }
+
+func TestMemoryBrokerDeadLetter(t *testing.T) {
+	b := NewBroker()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	var attempts int
+	_, err := b.Subscribe("test", func(m *broker.Message) error {
+		attempts++
+		return fmt.Errorf("failed")
+	}, broker.DeadLetter("test.dlq", 3))
+	if err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	var dead []*broker.Message
+	_, err = b.Subscribe("test.dlq", func(m *broker.Message) error {
+		dead = append(dead, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := b.Publish("test", &broker.Message{Header: map[string]string{"id": "1"}, Body: []byte(`hello`)}, broker.Ack()); err != nil {
+		t.Fatalf("Expected the dead lettered message to be acked got %v", err)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("Expected 3 attempts got %d", attempts)
+	}
+
+	if len(dead) != 1 {
+		t.Fatalf("Expected 1 dead letter got %d", len(dead))
+	}
+
+	h := dead[0].Header
+	if h["id"] != "1" || h[broker.DeadLetterTopicHeader] != "test" || h[broker.DeadLetterErrorHeader] != "failed" || h[broker.DeadLetterRetriesHeader] != "3" {
+		t.Fatalf("Unexpected dead letter headers %+v", h)
+	}
+}
+
+func TestMemoryBrokerDelay(t *testing.T) {
+	s := mstore.NewStore()
+
+	b := NewBroker(broker.Store(s))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	recv := make(chan *broker.Message, 1)
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		recv <- m
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := b.Publish("test", &broker.Message{Body: []byte(`hello`)}, broker.Delay(time.Millisecond*300)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+
+	select {
+	case <-recv:
+		t.Fatal("Expected the message to be delayed")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	// scheduled messages are kept in the store across restarts
+	b.Disconnect()
+
+	b = NewBroker(broker.Store(s))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		recv <- m
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	select {
+	case m := <-recv:
+		if string(m.Body) != "hello" {
+			t.Fatalf("Unexpected message %s", m.Body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the delayed message to be delivered")
+	}
+}
+
+func TestMemoryBrokerPublishBatch(t *testing.T) {
+	b := NewBroker()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	var got []string
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		got = append(got, string(m.Body))
+		if string(m.Body) == "2" {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	msgs := []*broker.Message{{Body: []byte("1")}, {Body: []byte("2")}, {Body: []byte("3")}}
+
+	// the fallback publishes in order and stops at the first error
+	if err := broker.PublishBatch(b, "test", msgs, broker.Ack()); err == nil {
+		t.Fatal("Expected the batch to be rejected")
+	}
+
+	if len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Fatalf("Unexpected messages %v", got)
+	}
+}
+
+func TestMemoryBrokerWrappers(t *testing.T) {
+	var calls []string
+
+	pub := func(fn broker.PublishFunc) broker.PublishFunc {
+		return func(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+			calls = append(calls, "publish "+topic)
+			return fn(topic, m, opts...)
+		}
+	}
+
+	sub := func(fn broker.SubscribeFunc) broker.SubscribeFunc {
+		return func(topic string, h broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+			return fn(topic, func(m *broker.Message) error {
+				calls = append(calls, "handle "+topic)
+				return h(m)
+			}, opts...)
+		}
+	}
+
+	b := NewBroker(broker.WrapPublish(pub), broker.WrapSubscribe(sub))
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		calls = append(calls, "handler")
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := b.Publish("test", &broker.Message{Body: []byte(`hello`)}); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+
+	if fmt.Sprint(calls) != "[publish test handle test handler]" {
+		t.Fatalf("Unexpected calls %v", calls)
+	}
+}
+
+func TestMemoryBrokerPrefetch(t *testing.T) {
+	b := NewBroker()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	var mtx sync.Mutex
+	var inflight, max int
+
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		mtx.Lock()
+		inflight++
+		if inflight > max {
+			max = inflight
+		}
+		mtx.Unlock()
+
+		time.Sleep(time.Millisecond * 10)
+
+		mtx.Lock()
+		inflight--
+		mtx.Unlock()
+		return nil
+	}, broker.SubscribePrefetch(2)); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Publish("test", &broker.Message{Body: []byte(`hello`)})
+		}()
+	}
+	wg.Wait()
+
+	if max > 2 {
+		t.Fatalf("Expected at most 2 messages in flight got %d", max)
+	}
+}
+
+func TestMemoryBrokerMetrics(t *testing.T) {
+	m := broker.NewMetrics()
+	b := NewBroker(broker.WithMetrics(m))
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		return fmt.Errorf("failed")
+	}, broker.DeadLetter("test.dlq", 3)); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	sub, err := b.Subscribe("test.dlq", func(m *broker.Message) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := b.Publish("test", &broker.Message{Body: []byte(`hello`)}); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+
+	stats := m.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats of 2 topics got %d", len(stats))
+	}
+
+	// the retries of the handler are redeliveries
+	if s := stats[0]; s.Topic != "test" || s.Published != 1 || s.Consumed != 3 || s.ConsumeErrors != 3 || s.Redelivered != 2 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+
+	if s := stats[1]; s.Topic != "test.dlq" || s.Published != 1 || s.Consumed != 1 || s.ConsumeErrors != 0 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+}
+
+func TestMemoryBrokerResilience(t *testing.T) {
+	b := NewBroker(broker.Resilience(2, broker.DropOldest))
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	var received []string
+
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		received = append(received, string(m.Body))
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := b.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error %v", err)
+	}
+
+	// the oldest message is dropped once the buffer is full
+	for _, body := range []string{"1", "2", "3"} {
+		if err := b.Publish("test", &broker.Message{Body: []byte(body)}); err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	}
+
+	if len(received) != 0 {
+		t.Fatalf("Expected no messages while disconnected got %v", received)
+	}
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	if fmt.Sprint(received) != "[2 3]" {
+		t.Fatalf("Expected the buffered messages in order got %v", received)
+	}
+
+	if l := b.Options().Buffer.Len(); l != 0 {
+		t.Fatalf("Expected the buffer to be flushed got %d messages", l)
+	}
+}
+
+func TestMemoryBrokerResilienceFlushDisconnect(t *testing.T) {
+	b := NewBroker(broker.Resilience(2, broker.Block))
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	var received []string
+
+	if _, err := b.Subscribe("test", func(m *broker.Message) error {
+		received = append(received, string(m.Body))
+		// disconnected while flushing
+		if string(m.Body) == "1" {
+			b.Disconnect()
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := b.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error %v", err)
+	}
+
+	// the buffer is full
+	for _, body := range []string{"1", "2"} {
+		if err := b.Publish("test", &broker.Message{Body: []byte(body)}); err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	}
+
+	// the flush stops at the message which couldn't be sent
+	b.Connect()
+
+	if fmt.Sprint(received) != "[1]" {
+		t.Fatalf("Expected the first message got %v", received)
+	}
+	if l := b.Options().Buffer.Len(); l != 1 {
+		t.Fatalf("Expected the unsent message to stay buffered got %d messages", l)
+	}
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	if fmt.Sprint(received) != "[1 2]" {
+		t.Fatalf("Expected the buffered messages in order got %v", received)
+	}
+}
+
+func TestMemoryBrokerResilienceOverflow(t *testing.T) {
+	b := NewBroker(broker.Resilience(1, broker.DropNewest))
+
+	if err := b.Publish("test", &broker.Message{Body: []byte(`1`)}); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	if err := b.Publish("test", &broker.Message{Body: []byte(`2`)}); err != broker.ErrBufferFull {
+		t.Fatalf("Expected buffer full got %v", err)
+	}
+
+	b = NewBroker(broker.Resilience(1, broker.Block))
+
+	if err := b.Publish("test", &broker.Message{Body: []byte(`1`)}); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+
+	// blocks until there's room or the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	if err := b.Publish("test", &broker.Message{Body: []byte(`2`)}, broker.PublishContext(ctx)); err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Publish("test", &broker.Message{Body: []byte(`2`)})
+	}()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the blocked publish to return once flushed")
+	}
+	if l := b.Options().Buffer.Len(); l != 0 {
+		t.Fatalf("Expected the buffer to be flushed got %d messages", l)
+	}
+}
@@ -0,0 +1,184 @@
+package broker
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DeliveryCountHeader is the number of times the message was delivered,
+// set by brokers which redeliver failed messages
+const DeliveryCountHeader = "Micro-Delivery-Count"
+
+// Lagger is implemented by subscribers which report their backlog
+type Lagger interface {
+	// Lag returns the number of messages not yet consumed
+	Lag() (int64, error)
+}
+
+// TopicStat are the publish and consume counters of a topic
+type TopicStat struct {
+	// Topic of the messages
+	Topic string `json:"topic"`
+	// Published messages
+	Published uint64 `json:"published"`
+	// PublishErrors returned by the broker
+	PublishErrors uint64 `json:"publish_errors"`
+	// Consumed messages handled by subscribers
+	Consumed uint64 `json:"consumed"`
+	// ConsumeErrors returned by the handlers
+	ConsumeErrors uint64 `json:"consume_errors"`
+	// Redelivered messages consumed more than once
+	Redelivered uint64 `json:"redelivered"`
+	// Latency is the total latency of the handlers
+	Latency time.Duration `json:"latency"`
+	// Lag is the backlog of the subscribers where supported
+	Lag int64 `json:"lag"`
+}
+
+// Metrics records the publish and consume counters per topic. Set it
+// with WithMetrics to record every message of the broker.
+type Metrics struct {
+	sync.Mutex
+	stats map[string]*TopicStat
+	subs  map[*metricsSubscriber]bool
+}
+
+type metricsSubscriber struct {
+	Subscriber
+	m *Metrics
+}
+
+// NewMetrics returns an empty metrics recorder
+func NewMetrics() *Metrics {
+	return &Metrics{
+		stats: make(map[string]*TopicStat),
+		subs:  make(map[*metricsSubscriber]bool),
+	}
+}
+
+// stat returns the stat of the topic, the lock must be held
+func (m *Metrics) stat(topic string) *TopicStat {
+	stat, ok := m.stats[topic]
+	if !ok {
+		stat = &TopicStat{Topic: topic}
+		m.stats[topic] = stat
+	}
+	return stat
+}
+
+// PublishWrapper counts the messages published
+func (m *Metrics) PublishWrapper() PublishWrapper {
+	return func(fn PublishFunc) PublishFunc {
+		return func(topic string, msg *Message, opts ...PublishOption) error {
+			err := fn(topic, msg, opts...)
+
+			m.Lock()
+			stat := m.stat(topic)
+			stat.Published++
+			if err != nil {
+				stat.PublishErrors++
+			}
+			m.Unlock()
+
+			return err
+		}
+	}
+}
+
+// SubscribeWrapper counts the messages consumed and the handler latency.
+// Messages with a delivery count above one are counted as redelivered.
+func (m *Metrics) SubscribeWrapper() SubscribeWrapper {
+	return func(fn SubscribeFunc) SubscribeFunc {
+		return func(topic string, h Handler, opts ...SubscribeOption) (Subscriber, error) {
+			sub, err := fn(topic, func(msg *Message) error {
+				start := time.Now()
+				err := h(msg)
+				d := time.Since(start)
+
+				count, _ := strconv.Atoi(msg.Header[DeliveryCountHeader])
+
+				m.Lock()
+				stat := m.stat(topic)
+				stat.Consumed++
+				stat.Latency += d
+				if err != nil {
+					stat.ConsumeErrors++
+				}
+				if count > 1 {
+					stat.Redelivered++
+				}
+				m.Unlock()
+
+				return err
+			}, opts...)
+			if err != nil {
+				return nil, err
+			}
+
+			ms := &metricsSubscriber{Subscriber: sub, m: m}
+
+			m.Lock()
+			m.subs[ms] = true
+			m.Unlock()
+
+			return ms, nil
+		}
+	}
+}
+
+// Stats returns the stats of the topics sorted by topic. The lag is
+// read from the subscribers implementing Lagger.
+func (m *Metrics) Stats() []*TopicStat {
+	m.Lock()
+	subs := make([]*metricsSubscriber, 0, len(m.subs))
+	for sub := range m.subs {
+		subs = append(subs, sub)
+	}
+	m.Unlock()
+
+	// read the lag without holding the lock, it may be a request
+	lag := make(map[string]int64)
+	for _, sub := range subs {
+		l, ok := sub.Subscriber.(Lagger)
+		if !ok {
+			continue
+		}
+		if n, err := l.Lag(); err == nil {
+			lag[sub.Topic()] += n
+		}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	stats := make([]*TopicStat, 0, len(m.stats))
+	for _, stat := range m.stats {
+		st := *stat
+		st.Lag = lag[st.Topic]
+		stats = append(stats, &st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Topic < stats[j].Topic
+	})
+
+	return stats
+}
+
+func (s *metricsSubscriber) Unsubscribe() error {
+	s.m.Lock()
+	delete(s.m.subs, s)
+	s.m.Unlock()
+	return s.Subscriber.Unsubscribe()
+}
+
+// WithMetrics records the counters of every message in the metrics
+func WithMetrics(m *Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+		o.PublishWrappers = append(o.PublishWrappers, m.PublishWrapper())
+		o.SubscribeWrappers = append(o.SubscribeWrappers, m.SubscribeWrapper())
+	}
+}
@@ -3,9 +3,11 @@ package broker
 import (
 	"context"
 	"crypto/tls"
+	"time"
 
 	"github.com/asim/go-micro/v3/codec"
 	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/store"
 )
 
 type Options struct {
@@ -16,12 +18,30 @@ type Options struct {
 	TLSConfig *tls.Config
 	// Registry used for clustering
 	Registry registry.Registry
+	// Store keeps messages scheduled for later delivery
+	// where the broker has no native support
+	Store store.Store
+	// PublishWrappers wrap every message published
+	PublishWrappers []PublishWrapper
+	// SubscribeWrappers wrap every subscription
+	SubscribeWrappers []SubscribeWrapper
+	// Metrics of the topics, set with WithMetrics
+	Metrics *Metrics
+	// Buffer keeps the messages published while disconnected,
+	// set with Resilience
+	Buffer *Buffer
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
 }
 
 type PublishOptions struct {
+	// Ack waits for the message to be acknowledged
+	Ack bool
+	// DeliverAt delays delivery of the message until the time
+	DeliverAt time.Time
+	// Key orders the messages published with the same key
+	Key string
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -36,6 +56,15 @@ type SubscribeOptions struct {
 	// receives a subset of messages.
 	Queue string
 
+	// DeadLetter is the topic messages are published to once
+	// the handler failed MaxRetries times
+	DeadLetter string
+	// MaxRetries before a message is dead lettered
+	MaxRetries int
+	// Prefetch limits the messages delivered to the
+	// subscriber which haven't been handled yet
+	Prefetch int
+
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -52,6 +81,36 @@ func PublishContext(ctx context.Context) PublishOption {
 	}
 }
 
+// WithKey sets the ordering key of the message e.g a customer id.
+// Messages with the same key are delivered in order.
+func WithKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.Key = key
+	}
+}
+
+// PublishAt delivers the message at the time
+func PublishAt(t time.Time) PublishOption {
+	return func(o *PublishOptions) {
+		o.DeliverAt = t
+	}
+}
+
+// Delay delivers the message after the duration
+func Delay(d time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.DeliverAt = time.Now().Add(d)
+	}
+}
+
+// Ack waits for the message to be acknowledged. A message which
+// is rejected returns a *NackError with the reason.
+func Ack() PublishOption {
+	return func(o *PublishOptions) {
+		o.Ack = true
+	}
+}
+
 type SubscribeOption func(*SubscribeOptions)
 
 func NewSubscribeOptions(opts ...SubscribeOption) SubscribeOptions {
@@ -87,6 +146,23 @@ func HandleError(h ErrorHandler) SubscribeOption {
 	}
 }
 
+// DeadLetter publishes messages to the topic once the handler failed
+// maxRetries times, with the failure in the Micro-Dead-Letter headers
+func DeadLetter(topic string, maxRetries int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DeadLetter = topic
+		o.MaxRetries = maxRetries
+	}
+}
+
+// SubscribePrefetch limits the messages in flight to the subscriber so
+// slow consumers apply backpressure instead of buffering without bound
+func SubscribePrefetch(n int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Prefetch = n
+	}
+}
+
 // Queue sets the name of the queue to share messages on
 func Queue(name string) SubscribeOption {
 	return func(o *SubscribeOptions) {
@@ -94,6 +170,27 @@ func Queue(name string) SubscribeOption {
 	}
 }
 
+// WrapPublish adds publish wrappers applied to every message published
+func WrapPublish(w ...PublishWrapper) Option {
+	return func(o *Options) {
+		o.PublishWrappers = append(o.PublishWrappers, w...)
+	}
+}
+
+// WrapSubscribe adds subscribe wrappers applied to every subscription
+func WrapSubscribe(w ...SubscribeWrapper) Option {
+	return func(o *Options) {
+		o.SubscribeWrappers = append(o.SubscribeWrappers, w...)
+	}
+}
+
+// Store keeps the messages scheduled with PublishAt or Delay
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
 func Registry(r registry.Registry) Option {
 	return func(o *Options) {
 		o.Registry = r
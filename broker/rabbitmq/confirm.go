@@ -0,0 +1,83 @@
+package rabbitmq
+
+import (
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// confirmer matches the confirms of a channel in confirm mode to the
+// messages published by their delivery tag, so a publish which stopped
+// waiting doesn't leave its confirm to the next one
+type confirmer struct {
+	sync.Mutex
+	// tag of the last message published on the channel
+	tag     uint64
+	waiters map[uint64]chan amqp.Confirmation
+	closed  bool
+}
+
+func newConfirmer(confirms chan amqp.Confirmation) *confirmer {
+	c := &confirmer{
+		waiters: make(map[uint64]chan amqp.Confirmation),
+	}
+	go c.run(confirms)
+	return c
+}
+
+// run passes the confirms to their waiters until the channel is closed
+func (c *confirmer) run(confirms chan amqp.Confirmation) {
+	for conf := range confirms {
+		c.Lock()
+		w, ok := c.waiters[conf.DeliveryTag]
+		delete(c.waiters, conf.DeliveryTag)
+		c.Unlock()
+
+		// nobody waits for the confirms of the publishes given up on
+		if ok {
+			w <- conf
+		}
+	}
+
+	c.Lock()
+	c.closed = true
+	for tag, w := range c.waiters {
+		close(w)
+		delete(c.waiters, tag)
+	}
+	c.Unlock()
+}
+
+// publish calls fn which publishes a message on the channel and returns
+// the channel of its confirm, closed if the channel is. The publishes of
+// the channel have to be serialized so the tags are in order.
+func (c *confirmer) publish(fn func() error) (<-chan amqp.Confirmation, func(), error) {
+	w := make(chan amqp.Confirmation, 1)
+
+	c.Lock()
+	if c.closed {
+		c.Unlock()
+		return nil, nil, amqp.ErrClosed
+	}
+	c.tag++
+	tag := c.tag
+	c.waiters[tag] = w
+	c.Unlock()
+
+	if err := fn(); err != nil {
+		// the tag is only used once the message is sent
+		c.Lock()
+		c.tag--
+		delete(c.waiters, tag)
+		c.Unlock()
+		return nil, nil, err
+	}
+
+	cancel := func() {
+		c.Lock()
+		delete(c.waiters, tag)
+		c.Unlock()
+	}
+
+	return w, cancel, nil
+}
@@ -0,0 +1,118 @@
+package rabbitmq
+
+import (
+	"context"
+
+	"github.com/asim/go-micro/v3/broker"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Exchange types
+const (
+	Direct  = "direct"
+	Fanout  = "fanout"
+	Topic   = "topic"
+	Headers = "headers"
+)
+
+// ExchangeOptions configure the exchange messages are published to
+type ExchangeOptions struct {
+	// Name of the exchange
+	Name string
+	// Type is one of direct, fanout, topic or headers
+	Type string
+	// Durable exchanges survive a broker restart
+	Durable bool
+}
+
+// QueueOptions configure the queue of a subscriber
+type QueueOptions struct {
+	// BindingKey binds the queue to the exchange, the topic by default.
+	// Topic exchanges accept patterns e.g orders.*
+	BindingKey string
+	// Durable queues survive a broker restart, only queues
+	// with a name from broker.Queue are durable
+	Durable bool
+	// NoRequeue discards failed messages instead of requeuing them
+	NoRequeue bool
+	// Args of the queue e.g x-message-ttl
+	Args amqp.Table
+	// BindArgs match the headers of a headers exchange
+	BindArgs amqp.Table
+}
+
+// PublishOptions configure a published message
+type PublishOptions struct {
+	// RoutingKey of the message, the topic by default
+	RoutingKey string
+	// Persistent messages are written to disk
+	Persistent bool
+	// Priority of the message, 0-9
+	Priority uint8
+	// Expiration of the message in milliseconds
+	Expiration string
+}
+
+type exchangeKey struct{}
+type queueKey struct{}
+type publishKey struct{}
+
+// WithExchange sets the exchange of the broker
+func WithExchange(e ExchangeOptions) broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, exchangeKey{}, e)
+	}
+}
+
+// WithQueue sets the queue options of the subscriber
+func WithQueue(q QueueOptions) broker.SubscribeOption {
+	return func(o *broker.SubscribeOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, queueKey{}, q)
+	}
+}
+
+// WithPublish sets the options of the published message
+func WithPublish(p PublishOptions) broker.PublishOption {
+	return func(o *broker.PublishOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, publishKey{}, p)
+	}
+}
+
+func exchangeOptions(ctx context.Context) ExchangeOptions {
+	e, ok := ctx.Value(exchangeKey{}).(ExchangeOptions)
+	if !ok {
+		e = DefaultExchange
+	}
+	if len(e.Name) == 0 {
+		e.Name = DefaultExchange.Name
+	}
+	if len(e.Type) == 0 {
+		e.Type = DefaultExchange.Type
+	}
+	return e
+}
+
+func queueOptions(ctx context.Context) QueueOptions {
+	if ctx == nil {
+		return QueueOptions{}
+	}
+	q, _ := ctx.Value(queueKey{}).(QueueOptions)
+	return q
+}
+
+func publishOptions(ctx context.Context) PublishOptions {
+	if ctx == nil {
+		return PublishOptions{}
+	}
+	p, _ := ctx.Value(publishKey{}).(PublishOptions)
+	return p
+}
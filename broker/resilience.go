@@ -0,0 +1,161 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBufferFull is returned by publish when the buffer of messages
+// published while disconnected is full
+var ErrBufferFull = errors.New("publish buffer full")
+
+// OverflowPolicy decides what happens to messages published while the
+// buffer is full
+type OverflowPolicy int
+
+const (
+	// DropNewest rejects the message published with ErrBufferFull
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest buffered message
+	DropOldest
+	// Block waits for room until the publish context is done
+	Block
+)
+
+// buffered is a message published while disconnected
+type buffered struct {
+	topic string
+	msg   *Message
+	opts  []PublishOption
+}
+
+// Buffer keeps the messages published while the broker is disconnected
+// so they're republished in order once it's reconnected
+type Buffer struct {
+	size   int
+	policy OverflowPolicy
+
+	sync.Mutex
+	msgs []*buffered
+	// closed when room is made
+	space chan struct{}
+	// flush publishes one message at a time
+	flush sync.Mutex
+}
+
+// NewBuffer returns a buffer of size messages
+func NewBuffer(size int, policy OverflowPolicy) *Buffer {
+	if size < 1 {
+		size = 1
+	}
+	return &Buffer{
+		size:   size,
+		policy: policy,
+		space:  make(chan struct{}),
+	}
+}
+
+// Add buffers the message published to the topic
+func (b *Buffer) Add(topic string, m *Message, opts ...PublishOption) error {
+	options := PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	for {
+		b.Lock()
+
+		if len(b.msgs) < b.size {
+			b.msgs = append(b.msgs, &buffered{topic: topic, msg: m, opts: opts})
+			b.Unlock()
+			return nil
+		}
+
+		switch b.policy {
+		case DropOldest:
+			b.msgs = append(b.msgs[1:], &buffered{topic: topic, msg: m, opts: opts})
+			b.Unlock()
+			return nil
+		case Block:
+			space := b.space
+			b.Unlock()
+
+			select {
+			case <-space:
+			case <-options.Context.Done():
+				return options.Context.Err()
+			}
+		default:
+			b.Unlock()
+			return ErrBufferFull
+		}
+	}
+}
+
+// Len returns the number of buffered messages
+func (b *Buffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+	return len(b.msgs)
+}
+
+// Publish publishes the message unless messages are still buffered, it's
+// buffered behind them otherwise so it isn't published ahead of them. The
+// buffer is flushed once the previous flush finished.
+func (b *Buffer) Publish(publish PublishFunc, topic string, m *Message, opts ...PublishOption) error {
+	if b.Len() == 0 {
+		return publish(topic, m, opts...)
+	}
+
+	if err := b.Add(topic, m, opts...); err != nil {
+		return err
+	}
+
+	// the messages which fail are kept for the next flush
+	b.Flush(publish)
+
+	return nil
+}
+
+// Flush republishes the buffered messages in order. Messages from the
+// first which failed are kept. The publish func must return an error
+// rather than buffer the message while disconnected.
+func (b *Buffer) Flush(publish PublishFunc) error {
+	b.flush.Lock()
+	defer b.flush.Unlock()
+
+	for {
+		b.Lock()
+		if len(b.msgs) == 0 {
+			b.Unlock()
+			return nil
+		}
+		m := b.msgs[0]
+		b.Unlock()
+
+		if err := publish(m.topic, m.msg, m.opts...); err != nil {
+			return err
+		}
+
+		b.Lock()
+		// the message may have been dropped while publishing
+		if len(b.msgs) > 0 && b.msgs[0] == m {
+			b.msgs = b.msgs[1:]
+		}
+		close(b.space)
+		b.space = make(chan struct{})
+		b.Unlock()
+	}
+}
+
+// Resilience buffers up to size messages published while brokers which
+// reconnect are disconnected, the policy decides what happens once it's
+// full. Subscribers are resubscribed once reconnected.
+func Resilience(size int, policy OverflowPolicy) Option {
+	return func(o *Options) {
+		o.Buffer = NewBuffer(size, policy)
+	}
+}
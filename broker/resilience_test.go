@@ -0,0 +1,74 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBufferPublish(t *testing.T) {
+	b := NewBuffer(10, DropNewest)
+
+	for _, body := range []string{"1", "2"} {
+		if err := b.Add("test", &Message{Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mtx  sync.Mutex
+		sent []string
+	)
+
+	flushing := make(chan bool)
+	resume := make(chan bool)
+
+	publish := func(topic string, m *Message, opts ...PublishOption) error {
+		// hold up the flush after its first message
+		if string(m.Body) == "1" {
+			flushing <- true
+			<-resume
+		}
+		mtx.Lock()
+		sent = append(sent, string(m.Body))
+		mtx.Unlock()
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Flush(publish)
+	}()
+
+	<-flushing
+
+	// published while the buffer is flushed
+	published := make(chan error, 1)
+	go func() {
+		published <- b.Publish(publish, "test", &Message{Body: []byte("3")})
+	}()
+
+	// buffered behind the messages being flushed
+	for b.Len() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(resume)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-published; err != nil {
+		t.Fatal(err)
+	}
+
+	// published once the buffer is empty
+	if err := b.Publish(publish, "test", &Message{Body: []byte("4")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(sent) != "[1 2 3 4]" || b.Len() != 0 {
+		t.Fatalf("expected the messages in order got %v with %d buffered", sent, b.Len())
+	}
+}
@@ -0,0 +1,262 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/store"
+	"github.com/asim/go-micro/v3/store/memory"
+	"github.com/google/uuid"
+)
+
+var (
+	// DefaultScheduleInterval is the shortest time between the checks for
+	// due messages
+	DefaultScheduleInterval = time.Millisecond * 100
+	// MaxScheduleInterval is the longest time between the checks while
+	// nothing is due, the messages scheduled by other processes sharing
+	// the store are seen within it
+	MaxScheduleInterval = time.Second * 5
+	// DefaultClaimTTL is how long a scheduler has to publish a due message
+	// it claimed before the others sharing the store may claim it
+	DefaultClaimTTL = time.Second * 30
+
+	// schedulePrefix of the keys of scheduled messages
+	schedulePrefix = "broker/scheduled/"
+	// claimPrefix of the keys of the claims of due messages
+	claimPrefix = "broker/claims/"
+)
+
+// scheduled is the stored form of a scheduled message
+type scheduled struct {
+	Topic  string            `json:"topic"`
+	Key    string            `json:"key,omitempty"`
+	Header map[string]string `json:"header"`
+	Body   []byte            `json:"body"`
+}
+
+// claim of a due message by a scheduler
+type claim struct {
+	Owner string    `json:"owner"`
+	Until time.Time `json:"until"`
+}
+
+// Scheduler delivers messages published with PublishAt or Delay for
+// brokers without native delayed delivery. Messages are kept in the
+// store until they're due so a persistent store survives restarts. The
+// schedulers sharing a store claim the due messages before publishing
+// them so they're delivered by one of them, a message is only delivered
+// again if its claim expires before it's published.
+type Scheduler struct {
+	store   store.Store
+	publish func(topic string, m *Message, opts ...PublishOption) error
+	// id of the scheduler owning the claims
+	id string
+
+	once sync.Once
+	exit chan bool
+	// wakes the scheduler when a message is scheduled
+	wake chan bool
+}
+
+// NewScheduler returns a scheduler keeping messages in the store, or in
+// memory if nil, and delivering them with the publish func
+func NewScheduler(s store.Store, publish func(topic string, m *Message, opts ...PublishOption) error) *Scheduler {
+	if s == nil {
+		s = memory.NewStore()
+	}
+
+	return &Scheduler{
+		store:   s,
+		publish: publish,
+		id:      uuid.New().String(),
+		exit:    make(chan bool),
+		wake:    make(chan bool, 1),
+	}
+}
+
+// Schedule the message for delivery to the topic at the time of the options
+func (s *Scheduler) Schedule(topic string, m *Message, opts PublishOptions) error {
+	b, err := json.Marshal(&scheduled{Topic: topic, Key: opts.Key, Header: m.Header, Body: m.Body})
+	if err != nil {
+		return err
+	}
+
+	// keys sort by delivery time
+	key := fmt.Sprintf("%s%020d/%s", schedulePrefix, opts.DeliverAt.UnixNano(), uuid.New().String())
+
+	if err := s.store.Write(&store.Record{Key: key, Value: b}); err != nil {
+		return err
+	}
+
+	// the message may be due before the next check
+	select {
+	case s.wake <- true:
+	default:
+	}
+
+	return nil
+}
+
+// Start delivering due messages in the background
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+// run checks for due messages until the next one is due, backing off
+// up to the MaxScheduleInterval while there's none or the store fails
+func (s *Scheduler) run() {
+	wait := DefaultScheduleInterval
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.exit:
+			return
+		case <-s.wake:
+			if !t.Stop() {
+				<-t.C
+			}
+		case <-t.C:
+		}
+
+		next, err := s.deliver(time.Now())
+		switch {
+		case err != nil:
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[broker] scheduler error: %v", err)
+			}
+			wait *= 2
+		case next.IsZero():
+			wait *= 2
+		default:
+			wait = time.Until(next)
+		}
+
+		if wait < DefaultScheduleInterval {
+			wait = DefaultScheduleInterval
+		}
+		if wait > MaxScheduleInterval {
+			wait = MaxScheduleInterval
+		}
+
+		t.Reset(wait)
+	}
+}
+
+// deliver publishes the messages due at the time and returns when the next
+// one is due, zero if there's none. Messages which fail to publish are kept
+// and retried.
+func (s *Scheduler) deliver(now time.Time) (time.Time, error) {
+	keys, err := s.store.List(store.ListPrefix(schedulePrefix))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		parts := strings.SplitN(strings.TrimPrefix(key, schedulePrefix), "/", 2)
+		at, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if at > now.UnixNano() {
+			return time.Unix(0, at), nil
+		}
+
+		// claimed by another scheduler sharing the store
+		ckey := claimPrefix + strings.TrimPrefix(key, schedulePrefix)
+		if ok, err := s.claim(ckey, now); err != nil {
+			return time.Time{}, err
+		} else if !ok {
+			continue
+		}
+
+		recs, err := s.store.Read(key)
+		if err == store.ErrNotFound {
+			// delivered by another scheduler
+			s.store.Delete(ckey)
+			continue
+		} else if err != nil {
+			return time.Time{}, err
+		}
+
+		var msg scheduled
+		if err := json.Unmarshal(recs[0].Value, &msg); err != nil {
+			s.store.Delete(key)
+			s.store.Delete(ckey)
+			continue
+		}
+
+		if err := s.publish(msg.Topic, &Message{Header: msg.Header, Body: msg.Body}, WithKey(msg.Key)); err != nil {
+			// retried by any scheduler
+			s.store.Delete(ckey)
+			return time.Time{}, err
+		}
+
+		if err := s.store.Delete(key); err != nil {
+			return time.Time{}, err
+		}
+		s.store.Delete(ckey)
+	}
+
+	return time.Time{}, nil
+}
+
+// claim writes the claim of the due message unless another scheduler holds
+// it, and returns whether it's held by this scheduler once written
+func (s *Scheduler) claim(key string, now time.Time) (bool, error) {
+	if c, err := s.readClaim(key); err != nil {
+		return false, err
+	} else if c != nil && c.Owner != s.id && now.Before(c.Until) {
+		return false, nil
+	}
+
+	b, err := json.Marshal(&claim{Owner: s.id, Until: now.Add(DefaultClaimTTL)})
+	if err != nil {
+		return false, err
+	}
+	if err := s.store.Write(&store.Record{Key: key, Value: b, Expiry: DefaultClaimTTL}); err != nil {
+		return false, err
+	}
+
+	// the last of concurrent claims is held
+	c, err := s.readClaim(key)
+	if err != nil {
+		return false, err
+	}
+	return c != nil && c.Owner == s.id, nil
+}
+
+// readClaim returns the claim of the key, nil if there's none
+func (s *Scheduler) readClaim(key string) (*claim, error) {
+	recs, err := s.store.Read(key)
+	if err == store.ErrNotFound || (err == nil && len(recs) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c claim
+	if err := json.Unmarshal(recs[0].Value, &c); err != nil {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// Stop delivering messages. Scheduled messages are kept in the store.
+func (s *Scheduler) Stop() {
+	s.once.Do(func() {
+		close(s.exit)
+	})
+}
@@ -0,0 +1,84 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/store/memory"
+)
+
+func TestSchedulerClaim(t *testing.T) {
+	s := memory.NewStore()
+
+	var delivered []string
+	publish := func(name string) func(string, *Message, ...PublishOption) error {
+		return func(topic string, m *Message, opts ...PublishOption) error {
+			delivered = append(delivered, name)
+			return nil
+		}
+	}
+
+	// schedulers of the brokers sharing the store
+	a := NewScheduler(s, publish("a"))
+	b := NewScheduler(s, publish("b"))
+
+	now := time.Now()
+	if err := a.Schedule("test", &Message{Body: []byte(`hello`)}, PublishOptions{DeliverAt: now}); err != nil {
+		t.Fatalf("Unexpected error scheduling %v", err)
+	}
+
+	keys, err := s.List()
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("Expected the scheduled message, got %v %v", keys, err)
+	}
+	ckey := claimPrefix + keys[0][len(schedulePrefix):]
+
+	// claimed by a which hasn't published it yet
+	if ok, err := a.claim(ckey, now); err != nil || !ok {
+		t.Fatalf("Expected a to claim the message, got %v %v", ok, err)
+	}
+	if _, err := b.deliver(now.Add(time.Millisecond)); err != nil {
+		t.Fatalf("Unexpected deliver error %v", err)
+	}
+	if len(delivered) != 0 {
+		t.Fatalf("Expected the claimed message not to be delivered, got %v", delivered)
+	}
+
+	// the claim expires if a dies before publishing
+	if _, err := b.deliver(now.Add(DefaultClaimTTL + time.Second)); err != nil {
+		t.Fatalf("Unexpected deliver error %v", err)
+	}
+	if len(delivered) != 1 || delivered[0] != "b" {
+		t.Fatalf("Expected b to deliver the message, got %v", delivered)
+	}
+
+	// delivered once
+	if _, err := a.deliver(now.Add(DefaultClaimTTL + time.Second)); err != nil {
+		t.Fatalf("Unexpected deliver error %v", err)
+	}
+	if len(delivered) != 1 {
+		t.Fatalf("Expected the message to be delivered once, got %v", delivered)
+	}
+	if keys, _ := s.List(); len(keys) != 0 {
+		t.Fatalf("Expected the message and claim to be deleted, got %v", keys)
+	}
+}
+
+func TestSchedulerNext(t *testing.T) {
+	sc := NewScheduler(memory.NewStore(), func(string, *Message, ...PublishOption) error {
+		return nil
+	})
+
+	now := time.Now()
+	if next, err := sc.deliver(now); err != nil || !next.IsZero() {
+		t.Fatalf("Expected nothing scheduled, got %v %v", next, err)
+	}
+
+	at := now.Add(time.Minute)
+	if err := sc.Schedule("test", &Message{}, PublishOptions{DeliverAt: at}); err != nil {
+		t.Fatalf("Unexpected error scheduling %v", err)
+	}
+	if next, err := sc.deliver(now); err != nil || !next.Equal(time.Unix(0, at.UnixNano())) {
+		t.Fatalf("Expected the next message due at %v, got %v %v", at, next, err)
+	}
+}
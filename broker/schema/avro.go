@@ -0,0 +1,273 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+func avroCodec(s *Schema) (*goavro.Codec, error) {
+	return goavro.NewCodec(string(s.Definition))
+}
+
+func compileAvro(s *Schema) (validator, error) {
+	codec, err := avroCodec(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(b []byte, text bool) error {
+		var rest []byte
+		var err error
+
+		if text {
+			_, rest, err = codec.NativeFromTextual(b)
+			rest = bytes.TrimSpace(rest)
+		} else {
+			_, rest, err = codec.NativeFromBinary(b)
+		}
+
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 {
+			return fmt.Errorf("%d trailing bytes", len(rest))
+		}
+		return nil
+	}, nil
+}
+
+// avroSchema is a parsed avro schema and its named types
+type avroSchema struct {
+	root  interface{}
+	names map[string]interface{}
+}
+
+func parseAvro(b []byte) (*avroSchema, error) {
+	var root interface{}
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+
+	s := &avroSchema{root: root, names: make(map[string]interface{})}
+	s.collect(root, "")
+
+	return s, nil
+}
+
+// collect indexes the named types by name and full name
+func (s *avroSchema) collect(t interface{}, ns string) {
+	switch t := t.(type) {
+	case []interface{}:
+		for _, u := range t {
+			s.collect(u, ns)
+		}
+	case map[string]interface{}:
+		if n, ok := t["namespace"].(string); ok {
+			ns = n
+		}
+		if name, ok := t["name"].(string); ok {
+			s.names[name] = t
+			if len(ns) > 0 {
+				s.names[ns+"."+name] = t
+			}
+		}
+		if fields, ok := t["fields"].([]interface{}); ok {
+			for _, f := range fields {
+				if fm, ok := f.(map[string]interface{}); ok {
+					s.collect(fm["type"], ns)
+				}
+			}
+		}
+		s.collect(t["items"], ns)
+		s.collect(t["values"], ns)
+	}
+}
+
+// resolve returns the definition of named type references
+func (s *avroSchema) resolve(t interface{}) interface{} {
+	if name, ok := t.(string); ok {
+		if d, ok := s.names[name]; ok {
+			return d
+		}
+	}
+	return t
+}
+
+// avroType returns the type name of the schema
+func avroType(t interface{}) string {
+	switch t := t.(type) {
+	case string:
+		return t
+	case []interface{}:
+		return "union"
+	case map[string]interface{}:
+		return avroType(t["type"])
+	}
+	return ""
+}
+
+// promotions of the writer types the reader accepts
+var promotions = map[string][]string{
+	"int":    {"long", "float", "double"},
+	"long":   {"float", "double"},
+	"float":  {"double"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+func compatibleAvro(old, s *Schema) error {
+	w, err := parseAvro(old.Definition)
+	if err != nil {
+		return err
+	}
+	r, err := parseAvro(s.Definition)
+	if err != nil {
+		return err
+	}
+	return avroCompatible(w, r, w.root, r.root, "$", make(map[string]bool))
+}
+
+// avroCompatible checks data written with the writer type can be read
+// with the reader type by the avro schema resolution rules
+func avroCompatible(ws, rs *avroSchema, wt, rt interface{}, path string, seen map[string]bool) error {
+	wt, rt = ws.resolve(wt), rs.resolve(rt)
+
+	wtyp, rtyp := avroType(wt), avroType(rt)
+
+	// every branch written has to be readable
+	if wtyp == "union" {
+		for _, b := range wt.([]interface{}) {
+			if err := avroCompatible(ws, rs, b, rt, path, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if rtyp == "union" {
+		for _, b := range rt.([]interface{}) {
+			if avroCompatible(ws, rs, wt, b, path, seen) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %s is not in the union", path, wtyp)
+	}
+
+	if wtyp != rtyp {
+		for _, p := range promotions[wtyp] {
+			if p == rtyp {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %s changed to %s", path, wtyp, rtyp)
+	}
+
+	w, _ := wt.(map[string]interface{})
+	r, _ := rt.(map[string]interface{})
+
+	switch rtyp {
+	case "record":
+		// recursive records are checked once, union branches which
+		// didn't match are checked again
+		wname, _ := w["name"].(string)
+		rname, _ := r["name"].(string)
+		key := wname + ":" + rname
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+
+		if err := avroRecordCompatible(ws, rs, w, r, path, seen); err != nil {
+			delete(seen, key)
+			return err
+		}
+	case "enum":
+		if _, ok := r["default"]; ok {
+			return nil
+		}
+		rsyms, _ := r["symbols"].([]interface{})
+		wsyms, _ := w["symbols"].([]interface{})
+
+		symbols := make(map[interface{}]bool)
+		for _, s := range rsyms {
+			symbols[s] = true
+		}
+		for _, s := range wsyms {
+			if !symbols[s] {
+				return fmt.Errorf("%s: symbol %v removed", path, s)
+			}
+		}
+	case "array":
+		return avroCompatible(ws, rs, w["items"], r["items"], path+"[]", seen)
+	case "map":
+		return avroCompatible(ws, rs, w["values"], r["values"], path+"{}", seen)
+	case "fixed":
+		if w["size"] != r["size"] {
+			return fmt.Errorf("%s: size changed from %v to %v", path, w["size"], r["size"])
+		}
+	}
+
+	return nil
+}
+
+// avroRecordCompatible checks the fields of the reader record are written
+// or have a default
+func avroRecordCompatible(ws, rs *avroSchema, w, r map[string]interface{}, path string, seen map[string]bool) error {
+	written := make(map[string]map[string]interface{})
+	for _, f := range fieldsOf(w) {
+		if fname, ok := f["name"].(string); ok {
+			written[fname] = f
+		}
+	}
+
+	for _, f := range fieldsOf(r) {
+		fname, _ := f["name"].(string)
+
+		wf, ok := written[fname]
+		if !ok {
+			for _, a := range aliasesOf(f) {
+				if wf, ok = written[a]; ok {
+					break
+				}
+			}
+		}
+
+		if !ok {
+			if _, ok := f["default"]; !ok {
+				return fmt.Errorf("%s: field %s added without a default", path, fname)
+			}
+			continue
+		}
+
+		if err := avroCompatible(ws, rs, wf["type"], f["type"], path+"."+fname, seen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fieldsOf(t map[string]interface{}) []map[string]interface{} {
+	var fields []map[string]interface{}
+	fs, _ := t["fields"].([]interface{})
+	for _, f := range fs {
+		if fm, ok := f.(map[string]interface{}); ok {
+			fields = append(fields, fm)
+		}
+	}
+	return fields
+}
+
+func aliasesOf(f map[string]interface{}) []string {
+	var aliases []string
+	as, _ := f["aliases"].([]interface{})
+	for _, a := range as {
+		if s, ok := a.(string); ok {
+			aliases = append(aliases, s)
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,259 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// confluentType of the formats, protobuf schemas are registered as
+// .proto files which aren't supported
+var confluentType = map[Format]string{
+	JSON: "JSON",
+	Avro: "AVRO",
+}
+
+const confluentContentType = "application/vnd.schemaregistry.v1+json"
+
+type confluentRegistry struct {
+	addr   string
+	user   string
+	pass   string
+	client *http.Client
+
+	sync.RWMutex
+	cache map[string]*cached
+	// schemas by id, which never change
+	ids map[int]*Schema
+}
+
+type confluentSchema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+	Version    int    `json:"version,omitempty"`
+	ID         int    `json:"id,omitempty"`
+}
+
+type confluentError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// subject of the topic values, the default subject name strategy
+func subject(topic string) string {
+	return url.PathEscape(topic + "-value")
+}
+
+func (r *confluentRegistry) do(method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, r.addr+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", confluentContentType)
+	req.Header.Set("Content-Type", confluentContentType)
+	if len(r.user) > 0 {
+		req.SetBasicAuth(r.user, r.pass)
+	}
+
+	rsp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		var e confluentError
+		json.NewDecoder(rsp.Body).Decode(&e)
+
+		switch {
+		case rsp.StatusCode == http.StatusNotFound:
+			return ErrNotFound
+		case rsp.StatusCode == http.StatusConflict:
+			return fmt.Errorf("%w: %s", ErrIncompatible, e.Message)
+		}
+		return fmt.Errorf("schema registry error %d: %s", rsp.StatusCode, e.Message)
+	}
+
+	return json.NewDecoder(rsp.Body).Decode(out)
+}
+
+// toSchema returns the schema of the confluent schema
+func (cs *confluentSchema) toSchema(topic string) *Schema {
+	s := &Schema{
+		Topic:      topic,
+		Format:     Avro,
+		Version:    cs.Version,
+		Definition: []byte(cs.Schema),
+		ID:         cs.ID,
+	}
+
+	// the schema type is omitted for avro
+	for f, t := range confluentType {
+		if t == cs.SchemaType {
+			s.Format = f
+		}
+	}
+
+	return s
+}
+
+func (r *confluentRegistry) read(topic string) (*Schema, error) {
+	var cs confluentSchema
+	if err := r.do("GET", "/subjects/"+subject(topic)+"/versions/latest", nil, &cs); err != nil {
+		return nil, err
+	}
+	return cs.toSchema(topic), nil
+}
+
+// Register checks the compatibility with the latest version before it's
+// registered, the registry checks it with its compatibility level
+func (r *confluentRegistry) Register(s *Schema) error {
+	typ, ok := confluentType[s.Format]
+	if !ok {
+		return fmt.Errorf("%s schemas aren't supported by the confluent registry", s.Format)
+	}
+
+	if _, err := s.validator(); err != nil {
+		return err
+	}
+
+	old, err := r.read(s.Topic)
+	switch err {
+	case nil:
+		if err := Compatible(old, s); err != nil {
+			return err
+		}
+	case ErrNotFound:
+	default:
+		return err
+	}
+
+	if typ == "AVRO" {
+		typ = ""
+	}
+
+	var rsp struct {
+		ID int `json:"id"`
+	}
+	if err := r.do("POST", "/subjects/"+subject(s.Topic)+"/versions", &confluentSchema{Schema: string(s.Definition), SchemaType: typ}, &rsp); err != nil {
+		return err
+	}
+
+	latest, err := r.read(s.Topic)
+	if err != nil {
+		return err
+	}
+	s.Version = latest.Version
+	s.ID = rsp.ID
+
+	r.Lock()
+	r.cache[s.Topic] = &cached{schema: s, expires: time.Now().Add(DefaultCacheTTL)}
+	r.Unlock()
+
+	return nil
+}
+
+// Latest returns the latest schema of the topic cached for the DefaultCacheTTL
+func (r *confluentRegistry) Latest(topic string) (*Schema, error) {
+	r.RLock()
+	c, ok := r.cache[topic]
+	r.RUnlock()
+
+	if !ok || time.Now().After(c.expires) {
+		s, err := r.read(topic)
+		if err != nil && err != ErrNotFound {
+			return nil, err
+		}
+
+		c = &cached{schema: s, expires: time.Now().Add(DefaultCacheTTL)}
+
+		r.Lock()
+		r.cache[topic] = c
+		r.Unlock()
+	}
+
+	if c.schema == nil {
+		return nil, ErrNotFound
+	}
+
+	return c.schema, nil
+}
+
+// Schema returns the schema of the id, which has no topic as it may be
+// registered for several
+func (r *confluentRegistry) Schema(id int) (*Schema, error) {
+	r.RLock()
+	s, ok := r.ids[id]
+	r.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	var cs confluentSchema
+	if err := r.do("GET", fmt.Sprintf("/schemas/ids/%d", id), nil, &cs); err != nil {
+		return nil, err
+	}
+	cs.ID = id
+	s = cs.toSchema("")
+
+	r.Lock()
+	r.ids[id] = s
+	r.Unlock()
+
+	return s, nil
+}
+
+// Version returns the version of the schema of the topic
+func (r *confluentRegistry) Version(topic string, version int) (*Schema, error) {
+	var cs confluentSchema
+	if err := r.do("GET", fmt.Sprintf("/subjects/%s/versions/%d", subject(topic), version), nil, &cs); err != nil {
+		return nil, err
+	}
+	return cs.toSchema(topic), nil
+}
+
+func (r *confluentRegistry) String() string {
+	return "confluent"
+}
+
+// NewConfluentRegistry returns a registry using the confluent schema
+// registry api at the address, with the subject of the topic values.
+// Credentials of the address are used for basic auth.
+func NewConfluentRegistry(addr string) (IDRegistry, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(u.Host) == 0 {
+		return nil, errors.New("invalid schema registry address")
+	}
+
+	r := &confluentRegistry{
+		client: &http.Client{Timeout: time.Second * 10},
+		cache:  make(map[string]*cached),
+		ids:    make(map[int]*Schema),
+	}
+
+	if u.User != nil {
+		r.user = u.User.Username()
+		r.pass, _ = u.User.Password()
+		u.User = nil
+	}
+
+	r.addr = strings.TrimSuffix(u.String(), "/")
+
+	return r, nil
+}
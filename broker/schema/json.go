@@ -0,0 +1,296 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// jsonSchema is the subset of json schema validated: type, properties,
+// required, additionalProperties, items, enum and the bounds of numbers
+// and strings
+type jsonSchema struct {
+	Type                 jsonTypes              `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+
+	// additional is the schema of additional properties
+	additional *jsonSchema
+	// closed is set if additional properties aren't allowed
+	closed bool
+}
+
+// jsonTypes is either a single type or a list of types
+type jsonTypes []string
+
+func (t *jsonTypes) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = jsonTypes{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// has returns true if values of the type are allowed
+func (t jsonTypes) has(typ string) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, v := range t {
+		// integers are numbers
+		if v == typ || (v == "number" && typ == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseJSON(b []byte) (*jsonSchema, error) {
+	var s *jsonSchema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("empty json schema")
+	}
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// init parses additionalProperties which is either a bool or a schema
+func (s *jsonSchema) init() error {
+	if ap := bytes.TrimSpace(s.AdditionalProperties); len(ap) > 0 {
+		switch string(ap) {
+		case "true":
+		case "false":
+			s.closed = true
+		default:
+			if err := json.Unmarshal(ap, &s.additional); err != nil {
+				return err
+			}
+			if err := s.additional.init(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, p := range s.Properties {
+		if err := p.init(); err != nil {
+			return err
+		}
+	}
+
+	if s.Items != nil {
+		return s.Items.init()
+	}
+
+	return nil
+}
+
+func compileJSON(s *Schema) (validator, error) {
+	js, err := parseJSON(s.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(b []byte, text bool) error {
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		return js.validate(v, "$")
+	}, nil
+}
+
+// typeOf returns the json schema type of the decoded value
+func typeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func (s *jsonSchema) validate(v interface{}, path string) error {
+	typ := typeOf(v)
+
+	if !s.Type.has(typ) {
+		return fmt.Errorf("%s: expected %v got %s", path, []string(s.Type), typ)
+	}
+
+	if len(s.Enum) > 0 {
+		var found bool
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, v, s.Enum)
+		}
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fmt.Errorf("%s: %v is less than %v", path, v, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fmt.Errorf("%s: %v is greater than %v", path, v, *s.Maximum)
+		}
+	case string:
+		n := len([]rune(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			return fmt.Errorf("%s: shorter than %d", path, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fmt.Errorf("%s: longer than %d", path, *s.MaxLength)
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range v {
+			if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, r := range s.Required {
+			if _, ok := v[r]; !ok {
+				return fmt.Errorf("%s: missing %s", path, r)
+			}
+		}
+
+		// validate in order for stable errors
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p, ok := s.Properties[k]
+			switch {
+			case ok:
+			case s.additional != nil:
+				p = s.additional
+			case s.closed:
+				return fmt.Errorf("%s: unexpected property %s", path, k)
+			default:
+				continue
+			}
+			if err := p.validate(v[k], path+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func compatibleJSON(old, s *Schema) error {
+	o, err := parseJSON(old.Definition)
+	if err != nil {
+		return err
+	}
+	n, err := parseJSON(s.Definition)
+	if err != nil {
+		return err
+	}
+	return jsonCompatible(o, n, "$")
+}
+
+// jsonCompatible checks values valid for the old schema are valid for
+// the new schema
+func jsonCompatible(o, n *jsonSchema, path string) error {
+	if len(n.Type) > 0 {
+		if len(o.Type) == 0 {
+			return fmt.Errorf("%s: type restricted to %v", path, []string(n.Type))
+		}
+		for _, t := range o.Type {
+			if !n.Type.has(t) {
+				return fmt.Errorf("%s: type %s removed", path, t)
+			}
+		}
+	}
+
+	for _, r := range n.Required {
+		var found bool
+		for _, or := range o.Required {
+			if or == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %s is required", path, r)
+		}
+	}
+
+	for name, op := range o.Properties {
+		np, ok := n.Properties[name]
+		if !ok {
+			if n.closed {
+				return fmt.Errorf("%s: property %s removed", path, name)
+			}
+			continue
+		}
+		if err := jsonCompatible(op, np, path+"."+name); err != nil {
+			return err
+		}
+	}
+
+	if o.Items != nil && n.Items != nil {
+		if err := jsonCompatible(o.Items, n.Items, path+"[]"); err != nil {
+			return err
+		}
+	}
+
+	if len(n.Enum) > 0 {
+		if len(o.Enum) == 0 {
+			return fmt.Errorf("%s: values restricted to %v", path, n.Enum)
+		}
+		for _, oe := range o.Enum {
+			var found bool
+			for _, ne := range n.Enum {
+				if reflect.DeepEqual(oe, ne) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s: value %v removed", path, oe)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protobufMessage returns the descriptor of the message of the schema
+func protobufMessage(s *Schema) (protoreflect.MessageDescriptor, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(s.Definition, &set); err != nil {
+		return nil, err
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(s.Message))
+	if err != nil {
+		return nil, fmt.Errorf("message %q: %v", s.Message, err)
+	}
+
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a message", s.Message)
+	}
+
+	return md, nil
+}
+
+func compileProtobuf(s *Schema) (validator, error) {
+	md, err := protobufMessage(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(b []byte, text bool) error {
+		m := dynamicpb.NewMessage(md)
+		if text {
+			return protojson.Unmarshal(b, m)
+		}
+		// required fields of proto2 messages are checked
+		return proto.Unmarshal(b, m)
+	}, nil
+}
+
+func compatibleProtobuf(old, s *Schema) error {
+	o, err := protobufMessage(old)
+	if err != nil {
+		return err
+	}
+	n, err := protobufMessage(s)
+	if err != nil {
+		return err
+	}
+	return protobufCompatible(o, n, make(map[protoreflect.FullName]bool))
+}
+
+// varint kinds can be read as each other
+var varints = map[protoreflect.Kind]bool{
+	protoreflect.Int32Kind:  true,
+	protoreflect.Int64Kind:  true,
+	protoreflect.Uint32Kind: true,
+	protoreflect.Uint64Kind: true,
+	protoreflect.BoolKind:   true,
+	protoreflect.EnumKind:   true,
+}
+
+// protobufCompatible checks the fields kept by number have the same wire
+// type and no required fields were added
+func protobufCompatible(o, n protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) error {
+	if seen[n.FullName()] {
+		return nil
+	}
+	seen[n.FullName()] = true
+
+	fields := n.Fields()
+
+	for i := 0; i < fields.Len(); i++ {
+		nf := fields.Get(i)
+
+		of := o.Fields().ByNumber(nf.Number())
+		if of == nil {
+			if nf.Cardinality() == protoreflect.Required {
+				return fmt.Errorf("%s: required field %s added", n.FullName(), nf.Name())
+			}
+			continue
+		}
+
+		if of.IsList() != nf.IsList() || of.IsMap() != nf.IsMap() {
+			return fmt.Errorf("%s: cardinality of field %d changed", n.FullName(), nf.Number())
+		}
+
+		if of.Kind() != nf.Kind() && !(varints[of.Kind()] && varints[nf.Kind()]) {
+			return fmt.Errorf("%s: field %d changed from %s to %s", n.FullName(), nf.Number(), of.Kind(), nf.Kind())
+		}
+
+		if nf.Message() != nil && of.Message() != nil {
+			if err := protobufCompatible(of.Message(), nf.Message(), seen); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package schema binds topics to message schemas. Messages published and
+// delivered on a topic with a schema are validated against it and schema
+// changes which can't read the messages of the previous version are
+// rejected.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+)
+
+const (
+	// IDHeader is the id of the schema a message was written with, set by
+	// the registries assigning ids
+	IDHeader = "Micro-Schema-Id"
+	// VersionHeader is the version of the schema a message was written with
+	VersionHeader = "Micro-Schema-Version"
+)
+
+var (
+	// DefaultCacheTTL of the latest schemas read by the registries
+	DefaultCacheTTL = time.Minute
+
+	// ErrNotFound is returned for topics without a schema
+	ErrNotFound = errors.New("schema not found")
+	// ErrInvalid is returned for messages which don't match the schema
+	ErrInvalid = errors.New("invalid message")
+	// ErrIncompatible is returned for schema changes rejected by the registry
+	ErrIncompatible = errors.New("incompatible schema")
+)
+
+// Format of a schema definition
+type Format string
+
+const (
+	// JSON is a json schema
+	JSON Format = "json"
+	// Protobuf is a serialized FileDescriptorSet, the message type is
+	// set as the Message of the schema
+	Protobuf Format = "protobuf"
+	// Avro is an avro schema
+	Avro Format = "avro"
+)
+
+// Schema is a version of the schema of a topic
+type Schema struct {
+	// Topic the schema is bound to
+	Topic string `json:"topic"`
+	// Format of the definition
+	Format Format `json:"format"`
+	// Version is set by the registry, starting at 1
+	Version int `json:"version"`
+	// Definition of the schema
+	Definition []byte `json:"definition"`
+	// Message is the full name of the protobuf message
+	Message string `json:"message,omitempty"`
+	// ID is the global id of the schema set by registries assigning them
+	ID int `json:"id,omitempty"`
+
+	// the validator is compiled once
+	once sync.Once
+	v    validator
+	err  error
+}
+
+// validator checks the body of a message, text is true for json
+type validator func(b []byte, text bool) error
+
+// validator returns the validator compiled from the definition
+func (s *Schema) validator() (validator, error) {
+	s.once.Do(func() {
+		switch s.Format {
+		case JSON:
+			s.v, s.err = compileJSON(s)
+		case Protobuf:
+			s.v, s.err = compileProtobuf(s)
+		case Avro:
+			s.v, s.err = compileAvro(s)
+		default:
+			s.err = fmt.Errorf("unknown schema format %q", s.Format)
+		}
+	})
+	return s.v, s.err
+}
+
+// Registry keeps the schema versions of topics
+type Registry interface {
+	// Register the schema as the next version of the topic. The version
+	// is set on the schema. Schemas which can't read messages of the
+	// latest version return ErrIncompatible.
+	Register(*Schema) error
+	// Latest returns the latest version of the topic or ErrNotFound
+	Latest(topic string) (*Schema, error)
+	String() string
+}
+
+// IDRegistry is a registry assigning global ids to the schemas, so
+// messages can be tagged with the id of the schema they were written with
+type IDRegistry interface {
+	Registry
+	// Schema returns the schema of the id or ErrNotFound
+	Schema(id int) (*Schema, error)
+}
+
+// VersionRegistry is a registry returning the previous versions of the
+// schemas, so messages are validated with the version they were written with
+type VersionRegistry interface {
+	Registry
+	// Version returns the version of the schema of the topic or ErrNotFound
+	Version(topic string, version int) (*Schema, error)
+}
+
+// Validate checks the message matches the schema. Messages with a json
+// content type are validated in their json form.
+func Validate(s *Schema, m *broker.Message) error {
+	v, err := s.validator()
+	if err != nil {
+		return err
+	}
+
+	if err := v(m.Body, isJSON(m.Header["Content-Type"])); err != nil {
+		return fmt.Errorf("%w: %s version %d: %v", ErrInvalid, s.Topic, s.Version, err)
+	}
+
+	return nil
+}
+
+// Compatible checks messages written with the old schema can be read
+// with the new schema
+func Compatible(old, s *Schema) error {
+	if old.Format != s.Format {
+		return fmt.Errorf("%w: format changed from %s to %s", ErrIncompatible, old.Format, s.Format)
+	}
+
+	var err error
+
+	switch s.Format {
+	case JSON:
+		err = compatibleJSON(old, s)
+	case Protobuf:
+		err = compatibleProtobuf(old, s)
+	case Avro:
+		err = compatibleAvro(old, s)
+	default:
+		return fmt.Errorf("unknown schema format %q", s.Format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIncompatible, err)
+	}
+
+	return nil
+}
+
+func isJSON(ct string) bool {
+	return ct == "application/json" || strings.HasSuffix(ct, "+json")
+}
+
+// writer returns the schema the message was written with from its
+// headers, the latest schema of the topic for the messages without them
+func writer(r Registry, topic string, m *broker.Message) (*Schema, error) {
+	if ir, ok := r.(IDRegistry); ok {
+		if id, err := strconv.Atoi(m.Header[IDHeader]); err == nil {
+			s, err := ir.Schema(id)
+			if err == ErrNotFound {
+				return nil, fmt.Errorf("%w: %s schema id %d not found", ErrInvalid, topic, id)
+			}
+			return s, err
+		}
+	}
+
+	if vr, ok := r.(VersionRegistry); ok {
+		if v, err := strconv.Atoi(m.Header[VersionHeader]); err == nil {
+			s, err := vr.Version(topic, v)
+			if err == ErrNotFound {
+				return nil, fmt.Errorf("%w: %s version %d not found", ErrInvalid, topic, v)
+			}
+			return s, err
+		}
+	}
+
+	return r.Latest(topic)
+}
+
+// NewPublishWrapper rejects published messages which don't match the
+// schema of the topic, the others have the id and version of the schema
+// set in their headers
+func NewPublishWrapper(r Registry) broker.PublishWrapper {
+	return func(fn broker.PublishFunc) broker.PublishFunc {
+		return func(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+			s, err := r.Latest(topic)
+			if err == ErrNotFound {
+				return fn(topic, m, opts...)
+			}
+			if err != nil {
+				return err
+			}
+			if err := Validate(s, m); err != nil {
+				return err
+			}
+
+			// the headers of the caller are left as is
+			hdr := make(map[string]string, len(m.Header)+2)
+			for k, v := range m.Header {
+				hdr[k] = v
+			}
+			hdr[VersionHeader] = strconv.Itoa(s.Version)
+			if s.ID > 0 {
+				hdr[IDHeader] = strconv.Itoa(s.ID)
+			}
+
+			return fn(topic, &broker.Message{Header: hdr, Body: m.Body}, opts...)
+		}
+	}
+}
+
+// NewSubscribeWrapper returns an error for delivered messages which
+// don't match the schema they were written with, the handler isn't called
+func NewSubscribeWrapper(r Registry) broker.SubscribeWrapper {
+	return func(fn broker.SubscribeFunc) broker.SubscribeFunc {
+		return func(topic string, h broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+			return fn(topic, func(m *broker.Message) error {
+				s, err := writer(r, topic, m)
+				if err == ErrNotFound {
+					return h(m)
+				}
+				if err != nil {
+					return err
+				}
+				if err := Validate(s, m); err != nil {
+					return err
+				}
+				return h(m)
+			}, opts...)
+		}
+	}
+}
+
+// Wrap sets both wrappers on the broker
+func Wrap(r Registry) broker.Option {
+	return func(o *broker.Options) {
+		broker.WrapPublish(NewPublishWrapper(r))(o)
+		broker.WrapSubscribe(NewSubscribeWrapper(r))(o)
+	}
+}
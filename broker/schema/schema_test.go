@@ -0,0 +1,434 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/broker/memory"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"properties": {
+		"id": {"type": "string"},
+		"amount": {"type": "integer", "minimum": 0}
+	},
+	"required": ["id"]
+}`
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(nil)
+
+	if _, err := r.Latest("orders"); err != ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+
+	s := &Schema{Topic: "orders", Format: JSON, Definition: []byte(orderSchema)}
+	if err := r.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 1 {
+		t.Fatalf("expected version 1 got %d", s.Version)
+	}
+
+	// a new required field can't read the previous messages
+	err := r.Register(&Schema{Topic: "orders", Format: JSON, Definition: []byte(`{
+		"type": "object",
+		"properties": {"id": {"type": "string"}, "currency": {"type": "string"}},
+		"required": ["id", "currency"]
+	}`)})
+	if !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("expected incompatible got %v", err)
+	}
+
+	// optional fields are compatible
+	s2 := &Schema{Topic: "orders", Format: JSON, Definition: []byte(`{
+		"type": "object",
+		"properties": {"id": {"type": "string"}, "amount": {"type": "number"}, "currency": {"type": "string"}},
+		"required": ["id"]
+	}`)}
+	if err := r.Register(s2); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := r.Latest("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != 2 {
+		t.Fatalf("expected version 2 got %d", latest.Version)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.Register(&Schema{Topic: "orders", Format: JSON, Definition: []byte(orderSchema)}); err != nil {
+		t.Fatal(err)
+	}
+
+	b := memory.NewBroker(Wrap(r))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var mtx sync.Mutex
+	var received []string
+
+	if _, err := b.Subscribe("orders", func(m *broker.Message) error {
+		mtx.Lock()
+		received = append(received, string(m.Body))
+		mtx.Unlock()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Publish("orders", &broker.Message{Body: []byte(`{"id":"1","amount":10}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{`{"amount":10}`, `{"id":"1","amount":-1}`, `{"id":1}`, `not json`} {
+		if err := b.Publish("orders", &broker.Message{Body: []byte(body)}); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("expected %s to be invalid got %v", body, err)
+		}
+	}
+
+	// topics without a schema aren't validated
+	if err := b.Publish("events", &broker.Message{Body: []byte(`anything`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if len(received) != 1 {
+		t.Fatalf("expected 1 message got %v", received)
+	}
+}
+
+func TestSubscribeWrapper(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.Register(&Schema{Topic: "orders", Format: JSON, Definition: []byte(orderSchema)}); err != nil {
+		t.Fatal(err)
+	}
+
+	b := memory.NewBroker(broker.WrapSubscribe(NewSubscribeWrapper(r)))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var handled int
+	var failed error
+
+	if _, err := b.Subscribe("orders", func(m *broker.Message) error {
+		handled++
+		return nil
+	}, broker.HandleError(func(m *broker.Message, err error) {
+		failed = err
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Publish("orders", &broker.Message{Body: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 0 || !errors.Is(failed, ErrInvalid) {
+		t.Fatalf("expected the delivery to be rejected got %d handled, %v", handled, failed)
+	}
+}
+
+const paymentSchema = `{
+	"type": "record",
+	"name": "Payment",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "amount", "type": "int"}
+	]
+}`
+
+func TestAvro(t *testing.T) {
+	s := &Schema{Topic: "payments", Format: Avro, Definition: []byte(paymentSchema)}
+
+	codec, err := goavro.NewCodec(paymentSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := codec.BinaryFromNative(nil, map[string]interface{}{"id": "1", "amount": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Validate(s, &broker.Message{Body: b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(s, &broker.Message{Body: append(b, 0x01)}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected trailing bytes to be invalid got %v", err)
+	}
+	if err := Validate(s, &broker.Message{
+		Header: map[string]string{"Content-Type": "application/json"},
+		Body:   []byte(`{"id":"1","amount":10}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for def, compatible := range map[string]bool{
+		// int is promoted to long
+		`{"type":"record","name":"Payment","fields":[{"name":"id","type":"string"},{"name":"amount","type":"long"}]}`: true,
+		// fields added with a default and removed
+		`{"type":"record","name":"Payment","fields":[{"name":"id","type":"string"},{"name":"currency","type":"string","default":"EUR"}]}`: true,
+		// union including the type written
+		`{"type":"record","name":"Payment","fields":[{"name":"id","type":["null","string"]},{"name":"amount","type":"int"}]}`: true,
+		// a field added without a default
+		`{"type":"record","name":"Payment","fields":[{"name":"id","type":"string"},{"name":"amount","type":"int"},{"name":"currency","type":"string"}]}`: false,
+		// long can't be read as int
+		`{"type":"record","name":"Payment","fields":[{"name":"id","type":"int"},{"name":"amount","type":"int"}]}`: false,
+	} {
+		err := Compatible(s, &Schema{Topic: "payments", Format: Avro, Definition: []byte(def)})
+		if compatible && err != nil {
+			t.Fatalf("expected %s to be compatible got %v", def, err)
+		}
+		if !compatible && !errors.Is(err, ErrIncompatible) {
+			t.Fatalf("expected %s to be incompatible got %v", def, err)
+		}
+	}
+}
+
+// testFile returns the descriptor set of a file with an Order message
+func testFile(t *testing.T, kind descriptorpb.FieldDescriptorProto_Type) []byte {
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("order.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("id"),
+				JsonName: proto.String("id"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     kind.Enum(),
+			}},
+		}},
+	}
+
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fd}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestProtobuf(t *testing.T) {
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(durationpb.File_google_protobuf_duration_proto)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Schema{Topic: "timeouts", Format: Protobuf, Definition: set, Message: "google.protobuf.Duration"}
+
+	b, err := proto.Marshal(durationpb.New(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Validate(s, &broker.Message{Body: b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(s, &broker.Message{Body: []byte{0xff, 0xff}}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected invalid got %v", err)
+	}
+	if err := Validate(s, &broker.Message{
+		Header: map[string]string{"Content-Type": "application/json"},
+		Body:   []byte(`"1s"`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	old := &Schema{Topic: "orders", Format: Protobuf, Definition: testFile(t, descriptorpb.FieldDescriptorProto_TYPE_INT32), Message: "test.Order"}
+
+	if err := Compatible(old, &Schema{Topic: "orders", Format: Protobuf, Definition: testFile(t, descriptorpb.FieldDescriptorProto_TYPE_INT64), Message: "test.Order"}); err != nil {
+		t.Fatalf("expected varints to be compatible got %v", err)
+	}
+	if err := Compatible(old, &Schema{Topic: "orders", Format: Protobuf, Definition: testFile(t, descriptorpb.FieldDescriptorProto_TYPE_STRING), Message: "test.Order"}); !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("expected incompatible got %v", err)
+	}
+}
+
+func TestConfluentRegistry(t *testing.T) {
+	var mtx sync.Mutex
+	var versions []confluentSchema
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if user, pass, _ := r.BasicAuth(); user != "key" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path == "/schemas/ids/1" && len(versions) > 0 {
+			json.NewEncoder(w).Encode(&confluentSchema{Schema: versions[0].Schema, SchemaType: versions[0].SchemaType})
+			return
+		}
+
+		if !strings.HasPrefix(r.URL.Path, "/subjects/orders-value/versions") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			if len(versions) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&confluentError{ErrorCode: 40401, Message: "Subject not found"})
+				return
+			}
+			json.NewEncoder(w).Encode(versions[len(versions)-1])
+		case "POST":
+			var cs confluentSchema
+			json.NewDecoder(r.Body).Decode(&cs)
+			cs.Version = len(versions) + 1
+			cs.ID = cs.Version
+			versions = append(versions, cs)
+			json.NewEncoder(w).Encode(map[string]int{"id": cs.Version})
+		}
+	}))
+	defer srv.Close()
+
+	r, err := NewConfluentRegistry(strings.Replace(srv.URL, "http://", "http://key:secret@", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Latest("orders"); err != ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+
+	s := &Schema{Topic: "orders", Format: JSON, Definition: []byte(orderSchema)}
+	if err := r.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 1 || s.ID != 1 || versions[0].SchemaType != "JSON" {
+		t.Fatalf("unexpected registration %+v", versions)
+	}
+
+	byID, err := r.Schema(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID.ID != 1 || byID.Format != JSON || string(byID.Definition) != orderSchema {
+		t.Fatalf("unexpected schema %+v", byID)
+	}
+	if _, err := r.Schema(2); err != ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+
+	latest, err := r.Latest("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Format != JSON || string(latest.Definition) != orderSchema {
+		t.Fatalf("unexpected schema %+v", latest)
+	}
+
+	// the messages are tagged with the id of the schema and validated with it
+	var published *broker.Message
+	publish := NewPublishWrapper(r)(func(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+		published = m
+		return nil
+	})
+	if err := publish("orders", &broker.Message{Body: []byte(`{"id":"1"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if published.Header[IDHeader] != "1" {
+		t.Fatalf("expected the schema id header got %+v", published.Header)
+	}
+	published.Header[IDHeader] = "2"
+	if _, err := writer(r, "orders", published); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected the unknown id to be invalid got %v", err)
+	}
+
+	if err := r.Register(&Schema{Topic: "orders", Format: Protobuf}); err == nil {
+		t.Fatal("expected protobuf schemas to be rejected")
+	}
+}
+
+func TestWriterSchema(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.Register(&Schema{Topic: "payments", Format: Avro, Definition: []byte(paymentSchema)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var delivered []*broker.Message
+
+	// the messages published are tagged with the version of the schema
+	publish := NewPublishWrapper(r)(func(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+		delivered = append(delivered, m)
+		return nil
+	})
+
+	codec, err := goavro.NewCodec(paymentSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := codec.BinaryFromNative(nil, map[string]interface{}{"id": "1", "amount": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hdr := map[string]string{"Content-Type": "avro/binary"}
+	if err := publish("payments", &broker.Message{Header: hdr, Body: b}); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 1 || delivered[0].Header[VersionHeader] != "1" || len(hdr) != 1 {
+		t.Fatalf("expected the version header set on a copy got %+v", delivered)
+	}
+
+	// a field added with a default while the message is in flight
+	if err := r.Register(&Schema{Topic: "payments", Format: Avro, Definition: []byte(`{"type":"record","name":"Payment","fields":[
+		{"name":"id","type":"string"},{"name":"amount","type":"int"},{"name":"currency","type":"string","default":"EUR"}]}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var handled int
+	var handler broker.Handler
+	NewSubscribeWrapper(r)(func(topic string, h broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+		handler = h
+		return nil, nil
+	})("payments", func(m *broker.Message) error {
+		handled++
+		return nil
+	})
+
+	// validated with the version it was written with
+	if err := handler(delivered[0]); err != nil || handled != 1 {
+		t.Fatalf("expected the message of version 1 to be handled got %v", err)
+	}
+
+	// the messages without the header are validated with the latest
+	if err := handler(&broker.Message{Header: map[string]string{}, Body: b}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected the message to be invalid for the latest version got %v", err)
+	}
+
+	// unknown versions are rejected
+	if err := handler(&broker.Message{Header: map[string]string{VersionHeader: "9"}, Body: b}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected the unknown version to be invalid got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/store"
+	"github.com/asim/go-micro/v3/store/memory"
+)
+
+// storePrefix of the schema keys, versions sort as they are zero padded
+const storePrefix = "broker/schema/"
+
+type storeRegistry struct {
+	store store.Store
+
+	sync.RWMutex
+	// latest versions read from the store
+	cache map[string]*cached
+	// versions by key, which never change
+	versions map[string]*Schema
+}
+
+// cached is the latest schema of a topic, nil if it has none
+type cached struct {
+	schema  *Schema
+	expires time.Time
+}
+
+func (r *storeRegistry) key(topic string, version int) string {
+	return fmt.Sprintf("%s%s/%010d", storePrefix, topic, version)
+}
+
+func (r *storeRegistry) read(topic string) (*Schema, error) {
+	keys, err := r.store.List(store.ListPrefix(storePrefix + topic + "/"))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+
+	sort.Strings(keys)
+
+	recs, err := r.store.Read(keys[len(keys)-1])
+	if err == store.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s *Schema
+	if err := json.Unmarshal(recs[0].Value, &s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *storeRegistry) Register(s *Schema) error {
+	if strings.Contains(s.Topic, "/") {
+		return fmt.Errorf("invalid topic %q", s.Topic)
+	}
+
+	if _, err := s.validator(); err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	old, err := r.read(s.Topic)
+	switch err {
+	case nil:
+		if err := Compatible(old, s); err != nil {
+			return err
+		}
+		s.Version = old.Version + 1
+	case ErrNotFound:
+		s.Version = 1
+	default:
+		return err
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err := r.store.Write(&store.Record{Key: r.key(s.Topic, s.Version), Value: b}); err != nil {
+		return err
+	}
+
+	r.cache[s.Topic] = &cached{schema: s, expires: time.Now().Add(DefaultCacheTTL)}
+
+	return nil
+}
+
+// Latest returns the latest schema of the topic. It's cached for the
+// DefaultCacheTTL, the versions registered by other processes are seen
+// once it expires.
+func (r *storeRegistry) Latest(topic string) (*Schema, error) {
+	r.RLock()
+	c, ok := r.cache[topic]
+	r.RUnlock()
+
+	if !ok || time.Now().After(c.expires) {
+		s, err := r.read(topic)
+		if err != nil && err != ErrNotFound {
+			return nil, err
+		}
+
+		c = &cached{schema: s, expires: time.Now().Add(DefaultCacheTTL)}
+
+		r.Lock()
+		r.cache[topic] = c
+		r.Unlock()
+	}
+
+	if c.schema == nil {
+		return nil, ErrNotFound
+	}
+
+	return c.schema, nil
+}
+
+// Version returns the version of the schema of the topic
+func (r *storeRegistry) Version(topic string, version int) (*Schema, error) {
+	key := r.key(topic, version)
+
+	r.RLock()
+	s, ok := r.versions[key]
+	r.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	recs, err := r.store.Read(key)
+	if err == store.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(recs[0].Value, &s); err != nil {
+		return nil, err
+	}
+
+	r.Lock()
+	r.versions[key] = s
+	r.Unlock()
+
+	return s, nil
+}
+
+func (r *storeRegistry) String() string {
+	return "store"
+}
+
+// NewRegistry returns a registry keeping the schemas in the store, or in
+// memory if nil
+func NewRegistry(s store.Store) VersionRegistry {
+	if s == nil {
+		s = memory.NewStore()
+	}
+
+	return &storeRegistry{
+		store:    s,
+		cache:    make(map[string]*cached),
+		versions: make(map[string]*Schema),
+	}
+}
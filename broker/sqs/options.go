@@ -0,0 +1,57 @@
+package sqs
+
+import (
+	"context"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+type sessionKey struct{}
+type fifoKey struct{}
+type visibilityKey struct{}
+type waitTimeKey struct{}
+
+// Session sets the aws session the clients are created with. By default
+// the session is created from the environment.
+func Session(s *session.Session) broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, sessionKey{}, s)
+	}
+}
+
+// FIFO uses fifo topics and queues. Messages with the same key, see
+// broker.WithKey, are in the same message group and delivered in order.
+func FIFO() broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, fifoKey{}, true)
+	}
+}
+
+// VisibilityTimeout is how long a received message is hidden from other
+// consumers. Failed messages are received again once it expires.
+func VisibilityTimeout(d time.Duration) broker.SubscribeOption {
+	return func(o *broker.SubscribeOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, visibilityKey{}, d)
+	}
+}
+
+// WaitTime is how long a receive waits for messages, up to 20 seconds
+func WaitTime(d time.Duration) broker.SubscribeOption {
+	return func(o *broker.SubscribeOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, waitTimeKey{}, d)
+	}
+}
@@ -0,0 +1,556 @@
+// Package sqs provides a broker using sns topics fanning out to sqs queues
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/google/uuid"
+)
+
+var (
+	// DefaultWaitTime of the long polling receive
+	DefaultWaitTime = time.Second * 20
+	// DefaultVisibilityTimeout of received messages
+	DefaultVisibilityTimeout = time.Second * 30
+	// DefaultMaxMessages received at once, at most 10
+	DefaultMaxMessages = 10
+	// MaxDelay of the messages sent to the queues with a delay, longer
+	// delays are scheduled
+	MaxDelay = time.Minute * 15
+)
+
+type sqsBroker struct {
+	opts broker.Options
+
+	sync.RWMutex
+	sns       snsiface.SNSAPI
+	sqs       sqsiface.SQSAPI
+	fifo      bool
+	topics    map[string]string
+	queues    map[string]string
+	connected bool
+	scheduler *broker.Scheduler
+}
+
+type subscriber struct {
+	topic    string
+	opts     broker.SubscribeOptions
+	b        *sqsBroker
+	queueURL string
+	subArn   string
+	shared   bool
+	cancel   context.CancelFunc
+	done     chan bool
+}
+
+func (b *sqsBroker) Options() broker.Options {
+	return b.opts
+}
+
+func (b *sqsBroker) Address() string {
+	if len(b.opts.Addrs) > 0 {
+		return b.opts.Addrs[0]
+	}
+	return "sns"
+}
+
+func (b *sqsBroker) Connect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.connected {
+		return nil
+	}
+
+	if b.sns == nil || b.sqs == nil {
+		sess, ok := b.opts.Context.Value(sessionKey{}).(*session.Session)
+		if !ok {
+			cfg := aws.NewConfig()
+			// the address overrides the endpoint e.g for localstack
+			if len(b.opts.Addrs) > 0 {
+				cfg = cfg.WithEndpoint(b.opts.Addrs[0])
+			}
+
+			s, err := session.NewSession(cfg)
+			if err != nil {
+				return err
+			}
+			sess = s
+		}
+
+		b.sns = sns.New(sess)
+		b.sqs = sqs.New(sess)
+	}
+
+	b.fifo, _ = b.opts.Context.Value(fifoKey{}).(bool)
+	b.topics = make(map[string]string)
+	b.queues = make(map[string]string)
+	b.connected = true
+
+	// sns has no delayed delivery, sqs delays up to the MaxDelay of
+	// standard queues
+	b.scheduler = broker.NewScheduler(b.opts.Store, func(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+		return b.publish(topic, msg, opts...)
+	})
+	b.scheduler.Start()
+
+	return nil
+}
+
+func (b *sqsBroker) Disconnect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.connected {
+		return nil
+	}
+
+	b.connected = false
+	b.scheduler.Stop()
+
+	return nil
+}
+
+func (b *sqsBroker) Init(opts ...broker.Option) error {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, o := range opts {
+		o(&b.opts)
+	}
+
+	return nil
+}
+
+// name returns a valid topic or queue name
+func (b *sqsBroker) name(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, name)
+
+	if b.fifo {
+		name += ".fifo"
+	}
+
+	return name
+}
+
+// topicArn creates the sns topic if it doesn't exist
+func (b *sqsBroker) topicArn(topic string) (string, error) {
+	b.RLock()
+	if !b.connected {
+		b.RUnlock()
+		return "", errors.New("not connected")
+	}
+	arn, ok := b.topics[topic]
+	b.RUnlock()
+
+	if ok {
+		return arn, nil
+	}
+
+	input := &sns.CreateTopicInput{Name: aws.String(b.name(topic))}
+	if b.fifo {
+		input.Attributes = map[string]*string{"FifoTopic": aws.String("true")}
+	}
+
+	// create topic is idempotent
+	rsp, err := b.sns.CreateTopic(input)
+	if err != nil {
+		return "", err
+	}
+
+	b.Lock()
+	b.topics[topic] = *rsp.TopicArn
+	b.Unlock()
+
+	return *rsp.TopicArn, nil
+}
+
+func (b *sqsBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return broker.WrapPublishFunc(b.opts, b.publish)(topic, msg, opts...)
+}
+
+func (b *sqsBroker) publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	arn, err := b.topicArn(topic)
+	if err != nil {
+		return err
+	}
+
+	options := broker.PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if d := time.Until(options.DeliverAt); d > 0 && d <= MaxDelay && !b.fifo {
+		return b.delay(arn, topic, msg, d, options)
+	} else if d > 0 {
+		b.RLock()
+		scheduler := b.scheduler
+		b.RUnlock()
+		return scheduler.Schedule(topic, msg, options)
+	}
+
+	// sns messages are text, the body is base64 encoded
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(arn),
+		Message:  aws.String(string(body)),
+	}
+
+	if b.fifo {
+		group := options.Key
+		if len(group) == 0 {
+			group = topic
+		}
+		id := msg.Header["Micro-Id"]
+		if len(id) == 0 {
+			id = uuid.New().String()
+		}
+		input.MessageGroupId = aws.String(group)
+		input.MessageDeduplicationId = aws.String(id)
+	}
+
+	if _, err := b.sns.PublishWithContext(options.Context, input); err != nil {
+		if options.Ack {
+			return &broker.NackError{Topic: topic, Reason: err.Error()}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// delay sends the message to the queues subscribed to the topic with the
+// delay, fifo queues have no per message delay
+func (b *sqsBroker) delay(arn, topic string, msg *broker.Message, d time.Duration, options broker.PublishOptions) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var queues []string
+	if err := b.sns.ListSubscriptionsByTopicPagesWithContext(options.Context, &sns.ListSubscriptionsByTopicInput{
+		TopicArn: aws.String(arn),
+	}, func(page *sns.ListSubscriptionsByTopicOutput, last bool) bool {
+		for _, s := range page.Subscriptions {
+			if aws.StringValue(s.Protocol) == "sqs" {
+				queues = append(queues, aws.StringValue(s.Endpoint))
+			}
+		}
+		return true
+	}); err != nil {
+		return err
+	}
+
+	// rounded up, delivered no earlier than published at
+	secs := int64((d + time.Second - 1) / time.Second)
+
+	for _, queueArn := range queues {
+		url, err := b.queueURL(queueArn)
+		if err != nil {
+			return err
+		}
+
+		if _, err := b.sqs.SendMessageWithContext(options.Context, &sqs.SendMessageInput{
+			QueueUrl:     aws.String(url),
+			MessageBody:  aws.String(string(body)),
+			DelaySeconds: aws.Int64(secs),
+		}); err != nil {
+			if options.Ack {
+				return &broker.NackError{Topic: topic, Reason: err.Error()}
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queueURL returns the url of the queue with the arn
+func (b *sqsBroker) queueURL(arn string) (string, error) {
+	b.RLock()
+	url, ok := b.queues[arn]
+	b.RUnlock()
+
+	if ok {
+		return url, nil
+	}
+
+	// arn:aws:sqs:region:account:name
+	input := &sqs.GetQueueUrlInput{QueueName: aws.String(arn)}
+	if parts := strings.Split(arn, ":"); len(parts) == 6 {
+		input.QueueName = aws.String(parts[5])
+		input.QueueOwnerAWSAccountId = aws.String(parts[4])
+	}
+
+	rsp, err := b.sqs.GetQueueUrl(input)
+	if err != nil {
+		return "", err
+	}
+
+	b.Lock()
+	b.queues[arn] = *rsp.QueueUrl
+	b.Unlock()
+
+	return *rsp.QueueUrl, nil
+}
+
+func (b *sqsBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	return broker.WrapSubscribeFunc(b.opts, b.subscribe)(topic, handler, opts...)
+}
+
+func (b *sqsBroker) subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	topicArn, err := b.topicArn(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	options := broker.SubscribeOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	visibility := DefaultVisibilityTimeout
+	if d, ok := options.Context.Value(visibilityKey{}).(time.Duration); ok {
+		visibility = d
+	}
+
+	wait := DefaultWaitTime
+	if d, ok := options.Context.Value(waitTimeKey{}).(time.Duration); ok {
+		wait = d
+	}
+
+	// subscribers with a queue share it, others get their own
+	shared := len(options.Queue) > 0
+	queue := options.Queue
+	if !shared {
+		queue = "micro-" + uuid.New().String()
+	}
+
+	attrs := map[string]*string{
+		"VisibilityTimeout":             aws.String(strconv.Itoa(int(visibility.Seconds()))),
+		"ReceiveMessageWaitTimeSeconds": aws.String(strconv.Itoa(int(wait.Seconds()))),
+	}
+	if b.fifo {
+		attrs["FifoQueue"] = aws.String("true")
+	}
+
+	q, err := b.sqs.CreateQueue(&sqs.CreateQueueInput{
+		QueueName:  aws.String(b.name(queue)),
+		Attributes: attrs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	qa, err := b.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       q.QueueUrl,
+		AttributeNames: []*string{aws.String("QueueArn")},
+	})
+	if err != nil {
+		return nil, err
+	}
+	queueArn := aws.StringValue(qa.Attributes["QueueArn"])
+
+	// allow the topic to send to the queue
+	if _, err := b.sqs.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		QueueUrl:   q.QueueUrl,
+		Attributes: map[string]*string{"Policy": aws.String(policy(queueArn, topicArn))},
+	}); err != nil {
+		return nil, err
+	}
+
+	s, err := b.sns.Subscribe(&sns.SubscribeInput{
+		TopicArn:   aws.String(topicArn),
+		Protocol:   aws.String("sqs"),
+		Endpoint:   aws.String(queueArn),
+		Attributes: map[string]*string{"RawMessageDelivery": aws.String("true")},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sub := &subscriber{
+		topic:    topic,
+		opts:     options,
+		b:        b,
+		queueURL: *q.QueueUrl,
+		subArn:   aws.StringValue(s.SubscriptionArn),
+		shared:   shared,
+		cancel:   cancel,
+		done:     make(chan bool),
+	}
+
+	max := DefaultMaxMessages
+	if options.Prefetch > 0 && options.Prefetch < max {
+		max = options.Prefetch
+	}
+
+	go sub.run(ctx, handler, int64(max), int64(wait.Seconds()))
+
+	return sub, nil
+}
+
+func (b *sqsBroker) String() string {
+	return "sqs"
+}
+
+// run receives messages until the subscriber is stopped
+func (s *subscriber) run(ctx context.Context, handler broker.Handler, max, wait int64) {
+	defer close(s.done)
+
+	for {
+		rsp, err := s.b.sqs.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(s.queueURL),
+			MaxNumberOfMessages: aws.Int64(max),
+			WaitTimeSeconds:     aws.Int64(wait),
+			AttributeNames:      []*string{aws.String("ApproximateReceiveCount")},
+		})
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[sqs] receive %s error: %v", s.topic, err)
+			}
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, m := range rsp.Messages {
+			s.handle(m, handler)
+		}
+	}
+}
+
+func (s *subscriber) handle(m *sqs.Message, handler broker.Handler) {
+	var msg broker.Message
+
+	if err := json.Unmarshal([]byte(aws.StringValue(m.Body)), &msg); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[sqs] subscriber %s decode error: %v", s.topic, err)
+		}
+		return
+	}
+
+	count, _ := strconv.Atoi(aws.StringValue(m.Attributes["ApproximateReceiveCount"]))
+	if count > 0 {
+		if msg.Header == nil {
+			msg.Header = make(map[string]string)
+		}
+		msg.Header[broker.DeliveryCountHeader] = strconv.Itoa(count)
+	}
+
+	err := handler(&msg)
+
+	if err != nil {
+		// dead letter once received max retries times
+		if len(s.opts.DeadLetter) == 0 || count < s.opts.MaxRetries {
+			if eh := s.opts.ErrorHandler; eh != nil {
+				eh(&msg, err)
+			} else if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[sqs] subscriber %s error: %v", s.topic, err)
+			}
+			// received again once the visibility timeout expires
+			return
+		}
+
+		if err := broker.PublishDeadLetter(s.b, s.topic, &msg, err, count, s.opts); err != nil {
+			return
+		}
+	}
+
+	s.b.sqs.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(s.queueURL),
+		ReceiptHandle: m.ReceiptHandle,
+	})
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *subscriber) Topic() string {
+	return s.topic
+}
+
+// Lag returns the approximate number of messages in the queue
+func (s *subscriber) Lag() (int64, error) {
+	rsp, err := s.b.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(s.queueURL),
+		AttributeNames: []*string{aws.String("ApproximateNumberOfMessages")},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(aws.StringValue(rsp.Attributes["ApproximateNumberOfMessages"]), 10, 64)
+}
+
+func (s *subscriber) Unsubscribe() error {
+	s.cancel()
+	<-s.done
+
+	if _, err := s.b.sns.Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: aws.String(s.subArn)}); err != nil {
+		return err
+	}
+
+	// queues of a single subscriber are removed
+	if !s.shared {
+		_, err := s.b.sqs.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: aws.String(s.queueURL)})
+		return err
+	}
+
+	return nil
+}
+
+// policy allows the topic to send messages to the queue
+func policy(queueArn, topicArn string) string {
+	return fmt.Sprintf(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"sns.amazonaws.com"},"Action":"sqs:SendMessage","Resource":%q,"Condition":{"ArnEquals":{"aws:SourceArn":%q}}}]}`, queueArn, topicArn)
+}
+
+// NewBroker returns a broker publishing to sns topics which fan out to
+// a sqs queue per subscriber, or per queue for shared subscriptions
+func NewBroker(opts ...broker.Option) broker.Broker {
+	options := broker.Options{
+		Context: context.Background(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &sqsBroker{
+		opts: options,
+	}
+}
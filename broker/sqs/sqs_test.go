@@ -0,0 +1,282 @@
+package sqs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/store/memory"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// fakeAWS fans out sns topics to in memory sqs queues
+type fakeAWS struct {
+	sync.Mutex
+	subs   map[string][]string
+	queues map[string][]*sqs.Message
+	counts map[string]int
+	delays map[string]time.Time
+	groups []string
+}
+
+func newFakeAWS() *fakeAWS {
+	return &fakeAWS{
+		subs:   make(map[string][]string),
+		queues: make(map[string][]*sqs.Message),
+		counts: make(map[string]int),
+		delays: make(map[string]time.Time),
+	}
+}
+
+// fakeSNS and fakeSQS share the fake, both interfaces can't be embedded
+type fakeSNS struct {
+	snsiface.SNSAPI
+	*fakeAWS
+}
+
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	*fakeAWS
+}
+
+func (f *fakeSNS) CreateTopic(in *sns.CreateTopicInput) (*sns.CreateTopicOutput, error) {
+	return &sns.CreateTopicOutput{TopicArn: aws.String("arn:" + *in.Name)}, nil
+}
+
+func (f *fakeSNS) PublishWithContext(ctx aws.Context, in *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if in.MessageGroupId != nil {
+		f.groups = append(f.groups, *in.MessageGroupId)
+	}
+
+	for _, q := range f.subs[*in.TopicArn] {
+		id := fmt.Sprintf("%s-%d", q, len(f.counts))
+		f.counts[id] = 0
+		f.queues[q] = append(f.queues[q], &sqs.Message{Body: in.Message, ReceiptHandle: aws.String(id)})
+	}
+
+	return &sns.PublishOutput{}, nil
+}
+
+func (f *fakeSNS) Subscribe(in *sns.SubscribeInput) (*sns.SubscribeOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.subs[*in.TopicArn] = append(f.subs[*in.TopicArn], *in.Endpoint)
+	return &sns.SubscribeOutput{SubscriptionArn: aws.String(*in.Endpoint)}, nil
+}
+
+func (f *fakeSNS) ListSubscriptionsByTopicPagesWithContext(ctx aws.Context, in *sns.ListSubscriptionsByTopicInput, fn func(*sns.ListSubscriptionsByTopicOutput, bool) bool, opts ...request.Option) error {
+	f.Lock()
+	defer f.Unlock()
+
+	page := &sns.ListSubscriptionsByTopicOutput{}
+	for _, q := range f.subs[*in.TopicArn] {
+		page.Subscriptions = append(page.Subscriptions, &sns.Subscription{Protocol: aws.String("sqs"), Endpoint: aws.String(q)})
+	}
+	fn(page, true)
+
+	return nil
+}
+
+func (f *fakeSNS) Unsubscribe(in *sns.UnsubscribeInput) (*sns.UnsubscribeOutput, error) {
+	return &sns.UnsubscribeOutput{}, nil
+}
+
+func (f *fakeSQS) CreateQueue(in *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+	return &sqs.CreateQueueOutput{QueueUrl: in.QueueName}, nil
+}
+
+func (f *fakeSQS) GetQueueAttributes(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]*string{"QueueArn": in.QueueUrl}}, nil
+}
+
+func (f *fakeSQS) SetQueueAttributes(in *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (f *fakeSQS) GetQueueUrl(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	return &sqs.GetQueueUrlOutput{QueueUrl: in.QueueName}, nil
+}
+
+// SendMessageWithContext adds the message to the queue, hidden until the delay
+func (f *fakeSQS) SendMessageWithContext(ctx aws.Context, in *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	id := fmt.Sprintf("%s-%d", *in.QueueUrl, len(f.counts))
+	f.counts[id] = 0
+	f.delays[id] = time.Now().Add(time.Duration(aws.Int64Value(in.DelaySeconds)) * time.Second)
+	f.queues[*in.QueueUrl] = append(f.queues[*in.QueueUrl], &sqs.Message{Body: in.MessageBody, ReceiptHandle: aws.String(id)})
+
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) DeleteQueue(in *sqs.DeleteQueueInput) (*sqs.DeleteQueueOutput, error) {
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+// ReceiveMessageWithContext returns the messages of the queue which stay
+// visible until deleted
+func (f *fakeSQS) ReceiveMessageWithContext(ctx aws.Context, in *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Millisecond * 10):
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	var msgs []*sqs.Message
+	for _, m := range f.queues[*in.QueueUrl] {
+		if time.Now().Before(f.delays[*m.ReceiptHandle]) {
+			continue
+		}
+		f.counts[*m.ReceiptHandle]++
+		m.Attributes = map[string]*string{"ApproximateReceiveCount": aws.String(fmt.Sprint(f.counts[*m.ReceiptHandle]))}
+		msgs = append(msgs, m)
+	}
+
+	return &sqs.ReceiveMessageOutput{Messages: msgs}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	msgs := f.queues[*in.QueueUrl]
+	for i, m := range msgs {
+		if *m.ReceiptHandle == *in.ReceiptHandle {
+			f.queues[*in.QueueUrl] = append(msgs[:i:i], msgs[i+1:]...)
+			break
+		}
+	}
+
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func newTestBroker(f *fakeAWS, opts ...broker.Option) broker.Broker {
+	b := NewBroker(opts...).(*sqsBroker)
+	b.sns = &fakeSNS{fakeAWS: f}
+	b.sqs = &fakeSQS{fakeAWS: f}
+	return b
+}
+
+func TestSQS(t *testing.T) {
+	f := newFakeAWS()
+
+	b := newTestBroker(f, FIFO())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	recv := make(chan *broker.Message, 10)
+	dead := make(chan *broker.Message, 1)
+
+	sub, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		if string(m.Body) == "fail" {
+			return errors.New("failed")
+		}
+		recv <- m
+		return nil
+	}, broker.DeadLetter("test.dlq", 2), broker.HandleError(func(*broker.Message, error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	if _, err := b.Subscribe("test.dlq", func(m *broker.Message) error {
+		dead <- m
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &broker.Message{Header: map[string]string{"Micro-Id": "1"}, Body: []byte{0xff, 0x00}}
+	if err := b.Publish("test.topic", msg, broker.WithKey("customer-123")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case m := <-recv:
+		if m.Header["Micro-Id"] != "1" || string(m.Body) != string(msg.Body) {
+			t.Fatalf("unexpected message %+v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the message to be received")
+	}
+
+	f.Lock()
+	if len(f.groups) != 1 || f.groups[0] != "customer-123" {
+		t.Fatalf("expected the key as message group got %v", f.groups)
+	}
+	f.Unlock()
+
+	// failed messages are received again until dead lettered
+	if err := b.Publish("test.topic", &broker.Message{Body: []byte("fail")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case m := <-dead:
+		if m.Header[broker.DeadLetterRetriesHeader] != "2" {
+			t.Fatalf("unexpected dead letter %+v", m.Header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the message to be dead lettered")
+	}
+}
+
+func TestSQSDelay(t *testing.T) {
+	f := newFakeAWS()
+	s := memory.NewStore()
+
+	b := newTestBroker(f, broker.Store(s))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	recv := make(chan *broker.Message, 1)
+	if _, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		recv <- m
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// sent to the queue with the delay rather than scheduled
+	if err := b.Publish("test.topic", &broker.Message{Body: []byte("hello")}, broker.Delay(time.Millisecond*500)); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, _ := s.List(); len(keys) != 0 {
+		t.Fatalf("expected the message not to be scheduled got %v", keys)
+	}
+
+	select {
+	case <-recv:
+		t.Fatal("expected the message to be delayed")
+	case <-time.After(time.Millisecond * 300):
+	}
+
+	select {
+	case m := <-recv:
+		if string(m.Body) != "hello" {
+			t.Fatalf("unexpected message %s", m.Body)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("expected the delayed message to be received")
+	}
+}
@@ -0,0 +1,32 @@
+package broker
+
+// PublishFunc publishes a message to the topic
+type PublishFunc func(topic string, m *Message, opts ...PublishOption) error
+
+// PublishWrapper wraps publishing e.g for tracing, metrics or encryption
+type PublishWrapper func(PublishFunc) PublishFunc
+
+// SubscribeFunc subscribes the handler to the topic
+type SubscribeFunc func(topic string, h Handler, opts ...SubscribeOption) (Subscriber, error)
+
+// SubscribeWrapper wraps subscribing, usually to wrap the handler
+// of the subscription
+type SubscribeWrapper func(SubscribeFunc) SubscribeFunc
+
+// WrapPublishFunc returns fn wrapped by the publish wrappers of the
+// options, the first wrapper being the outermost
+func WrapPublishFunc(opts Options, fn PublishFunc) PublishFunc {
+	for i := len(opts.PublishWrappers); i > 0; i-- {
+		fn = opts.PublishWrappers[i-1](fn)
+	}
+	return fn
+}
+
+// WrapSubscribeFunc returns fn wrapped by the subscribe wrappers of the
+// options, the first wrapper being the outermost
+func WrapSubscribeFunc(opts Options, fn SubscribeFunc) SubscribeFunc {
+	for i := len(opts.SubscribeWrappers); i > 0; i-- {
+		fn = opts.SubscribeWrappers[i-1](fn)
+	}
+	return fn
+}
@@ -12,3 +12,11 @@ type BackoffFunc func(ctx context.Context, req Request, attempts int) (time.Dura
 func exponentialBackoff(ctx context.Context, req Request, attempts int) (time.Duration, error) {
 	return backoff.Do(attempts), nil
 }
+
+// BackoffStrategy returns a BackoffFunc using the backoff strategy e.g
+// client.Backoff(client.BackoffStrategy(backoff.ExpJitter(base, max)))
+func BackoffStrategy(s backoff.Strategy) BackoffFunc {
+	return func(ctx context.Context, req Request, attempts int) (time.Duration, error) {
+		return s(attempts), nil
+	}
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+var (
+	// DefaultBatchParallelism is the max number of calls a batch makes at once
+	DefaultBatchParallelism = 10
+)
+
+// BatchRequest is a single call made by Batch
+type BatchRequest struct {
+	Request Request
+	// Response is decoded into by the call
+	Response interface{}
+	// Options for the call
+	Options []CallOption
+}
+
+// BatchResponse is the result of a call made by Batch
+type BatchResponse struct {
+	Request  Request
+	Response interface{}
+	// Error of the call, nil if it succeeded
+	Error error
+}
+
+// BatchOptions control how a batch is executed
+type BatchOptions struct {
+	// Parallelism is the max number of calls in flight
+	Parallelism int
+	// Timeout is the deadline shared by all the calls
+	Timeout time.Duration
+	// FailFast cancels the remaining calls on the first error
+	FailFast bool
+}
+
+// BatchOption sets values in BatchOptions
+type BatchOption func(*BatchOptions)
+
+// BatchParallelism sets the max number of calls in flight
+func BatchParallelism(n int) BatchOption {
+	return func(o *BatchOptions) {
+		o.Parallelism = n
+	}
+}
+
+// BatchTimeout sets a deadline shared by all the calls in the batch
+func BatchTimeout(d time.Duration) BatchOption {
+	return func(o *BatchOptions) {
+		o.Timeout = d
+	}
+}
+
+// BatchFailFast cancels the calls not yet completed once any call fails
+func BatchFailFast() BatchOption {
+	return func(o *BatchOptions) {
+		o.FailFast = true
+	}
+}
+
+// Batch makes the calls concurrently and returns a response for each request
+// in the same order. A failed call doesn't fail the batch, its error is set on
+// its response. Calls which couldn't be made before the deadline fail with a
+// timeout error.
+func Batch(ctx context.Context, c Client, reqs []*BatchRequest, opts ...BatchOption) []*BatchResponse {
+	options := BatchOptions{
+		Parallelism: DefaultBatchParallelism,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Parallelism <= 0 {
+		options.Parallelism = len(reqs)
+	}
+
+	var cancel context.CancelFunc
+	if options.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	rsps := make([]*BatchResponse, len(reqs))
+	sem := make(chan struct{}, options.Parallelism)
+
+	var wg sync.WaitGroup
+
+	for i, req := range reqs {
+		rsps[i] = &BatchResponse{
+			Request:  req.Request,
+			Response: req.Response,
+		}
+
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+
+		if err := ctx.Err(); err != nil {
+			rsps[i].Error = errors.Timeout("go.micro.client", fmt.Sprintf("batch: %v", err))
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(req *BatchRequest, rsp *BatchResponse) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			rsp.Error = c.Call(ctx, req.Request, req.Response, req.Options...)
+
+			if rsp.Error != nil && options.FailFast {
+				cancel()
+			}
+		}(req, rsps[i])
+	}
+
+	wg.Wait()
+
+	return rsps
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+var (
+	// ErrCircuitOpen is returned when the circuit breaker short circuits a call
+	ErrCircuitOpen = errors.New("go.micro.client", "circuit breaker is open", http.StatusServiceUnavailable)
+)
+
+// CircuitState is the state of a circuit
+type CircuitState string
+
+const (
+	// CircuitClosed allows calls
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen short circuits calls
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen allows a single trial call
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitStat is a snapshot of a circuit
+type CircuitStat struct {
+	// Key is the service name or node address
+	Key      string       `json:"key"`
+	State    CircuitState `json:"state"`
+	Requests uint64       `json:"requests"`
+	Failures uint64       `json:"failures"`
+	// Opened is the unix timestamp the circuit last opened
+	Opened int64 `json:"opened,omitempty"`
+}
+
+type circuit struct {
+	state    CircuitState
+	start    time.Time
+	opened   time.Time
+	requests uint64
+	failures uint64
+	// a trial call is in flight when half open
+	trial bool
+}
+
+// Breaker tracks failures per service and per node. A circuit opens once
+// the failures within the window reach the threshold. After the cooldown
+// a single trial call is allowed which closes the circuit on success.
+type Breaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewBreaker returns a circuit breaker
+func NewBreaker(threshold int, window, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+func (b *Breaker) get(key string, now time.Time) *circuit {
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{state: CircuitClosed, start: now}
+		b.circuits[key] = c
+	}
+
+	// reset the window
+	if c.state == CircuitClosed && now.Sub(c.start) > b.window {
+		c.start = now
+		c.requests = 0
+		c.failures = 0
+	}
+
+	return c
+}
+
+// Allow returns ErrCircuitOpen if the circuit for any of the keys is open
+func (b *Breaker) Allow(keys ...string) error {
+	b.Lock()
+	defer b.Unlock()
+
+	now := time.Now()
+	circuits := make([]*circuit, 0, len(keys))
+
+	for _, key := range keys {
+		c := b.get(key, now)
+
+		switch c.state {
+		case CircuitOpen:
+			if now.Sub(c.opened) < b.cooldown {
+				return ErrCircuitOpen
+			}
+		case CircuitHalfOpen:
+			if c.trial {
+				return ErrCircuitOpen
+			}
+		}
+
+		circuits = append(circuits, c)
+	}
+
+	// allow a trial call for the circuits past the cooldown
+	for _, c := range circuits {
+		if c.state != CircuitClosed {
+			c.state = CircuitHalfOpen
+			c.trial = true
+		}
+	}
+
+	return nil
+}
+
+// Record the result of a call for the keys
+func (b *Breaker) Record(err error, keys ...string) {
+	b.Lock()
+	defer b.Unlock()
+
+	now := time.Now()
+	failed := failure(err)
+
+	for _, key := range keys {
+		c := b.get(key, now)
+		c.requests++
+
+		switch c.state {
+		case CircuitHalfOpen:
+			c.trial = false
+			if failed {
+				c.failures++
+				c.state = CircuitOpen
+				c.opened = now
+				continue
+			}
+			// the trial succeeded so close the circuit
+			c.state = CircuitClosed
+			c.start = now
+			c.requests = 0
+			c.failures = 0
+		case CircuitClosed:
+			if !failed {
+				continue
+			}
+			c.failures++
+			if int(c.failures) >= b.threshold {
+				c.state = CircuitOpen
+				c.opened = now
+			}
+		}
+	}
+}
+
+// Cancel releases the trial calls Allow granted for the keys without
+// recording a result, for calls which weren't made e.g as they were shed
+func (b *Breaker) Cancel(keys ...string) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, key := range keys {
+		if c, ok := b.circuits[key]; ok && c.state == CircuitHalfOpen {
+			c.trial = false
+		}
+	}
+}
+
+// Stats returns a snapshot of the circuits sorted by key
+func (b *Breaker) Stats() []*CircuitStat {
+	b.Lock()
+	defer b.Unlock()
+
+	stats := make([]*CircuitStat, 0, len(b.circuits))
+
+	for key, c := range b.circuits {
+		stat := &CircuitStat{
+			Key:      key,
+			State:    c.state,
+			Requests: c.requests,
+			Failures: c.failures,
+		}
+		if !c.opened.IsZero() {
+			stat.Opened = c.opened.Unix()
+		}
+		stats = append(stats, stat)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Key < stats[j].Key
+	})
+
+	return stats
+}
+
+// failure returns true if the error is a timeout or server error
+func failure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	e := errors.FromError(err)
+
+	return e.Code == 0 || e.Code == http.StatusRequestTimeout || e.Code >= http.StatusInternalServerError
+}
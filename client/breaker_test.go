@@ -0,0 +1,46 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+func TestBreaker(t *testing.T) {
+	b := NewBreaker(2, time.Minute, time.Millisecond*50)
+	ierr := errors.InternalServerError("foo", "error")
+
+	// client errors don't open the circuit
+	b.Record(errors.BadRequest("foo", "bad request"), "foo", "10.0.0.1:8080")
+	b.Record(ierr, "foo", "10.0.0.1:8080")
+	if err := b.Allow("foo", "10.0.0.1:8080"); err != nil {
+		t.Fatalf("expected circuit to be closed got %v", err)
+	}
+
+	b.Record(ierr, "foo", "10.0.0.1:8080")
+	if err := b.Allow("foo", "10.0.0.1:8080"); err != ErrCircuitOpen {
+		t.Fatalf("expected circuit to be open got %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+
+	// a single trial call is allowed after the cooldown
+	if err := b.Allow("foo", "10.0.0.1:8080"); err != nil {
+		t.Fatalf("expected trial call got %v", err)
+	}
+	if err := b.Allow("foo", "10.0.0.1:8080"); err != ErrCircuitOpen {
+		t.Fatalf("expected circuit to be half open got %v", err)
+	}
+
+	b.Record(nil, "foo", "10.0.0.1:8080")
+	if err := b.Allow("foo", "10.0.0.1:8080"); err != nil {
+		t.Fatalf("expected circuit to be closed got %v", err)
+	}
+
+	for _, stat := range b.Stats() {
+		if stat.State != CircuitClosed {
+			t.Fatalf("expected %s to be closed got %s", stat.Key, stat.State)
+		}
+	}
+}
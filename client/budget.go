@@ -0,0 +1,74 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultRetryBudgetMinRetries are always allowed per window so
+	// services with little traffic can still retry
+	DefaultRetryBudgetMinRetries = 10
+)
+
+type retryWindow struct {
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// Budget limits retries to a ratio of the requests made to a service
+// within a window e.g 0.2 allows 20% extra requests. Once the budget is
+// spent retries are denied until the next window so retries don't amplify
+// the load on a saturated service.
+type Budget struct {
+	ratio  float64
+	window time.Duration
+
+	sync.Mutex
+	budgets map[string]*retryWindow
+}
+
+// NewBudget returns a retry budget
+func NewBudget(ratio float64, window time.Duration) *Budget {
+	return &Budget{
+		ratio:   ratio,
+		window:  window,
+		budgets: make(map[string]*retryWindow),
+	}
+}
+
+func (r *Budget) get(service string) *retryWindow {
+	now := time.Now()
+
+	b, ok := r.budgets[service]
+	if !ok || now.Sub(b.start) > r.window {
+		b = &retryWindow{start: now}
+		r.budgets[service] = b
+	}
+
+	return b
+}
+
+// Request records a request to the service
+func (r *Budget) Request(service string) {
+	r.Lock()
+	r.get(service).requests++
+	r.Unlock()
+}
+
+// Retry returns true and spends the budget if a retry to the service is allowed
+func (r *Budget) Retry(service string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	b := r.get(service)
+
+	if b.retries >= DefaultRetryBudgetMinRetries && float64(b.retries) >= float64(b.requests)*r.ratio {
+		return false
+	}
+
+	b.retries++
+
+	return true
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(0.2, time.Minute)
+
+	for i := 0; i < 100; i++ {
+		b.Request("foo")
+	}
+
+	var retries int
+	for i := 0; i < 100; i++ {
+		if b.Retry("foo") {
+			retries++
+		}
+	}
+
+	if retries != 20 {
+		t.Fatalf("expected 20 retries got %d", retries)
+	}
+
+	// the minimum is allowed without requests
+	retries = 0
+	for i := 0; i < 100; i++ {
+		if b.Retry("bar") {
+			retries++
+		}
+	}
+
+	if retries != DefaultRetryBudgetMinRetries {
+		t.Fatalf("expected %d retries got %d", DefaultRetryBudgetMinRetries, retries)
+	}
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/store"
+)
+
+var (
+	// DefaultCacheSize is the max number of responses held in memory
+	DefaultCacheSize = 1000
+
+	// CacheControlKey is the metadata key used to control caching.
+	// A value of "no-cache" bypasses the cached response and refreshes it.
+	CacheControlKey = "Cache-Control"
+)
+
+type cacheEntry struct {
+	key    string
+	value  []byte
+	expiry time.Time
+}
+
+// Cache is an LRU of responses keyed by service, endpoint and a hash of the
+// request. Responses are json encoded so they are copied in and out of the
+// cache. If a store is provided responses are also written to it so they can
+// be shared by other clients and survive eviction.
+type Cache struct {
+	size  int
+	store store.Store
+
+	sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+}
+
+// NewCache returns a response cache holding up to size entries in memory.
+// The store is optional.
+func NewCache(size int, st store.Store) *Cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	return &Cache{
+		size:  size,
+		store: st,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// Key returns the cache key for the request
+func (c *Cache) Key(req Request) (string, error) {
+	b, err := json.Marshal(req.Body())
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(b)
+
+	return cachePrefix(req.Service(), req.Endpoint()) + hex.EncodeToString(h[:]), nil
+}
+
+// Get decodes the cached response into rsp and returns true if found
+func (c *Cache) Get(key string, rsp interface{}) bool {
+	c.Lock()
+	e, ok := c.items[key]
+	if ok && time.Now().After(e.Value.(*cacheEntry).expiry) {
+		c.remove(e)
+		ok = false
+	}
+	if ok {
+		c.lru.MoveToFront(e)
+		b := e.Value.(*cacheEntry).value
+		c.Unlock()
+		return json.Unmarshal(b, rsp) == nil
+	}
+	c.Unlock()
+
+	if c.store == nil {
+		return false
+	}
+
+	recs, err := c.store.Read(key)
+	if err != nil || len(recs) == 0 {
+		return false
+	}
+
+	return json.Unmarshal(recs[0].Value, rsp) == nil
+}
+
+// Set caches the response for the ttl
+func (c *Cache) Set(key string, rsp interface{}, ttl time.Duration) error {
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{
+		key:    key,
+		value:  b,
+		expiry: time.Now().Add(ttl),
+	})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+	c.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+
+	return c.store.Write(&store.Record{
+		Key:    key,
+		Value:  b,
+		Expiry: ttl,
+	})
+}
+
+// Invalidate removes the cached responses for the endpoint of a service.
+// An empty endpoint removes all the responses for the service.
+func (c *Cache) Invalidate(service, endpoint string) error {
+	p := cachePrefix(service, endpoint)
+
+	c.Lock()
+	for key, e := range c.items {
+		if strings.HasPrefix(key, p) {
+			c.remove(e)
+		}
+	}
+	c.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+
+	keys, err := c.store.List(store.ListPrefix(p))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := c.store.Delete(key); err != nil && err != store.ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.items, e.Value.(*cacheEntry).key)
+}
+
+func cachePrefix(service, endpoint string) string {
+	if len(endpoint) == 0 {
+		return service + "/"
+	}
+	return service + "/" + endpoint + "/"
+}
+
+// CacheBypass returns true if the context metadata asks to bypass the cache
+func CacheBypass(ctx context.Context) bool {
+	v, ok := metadata.Get(ctx, CacheControlKey)
+	return ok && strings.EqualFold(v, "no-cache")
+}
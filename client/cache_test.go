@@ -0,0 +1,83 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/store/memory"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCache(2, nil)
+
+	set := func(endpoint, body string) string {
+		key, err := c.Key(newRequest("foo", endpoint, body, "application/json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Set(key, body, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	a := set("Foo.Bar", "a")
+	b := set("Foo.Bar", "b")
+
+	var rsp string
+	if !c.Get(a, &rsp) || rsp != "a" {
+		t.Fatalf("expected cached response a got %s", rsp)
+	}
+
+	// b is the least recently used so it's evicted
+	set("Foo.Baz", "c")
+
+	if c.Get(b, &rsp) {
+		t.Fatal("expected b to be evicted")
+	}
+
+	if err := c.Invalidate("foo", "Foo.Bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(a, &rsp) {
+		t.Fatal("expected a to be invalidated")
+	}
+
+	// expired responses are not returned
+	key, _ := c.Key(newRequest("foo", "Foo.Bar", "d", "application/json"))
+	c.Set(key, "d", -time.Second)
+
+	if c.Get(key, &rsp) {
+		t.Fatal("expected d to be expired")
+	}
+}
+
+func TestCacheStore(t *testing.T) {
+	st := memory.NewStore()
+
+	key, err := NewCache(1, st).Key(newRequest("foo", "Foo.Bar", "a", "application/json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewCache(1, st).Set(key, "a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// responses are shared through the store
+	c := NewCache(1, st)
+
+	var rsp string
+	if !c.Get(key, &rsp) || rsp != "a" {
+		t.Fatalf("expected stored response a got %s", rsp)
+	}
+
+	if err := c.Invalidate("foo", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(key, &rsp) {
+		t.Fatal("expected the stored response to be invalidated")
+	}
+}
@@ -103,4 +103,28 @@ var (
 	DefaultPoolSize = 100
 	// DefaultPoolTTL sets the connection pool ttl
 	DefaultPoolTTL = time.Minute
+	// DefaultMaxReconnects is the max number of reconnects of a stream
+	DefaultMaxReconnects = 10
+	// DefaultMaxRecvSize is the max size in bytes of a grpc response
+	DefaultMaxRecvSize = 1024 * 1024 * 4
+	// StreamResumeKey is the metadata key holding the number of messages
+	// received by a stream before it was reconnected
+	StreamResumeKey = "Micro-Stream-Resume"
 )
+
+// PublishSync publishes the message with the client and waits for the
+// broker to acknowledge it. See WaitForAck.
+func PublishSync(ctx context.Context, c Client, msg Message, opts ...PublishOption) error {
+	return c.Publish(ctx, msg, append(opts, WaitForAck())...)
+}
+
+// ReconnectPolicy controls how broken streams are re-established
+type ReconnectPolicy struct {
+	// Attempts is the max number of reconnects for each failure
+	Attempts int
+	// Max is the max number of reconnects over the life of the stream,
+	// defaults to DefaultMaxReconnects
+	Max int
+	// Backoff between attempts, defaults to the call backoff
+	Backoff BackoffFunc
+}
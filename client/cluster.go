@@ -0,0 +1,28 @@
+package client
+
+import (
+	"github.com/asim/go-micro/v3/router"
+)
+
+// LocalCluster is the name of the cluster of the client router
+var LocalCluster = "local"
+
+// ClusterRouter is a named cluster and the router for its registry
+type ClusterRouter struct {
+	Name   string
+	Router router.Router
+}
+
+// ClusterPolicy determines the clusters used when the cluster
+// of the call has no nodes for the service
+type ClusterPolicy string
+
+const (
+	// PreferLocal fails over to the nodes of all the other clusters
+	PreferLocal ClusterPolicy = "prefer-local"
+	// Failover fails over to the next cluster with nodes in the order
+	// the clusters were added
+	Failover ClusterPolicy = "failover"
+	// RoundRobinAcrossClusters balances calls across the nodes of all clusters
+	RoundRobinAcrossClusters ClusterPolicy = "round-robin"
+)
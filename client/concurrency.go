@@ -0,0 +1,168 @@
+package client
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+var (
+	// ErrOverloaded is returned when a call exceeds the concurrency limit of the service
+	ErrOverloaded = errors.New("go.micro.client", "service overloaded", http.StatusServiceUnavailable)
+
+	// DefaultLatencyTolerance is the multiple of the minimum latency above
+	// which a call is considered a sign of congestion
+	DefaultLatencyTolerance = 2.0
+
+	// DefaultLimitBackoff is the factor the limit is multiplied by on congestion
+	DefaultLimitBackoff = 0.9
+
+	// DefaultLatencyWindow is the number of calls after which the minimum
+	// latency is reset so the limiter adapts to changes in the service
+	DefaultLatencyWindow = 100
+)
+
+// ConcurrencyStat is a snapshot of the concurrency limit of a service
+type ConcurrencyStat struct {
+	Service string `json:"service"`
+	// Limit is the current number of calls allowed in flight
+	Limit int `json:"limit"`
+	// InFlight is the number of calls in flight
+	InFlight int `json:"in_flight"`
+	// MinLatency is the lowest latency observed in the window
+	MinLatency time.Duration `json:"min_latency"`
+	// Allowed is the total number of calls allowed
+	Allowed uint64 `json:"allowed"`
+	// Rejected is the total number of calls rejected
+	Rejected uint64 `json:"rejected"`
+}
+
+type concurrency struct {
+	limit    float64
+	inflight int
+	// min latency of the previous and current window
+	minRTT    time.Duration
+	windowRTT time.Duration
+	samples   int
+	allowed   uint64
+	rejected  uint64
+}
+
+// ConcurrencyLimiter is an AIMD limit on the calls in flight to each service.
+// The limit grows by one for each round of calls completing within the
+// latency tolerance and shrinks on slow or failed calls, so excess calls are
+// rejected early with ErrOverloaded rather than queueing until they time out.
+type ConcurrencyLimiter struct {
+	initial int
+	max     int
+
+	sync.Mutex
+	limits map[string]*concurrency
+}
+
+// NewConcurrencyLimiter returns a limiter starting each service at the initial
+// limit which can grow up to max
+func NewConcurrencyLimiter(initial, max int) *ConcurrencyLimiter {
+	if initial <= 0 {
+		initial = 1
+	}
+	if max < initial {
+		max = initial
+	}
+
+	return &ConcurrencyLimiter{
+		initial: initial,
+		max:     max,
+		limits:  make(map[string]*concurrency),
+	}
+}
+
+func (c *ConcurrencyLimiter) get(service string) *concurrency {
+	l, ok := c.limits[service]
+	if !ok {
+		l = &concurrency{limit: float64(c.initial)}
+		c.limits[service] = l
+	}
+	return l
+}
+
+// Acquire returns ErrOverloaded if the service is at its limit. Otherwise the
+// call is in flight until Release is called.
+func (c *ConcurrencyLimiter) Acquire(service string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	l := c.get(service)
+
+	if l.inflight >= int(l.limit) {
+		l.rejected++
+		return ErrOverloaded
+	}
+
+	l.inflight++
+	l.allowed++
+
+	return nil
+}
+
+// Release records the latency and error of a call and adjusts the limit
+func (c *ConcurrencyLimiter) Release(service string, latency time.Duration, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	l := c.get(service)
+
+	if l.inflight > 0 {
+		l.inflight--
+	}
+
+	if l.windowRTT == 0 || latency < l.windowRTT {
+		l.windowRTT = latency
+	}
+	if l.minRTT == 0 || latency < l.minRTT {
+		l.minRTT = latency
+	}
+
+	l.samples++
+	if l.samples >= DefaultLatencyWindow {
+		l.minRTT = l.windowRTT
+		l.windowRTT = 0
+		l.samples = 0
+	}
+
+	if failure(err) || float64(latency) > float64(l.minRTT)*DefaultLatencyTolerance {
+		l.limit = math.Max(1, l.limit*DefaultLimitBackoff)
+		return
+	}
+
+	l.limit = math.Min(float64(c.max), l.limit+1/l.limit)
+}
+
+// Stats returns a snapshot of the concurrency limit of each service
+func (c *ConcurrencyLimiter) Stats() []*ConcurrencyStat {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := make([]*ConcurrencyStat, 0, len(c.limits))
+
+	for service, l := range c.limits {
+		stats = append(stats, &ConcurrencyStat{
+			Service:    service,
+			Limit:      int(l.limit),
+			InFlight:   l.inflight,
+			MinLatency: l.minRTT,
+			Allowed:    l.allowed,
+			Rejected:   l.rejected,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Service < stats[j].Service
+	})
+
+	return stats
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	c := NewConcurrencyLimiter(2, 4)
+
+	if err := c.Acquire("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Acquire("foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	// excess calls are rejected rather than queued
+	if err := c.Acquire("foo"); err != ErrOverloaded {
+		t.Fatalf("expected ErrOverloaded got %v", err)
+	}
+
+	// other services have their own limit
+	if err := c.Acquire("bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	// fast calls grow the limit
+	for i := 0; i < 20; i++ {
+		c.Release("foo", time.Millisecond, nil)
+		c.Acquire("foo")
+	}
+
+	stats := c.Stats()
+	if len(stats) != 2 || stats[1].Service != "foo" || stats[1].Limit != 4 {
+		t.Fatalf("expected the limit to grow to 4 got %+v", stats[1])
+	}
+
+	// slow and failed calls shrink it
+	c.Release("foo", time.Second, nil)
+	for i := 0; i < 10; i++ {
+		c.Release("foo", time.Millisecond, errors.InternalServerError("foo", "error"))
+	}
+
+	stats = c.Stats()
+	if stats[1].Limit >= 4 || stats[1].Rejected != 1 {
+		t.Fatalf("expected the limit to shrink got %+v", stats[1])
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/asim/go-micro/v3/metadata"
+)
+
+var (
+	// DeadlineKey is the metadata key used to propagate the absolute
+	// deadline of a call as unix nanoseconds
+	DeadlineKey = "Micro-Deadline"
+
+	// DeadlineMiddleware is the name of the DeadlineWrapper in the call wrappers
+	DeadlineMiddleware = "deadline"
+)
+
+// DeadlineWrapper sets the context deadline in the call metadata so servers
+// and their downstream calls respect the timeout of the original caller.
+// It's included in the default middleware and disabled with
+// DeadlinePropagation(false).
+func DeadlineWrapper(cf CallFunc) CallFunc {
+	return func(ctx context.Context, addr string, req Request, rsp interface{}, opts CallOptions) error {
+		// don't forward a deadline received from upstream
+		if !opts.DeadlinePropagation {
+			return cf(metadata.Delete(ctx, DeadlineKey), addr, req, rsp, opts)
+		}
+
+		if d, ok := ctx.Deadline(); ok {
+			ctx = metadata.Set(ctx, DeadlineKey, strconv.FormatInt(d.UnixNano(), 10))
+		}
+
+		return cf(ctx, addr, req, rsp, opts)
+	}
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/metadata"
+)
+
+func TestDeadlineWrapper(t *testing.T) {
+	var got string
+	var ok bool
+
+	cf := DeadlineWrapper(func(ctx context.Context, addr string, req Request, rsp interface{}, opts CallOptions) error {
+		got, ok = metadata.Get(ctx, DeadlineKey)
+		return nil
+	})
+
+	d := time.Now().Add(time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), d)
+	defer cancel()
+
+	opts := NewOptions().CallOptions
+	req := newRequest("foo", "Foo.Bar", nil, "application/json")
+
+	cf(ctx, "", req, nil, opts)
+
+	if !ok || got != strconv.FormatInt(d.UnixNano(), 10) {
+		t.Fatalf("expected deadline %d got %s", d.UnixNano(), got)
+	}
+
+	// the upstream deadline is not forwarded when disabled
+	ctx = metadata.Set(ctx, DeadlineKey, got)
+	opts.DeadlinePropagation = false
+
+	cf(ctx, "", req, nil, opts)
+
+	if ok {
+		t.Fatalf("expected no deadline got %s", got)
+	}
+}
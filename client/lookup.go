@@ -3,9 +3,13 @@ package client
 import (
 	"context"
 	"sort"
+	"strconv"
 
 	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/health"
 	"github.com/asim/go-micro/v3/router"
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/asim/go-micro/v3/util/semver"
 )
 
 // LookupFunc is used to lookup routes for a service
@@ -18,6 +22,90 @@ func LookupRoute(ctx context.Context, req Request, opts CallOptions) ([]string,
 		return opts.Address, nil
 	}
 
+	if len(opts.Clusters) > 0 {
+		return lookupClusters(ctx, req, opts)
+	}
+
+	return lookup(ctx, req, opts, opts.Router)
+}
+
+// lookupClusters looks up the routes in the cluster of the call and
+// fails over to the other clusters according to the cluster policy
+func lookupClusters(ctx context.Context, req Request, opts CallOptions) ([]string, error) {
+	clusters := append([]ClusterRouter{{Name: LocalCluster, Router: opts.Router}}, opts.Clusters...)
+
+	name := opts.Cluster
+	if len(name) == 0 {
+		name = LocalCluster
+	}
+
+	// balance across the routes of all clusters
+	if opts.ClusterPolicy == RoundRobinAcrossClusters {
+		return lookupAll(ctx, req, opts, clusters)
+	}
+
+	var target router.Router
+	var others []ClusterRouter
+
+	for _, c := range clusters {
+		if c.Name == name {
+			target = c.Router
+		} else {
+			others = append(others, c)
+		}
+	}
+
+	if target == nil {
+		return nil, errors.BadRequest("go.micro.client", "unknown cluster %s", name)
+	}
+
+	addrs, err := lookup(ctx, req, opts, target)
+	if err == nil {
+		return addrs, nil
+	}
+
+	switch opts.ClusterPolicy {
+	case PreferLocal:
+		if addrs, ferr := lookupAll(ctx, req, opts, others); ferr == nil {
+			return addrs, nil
+		}
+	case Failover:
+		for _, c := range others {
+			if addrs, ferr := lookup(ctx, req, opts, c.Router); ferr == nil {
+				return addrs, nil
+			}
+		}
+	}
+
+	return nil, err
+}
+
+// lookupAll returns the routes of all the clusters
+func lookupAll(ctx context.Context, req Request, opts CallOptions, clusters []ClusterRouter) ([]string, error) {
+	var addrs []string
+	var gerr error
+
+	for _, c := range clusters {
+		a, err := lookup(ctx, req, opts, c.Router)
+		if err != nil {
+			gerr = err
+			continue
+		}
+		addrs = append(addrs, a...)
+	}
+
+	if len(addrs) == 0 {
+		if gerr == nil {
+			gerr = errors.InternalServerError("go.micro.client", "service %s: %s", req.Service(), router.ErrRouteNotFound.Error())
+		}
+		return nil, gerr
+	}
+
+	return addrs, nil
+}
+
+// lookup the routes for the request in the router
+func lookup(ctx context.Context, req Request, opts CallOptions, r router.Router) ([]string, error) {
 	// construct the router query
 	query := []router.LookupOption{}
 
@@ -28,13 +116,40 @@ func LookupRoute(ctx context.Context, req Request, opts CallOptions) ([]string,
 	}
 
 	// lookup the routes which can be used to execute the request
-	routes, err := opts.Router.Lookup(req.Service(), query...)
+	routes, err := r.Lookup(req.Service(), query...)
 	if err == router.ErrRouteNotFound {
 		return nil, errors.InternalServerError("go.micro.client", "service %s: %s", req.Service(), err.Error())
 	} else if err != nil {
 		return nil, errors.InternalServerError("go.micro.client", "error getting next %s node: %s", req.Service(), err.Error())
 	}
 
+	// filter the routes by version
+	if len(opts.VersionConstraint) > 0 {
+		c, err := semver.NewConstraint(opts.VersionConstraint)
+		if err != nil {
+			return nil, errors.BadRequest("go.micro.client", "invalid version constraint %s", opts.VersionConstraint)
+		}
+
+		routes = filterVersion(routes, c)
+		if len(routes) == 0 {
+			return nil, errors.InternalServerError("go.micro.client", "service %s: no nodes match version %s", req.Service(), c)
+		}
+	}
+
+	// filter the routes by the version and metadata of their nodes
+	if len(opts.FilterVersion) > 0 || len(opts.FilterMetadata) > 0 {
+		routes = filterMetadata(routes, opts.FilterVersion, opts.FilterMetadata)
+		if len(routes) == 0 {
+			return nil, errors.InternalServerError("go.micro.client", "service %s: no nodes match the filters", req.Service())
+		}
+	}
+
+	// skip the nodes failing their readiness checks
+	routes = filterHealthy(routes)
+	if len(routes) == 0 {
+		return nil, errors.InternalServerError("go.micro.client", "service %s: no healthy nodes", req.Service())
+	}
+
 	// sort by lowest metric first
 	sort.Slice(routes, func(i, j int) bool {
 		return routes[i].Metric < routes[j].Metric
@@ -48,3 +163,105 @@ func LookupRoute(ctx context.Context, req Request, opts CallOptions) ([]string,
 
 	return addrs, nil
 }
+
+// LookupMetadata returns the values of the metadata key of the nodes of the
+// request by address, for the selectors selecting by the metadata of the
+// routes such as their weight or zone
+func LookupMetadata(req Request, opts CallOptions, key string) map[string]string {
+	routers := []router.Router{opts.Router}
+	for _, c := range opts.Clusters {
+		routers = append(routers, c.Router)
+	}
+
+	values := make(map[string]string)
+
+	for _, r := range routers {
+		if r == nil {
+			continue
+		}
+		routes, err := r.Lookup(req.Service())
+		if err != nil {
+			continue
+		}
+		for _, route := range routes {
+			if v, ok := route.Metadata[key]; ok && len(v) > 0 {
+				values[route.Address] = v
+			}
+		}
+	}
+
+	return values
+}
+
+// LookupWeights returns the weights of the routes of the request by
+// address, read from the selector.WeightKey metadata of their nodes. It's
+// used to pass the weights to the selectors balancing by weight.
+func LookupWeights(req Request, opts CallOptions) map[string]int {
+	weights := make(map[string]int)
+
+	for addr, v := range LookupMetadata(req, opts, selector.WeightKey) {
+		w, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		weights[addr] = w
+	}
+
+	return weights
+}
+
+// filterVersion returns the routes whose version satisfies the constraint
+func filterVersion(routes []router.Route, c *semver.Constraint) []router.Route {
+	var filtered []router.Route
+
+	for _, route := range routes {
+		v, err := semver.Parse(route.Metadata["version"])
+		if err != nil {
+			continue
+		}
+		if c.Check(v) {
+			filtered = append(filtered, route)
+		}
+	}
+
+	return filtered
+}
+
+// filterMetadata returns the routes of the version if set whose metadata
+// has the values of md
+func filterMetadata(routes []router.Route, version string, md map[string]string) []router.Route {
+	var filtered []router.Route
+
+	for _, route := range routes {
+		if len(version) > 0 && route.Metadata["version"] != version {
+			continue
+		}
+		matched := true
+		for k, v := range md {
+			if route.Metadata[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			filtered = append(filtered, route)
+		}
+	}
+
+	return filtered
+}
+
+// filterHealthy returns the routes of the nodes which aren't down, nodes
+// registered without a status are healthy
+func filterHealthy(routes []router.Route) []router.Route {
+	var filtered []router.Route
+
+	for _, route := range routes {
+		if route.Metadata[health.MetadataKey] == string(health.StatusDown) {
+			continue
+		}
+		filtered = append(filtered, route)
+	}
+
+	return filtered
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/registry/memory"
+	"github.com/asim/go-micro/v3/router"
+	regRouter "github.com/asim/go-micro/v3/router/registry"
+)
+
+func TestLookupVersionConstraint(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	for _, v := range []string{"1.0.0", "2.3.0", "3.0.0"} {
+		if err := reg.Register(&registry.Service{
+			Name:    "foo",
+			Version: v,
+			Nodes: []*registry.Node{{
+				Id:       "foo-" + v,
+				Address:  "10.0.0.1:" + v[:1],
+				Metadata: map[string]string{"version": v},
+			}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := CallOptions{
+		Router:            regRouter.NewRouter(router.Registry(reg)),
+		VersionConstraint: ">=2.0.0 <3.0.0",
+	}
+
+	addrs, err := LookupRoute(context.TODO(), &testRequest{service: "foo"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "10.0.0.1:2" {
+		t.Fatalf("expected [10.0.0.1:2] got %v", addrs)
+	}
+
+	opts.VersionConstraint = ">=4.0.0"
+	if _, err := LookupRoute(context.TODO(), &testRequest{service: "foo"}, opts); err == nil {
+		t.Fatal("expected no matching nodes")
+	}
+}
+
+func TestLookupHealth(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	for addr, status := range map[string]string{"10.0.0.1:8080": "up", "10.0.0.2:8080": "down", "10.0.0.3:8080": ""} {
+		if err := reg.Register(&registry.Service{
+			Name: "foo",
+			Nodes: []*registry.Node{{
+				Id:       "foo-" + addr,
+				Address:  addr,
+				Metadata: map[string]string{"health": status},
+			}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := CallOptions{Router: regRouter.NewRouter(router.Registry(reg))}
+
+	addrs, err := LookupRoute(context.TODO(), &testRequest{service: "foo"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nodes without a status aren't excluded
+	sort.Strings(addrs)
+	if strings.Join(addrs, ",") != "10.0.0.1:8080,10.0.0.3:8080" {
+		t.Fatalf("expected the nodes which aren't down got %v", addrs)
+	}
+
+	routes := filterHealthy([]router.Route{{Address: "10.0.0.2:8080", Metadata: map[string]string{"health": "down"}}})
+	if len(routes) != 0 {
+		t.Fatalf("expected no healthy routes got %v", routes)
+	}
+}
+
+func TestLookupClusters(t *testing.T) {
+	newCluster := func(addrs ...string) router.Router {
+		reg := memory.NewRegistry()
+		for _, addr := range addrs {
+			if err := reg.Register(&registry.Service{
+				Name:  "foo",
+				Nodes: []*registry.Node{{Id: "foo-" + addr, Address: addr}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return regRouter.NewRouter(router.Registry(reg))
+	}
+
+	opts := CallOptions{
+		Router: newCluster(),
+		Clusters: []ClusterRouter{
+			{Name: "eu-west", Router: newCluster("10.0.0.1:8080")},
+			{Name: "us-east", Router: newCluster("10.0.1.1:8080")},
+		},
+	}
+
+	lookup := func(opts CallOptions) string {
+		addrs, _ := LookupRoute(context.TODO(), &testRequest{service: "foo"}, opts)
+		sort.Strings(addrs)
+		return strings.Join(addrs, ",")
+	}
+
+	// the local cluster has no nodes
+	if addrs := lookup(opts); addrs != "" {
+		t.Fatalf("expected no local routes got %s", addrs)
+	}
+
+	opts.Cluster = "us-east"
+	if addrs := lookup(opts); addrs != "10.0.1.1:8080" {
+		t.Fatalf("expected us-east routes got %s", addrs)
+	}
+
+	opts.Cluster = ""
+	opts.ClusterPolicy = Failover
+	if addrs := lookup(opts); addrs != "10.0.0.1:8080" {
+		t.Fatalf("expected failover to eu-west got %s", addrs)
+	}
+
+	opts.ClusterPolicy = PreferLocal
+	if addrs := lookup(opts); addrs != "10.0.0.1:8080,10.0.1.1:8080" {
+		t.Fatalf("expected failover to all clusters got %s", addrs)
+	}
+
+	opts.Cluster = "eu-west"
+	opts.ClusterPolicy = RoundRobinAcrossClusters
+	if addrs := lookup(opts); addrs != "10.0.0.1:8080,10.0.1.1:8080" {
+		t.Fatalf("expected routes across clusters got %s", addrs)
+	}
+
+	opts.ClusterPolicy = ""
+	opts.Cluster = "ap-south"
+	if _, err := LookupRoute(context.TODO(), &testRequest{service: "foo"}, opts); err == nil {
+		t.Fatal("expected unknown cluster error")
+	}
+}
+
+func TestLookupWeights(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	for addr, weight := range map[string]string{"10.0.0.1:8080": "30", "10.0.0.2:8080": "10", "10.0.0.3:8080": ""} {
+		if err := reg.Register(&registry.Service{
+			Name: "foo",
+			Nodes: []*registry.Node{{
+				Id:       "foo-" + addr,
+				Address:  addr,
+				Metadata: map[string]string{"weight": weight},
+			}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := CallOptions{Router: regRouter.NewRouter(router.Registry(reg))}
+
+	// nodes without a weight are left to the selector
+	weights := LookupWeights(&testRequest{service: "foo"}, opts)
+	if len(weights) != 2 || weights["10.0.0.1:8080"] != 30 || weights["10.0.0.2:8080"] != 10 {
+		t.Fatalf("unexpected weights %v", weights)
+	}
+
+	if md := LookupMetadata(&testRequest{service: "foo"}, opts, "weight"); len(md) != 2 || md["10.0.0.1:8080"] != "30" {
+		t.Fatalf("unexpected metadata %v", md)
+	}
+}
+
+func TestLookupFilters(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	nodes := map[string]map[string]string{
+		"10.0.0.1:8080": {"version": "v1"},
+		"10.0.0.2:8080": {"version": "v2"},
+		"10.0.0.3:8080": {"version": "v2", "canary": "true"},
+	}
+	for addr, md := range nodes {
+		if err := reg.Register(&registry.Service{
+			Name:    "foo",
+			Version: md["version"],
+			Nodes:   []*registry.Node{{Id: "foo-" + addr, Address: addr, Metadata: md}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lookup := func(opts ...CallOption) []string {
+		options := CallOptions{Router: regRouter.NewRouter(router.Registry(reg))}
+		for _, o := range opts {
+			o(&options)
+		}
+		addrs, _ := LookupRoute(context.TODO(), &testRequest{service: "foo"}, options)
+		sort.Strings(addrs)
+		return addrs
+	}
+
+	if addrs := lookup(WithFilterVersion("v2")); strings.Join(addrs, ",") != "10.0.0.2:8080,10.0.0.3:8080" {
+		t.Fatalf("expected the nodes of v2 got %v", addrs)
+	}
+	if addrs := lookup(WithFilterMetadata("canary", "true")); strings.Join(addrs, ",") != "10.0.0.3:8080" {
+		t.Fatalf("expected the canary got %v", addrs)
+	}
+	if addrs := lookup(WithFilterVersion("v1"), WithFilterMetadata("canary", "true")); len(addrs) != 0 {
+		t.Fatalf("expected no nodes got %v", addrs)
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+// Middleware is a call wrapper with an optional name. Named
+// middleware can be replaced and used to position other wrappers.
+type Middleware struct {
+	Name    string
+	Wrapper CallWrapper
+}
+
+// insert adds the middleware at position i, replacing any with the same name
+func insert(mw []Middleware, i int, m Middleware) []Middleware {
+	if len(m.Name) > 0 {
+		if j := index(mw, m.Name); j >= 0 {
+			mw[j] = m
+			return mw
+		}
+	}
+
+	if i < 0 || i > len(mw) {
+		i = len(mw)
+	}
+
+	mw = append(mw, Middleware{})
+	copy(mw[i+1:], mw[i:])
+	mw[i] = m
+
+	return mw
+}
+
+// index returns the position of the named middleware or -1
+func index(mw []Middleware, name string) int {
+	for i, m := range mw {
+		if m.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// use updates the middleware and the call wrappers executed in its order
+func use(o *Options, i int, m Middleware) {
+	o.Middleware = insert(o.Middleware, i, m)
+	o.CallOptions.CallWrappers = make([]CallWrapper, 0, len(o.Middleware))
+	for _, m := range o.Middleware {
+		o.CallOptions.CallWrappers = append(o.CallOptions.CallWrappers, m.Wrapper)
+	}
+}
+
+// Use appends the call wrappers to the chain. Wrappers are executed
+// in the order they're added so the first is the outermost. The chain
+// starts with the DeadlineMiddleware by default.
+func Use(w ...CallWrapper) Option {
+	return func(o *Options) {
+		for _, h := range w {
+			use(o, -1, Middleware{Wrapper: h})
+		}
+	}
+}
+
+// UseNamed appends a named call wrapper to the chain. A wrapper
+// previously added with the same name is replaced in place.
+func UseNamed(name string, w CallWrapper) Option {
+	return func(o *Options) {
+		use(o, -1, Middleware{Name: name, Wrapper: w})
+	}
+}
+
+// UseBefore inserts a named call wrapper so it's executed before the
+// wrapper named ref. The wrapper is appended if ref is not found.
+func UseBefore(ref, name string, w CallWrapper) Option {
+	return func(o *Options) {
+		use(o, index(o.Middleware, ref), Middleware{Name: name, Wrapper: w})
+	}
+}
+
+// UseAfter inserts a named call wrapper so it's executed after the
+// wrapper named ref. The wrapper is appended if ref is not found.
+func UseAfter(ref, name string, w CallWrapper) Option {
+	return func(o *Options) {
+		i := index(o.Middleware, ref)
+		if i >= 0 {
+			i++
+		}
+		use(o, i, Middleware{Name: name, Wrapper: w})
+	}
+}
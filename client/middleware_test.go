@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var order []string
+
+	wrap := func(name string) CallWrapper {
+		return func(cf CallFunc) CallFunc {
+			return func(ctx context.Context, addr string, req Request, rsp interface{}, opts CallOptions) error {
+				order = append(order, name)
+				return cf(ctx, addr, req, rsp, opts)
+			}
+		}
+	}
+
+	opts := NewOptions(
+		Use(wrap("a")),
+		UseNamed("b", wrap("b")),
+		UseBefore(DeadlineMiddleware, "c", wrap("c")),
+		UseAfter("b", "d", wrap("d")),
+		// replaces c in place
+		UseNamed("c", wrap("e")),
+		WrapCall(wrap("f")),
+	)
+
+	cf := func(ctx context.Context, addr string, req Request, rsp interface{}, opts CallOptions) error {
+		return nil
+	}
+
+	// wrap in reverse so the first is the outermost
+	wrappers := opts.CallOptions.CallWrappers
+	for i := len(wrappers); i > 0; i-- {
+		cf = wrappers[i-1](cf)
+	}
+
+	cf(context.Background(), "", newRequest("foo", "Foo.Bar", nil, "application/json"), nil, opts.CallOptions)
+
+	if got := strings.Join(order, ","); got != "e,a,b,d,f" {
+		t.Fatalf("unexpected wrapper order %s", got)
+	}
+
+	var names []string
+	for _, m := range opts.Middleware {
+		names = append(names, m.Name)
+	}
+
+	if got := strings.Join(names, ","); got != "c,deadline,,b,d," {
+		t.Fatalf("unexpected middleware %s", got)
+	}
+}
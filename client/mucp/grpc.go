@@ -0,0 +1,221 @@
+package mucp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/http2"
+)
+
+const (
+	// grpcScheme is the address scheme of external grpc services
+	grpcScheme = "grpc://"
+	// maxGRPCTransports is the max number of tls configs transports are
+	// kept for, the configs may be created per call
+	maxGRPCTransports = 16
+)
+
+// grpcCodes maps grpc status codes to error codes
+var grpcCodes = map[int]int32{
+	1:  499, // canceled
+	3:  400, // invalid argument
+	4:  408, // deadline exceeded
+	5:  404, // not found
+	6:  409, // already exists
+	7:  403, // permission denied
+	8:  429, // resource exhausted
+	9:  400, // failed precondition
+	12: 501, // unimplemented
+	14: 503, // unavailable
+	16: 401, // unauthenticated
+}
+
+// transport returns the http transport for the tls config. Without a
+// config grpc is spoken over cleartext http2. Connections are dialled with
+// the dial timeout of the client.
+func (r *rpcClient) transport(cfg *tls.Config) *http2.Transport {
+	r.Lock()
+	defer r.Unlock()
+
+	if t, ok := r.grpcTransports[cfg]; ok {
+		return t
+	}
+
+	// close the transport of another config to make room
+	if len(r.grpcTransports) >= maxGRPCTransports {
+		for c, t := range r.grpcTransports {
+			t.CloseIdleConnections()
+			delete(r.grpcTransports, c)
+			break
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: r.opts.CallOptions.DialTimeout}
+
+	t := &http2.Transport{TLSClientConfig: cfg}
+
+	if cfg != nil {
+		t.DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, network, addr, cfg)
+		}
+	} else {
+		t.AllowHTTP = true
+		t.DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return dialer.Dial(network, addr)
+		}
+	}
+
+	r.grpcTransports[cfg] = t
+
+	return t
+}
+
+// grpc makes a unary call to an external grpc service at a grpc:// address.
+// The endpoint is the full method name e.g pkg.Service.Method and the
+// request and response must be protobuf messages.
+func (r *rpcClient) grpc(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+	in, ok := req.Body().(proto.Message)
+	if !ok {
+		return errors.BadRequest("go.micro.client", "grpc request must be a proto message")
+	}
+	out, ok := rsp.(proto.Message)
+	if !ok {
+		return errors.BadRequest("go.micro.client", "grpc response must be a proto message")
+	}
+
+	endpoint := req.Endpoint()
+	i := strings.LastIndex(endpoint, ".")
+	if i <= 0 {
+		return errors.BadRequest("go.micro.client", "invalid grpc method %s", endpoint)
+	}
+
+	scheme := "http"
+	if opts.TLSConfig != nil {
+		scheme = "https"
+	}
+
+	u := url.URL{
+		Scheme: scheme,
+		Host:   strings.TrimPrefix(addr, grpcScheme),
+		Path:   "/" + endpoint[:i] + "/" + endpoint[i+1:],
+	}
+
+	b, err := proto.Marshal(in)
+	if err != nil {
+		return errors.InternalServerError("go.micro.client", "grpc marshal error: %v", err)
+	}
+
+	// length prefixed uncompressed message
+	body := make([]byte, 5+len(b))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(b)))
+	copy(body[5:], b)
+
+	hreq, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return errors.InternalServerError("go.micro.client", err.Error())
+	}
+	hreq = hreq.WithContext(ctx)
+
+	if md, ok := metadata.FromContext(ctx); ok {
+		for k, v := range md {
+			hreq.Header.Set(k, v)
+		}
+	}
+
+	hreq.Header.Set("Content-Type", "application/grpc+proto")
+	hreq.Header.Set("TE", "trailers")
+
+	if d, ok := ctx.Deadline(); ok {
+		timeout := time.Until(d)
+		if timeout <= 0 {
+			return errors.Timeout("go.micro.client", "grpc deadline exceeded")
+		}
+		// rounded up so it isn't sent as zero
+		ms := (timeout + time.Millisecond - 1) / time.Millisecond
+		hreq.Header.Set("Grpc-Timeout", fmt.Sprintf("%dm", ms))
+	}
+
+	hrsp, err := r.transport(opts.TLSConfig).RoundTrip(hreq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return errors.Timeout("go.micro.client", fmt.Sprintf("%v", ctx.Err()))
+		}
+		return errors.InternalServerError("go.micro.client", "connection error: %v", err)
+	}
+	defer hrsp.Body.Close()
+
+	if hrsp.StatusCode != http.StatusOK {
+		return errors.New("go.micro.client", hrsp.Status, int32(hrsp.StatusCode))
+	}
+
+	var rd io.Reader = hrsp.Body
+
+	// read past the limit to tell larger responses apart
+	max := r.opts.MaxRecvSize
+	if max > 0 {
+		rd = io.LimitReader(rd, int64(max)+6)
+	}
+
+	msg, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return errors.InternalServerError("go.micro.client", "grpc read error: %v", err)
+	}
+
+	if max > 0 && len(msg) > max+5 {
+		return errors.InternalServerError("go.micro.client", "grpc read error: %v", &codec.MessageSizeError{Size: len(msg) - 5, Limit: max})
+	}
+
+	// the status is in the trailers or the headers of a trailers only response
+	status := hrsp.Trailer.Get("Grpc-Status")
+	message := hrsp.Trailer.Get("Grpc-Message")
+	if len(status) == 0 {
+		status = hrsp.Header.Get("Grpc-Status")
+		message = hrsp.Header.Get("Grpc-Message")
+	}
+
+	if code, _ := strconv.Atoi(status); code != 0 {
+		c, ok := grpcCodes[code]
+		if !ok {
+			c = 500
+		}
+		if m, err := url.PathUnescape(message); err == nil {
+			message = m
+		}
+		return errors.New("go.micro.client", message, c)
+	}
+
+	if len(msg) < 5 {
+		return errors.InternalServerError("go.micro.client", "grpc read error: %v", io.ErrUnexpectedEOF)
+	}
+
+	if msg[0] != 0 {
+		return errors.InternalServerError("go.micro.client", "grpc compressed responses are not supported")
+	}
+
+	n := binary.BigEndian.Uint32(msg[1:5])
+	if int(n) > len(msg)-5 {
+		return errors.InternalServerError("go.micro.client", "grpc read error: %v", io.ErrUnexpectedEOF)
+	}
+
+	if err := proto.Unmarshal(msg[5:5+n], out); err != nil {
+		return errors.InternalServerError("go.micro.client", "grpc unmarshal error: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package mucp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+func TestCallGRPC(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/test.Greeter/Fail" {
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "not%20found")
+			return
+		}
+
+		if r.URL.Path != "/test.Greeter/Hello" || r.Header.Get("Content-Type") != "application/grpc+proto" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		var in wrappers.StringValue
+		if err := proto.Unmarshal(b[5:], &in); err != nil {
+			t.Error(err)
+		}
+
+		out, _ := proto.Marshal(&wrappers.StringValue{Value: "hello " + in.Value})
+		msg := make([]byte, 5+len(out))
+		binary.BigEndian.PutUint32(msg[1:], uint32(len(out)))
+		copy(msg[5:], out)
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(msg)
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	addr := "grpc://" + strings.TrimPrefix(srv.URL, "https://")
+	cfg := srv.Client().Transport.(*http.Transport).TLSClientConfig
+
+	c := NewClient()
+
+	req := c.NewRequest("", "test.Greeter.Hello", &wrappers.StringValue{Value: "john"})
+	rsp := &wrappers.StringValue{}
+
+	if err := c.Call(context.Background(), req, rsp, client.WithAddress(addr), client.WithTLS(cfg)); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.Value != "hello john" {
+		t.Fatalf("unexpected response %s", rsp.Value)
+	}
+
+	req = c.NewRequest("", "test.Greeter.Fail", &wrappers.StringValue{})
+
+	err := c.Call(context.Background(), req, rsp, client.WithAddress(addr), client.WithTLS(cfg), client.WithRetries(0))
+	if e := errors.FromError(err); e.Code != 404 || e.Detail != "not found" {
+		t.Fatalf("expected not found error got %v", err)
+	}
+
+	// the response exceeds the max size
+	c = NewClient(client.MaxRecvSize(8))
+
+	req = c.NewRequest("", "test.Greeter.Hello", &wrappers.StringValue{Value: "john"})
+
+	err = c.Call(context.Background(), req, rsp, client.WithAddress(addr), client.WithTLS(cfg), client.WithRetries(0))
+	if e := errors.FromError(err); e.Code != 500 || !strings.Contains(e.Detail, "exceeds the limit of 8 bytes") {
+		t.Fatalf("expected the response to exceed the max size got %v", err)
+	}
+}
+
+func TestGRPCDeadline(t *testing.T) {
+	var called bool
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	addr := "grpc://" + strings.TrimPrefix(srv.URL, "https://")
+	cfg := srv.Client().Transport.(*http.Transport).TLSClientConfig
+
+	r := NewClient().(*rpcClient)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	req := r.NewRequest("", "test.Greeter.Hello", &wrappers.StringValue{})
+
+	err := r.grpc(ctx, addr, req, &wrappers.StringValue{}, client.CallOptions{TLSConfig: cfg})
+	if e := errors.FromError(err); e.Code != 408 || called {
+		t.Fatalf("expected the call to time out before it's sent got %v", err)
+	}
+}
+
+func TestGRPCTransports(t *testing.T) {
+	r := NewClient().(*rpcClient)
+
+	// the transports are kept for a bounded number of configs
+	for i := 0; i < maxGRPCTransports*2; i++ {
+		r.transport(&tls.Config{})
+	}
+
+	if l := len(r.grpcTransports); l != maxGRPCTransports {
+		t.Fatalf("expected %d transports got %d", maxGRPCTransports, l)
+	}
+}
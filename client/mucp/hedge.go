@@ -0,0 +1,117 @@
+package mucp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/golang/protobuf/proto"
+)
+
+type hedgeFunc func(ctx context.Context, i int, node string, rsp interface{}) error
+
+// hedge sends the request to the next node each time the delay passes or
+// a request fails, up to the hedge attempts. The requests hedged after the
+// delay are sent to nodes not sent the request yet. The first response to
+// succeed is returned and the requests still in flight are cancelled.
+func hedge(ctx context.Context, opts client.CallOptions, response interface{}, routes []string, next selector.Next, call hedgeFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		rsp interface{}
+		err error
+	}
+
+	ch := make(chan result, opts.HedgeAttempts)
+	typ := reflect.TypeOf(response).Elem()
+
+	var (
+		sent  int
+		delay <-chan time.Time
+		used  = make(map[string]bool)
+	)
+
+	// pick returns a node not sent the request yet
+	pick := func() (string, bool) {
+		// the selector may return a node more than once
+		for i := 0; i < len(routes)*2; i++ {
+			if node := next(); !used[node] {
+				used[node] = true
+				return node, true
+			}
+		}
+		return "", false
+	}
+
+	send := func(node string) {
+		// each request decodes into its own response
+		rsp := reflect.New(typ).Interface()
+
+		go func() {
+			ch <- result{rsp, call(ctx, 0, node, rsp)}
+		}()
+
+		sent++
+		if sent < opts.HedgeAttempts {
+			delay = time.After(opts.HedgeDelay)
+		} else {
+			delay = nil
+		}
+	}
+
+	node := next()
+	used[node] = true
+	send(node)
+
+	var gerr error
+
+	for done := 0; done < sent; {
+		select {
+		case <-ctx.Done():
+			return errors.Timeout("go.micro.client", fmt.Sprintf("call timeout: %v", ctx.Err()))
+		case <-delay:
+			node, ok := pick()
+			if !ok {
+				// every node is already handling the request
+				delay = nil
+				continue
+			}
+			send(node)
+		case res := <-ch:
+			done++
+			if res.err == nil {
+				set(response, res.rsp)
+				return nil
+			}
+			gerr = res.err
+			// don't wait for the delay to send the next, the failed
+			// node is retried if there's no other
+			if sent < opts.HedgeAttempts {
+				node, ok := pick()
+				if !ok {
+					node = next()
+				}
+				send(node)
+			}
+		}
+	}
+
+	return gerr
+}
+
+// set copies the response decoded by the request into the response.
+// Proto messages are merged rather than copied so their internal state
+// isn't shared.
+func set(response, rsp interface{}) {
+	if m, ok := response.(proto.Message); ok {
+		m.Reset()
+		proto.Merge(m, rsp.(proto.Message))
+		return
+	}
+	reflect.ValueOf(response).Elem().Set(reflect.ValueOf(rsp).Elem())
+}
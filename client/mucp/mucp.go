@@ -3,7 +3,12 @@ package mucp
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -13,10 +18,14 @@ import (
 	raw "github.com/asim/go-micro/v3/codec/bytes"
 	"github.com/asim/go-micro/v3/errors"
 	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/selector"
 	"github.com/asim/go-micro/v3/transport"
+	"github.com/asim/go-micro/v3/util/backoff"
 	"github.com/asim/go-micro/v3/util/buf"
+	"github.com/asim/go-micro/v3/util/compress"
 	"github.com/asim/go-micro/v3/util/pool"
 	"github.com/google/uuid"
+	"golang.org/x/net/http2"
 )
 
 type rpcClient struct {
@@ -24,6 +33,10 @@ type rpcClient struct {
 	opts client.Options
 	pool pool.Pool
 	seq  uint64
+
+	sync.Mutex
+	// http2 transports for grpc:// addresses by tls config
+	grpcTransports map[*tls.Config]*http2.Transport
 }
 
 // NewClient returns a new micro client interface
@@ -33,6 +46,8 @@ func NewClient(opt ...client.Option) client.Client {
 	p := pool.NewPool(
 		pool.Size(opts.PoolSize),
 		pool.TTL(opts.PoolTTL),
+		pool.IdleTimeout(opts.PoolIdleTimeout),
+		pool.MaxConns(opts.PoolMaxConns),
 		pool.Transport(opts.Transport),
 	)
 
@@ -40,6 +55,8 @@ func NewClient(opt ...client.Option) client.Client {
 		opts: opts,
 		pool: p,
 		seq:  0,
+
+		grpcTransports: make(map[*tls.Config]*http2.Transport),
 	}
 	rc.once.Store(false)
 
@@ -63,7 +80,21 @@ func (r *rpcClient) newCodec(contentType string) (codec.NewCodec, error) {
 	return nil, fmt.Errorf("Unsupported Content-Type: %s", contentType)
 }
 
+// acceptCodec returns the codec of the responses accepted, nil if they have
+// the content type of the request
+func (r *rpcClient) acceptCodec(req client.Request, opts client.CallOptions) (codec.NewCodec, error) {
+	if len(opts.Accept) == 0 || opts.Accept == req.ContentType() {
+		return nil, nil
+	}
+	return r.newCodec(opts.Accept)
+}
+
 func (r *rpcClient) call(ctx context.Context, addr string, req client.Request, resp interface{}, opts client.CallOptions) error {
+	// external grpc services are called directly
+	if strings.HasPrefix(addr, grpcScheme) {
+		return r.grpc(ctx, addr, req, resp, opts)
+	}
+
 	msg := &transport.Message{
 		Header: make(map[string]string),
 	}
@@ -86,15 +117,26 @@ func (r *rpcClient) call(ctx context.Context, addr string, req client.Request, r
 	msg.Header["Content-Type"] = req.ContentType()
 	// set the accept header
 	msg.Header["Accept"] = req.ContentType()
+	if len(opts.Accept) > 0 {
+		msg.Header["Accept"] = opts.Accept
+	}
+	// set the compressions of the responses accepted
+	msg.Header[compress.AcceptHeader] = compress.Accept(opts.Compression)
 
 	cf, err := r.newCodec(req.ContentType())
 	if err != nil {
 		return errors.InternalServerError("go.micro.client", err.Error())
 	}
+	af, err := r.acceptCodec(req, opts)
+	if err != nil {
+		return errors.InternalServerError("go.micro.client", err.Error())
+	}
 
 	dOpts := []transport.DialOption{
 		transport.WithStream(),
+		transport.WithContext(ctx),
 	}
+	dOpts = append(dOpts, r.opts.DialOptions...)
 
 	if opts.DialTimeout >= 0 {
 		dOpts = append(dOpts, transport.WithTimeout(opts.DialTimeout))
@@ -106,7 +148,7 @@ func (r *rpcClient) call(ctx context.Context, addr string, req client.Request, r
 	}
 
 	seq := atomic.AddUint64(&r.seq, 1) - 1
-	codec := newRpcCodec(msg, c, cf, "")
+	codec := newRpcCodec(msg, c, cf, af, "", r.opts.MaxSendSize, r.opts.CodecMetrics, opts)
 
 	rsp := &rpcResponse{
 		socket: c,
@@ -193,15 +235,25 @@ func (r *rpcClient) stream(ctx context.Context, addr string, req client.Request,
 	msg.Header["Content-Type"] = req.ContentType()
 	// set the accept header
 	msg.Header["Accept"] = req.ContentType()
+	if len(opts.Accept) > 0 {
+		msg.Header["Accept"] = opts.Accept
+	}
+	// set the compressions of the responses accepted
+	msg.Header[compress.AcceptHeader] = compress.Accept(opts.Compression)
 
 	cf, err := r.newCodec(req.ContentType())
 	if err != nil {
 		return nil, errors.InternalServerError("go.micro.client", err.Error())
 	}
+	af, err := r.acceptCodec(req, opts)
+	if err != nil {
+		return nil, errors.InternalServerError("go.micro.client", err.Error())
+	}
 
 	dOpts := []transport.DialOption{
 		transport.WithStream(),
 	}
+	dOpts = append(dOpts, r.opts.DialOptions...)
 
 	if opts.DialTimeout >= 0 {
 		dOpts = append(dOpts, transport.WithTimeout(opts.DialTimeout))
@@ -217,7 +269,7 @@ func (r *rpcClient) stream(ctx context.Context, addr string, req client.Request,
 	id := fmt.Sprintf("%v", seq)
 
 	// create codec with stream id
-	codec := newRpcCodec(msg, c, cf, id)
+	codec := newRpcCodec(msg, c, cf, af, id, r.opts.MaxSendSize, r.opts.CodecMetrics, opts)
 
 	rsp := &rpcResponse{
 		socket: c,
@@ -277,6 +329,8 @@ func (r *rpcClient) stream(ctx context.Context, addr string, req client.Request,
 func (r *rpcClient) Init(opts ...client.Option) error {
 	size := r.opts.PoolSize
 	ttl := r.opts.PoolTTL
+	idle := r.opts.PoolIdleTimeout
+	max := r.opts.PoolMaxConns
 	tr := r.opts.Transport
 
 	for _, o := range opts {
@@ -284,13 +338,16 @@ func (r *rpcClient) Init(opts ...client.Option) error {
 	}
 
 	// update pool configuration if the options changed
-	if size != r.opts.PoolSize || ttl != r.opts.PoolTTL || tr != r.opts.Transport {
+	if size != r.opts.PoolSize || ttl != r.opts.PoolTTL || idle != r.opts.PoolIdleTimeout ||
+		max != r.opts.PoolMaxConns || tr != r.opts.Transport {
 		// close existing pool
 		r.pool.Close()
 		// create new pool
 		r.pool = pool.NewPool(
 			pool.Size(r.opts.PoolSize),
 			pool.TTL(r.opts.PoolTTL),
+			pool.IdleTimeout(r.opts.PoolIdleTimeout),
+			pool.MaxConns(r.opts.PoolMaxConns),
 			pool.Transport(r.opts.Transport),
 		)
 	}
@@ -298,6 +355,14 @@ func (r *rpcClient) Init(opts ...client.Option) error {
 	return nil
 }
 
+// PoolStats returns the utilisation of the connection pool
+func (r *rpcClient) PoolStats() pool.Stats {
+	if rp, ok := r.pool.(pool.Reporter); ok {
+		return rp.Stats()
+	}
+	return pool.Stats{}
+}
+
 func (r *rpcClient) Options() client.Options {
 	return r.opts
 }
@@ -309,6 +374,57 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 		opt(&callOpts)
 	}
 
+	for _, fn := range r.opts.Interceptors {
+		ctx = fn(ctx, request)
+	}
+
+	// duplicate a percentage of calls to the shadow target of the service
+	shadow, ok := r.opts.Shadows[request.Service()]
+	if p := callOpts.Shadow; p != nil {
+		shadow, ok = *p, true
+	}
+	if ok && len(shadow.Target) > 0 && rand.Float64()*100 < shadow.Percent {
+		return r.shadow(ctx, request, response, shadow.Target, callOpts, opts)
+	}
+
+	return r.cached(ctx, request, response, callOpts)
+}
+
+// cached serves the response from the cache for calls made WithCache
+func (r *rpcClient) cached(ctx context.Context, request client.Request, response interface{}, callOpts client.CallOptions) error {
+	c := r.opts.Cache
+	if c == nil || callOpts.CacheExpiry <= 0 {
+		return r.invoke(ctx, request, response, callOpts)
+	}
+
+	key, err := c.Key(request)
+	if err != nil {
+		return r.invoke(ctx, request, response, callOpts)
+	}
+
+	// serve the response from the cache
+	if !client.CacheBypass(ctx) && c.Get(key, response) {
+		return nil
+	}
+
+	if err := r.invoke(ctx, request, response, callOpts); err != nil {
+		return err
+	}
+
+	c.Set(key, response, callOpts.CacheExpiry)
+
+	return nil
+}
+
+// invoke looks up the routes and makes the call with retries
+func (r *rpcClient) invoke(ctx context.Context, request client.Request, response interface{}, callOpts client.CallOptions) error {
+	// limit the calls to the service
+	if l := r.opts.RateLimiter; l != nil {
+		if err := l.Allow(request.Service()); err != nil {
+			return err
+		}
+	}
+
 	// check if we already have a deadline
 	if d, ok := ctx.Deadline(); !ok {
 		// no deadline so we create a new one
@@ -360,13 +476,13 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 	}
 
 	// balance the list of nodes
-	next, err := callOpts.Selector.Select(routes)
+	next, err := callOpts.Selector.Select(routes, r.selectOptions(request, callOpts)...)
 	if err != nil {
 		return err
 	}
 
 	// return errors.New("go.micro.client", "request timeout", 408)
-	call := func(i int) error {
+	call := func(ctx context.Context, i int, node string, response interface{}) error {
 		// call backoff first. Someone may want an initial start delay
 		t, err := callOpts.Backoff(ctx, request, i)
 		if err != nil {
@@ -378,18 +494,58 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 			time.Sleep(t)
 		}
 
-		// get the next node
-		node := next()
+		// short circuit failing services and nodes
+		if b := r.opts.Breaker; b != nil {
+			if err := b.Allow(request.Service(), node); err != nil {
+				return err
+			}
+		}
+
+		// shed load once the service is at its concurrency limit
+		if l := r.opts.Concurrency; l != nil {
+			if err := l.Acquire(request.Service()); err != nil {
+				// the call wasn't made so a half open circuit can trial another
+				if b := r.opts.Breaker; b != nil {
+					b.Cancel(request.Service(), node)
+				}
+				return err
+			}
+		}
+
+		// the selectors balancing by the requests in flight track them
+		tr, track := callOpts.Selector.(selector.Tracker)
+		if track {
+			tr.Start(node)
+		}
+
+		start := time.Now()
 
 		// make the call
 		err = rcall(ctx, node, request, response, callOpts)
 
+		if track {
+			tr.Done(node, time.Since(start), err)
+		}
+
+		if l := r.opts.Concurrency; l != nil {
+			l.Release(request.Service(), time.Since(start), err)
+		}
+
 		// record the result of the call to inform future routing decisions
 		r.opts.Selector.Record(node, err)
 
+		if b := r.opts.Breaker; b != nil {
+			b.Record(err, request.Service(), node)
+		}
+
 		return err
 	}
 
+	// hedge the request across nodes rather than retrying
+	if callOpts.HedgeAttempts > 1 && response != nil && reflect.TypeOf(response).Kind() == reflect.Ptr {
+		return hedge(ctx, callOpts, response, routes, next, call)
+	}
+
 	// get the retries
 	retries := callOpts.Retries
 
@@ -401,9 +557,22 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 	ch := make(chan error, retries+1)
 	var gerr error
 
+	budget := r.opts.RetryBudget
+	if budget != nil {
+		budget.Request(request.Service())
+	}
+
 	for i := 0; i <= retries; i++ {
+		// stop retrying once the budget is spent
+		if i > 0 && budget != nil && !budget.Retry(request.Service()) {
+			return gerr
+		}
+
+		// get the next node
+		node := next()
+
 		go func(i int) {
-			ch <- call(i)
+			ch <- call(ctx, i, node, response)
 		}(i)
 
 		select {
@@ -438,6 +607,17 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 		opt(&callOpts)
 	}
 
+	for _, fn := range r.opts.Interceptors {
+		ctx = fn(ctx, request)
+	}
+
+	// limit the streams to the service
+	if l := r.opts.RateLimiter; l != nil {
+		if err := l.Allow(request.Service()); err != nil {
+			return nil, err
+		}
+	}
+
 	// should we noop right here?
 	select {
 	case <-ctx.Done():
@@ -460,6 +640,41 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 		callOpts.Address = []string{r.opts.Proxy}
 	}
 
+	// get the retries
+	retries := callOpts.Retries
+
+	// disable retries when using a proxy
+	if len(r.opts.Proxy) > 0 {
+		retries = 0
+	}
+
+	// re-establish the stream on transient errors, each attempt of the
+	// reconnect dials a node once
+	if p := callOpts.StreamReconnect; p != nil && p.Attempts > 0 {
+		policy := *p
+		if policy.Backoff == nil {
+			policy.Backoff = callOpts.Backoff
+		}
+		if policy.Max <= 0 {
+			policy.Max = client.DefaultMaxReconnects
+		}
+
+		stream, err := r.dialStream(ctx, request, callOpts, retries)
+		if err != nil {
+			return nil, err
+		}
+
+		return newReconnectStream(ctx, request, policy, stream, func(ctx context.Context) (client.Stream, error) {
+			return r.dialStream(ctx, request, callOpts, 0)
+		}), nil
+	}
+
+	return r.dialStream(ctx, request, callOpts, retries)
+}
+
+// dialStream looks up the routes of the request and dials a stream to the
+// selected node, retrying on the others up to the retries
+func (r *rpcClient) dialStream(ctx context.Context, request client.Request, callOpts client.CallOptions, retries int) (client.Stream, error) {
 	// lookup the route to send the reques to
 	// TODO apply any filtering here
 	routes, err := r.opts.Lookup(ctx, request, callOpts)
@@ -468,7 +683,7 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 	}
 
 	// balance the list of nodes
-	next, err := callOpts.Selector.Select(routes)
+	next, err := callOpts.Selector.Select(routes, r.selectOptions(request, callOpts)...)
 	if err != nil {
 		return nil, err
 	}
@@ -488,13 +703,61 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 		// get the next node
 		node := next()
 
+		// short circuit failing services and nodes
+		if b := r.opts.Breaker; b != nil {
+			if err := b.Allow(request.Service(), node); err != nil {
+				return nil, err
+			}
+		}
+
+		// shed load once the service is at its concurrency limit
+		l := r.opts.Concurrency
+		if l != nil {
+			if err := l.Acquire(request.Service()); err != nil {
+				// the stream wasn't dialled so a half open circuit can trial another
+				if b := r.opts.Breaker; b != nil {
+					b.Cancel(request.Service(), node)
+				}
+				return nil, err
+			}
+		}
+
+		// the selectors balancing by the requests in flight track them
+		tr, track := callOpts.Selector.(selector.Tracker)
+		if track {
+			tr.Start(node)
+		}
+
+		start := time.Now()
+
 		// perform the call
 		stream, err := r.stream(ctx, node, request, callOpts)
 
+		// the stream is in flight until it's closed, the latency is the time
+		// taken to open it so long lived streams don't skew the latencies
+		latency := time.Since(start)
+		done := func(err error) {
+			if track {
+				tr.Done(node, latency, err)
+			}
+			if l != nil {
+				l.Release(request.Service(), latency, err)
+			}
+		}
+
 		// record the result of the call to inform future routing decisions
 		r.opts.Selector.Record(node, err)
 
-		return stream, err
+		if b := r.opts.Breaker; b != nil {
+			b.Record(err, request.Service(), node)
+		}
+
+		if err != nil {
+			done(err)
+			return nil, err
+		}
+
+		return &trackedStream{Stream: stream, done: done}, nil
 	}
 
 	type response struct {
@@ -502,14 +765,6 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 		err    error
 	}
 
-	// get the retries
-	retries := callOpts.Retries
-
-	// disable retries when using a proxy
-	if len(r.opts.Proxy) > 0 {
-		retries = 0
-	}
-
 	ch := make(chan response, retries+1)
 	var grr error
 
@@ -546,7 +801,8 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 
 func (r *rpcClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
 	options := client.PublishOptions{
-		Context: context.Background(),
+		Context:    context.Background(),
+		AckRetries: client.DefaultRetries,
 	}
 	for _, o := range opts {
 		o(&options)
@@ -607,10 +863,44 @@ func (r *rpcClient) Publish(ctx context.Context, msg client.Message, opts ...cli
 		r.once.Store(true)
 	}
 
-	return r.opts.Broker.Publish(topic, &broker.Message{
+	bmsg := &broker.Message{
 		Header: md,
 		Body:   body,
-	}, broker.PublishContext(options.Context))
+	}
+
+	bopts := []broker.PublishOption{broker.PublishContext(options.Context)}
+	if len(options.Key) > 0 {
+		bopts = append(bopts, broker.WithKey(options.Key))
+	}
+
+	if !options.Ack {
+		return r.opts.Broker.Publish(topic, bmsg, bopts...)
+	}
+
+	bopts = append(bopts, broker.Ack())
+
+	err = r.opts.Broker.Publish(topic, bmsg, bopts...)
+
+	// retry rejected messages
+	for i := 0; ; i++ {
+		nerr, ok := err.(*broker.NackError)
+		if !ok || i >= options.AckRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff.Do(i + 1)):
+		}
+
+		// only the subscribers which rejected it get it again
+		if nerr.Retry != nil {
+			err = nerr.Retry()
+		} else {
+			err = r.opts.Broker.Publish(topic, bmsg, bopts...)
+		}
+	}
 }
 
 func (r *rpcClient) NewMessage(topic string, message interface{}, opts ...client.MessageOption) client.Message {
@@ -624,3 +914,21 @@ func (r *rpcClient) NewRequest(service, method string, request interface{}, reqO
 func (r *rpcClient) String() string {
 	return "mucp"
 }
+
+// selectOptions returns the options of the selection of the routes, those of
+// the call, the weights of the routes for the selectors balancing by weight
+// and their zones for the selectors preferring the zone of the caller
+func (r *rpcClient) selectOptions(req client.Request, opts client.CallOptions) []selector.SelectOption {
+	sopts := append([]selector.SelectOption{}, opts.SelectOptions...)
+	if len(r.opts.Proxy) > 0 {
+		return sopts
+	}
+
+	if _, ok := opts.Selector.(selector.Weighted); ok {
+		sopts = append(sopts, selector.Weights(client.LookupWeights(req, opts)))
+	}
+	if l, ok := opts.Selector.(selector.Localized); ok && len(l.Locality()) > 0 {
+		sopts = append(sopts, selector.Localities(client.LookupMetadata(req, opts, l.Locality())))
+	}
+	return sopts
+}
@@ -3,17 +3,23 @@ package mucp
 import (
 	"bytes"
 	errs "errors"
+	"time"
 
+	"github.com/asim/go-micro/v3/client"
 	"github.com/asim/go-micro/v3/codec"
 	raw "github.com/asim/go-micro/v3/codec/bytes"
+	"github.com/asim/go-micro/v3/codec/cbor"
+	"github.com/asim/go-micro/v3/codec/flatbuffers"
 	"github.com/asim/go-micro/v3/codec/grpc"
 	"github.com/asim/go-micro/v3/codec/json"
 	"github.com/asim/go-micro/v3/codec/jsonrpc"
+	"github.com/asim/go-micro/v3/codec/msgpack"
 	"github.com/asim/go-micro/v3/codec/proto"
 	"github.com/asim/go-micro/v3/codec/protorpc"
 	"github.com/asim/go-micro/v3/errors"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/transport"
+	"github.com/asim/go-micro/v3/util/compress"
 )
 
 const (
@@ -36,12 +42,26 @@ var (
 type rpcCodec struct {
 	client transport.Client
 	codec  codec.Codec
+	// accept is the codec of the responses accepted, nil if it's the
+	// codec, and read is the codec of the message read
+	accept codec.Codec
+	read   codec.Codec
 
 	req *transport.Message
 	buf *readWriteCloser
 
 	// signify if its a stream
 	stream string
+	// max size of the messages written
+	maxSize int
+	// compression of the messages written of at least min bytes
+	compression string
+	minCompress int
+	// records the sizes and latency of the bodies of the endpoint, the
+	// size of the message read
+	metrics  *codec.Metrics
+	endpoint string
+	size     int
 }
 
 type readWriteCloser struct {
@@ -53,12 +73,15 @@ var (
 	DefaultContentType = "application/protobuf"
 
 	DefaultCodecs = map[string]codec.NewCodec{
+		"application/cbor":         cbor.NewCodec,
 		"application/grpc":         grpc.NewCodec,
 		"application/grpc+json":    grpc.NewCodec,
 		"application/grpc+proto":   grpc.NewCodec,
 		"application/protobuf":     proto.NewCodec,
 		"application/json":         json.NewCodec,
 		"application/json-rpc":     jsonrpc.NewCodec,
+		"application/msgpack":      msgpack.NewCodec,
+		"application/flatbuffers":  flatbuffers.NewCodec,
 		"application/proto-rpc":    protorpc.NewCodec,
 		"application/octet-stream": raw.NewCodec,
 	}
@@ -151,17 +174,26 @@ func setupProtocol(msg *transport.Message, node *registry.Node) codec.NewCodec {
 	return defaultCodecs[msg.Header["Content-Type"]]
 }
 
-func newRpcCodec(req *transport.Message, client transport.Client, c codec.NewCodec, stream string) codec.Codec {
+func newRpcCodec(req *transport.Message, client transport.Client, c, ac codec.NewCodec, stream string, maxSize int, m *codec.Metrics, opts client.CallOptions) codec.Codec {
 	rwc := &readWriteCloser{
 		wbuf: bytes.NewBuffer(nil),
 		rbuf: bytes.NewBuffer(nil),
 	}
 	r := &rpcCodec{
-		buf:    rwc,
-		client: client,
-		codec:  c(rwc),
-		req:    req,
-		stream: stream,
+		buf:     rwc,
+		client:  client,
+		codec:   c(rwc),
+		req:     req,
+		stream:  stream,
+		maxSize: maxSize,
+		metrics: m,
+
+		compression: opts.Compression,
+		minCompress: opts.CompressThreshold,
+	}
+	r.read = r.codec
+	if ac != nil {
+		r.accept = ac(rwc)
 	}
 	return r
 }
@@ -181,6 +213,7 @@ func (c *rpcCodec) Write(m *codec.Message, body interface{}) error {
 
 	// set the mucp headers
 	setHeaders(m, c.stream)
+	c.endpoint = m.Endpoint
 
 	// if body is bytes Frame don't encode
 	if body != nil {
@@ -189,20 +222,32 @@ func (c *rpcCodec) Write(m *codec.Message, body interface{}) error {
 			m.Body = b.Data
 		} else {
 			// write to codec
+			start := time.Now()
 			if err := c.codec.Write(m, body); err != nil {
 				return errors.InternalServerError("go.micro.client.codec", err.Error())
 			}
 			// set body
 			m.Body = c.buf.wbuf.Bytes()
+			if c.metrics != nil {
+				c.metrics.Marshaled(c.endpoint, len(m.Body), time.Since(start))
+			}
 		}
 	}
 
+	if c.maxSize > 0 && len(m.Body) > c.maxSize {
+		return &codec.MessageSizeError{Size: len(m.Body), Limit: c.maxSize}
+	}
+
 	// create new transport message
 	msg := transport.Message{
 		Header: m.Header,
 		Body:   m.Body,
 	}
 
+	if err := compress.Encode(&msg, c.compression, c.minCompress); err != nil {
+		return errors.InternalServerError("go.micro.client.compress", err.Error())
+	}
+
 	// send the request
 	if err := c.client.Send(&msg); err != nil {
 		return errors.InternalServerError("go.micro.client.transport", err.Error())
@@ -219,14 +264,27 @@ func (c *rpcCodec) ReadHeader(m *codec.Message, r codec.MessageType) error {
 		return errors.InternalServerError("go.micro.client.transport", err.Error())
 	}
 
+	// the body is decompressed up to the default limit
+	if err := compress.Decode(&tm, 0); err != nil {
+		return errors.InternalServerError("go.micro.client.compress", err.Error())
+	}
+
 	c.buf.rbuf.Reset()
 	c.buf.rbuf.Write(tm.Body)
+	c.size = len(tm.Body)
 
 	// set headers from transport
 	m.Header = tm.Header
 
+	// the servers without a codec for the content type accepted respond
+	// with the content type of the request
+	c.read = c.codec
+	if c.accept != nil && tm.Header["Content-Type"] == c.req.Header["Accept"] {
+		c.read = c.accept
+	}
+
 	// read header
-	err := c.codec.ReadHeader(m, r)
+	err := c.read.ReadHeader(m, r)
 
 	// get headers
 	getHeaders(m)
@@ -247,15 +305,22 @@ func (c *rpcCodec) ReadBody(b interface{}) error {
 		return nil
 	}
 
-	if err := c.codec.ReadBody(b); err != nil {
+	start := time.Now()
+	if err := c.read.ReadBody(b); err != nil {
 		return errors.InternalServerError("go.micro.client.codec", err.Error())
 	}
+	if c.metrics != nil && b != nil {
+		c.metrics.Unmarshaled(c.endpoint, c.size, time.Since(start))
+	}
 	return nil
 }
 
 func (c *rpcCodec) Close() error {
 	c.buf.Close()
 	c.codec.Close()
+	if c.accept != nil {
+		c.accept.Close()
+	}
 	if err := c.client.Close(); err != nil {
 		return errors.InternalServerError("go.micro.client.transport", err.Error())
 	}
@@ -27,6 +27,27 @@ type rpcStream struct {
 	release func(err error)
 }
 
+// trackedStream is a stream in flight until it's closed
+type trackedStream struct {
+	client.Stream
+	once sync.Once
+	done func(err error)
+}
+
+func (t *trackedStream) Close() error {
+	err := t.Stream.Close()
+
+	t.once.Do(func() {
+		serr := t.Stream.Error()
+		if serr == io.EOF {
+			serr = nil
+		}
+		t.done(serr)
+	})
+
+	return err
+}
+
 func (r *rpcStream) isClosed() bool {
 	select {
 	case <-r.closed:
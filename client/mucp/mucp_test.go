@@ -3,14 +3,26 @@ package mucp
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/asim/go-micro/v3/broker"
+	bmemory "github.com/asim/go-micro/v3/broker/memory"
 	"github.com/asim/go-micro/v3/client"
+	raw "github.com/asim/go-micro/v3/codec/bytes"
 	"github.com/asim/go-micro/v3/errors"
+	pb "github.com/asim/go-micro/v3/errors/proto"
+	"github.com/asim/go-micro/v3/metadata"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/registry/memory"
 	"github.com/asim/go-micro/v3/router"
 	regRouter "github.com/asim/go-micro/v3/router/registry"
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/asim/go-micro/v3/selector/roundrobin"
+	"github.com/asim/go-micro/v3/transport"
+	tmem "github.com/asim/go-micro/v3/transport/memory"
 )
 
 func newTestRouter() router.Router {
@@ -157,3 +169,749 @@ func TestCallWrapper(t *testing.T) {
 		t.Fatal("wrapper not called")
 	}
 }
+
+func TestCallHedging(t *testing.T) {
+	slow := "10.1.10.1:8080"
+	fast := "10.1.10.2:8080"
+
+	cancelled := make(chan bool, 1)
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			if node == slow {
+				<-ctx.Done()
+				cancelled <- true
+				return ctx.Err()
+			}
+			*(rsp.(*string)) = node
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+
+	var rsp string
+	if err := c.Call(context.Background(), req, &rsp,
+		client.WithAddress(slow, fast),
+		client.WithHedging(time.Millisecond*10, 2),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp != fast {
+		t.Fatalf("expected response from %s got %s", fast, rsp)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the slow request to be cancelled")
+	}
+}
+
+// repeatSelector returns the first route twice before the others
+type repeatSelector struct {
+	selector.Selector
+}
+
+func (r *repeatSelector) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	var i int
+	return func() string {
+		i++
+		if i <= 2 {
+			return routes[0]
+		}
+		return routes[(i-2)%len(routes)]
+	}, nil
+}
+
+func TestCallHedgingNodes(t *testing.T) {
+	slow := "10.1.10.1:8080"
+	fast := "10.1.10.2:8080"
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			if node == slow {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			rsp.(*pb.Error).Id = node
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Selector(&repeatSelector{roundrobin.NewSelector()}),
+		client.WrapCall(wrap),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+
+	// the hedged request skips the node sent the first
+	rsp := new(pb.Error)
+	if err := c.Call(context.Background(), req, rsp,
+		client.WithAddress(slow, fast),
+		client.WithHedging(time.Millisecond*10, 2),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.Id != fast {
+		t.Fatalf("expected response from %s got %s", fast, rsp.Id)
+	}
+}
+
+func TestCallCache(t *testing.T) {
+	var calls int
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			calls++
+			*(rsp.(*string)) = fmt.Sprintf("response %d", calls)
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", map[string]string{"foo": "bar"})
+
+	call := func(ctx context.Context) string {
+		var rsp string
+		if err := c.Call(ctx, req, &rsp,
+			client.WithAddress("10.1.10.1:8080"),
+			client.WithCache(time.Minute),
+		); err != nil {
+			t.Fatal(err)
+		}
+		return rsp
+	}
+
+	if rsp := call(context.Background()); rsp != "response 1" {
+		t.Fatalf("unexpected response %s", rsp)
+	}
+
+	// served from the cache
+	if rsp := call(context.Background()); rsp != "response 1" || calls != 1 {
+		t.Fatalf("expected cached response got %s after %d calls", rsp, calls)
+	}
+
+	// bypass and refresh the cache
+	ctx := metadata.Set(context.Background(), client.CacheControlKey, "no-cache")
+	if rsp := call(ctx); rsp != "response 2" {
+		t.Fatalf("expected cache bypass got %s", rsp)
+	}
+
+	if rsp := call(context.Background()); rsp != "response 2" {
+		t.Fatalf("expected refreshed response got %s", rsp)
+	}
+
+	c.Options().Cache.Invalidate("test.service", "")
+
+	if rsp := call(context.Background()); rsp != "response 3" {
+		t.Fatalf("expected invalidated response got %s", rsp)
+	}
+}
+
+type testStream struct {
+	client.Stream
+	msgs []string
+}
+
+func (s *testStream) Recv(msg interface{}) error {
+	if len(s.msgs) == 0 {
+		return fmt.Errorf("connection reset")
+	}
+	*(msg.(*string)) = s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return nil
+}
+
+func (s *testStream) Close() error {
+	return nil
+}
+
+func TestReconnectStream(t *testing.T) {
+	var resumed []string
+
+	dial := func(ctx context.Context) (client.Stream, error) {
+		seq, _ := metadata.Get(ctx, client.StreamResumeKey)
+		resumed = append(resumed, seq)
+
+		switch seq {
+		case "":
+			return &testStream{msgs: []string{"a", "b"}}, nil
+		case "2":
+			return &testStream{msgs: []string{"c"}}, nil
+		}
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	req := newRequest("test.service", "Test.Endpoint", nil, "application/json")
+
+	first, _ := dial(context.Background())
+	stream := newReconnectStream(context.Background(), req, client.ReconnectPolicy{Attempts: 2}, first, dial)
+
+	var got []string
+	for {
+		var msg string
+		if err := stream.Recv(&msg); err != nil {
+			break
+		}
+		got = append(got, msg)
+	}
+
+	if fmt.Sprint(got) != "[a b c]" {
+		t.Fatalf("expected a single logical stream got %v", got)
+	}
+
+	// resumed after 2 messages then failed to resume after 3
+	if fmt.Sprint(resumed) != "[ 2 3 3]" {
+		t.Fatalf("unexpected resume sequence %q", resumed)
+	}
+
+	if err := stream.Error(); err == nil || err.Error() != "connection refused" {
+		t.Fatalf("expected the reconnect error got %v", err)
+	}
+}
+
+func TestReconnectStreamMax(t *testing.T) {
+	var dials int
+
+	// every stream breaks after a message
+	dial := func(ctx context.Context) (client.Stream, error) {
+		dials++
+		return &testStream{msgs: []string{"a"}}, nil
+	}
+
+	req := newRequest("test.service", "Test.Endpoint", nil, "application/json")
+
+	first, _ := dial(context.Background())
+	stream := newReconnectStream(context.Background(), req, client.ReconnectPolicy{Attempts: 2, Max: 3}, first, dial)
+
+	var got int
+	for {
+		var msg string
+		if err := stream.Recv(&msg); err != nil {
+			break
+		}
+		got++
+	}
+
+	// the first stream and 3 reconnects
+	if dials != 4 || got != 4 {
+		t.Fatalf("expected the reconnects to stop at the max got %d dials %d messages", dials, got)
+	}
+
+	if err := stream.Error(); err == nil || err.Error() != "connection reset" {
+		t.Fatalf("expected the stream error got %v", err)
+	}
+}
+
+func TestCallShadow(t *testing.T) {
+	shadowed := make(chan string, 1)
+	release := make(chan bool)
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			if req.Service() == "foo" {
+				<-release
+				*(rsp.(*string)) = "changed"
+				shadowed <- req.Endpoint() + " " + strings.TrimSpace(string(req.Body().(*raw.Frame).Data))
+				return nil
+			}
+			*(rsp.(*string)) = "original"
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+		client.ContentType("application/json"),
+		client.Shadow("test.service", "foo", 100),
+	)
+
+	// the calls to other services aren't shadowed
+	var rsp string
+	if err := c.Call(context.Background(), c.NewRequest("other.service", "Test.Endpoint", nil), &rsp, client.WithAddress("10.1.10.1:8080")); err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]string{"name": "a"}
+	req := c.NewRequest("test.service", "Test.Endpoint", body)
+
+	if err := c.Call(context.Background(), req, &rsp, client.WithAddress("10.1.10.1:8080")); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp != "original" {
+		t.Fatalf("expected the original response got %s", rsp)
+	}
+
+	// the caller reuses the request and response before the shadow call
+	body["name"] = "b"
+	rsp = "reused"
+	close(release)
+
+	select {
+	case call := <-shadowed:
+		if call != `Test.Endpoint {"name":"a"}` {
+			t.Fatalf("expected the shadow call to Test.Endpoint with the original body got %s", call)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the call to be shadowed")
+	}
+
+	var stats []*client.ShadowStat
+	for i := 0; i < 100; i++ {
+		if stats = c.Options().ShadowStats.Stats(); len(stats) > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if len(stats) != 1 || stats[0].Target != "foo" || stats[0].Calls != 1 || stats[0].Mismatches != 1 {
+		t.Fatalf("unexpected shadow stats %+v", stats)
+	}
+}
+
+func TestPublishSync(t *testing.T) {
+	b := bmemory.NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var attempts, delivered int
+	if _, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		attempts++
+		if attempts == 1 {
+			return fmt.Errorf("busy")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Subscribe("test.topic", func(m *broker.Message) error {
+		delivered++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(client.Broker(b))
+	msg := c.NewMessage("test.topic", map[string]string{"foo": "bar"}, client.WithMessageContentType("application/json"))
+
+	// rejected messages are retried
+	if err := client.PublishSync(context.Background(), c, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscriber which handled the message doesn't get it again
+	if attempts != 2 || delivered != 1 {
+		t.Fatalf("expected 2 attempts and 1 delivery got %d and %d", attempts, delivered)
+	}
+
+	// the nack reason is returned once the retries are exhausted
+	attempts = 0
+	err := c.Publish(context.Background(), msg, client.WaitForAck(), client.WithAckRetries(0))
+	if nerr, ok := err.(*broker.NackError); !ok || nerr.Reason != "busy" {
+		t.Fatalf("expected nack error got %v", err)
+	}
+
+	// fire and forget ignores the rejection
+	attempts = 0
+	if err := c.Publish(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type keyBroker struct {
+	broker.Broker
+	key string
+}
+
+func (b *keyBroker) Publish(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	b.key = options.Key
+	return b.Broker.Publish(topic, m, opts...)
+}
+
+func TestPublishKey(t *testing.T) {
+	b := &keyBroker{Broker: bmemory.NewBroker()}
+
+	c := NewClient(client.Broker(b))
+	msg := c.NewMessage("test.topic", map[string]string{"foo": "bar"}, client.WithMessageContentType("application/json"))
+
+	if err := c.Publish(context.Background(), msg, client.WithKey("customer-123")); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.key != "customer-123" {
+		t.Fatalf("expected the key to be passed to the broker got %q", b.key)
+	}
+}
+
+func TestCallInterceptor(t *testing.T) {
+	var tenant string
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			tenant, _ = metadata.Get(ctx, "Tenant")
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+		client.CallInterceptor(func(ctx context.Context, req client.Request) context.Context {
+			return metadata.Set(ctx, "Tenant", req.Service()+"-tenant")
+		}),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+	if err := c.Call(context.Background(), req, nil, client.WithAddress("10.1.10.1:8080")); err != nil {
+		t.Fatal(err)
+	}
+
+	if tenant != "test.service-tenant" {
+		t.Fatalf("expected the interceptor metadata got %q", tenant)
+	}
+}
+
+func TestCallOverloaded(t *testing.T) {
+	block := make(chan bool)
+	started := make(chan bool)
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			started <- true
+			<-block
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+		client.AdaptiveConcurrency(1, 1),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Call(context.Background(), req, nil, client.WithAddress("10.1.10.1:8080"))
+	}()
+	<-started
+
+	// the second call is rejected early rather than queued
+	if err := c.Call(context.Background(), req, nil, client.WithAddress("10.1.10.1:8080")); err != client.ErrOverloaded {
+		t.Fatalf("expected ErrOverloaded got %v", err)
+	}
+
+	close(block)
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	stats := c.Options().Concurrency.Stats()
+	if len(stats) != 1 || stats[0].InFlight != 0 || stats[0].Rejected != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCallOverloadedBreaker(t *testing.T) {
+	var mtx sync.Mutex
+	fail := true
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			if fail {
+				return errors.InternalServerError("test.service", "failed")
+			}
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+		client.CircuitBreaker(1, time.Minute, time.Millisecond*10),
+		client.AdaptiveConcurrency(1, 1),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+	call := func() error {
+		return c.Call(context.Background(), req, nil, client.WithAddress("10.1.10.1:8080"), client.WithRetries(0))
+	}
+
+	// open the circuit and wait for the cooldown
+	if err := call(); err == nil {
+		t.Fatal("expected the call to fail")
+	}
+	time.Sleep(time.Millisecond * 20)
+
+	mtx.Lock()
+	fail = false
+	mtx.Unlock()
+
+	// the trial call is shed as the service is at its limit
+	l := c.Options().Concurrency
+	if err := l.Acquire("test.service"); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(); err != client.ErrOverloaded {
+		t.Fatalf("expected ErrOverloaded got %v", err)
+	}
+	l.Release("test.service", 0, nil)
+
+	// another trial is allowed which closes the circuit
+	if err := call(); err != nil {
+		t.Fatalf("expected the trial call to succeed got %v", err)
+	}
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamBreaker(t *testing.T) {
+	// nothing listens on the memory transport so the streams fail to dial
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Transport(tmem.NewTransport()),
+		client.CircuitBreaker(1, time.Minute, time.Minute),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+	stream := func() error {
+		_, err := c.Stream(context.Background(), req, client.WithAddress("10.1.10.1:8080"), client.WithRetries(0))
+		return err
+	}
+
+	if err := stream(); err == nil || err == client.ErrCircuitOpen {
+		t.Fatalf("expected the connection error got %v", err)
+	}
+
+	// the failure opened the circuit
+	if err := stream(); err != client.ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen got %v", err)
+	}
+}
+
+type testTracker struct {
+	selector.Selector
+
+	sync.Mutex
+	pending int
+	latency time.Duration
+	err     error
+}
+
+func (t *testTracker) Start(route string) {
+	t.Lock()
+	t.pending++
+	t.Unlock()
+}
+
+func (t *testTracker) Done(route string, latency time.Duration, err error) {
+	t.Lock()
+	t.pending--
+	t.latency = latency
+	t.err = err
+	t.Unlock()
+}
+
+func TestCallTracker(t *testing.T) {
+	tr := &testTracker{Selector: roundrobin.NewSelector()}
+	cerr := errors.InternalServerError("test.service", "failed")
+
+	var pending int
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			tr.Lock()
+			pending = tr.pending
+			tr.Unlock()
+			time.Sleep(time.Millisecond * 10)
+			return cerr
+		}
+	}
+
+	// the selector tracks the calls without wrapping the client
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Selector(tr),
+		client.WrapCall(wrap),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+	if err := c.Call(context.Background(), req, nil, client.WithAddress("10.1.10.1:8080"), client.WithRetries(0)); err == nil {
+		t.Fatal("expected the error of the call")
+	}
+
+	if pending != 1 || tr.pending != 0 {
+		t.Fatalf("expected the call in flight until done got %d then %d", pending, tr.pending)
+	}
+	if tr.latency < time.Millisecond*10 || tr.err == nil {
+		t.Fatalf("expected the latency and error of the call got %v %v", tr.latency, tr.err)
+	}
+}
+
+func TestStreamTracker(t *testing.T) {
+	tr := tmem.NewTransport()
+
+	l, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// a server reading the messages of the stream
+	go l.Accept(func(sock transport.Socket) {
+		var m transport.Message
+		for sock.Recv(&m) == nil {
+		}
+	})
+
+	ts := &testTracker{Selector: roundrobin.NewSelector()}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Transport(tr),
+		client.Selector(ts),
+		client.AdaptiveConcurrency(1, 1),
+	)
+
+	req := c.NewRequest("test.service", "Test.Endpoint", nil)
+	stream, err := c.Stream(context.Background(), req, client.WithAddress(l.Addr()), client.WithRetries(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream is in flight until it's closed
+	if _, err := c.Stream(context.Background(), req, client.WithAddress(l.Addr()), client.WithRetries(0)); err != client.ErrOverloaded {
+		t.Fatalf("expected ErrOverloaded got %v", err)
+	}
+	ts.Lock()
+	pending := ts.pending
+	ts.Unlock()
+	if pending != 1 {
+		t.Fatalf("expected the stream to be tracked got %d pending", pending)
+	}
+
+	stream.Close()
+
+	if stats := c.Options().Concurrency.Stats(); len(stats) != 1 || stats[0].InFlight != 0 {
+		t.Fatalf("expected the stream to be released got %+v", stats)
+	}
+	ts.Lock()
+	pending = ts.pending
+	ts.Unlock()
+	if pending != 0 {
+		t.Fatalf("expected the stream to be done got %d pending", pending)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var inflight, peak int32
+	var mtx sync.Mutex
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			mtx.Lock()
+			inflight++
+			if inflight > peak {
+				peak = inflight
+			}
+			mtx.Unlock()
+
+			defer func() {
+				mtx.Lock()
+				inflight--
+				mtx.Unlock()
+			}()
+
+			time.Sleep(time.Millisecond * 10)
+
+			if req.Endpoint() == "Test.Fail" {
+				return errors.InternalServerError("test.service", "failed")
+			}
+
+			*rsp.(*string) = req.Endpoint()
+
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+		client.Retries(0),
+	)
+
+	var reqs []*client.BatchRequest
+	for i := 0; i < 6; i++ {
+		endpoint := "Test.Endpoint"
+		if i == 3 {
+			endpoint = "Test.Fail"
+		}
+		reqs = append(reqs, &client.BatchRequest{
+			Request:  c.NewRequest("test.service", endpoint, nil),
+			Response: new(string),
+			Options:  []client.CallOption{client.WithAddress("10.1.10.1:8080")},
+		})
+	}
+
+	rsps := client.Batch(context.Background(), c, reqs, client.BatchParallelism(2))
+
+	if len(rsps) != 6 {
+		t.Fatalf("expected 6 responses got %d", len(rsps))
+	}
+
+	// a failed call doesn't fail the batch
+	for i, rsp := range rsps {
+		if i == 3 {
+			if rsp.Error == nil {
+				t.Fatal("expected the failed call to return an error")
+			}
+			continue
+		}
+		if rsp.Error != nil {
+			t.Fatal(rsp.Error)
+		}
+		if v := *rsp.Response.(*string); v != "Test.Endpoint" {
+			t.Fatalf("expected Test.Endpoint got %s", v)
+		}
+	}
+
+	if peak > 2 {
+		t.Fatalf("expected at most 2 calls in flight got %d", peak)
+	}
+
+	// calls not made before the shared deadline time out
+	rsps = client.Batch(context.Background(), c, reqs, client.BatchParallelism(1), client.BatchTimeout(time.Millisecond*15))
+
+	if rsps[0].Error != nil {
+		t.Fatal(rsps[0].Error)
+	}
+	if err := errors.FromError(rsps[5].Error); err.Code != 408 {
+		t.Fatalf("expected a timeout got %v", rsps[5].Error)
+	}
+}
@@ -0,0 +1,199 @@
+package mucp
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/metadata"
+)
+
+type dialFunc func(ctx context.Context) (client.Stream, error)
+
+// reconnectStream is a single logical stream which re-dials the
+// underlying stream when it fails with a transient error, up to the max
+// reconnects of the policy over its life
+type reconnectStream struct {
+	ctx     context.Context
+	request client.Request
+	policy  client.ReconnectPolicy
+	dial    dialFunc
+
+	sync.RWMutex
+	stream client.Stream
+	// incremented on each reconnect
+	gen int
+	// messages received
+	recv   uint64
+	closed bool
+	err    error
+}
+
+func newReconnectStream(ctx context.Context, req client.Request, policy client.ReconnectPolicy, stream client.Stream, dial dialFunc) client.Stream {
+	return &reconnectStream{
+		ctx:     ctx,
+		request: req,
+		policy:  policy,
+		dial:    dial,
+		stream:  stream,
+	}
+}
+
+// transient returns true if the stream should be reconnected after the
+// error. Errors returned by the handler are not transient.
+func transient(err error) bool {
+	if err == nil || err == io.EOF {
+		return false
+	}
+	_, ok := err.(serverError)
+	return !ok
+}
+
+// current returns the stream and its generation
+func (r *reconnectStream) current() (client.Stream, int) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.stream, r.gen
+}
+
+// replaced returns true if the stream of the generation was replaced
+func (r *reconnectStream) replaced(gen int) bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.gen != gen && !r.closed
+}
+
+// reconnect replaces the stream of the generation which failed. It's a noop
+// if the stream was already replaced by a concurrent Send or Recv.
+func (r *reconnectStream) reconnect(gen int, cause error) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.closed {
+		return cause
+	}
+
+	if r.gen != gen {
+		return nil
+	}
+
+	r.stream.Close()
+
+	if r.policy.Max > 0 && r.gen >= r.policy.Max {
+		r.err = cause
+		return cause
+	}
+
+	for i := 1; i <= r.policy.Attempts; i++ {
+		if r.policy.Backoff != nil {
+			d, err := r.policy.Backoff(r.ctx, r.request, i)
+			if err != nil {
+				break
+			}
+			select {
+			case <-r.ctx.Done():
+				r.err = errors.Timeout("go.micro.client", "stream reconnect: %v", r.ctx.Err())
+				return r.err
+			case <-time.After(d):
+			}
+		}
+
+		ctx := metadata.Set(r.ctx, client.StreamResumeKey, strconv.FormatUint(r.recv, 10))
+
+		stream, err := r.dial(ctx)
+		if err != nil {
+			cause = err
+			continue
+		}
+
+		r.stream = stream
+		r.gen++
+
+		return nil
+	}
+
+	r.err = cause
+
+	return cause
+}
+
+func (r *reconnectStream) Context() context.Context {
+	return r.ctx
+}
+
+func (r *reconnectStream) Request() client.Request {
+	return r.request
+}
+
+func (r *reconnectStream) Response() client.Response {
+	stream, _ := r.current()
+	return stream.Response()
+}
+
+func (r *reconnectStream) Send(msg interface{}) error {
+	for {
+		stream, gen := r.current()
+
+		err := stream.Send(msg)
+		if err != nil && r.replaced(gen) {
+			continue
+		}
+		if !transient(err) {
+			return err
+		}
+
+		if err := r.reconnect(gen, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *reconnectStream) Recv(msg interface{}) error {
+	for {
+		stream, gen := r.current()
+
+		err := stream.Recv(msg)
+		if err == nil {
+			r.Lock()
+			r.recv++
+			r.Unlock()
+			return nil
+		}
+
+		// the stream was closed by a reconnect
+		if r.replaced(gen) {
+			continue
+		}
+
+		if !transient(err) {
+			return err
+		}
+
+		if err := r.reconnect(gen, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *reconnectStream) Error() error {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	return r.stream.Error()
+}
+
+func (r *reconnectStream) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.closed = true
+
+	return r.stream.Close()
+}
@@ -0,0 +1,103 @@
+package mucp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/codec"
+	raw "github.com/asim/go-micro/v3/codec/bytes"
+	"github.com/asim/go-micro/v3/metadata"
+)
+
+// shadow makes the call and asynchronously duplicates it to the shadow
+// target. The shadow response is discarded after it's compared with the
+// response of the call.
+func (r *rpcClient) shadow(ctx context.Context, request client.Request, response interface{}, target string, callOpts client.CallOptions, opts []client.CallOption) error {
+	if response == nil || reflect.TypeOf(response).Kind() != reflect.Ptr {
+		return r.cached(ctx, request, response, callOpts)
+	}
+
+	// the body is encoded once so the caller can reuse the request once the
+	// call returns, while the shadow call is still being made
+	body, err := r.encode(request)
+	if err != nil {
+		return r.cached(ctx, request, response, callOpts)
+	}
+
+	sreq := r.NewRequest(target, request.Endpoint(), &raw.Frame{Data: body}, client.WithContentType(request.ContentType()))
+	srsp := reflect.New(reflect.TypeOf(response).Elem()).Interface()
+
+	// the shadow call is independent of the callers context
+	md, _ := metadata.FromContext(ctx)
+	sctx := metadata.NewContext(context.Background(), metadata.Copy(md))
+
+	// the addresses of the call don't apply to the target
+	sopts := append([]client.CallOption{}, opts...)
+	sopts = append(sopts, client.WithShadow("", 0), client.WithAddress())
+
+	type result struct {
+		err error
+		d   time.Duration
+	}
+
+	ch := make(chan result, 1)
+
+	go func() {
+		start := time.Now()
+		err := r.Call(sctx, sreq, srsp, sopts...)
+		ch <- result{err, time.Since(start)}
+	}()
+
+	start := time.Now()
+	err = r.cached(ctx, request, response, callOpts)
+	d := time.Since(start)
+
+	stats := r.opts.ShadowStats
+	if stats == nil {
+		return err
+	}
+
+	// the response is compared by its encoding, taken before it's returned
+	// to the caller
+	var rsp interface{}
+	if err == nil {
+		if b, merr := json.Marshal(response); merr == nil {
+			rsp = json.RawMessage(b)
+		}
+	}
+
+	go func() {
+		res := <-ch
+		stats.Record(target, rsp, srsp, err, res.err, d, res.d)
+	}()
+
+	return err
+}
+
+// encode returns the body of the request encoded with its codec
+func (r *rpcClient) encode(req client.Request) ([]byte, error) {
+	if f, ok := req.Body().(*raw.Frame); ok {
+		return append([]byte(nil), f.Data...), nil
+	}
+
+	cf, err := r.newCodec(req.ContentType())
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &readWriteCloser{wbuf: new(bytes.Buffer), rbuf: new(bytes.Buffer)}
+	if err := cf(buf).Write(&codec.Message{
+		Type:     codec.Request,
+		Target:   req.Service(),
+		Method:   req.Method(),
+		Endpoint: req.Endpoint(),
+	}, req.Body()); err != nil {
+		return nil, err
+	}
+
+	return buf.wbuf.Bytes(), nil
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"time"
 
 	"github.com/asim/go-micro/v3/broker"
@@ -32,12 +33,45 @@ type Options struct {
 	// Lookup used for looking up routes
 	Lookup LookupFunc
 
+	// Breaker short circuits calls to failing services and nodes
+	Breaker *Breaker
+	// RetryBudget limits the retries made to a service
+	RetryBudget *Budget
+	// RateLimiter limits the calls made to a service
+	RateLimiter *RateLimiter
+	// Concurrency adaptively limits the calls in flight to a service
+	Concurrency *ConcurrencyLimiter
+	// Cache of responses for calls made WithCache
+	Cache *Cache
+	// Shadows are the shadow policies of the calls by service
+	Shadows map[string]ShadowPolicy
+	// ShadowStats compares shadow calls with the calls they duplicate
+	ShadowStats *ShadowStats
+
 	// Connection Pool
 	PoolSize int
 	PoolTTL  time.Duration
+	// PoolIdleTimeout closes connections idle for longer
+	PoolIdleTimeout time.Duration
+	// PoolMaxConns limits the open connections per host
+	PoolMaxConns int
+
+	// MaxSendSize is the max size in bytes of a message sent
+	MaxSendSize int
+	// MaxRecvSize is the max size in bytes of a response read from a
+	// grpc:// address, zero is unlimited
+	MaxRecvSize int
+	// DialOptions are passed to the transport when dialling
+	DialOptions []transport.DialOption
+	// CodecMetrics records the sizes and codec latency of the messages
+	CodecMetrics *codec.Metrics
 
 	// Middleware for client
 	Wrappers []Wrapper
+	// Middleware is the ordered chain the call wrappers are built from
+	Middleware []Middleware
+	// Interceptors run before each call and stream
+	Interceptors []Interceptor
 
 	// Default Call Options
 	CallOptions CallOptions
@@ -72,6 +106,40 @@ type CallOptions struct {
 	AuthToken bool
 	// Network to lookup the route within
 	Network string
+	// VersionConstraint the route version must satisfy e.g >=2.0.0 <3.0.0
+	VersionConstraint string
+	// FilterVersion is the version of the nodes routed to e.g v2
+	FilterVersion string
+	// FilterMetadata the metadata of the nodes routed to must match
+	FilterMetadata map[string]string
+	// HedgeDelay before sending the request to another node
+	HedgeDelay time.Duration
+	// HedgeAttempts is the max number of requests sent when hedging
+	HedgeAttempts int
+	// CacheExpiry of the response, zero disables caching
+	CacheExpiry time.Duration
+	// DeadlinePropagation sends the deadline of the call in the metadata
+	DeadlinePropagation bool
+	// StreamReconnect re-establishes streams broken by transient errors
+	StreamReconnect *ReconnectPolicy
+	// Shadow overrides the shadow policy of the service, an empty target
+	// disables it
+	Shadow *ShadowPolicy
+	// TLSConfig for calls to grpc:// addresses, nil uses cleartext
+	TLSConfig *tls.Config
+	// Clusters other than the local cluster of the router
+	Clusters []ClusterRouter
+	// Cluster to route the call to, defaults to the LocalCluster
+	Cluster string
+	// ClusterPolicy used when the cluster has no nodes
+	ClusterPolicy ClusterPolicy
+	// Compression of the requests e.g gzip, the preferred one of responses
+	Compression string
+	// CompressThreshold is the min size in bytes of the requests compressed
+	CompressThreshold int
+	// Accept is the content type of the responses, defaults to the
+	// content type of the request
+	Accept string
 
 	// Middleware for low level call func
 	CallWrappers []CallWrapper
@@ -84,6 +152,12 @@ type CallOptions struct {
 type PublishOptions struct {
 	// Exchange is the routing exchange for the message
 	Exchange string
+	// Ack waits for the broker to acknowledge the message
+	Ack bool
+	// AckRetries is the number of times a rejected message is retried
+	AckRetries int
+	// Key orders the messages published with the same key
+	Key string
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -107,20 +181,29 @@ func NewOptions(options ...Option) Options {
 		Context:     context.Background(),
 		ContentType: "application/protobuf",
 		Codecs:      make(map[string]codec.NewCodec),
+		MaxRecvSize: DefaultMaxRecvSize,
 		CallOptions: CallOptions{
 			Backoff:        DefaultBackoff,
 			Retry:          DefaultRetry,
 			Retries:        DefaultRetries,
 			RequestTimeout: DefaultRequestTimeout,
 			DialTimeout:    transport.DefaultDialTimeout,
+			CallWrappers:   []CallWrapper{DeadlineWrapper},
+
+			DeadlinePropagation: true,
 		},
 		Lookup:    LookupRoute,
+		Cache:     NewCache(DefaultCacheSize, nil),
 		PoolSize:  DefaultPoolSize,
 		PoolTTL:   DefaultPoolTTL,
 		Broker:    mbroker.NewBroker(),
 		Router:    regRouter.NewRouter(),
 		Selector:  roundrobin.NewSelector(),
 		Transport: tmem.NewTransport(),
+		Middleware: []Middleware{
+			{Name: DeadlineMiddleware, Wrapper: DeadlineWrapper},
+		},
+		ShadowStats: NewShadowStats(),
 	}
 
 	for _, o := range options {
@@ -165,13 +248,71 @@ func PoolSize(d int) Option {
 	}
 }
 
-// PoolTTL sets the connection pool ttl
+// PoolTTL sets the connection pool ttl, the max lifetime of a connection
 func PoolTTL(d time.Duration) Option {
 	return func(o *Options) {
 		o.PoolTTL = d
 	}
 }
 
+// PoolIdleTimeout closes pooled connections idle for longer than the timeout
+func PoolIdleTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.PoolIdleTimeout = d
+	}
+}
+
+// MaxSendSize limits the size of the messages sent. Larger messages
+// fail with a *codec.MessageSizeError before they're sent.
+func MaxSendSize(n int) Option {
+	return func(o *Options) {
+		o.MaxSendSize = n
+	}
+}
+
+// MaxRecvSize limits the size of the responses read from grpc://
+// addresses. Larger responses fail without being decoded.
+func MaxRecvSize(n int) Option {
+	return func(o *Options) {
+		o.MaxRecvSize = n
+	}
+}
+
+// DialOptions are passed to the transport when dialling
+// e.g transport.WithKeepAlive()
+func DialOptions(opts ...transport.DialOption) Option {
+	return func(o *Options) {
+		o.DialOptions = append(o.DialOptions, opts...)
+	}
+}
+
+// CodecMetrics records the sizes and codec latency of the requests written
+// and responses read per endpoint
+func CodecMetrics(m *codec.Metrics) Option {
+	return func(o *Options) {
+		o.CodecMetrics = m
+	}
+}
+
+// Compression compresses the requests of at least min bytes by default,
+// the services must support the compression e.g gzip, zstd or snappy. It's
+// also the preferred compression of the responses, which the services
+// compress above their own threshold.
+func Compression(compression string, min int) Option {
+	return func(o *Options) {
+		o.CallOptions.Compression = compression
+		o.CallOptions.CompressThreshold = min
+	}
+}
+
+// PoolMaxConns limits the open connections per host. Calls wait
+// for a connection to be released once the limit is reached.
+func PoolMaxConns(i int) Option {
+	return func(o *Options) {
+		o.PoolMaxConns = i
+	}
+}
+
 // Transport to use for communication e.g http, rabbitmq, etc
 func Transport(t transport.Transport) Option {
 	return func(o *Options) {
@@ -193,6 +334,25 @@ func Router(r router.Router) Option {
 	}
 }
 
+// Cluster adds a cluster whose services are discovered in the registry.
+// Calls are routed to it using WithCluster.
+func Cluster(name string, r registry.Registry) Option {
+	return func(o *Options) {
+		o.CallOptions.Clusters = append(o.CallOptions.Clusters, ClusterRouter{
+			Name:   name,
+			Router: regRouter.NewRouter(router.Registry(r)),
+		})
+	}
+}
+
+// ClusterRouting sets the default policy used when the cluster of
+// a call has no nodes for the service
+func ClusterRouting(p ClusterPolicy) Option {
+	return func(o *Options) {
+		o.CallOptions.ClusterPolicy = p
+	}
+}
+
 // Selector is used to select a route
 func Selector(s selector.Selector) Option {
 	return func(o *Options) {
@@ -207,10 +367,20 @@ func Wrap(w Wrapper) Option {
 	}
 }
 
+// CallInterceptor adds an interceptor run before each call and stream e.g
+// to set tenant, locale or feature flag metadata on every request
+func CallInterceptor(fn Interceptor) Option {
+	return func(o *Options) {
+		o.Interceptors = append(o.Interceptors, fn)
+	}
+}
+
 // Adds a Wrapper to the list of CallFunc wrappers
 func WrapCall(cw ...CallWrapper) Option {
 	return func(o *Options) {
-		o.CallOptions.CallWrappers = append(o.CallOptions.CallWrappers, cw...)
+		for _, w := range cw {
+			use(o, -1, Middleware{Wrapper: w})
+		}
 	}
 }
 
@@ -222,6 +392,82 @@ func Backoff(fn BackoffFunc) Option {
 	}
 }
 
+// CircuitBreaker short circuits calls with ErrCircuitOpen once a service
+// or node fails threshold times within the window. A trial call is
+// allowed after the cooldown.
+func CircuitBreaker(threshold int, window, cooldown time.Duration) Option {
+	return func(o *Options) {
+		o.Breaker = NewBreaker(threshold, window, cooldown)
+	}
+}
+
+// RetryBudget limits retries to a ratio of the requests made to each
+// service within the window e.g 0.2 allows 20% extra requests
+func RetryBudget(ratio float64, window time.Duration) Option {
+	return func(o *Options) {
+		o.RetryBudget = NewBudget(ratio, window)
+	}
+}
+
+// ResponseCache sets the cache used for calls made WithCache e.g
+// to back it with a store
+func ResponseCache(c *Cache) Option {
+	return func(o *Options) {
+		o.Cache = c
+	}
+}
+
+// DeadlinePropagation enables sending the deadline of calls in the
+// metadata so the whole call tree respects the original timeout
+func DeadlinePropagation(b bool) Option {
+	return func(o *Options) {
+		o.CallOptions.DeadlinePropagation = b
+	}
+}
+
+// Shadow asynchronously duplicates a percentage of the calls to the source
+// service to the target e.g Shadow("service", "service-v2", 10). Shadow
+// responses are discarded and compared with the originals in the ShadowStats.
+func Shadow(source, target string, percent float64) Option {
+	return func(o *Options) {
+		if o.Shadows == nil {
+			o.Shadows = make(map[string]ShadowPolicy)
+		}
+		o.Shadows[source] = ShadowPolicy{Target: target, Percent: percent}
+	}
+}
+
+// StreamReconnect re-establishes streams broken by transient errors.
+// The stream is re-dialled with the initial request and the number of
+// messages received in the StreamResumeKey metadata so the handler can
+// resume where it left off.
+func StreamReconnect(p ReconnectPolicy) Option {
+	return func(o *Options) {
+		o.CallOptions.StreamReconnect = &p
+	}
+}
+
+// RateLimit limits the calls to the service to limit per interval e.g
+// RateLimit("users", 100, time.Second). Calls over the limit fail
+// with ErrRateLimited.
+func RateLimit(service string, limit int, interval time.Duration) Option {
+	return func(o *Options) {
+		if o.RateLimiter == nil {
+			o.RateLimiter = NewRateLimiter()
+		}
+		o.RateLimiter.Limit(service, limit, interval)
+	}
+}
+
+// AdaptiveConcurrency limits the calls in flight to each service, starting
+// at initial and adapting up to max based on the observed latency. Calls
+// over the limit fail early with ErrOverloaded.
+func AdaptiveConcurrency(initial, max int) Option {
+	return func(o *Options) {
+		o.Concurrency = NewConcurrencyLimiter(initial, max)
+	}
+}
+
 // Lookup sets the lookup function to use for resolving service names
 func Lookup(l LookupFunc) Option {
 	return func(o *Options) {
@@ -275,6 +521,30 @@ func WithExchange(e string) PublishOption {
 	}
 }
 
+// WithKey sets the ordering key of the message, messages with the same
+// key are delivered in order. See broker.WithKey.
+func WithKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.Key = key
+	}
+}
+
+// WaitForAck waits for the broker to acknowledge the message. Rejected
+// messages are retried with backoff and the *broker.NackError with the
+// reason is returned once the retries are exhausted.
+func WaitForAck() PublishOption {
+	return func(o *PublishOptions) {
+		o.Ack = true
+	}
+}
+
+// WithAckRetries sets the number of times a rejected message is retried
+func WithAckRetries(i int) PublishOption {
+	return func(o *PublishOptions) {
+		o.AckRetries = i
+	}
+}
+
 // PublishContext sets the context in publish options
 func PublishContext(ctx context.Context) PublishOption {
 	return func(o *PublishOptions) {
@@ -358,6 +628,110 @@ func WithNetwork(n string) CallOption {
 	}
 }
 
+// WithVersionConstraint only routes to nodes whose semantic version
+// satisfies the constraint e.g ">=2.0.0 <3.0.0"
+func WithVersionConstraint(c string) CallOption {
+	return func(o *CallOptions) {
+		o.VersionConstraint = c
+	}
+}
+
+// WithFilterVersion only routes to nodes of the version e.g "v2"
+func WithFilterVersion(v string) CallOption {
+	return func(o *CallOptions) {
+		o.FilterVersion = v
+	}
+}
+
+// WithFilterMetadata only routes to nodes with the metadata e.g "canary",
+// "true", it's called again to match several keys
+func WithFilterMetadata(key, val string) CallOption {
+	return func(o *CallOptions) {
+		md := make(map[string]string, len(o.FilterMetadata)+1)
+		for k, v := range o.FilterMetadata {
+			md[k] = v
+		}
+		md[key] = val
+		o.FilterMetadata = md
+	}
+}
+
+// WithHedging sends the request to another node if no response is received
+// within the delay, up to max attempts. The first successful response is
+// returned and the others cancelled. Retries are not used when hedging so
+// it should only be used for idempotent requests.
+func WithHedging(delay time.Duration, max int) CallOption {
+	return func(o *CallOptions) {
+		o.HedgeDelay = delay
+		o.HedgeAttempts = max
+	}
+}
+
+// WithCache caches a successful response for the ttl. Subsequent calls with
+// the same request are served from the cache unless the Cache-Control
+// metadata is set to no-cache.
+func WithCache(ttl time.Duration) CallOption {
+	return func(o *CallOptions) {
+		o.CacheExpiry = ttl
+	}
+}
+
+// WithShadow is a CallOption which overrides the shadow target and
+// percentage of the service set with Shadow. An empty target disables it.
+func WithShadow(target string, percent float64) CallOption {
+	return func(o *CallOptions) {
+		o.Shadow = &ShadowPolicy{Target: target, Percent: percent}
+	}
+}
+
+// WithCompression is a CallOption which overrides the compression set in
+// Options.CallOptions, a min of zero doesn't compress the request
+func WithCompression(compression string, min int) CallOption {
+	return func(o *CallOptions) {
+		o.Compression = compression
+		o.CompressThreshold = min
+	}
+}
+
+// WithAccept is a CallOption which sets the content type of the responses
+// accepted. Servers with a codec for it respond with it, otherwise with
+// the content type of the request.
+func WithAccept(ct string) CallOption {
+	return func(o *CallOptions) {
+		o.Accept = ct
+	}
+}
+
+// WithStreamReconnect overrides the stream reconnect policy, nil disables it
+func WithStreamReconnect(p *ReconnectPolicy) CallOption {
+	return func(o *CallOptions) {
+		o.StreamReconnect = p
+	}
+}
+
+// WithTLS sets the tls config used to call external grpc services
+// at grpc:// addresses e.g WithAddress("grpc://host:443"), WithTLS(cfg)
+func WithTLS(cfg *tls.Config) CallOption {
+	return func(o *CallOptions) {
+		o.TLSConfig = cfg
+	}
+}
+
+// WithCluster routes the call to the named cluster e.g WithCluster("eu-west")
+func WithCluster(name string) CallOption {
+	return func(o *CallOptions) {
+		o.Cluster = name
+	}
+}
+
+// WithClusterPolicy is a CallOption which overrides the cluster policy
+// set in Options.CallOptions
+func WithClusterPolicy(p ClusterPolicy) CallOption {
+	return func(o *CallOptions) {
+		o.ClusterPolicy = p
+	}
+}
+
 // WithRouter sets the router to use for this call
 func WithRouter(r router.Router) CallOption {
 	return func(o *CallOptions) {
@@ -379,6 +753,14 @@ func WithSelectOptions(sops ...selector.SelectOption) CallOption {
 	}
 }
 
+// WithSelectKey sets the key the hashing selectors route the call by e.g the
+// id of the user, so calls with the same key land on the same node
+func WithSelectKey(k string) CallOption {
+	return func(o *CallOptions) {
+		o.SelectOptions = append(o.SelectOptions, selector.Key(k))
+	}
+}
+
 func WithMessageContentType(ct string) MessageOption {
 	return func(o *MessageOptions) {
 		o.ContentType = ct
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+var (
+	// ErrRateLimited is returned when a call exceeds the rate limit of the service
+	ErrRateLimited = errors.New("go.micro.client", "rate limit exceeded", http.StatusTooManyRequests)
+)
+
+// RateLimitStat is a snapshot of the rate limit of a service
+type RateLimitStat struct {
+	Service string `json:"service"`
+	// Limit is the number of calls allowed per interval
+	Limit    int           `json:"limit"`
+	Interval time.Duration `json:"interval"`
+	// Tokens available for calls
+	Tokens float64 `json:"tokens"`
+	// Allowed is the total number of calls allowed
+	Allowed uint64 `json:"allowed"`
+	// Limited is the total number of calls rejected
+	Limited uint64 `json:"limited"`
+}
+
+type bucket struct {
+	limit    int
+	interval time.Duration
+	tokens   float64
+	updated  time.Time
+	allowed  uint64
+	limited  uint64
+}
+
+// take refills the bucket and takes a token if one is available
+func (b *bucket) take(now time.Time) bool {
+	rate := float64(b.limit) / float64(b.interval)
+
+	b.tokens += float64(now.Sub(b.updated)) * rate
+	if b.tokens > float64(b.limit) {
+		b.tokens = float64(b.limit)
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		b.limited++
+		return false
+	}
+
+	b.tokens--
+	b.allowed++
+
+	return true
+}
+
+// RateLimiter is a token bucket limiting the calls made to each service
+type RateLimiter struct {
+	sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewRateLimiter returns a rate limiter without any limits
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Limit allows limit calls to the service per interval with bursts up to the limit
+func (r *RateLimiter) Limit(service string, limit int, interval time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.buckets[service] = &bucket{
+		limit:    limit,
+		interval: interval,
+		tokens:   float64(limit),
+		updated:  time.Now(),
+	}
+}
+
+// Allow returns ErrRateLimited if the call to the service exceeds its limit
+func (r *RateLimiter) Allow(service string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	b, ok := r.buckets[service]
+	if !ok {
+		return nil
+	}
+
+	if !b.take(time.Now()) {
+		return ErrRateLimited
+	}
+
+	return nil
+}
+
+// Stats returns a snapshot of the rate limit of each service
+func (r *RateLimiter) Stats() []*RateLimitStat {
+	r.Lock()
+	defer r.Unlock()
+
+	stats := make([]*RateLimitStat, 0, len(r.buckets))
+
+	for service, b := range r.buckets {
+		stats = append(stats, &RateLimitStat{
+			Service:  service,
+			Limit:    b.limit,
+			Interval: b.interval,
+			Tokens:   b.tokens,
+			Allowed:  b.allowed,
+			Limited:  b.limited,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Service < stats[j].Service
+	})
+
+	return stats
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	r := NewRateLimiter()
+	r.Limit("foo", 10, time.Second)
+
+	var limited int
+	for i := 0; i < 20; i++ {
+		if err := r.Allow("foo"); err == ErrRateLimited {
+			limited++
+		}
+	}
+
+	if limited != 10 {
+		t.Fatalf("expected 10 calls to be limited got %d", limited)
+	}
+
+	// services without a limit are allowed
+	if err := r.Allow("bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	// tokens are refilled over the interval
+	time.Sleep(time.Millisecond * 250)
+
+	if err := r.Allow("foo"); err != nil {
+		t.Fatalf("expected a refilled token got %v", err)
+	}
+
+	stats := r.Stats()
+	if len(stats) != 1 || stats[0].Allowed != 11 || stats[0].Limited != 10 {
+		t.Fatalf("unexpected stats %+v", stats[0])
+	}
+}
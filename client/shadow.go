@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ShadowPolicy duplicates a percentage of the calls to a service to the
+// target service
+type ShadowPolicy struct {
+	// Target is the service the calls are duplicated to
+	Target string
+	// Percent of the calls duplicated
+	Percent float64
+}
+
+// ShadowStat compares the calls to a shadow target with the calls they duplicate
+type ShadowStat struct {
+	// Target is the shadow service
+	Target string `json:"target"`
+	// Calls duplicated to the target
+	Calls uint64 `json:"calls"`
+	// Errors returned by the target
+	Errors uint64 `json:"errors"`
+	// Mismatches where the target response or error differed
+	Mismatches uint64 `json:"mismatches"`
+	// Latency is the total latency of the duplicated calls
+	Latency time.Duration `json:"latency"`
+	// ShadowLatency is the total latency of the target
+	ShadowLatency time.Duration `json:"shadow_latency"`
+}
+
+// ShadowStats records the comparison of shadow calls
+type ShadowStats struct {
+	sync.Mutex
+	stats map[string]*ShadowStat
+}
+
+// NewShadowStats returns an empty shadow stats recorder
+func NewShadowStats() *ShadowStats {
+	return &ShadowStats{
+		stats: make(map[string]*ShadowStat),
+	}
+}
+
+// Record compares the response and error of the call with those of the
+// shadow call. Responses are compared by their json encoding.
+func (s *ShadowStats) Record(target string, rsp, srsp interface{}, err, serr error, d, sd time.Duration) {
+	mismatch := (err == nil) != (serr == nil)
+	if !mismatch && err == nil {
+		a, aerr := json.Marshal(rsp)
+		b, berr := json.Marshal(srsp)
+		mismatch = aerr != nil || berr != nil || !bytes.Equal(a, b)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	stat, ok := s.stats[target]
+	if !ok {
+		stat = &ShadowStat{Target: target}
+		s.stats[target] = stat
+	}
+
+	stat.Calls++
+	if serr != nil {
+		stat.Errors++
+	}
+	if mismatch {
+		stat.Mismatches++
+	}
+	stat.Latency += d
+	stat.ShadowLatency += sd
+}
+
+// Stats returns a snapshot of the stats for each target
+func (s *ShadowStats) Stats() []*ShadowStat {
+	s.Lock()
+	defer s.Unlock()
+
+	stats := make([]*ShadowStat, 0, len(s.stats))
+	for _, stat := range s.stats {
+		st := *stat
+		stats = append(stats, &st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Target < stats[j].Target
+	})
+
+	return stats
+}
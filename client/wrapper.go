@@ -10,6 +10,10 @@ type CallFunc func(ctx context.Context, addr string, req Request, rsp interface{
 // CallWrapper is a low level wrapper for the CallFunc
 type CallWrapper func(CallFunc) CallFunc
 
+// Interceptor returns the context used for the request e.g to set metadata.
+// Interceptors run before the call wrappers.
+type Interceptor func(ctx context.Context, req Request) context.Context
+
 // Wrapper wraps a client and returns a client
 type Wrapper func(Client) Client
 
@@ -0,0 +1,69 @@
+// Command flatc-gen-micro generates the go-micro clients and handlers of
+// the rpc services of flatbuffers schemas, next to the tables generated by
+// flatc --go. The requests and responses use the flatbuffers codec, the
+// clients and handlers send finished builders and receive tables.
+//
+//	flatc --go -o gen schema.fbs
+//	flatc-gen-micro -o gen schema.fbs
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var out = flag.String("o", ".", "directory of the code generated by flatc")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: flatc-gen-micro [-o dir] schema.fbs...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	for _, name := range flag.Args() {
+		if err := generate(name); err != nil {
+			fmt.Fprintf(os.Stderr, "flatc-gen-micro: %s: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// generate writes a file per service of the schema to the directory of
+// its namespace, as flatc does for the tables
+func generate(name string) error {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	s, err := parse(string(b))
+	if err != nil {
+		return err
+	}
+
+	for _, svc := range s.Services {
+		src, err := generateService(s, svc, filepath.Base(name))
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Join(*out, filepath.Join(s.Namespace...))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, svc.Name+"_micro.go"), src, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// schema is the namespace and rpc services of a flatbuffers schema
+type schema struct {
+	Namespace []string
+	Services  []*service
+}
+
+type service struct {
+	Name    string
+	Methods []*method
+}
+
+type method struct {
+	Name     string
+	Request  string
+	Response string
+	// Streaming is server, client or bidi, empty if it's unary
+	Streaming string
+}
+
+var (
+	comments  = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+	namespace = regexp.MustCompile(`\bnamespace\s+([\w.]+)\s*;`)
+	services  = regexp.MustCompile(`\brpc_service\s+(\w+)\s*\{([^}]*)\}`)
+	methods   = regexp.MustCompile(`(\w+)\s*\(\s*([\w.]+)\s*\)\s*:\s*([\w.]+)\s*(?:\(([^)]*)\))?\s*;`)
+	streaming = regexp.MustCompile(`\bstreaming\s*:\s*"(\w+)"`)
+)
+
+// parse returns the namespace and rpc services of the schema, the tables
+// of the methods must be in the namespace of the schema
+func parse(src string) (*schema, error) {
+	src = comments.ReplaceAllString(src, "")
+
+	s := new(schema)
+	if m := namespace.FindStringSubmatch(src); m != nil {
+		s.Namespace = strings.Split(m[1], ".")
+	}
+
+	for _, sm := range services.FindAllStringSubmatch(src, -1) {
+		svc := &service{Name: sm[1]}
+
+		// the body is only methods
+		body := strings.TrimSpace(sm[2])
+		if rest := strings.TrimSpace(methods.ReplaceAllString(body, "")); len(rest) > 0 {
+			return nil, fmt.Errorf("rpc_service %s: invalid method %q", svc.Name, rest)
+		}
+
+		for _, mm := range methods.FindAllStringSubmatch(body, -1) {
+			m := &method{
+				Name:     mm[1],
+				Request:  table(mm[2]),
+				Response: table(mm[3]),
+			}
+			if st := streaming.FindStringSubmatch(mm[4]); st != nil {
+				switch st[1] {
+				case "server", "client", "bidi":
+					m.Streaming = st[1]
+				case "none":
+				default:
+					return nil, fmt.Errorf("rpc_service %s: %s has unknown streaming %q", svc.Name, m.Name, st[1])
+				}
+			}
+			svc.Methods = append(svc.Methods, m)
+		}
+
+		s.Services = append(s.Services, svc)
+	}
+
+	if len(s.Services) == 0 {
+		return nil, fmt.Errorf("no rpc_service")
+	}
+	if len(s.Namespace) == 0 {
+		return nil, fmt.Errorf("no namespace for the package")
+	}
+
+	return s, nil
+}
+
+// table returns the name of the table without its namespace
+func table(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// Package is the go package of the tables generated by flatc
+func (s *schema) Package() string {
+	return s.Namespace[len(s.Namespace)-1]
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSchema = `// the greeter
+namespace example.greeter;
+
+table Request { name:string; }
+table Response { msg:string; }
+
+/* the service */
+rpc_service Greeter {
+  Hello(Request):Response;
+  Stream(example.greeter.Request):Response (streaming: "server");
+  Chat(Request):Response (streaming: "bidi");
+}
+`
+
+func TestParse(t *testing.T) {
+	s, err := parse(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pkg := s.Package(); pkg != "greeter" {
+		t.Fatalf("expected package greeter got %s", pkg)
+	}
+	if len(s.Services) != 1 || s.Services[0].Name != "Greeter" {
+		t.Fatalf("expected the Greeter service got %+v", s.Services)
+	}
+
+	expect := []method{
+		{Name: "Hello", Request: "Request", Response: "Response"},
+		{Name: "Stream", Request: "Request", Response: "Response", Streaming: "server"},
+		{Name: "Chat", Request: "Request", Response: "Response", Streaming: "bidi"},
+	}
+	methods := s.Services[0].Methods
+	if len(methods) != len(expect) {
+		t.Fatalf("expected %d methods got %d", len(expect), len(methods))
+	}
+	for i, m := range methods {
+		if *m != expect[i] {
+			t.Fatalf("expected %+v got %+v", expect[i], *m)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testData := []struct {
+		schema string
+		err    string
+	}{
+		{`namespace a; table A {}`, "no rpc_service"},
+		{`rpc_service S { M(A):B; }`, "no namespace"},
+		{`namespace a; rpc_service S { M(A) B; }`, "invalid method"},
+		{`namespace a; rpc_service S { M(A):B (streaming: "all"); }`, "unknown streaming"},
+	}
+
+	for _, d := range testData {
+		_, err := parse(d.schema)
+		if err == nil || !strings.Contains(err.Error(), d.err) {
+			t.Fatalf("expected %q for %s got %v", d.err, d.schema, err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	s, err := parse(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the source is formatted so it's valid go
+	src, err := generateService(s, s.Services[0], "greeter.fbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expect := range []string{
+		"package greeter",
+		"Hello(ctx context.Context, in *flatbuffers.Builder, opts ...client.CallOption) (*Response, error)",
+		"Stream(context.Context, *Request, Greeter_StreamStream) error",
+		"Chat(context.Context, Greeter_ChatStream) error",
+		"func RegisterGreeterHandler(",
+	} {
+		if !strings.Contains(string(src), expect) {
+			t.Fatalf("expected %q in the source:\n%s", expect, src)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+var funcs = template.FuncMap{
+	"lower": func(s string) string {
+		r, n := utf8.DecodeRuneInString(s)
+		return string(unicode.ToLower(r)) + s[n:]
+	},
+}
+
+var tmpl = template.Must(template.New("service").Funcs(funcs).Parse(`// Code generated by flatc-gen-micro. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.Package}}
+
+import (
+	context "context"
+
+	client "github.com/asim/go-micro/v3/client"
+	server "github.com/asim/go-micro/v3/server"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// ContentType of the requests of the {{.Name}} service
+const {{.Name}}ContentType = "application/flatbuffers"
+
+// Client API for {{.Name}} service
+
+type {{.Name}}Service interface {
+{{- range .Methods}}
+	{{.Name}}(ctx context.Context{{if not (.ClientStreams)}}, in *flatbuffers.Builder{{end}}, opts ...client.CallOption) ({{if .Streaming}}{{$.Name}}_{{.Name}}Service{{else}}*{{.Response}}{{end}}, error)
+{{- end}}
+}
+
+type {{lower .Name}}Service struct {
+	c    client.Client
+	name string
+}
+
+func New{{.Name}}Service(name string, c client.Client) {{.Name}}Service {
+	return &{{lower .Name}}Service{
+		c:    c,
+		name: name,
+	}
+}
+{{range .Methods}}{{if not .Streaming}}
+func (c *{{lower $.Name}}Service) {{.Name}}(ctx context.Context, in *flatbuffers.Builder, opts ...client.CallOption) (*{{.Response}}, error) {
+	req := c.c.NewRequest(c.name, "{{$.Name}}.{{.Name}}", in, client.WithContentType({{$.Name}}ContentType))
+	out := new({{.Response}})
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+{{else}}
+func (c *{{lower $.Name}}Service) {{.Name}}(ctx context.Context{{if not .ClientStreams}}, in *flatbuffers.Builder{{end}}, opts ...client.CallOption) ({{$.Name}}_{{.Name}}Service, error) {
+	req := c.c.NewRequest(c.name, "{{$.Name}}.{{.Name}}", &{{.Request}}{}, client.WithContentType({{$.Name}}ContentType))
+	stream, err := c.c.Stream(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+{{- if not .ClientStreams}}
+	if err := stream.Send(in); err != nil {
+		return nil, err
+	}
+{{- end}}
+	return &{{lower $.Name}}Service{{.Name}}{stream}, nil
+}
+
+type {{$.Name}}_{{.Name}}Service interface {
+	Context() context.Context
+	SendMsg(interface{}) error
+	RecvMsg(interface{}) error
+	Close() error
+{{- if .ClientStreams}}
+	Send(*flatbuffers.Builder) error
+{{- end}}
+{{- if .ServerStreams}}
+	Recv() (*{{.Response}}, error)
+{{- end}}
+}
+
+type {{lower $.Name}}Service{{.Name}} struct {
+	stream client.Stream
+}
+
+func (x *{{lower $.Name}}Service{{.Name}}) Close() error {
+	return x.stream.Close()
+}
+
+func (x *{{lower $.Name}}Service{{.Name}}) Context() context.Context {
+	return x.stream.Context()
+}
+
+func (x *{{lower $.Name}}Service{{.Name}}) SendMsg(m interface{}) error {
+	return x.stream.Send(m)
+}
+
+func (x *{{lower $.Name}}Service{{.Name}}) RecvMsg(m interface{}) error {
+	return x.stream.Recv(m)
+}
+{{- if .ClientStreams}}
+
+func (x *{{lower $.Name}}Service{{.Name}}) Send(m *flatbuffers.Builder) error {
+	return x.stream.Send(m)
+}
+{{- end}}
+{{- if .ServerStreams}}
+
+func (x *{{lower $.Name}}Service{{.Name}}) Recv() (*{{.Response}}, error) {
+	m := new({{.Response}})
+	err := x.stream.Recv(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+{{- end}}
+{{end}}{{end}}
+// Server API for {{.Name}} service
+
+type {{.Name}}Handler interface {
+{{- range .Methods}}
+{{- if not .Streaming}}
+	{{.Name}}(context.Context, *{{.Request}}, *flatbuffers.Builder) error
+{{- else if .ClientStreams}}
+	{{.Name}}(context.Context, {{$.Name}}_{{.Name}}Stream) error
+{{- else}}
+	{{.Name}}(context.Context, *{{.Request}}, {{$.Name}}_{{.Name}}Stream) error
+{{- end}}
+{{- end}}
+}
+
+func Register{{.Name}}Handler(s server.Server, hdlr {{.Name}}Handler, opts ...server.HandlerOption) error {
+	type {{lower .Name}} interface {
+{{- range .Methods}}
+{{- if .Streaming}}
+		{{.Name}}(ctx context.Context, stream server.Stream) error
+{{- else}}
+		{{.Name}}(ctx context.Context, in *{{.Request}}, out *flatbuffers.Builder) error
+{{- end}}
+{{- end}}
+	}
+	type {{.Name}} struct {
+		{{lower .Name}}
+	}
+	h := &{{lower .Name}}Handler{hdlr}
+	return s.Handle(s.NewHandler(&{{.Name}}{h}, opts...))
+}
+
+type {{lower .Name}}Handler struct {
+	{{.Name}}Handler
+}
+{{range .Methods}}{{if not .Streaming}}
+func (h *{{lower $.Name}}Handler) {{.Name}}(ctx context.Context, in *{{.Request}}, out *flatbuffers.Builder) error {
+	// the response is built and finished by the handler
+	out.Reset()
+	return h.{{$.Name}}Handler.{{.Name}}(ctx, in, out)
+}
+{{else}}
+func (h *{{lower $.Name}}Handler) {{.Name}}(ctx context.Context, stream server.Stream) error {
+{{- if .ClientStreams}}
+	return h.{{$.Name}}Handler.{{.Name}}(ctx, &{{lower $.Name}}{{.Name}}Stream{stream})
+{{- else}}
+	m := new({{.Request}})
+	if err := stream.Recv(m); err != nil {
+		return err
+	}
+	return h.{{$.Name}}Handler.{{.Name}}(ctx, m, &{{lower $.Name}}{{.Name}}Stream{stream})
+{{- end}}
+}
+
+type {{$.Name}}_{{.Name}}Stream interface {
+	Context() context.Context
+	SendMsg(interface{}) error
+	RecvMsg(interface{}) error
+	Close() error
+	Send(*flatbuffers.Builder) error
+{{- if .ClientStreams}}
+	Recv() (*{{.Request}}, error)
+{{- end}}
+}
+
+type {{lower $.Name}}{{.Name}}Stream struct {
+	stream server.Stream
+}
+
+func (x *{{lower $.Name}}{{.Name}}Stream) Close() error {
+	return x.stream.Close()
+}
+
+func (x *{{lower $.Name}}{{.Name}}Stream) Context() context.Context {
+	return x.stream.Context()
+}
+
+func (x *{{lower $.Name}}{{.Name}}Stream) SendMsg(m interface{}) error {
+	return x.stream.Send(m)
+}
+
+func (x *{{lower $.Name}}{{.Name}}Stream) RecvMsg(m interface{}) error {
+	return x.stream.Recv(m)
+}
+
+func (x *{{lower $.Name}}{{.Name}}Stream) Send(m *flatbuffers.Builder) error {
+	return x.stream.Send(m)
+}
+{{- if .ClientStreams}}
+
+func (x *{{lower $.Name}}{{.Name}}Stream) Recv() (*{{.Request}}, error) {
+	m := new({{.Request}})
+	if err := x.stream.Recv(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+{{- end}}
+{{end}}{{end}}`))
+
+// ClientStreams returns whether the client sends a stream
+func (m *method) ClientStreams() bool {
+	return m.Streaming == "client" || m.Streaming == "bidi"
+}
+
+// ServerStreams returns whether the server sends a stream
+func (m *method) ServerStreams() bool {
+	return m.Streaming == "server" || m.Streaming == "bidi"
+}
+
+// generateService returns the formatted source of the service
+func generateService(s *schema, svc *service, source string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, struct {
+		*service
+		Package string
+		Source  string
+	}{svc, s.Package(), source})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source([]byte(strings.TrimSpace(buf.String()) + "\n"))
+}
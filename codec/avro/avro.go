@@ -0,0 +1,121 @@
+// Package avro provides an avro codec using the schemas of a registry
+// assigning them ids e.g the confluent schema registry. The bodies are in
+// the confluent wire format, the id of the writer schema followed by the
+// avro binary, so they can be shared with kafka pipelines. The values
+// written return their schema, registered for the subject of the record
+// name, and are read as the fields they have with the json names of the
+// writer schema.
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/asim/go-micro/v3/broker/schema"
+	"github.com/asim/go-micro/v3/codec"
+)
+
+// magic byte of the confluent wire format
+const magic = 0x0
+
+// Record is implemented by the values written, returning their avro
+// schema e.g the types generated by gogen-avro
+type Record interface {
+	Schema() string
+}
+
+type Codec struct {
+	Conn io.ReadWriteCloser
+	r    *registry
+}
+
+func (c *Codec) ReadHeader(m *codec.Message, t codec.MessageType) error {
+	return nil
+}
+
+func (c *Codec) ReadBody(b interface{}) error {
+	buf, err := ioutil.ReadAll(c.Conn)
+	if err != nil {
+		return err
+	}
+	if b == nil || len(buf) == 0 {
+		return nil
+	}
+	if len(buf) < 5 || buf[0] != magic {
+		return errors.New("avro: unknown wire format")
+	}
+
+	s, err := c.r.schema(int(binary.BigEndian.Uint32(buf[1:5])))
+	if err != nil {
+		return err
+	}
+
+	native, _, err := s.codec.NativeFromBinary(buf[5:])
+	if err != nil {
+		return err
+	}
+
+	// the fields are read by their json names
+	j, err := json.Marshal(s.standard(s.root, native))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, b)
+}
+
+func (c *Codec) Write(m *codec.Message, b interface{}) error {
+	if b == nil {
+		return nil
+	}
+
+	rec, ok := b.(Record)
+	if !ok {
+		return fmt.Errorf("avro: %T has no schema", b)
+	}
+
+	s, err := c.r.writer(rec.Schema())
+	if err != nil {
+		return err
+	}
+
+	j, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	native, _, err := s.codec.NativeFromTextual(j)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 5, 5+len(j))
+	buf[0] = magic
+	binary.BigEndian.PutUint32(buf[1:], uint32(s.id))
+
+	if buf, err = s.codec.BinaryFromNative(buf, native); err != nil {
+		return err
+	}
+
+	_, err = c.Conn.Write(buf)
+	return err
+}
+
+func (c *Codec) Close() error {
+	return c.Conn.Close()
+}
+
+func (c *Codec) String() string {
+	return "avro"
+}
+
+// NewCodec returns the avro codec of the registry, set it for the content
+// type e.g application/avro with client.Codec and server.Codec
+func NewCodec(r schema.IDRegistry) codec.NewCodec {
+	reg := newRegistry(r)
+	return func(c io.ReadWriteCloser) codec.Codec {
+		return &Codec{Conn: c, r: reg}
+	}
+}
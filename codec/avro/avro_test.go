@@ -0,0 +1,133 @@
+package avro
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/asim/go-micro/v3/broker/schema"
+	"github.com/asim/go-micro/v3/codec"
+)
+
+const userSchema = `{
+	"type": "record",
+	"name": "User",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "name", "type": "string"},
+		{"name": "email", "type": ["null", "string"], "default": null},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "address", "type": ["null", {"type": "record", "name": "Address", "fields": [
+			{"name": "city", "type": "string"}
+		]}], "default": null}
+	]
+}`
+
+type Address struct {
+	City string `json:"city"`
+}
+
+type User struct {
+	Name    string   `json:"name"`
+	Email   *string  `json:"email"`
+	Tags    []string `json:"tags"`
+	Address *Address `json:"address"`
+}
+
+func (u *User) Schema() string { return userSchema }
+
+// testRegistry assigns ids to the schemas in memory
+type testRegistry struct {
+	sync.Mutex
+	schemas []*schema.Schema
+}
+
+func (r *testRegistry) Register(s *schema.Schema) error {
+	r.Lock()
+	defer r.Unlock()
+	s.ID = len(r.schemas) + 1
+	s.Version = s.ID
+	r.schemas = append(r.schemas, s)
+	return nil
+}
+
+func (r *testRegistry) Latest(topic string) (*schema.Schema, error) {
+	r.Lock()
+	defer r.Unlock()
+	for i := len(r.schemas) - 1; i >= 0; i-- {
+		if r.schemas[i].Topic == topic {
+			return r.schemas[i], nil
+		}
+	}
+	return nil, schema.ErrNotFound
+}
+
+func (r *testRegistry) Schema(id int) (*schema.Schema, error) {
+	r.Lock()
+	defer r.Unlock()
+	if id < 1 || id > len(r.schemas) {
+		return nil, schema.ErrNotFound
+	}
+	return r.schemas[id-1], nil
+}
+
+func (r *testRegistry) String() string { return "test" }
+
+type testRWC struct {
+	bytes.Buffer
+}
+
+func (rwc *testRWC) Close() error { return nil }
+
+func TestCodec(t *testing.T) {
+	reg := new(testRegistry)
+	nc := NewCodec(reg)
+
+	email := "user@example.com"
+	user := &User{Name: "user", Email: &email, Tags: []string{"a", "b"}, Address: &Address{City: "London"}}
+
+	rwc := new(testRWC)
+	if err := nc(rwc).Write(&codec.Message{}, user); err != nil {
+		t.Fatal(err)
+	}
+
+	// the confluent wire format with the id of the registered schema
+	if b := rwc.Bytes(); len(b) < 5 || !bytes.Equal(b[:5], []byte{0, 0, 0, 0, 1}) {
+		t.Fatalf("unexpected wire format %x", b)
+	}
+	if len(reg.schemas) != 1 || reg.schemas[0].Topic != "com.example.User" {
+		t.Fatalf("expected the schema registered for the record name got %+v", reg.schemas)
+	}
+
+	var got User
+	if err := nc(rwc).ReadBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "user" || got.Email == nil || *got.Email != email || len(got.Tags) != 2 || got.Address == nil || got.Address.City != "London" {
+		t.Fatalf("unexpected user %+v", got)
+	}
+
+	// other codecs of the registry use the same version
+	rwc.Reset()
+	if err := NewCodec(reg)(rwc).Write(&codec.Message{}, &User{Name: "other", Tags: []string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(reg.schemas) != 1 {
+		t.Fatalf("expected the latest version used got %d", len(reg.schemas))
+	}
+
+	// readers read the fields they have
+	var name struct {
+		Name string `json:"name"`
+	}
+	if err := nc(rwc).ReadBody(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name.Name != "other" {
+		t.Fatalf("expected other got %s", name.Name)
+	}
+
+	if err := nc(rwc).Write(&codec.Message{}, &name); err == nil {
+		t.Fatal("expected values without a schema rejected")
+	}
+}
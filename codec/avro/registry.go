@@ -0,0 +1,282 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/asim/go-micro/v3/broker/schema"
+	"github.com/linkedin/goavro/v2"
+)
+
+// registry caches the schemas of the registry by id and definition
+type registry struct {
+	r schema.IDRegistry
+
+	sync.RWMutex
+	ids     map[int]*avroSchema
+	writers map[string]*avroSchema
+}
+
+// avroSchema is a parsed schema and its named types
+type avroSchema struct {
+	id    int
+	codec *goavro.Codec
+	root  interface{}
+	// definitions by name and full name
+	names map[string]interface{}
+	// full names by name and full name
+	full map[string]string
+}
+
+func newRegistry(r schema.IDRegistry) *registry {
+	return &registry{
+		r:       r,
+		ids:     make(map[int]*avroSchema),
+		writers: make(map[string]*avroSchema),
+	}
+}
+
+func parse(id int, def string) (*avroSchema, error) {
+	// the json of the values is read with plain values for unions
+	c, err := goavro.NewCodecForStandardJSON(def)
+	if err != nil {
+		return nil, err
+	}
+
+	var root interface{}
+	if err := json.Unmarshal([]byte(def), &root); err != nil {
+		return nil, err
+	}
+
+	s := &avroSchema{
+		id:    id,
+		codec: c,
+		root:  root,
+		names: make(map[string]interface{}),
+		full:  make(map[string]string),
+	}
+	s.collect(root, "")
+
+	return s, nil
+}
+
+// writer returns the schema of the definition registered for the subject
+// of its record name, the latest version is used if it's the same
+func (r *registry) writer(def string) (*avroSchema, error) {
+	r.RLock()
+	s, ok := r.writers[def]
+	r.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	s, err := parse(0, def)
+	if err != nil {
+		return nil, err
+	}
+	subject := s.fullName(s.root)
+	if len(subject) == 0 {
+		return nil, fmt.Errorf("avro: schema has no name")
+	}
+
+	latest, err := r.r.Latest(subject)
+	if err != nil && err != schema.ErrNotFound {
+		return nil, err
+	}
+
+	if err == nil && latest.ID > 0 && canonical(string(latest.Definition)) == s.codec.CanonicalSchema() {
+		s.id = latest.ID
+	} else {
+		rs := &schema.Schema{Topic: subject, Format: schema.Avro, Definition: []byte(def)}
+		if err := r.r.Register(rs); err != nil {
+			return nil, err
+		}
+		s.id = rs.ID
+	}
+
+	if s.id <= 0 {
+		return nil, fmt.Errorf("avro: %s registry assigned no schema id", r.r.String())
+	}
+
+	r.Lock()
+	r.writers[def] = s
+	r.ids[s.id] = s
+	r.Unlock()
+
+	return s, nil
+}
+
+// schema returns the schema of the id
+func (r *registry) schema(id int) (*avroSchema, error) {
+	r.RLock()
+	s, ok := r.ids[id]
+	r.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	rs, err := r.r.Schema(id)
+	if err != nil {
+		return nil, fmt.Errorf("avro: schema %d: %v", id, err)
+	}
+	if rs.Format != schema.Avro {
+		return nil, fmt.Errorf("avro: schema %d is %s", id, rs.Format)
+	}
+
+	if s, err = parse(id, string(rs.Definition)); err != nil {
+		return nil, err
+	}
+
+	r.Lock()
+	r.ids[id] = s
+	r.Unlock()
+
+	return s, nil
+}
+
+// canonical returns the parsing canonical form of the definition
+func canonical(def string) string {
+	c, err := goavro.NewCodec(def)
+	if err != nil {
+		return ""
+	}
+	return c.CanonicalSchema()
+}
+
+// collect indexes the named types
+func (s *avroSchema) collect(t interface{}, ns string) {
+	switch t := t.(type) {
+	case []interface{}:
+		for _, u := range t {
+			s.collect(u, ns)
+		}
+	case map[string]interface{}:
+		if n, ok := t["namespace"].(string); ok {
+			ns = n
+		}
+		if name, ok := t["name"].(string); ok {
+			full := name
+			if i := strings.LastIndex(name, "."); i > 0 {
+				ns = name[:i]
+			} else if len(ns) > 0 {
+				full = ns + "." + name
+			}
+			s.names[name], s.names[full] = t, t
+			s.full[name], s.full[full] = full, full
+		}
+		if fields, ok := t["fields"].([]interface{}); ok {
+			for _, f := range fields {
+				if fm, ok := f.(map[string]interface{}); ok {
+					s.collect(fm["type"], ns)
+				}
+			}
+		}
+		s.collect(t["type"], ns)
+		s.collect(t["items"], ns)
+		s.collect(t["values"], ns)
+	}
+}
+
+// fullName returns the full name of the named type, empty if it's not
+func (s *avroSchema) fullName(t interface{}) string {
+	switch t := t.(type) {
+	case string:
+		return s.full[t]
+	case map[string]interface{}:
+		if name, ok := t["name"].(string); ok {
+			return s.full[name]
+		}
+	}
+	return ""
+}
+
+// unionName returns the name of a union branch of the type
+func (s *avroSchema) unionName(t interface{}) string {
+	if name := s.fullName(t); len(name) > 0 {
+		return name
+	}
+	switch t := t.(type) {
+	case string:
+		return t
+	case map[string]interface{}:
+		typ, _ := t["type"].(string)
+		if lt, ok := t["logicalType"].(string); ok {
+			return typ + "." + lt
+		}
+		return typ
+	}
+	return ""
+}
+
+// standard returns the native value with the unions unwrapped, as they
+// are in plain json
+func (s *avroSchema) standard(t, v interface{}) interface{} {
+	if name, ok := t.(string); ok {
+		if d, ok := s.names[name]; ok {
+			t = d
+		}
+	}
+
+	switch t := t.(type) {
+	case []interface{}:
+		// nil or the value keyed by the name of its branch
+		m, ok := v.(map[string]interface{})
+		if !ok || len(m) != 1 {
+			return v
+		}
+		for name, bv := range m {
+			for _, b := range t {
+				if s.unionName(b) == name {
+					return s.standard(b, bv)
+				}
+			}
+			return bv
+		}
+	case map[string]interface{}:
+		switch t["type"] {
+		case "record", "error":
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return v
+			}
+			out := make(map[string]interface{}, len(m))
+			fields, _ := t["fields"].([]interface{})
+			for _, f := range fields {
+				fm, _ := f.(map[string]interface{})
+				name, _ := fm["name"].(string)
+				if fv, ok := m[name]; ok {
+					out[name] = s.standard(fm["type"], fv)
+				}
+			}
+			return out
+		case "array":
+			a, ok := v.([]interface{})
+			if !ok {
+				return v
+			}
+			out := make([]interface{}, len(a))
+			for i, av := range a {
+				out[i] = s.standard(t["items"], av)
+			}
+			return out
+		case "map":
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return v
+			}
+			out := make(map[string]interface{}, len(m))
+			for k, mv := range m {
+				out[k] = s.standard(t["values"], mv)
+			}
+			return out
+		case "enum", "fixed":
+			return v
+		}
+		// a type nested in the type
+		return s.standard(t["type"], v)
+	}
+
+	return v
+}
@@ -1,4 +1,7 @@
-// Package bytes provides a bytes codec which does not encode or decode anything
+// Package bytes provides the application/octet-stream codec which does not
+// encode or decode anything. Requests and responses of Frames are passed
+// through as they are by the client and server so services relaying them
+// e.g with proxy.Forward don't decode and encode them.
 package bytes
 
 import (
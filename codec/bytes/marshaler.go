@@ -19,6 +19,8 @@ func (n Marshaler) Marshal(v interface{}) ([]byte, error) {
 		return ve, nil
 	case *Message:
 		return ve.Body, nil
+	case *Frame:
+		return ve.Data, nil
 	}
 	return nil, codec.ErrInvalidMessage
 }
@@ -29,8 +31,12 @@ func (n Marshaler) Unmarshal(d []byte, v interface{}) error {
 		*ve = d
 	case *Message:
 		ve.Body = d
+	case *Frame:
+		ve.Data = d
+	default:
+		return codec.ErrInvalidMessage
 	}
-	return codec.ErrInvalidMessage
+	return nil
 }
 
 func (n Marshaler) String() string {
@@ -0,0 +1,30 @@
+package bytes
+
+import (
+	"testing"
+)
+
+func TestMarshaler(t *testing.T) {
+	var m Marshaler
+
+	b, err := m.Marshal(&Frame{Data: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "test" {
+		t.Fatalf("expected the frame data got %s", b)
+	}
+
+	f := new(Frame)
+	if err := m.Unmarshal(b, f); err != nil {
+		t.Fatal(err)
+	}
+	if string(f.Data) != "test" {
+		t.Fatalf("expected the frame data got %s", f.Data)
+	}
+
+	var s string
+	if err := m.Unmarshal(b, &s); err == nil {
+		t.Fatal("expected an error for a string")
+	}
+}
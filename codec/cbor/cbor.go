@@ -0,0 +1,87 @@
+// Package cbor provides a cbor codec, a compact binary encoding for
+// constrained devices. The fields are named by their cbor or json tags.
+// The canonical codec encodes the same values to the same bytes so the
+// payloads can be hashed or signed, and rejects duplicate map keys.
+package cbor
+
+import (
+	"io"
+
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/fxamacker/cbor/v2"
+)
+
+var (
+	encMode cbor.EncMode
+	decMode cbor.DecMode
+
+	// canonical is the core deterministic encoding of RFC 8949
+	canonicalEncMode cbor.EncMode
+	canonicalDecMode cbor.DecMode
+)
+
+func init() {
+	var err error
+	if encMode, err = (cbor.EncOptions{}).EncMode(); err != nil {
+		panic(err)
+	}
+	if decMode, err = (cbor.DecOptions{}).DecMode(); err != nil {
+		panic(err)
+	}
+	if canonicalEncMode, err = cbor.CoreDetEncOptions().EncMode(); err != nil {
+		panic(err)
+	}
+	if canonicalDecMode, err = (cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}).DecMode(); err != nil {
+		panic(err)
+	}
+}
+
+type Codec struct {
+	Conn    io.ReadWriteCloser
+	Encoder *cbor.Encoder
+	Decoder *cbor.Decoder
+}
+
+func (c *Codec) ReadHeader(m *codec.Message, t codec.MessageType) error {
+	return nil
+}
+
+func (c *Codec) ReadBody(b interface{}) error {
+	if b == nil {
+		return nil
+	}
+	return c.Decoder.Decode(b)
+}
+
+func (c *Codec) Write(m *codec.Message, b interface{}) error {
+	if b == nil {
+		return nil
+	}
+	return c.Encoder.Encode(b)
+}
+
+func (c *Codec) Close() error {
+	return c.Conn.Close()
+}
+
+func (c *Codec) String() string {
+	return "cbor"
+}
+
+func NewCodec(c io.ReadWriteCloser) codec.Codec {
+	return &Codec{
+		Conn:    c,
+		Decoder: decMode.NewDecoder(c),
+		Encoder: encMode.NewEncoder(c),
+	}
+}
+
+// NewCanonicalCodec returns a codec using the canonical encoding, set it
+// for the content type with client.Codec and server.Codec
+func NewCanonicalCodec(c io.ReadWriteCloser) codec.Codec {
+	return &Codec{
+		Conn:    c,
+		Decoder: canonicalDecMode.NewDecoder(c),
+		Encoder: canonicalEncMode.NewEncoder(c),
+	}
+}
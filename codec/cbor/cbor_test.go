@@ -0,0 +1,33 @@
+package cbor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	m := Marshaler{Canonical: true}
+
+	// the keys are sorted whatever the order of the map
+	expect := []byte{0xa3, 0x61, 0x61, 0x01, 0x61, 0x62, 0x02, 0x61, 0x63, 0x03}
+	for i := 0; i < 10; i++ {
+		b, err := m.Marshal(map[string]int{"c": 3, "b": 2, "a": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, expect) {
+			t.Fatalf("expected %x got %x", expect, b)
+		}
+	}
+
+	// {"a": 1, "a": 2}
+	dup := []byte{0xa2, 0x61, 0x61, 0x01, 0x61, 0x61, 0x02}
+
+	var v map[string]int
+	if err := m.Unmarshal(dup, &v); err == nil {
+		t.Fatal("expected duplicate keys rejected")
+	}
+	if err := (Marshaler{}).Unmarshal(dup, &v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,24 @@
+package cbor
+
+// Marshaler encodes cbor, canonically if set
+type Marshaler struct {
+	Canonical bool
+}
+
+func (m Marshaler) Marshal(v interface{}) ([]byte, error) {
+	if m.Canonical {
+		return canonicalEncMode.Marshal(v)
+	}
+	return encMode.Marshal(v)
+}
+
+func (m Marshaler) Unmarshal(d []byte, v interface{}) error {
+	if m.Canonical {
+		return canonicalDecMode.Unmarshal(d, v)
+	}
+	return decMode.Unmarshal(d, v)
+}
+
+func (m Marshaler) String() string {
+	return "cbor"
+}
@@ -3,6 +3,7 @@ package codec
 
 import (
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -19,6 +20,16 @@ var (
 
 type MessageType int
 
+// MessageSizeError is returned when a message exceeds the size limit
+type MessageSizeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *MessageSizeError) Error() string {
+	return fmt.Sprintf("message size %d exceeds the limit of %d bytes", e.Size, e.Limit)
+}
+
 // Takes in a connection/buffer and returns a new Codec
 type NewCodec func(io.ReadWriteCloser) Codec
 
@@ -6,9 +6,12 @@ import (
 
 	"github.com/asim/go-micro/v3/codec"
 	"github.com/asim/go-micro/v3/codec/bytes"
+	"github.com/asim/go-micro/v3/codec/cbor"
+	"github.com/asim/go-micro/v3/codec/flatbuffers"
 	"github.com/asim/go-micro/v3/codec/grpc"
 	"github.com/asim/go-micro/v3/codec/json"
 	"github.com/asim/go-micro/v3/codec/jsonrpc"
+	"github.com/asim/go-micro/v3/codec/msgpack"
 	"github.com/asim/go-micro/v3/codec/proto"
 	"github.com/asim/go-micro/v3/codec/protorpc"
 	"github.com/asim/go-micro/v3/codec/text"
@@ -30,13 +33,16 @@ func (rwc *testRWC) Close() error {
 
 func getCodecs(c io.ReadWriteCloser) map[string]codec.Codec {
 	return map[string]codec.Codec{
-		"bytes":    bytes.NewCodec(c),
-		"grpc":     grpc.NewCodec(c),
-		"json":     json.NewCodec(c),
-		"jsonrpc":  jsonrpc.NewCodec(c),
-		"proto":    proto.NewCodec(c),
-		"protorpc": protorpc.NewCodec(c),
-		"text":     text.NewCodec(c),
+		"bytes":       bytes.NewCodec(c),
+		"cbor":        cbor.NewCodec(c),
+		"flatbuffers": flatbuffers.NewCodec(c),
+		"grpc":        grpc.NewCodec(c),
+		"json":        json.NewCodec(c),
+		"jsonrpc":     jsonrpc.NewCodec(c),
+		"msgpack":     msgpack.NewCodec(c),
+		"proto":       proto.NewCodec(c),
+		"protorpc":    protorpc.NewCodec(c),
+		"text":        text.NewCodec(c),
 	}
 }
 
@@ -0,0 +1,104 @@
+// Package flatbuffers provides a flatbuffers codec for latency critical
+// services. The requests and responses are written from a finished builder
+// and read into the tables generated by flatc, which access the fields of
+// the bytes read so nothing is unmarshaled. Use flatc-gen-micro to generate
+// the clients and handlers of the rpc services of a schema.
+package flatbuffers
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/asim/go-micro/v3/codec"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// Table is implemented by the tables generated by flatc
+type Table interface {
+	Init(buf []byte, i flatbuffers.UOffsetT)
+	Table() flatbuffers.Table
+}
+
+type Codec struct {
+	Conn io.ReadWriteCloser
+}
+
+func (c *Codec) ReadHeader(m *codec.Message, t codec.MessageType) error {
+	return nil
+}
+
+func (c *Codec) ReadBody(b interface{}) error {
+	if b == nil {
+		return nil
+	}
+	// the bytes are copied as the buffer of the conn is reused
+	buf, err := ioutil.ReadAll(c.Conn)
+	if err != nil {
+		return err
+	}
+	return unmarshal(buf, b)
+}
+
+func (c *Codec) Write(m *codec.Message, b interface{}) error {
+	if b == nil {
+		return nil
+	}
+	buf, err := marshal(b)
+	if err != nil {
+		return err
+	}
+	_, err = c.Conn.Write(buf)
+	return err
+}
+
+func (c *Codec) Close() error {
+	return c.Conn.Close()
+}
+
+func (c *Codec) String() string {
+	return "flatbuffers"
+}
+
+func NewCodec(c io.ReadWriteCloser) codec.Codec {
+	return &Codec{
+		Conn: c,
+	}
+}
+
+// marshal returns the bytes of a finished builder or of a root table
+func marshal(v interface{}) (buf []byte, err error) {
+	switch v := v.(type) {
+	case *flatbuffers.Builder:
+		// the builder panics if it's not finished
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("flatbuffers: %v", r)
+			}
+		}()
+		return v.FinishedBytes(), nil
+	case Table:
+		return v.Table().Bytes, nil
+	case []byte:
+		return v, nil
+	case *[]byte:
+		return *v, nil
+	}
+	return nil, fmt.Errorf("flatbuffers: %T is not a builder or table", v)
+}
+
+// unmarshal sets the table to the root of the bytes, they're referenced
+func unmarshal(buf []byte, v interface{}) error {
+	switch v := v.(type) {
+	case Table:
+		if len(buf) < flatbuffers.SizeUOffsetT {
+			return fmt.Errorf("flatbuffers: %d bytes is too short for a table", len(buf))
+		}
+		v.Init(buf, flatbuffers.GetUOffsetT(buf))
+		return nil
+	case *[]byte:
+		*v = buf
+		return nil
+	}
+	return fmt.Errorf("flatbuffers: %T is not a table", v)
+}
@@ -0,0 +1,101 @@
+package flatbuffers
+
+import (
+	"bytes"
+	"testing"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// request is a table as generated by flatc for
+// table Request { name:string; }
+type request struct {
+	_tab flatbuffers.Table
+}
+
+func (rcv *request) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *request) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *request) Name() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func newRequest(name string) *flatbuffers.Builder {
+	b := flatbuffers.NewBuilder(0)
+	n := b.CreateString(name)
+	b.StartObject(1)
+	b.PrependUOffsetTSlot(0, n, 0)
+	b.Finish(b.EndObject())
+	return b
+}
+
+type buffer struct {
+	*bytes.Buffer
+}
+
+func (b *buffer) Close() error {
+	return nil
+}
+
+func TestCodec(t *testing.T) {
+	b := &buffer{new(bytes.Buffer)}
+	c := NewCodec(b)
+
+	if err := c.Write(nil, newRequest("test.service")); err != nil {
+		t.Fatal(err)
+	}
+
+	req := new(request)
+	if err := c.ReadBody(req); err != nil {
+		t.Fatal(err)
+	}
+	if name := string(req.Name()); name != "test.service" {
+		t.Fatalf("expected test.service got %s", name)
+	}
+
+	// the table read is written as is
+	if err := c.Write(nil, req); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), req.Table().Bytes) {
+		t.Fatal("expected the bytes of the table written")
+	}
+}
+
+func TestCodecUnfinished(t *testing.T) {
+	c := NewCodec(&buffer{new(bytes.Buffer)})
+	if err := c.Write(nil, flatbuffers.NewBuilder(0)); err == nil {
+		t.Fatal("expected an unfinished builder to fail")
+	}
+}
+
+func TestMarshaler(t *testing.T) {
+	var m Marshaler
+
+	b, err := m.Marshal(newRequest("test.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := new(request)
+	if err := m.Unmarshal(b, req); err != nil {
+		t.Fatal(err)
+	}
+	if name := string(req.Name()); name != "test.service" {
+		t.Fatalf("expected test.service got %s", name)
+	}
+
+	if err := m.Unmarshal(b, new(string)); err == nil {
+		t.Fatal("expected an error for a string")
+	}
+}
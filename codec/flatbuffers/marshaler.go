@@ -0,0 +1,15 @@
+package flatbuffers
+
+type Marshaler struct{}
+
+func (Marshaler) Marshal(v interface{}) ([]byte, error) {
+	return marshal(v)
+}
+
+func (Marshaler) Unmarshal(d []byte, v interface{}) error {
+	return unmarshal(d, v)
+}
+
+func (Marshaler) String() string {
+	return "flatbuffers"
+}
@@ -14,6 +14,42 @@ type Codec struct {
 	Conn    io.ReadWriteCloser
 	Encoder *json.Encoder
 	Decoder *json.Decoder
+	// Options of the proto messages
+	Options Options
+}
+
+// Options tune the json of proto messages. They're read with jsonpb
+// accepting either field name. The codec writes them with encoding/json,
+// using their json tags with the proto field names and omitting zero
+// values, unless an option is set while the marshaler always uses jsonpb.
+type Options struct {
+	// EmitDefaults writes the fields with zero values
+	EmitDefaults bool
+	// EnumsAsInts writes enums as their numbers rather than names
+	EnumsAsInts bool
+	// OrigName writes the proto field names rather than lowerCamelCase
+	OrigName bool
+	// DiscardUnknown ignores unknown fields rather than failing to read
+	DiscardUnknown bool
+}
+
+func (o Options) marshaler() *jsonpb.Marshaler {
+	return &jsonpb.Marshaler{
+		EmitDefaults: o.EmitDefaults,
+		EnumsAsInts:  o.EnumsAsInts,
+		OrigName:     o.OrigName,
+	}
+}
+
+func (o Options) unmarshaler() *jsonpb.Unmarshaler {
+	return &jsonpb.Unmarshaler{
+		AllowUnknownFields: o.DiscardUnknown,
+	}
+}
+
+// jsonpb returns whether proto messages are written with jsonpb
+func (o Options) jsonpb() bool {
+	return o.EmitDefaults || o.EnumsAsInts || o.OrigName
 }
 
 func (c *Codec) ReadHeader(m *codec.Message, t codec.MessageType) error {
@@ -25,7 +61,7 @@ func (c *Codec) ReadBody(b interface{}) error {
 		return nil
 	}
 	if pb, ok := b.(proto.Message); ok {
-		return jsonpb.UnmarshalNext(c.Decoder, pb)
+		return c.Options.unmarshaler().UnmarshalNext(c.Decoder, pb)
 	}
 	return c.Decoder.Decode(b)
 }
@@ -34,6 +70,17 @@ func (c *Codec) Write(m *codec.Message, b interface{}) error {
 	if b == nil {
 		return nil
 	}
+	if pb, ok := b.(proto.Message); ok && c.Options.jsonpb() {
+		buf := bufferPool.Get()
+		defer bufferPool.Put(buf)
+		if err := c.Options.marshaler().Marshal(buf, pb); err != nil {
+			return err
+		}
+		// terminated like the values of the encoder
+		buf.WriteByte('\n')
+		_, err := c.Conn.Write(buf.Bytes())
+		return err
+	}
 	return c.Encoder.Encode(b)
 }
 
@@ -52,3 +99,17 @@ func NewCodec(c io.ReadWriteCloser) codec.Codec {
 		Encoder: json.NewEncoder(c),
 	}
 }
+
+// NewCodecWithOptions returns a json codec with the options of proto
+// messages, set it for application/json with client.Codec, server.Codec or
+// server.HandlerCodec for a handler
+func NewCodecWithOptions(o Options) codec.NewCodec {
+	return func(c io.ReadWriteCloser) codec.Codec {
+		return &Codec{
+			Conn:    c,
+			Decoder: json.NewDecoder(c),
+			Encoder: json.NewEncoder(c),
+			Options: o,
+		}
+	}
+}
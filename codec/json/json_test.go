@@ -0,0 +1,69 @@
+package json
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	pb "github.com/asim/go-micro/v3/errors/proto"
+)
+
+type buffer struct {
+	*bytes.Buffer
+}
+
+func (b *buffer) Close() error {
+	return nil
+}
+
+func TestCodecOptions(t *testing.T) {
+	testData := []struct {
+		opts   Options
+		expect string
+	}{
+		{Options{}, `{"id":"test.service"}`},
+		{Options{EmitDefaults: true}, `{"id":"test.service","code":0,"detail":"","status":""}`},
+	}
+
+	for _, d := range testData {
+		b := &buffer{new(bytes.Buffer)}
+		c := NewCodecWithOptions(d.opts)(b)
+
+		if err := c.Write(nil, &pb.Error{Id: "test.service"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(b.String()); got != d.expect {
+			t.Fatalf("expected %s got %s", d.expect, got)
+		}
+	}
+}
+
+func TestCodecDiscardUnknown(t *testing.T) {
+	body := `{"id":"test.service","unknown":1}`
+
+	c := NewCodec(&buffer{bytes.NewBufferString(body)})
+	if err := c.ReadBody(new(pb.Error)); err == nil {
+		t.Fatal("expected unknown fields to fail")
+	}
+
+	c = NewCodecWithOptions(Options{DiscardUnknown: true})(&buffer{bytes.NewBufferString(body)})
+	e := new(pb.Error)
+	if err := c.ReadBody(e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Id != "test.service" {
+		t.Fatalf("expected test.service got %s", e.Id)
+	}
+}
+
+func TestMarshalerOptions(t *testing.T) {
+	m := Marshaler{Options: Options{OrigName: true, EmitDefaults: true}}
+
+	b, err := m.Marshal(&pb.Error{Id: "test.service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `{"id":"test.service","code":0,"detail":"","status":""}`; string(b) != expect {
+		t.Fatalf("expected %s got %s", expect, b)
+	}
+}
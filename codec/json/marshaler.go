@@ -5,22 +5,22 @@ import (
 	"encoding/json"
 
 	"github.com/asim/go-micro/v3/util/buf"
-	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
 )
 
-var jsonpbMarshaler = &jsonpb.Marshaler{}
-
 // create buffer pool with 16 instances each preallocated with 256 bytes
 var bufferPool = buf.NewPool()
 
-type Marshaler struct{}
+type Marshaler struct {
+	// Options of the proto messages
+	Options Options
+}
 
 func (j Marshaler) Marshal(v interface{}) ([]byte, error) {
 	if pb, ok := v.(proto.Message); ok {
 		buf := bufferPool.Get()
 		defer bufferPool.Put(buf)
-		if err := jsonpbMarshaler.Marshal(buf, pb); err != nil {
+		if err := j.Options.marshaler().Marshal(buf, pb); err != nil {
 			return nil, err
 		}
 		return buf.Bytes(), nil
@@ -30,7 +30,7 @@ func (j Marshaler) Marshal(v interface{}) ([]byte, error) {
 
 func (j Marshaler) Unmarshal(d []byte, v interface{}) error {
 	if pb, ok := v.(proto.Message); ok {
-		return jsonpb.Unmarshal(bytes.NewReader(d), pb)
+		return j.Options.unmarshaler().Unmarshal(bytes.NewReader(d), pb)
 	}
 	return json.Unmarshal(d, v)
 }
@@ -0,0 +1,134 @@
+package codec
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SizeBuckets are the upper bounds in bytes of the buckets of the message
+// sizes recorded
+var SizeBuckets = []uint64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// Histogram counts the values recorded per bucket
+type Histogram struct {
+	// Bounds are the upper bounds of the buckets
+	Bounds []uint64 `json:"bounds"`
+	// Counts of the values of each bucket, the last is over the bounds
+	Counts []uint64 `json:"counts"`
+	// Count of the values
+	Count uint64 `json:"count"`
+	// Sum of the values
+	Sum uint64 `json:"sum"`
+}
+
+// EndpointStat are the sizes and latencies of the messages marshaled and
+// unmarshaled by the codecs of an endpoint
+type EndpointStat struct {
+	// Endpoint of the messages e.g Greeter.Hello
+	Endpoint string `json:"endpoint"`
+	// Marshaled messages written
+	Marshaled uint64 `json:"marshaled"`
+	// MarshalLatency is the total latency marshaling them
+	MarshalLatency time.Duration `json:"marshal_latency"`
+	// MarshalSizes of the bodies written
+	MarshalSizes Histogram `json:"marshal_sizes"`
+	// Unmarshaled messages read
+	Unmarshaled uint64 `json:"unmarshaled"`
+	// UnmarshalLatency is the total latency unmarshaling them
+	UnmarshalLatency time.Duration `json:"unmarshal_latency"`
+	// UnmarshalSizes of the bodies read
+	UnmarshalSizes Histogram `json:"unmarshal_sizes"`
+}
+
+// Metrics records the message sizes and codec latency per endpoint. The
+// client and server record the requests and responses of their codecs
+// once it's set for them, each should have its own.
+type Metrics struct {
+	sync.Mutex
+	stats map[string]*EndpointStat
+}
+
+// NewMetrics returns an empty metrics recorder
+func NewMetrics() *Metrics {
+	return &Metrics{
+		stats: make(map[string]*EndpointStat),
+	}
+}
+
+func newHistogram() Histogram {
+	return Histogram{
+		Bounds: SizeBuckets,
+		Counts: make([]uint64, len(SizeBuckets)+1),
+	}
+}
+
+func (h *Histogram) record(v uint64) {
+	i := sort.Search(len(h.Bounds), func(i int) bool {
+		return v <= h.Bounds[i]
+	})
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+}
+
+func (h Histogram) copy() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// stat returns the stat of the endpoint, the lock must be held
+func (m *Metrics) stat(endpoint string) *EndpointStat {
+	stat, ok := m.stats[endpoint]
+	if !ok {
+		stat = &EndpointStat{
+			Endpoint:       endpoint,
+			MarshalSizes:   newHistogram(),
+			UnmarshalSizes: newHistogram(),
+		}
+		m.stats[endpoint] = stat
+	}
+	return stat
+}
+
+// Marshaled records a message of the endpoint marshaled to size bytes
+func (m *Metrics) Marshaled(endpoint string, size int, d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	stat := m.stat(endpoint)
+	stat.Marshaled++
+	stat.MarshalLatency += d
+	stat.MarshalSizes.record(uint64(size))
+}
+
+// Unmarshaled records a message of the endpoint unmarshaled from size bytes
+func (m *Metrics) Unmarshaled(endpoint string, size int, d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	stat := m.stat(endpoint)
+	stat.Unmarshaled++
+	stat.UnmarshalLatency += d
+	stat.UnmarshalSizes.record(uint64(size))
+}
+
+// Stats returns the stats of the endpoints sorted by endpoint
+func (m *Metrics) Stats() []*EndpointStat {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := make([]*EndpointStat, 0, len(m.stats))
+	for _, stat := range m.stats {
+		st := *stat
+		st.MarshalSizes = stat.MarshalSizes.copy()
+		st.UnmarshalSizes = stat.UnmarshalSizes.copy()
+		stats = append(stats, &st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Endpoint < stats[j].Endpoint
+	})
+
+	return stats
+}
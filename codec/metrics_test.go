@@ -0,0 +1,43 @@
+package codec_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/codec"
+)
+
+func TestMetrics(t *testing.T) {
+	m := codec.NewMetrics()
+	m.Marshaled("Greeter.Hello", 10, time.Millisecond)
+	m.Marshaled("Greeter.Hello", 2000, time.Millisecond)
+	m.Unmarshaled("Greeter.Hello", 10<<20, time.Second)
+	m.Marshaled("Greeter.Bye", 10, time.Millisecond)
+
+	stats := m.Stats()
+	if len(stats) != 2 || stats[0].Endpoint != "Greeter.Bye" {
+		t.Fatalf("expected the endpoints sorted got %+v", stats)
+	}
+
+	st := stats[1]
+	if st.Marshaled != 2 || st.MarshalLatency != 2*time.Millisecond || st.Unmarshaled != 1 {
+		t.Fatalf("unexpected counters %+v", st)
+	}
+
+	// 10 bytes is in the first bucket, 2000 in the 4K bucket
+	sizes := st.MarshalSizes
+	if sizes.Counts[0] != 1 || sizes.Counts[3] != 1 || sizes.Count != 2 || sizes.Sum != 2010 {
+		t.Fatalf("unexpected histogram %+v", sizes)
+	}
+
+	// over the bounds is the last count
+	if counts := st.UnmarshalSizes.Counts; counts[len(counts)-1] != 1 {
+		t.Fatalf("expected the size over the bounds got %+v", counts)
+	}
+
+	// the stats are copies
+	sizes.Counts[0] = 10
+	if m.Stats()[1].MarshalSizes.Counts[0] != 1 {
+		t.Fatal("expected the stats to be copied")
+	}
+}
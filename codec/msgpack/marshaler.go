@@ -0,0 +1,41 @@
+package msgpack
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// tag the fields are named by
+const tag = "json"
+
+func newEncoder(w io.Writer) *msgpack.Encoder {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag(tag)
+	return enc
+}
+
+func newDecoder(r io.Reader) *msgpack.Decoder {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag(tag)
+	return dec
+}
+
+type Marshaler struct{}
+
+func (Marshaler) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := newEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Marshaler) Unmarshal(d []byte, v interface{}) error {
+	return newDecoder(bytes.NewReader(d)).Decode(v)
+}
+
+func (Marshaler) String() string {
+	return "msgpack"
+}
@@ -0,0 +1,51 @@
+// Package msgpack provides a msgpack codec, smaller and faster to encode
+// than json. The fields are named by their json tags, so the structs of
+// the json codec and the generated protobuf structs are encoded as is.
+package msgpack
+
+import (
+	"io"
+
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Codec struct {
+	Conn    io.ReadWriteCloser
+	Encoder *msgpack.Encoder
+	Decoder *msgpack.Decoder
+}
+
+func (c *Codec) ReadHeader(m *codec.Message, t codec.MessageType) error {
+	return nil
+}
+
+func (c *Codec) ReadBody(b interface{}) error {
+	if b == nil {
+		return nil
+	}
+	return c.Decoder.Decode(b)
+}
+
+func (c *Codec) Write(m *codec.Message, b interface{}) error {
+	if b == nil {
+		return nil
+	}
+	return c.Encoder.Encode(b)
+}
+
+func (c *Codec) Close() error {
+	return c.Conn.Close()
+}
+
+func (c *Codec) String() string {
+	return "msgpack"
+}
+
+func NewCodec(c io.ReadWriteCloser) codec.Codec {
+	return &Codec{
+		Conn:    c,
+		Decoder: newDecoder(c),
+		Encoder: newEncoder(c),
+	}
+}
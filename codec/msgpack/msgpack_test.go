@@ -0,0 +1,37 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type request struct {
+	Service string `json:"service"`
+}
+
+func TestMarshaler(t *testing.T) {
+	var m Marshaler
+
+	b, err := m.Marshal(&request{Service: "test.service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fields are named by their json tags
+	var fields map[string]interface{}
+	if err := msgpack.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["service"] != "test.service" {
+		t.Fatalf("expected the json field name got %v", fields)
+	}
+
+	var req request
+	if err := m.Unmarshal(b, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Service != "test.service" {
+		t.Fatalf("expected test.service got %s", req.Service)
+	}
+}
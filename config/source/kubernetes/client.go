@@ -0,0 +1,208 @@
+package kubernetes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// serviceAccountPath is where the service account of the pod is mounted
+var serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// errNotFound is returned for resources which don't exist
+var errNotFound = errors.New("not found")
+
+// secret is a Secret, its base64 data is decoded into the bytes
+type secret struct {
+	Data map[string][]byte `json:"data"`
+}
+
+// configMap is read separately as its data isn't base64
+type configMap struct {
+	Data map[string]string `json:"data"`
+}
+
+type event struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type status struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// client is a minimal client of the kubernetes api reading and watching
+// the resources
+type client struct {
+	host  string
+	token string
+	http  *http.Client
+}
+
+// newClient returns a client of the api server at addr, the in cluster
+// api server if empty
+func newClient(addr, token string, config *tls.Config) (*client, error) {
+	if len(addr) == 0 {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if len(host) == 0 || len(port) == 0 {
+			return nil, errors.New("not running in a kubernetes cluster, set the api server address")
+		}
+		addr = "https://" + net.JoinHostPort(host, port)
+
+		if config == nil {
+			ca, err := ioutil.ReadFile(serviceAccountPath + "/ca.crt")
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(ca)
+			config = &tls.Config{RootCAs: pool}
+		}
+	}
+
+	if !strings.Contains(addr, "://") {
+		if config != nil {
+			addr = "https://" + addr
+		} else {
+			addr = "http://" + addr
+		}
+	}
+
+	if len(token) == 0 {
+		if b, err := ioutil.ReadFile(serviceAccountPath + "/token"); err == nil {
+			token = strings.TrimSpace(string(b))
+		}
+	}
+
+	return &client{
+		host:  strings.TrimSuffix(addr, "/"),
+		token: token,
+		http: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: config,
+			},
+		},
+	}, nil
+}
+
+// podNamespace returns the namespace of the pod, default outside of it
+func podNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); len(ns) > 0 {
+		return ns
+	}
+	if b, err := ioutil.ReadFile(serviceAccountPath + "/namespace"); err == nil {
+		return strings.TrimSpace(string(b))
+	}
+	return "default"
+}
+
+func (c *client) request(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", c.host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode == http.StatusNotFound {
+		rsp.Body.Close()
+		return nil, errNotFound
+	}
+
+	if rsp.StatusCode >= 300 {
+		defer rsp.Body.Close()
+		var s status
+		b, _ := ioutil.ReadAll(rsp.Body)
+		if err := json.Unmarshal(b, &s); err != nil || len(s.Message) == 0 {
+			s.Message = string(b)
+		}
+		return nil, fmt.Errorf("kubernetes api %s: %d %s", path, rsp.StatusCode, s.Message)
+	}
+
+	return rsp, nil
+}
+
+// get returns the data of the resource, errNotFound if it doesn't exist
+func (c *client) get(ctx context.Context, r resource) (map[string][]byte, error) {
+	rsp, err := c.request(ctx, r.path())
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	if r.kind == "secrets" {
+		var s secret
+		if err := json.NewDecoder(rsp.Body).Decode(&s); err != nil {
+			return nil, err
+		}
+		return s.Data, nil
+	}
+
+	var cm configMap
+	if err := json.NewDecoder(rsp.Body).Decode(&cm); err != nil {
+		return nil, err
+	}
+	data := make(map[string][]byte, len(cm.Data))
+	for k, v := range cm.Data {
+		data[k] = []byte(v)
+	}
+	return data, nil
+}
+
+// watch calls fn for each event of the resource until the context is done
+// or the api server closes the stream
+func (c *client) watch(ctx context.Context, r resource, fn func(*event)) error {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/%s?watch=true&fieldSelector=%s",
+		r.namespace, r.kind, url.QueryEscape("metadata.name="+r.name))
+
+	rsp, err := c.request(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	br := bufio.NewReader(rsp.Body)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e event
+			if err := json.Unmarshal(line, &e); err != nil {
+				return err
+			}
+			if e.Type == "ERROR" {
+				var s status
+				json.Unmarshal(e.Object, &s)
+				return fmt.Errorf("kubernetes watch %s: %d %s", path, s.Code, s.Message)
+			}
+			fn(&e)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package kubernetes is a config source reading ConfigMaps and Secrets
+// from the kubernetes api, merging them in order and watching them for
+// changes
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/google/uuid"
+	"github.com/imdario/mergo"
+)
+
+type kubernetes struct {
+	opts      source.Options
+	client    *client
+	err       error
+	resources []resource
+
+	sync.Mutex
+	cs       *source.ChangeSet
+	watchers map[string]*watcher
+	cancel   context.CancelFunc
+}
+
+// resource is a ConfigMap or Secret
+type resource struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (r resource) path() string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/%s/%s", r.namespace, r.kind, r.name)
+}
+
+func (r resource) String() string {
+	return strings.TrimSuffix(r.kind, "s") + " " + r.namespace + "/" + r.name
+}
+
+func (k *kubernetes) Read() (*source.ChangeSet, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+	cs, err := k.read(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	k.Lock()
+	k.cs = cs
+	k.Unlock()
+
+	return cs, nil
+}
+
+// read merges the data of the resources, the ones which don't exist are
+// skipped so they can be created later
+func (k *kubernetes) read(ctx context.Context) (*source.ChangeSet, error) {
+	merged := make(map[string]interface{})
+
+	for _, r := range k.resources {
+		data, err := k.client.get(ctx, r)
+		if err == errNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values, err := k.values(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", r, err)
+		}
+
+		if err := mergo.Map(&merged, values, mergo.WithOverride); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := k.opts.Encoder.Encode(merged)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding config: %v", err)
+	}
+
+	cs := &source.ChangeSet{
+		Format:    k.opts.Encoder.String(),
+		Data:      b,
+		Source:    k.String(),
+		Timestamp: time.Now(),
+	}
+	cs.Checksum = cs.Sum()
+
+	return cs, nil
+}
+
+// values returns the config of the data. The keys of the format of the
+// encoder e.g config.json are decoded and merged at the root, the others
+// are set as strings at their keys split on dots.
+func (k *kubernetes) values(data map[string][]byte) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make(map[string]interface{})
+	ext := "." + k.opts.Encoder.String()
+
+	for _, key := range keys {
+		if strings.HasSuffix(key, ext) {
+			var m map[string]interface{}
+			if err := k.opts.Encoder.Decode(data[key], &m); err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			if err := mergo.Map(&values, m, mergo.WithOverride); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		m := values
+		parts := strings.Split(key, ".")
+		for _, p := range parts[:len(parts)-1] {
+			n, ok := m[p].(map[string]interface{})
+			if !ok {
+				n = make(map[string]interface{})
+				m[p] = n
+			}
+			m = n
+		}
+		m[parts[len(parts)-1]] = string(data[key])
+	}
+
+	return values, nil
+}
+
+func (k *kubernetes) Write(cs *source.ChangeSet) error {
+	return nil
+}
+
+// Watch returns a watcher of the changes to the resources, they're watched
+// while there are watchers
+func (k *kubernetes) Watch() (source.Watcher, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+
+	w := &watcher{
+		id:      uuid.New().String(),
+		k:       k,
+		updates: make(chan *source.ChangeSet, 1),
+		exit:    make(chan bool),
+	}
+
+	k.Lock()
+	k.watchers[w.id] = w
+	if k.cancel == nil {
+		var ctx context.Context
+		ctx, k.cancel = context.WithCancel(context.Background())
+		k.watch(ctx)
+	}
+	k.Unlock()
+
+	return w, nil
+}
+
+func (k *kubernetes) String() string {
+	return "kubernetes"
+}
+
+// NewSource returns a kubernetes source of the ConfigMaps and Secrets set
+// with WithConfigMap and WithSecret
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+
+	addr, _ := options.Context.Value(addressKey{}).(string)
+	token, _ := options.Context.Value(tokenKey{}).(string)
+	config, _ := options.Context.Value(tlsConfigKey{}).(*tls.Config)
+
+	ns, ok := options.Context.Value(namespaceKey{}).(string)
+	if !ok || len(ns) == 0 {
+		ns = podNamespace()
+	}
+
+	k := &kubernetes{
+		opts:     options,
+		watchers: make(map[string]*watcher),
+	}
+	k.client, k.err = newClient(addr, token, config)
+
+	rs, _ := options.Context.Value(resourcesKey{}).([]resource)
+	for _, r := range rs {
+		r.namespace = ns
+		if parts := strings.SplitN(r.name, "/", 2); len(parts) == 2 {
+			r.namespace, r.name = parts[0], parts[1]
+		}
+		k.resources = append(k.resources, r)
+	}
+
+	return k
+}
@@ -0,0 +1,189 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// api is a fake of the kubernetes api serving the ConfigMaps and Secrets
+// of the default namespace
+type api struct {
+	sync.Mutex
+	configMaps map[string]map[string]string
+	secrets    map[string]map[string][]byte
+	watchers   []chan string
+}
+
+func newAPI() *api {
+	return &api{
+		configMaps: make(map[string]map[string]string),
+		secrets:    make(map[string]map[string][]byte),
+	}
+}
+
+// update sets the data of the ConfigMap and notifies the watchers
+func (a *api) update(name string, data map[string]string) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.configMaps[name] = data
+	for _, w := range a.watchers {
+		select {
+		case w <- `{"type":"MODIFIED","object":{}}`:
+		default:
+		}
+	}
+}
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("watch") == "true" {
+		ch := make(chan string, 10)
+		a.Lock()
+		a.watchers = append(a.watchers, ch)
+		a.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"type":"ADDED","object":{}}` + "\n"))
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case e := <-ch:
+				w.Write([]byte(e + "\n"))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	var rsp interface{}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/default/configmaps/"):
+		data, ok := a.configMaps[strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/default/configmaps/")]
+		if ok {
+			rsp = &configMap{Data: data}
+		}
+	case strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/default/secrets/"):
+		data, ok := a.secrets[strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/default/secrets/")]
+		if ok {
+			rsp = &secret{Data: data}
+		}
+	}
+
+	if rsp == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found","code":404}`))
+		return
+	}
+
+	json.NewEncoder(w).Encode(rsp)
+}
+
+type testConfig struct {
+	DB struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	} `json:"db"`
+	Log struct {
+		Level string `json:"level"`
+	} `json:"log"`
+}
+
+func decode(t *testing.T, b []byte) *testConfig {
+	var c testConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+func TestKubernetesRead(t *testing.T) {
+	a := newAPI()
+	a.configMaps["base"] = map[string]string{
+		"config.json": `{"db":{"host":"db","port":5432}}`,
+		"log.level":   "info",
+	}
+	a.secrets["override"] = map[string][]byte{
+		"db.host": []byte("db.prod"),
+	}
+
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	s := NewSource(
+		WithAddress(srv.URL),
+		WithNamespace("default"),
+		WithConfigMap("base"),
+		// missing resources are skipped
+		WithConfigMap("missing"),
+		WithSecret("override"),
+	)
+
+	cs, err := s.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := decode(t, cs.Data)
+	if c.DB.Host != "db.prod" || c.DB.Port != 5432 || c.Log.Level != "info" {
+		t.Fatalf("unexpected config %s", cs.Data)
+	}
+}
+
+func TestKubernetesWatch(t *testing.T) {
+	a := newAPI()
+	a.configMaps["base"] = map[string]string{"log.level": "info"}
+
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	s := NewSource(
+		WithAddress(srv.URL),
+		WithNamespace("default"),
+		WithConfigMap("base"),
+	)
+
+	if _, err := s.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := s.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the watch before changing it
+	for {
+		a.Lock()
+		n := len(a.watchers)
+		a.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	a.update("base", map[string]string{"log.level": "debug"})
+
+	cs, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := decode(t, cs.Data); c.Log.Level != "debug" {
+		t.Fatalf("expected debug got %s", c.Log.Level)
+	}
+
+	w.Stop()
+	if _, err := w.Next(); err == nil {
+		t.Fatal("expected the stopped watcher to fail")
+	}
+}
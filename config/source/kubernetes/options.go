@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/asim/go-micro/v3/config/source"
+)
+
+type addressKey struct{}
+type tokenKey struct{}
+type tlsConfigKey struct{}
+type namespaceKey struct{}
+type resourcesKey struct{}
+
+func setOption(k, v interface{}) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// WithAddress sets the address of the api server, the in cluster api
+// server by default
+func WithAddress(addr string) source.Option {
+	return setOption(addressKey{}, addr)
+}
+
+// WithToken sets the bearer token used to authenticate to the api server,
+// the service account token by default
+func WithToken(token string) source.Option {
+	return setOption(tokenKey{}, token)
+}
+
+// WithTLSConfig sets the tls config of the api server, the service account
+// ca by default
+func WithTLSConfig(c *tls.Config) source.Option {
+	return setOption(tlsConfigKey{}, c)
+}
+
+// WithNamespace sets the namespace of the resources without one, the
+// namespace of the pod by default
+func WithNamespace(ns string) source.Option {
+	return setOption(namespaceKey{}, ns)
+}
+
+// WithConfigMap reads the ConfigMap of the name, namespace/name for other
+// namespaces. The resources are merged in the order they're set so the
+// later ones take precedence.
+func WithConfigMap(name string) source.Option {
+	return withResource("configmaps", name)
+}
+
+// WithSecret reads the Secret of the name, namespace/name for other
+// namespaces. The resources are merged in the order they're set so the
+// later ones take precedence.
+func WithSecret(name string) source.Option {
+	return withResource("secrets", name)
+}
+
+func withResource(kind, name string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		rs, _ := o.Context.Value(resourcesKey{}).([]resource)
+		o.Context = context.WithValue(o.Context, resourcesKey{}, append(rs, resource{kind: kind, name: name}))
+	}
+}
@@ -0,0 +1,139 @@
+package kubernetes
+
+import (
+	"context"
+	"time"
+
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/util/backoff"
+)
+
+var (
+	// DefaultWatchBackoff between the attempts to watch again, jittered so
+	// the watchers of many services don't retry together
+	DefaultWatchBackoff = backoff.ExpJitter(time.Millisecond*100, time.Minute)
+)
+
+type watcher struct {
+	id      string
+	k       *kubernetes
+	updates chan *source.ChangeSet
+	exit    chan bool
+}
+
+// watch watches the resources until the context is done, they're read
+// again on changes and the watchers are sent the change set if it changed
+func (k *kubernetes) watch(ctx context.Context) {
+	notify := make(chan struct{}, 1)
+	changed := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+
+	for _, r := range k.resources {
+		go k.watchResource(ctx, r, changed)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+
+			cs, err := k.read(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Errorf("kubernetes config: %v", err)
+				}
+				continue
+			}
+
+			k.Lock()
+			if k.cs == nil || k.cs.Checksum != cs.Checksum {
+				k.cs = cs
+				for _, w := range k.watchers {
+					// only the latest change set is kept
+					select {
+					case <-w.updates:
+					default:
+					}
+					w.updates <- cs
+				}
+			}
+			k.Unlock()
+		}
+	}()
+}
+
+// watchResource calls changed on the events of the resource, the watch is
+// started again when the api server closes it
+func (k *kubernetes) watchResource(ctx context.Context, r resource, changed func()) {
+	for i := 0; ; i++ {
+		err := k.client.watch(ctx, r, func(*event) {
+			i = 0
+			changed()
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if err != nil && logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debugf("kubernetes config watch %s: %v", r, err)
+		}
+
+		// changes may have been missed meanwhile
+		changed()
+
+		select {
+		case <-time.After(DefaultWatchBackoff(i + 1)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	// is it closed?
+	select {
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	default:
+	}
+
+	select {
+	case cs := <-w.updates:
+		return cs, nil
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	}
+}
+
+// Stop stops the watcher, the resources aren't watched once all of them
+// are stopped
+func (w *watcher) Stop() error {
+	w.k.Lock()
+	defer w.k.Unlock()
+
+	select {
+	case <-w.exit:
+		return nil
+	default:
+		close(w.exit)
+	}
+
+	delete(w.k.watchers, w.id)
+	if len(w.k.watchers) == 0 && w.k.cancel != nil {
+		w.k.cancel()
+		w.k.cancel = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/asim/go-micro/v3/config/source"
+)
+
+type addressKey struct{}
+type tokenKey struct{}
+type namespaceKey struct{}
+type secretsKey struct{}
+type intervalKey struct{}
+type httpClientKey struct{}
+
+func setOption(k, v interface{}) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// WithAddress sets the address of vault, defaults to $VAULT_ADDR or
+// http://127.0.0.1:8200
+func WithAddress(addr string) source.Option {
+	return setOption(addressKey{}, addr)
+}
+
+// WithToken sets the vault token, defaults to $VAULT_TOKEN
+func WithToken(token string) source.Option {
+	return setOption(tokenKey{}, token)
+}
+
+// WithNamespace sets the vault enterprise namespace, defaults to
+// $VAULT_NAMESPACE
+func WithNamespace(ns string) source.Option {
+	return setOption(namespaceKey{}, ns)
+}
+
+// WithKV reads the KV v2 secret at the path of the mount e.g secret, its
+// data is set at the config key e.g "database", the root if it's empty.
+// The secret is read again once its version changes.
+func WithKV(key, mount, path string) source.Option {
+	return withSecret(&secret{key: key, mount: mount, path: path})
+}
+
+// WithDatabase reads dynamic credentials of the role of the database
+// secrets engine mount e.g database, the username and password are set at
+// the config key e.g "database.credentials". The lease is renewed and the
+// credentials are rotated once it can't be, changing the config.
+func WithDatabase(key, mount, role string) source.Option {
+	return withSecret(&secret{key: key, mount: mount, path: role, dynamic: true})
+}
+
+func withSecret(s *secret) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		secrets, _ := o.Context.Value(secretsKey{}).([]*secret)
+		o.Context = context.WithValue(o.Context, secretsKey{}, append(secrets, s))
+	}
+}
+
+// WithInterval sets how often the versions of the KV secrets are checked,
+// defaults to a minute
+func WithInterval(d time.Duration) source.Option {
+	return setOption(intervalKey{}, d)
+}
+
+// WithHTTPClient sets the http client calling vault e.g with the tls config
+// of its certificate
+func WithHTTPClient(c *http.Client) source.Option {
+	return setOption(httpClientKey{}, c)
+}
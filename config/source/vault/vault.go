@@ -0,0 +1,433 @@
+// Package vault is a config source reading the KV v2 secrets and dynamic
+// database credentials of vault, renewing their leases and pushing the
+// rotated credentials to the watchers
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/google/uuid"
+)
+
+type vault struct {
+	opts source.Options
+
+	addr      string
+	token     string
+	namespace string
+	interval  time.Duration
+	client    *http.Client
+
+	// held while the secrets are read
+	load    sync.Mutex
+	secrets []*secret
+
+	sync.Mutex
+	cs       *source.ChangeSet
+	watchers map[string]*watcher
+	exit     chan bool
+}
+
+// secret is a KV secret or the dynamic credentials of a role
+type secret struct {
+	key     string
+	mount   string
+	path    string
+	dynamic bool
+
+	data    map[string]interface{}
+	version int
+
+	leaseID   string
+	duration  time.Duration
+	renewable bool
+	// when the lease is renewed, or the credentials rotated if rotate is set
+	renewAt time.Time
+	rotate  bool
+}
+
+type kvResponse struct {
+	Data struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+type leaseResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+}
+
+type renewRequest struct {
+	LeaseID   string `json:"lease_id"`
+	Increment int    `json:"increment"`
+}
+
+type errorResponse struct {
+	Errors []string `json:"errors"`
+}
+
+func (v *vault) Read() (*source.ChangeSet, error) {
+	v.Lock()
+	cs := v.cs
+	v.Unlock()
+
+	if cs == nil {
+		if err := v.refresh(true); err != nil {
+			return nil, err
+		}
+		v.Lock()
+		cs = v.cs
+		v.Unlock()
+	}
+
+	return &source.ChangeSet{
+		Format:    cs.Format,
+		Timestamp: cs.Timestamp,
+		Data:      cs.Data,
+		Checksum:  cs.Checksum,
+		Source:    cs.Source,
+	}, nil
+}
+
+func (v *vault) Write(cs *source.ChangeSet) error {
+	return nil
+}
+
+// Watch returns a watcher of the changes to the secrets, the leases are
+// renewed and the KV secrets polled while there are watchers
+func (v *vault) Watch() (source.Watcher, error) {
+	w := &watcher{
+		id:      uuid.New().String(),
+		v:       v,
+		updates: make(chan *source.ChangeSet, 1),
+		exit:    make(chan bool),
+	}
+
+	v.Lock()
+	v.watchers[w.id] = w
+	if v.exit == nil {
+		v.exit = make(chan bool)
+		go v.run(v.exit)
+	}
+	v.Unlock()
+
+	return w, nil
+}
+
+func (v *vault) String() string {
+	return "vault"
+}
+
+// run renews the leases and polls the KV secrets until exit is closed
+func (v *vault) run(exit chan bool) {
+	// the secrets are read first in case they're watched before they're read
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-t.C:
+		}
+
+		if err := v.refresh(false); err != nil {
+			logger.Errorf("vault: %v", err)
+		}
+		t.Reset(v.next())
+	}
+}
+
+// next returns how long until the next lease renewal or poll
+func (v *vault) next() time.Duration {
+	v.load.Lock()
+	defer v.load.Unlock()
+
+	d := v.interval
+	for _, s := range v.secrets {
+		if !s.dynamic || s.renewAt.IsZero() {
+			continue
+		}
+		if until := time.Until(s.renewAt); until < d {
+			d = until
+		}
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// refresh reads the secrets which are due, all of them if force is set,
+// and pushes the change set to the watchers if it changed
+func (v *vault) refresh(force bool) error {
+	v.load.Lock()
+	defer v.load.Unlock()
+
+	v.Lock()
+	loaded := v.cs != nil
+	v.Unlock()
+
+	// loaded by a concurrent read
+	if force && loaded {
+		return nil
+	}
+
+	now := time.Now()
+	var errs []string
+
+	for _, s := range v.secrets {
+		var err error
+		switch {
+		case !s.dynamic:
+			err = v.readKV(s)
+		case force || s.leaseID == "":
+			err = v.readCreds(s)
+		case s.renewAt.IsZero() || now.Before(s.renewAt):
+			continue
+		case s.renewable && !s.rotate:
+			if err = v.renew(s); err != nil {
+				// the lease can't be extended so the credentials are rotated
+				logger.Errorf("vault: renewing %s: %v", s.leaseID, err)
+				err = v.readCreds(s)
+			}
+		default:
+			err = v.readCreds(s)
+		}
+
+		if err != nil {
+			errs = append(errs, err.Error())
+			if s.dynamic {
+				// retry rather than waiting for the lease to expire
+				s.renewAt = now.Add(v.interval)
+			}
+		}
+	}
+
+	// the config is loaded whole or not at all
+	if len(errs) > 0 && !loaded {
+		return fmt.Errorf("vault: %s", strings.Join(errs, "; "))
+	}
+
+	cs, err := v.changeSet()
+	if err != nil {
+		return err
+	}
+
+	v.Lock()
+	if v.cs == nil || v.cs.Checksum != cs.Checksum {
+		v.cs = cs
+		for _, w := range v.watchers {
+			// only the latest change set is kept
+			select {
+			case <-w.updates:
+			default:
+			}
+			w.updates <- cs
+		}
+	}
+	v.Unlock()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// changeSet encodes the data of the secrets at their keys
+func (v *vault) changeSet() (*source.ChangeSet, error) {
+	values := make(map[string]interface{})
+
+	for _, s := range v.secrets {
+		m := values
+		if len(s.key) > 0 {
+			for _, k := range strings.Split(s.key, ".") {
+				n, ok := m[k].(map[string]interface{})
+				if !ok {
+					n = make(map[string]interface{})
+					m[k] = n
+				}
+				m = n
+			}
+		}
+		for k, val := range s.data {
+			m[k] = val
+		}
+	}
+
+	b, err := v.opts.Encoder.Encode(values)
+	if err != nil {
+		return nil, fmt.Errorf("vault: error encoding secrets: %v", err)
+	}
+
+	cs := &source.ChangeSet{
+		Format:    v.opts.Encoder.String(),
+		Data:      b,
+		Source:    v.String(),
+		Timestamp: time.Now(),
+	}
+	cs.Checksum = cs.Sum()
+
+	return cs, nil
+}
+
+// readKV reads the latest version of the KV secret
+func (v *vault) readKV(s *secret) error {
+	var rsp kvResponse
+	if err := v.do("GET", s.mount+"/data/"+s.path, nil, &rsp); err != nil {
+		return err
+	}
+	s.data = rsp.Data.Data
+	s.version = rsp.Data.Metadata.Version
+	return nil
+}
+
+// readCreds reads new credentials of the role, the lease of the previous
+// ones is left to expire as they may still be in use
+func (v *vault) readCreds(s *secret) error {
+	var rsp leaseResponse
+	if err := v.do("GET", s.mount+"/creds/"+s.path, nil, &rsp); err != nil {
+		return err
+	}
+	s.data = rsp.Data
+	s.leaseID = rsp.LeaseID
+	s.renewable = rsp.Renewable
+	s.duration = time.Duration(rsp.LeaseDuration) * time.Second
+	s.rotate = !rsp.Renewable
+	s.schedule(s.duration)
+	return nil
+}
+
+// renew extends the lease of the credentials by their initial duration,
+// they're rotated next time if it's capped by the max ttl of the role
+func (v *vault) renew(s *secret) error {
+	req := &renewRequest{LeaseID: s.leaseID, Increment: int(s.duration / time.Second)}
+
+	var rsp leaseResponse
+	if err := v.do("PUT", "sys/leases/renew", req, &rsp); err != nil {
+		return err
+	}
+
+	d := time.Duration(rsp.LeaseDuration) * time.Second
+	s.rotate = !rsp.Renewable || d < s.duration
+	if len(rsp.LeaseID) > 0 {
+		s.leaseID = rsp.LeaseID
+	}
+	if d <= 0 {
+		// the lease is about to expire
+		s.renewAt = time.Now()
+		return nil
+	}
+	s.schedule(d)
+	return nil
+}
+
+// schedule sets the renewal at two thirds of the lease duration, leases
+// without a duration aren't renewed
+func (s *secret) schedule(d time.Duration) {
+	if d <= 0 {
+		s.renewAt = time.Time{}
+		return
+	}
+	s.renewAt = time.Now().Add(d * 2 / 3)
+}
+
+// do calls the vault api at the path, decoding the response into rsp
+func (v *vault) do(method, path string, req, rsp interface{}) error {
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return err
+		}
+	}
+
+	hreq, err := http.NewRequest(method, v.addr+"/v1/"+strings.TrimPrefix(path, "/"), &body)
+	if err != nil {
+		return err
+	}
+	if len(v.token) > 0 {
+		hreq.Header.Set("X-Vault-Token", v.token)
+	}
+	if len(v.namespace) > 0 {
+		hreq.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if req != nil {
+		hreq.Header.Set("Content-Type", "application/json")
+	}
+
+	hrsp, err := v.client.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer hrsp.Body.Close()
+
+	if hrsp.StatusCode < 200 || hrsp.StatusCode > 299 {
+		var e errorResponse
+		json.NewDecoder(hrsp.Body).Decode(&e)
+		if len(e.Errors) == 0 {
+			e.Errors = []string{http.StatusText(hrsp.StatusCode)}
+		}
+		return fmt.Errorf("%s %s: %s", method, path, strings.Join(e.Errors, ", "))
+	}
+
+	return json.NewDecoder(hrsp.Body).Decode(rsp)
+}
+
+// NewSource returns a vault source of the secrets set with WithKV and
+// WithDatabase
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+
+	v := &vault{
+		opts:      options,
+		addr:      os.Getenv("VAULT_ADDR"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		interval:  time.Minute,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		watchers:  make(map[string]*watcher),
+	}
+
+	if addr, ok := options.Context.Value(addressKey{}).(string); ok {
+		v.addr = addr
+	}
+	if token, ok := options.Context.Value(tokenKey{}).(string); ok {
+		v.token = token
+	}
+	if ns, ok := options.Context.Value(namespaceKey{}).(string); ok {
+		v.namespace = ns
+	}
+	if d, ok := options.Context.Value(intervalKey{}).(time.Duration); ok && d > 0 {
+		v.interval = d
+	}
+	if c, ok := options.Context.Value(httpClientKey{}).(*http.Client); ok {
+		v.client = c
+	}
+	if secrets, ok := options.Context.Value(secretsKey{}).([]*secret); ok {
+		for _, s := range secrets {
+			sc := *s
+			v.secrets = append(v.secrets, &sc)
+		}
+	}
+
+	if len(v.addr) == 0 {
+		v.addr = "http://127.0.0.1:8200"
+	}
+	v.addr = strings.TrimSuffix(v.addr, "/")
+
+	return v
+}
@@ -0,0 +1,184 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeVault struct {
+	sync.Mutex
+	version int
+	creds   int
+	renews  int
+	// lease duration returned by renewals
+	renewTTL int
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	var rsp interface{}
+	switch r.URL.Path {
+	case "/v1/secret/data/app":
+		rsp = map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"api_key": fmt.Sprintf("key-%d", f.version)},
+				"metadata": map[string]interface{}{"version": f.version},
+			},
+		}
+	case "/v1/database/creds/app":
+		f.creds++
+		rsp = map[string]interface{}{
+			"lease_id":       fmt.Sprintf("database/creds/app/%d", f.creds),
+			"lease_duration": 1,
+			"renewable":      true,
+			"data": map[string]interface{}{
+				"username": fmt.Sprintf("user-%d", f.creds),
+				"password": "secret",
+			},
+		}
+	case "/v1/sys/leases/renew":
+		var req renewRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.renews++
+		rsp = map[string]interface{}{
+			"lease_id":       req.LeaseID,
+			"lease_duration": f.renewTTL,
+			"renewable":      true,
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+		return
+	}
+
+	json.NewEncoder(w).Encode(rsp)
+}
+
+func decode(t *testing.T, b []byte) map[string]map[string]string {
+	var m map[string]map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestVaultKV(t *testing.T) {
+	f := &fakeVault{version: 1}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	s := NewSource(
+		WithAddress(srv.URL),
+		WithToken("root"),
+		WithKV("app", "secret", "app"),
+		WithInterval(10*time.Millisecond),
+	)
+
+	cs, err := s.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := decode(t, cs.Data)["app"]["api_key"]; v != "key-1" {
+		t.Fatalf("expected key-1 got %s", v)
+	}
+
+	w, err := s.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	f.Lock()
+	f.version = 2
+	f.Unlock()
+
+	cs, err = w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := decode(t, cs.Data)["app"]["api_key"]; v != "key-2" {
+		t.Fatalf("expected key-2 got %s", v)
+	}
+}
+
+func TestVaultDatabase(t *testing.T) {
+	// the first renewal is capped by the max ttl so the credentials rotate
+	f := &fakeVault{renewTTL: 0}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	s := NewSource(
+		WithAddress(srv.URL),
+		WithToken("root"),
+		WithDatabase("database.credentials", "database", "app"),
+	)
+
+	username := func(b []byte) string {
+		var m map[string]map[string]map[string]string
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m["database"]["credentials"]["username"]
+	}
+
+	cs, err := s.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := username(cs.Data); u != "user-1" {
+		t.Fatalf("expected user-1 got %s", u)
+	}
+
+	w, err := s.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err = w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := username(cs.Data); u != "user-2" {
+		t.Fatalf("expected rotated user-2 got %s", u)
+	}
+
+	f.Lock()
+	renews := f.renews
+	f.Unlock()
+	if renews != 1 {
+		t.Fatalf("expected 1 renewal got %d", renews)
+	}
+
+	w.Stop()
+	if _, err := w.Next(); err == nil {
+		t.Fatal("expected the stopped watcher to fail")
+	}
+}
+
+func TestVaultError(t *testing.T) {
+	srv := httptest.NewServer(&fakeVault{})
+	defer srv.Close()
+
+	s := NewSource(
+		WithAddress(srv.URL),
+		WithToken("invalid"),
+		WithKV("", "secret", "app"),
+	)
+
+	if _, err := s.Read(); err == nil {
+		t.Fatal("expected permission denied")
+	}
+}
@@ -0,0 +1,50 @@
+package vault
+
+import (
+	"github.com/asim/go-micro/v3/config/source"
+)
+
+type watcher struct {
+	id      string
+	v       *vault
+	updates chan *source.ChangeSet
+	exit    chan bool
+}
+
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	// is it closed?
+	select {
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	default:
+	}
+
+	select {
+	case cs := <-w.updates:
+		return cs, nil
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	}
+}
+
+// Stop stops the watcher, the leases aren't renewed once all of them are
+// stopped
+func (w *watcher) Stop() error {
+	w.v.Lock()
+	defer w.v.Unlock()
+
+	select {
+	case <-w.exit:
+		return nil
+	default:
+		close(w.exit)
+	}
+
+	delete(w.v.watchers, w.id)
+	if len(w.v.watchers) == 0 && w.v.exit != nil {
+		close(w.v.exit)
+		w.v.exit = nil
+	}
+
+	return nil
+}
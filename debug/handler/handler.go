@@ -0,0 +1,381 @@
+// Package handler implements the debug handler embedded in go-micro services
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/asim/go-micro/v3/debug/log"
+	memLog "github.com/asim/go-micro/v3/debug/log/memory"
+	"github.com/asim/go-micro/v3/debug/stats"
+	memStats "github.com/asim/go-micro/v3/debug/stats/memory"
+	"github.com/asim/go-micro/v3/debug/trace"
+	"github.com/asim/go-micro/v3/flags"
+	"github.com/asim/go-micro/v3/health"
+	memHealth "github.com/asim/go-micro/v3/health/memory"
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/util/pool"
+	mtls "github.com/asim/go-micro/v3/util/tls"
+)
+
+// Debug is the debug handler. Its endpoints are registered as
+// Debug.Health, Debug.Stats, Debug.Trace, Debug.Log, Debug.Flags
+// and Debug.Info.
+type Debug struct {
+	opts Options
+}
+
+// Options for the debug handler
+type Options struct {
+	Health health.Health
+	Stats  stats.Stats
+	Trace  trace.Tracer
+	Log    log.Log
+	Flags  flags.Flags
+	// Metadata of the service
+	Metadata map[string]string
+	// Report returns the startup report of the service
+	Report func() *Report
+	// Breaker is the client circuit breaker
+	Breaker *client.Breaker
+	// Pool returns the client connection pool stats
+	Pool func() pool.Stats
+	// Shadows compares the client shadow calls
+	Shadows *client.ShadowStats
+	// RateLimiter is the client rate limiter
+	RateLimiter *client.RateLimiter
+	// Concurrency is the client concurrency limiter
+	Concurrency *client.ConcurrencyLimiter
+	// TLSConfig is the config of the certificate served
+	TLSConfig *tls.Config
+	// Broker is the broker topic metrics
+	Broker *broker.Metrics
+	// Codec is the server codec metrics
+	Codec *codec.Metrics
+}
+
+// Option sets values in Options
+type Option func(o *Options)
+
+// Health sets the health checks to report
+func Health(h health.Health) Option {
+	return func(o *Options) {
+		o.Health = h
+	}
+}
+
+// Stats sets the stats to report
+func Stats(s stats.Stats) Option {
+	return func(o *Options) {
+		o.Stats = s
+	}
+}
+
+// Trace sets the tracer to read spans from
+func Trace(t trace.Tracer) Option {
+	return func(o *Options) {
+		o.Trace = t
+	}
+}
+
+// Log sets the debug log to read records from
+func Log(l log.Log) Option {
+	return func(o *Options) {
+		o.Log = l
+	}
+}
+
+// Flags sets the feature flags to report
+func Flags(f flags.Flags) Option {
+	return func(o *Options) {
+		o.Flags = f
+	}
+}
+
+// Metadata sets the service metadata to report
+func Metadata(md map[string]string) Option {
+	return func(o *Options) {
+		o.Metadata = md
+	}
+}
+
+// WithReport sets the func returning the startup report
+func WithReport(fn func() *Report) Option {
+	return func(o *Options) {
+		o.Report = fn
+	}
+}
+
+// Breaker sets the client circuit breaker to report
+func Breaker(b *client.Breaker) Option {
+	return func(o *Options) {
+		o.Breaker = b
+	}
+}
+
+// Pool sets the func returning the client connection pool stats
+func Pool(fn func() pool.Stats) Option {
+	return func(o *Options) {
+		o.Pool = fn
+	}
+}
+
+// Shadows sets the client shadow call stats to report
+func Shadows(s *client.ShadowStats) Option {
+	return func(o *Options) {
+		o.Shadows = s
+	}
+}
+
+// RateLimiter sets the client rate limiter to report
+func RateLimiter(r *client.RateLimiter) Option {
+	return func(o *Options) {
+		o.RateLimiter = r
+	}
+}
+
+// Concurrency sets the client concurrency limiter to report
+func Concurrency(c *client.ConcurrencyLimiter) Option {
+	return func(o *Options) {
+		o.Concurrency = c
+	}
+}
+
+// TLSConfig sets the tls config whose certificate expiry is reported
+func TLSConfig(c *tls.Config) Option {
+	return func(o *Options) {
+		o.TLSConfig = c
+	}
+}
+
+// Broker sets the broker topic metrics to report
+func Broker(m *broker.Metrics) Option {
+	return func(o *Options) {
+		o.Broker = m
+	}
+}
+
+// Codec sets the codec endpoint metrics to report
+func Codec(m *codec.Metrics) Option {
+	return func(o *Options) {
+		o.Codec = m
+	}
+}
+
+// NewHandler returns a new debug handler
+func NewHandler(opts ...Option) *Debug {
+	options := Options{
+		Trace: trace.DefaultTracer,
+		Flags: flags.DefaultFlags,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Health == nil {
+		options.Health = memHealth.NewHealth()
+	}
+
+	if options.Stats == nil {
+		options.Stats = memStats.NewStats()
+	}
+
+	if options.Log == nil {
+		options.Log = memLog.NewLog()
+	}
+
+	return &Debug{opts: options}
+}
+
+// HealthRequest asks for either the "live" or "ready" checks. Defaults to ready.
+type HealthRequest struct {
+	Type string `json:"type"`
+}
+
+// HealthResponse returns the overall status and individual check results
+type HealthResponse struct {
+	Status string           `json:"status"`
+	Checks []*health.Result `json:"checks"`
+}
+
+// Health runs the health checks
+func (d *Debug) Health(ctx context.Context, req *HealthRequest, rsp *HealthResponse) error {
+	fn := d.opts.Health.Ready
+	if req.Type == "live" {
+		fn = d.opts.Health.Live
+	}
+
+	checks, err := fn(ctx)
+
+	rsp.Status = string(health.StatusUp)
+	rsp.Checks = checks
+
+	if err != nil {
+		rsp.Status = string(health.StatusDown)
+	}
+
+	return nil
+}
+
+// StatsRequest for the runtime stats
+type StatsRequest struct{}
+
+// StatsResponse returns the stat snapshots, circuit breaker state,
+// connection pool utilisation, shadow call comparisons, rate limits,
+// concurrency limits, certificate expiry, broker topics and the histograms
+// of the message sizes per endpoint
+type StatsResponse struct {
+	Stats       []*stats.Stat             `json:"stats"`
+	Circuits    []*client.CircuitStat     `json:"circuits,omitempty"`
+	Pool        *pool.Stats               `json:"pool,omitempty"`
+	Shadows     []*client.ShadowStat      `json:"shadows,omitempty"`
+	RateLimits  []*client.RateLimitStat   `json:"rate_limits,omitempty"`
+	Concurrency []*client.ConcurrencyStat `json:"concurrency,omitempty"`
+	CertExpiry  *time.Time                `json:"cert_expiry,omitempty"`
+	Topics      []*broker.TopicStat       `json:"topics,omitempty"`
+	Codecs      []*codec.EndpointStat     `json:"codecs,omitempty"`
+}
+
+// Stats returns the runtime stats
+func (d *Debug) Stats(ctx context.Context, req *StatsRequest, rsp *StatsResponse) error {
+	stats, err := d.opts.Stats.Read()
+	if err != nil {
+		return err
+	}
+	rsp.Stats = stats
+	if d.opts.Breaker != nil {
+		rsp.Circuits = d.opts.Breaker.Stats()
+	}
+	if d.opts.Pool != nil {
+		ps := d.opts.Pool()
+		rsp.Pool = &ps
+	}
+	if d.opts.Shadows != nil {
+		rsp.Shadows = d.opts.Shadows.Stats()
+	}
+	if d.opts.RateLimiter != nil {
+		rsp.RateLimits = d.opts.RateLimiter.Stats()
+	}
+	if d.opts.Concurrency != nil {
+		rsp.Concurrency = d.opts.Concurrency.Stats()
+	}
+	if t, ok := mtls.Expiry(d.opts.TLSConfig); ok {
+		rsp.CertExpiry = &t
+	}
+	if d.opts.Broker != nil {
+		rsp.Topics = d.opts.Broker.Stats()
+	}
+	if d.opts.Codec != nil {
+		rsp.Codecs = d.opts.Codec.Stats()
+	}
+	return nil
+}
+
+// TraceRequest optionally scopes the spans to a trace id
+type TraceRequest struct {
+	Id string `json:"id"`
+}
+
+// TraceResponse returns the spans
+type TraceResponse struct {
+	Spans []*trace.Span `json:"spans"`
+}
+
+// Trace returns the recorded spans
+func (d *Debug) Trace(ctx context.Context, req *TraceRequest, rsp *TraceResponse) error {
+	var opts []trace.ReadOption
+	if len(req.Id) > 0 {
+		opts = append(opts, trace.ReadTrace(req.Id))
+	}
+
+	spans, err := d.opts.Trace.Read(opts...)
+	if err != nil {
+		return err
+	}
+	rsp.Spans = spans
+	return nil
+}
+
+// LogRequest optionally limits the number of records returned
+type LogRequest struct {
+	Count int `json:"count"`
+}
+
+// LogResponse returns the log records
+type LogResponse struct {
+	Records []log.Record `json:"records"`
+}
+
+// Log returns the debug log records
+func (d *Debug) Log(ctx context.Context, req *LogRequest, rsp *LogResponse) error {
+	var opts []log.ReadOption
+	if req.Count > 0 {
+		opts = append(opts, log.Count(req.Count))
+	}
+
+	records, err := d.opts.Log.Read(opts...)
+	if err != nil {
+		return err
+	}
+	rsp.Records = records
+	return nil
+}
+
+// FlagsRequest optionally sets the namespace the flags are evaluated for
+type FlagsRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+// FlagsResponse returns the state of the feature flags
+type FlagsResponse struct {
+	Flags map[string]bool `json:"flags"`
+}
+
+// Flags returns the state of the feature flags
+func (d *Debug) Flags(ctx context.Context, req *FlagsRequest, rsp *FlagsResponse) error {
+	if len(req.Namespace) > 0 {
+		ctx = metadata.Set(ctx, flags.NamespaceKey, req.Namespace)
+	}
+	rsp.Flags = d.opts.Flags.List(ctx)
+	return nil
+}
+
+// InfoRequest for the service info
+type InfoRequest struct{}
+
+// InfoResponse returns the service metadata and startup report
+type InfoResponse struct {
+	Metadata map[string]string `json:"metadata"`
+	Report   *Report           `json:"report,omitempty"`
+}
+
+// Report describes the effective configuration of a service
+type Report struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Id        string            `json:"id"`
+	Type      string            `json:"type"`
+	Address   string            `json:"address"`
+	Transport string            `json:"transport"`
+	Registry  string            `json:"registry"`
+	Broker    string            `json:"broker"`
+	Auth      string            `json:"auth"`
+	Namespace string            `json:"namespace"`
+	Codecs    []string          `json:"codecs"`
+	Wrappers  []string          `json:"wrappers"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+// Info returns information about the service
+func (d *Debug) Info(ctx context.Context, req *InfoRequest, rsp *InfoResponse) error {
+	rsp.Metadata = d.opts.Metadata
+	if d.opts.Report != nil {
+		rsp.Report = d.opts.Report()
+	}
+	return nil
+}
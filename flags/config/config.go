@@ -0,0 +1,137 @@
+// Package config provides feature flags read from config
+package config
+
+import (
+	"context"
+	"sync"
+
+	"github.com/asim/go-micro/v3/config"
+	"github.com/asim/go-micro/v3/config/memory"
+	"github.com/asim/go-micro/v3/config/reader"
+	"github.com/asim/go-micro/v3/flags"
+	"github.com/asim/go-micro/v3/logger"
+)
+
+type configFlags struct {
+	opts flags.Options
+
+	sync.RWMutex
+	flags   map[string]*flags.Flag
+	watcher config.Watcher
+}
+
+func (c *configFlags) Init(opts ...flags.Option) error {
+	for _, o := range opts {
+		o(&c.opts)
+	}
+
+	if len(c.opts.Path) == 0 {
+		c.opts.Path = []string{"flags"}
+	}
+
+	if c.opts.Config == nil {
+		cfg, err := memory.NewConfig()
+		if err != nil {
+			return err
+		}
+		c.opts.Config = cfg
+	}
+
+	if err := c.load(c.opts.Config.Get(c.opts.Path...)); err != nil {
+		return err
+	}
+
+	w, err := c.opts.Config.Watch(c.opts.Path...)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	if c.watcher != nil {
+		c.watcher.Stop()
+	}
+	c.watcher = w
+	c.Unlock()
+
+	go c.watch(w)
+
+	return nil
+}
+
+// load reads the flags from the config value
+func (c *configFlags) load(v reader.Value) error {
+	var fl map[string]*flags.Flag
+
+	if err := v.Scan(&fl); err != nil {
+		return err
+	}
+
+	c.Lock()
+	c.flags = fl
+	c.Unlock()
+
+	return nil
+}
+
+// watch reloads the flags when the config changes
+func (c *configFlags) watch(w config.Watcher) {
+	for {
+		v, err := w.Next()
+		if err != nil {
+			return
+		}
+
+		if err := c.load(v); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Failed to load flags: %v", err)
+			}
+		}
+	}
+}
+
+func (c *configFlags) Options() flags.Options {
+	return c.opts
+}
+
+func (c *configFlags) Enabled(ctx context.Context, name string) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	f, ok := c.flags[name]
+	if !ok {
+		return false
+	}
+
+	return f.On(flags.Namespace(ctx))
+}
+
+func (c *configFlags) List(ctx context.Context) map[string]bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	ns := flags.Namespace(ctx)
+	list := make(map[string]bool, len(c.flags))
+
+	for name, f := range c.flags {
+		list[name] = f.On(ns)
+	}
+
+	return list
+}
+
+func (c *configFlags) String() string {
+	return "config"
+}
+
+// NewFlags returns feature flags read from the config
+func NewFlags(opts ...flags.Option) flags.Flags {
+	c := new(configFlags)
+
+	if err := c.Init(opts...); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Failed to initialise flags: %v", err)
+		}
+	}
+
+	return c
+}
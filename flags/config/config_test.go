@@ -0,0 +1,66 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/config"
+	"github.com/asim/go-micro/v3/config/memory"
+	"github.com/asim/go-micro/v3/config/source"
+	smemory "github.com/asim/go-micro/v3/config/source/memory"
+	"github.com/asim/go-micro/v3/flags"
+	"github.com/asim/go-micro/v3/metadata"
+)
+
+func TestFlags(t *testing.T) {
+	src := smemory.NewSource(smemory.WithJSON([]byte(`{
+		"flags": {
+			"new-checkout": {"enabled": false, "namespaces": {"beta": true}},
+			"dark-mode": true
+		}
+	}`)))
+
+	cfg, err := memory.NewConfig(config.WithSource(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFlags(flags.Config(cfg))
+	ctx := context.Background()
+	beta := metadata.Set(ctx, flags.NamespaceKey, "beta")
+
+	if !f.Enabled(ctx, "dark-mode") {
+		t.Fatal("expected dark-mode to be enabled")
+	}
+	if f.Enabled(ctx, "new-checkout") {
+		t.Fatal("expected new-checkout to be disabled")
+	}
+	if !f.Enabled(beta, "new-checkout") {
+		t.Fatal("expected new-checkout to be enabled for beta")
+	}
+	if f.Enabled(ctx, "unknown") {
+		t.Fatal("expected unknown flag to be disabled")
+	}
+
+	// update the config once the source is watched
+	time.Sleep(time.Millisecond * 100)
+
+	if err := src.Write(&source.ChangeSet{
+		Data:   []byte(`{"flags": {"dark-mode": false}}`),
+		Format: "json",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 100)
+
+	if f.Enabled(ctx, "dark-mode") {
+		t.Fatal("expected dark-mode to be disabled after update")
+	}
+
+	// the wrapper makes the flags available through the context
+	if flags.Enabled(flags.NewContext(beta, f), "new-checkout") {
+		t.Fatal("expected new-checkout to be removed")
+	}
+}
@@ -0,0 +1,130 @@
+// Package flags provides feature flags which can be toggled per namespace
+package flags
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/server"
+)
+
+// Flags is an interface for feature flags
+type Flags interface {
+	// Init the flags
+	Init(...Option) error
+	// Options returns the current options
+	Options() Options
+	// Enabled returns true if the flag is on for the namespace in the context
+	Enabled(ctx context.Context, name string) bool
+	// List returns the state of the flags for the namespace in the context
+	List(ctx context.Context) map[string]bool
+	// String returns the name of the implementation
+	String() string
+}
+
+var (
+	// DefaultFlags is used when no flags are found in the context.
+	// All flags are disabled by default.
+	DefaultFlags Flags = new(noop)
+	// NamespaceKey is the metadata key the namespace is read from
+	NamespaceKey = "Micro-Namespace"
+)
+
+// Middleware is the name of the handler wrapper in the server middleware
+const Middleware = "flags"
+
+type flagsKey struct{}
+
+// Flag is the state of a flag, either a bool or an object with per
+// namespace overrides e.g
+// {"new-checkout": {"enabled": false, "namespaces": {"beta": true}}}
+type Flag struct {
+	Enabled    bool            `json:"enabled"`
+	Namespaces map[string]bool `json:"namespaces"`
+}
+
+func (f *Flag) UnmarshalJSON(b []byte) error {
+	var on bool
+	if err := json.Unmarshal(b, &on); err == nil {
+		f.Enabled = on
+		return nil
+	}
+
+	type value Flag
+	return json.Unmarshal(b, (*value)(f))
+}
+
+// On returns true if the flag is on for the namespace
+func (f *Flag) On(ns string) bool {
+	if v, ok := f.Namespaces[ns]; ok && len(ns) > 0 {
+		return v
+	}
+	return f.Enabled
+}
+
+// Enabled checks the flag using the flags in the context or DefaultFlags
+func Enabled(ctx context.Context, name string) bool {
+	return FromContext(ctx).Enabled(ctx, name)
+}
+
+// FromContext returns the flags stored in the context or DefaultFlags
+func FromContext(ctx context.Context) Flags {
+	if f, ok := ctx.Value(flagsKey{}).(Flags); ok {
+		return f
+	}
+	return DefaultFlags
+}
+
+// NewContext stores the flags in the context
+func NewContext(ctx context.Context, f Flags) context.Context {
+	return context.WithValue(ctx, flagsKey{}, f)
+}
+
+// Namespace returns the namespace flags are evaluated for
+func Namespace(ctx context.Context) string {
+	ns, _ := metadata.Get(ctx, NamespaceKey)
+	return ns
+}
+
+// NewHandlerWrapper makes the flags available to handlers through
+// the context so they can be checked with Enabled
+func NewHandlerWrapper(f Flags) server.HandlerWrapper {
+	return func(h server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			return h(NewContext(ctx, f), req, rsp)
+		}
+	}
+}
+
+// NewSubscriberWrapper makes the flags available to subscribers through
+// the context so they can be checked with Enabled
+func NewSubscriberWrapper(f Flags) server.SubscriberWrapper {
+	return func(h server.SubscriberFunc) server.SubscriberFunc {
+		return func(ctx context.Context, msg server.Message) error {
+			return h(NewContext(ctx, f), msg)
+		}
+	}
+}
+
+type noop struct{}
+
+func (n *noop) Init(...Option) error {
+	return nil
+}
+
+func (n *noop) Options() Options {
+	return Options{}
+}
+
+func (n *noop) Enabled(context.Context, string) bool {
+	return false
+}
+
+func (n *noop) List(context.Context) map[string]bool {
+	return map[string]bool{}
+}
+
+func (n *noop) String() string {
+	return "noop"
+}
@@ -0,0 +1,52 @@
+package flags
+
+import (
+	"context"
+	"time"
+
+	"github.com/asim/go-micro/v3/config"
+	"github.com/asim/go-micro/v3/store"
+)
+
+type Options struct {
+	// Config the flags are read from
+	Config config.Config
+	// Store the flags are read from
+	Store store.Store
+	// Path to the flags in the config or store
+	Path []string
+	// Refresh is how often flags read from the store are reloaded
+	Refresh time.Duration
+	// Context for other options
+	Context context.Context
+}
+
+type Option func(o *Options)
+
+// Config sets the config the flags are read from
+func Config(c config.Config) Option {
+	return func(o *Options) {
+		o.Config = c
+	}
+}
+
+// Store sets the store the flags are read from
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Refresh sets how often the flags read from the store are reloaded
+func Refresh(d time.Duration) Option {
+	return func(o *Options) {
+		o.Refresh = d
+	}
+}
+
+// Path sets the path to the flags in the config or store. Defaults to "flags".
+func Path(p ...string) Option {
+	return func(o *Options) {
+		o.Path = p
+	}
+}
@@ -0,0 +1,155 @@
+// Package store provides feature flags read from a store
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/flags"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/store"
+	"github.com/asim/go-micro/v3/store/memory"
+)
+
+var (
+	// DefaultRefresh is how often the flags are reloaded from the store
+	DefaultRefresh = time.Second * 10
+)
+
+// storeFlags reads a record per flag keyed by the path and its name e.g
+// flags/new-checkout holding {"enabled": false, "namespaces": {"beta": true}}
+type storeFlags struct {
+	opts flags.Options
+
+	sync.RWMutex
+	flags  map[string]*flags.Flag
+	loaded time.Time
+}
+
+func (s *storeFlags) Init(opts ...flags.Option) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, o := range opts {
+		o(&s.opts)
+	}
+
+	if len(s.opts.Path) == 0 {
+		s.opts.Path = []string{"flags"}
+	}
+
+	if s.opts.Refresh <= 0 {
+		s.opts.Refresh = DefaultRefresh
+	}
+
+	if s.opts.Store == nil {
+		s.opts.Store = memory.NewStore()
+	}
+
+	return s.load()
+}
+
+// prefix of the keys of the flags
+func (s *storeFlags) prefix() string {
+	return strings.Join(s.opts.Path, "/") + "/"
+}
+
+// load reads the flags from the store, the lock must be held
+func (s *storeFlags) load() error {
+	recs, err := s.opts.Store.Read(s.prefix(), store.ReadPrefix())
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	fl := make(map[string]*flags.Flag, len(recs))
+
+	for _, rec := range recs {
+		f := new(flags.Flag)
+		if err := json.Unmarshal(rec.Value, f); err != nil {
+			return err
+		}
+		fl[strings.TrimPrefix(rec.Key, s.prefix())] = f
+	}
+
+	s.flags = fl
+	s.loaded = time.Now()
+
+	return nil
+}
+
+// get returns the flags, reloading them once the refresh interval passed
+func (s *storeFlags) get() map[string]*flags.Flag {
+	s.RLock()
+	fl := s.flags
+	stale := time.Since(s.loaded) > s.opts.Refresh
+	s.RUnlock()
+
+	if !stale {
+		return fl
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// reloaded meanwhile
+	if time.Since(s.loaded) <= s.opts.Refresh {
+		return s.flags
+	}
+
+	if err := s.load(); err != nil {
+		// the previous flags are kept until the store is readable
+		s.loaded = time.Now()
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Failed to load flags: %v", err)
+		}
+	}
+
+	return s.flags
+}
+
+func (s *storeFlags) Options() flags.Options {
+	s.RLock()
+	defer s.RUnlock()
+	return s.opts
+}
+
+func (s *storeFlags) Enabled(ctx context.Context, name string) bool {
+	f, ok := s.get()[name]
+	if !ok {
+		return false
+	}
+
+	return f.On(flags.Namespace(ctx))
+}
+
+func (s *storeFlags) List(ctx context.Context) map[string]bool {
+	fl := s.get()
+	ns := flags.Namespace(ctx)
+	list := make(map[string]bool, len(fl))
+
+	for name, f := range fl {
+		list[name] = f.On(ns)
+	}
+
+	return list
+}
+
+func (s *storeFlags) String() string {
+	return "store"
+}
+
+// NewFlags returns feature flags read from the store
+func NewFlags(opts ...flags.Option) flags.Flags {
+	s := new(storeFlags)
+
+	if err := s.Init(opts...); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Failed to initialise flags: %v", err)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/flags"
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/store"
+	"github.com/asim/go-micro/v3/store/memory"
+)
+
+func TestFlags(t *testing.T) {
+	st := memory.NewStore()
+
+	for _, rec := range []*store.Record{
+		{Key: "flags/new-checkout", Value: []byte(`{"enabled": false, "namespaces": {"beta": true}}`)},
+		{Key: "flags/dark-mode", Value: []byte(`true`)},
+	} {
+		if err := st.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := NewFlags(flags.Store(st), flags.Refresh(time.Millisecond*50))
+	ctx := context.Background()
+	beta := metadata.Set(ctx, flags.NamespaceKey, "beta")
+
+	if !f.Enabled(ctx, "dark-mode") {
+		t.Fatal("expected dark-mode to be enabled")
+	}
+	if f.Enabled(ctx, "new-checkout") {
+		t.Fatal("expected new-checkout to be disabled")
+	}
+	if !f.Enabled(beta, "new-checkout") {
+		t.Fatal("expected new-checkout to be enabled for beta")
+	}
+	if f.Enabled(ctx, "unknown") {
+		t.Fatal("expected unknown flag to be disabled")
+	}
+
+	if err := st.Write(&store.Record{Key: "flags/dark-mode", Value: []byte(`false`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the flags are reloaded once the refresh interval passed
+	if !f.Enabled(ctx, "dark-mode") {
+		t.Fatal("expected dark-mode to be enabled until reloaded")
+	}
+
+	time.Sleep(time.Millisecond * 100)
+
+	if list := f.List(beta); list["dark-mode"] || !list["new-checkout"] || len(list) != 2 {
+		t.Fatalf("unexpected flags after reload %v", list)
+	}
+}
@@ -0,0 +1,91 @@
+// Package health provides liveness and readiness checks for a service
+package health
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrUnhealthy is returned when one or more checks failed
+	ErrUnhealthy = errors.New("unhealthy")
+	// ErrCheckExists is returned when a check is registered twice
+	ErrCheckExists = errors.New("check already registered")
+	// DefaultTimeout is the default time a single check can take
+	DefaultTimeout = time.Second * 5
+)
+
+// Health is an interface for registering and running health checks
+type Health interface {
+	// Init initialises options
+	Init(...Option) error
+	// Options returns the current options
+	Options() Options
+	// Register a named check
+	Register(name string, fn CheckFunc, opts ...CheckOption) error
+	// Deregister a named check
+	Deregister(name string) error
+	// Live runs the liveness checks
+	Live(ctx context.Context) ([]*Result, error)
+	// Ready runs the readiness checks, which includes the liveness checks
+	Ready(ctx context.Context) ([]*Result, error)
+	// String returns the name of the implementation
+	String() string
+}
+
+// CheckFunc is a single health check. A nil error means healthy.
+type CheckFunc func(ctx context.Context) error
+
+// Type of check
+type Type int
+
+const (
+	// Readiness checks indicate whether a service can serve traffic
+	Readiness Type = iota
+	// Liveness checks indicate whether a service should be restarted
+	Liveness
+)
+
+// String returns human readable check type
+func (t Type) String() string {
+	switch t {
+	case Readiness:
+		return "readiness"
+	case Liveness:
+		return "liveness"
+	default:
+		return "unknown"
+	}
+}
+
+// Status of a check
+type Status string
+
+const (
+	// StatusUp means the check passed
+	StatusUp Status = "up"
+	// StatusDown means the check failed
+	StatusDown Status = "down"
+)
+
+// MetadataKey is the node metadata the readiness status is registered
+// under. Nodes which are down are excluded from selection, registries with
+// checks of their own may set it too.
+const MetadataKey = "health"
+
+// Result is the outcome of running a check
+type Result struct {
+	// Name of the check
+	Name string `json:"name"`
+	// Type of the check
+	Type string `json:"type"`
+	// Status of the check
+	Status Status `json:"status"`
+	// Error returned by the check if any
+	Error string `json:"error,omitempty"`
+	// Duration of the check
+	Duration time.Duration `json:"duration"`
+	// Timestamp the check was run at
+	Timestamp time.Time `json:"timestamp"`
+}
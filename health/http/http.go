@@ -0,0 +1,143 @@
+// Package http exposes health checks over http for use as kubernetes probes
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/health"
+)
+
+var (
+	DefaultAddress = ":8081"
+	// ShutdownTimeout is how long Stop waits for the requests to finish
+	ShutdownTimeout = time.Second * 5
+)
+
+type response struct {
+	Status health.Status    `json:"status"`
+	Checks []*health.Result `json:"checks"`
+}
+
+// NewHandler returns a http.Handler serving /healthz for liveness
+// and /readyz for readiness. Failing checks return a 503.
+func NewHandler(h health.Health) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handle(h.Live))
+	mux.HandleFunc("/readyz", handle(h.Ready))
+	return mux
+}
+
+func handle(fn func(context.Context) ([]*health.Result, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results, err := fn(r.Context())
+
+		rsp := &response{
+			Status: health.StatusUp,
+			Checks: results,
+		}
+
+		code := http.StatusOK
+
+		if err != nil {
+			rsp.Status = health.StatusDown
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(rsp)
+	}
+}
+
+// Server serves the health handler on its own address
+type Server struct {
+	sync.Mutex
+	running bool
+	server  *http.Server
+	errs    chan error
+}
+
+// Start the server
+func (s *Server) Start() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	// listen first so the address in use is returned
+	l, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := s.server.Serve(l); err != nil {
+			s.Lock()
+			s.running = false
+			s.Unlock()
+
+			if err == http.ErrServerClosed {
+				return
+			}
+
+			select {
+			case s.errs <- err:
+			default:
+			}
+		}
+	}()
+
+	s.running = true
+
+	return nil
+}
+
+// Errors returns the errors causing the server to exit
+func (s *Server) Errors() <-chan error {
+	return s.errs
+}
+
+// Stop the server
+func (s *Server) Stop() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+
+	// the connections still open once the timeout expires are closed
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+		return err
+	}
+	return nil
+}
+
+// NewServer returns a server for the health checks. If addr
+// is blank the DefaultAddress is used.
+func NewServer(h health.Health, addr string) *Server {
+	if len(addr) == 0 {
+		addr = DefaultAddress
+	}
+
+	return &Server{
+		server: &http.Server{
+			Addr:    addr,
+			Handler: NewHandler(h),
+		},
+		errs: make(chan error, 1),
+	}
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"net"
+	"testing"
+
+	"github.com/asim/go-micro/v3/health/memory"
+)
+
+func TestServerAddressInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := NewServer(memory.NewHealth(), l.Addr().String())
+	if err := s.Start(); err == nil {
+		s.Stop()
+		t.Fatal("expected an error starting on an address in use")
+	}
+
+	s = NewServer(memory.NewHealth(), "127.0.0.1:0")
+	if err := s.Start(); err != nil {
+		t.Fatalf("unexpected error starting %v", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("unexpected error stopping %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+// Package memory is an in memory implementation of health
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/health"
+)
+
+type check struct {
+	name string
+	fn   health.CheckFunc
+	opts health.CheckOptions
+}
+
+type memoryHealth struct {
+	opts health.Options
+
+	sync.RWMutex
+	checks map[string]*check
+}
+
+func (m *memoryHealth) Init(opts ...health.Option) error {
+	m.Lock()
+	defer m.Unlock()
+	for _, o := range opts {
+		o(&m.opts)
+	}
+	return nil
+}
+
+func (m *memoryHealth) Options() health.Options {
+	m.RLock()
+	defer m.RUnlock()
+	return m.opts
+}
+
+func (m *memoryHealth) Register(name string, fn health.CheckFunc, opts ...health.CheckOption) error {
+	var options health.CheckOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.checks[name]; ok {
+		return health.ErrCheckExists
+	}
+
+	m.checks[name] = &check{
+		name: name,
+		fn:   fn,
+		opts: options,
+	}
+
+	return nil
+}
+
+func (m *memoryHealth) Deregister(name string) error {
+	m.Lock()
+	delete(m.checks, name)
+	m.Unlock()
+	return nil
+}
+
+func (m *memoryHealth) Live(ctx context.Context) ([]*health.Result, error) {
+	return m.run(ctx, func(c *check) bool {
+		return c.opts.Type == health.Liveness
+	})
+}
+
+func (m *memoryHealth) Ready(ctx context.Context) ([]*health.Result, error) {
+	// a service which is not alive is not ready
+	return m.run(ctx, func(c *check) bool {
+		return true
+	})
+}
+
+// run executes the matching checks concurrently
+func (m *memoryHealth) run(ctx context.Context, match func(*check) bool) ([]*health.Result, error) {
+	m.RLock()
+	timeout := m.opts.Timeout
+	var checks []*check
+	for _, c := range m.checks {
+		if match(c) {
+			checks = append(checks, c)
+		}
+	}
+	m.RUnlock()
+
+	results := make([]*health.Result, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i, c := range checks {
+		wg.Add(1)
+
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c, timeout)
+		}(i, c)
+	}
+
+	wg.Wait()
+
+	// sort by name for consistent output
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	for _, r := range results {
+		if r.Status != health.StatusUp {
+			return results, health.ErrUnhealthy
+		}
+	}
+
+	return results, nil
+}
+
+func runCheck(ctx context.Context, c *check, timeout time.Duration) *health.Result {
+	if c.opts.Timeout > time.Duration(0) {
+		timeout = c.opts.Timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	res := &health.Result{
+		Name:      c.name,
+		Type:      c.opts.Type.String(),
+		Status:    health.StatusUp,
+		Timestamp: time.Now(),
+	}
+
+	ch := make(chan error, 1)
+
+	go func() {
+		ch <- c.fn(ctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-ch:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res.Duration = time.Since(res.Timestamp)
+
+	if err != nil {
+		res.Status = health.StatusDown
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+func (m *memoryHealth) String() string {
+	return "memory"
+}
+
+// NewHealth returns a new in memory health
+func NewHealth(opts ...health.Option) health.Health {
+	options := health.Options{
+		Timeout: health.DefaultTimeout,
+		Context: context.Background(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &memoryHealth{
+		opts:   options,
+		checks: make(map[string]*check),
+	}
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/health"
+)
+
+func TestHealth(t *testing.T) {
+	h := NewHealth()
+
+	if err := h.Register("live", func(context.Context) error { return nil }, health.CheckType(health.Liveness)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Register("live", func(context.Context) error { return nil }); err != health.ErrCheckExists {
+		t.Fatalf("expected %v got %v", health.ErrCheckExists, err)
+	}
+
+	if err := h.Register("broker", func(context.Context) error { return errors.New("not connected") }); err != nil {
+		t.Fatal(err)
+	}
+
+	// liveness only runs the liveness checks
+	results, err := h.Live(context.TODO())
+	if err != nil {
+		t.Fatalf("expected live got %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "live" {
+		t.Fatalf("unexpected liveness results %+v", results)
+	}
+
+	// readiness runs everything
+	results, err = h.Ready(context.TODO())
+	if err != health.ErrUnhealthy {
+		t.Fatalf("expected %v got %v", health.ErrUnhealthy, err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results got %d", len(results))
+	}
+	if results[0].Name != "broker" || results[0].Status != health.StatusDown || results[0].Error != "not connected" {
+		t.Fatalf("unexpected broker result %+v", results[0])
+	}
+
+	if err := h.Deregister("broker"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := h.Ready(context.TODO()); err != nil {
+		t.Fatalf("expected ready got %v", err)
+	}
+}
+
+func TestHealthTimeout(t *testing.T) {
+	h := NewHealth()
+
+	h.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, health.CheckTimeout(time.Millisecond*10))
+
+	results, err := h.Ready(context.TODO())
+	if err != health.ErrUnhealthy {
+		t.Fatalf("expected %v got %v", health.ErrUnhealthy, err)
+	}
+	if results[0].Status != health.StatusDown {
+		t.Fatalf("expected check to be down got %v", results[0].Status)
+	}
+}
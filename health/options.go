@@ -0,0 +1,50 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+// Options for health
+type Options struct {
+	// Timeout is the default timeout for each check
+	Timeout time.Duration
+	// Other options for implementations of the interface
+	// can be stored in a context
+	Context context.Context
+}
+
+// Option sets values in Options
+type Option func(o *Options)
+
+// Timeout sets the default timeout for each check
+func Timeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = t
+	}
+}
+
+// CheckOptions used when registering a check
+type CheckOptions struct {
+	// Type of the check
+	Type Type
+	// Timeout overrides the default timeout
+	Timeout time.Duration
+}
+
+// CheckOption sets values in CheckOptions
+type CheckOption func(o *CheckOptions)
+
+// CheckType sets the type of check, defaults to readiness
+func CheckType(t Type) CheckOption {
+	return func(o *CheckOptions) {
+		o.Type = t
+	}
+}
+
+// CheckTimeout sets the timeout for the check
+func CheckTimeout(t time.Duration) CheckOption {
+	return func(o *CheckOptions) {
+		o.Timeout = t
+	}
+}
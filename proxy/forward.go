@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"context"
+	"io"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/asim/go-micro/v3/codec/bytes"
+	"github.com/asim/go-micro/v3/server"
+)
+
+// relay is a router forwarding the requests to a service
+type relay struct {
+	c       client.Client
+	service string
+	opts    []client.CallOption
+}
+
+// Relay returns a router forwarding the requests to the service with the
+// client without decoding them, set it with server.WithRouter for a service
+// relaying another. Messages aren't relayed.
+func Relay(c client.Client, service string, opts ...client.CallOption) server.Router {
+	return &relay{c: c, service: service, opts: opts}
+}
+
+func (r *relay) ProcessMessage(ctx context.Context, msg server.Message) error {
+	return nil
+}
+
+func (r *relay) ServeRequest(ctx context.Context, req server.Request, rsp server.Response) error {
+	return Forward(ctx, r.c, r.service, req.Endpoint(), req, rsp, r.opts...)
+}
+
+// Forward calls the endpoint of the service with the request and writes the
+// responses, the bodies are raw frames of the content type of the request
+// so they're neither decoded nor encoded.
+func Forward(ctx context.Context, c client.Client, service, endpoint string, req server.Request, rsp server.Response, opts ...client.CallOption) error {
+	// read initial request
+	body, err := req.Read()
+	if err != nil {
+		return err
+	}
+
+	// create new request with raw bytes body
+	creq := c.NewRequest(service, endpoint, &bytes.Frame{Data: body}, client.WithContentType(req.ContentType()))
+
+	// not a stream so make a client.Call request
+	if !req.Stream() {
+		crsp := new(bytes.Frame)
+
+		// make a call to the backend
+		if err := c.Call(ctx, creq, crsp, opts...); err != nil {
+			return err
+		}
+
+		// write the response
+		return rsp.Write(crsp.Data)
+	}
+
+	// new context with cancel
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// create new stream
+	stream, err := c.Stream(ctx, creq, opts...)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	// if we receive a grpc stream we have to refire the initial request
+	cc, ok := req.Codec().(codec.Codec)
+	if ok && cc.String() == "grpc" && c.String() == "grpc" {
+		// get the header from client
+		hdr := req.Header()
+		msg := &codec.Message{
+			Type:   codec.Request,
+			Header: hdr,
+			Body:   body,
+		}
+
+		// write the raw request
+		err = stream.Request().Codec().Write(msg, nil)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	// create client request read loop if streaming
+	go func() {
+		err := readLoop(req, stream)
+		if err != nil && err != io.EOF {
+			// cancel the context
+			cancel()
+		}
+	}()
+
+	// get raw response
+	resp := stream.Response()
+
+	// create server response write loop
+	for {
+		// read backend response body
+		body, err := resp.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// read backend response header
+		hdr := resp.Header()
+
+		// write raw response header to client
+		rsp.WriteHeader(hdr)
+
+		// write raw response body to client
+		err = rsp.Write(body)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// read client request and write to server
+func readLoop(r server.Request, s client.Stream) error {
+	// request to backend server
+	req := s.Request()
+
+	for {
+		// get data from client
+		//  no need to decode it
+		body, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// get the header from client
+		hdr := r.Header()
+		msg := &codec.Message{
+			Type:   codec.Request,
+			Header: hdr,
+			Body:   body,
+		}
+
+		// write the raw request
+		err = req.Codec().Write(msg, nil)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
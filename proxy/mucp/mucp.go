@@ -4,7 +4,6 @@ package mucp
 import (
 	"context"
 	"fmt"
-	"io"
 	"sort"
 	"strings"
 	"sync"
@@ -12,8 +11,6 @@ import (
 
 	"github.com/asim/go-micro/v3/client"
 	"github.com/asim/go-micro/v3/client/mucp"
-	"github.com/asim/go-micro/v3/codec"
-	"github.com/asim/go-micro/v3/codec/bytes"
 	"github.com/asim/go-micro/v3/errors"
 	"github.com/asim/go-micro/v3/logger"
 	"github.com/asim/go-micro/v3/metadata"
@@ -51,41 +48,6 @@ type Proxy struct {
 	Selector selector.Selector
 }
 
-// read client request and write to server
-func readLoop(r server.Request, s client.Stream) error {
-	// request to backend server
-	req := s.Request()
-
-	for {
-		// get data from client
-		//  no need to decode it
-		body, err := r.Read()
-		if err == io.EOF {
-			return nil
-		}
-
-		if err != nil {
-			return err
-		}
-
-		// get the header from client
-		hdr := r.Header()
-		msg := &codec.Message{
-			Type:   codec.Request,
-			Header: hdr,
-			Body:   body,
-		}
-
-		// write the raw request
-		err = req.Codec().Write(msg, nil)
-		if err == io.EOF {
-			return nil
-		} else if err != nil {
-			return err
-		}
-	}
-}
-
 // toNodes returns a list of node addresses from given routes
 func toNodes(routes []router.Route) []string {
 	nodes := make([]string, 0, len(routes))
@@ -491,98 +453,7 @@ func (p *Proxy) ServeRequest(ctx context.Context, req server.Request, rsp server
 }
 
 func (p *Proxy) serveRequest(ctx context.Context, link client.Client, service, endpoint string, req server.Request, rsp server.Response, opts ...client.CallOption) error {
-	// read initial request
-	body, err := req.Read()
-	if err != nil {
-		return err
-	}
-
-	// create new request with raw bytes body
-	creq := link.NewRequest(service, endpoint, &bytes.Frame{Data: body}, client.WithContentType(req.ContentType()))
-
-	// not a stream so make a client.Call request
-	if !req.Stream() {
-		crsp := new(bytes.Frame)
-
-		// make a call to the backend
-		if err := link.Call(ctx, creq, crsp, opts...); err != nil {
-			return err
-		}
-
-		// write the response
-		if err := rsp.Write(crsp.Data); err != nil {
-			return err
-		}
-
-		return nil
-	}
-
-	// new context with cancel
-	ctx, cancel := context.WithCancel(ctx)
-
-	// create new stream
-	stream, err := link.Stream(ctx, creq, opts...)
-	if err != nil {
-		return err
-	}
-	defer stream.Close()
-
-	// if we receive a grpc stream we have to refire the initial request
-	c, ok := req.Codec().(codec.Codec)
-	if ok && c.String() == "grpc" && link.String() == "grpc" {
-		// get the header from client
-		hdr := req.Header()
-		msg := &codec.Message{
-			Type:   codec.Request,
-			Header: hdr,
-			Body:   body,
-		}
-
-		// write the raw request
-		err = stream.Request().Codec().Write(msg, nil)
-		if err == io.EOF {
-			return nil
-		} else if err != nil {
-			return err
-		}
-	}
-
-	// create client request read loop if streaming
-	go func() {
-		err := readLoop(req, stream)
-		if err != nil && err != io.EOF {
-			// cancel the context
-			cancel()
-		}
-	}()
-
-	// get raw response
-	resp := stream.Response()
-
-	// create server response write loop
-	for {
-		// read backend response body
-		body, err := resp.Read()
-		if err == io.EOF {
-			return nil
-		} else if err != nil {
-			return err
-		}
-
-		// read backend response header
-		hdr := resp.Header()
-
-		// write raw response header to client
-		rsp.WriteHeader(hdr)
-
-		// write raw response body to client
-		err = rsp.Write(body)
-		if err == io.EOF {
-			return nil
-		} else if err != nil {
-			return err
-		}
-	}
+	return proxy.Forward(ctx, link, service, endpoint, req, rsp, opts...)
 }
 
 func (p *Proxy) String() string {
@@ -0,0 +1,386 @@
+// Package dns provides a registry resolving services from DNS records, such
+// as those of kubernetes headless services or consul. The nodes of a service
+// are read from its SRV records, or its A and AAAA records when it has none.
+// Records are managed outside of the registry so registering does nothing.
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/registry"
+	util "github.com/asim/go-micro/v3/util/registry"
+	"github.com/miekg/dns"
+)
+
+var (
+	// DefaultPort of nodes resolved from A or AAAA records
+	DefaultPort = 8080
+	// DefaultRefreshInterval of watchers
+	DefaultRefreshInterval = time.Second * 30
+	// DefaultTimeout of queries
+	DefaultTimeout = time.Second * 5
+	// DefaultResolvConf is read for the name servers when none are set
+	DefaultResolvConf = "/etc/resolv.conf"
+)
+
+type dnsRegistry struct {
+	sync.RWMutex
+	options  registry.Options
+	client   *dns.Client
+	tcp      *dns.Client
+	servers  []string
+	suffix   string
+	port     int
+	interval time.Duration
+	// resolved services by name until the TTL of their records expires
+	records map[string]*record
+}
+
+type record struct {
+	services []*registry.Service
+	expires  time.Time
+}
+
+func (d *dnsRegistry) configure() error {
+	timeout := d.options.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	var servers []string
+	for _, addr := range d.options.Addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		servers = append(servers, addr)
+	}
+	if len(servers) == 0 {
+		if c, err := dns.ClientConfigFromFile(DefaultResolvConf); err == nil {
+			for _, s := range c.Servers {
+				servers = append(servers, net.JoinHostPort(s, c.Port))
+			}
+		}
+	}
+	if len(servers) == 0 {
+		servers = []string{"127.0.0.1:53"}
+	}
+
+	suffix, _ := d.options.Context.Value(suffixKey{}).(string)
+
+	port, ok := d.options.Context.Value(portKey{}).(int)
+	if !ok {
+		port = DefaultPort
+	}
+
+	interval, ok := d.options.Context.Value(refreshIntervalKey{}).(time.Duration)
+	if !ok || interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	d.Lock()
+	d.client = &dns.Client{Timeout: timeout}
+	d.tcp = &dns.Client{Net: "tcp", Timeout: timeout}
+	d.servers = servers
+	d.suffix = strings.Trim(suffix, ".")
+	d.port = port
+	d.interval = interval
+	d.records = make(map[string]*record)
+	d.Unlock()
+
+	return nil
+}
+
+// fqdn returns the name resolved for the service
+func (d *dnsRegistry) fqdn(name string) string {
+	d.RLock()
+	defer d.RUnlock()
+
+	if len(d.suffix) == 0 {
+		return dns.Fqdn(name)
+	}
+	return dns.Fqdn(name + "." + d.suffix)
+}
+
+// exchange sends the query to the name servers in turn until one answers,
+// over tcp when the answer is truncated
+func (d *dnsRegistry) exchange(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	d.RLock()
+	client, tcp, servers := d.client, d.tcp, d.servers
+	d.RUnlock()
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+
+	var err error
+	for _, s := range servers {
+		var r *dns.Msg
+		r, _, err = client.ExchangeContext(ctx, m, s)
+		if err == nil && r.Truncated {
+			r, _, err = tcp.ExchangeContext(ctx, m, s)
+		}
+		if err != nil {
+			continue
+		}
+		switch r.Rcode {
+		case dns.RcodeSuccess, dns.RcodeNameError:
+			return r, nil
+		}
+		err = errors.New("dns query " + name + ": " + dns.RcodeToString[r.Rcode])
+	}
+
+	return nil, err
+}
+
+// addresses returns the ip addresses of the name from the A and AAAA records
+// of the answers, or by resolving it if there are none, and their min TTL
+func (d *dnsRegistry) addresses(ctx context.Context, name string, rrs []dns.RR) ([]string, uint32, error) {
+	var ips []string
+	var ttl uint32
+
+	add := func(rr dns.RR) {
+		if !strings.EqualFold(rr.Header().Name, name) {
+			return
+		}
+		switch r := rr.(type) {
+		case *dns.A:
+			ips = append(ips, r.A.String())
+		case *dns.AAAA:
+			ips = append(ips, r.AAAA.String())
+		default:
+			return
+		}
+		if ttl == 0 || rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+
+	for _, rr := range rrs {
+		add(rr)
+	}
+	if len(ips) > 0 {
+		return ips, ttl, nil
+	}
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		r, err := d.exchange(ctx, name, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, rr := range r.Answer {
+			add(rr)
+		}
+	}
+
+	return ips, ttl, nil
+}
+
+// resolve returns the nodes of the service and the min TTL of the records
+func (d *dnsRegistry) resolve(ctx context.Context, name string) ([]*registry.Service, time.Duration, error) {
+	fqdn := d.fqdn(name)
+
+	r, err := d.exchange(ctx, fqdn, dns.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var nodes []*registry.Node
+	var ttl uint32
+
+	min := func(t uint32) {
+		if ttl == 0 || t < ttl {
+			ttl = t
+		}
+	}
+
+	for _, rr := range r.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		min(srv.Hdr.Ttl)
+
+		ips, t, err := d.addresses(ctx, srv.Target, r.Extra)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(ips) > 0 {
+			min(t)
+		}
+
+		port := strconv.Itoa(int(srv.Port))
+		for _, ip := range ips {
+			addr := net.JoinHostPort(ip, port)
+			nodes = append(nodes, &registry.Node{
+				Id:      name + "-" + addr,
+				Address: addr,
+				Metadata: map[string]string{
+					"priority": strconv.Itoa(int(srv.Priority)),
+					"weight":   strconv.Itoa(int(srv.Weight)),
+				},
+			})
+		}
+	}
+
+	// services without SRV records listen on the default port
+	if len(nodes) == 0 {
+		ips, t, err := d.addresses(ctx, fqdn, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		min(t)
+
+		d.RLock()
+		port := strconv.Itoa(d.port)
+		d.RUnlock()
+
+		for _, ip := range ips {
+			addr := net.JoinHostPort(ip, port)
+			nodes = append(nodes, &registry.Node{
+				Id:       name + "-" + addr,
+				Address:  addr,
+				Metadata: map[string]string{},
+			})
+		}
+	}
+
+	if len(nodes) == 0 {
+		return nil, 0, registry.ErrNotFound
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Id < nodes[j].Id })
+
+	return []*registry.Service{{
+		Name:     name,
+		Metadata: map[string]string{},
+		Nodes:    nodes,
+	}}, time.Duration(ttl) * time.Second, nil
+}
+
+// lookup returns the services resolved until the TTL of their records
+// expires, they're resolved again once it has
+func (d *dnsRegistry) lookup(ctx context.Context, name string) ([]*registry.Service, error) {
+	d.RLock()
+	r, ok := d.records[name]
+	d.RUnlock()
+
+	if ok && time.Now().Before(r.expires) {
+		return util.Copy(r.services), nil
+	}
+
+	services, ttl, err := d.resolve(ctx, name)
+	if err == registry.ErrNotFound {
+		d.Lock()
+		delete(d.records, name)
+		d.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.Lock()
+	d.records[name] = &record{services: services, expires: time.Now().Add(ttl)}
+	d.Unlock()
+
+	return util.Copy(services), nil
+}
+
+// expires returns when the records of the service expire
+func (d *dnsRegistry) expires(name string) (time.Time, bool) {
+	d.RLock()
+	defer d.RUnlock()
+
+	r, ok := d.records[name]
+	if !ok {
+		return time.Time{}, false
+	}
+	return r.expires, true
+}
+
+// names returns the names of the services which were resolved
+func (d *dnsRegistry) names() []string {
+	d.RLock()
+	defer d.RUnlock()
+
+	names := make([]string, 0, len(d.records))
+	for name := range d.records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *dnsRegistry) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&d.options)
+	}
+	return d.configure()
+}
+
+func (d *dnsRegistry) Options() registry.Options {
+	return d.options
+}
+
+func (d *dnsRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	return nil
+}
+
+func (d *dnsRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	return nil
+}
+
+func (d *dnsRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	options := registry.GetOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return d.lookup(options.Context, name)
+}
+
+// ListServices returns the services which were resolved, DNS can't list the
+// names of services
+func (d *dnsRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	names := d.names()
+
+	services := make([]*registry.Service, 0, len(names))
+	for _, name := range names {
+		services = append(services, &registry.Service{Name: name})
+	}
+	return services, nil
+}
+
+func (d *dnsRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	options := registry.WatchOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return newWatcher(d, options), nil
+}
+
+func (d *dnsRegistry) String() string {
+	return "dns"
+}
+
+// NewRegistry returns a registry resolving services from DNS, using the name
+// servers of /etc/resolv.conf if no address is set
+func NewRegistry(opts ...registry.Option) registry.Registry {
+	d := &dnsRegistry{
+		options: registry.Options{
+			Context: context.Background(),
+		},
+	}
+	d.Init(opts...)
+	return d
+}
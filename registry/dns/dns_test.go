@@ -0,0 +1,190 @@
+package dns
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/miekg/dns"
+)
+
+// server is a name server answering from its records
+type server struct {
+	sync.Mutex
+	records []string
+	queries int
+}
+
+func (s *server) set(records ...string) {
+	s.Lock()
+	s.records = records
+	s.Unlock()
+}
+
+func (s *server) count() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.queries
+}
+
+func (s *server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	s.Lock()
+	defer s.Unlock()
+	s.queries++
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	q := req.Question[0]
+	for _, r := range s.records {
+		rr, err := dns.NewRR(r)
+		if err != nil {
+			continue
+		}
+		if rr.Header().Name == q.Name && rr.Header().Rrtype == q.Qtype {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	if len(m.Answer) == 0 {
+		m.Rcode = dns.RcodeNameError
+	}
+
+	w.WriteMsg(m)
+}
+
+func newServer(t *testing.T) (*server, string, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &server{}
+	srv := &dns.Server{PacketConn: pc, Handler: s}
+	go srv.ActivateAndServe()
+
+	return s, pc.LocalAddr().String(), func() { srv.Shutdown() }
+}
+
+func addrs(t *testing.T, r registry.Registry, name string) []string {
+	services, err := r.GetService(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Name != name {
+		t.Fatalf("unexpected services %+v", services)
+	}
+
+	var addrs []string
+	for _, n := range services[0].Nodes {
+		addrs = append(addrs, n.Address)
+	}
+	return addrs
+}
+
+func TestDNSRegistry(t *testing.T) {
+	s, addr, stop := newServer(t)
+	defer stop()
+
+	s.set(
+		"_grpc._tcp.foo.default.svc.cluster.local. 60 IN SRV 0 50 9090 foo-0.foo.default.svc.cluster.local.",
+		"foo-0.foo.default.svc.cluster.local. 60 IN A 10.0.0.1",
+		"bar.default.svc.cluster.local. 60 IN A 10.0.0.2",
+		"bar.default.svc.cluster.local. 60 IN AAAA ::1",
+	)
+
+	r := NewRegistry(registry.Addrs(addr), Suffix("default.svc.cluster.local"), Port(8080))
+
+	// nodes of SRV records use their port
+	if got := addrs(t, r, "_grpc._tcp.foo"); len(got) != 1 || got[0] != "10.0.0.1:9090" {
+		t.Fatalf("expected the SRV node got %v", got)
+	}
+
+	// and the port set otherwise
+	if got := addrs(t, r, "bar"); len(got) != 2 || got[0] != "10.0.0.2:8080" || got[1] != "[::1]:8080" {
+		t.Fatalf("expected the A and AAAA nodes got %v", got)
+	}
+
+	if _, err := r.GetService("baz"); err != registry.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+
+	services, err := r.ListServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].Name != "_grpc._tcp.foo" || services[1].Name != "bar" {
+		t.Fatalf("expected the resolved services got %+v", services)
+	}
+
+	// registering is a noop
+	if err := r.Register(&registry.Service{Name: "baz", Nodes: []*registry.Node{{Id: "1"}}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDNSRegistryTTL(t *testing.T) {
+	s, addr, stop := newServer(t)
+	defer stop()
+
+	s.set("foo. 1 IN A 10.0.0.1")
+
+	r := NewRegistry(registry.Addrs(addr))
+
+	addrs(t, r, "foo")
+	queries := s.count()
+
+	// the records are cached for their ttl
+	s.set("foo. 1 IN A 10.0.0.2")
+	if got := addrs(t, r, "foo"); got[0] != "10.0.0.1:8080" || s.count() != queries {
+		t.Fatalf("expected the cached node got %v", got)
+	}
+
+	time.Sleep(time.Millisecond * 1100)
+
+	if got := addrs(t, r, "foo"); got[0] != "10.0.0.2:8080" {
+		t.Fatalf("expected the node to be resolved again got %v", got)
+	}
+}
+
+func TestDNSWatcher(t *testing.T) {
+	s, addr, stop := newServer(t)
+	defer stop()
+
+	s.set("foo. 0 IN A 10.0.0.1")
+
+	r := NewRegistry(registry.Addrs(addr), RefreshInterval(time.Millisecond*10))
+
+	w, err := r.Watch(registry.WatchService("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	next := func(action, addr string) {
+		results := make(chan *registry.Result, 1)
+		go func() {
+			r, _ := w.Next()
+			results <- r
+		}()
+
+		select {
+		case r := <-results:
+			if r == nil || r.Action != action || r.Service.Nodes[0].Address != addr {
+				t.Fatalf("expected %s of %s got %+v", action, addr, r)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s of %s", action, addr)
+		}
+	}
+
+	s.set("foo. 0 IN A 10.0.0.2")
+	next("update", "10.0.0.2:8080")
+
+	s.set()
+	next("delete", "10.0.0.2:8080")
+
+	s.set("foo. 0 IN A 10.0.0.3")
+	next("create", "10.0.0.3:8080")
+}
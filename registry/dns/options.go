@@ -0,0 +1,39 @@
+package dns
+
+import (
+	"context"
+	"time"
+
+	"github.com/asim/go-micro/v3/registry"
+)
+
+type suffixKey struct{}
+type portKey struct{}
+type refreshIntervalKey struct{}
+
+func setRegistryOption(k, v interface{}) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// Suffix sets the domain appended to the service names to resolve them e.g
+// default.svc.cluster.local for kubernetes or service.consul for consul
+func Suffix(s string) registry.Option {
+	return setRegistryOption(suffixKey{}, s)
+}
+
+// Port sets the port of the nodes of services resolved from A or AAAA
+// records when they have no SRV records, DefaultPort by default
+func Port(p int) registry.Option {
+	return setRegistryOption(portKey{}, p)
+}
+
+// RefreshInterval sets how often watchers resolve the services again,
+// sooner when the TTL of the records expires
+func RefreshInterval(d time.Duration) registry.Option {
+	return setRegistryOption(refreshIntervalKey{}, d)
+}
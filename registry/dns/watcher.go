@@ -0,0 +1,148 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/registry"
+)
+
+// watcher resolves the services on the refresh interval, or when the TTL of
+// their records expires, and compares them with the previous ones. Without a
+// service it watches those which were resolved.
+type watcher struct {
+	registry *dnsRegistry
+	opts     registry.WatchOptions
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results chan *registry.Result
+	// services by name
+	services map[string]*registry.Service
+}
+
+func newWatcher(d *dnsRegistry, opts registry.WatchOptions) *watcher {
+	ctx, cancel := context.WithCancel(opts.Context)
+
+	w := &watcher{
+		registry: d,
+		opts:     opts,
+		ctx:      ctx,
+		cancel:   cancel,
+		results:  make(chan *registry.Result),
+		services: make(map[string]*registry.Service),
+	}
+
+	for _, name := range w.names() {
+		if svcs, err := d.lookup(ctx, name); err == nil {
+			w.services[name] = svcs[0]
+		}
+	}
+
+	go w.run()
+
+	return w
+}
+
+// names returns the names of the services watched
+func (w *watcher) names() []string {
+	if len(w.opts.Service) > 0 {
+		return []string{w.opts.Service}
+	}
+	return w.registry.names()
+}
+
+// next returns the delay until the services are resolved again
+func (w *watcher) next() time.Duration {
+	w.registry.RLock()
+	d := w.registry.interval
+	w.registry.RUnlock()
+
+	now := time.Now()
+	for _, name := range w.names() {
+		expires, ok := w.registry.expires(name)
+		if !ok {
+			continue
+		}
+		// records with a ttl of zero are resolved on the interval
+		if e := expires.Sub(now); e > 0 && e < d {
+			d = e
+		}
+	}
+
+	return d
+}
+
+func (w *watcher) run() {
+	t := time.NewTimer(w.next())
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-w.ctx.Done():
+			return
+		}
+
+		var results []*registry.Result
+
+		for _, name := range w.names() {
+			prev, ok := w.services[name]
+
+			svcs, err := w.registry.lookup(w.ctx, name)
+			switch {
+			case err == registry.ErrNotFound:
+				if ok {
+					results = append(results, &registry.Result{Action: "delete", Service: prev})
+					delete(w.services, name)
+				}
+			case err != nil:
+				if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+					logger.Debugf("dns registry watch %s: %v", name, err)
+				}
+			case !ok:
+				results = append(results, &registry.Result{Action: "create", Service: svcs[0]})
+				w.services[name] = svcs[0]
+			case !equal(prev, svcs[0]):
+				results = append(results, &registry.Result{Action: "update", Service: svcs[0]})
+				w.services[name] = svcs[0]
+			}
+		}
+
+		for _, r := range results {
+			// filter the version and metadata
+			if r = registry.Filter(r, w.opts); r == nil {
+				continue
+			}
+
+			select {
+			case w.results <- r:
+			case <-w.ctx.Done():
+				return
+			}
+		}
+
+		t.Reset(w.next())
+	}
+}
+
+func equal(a, b *registry.Service) bool {
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	return string(ab) == string(bb)
+}
+
+func (w *watcher) Next() (*registry.Result, error) {
+	select {
+	case r := <-w.results:
+		return r, nil
+	case <-w.ctx.Done():
+		return nil, registry.ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() {
+	w.cancel()
+}
@@ -0,0 +1,385 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// num is an int64 of the api, encoded as a string by the json gateway
+type num int64
+
+func (n num) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatInt(int64(n), 10))), nil
+}
+
+func (n *num) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if len(s) == 0 || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = num(v)
+	return nil
+}
+
+type keyValue struct {
+	Key            []byte `json:"key"`
+	Value          []byte `json:"value"`
+	CreateRevision num    `json:"create_revision"`
+	ModRevision    num    `json:"mod_revision"`
+	Lease          num    `json:"lease"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type rangeResponse struct {
+	Kvs []*keyValue `json:"kvs"`
+}
+
+type putRequest struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+	Lease num    `json:"lease,omitempty"`
+}
+
+type deleteRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type requestOp struct {
+	RequestPut         *putRequest         `json:"request_put,omitempty"`
+	RequestDeleteRange *deleteRangeRequest `json:"request_delete_range,omitempty"`
+}
+
+type txnRequest struct {
+	Success []*requestOp `json:"success"`
+}
+
+type txnResponse struct {
+	Succeeded bool `json:"succeeded"`
+}
+
+type lease struct {
+	ID  num `json:"ID"`
+	TTL num `json:"TTL"`
+}
+
+type watchCreateRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+	PrevKv   bool   `json:"prev_kv"`
+}
+
+type watchRequest struct {
+	CreateRequest *watchCreateRequest `json:"create_request"`
+}
+
+type watchEvent struct {
+	// Type is PUT or DELETE
+	Type   string    `json:"type"`
+	Kv     *keyValue `json:"kv"`
+	PrevKv *keyValue `json:"prev_kv"`
+}
+
+type watchResponse struct {
+	Created  bool          `json:"created"`
+	Canceled bool          `json:"canceled"`
+	Events   []*watchEvent `json:"events"`
+}
+
+// streamError is the error of a stream of the gateway
+type streamError struct {
+	Message string `json:"message"`
+}
+
+type authResponse struct {
+	Token string `json:"token"`
+}
+
+// client is a minimal client of the etcd v3 json gateway used by the
+// registry
+type client struct {
+	hosts    []string
+	timeout  time.Duration
+	http     *http.Client
+	username string
+	password string
+
+	sync.Mutex
+	// token of the authenticated user
+	token string
+}
+
+func newClient(addrs []string, username, password string, secure bool, config *tls.Config, timeout time.Duration) *client {
+	var hosts []string
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "://") {
+			if secure || config != nil {
+				addr = "https://" + addr
+			} else {
+				addr = "http://" + addr
+			}
+		}
+		hosts = append(hosts, strings.TrimSuffix(addr, "/"))
+	}
+
+	return &client{
+		hosts:    hosts,
+		timeout:  timeout,
+		username: username,
+		password: password,
+		http: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: config,
+			},
+		},
+	}
+}
+
+// prefixEnd returns the end of the range of the keys with the prefix
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// the range of all the keys
+	return []byte{0}
+}
+
+func (c *client) do(ctx context.Context, path string, body, out interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	rsp, err := c.request(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(rsp.Body).Decode(out)
+}
+
+// request posts the body to the endpoints in turn until one responds
+func (c *client) request(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, host := range c.hosts {
+		var req *http.Request
+		req, err = http.NewRequest("POST", host+path, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		if len(token) > 0 {
+			req.Header.Set("Authorization", token)
+		}
+
+		var rsp *http.Response
+		rsp, err = c.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if rsp.StatusCode >= 300 {
+			defer rsp.Body.Close()
+			var s streamError
+			b, _ := ioutil.ReadAll(rsp.Body)
+			if err := json.Unmarshal(b, &s); err != nil || len(s.Message) == 0 {
+				s.Message = string(b)
+			}
+			// the token is requested again once it expires
+			if rsp.StatusCode == http.StatusUnauthorized {
+				c.Lock()
+				c.token = ""
+				c.Unlock()
+			}
+			return nil, fmt.Errorf("etcd %s: %d %s", path, rsp.StatusCode, s.Message)
+		}
+
+		return rsp, nil
+	}
+
+	return nil, err
+}
+
+// authenticate returns the token of the user, none without a user
+func (c *client) authenticate(ctx context.Context) (string, error) {
+	if len(c.username) == 0 {
+		return "", nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.token) > 0 {
+		return c.token, nil
+	}
+
+	b, _ := json.Marshal(map[string]string{"name": c.username, "password": c.password})
+
+	var err error
+	for _, host := range c.hosts {
+		var req *http.Request
+		req, err = http.NewRequest("POST", host+"/v3/auth/authenticate", bytes.NewReader(b))
+		if err != nil {
+			return "", err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		var rsp *http.Response
+		rsp, err = c.http.Do(req)
+		if err != nil {
+			continue
+		}
+
+		var a authResponse
+		err = json.NewDecoder(rsp.Body).Decode(&a)
+		rsp.Body.Close()
+		if err == nil && (rsp.StatusCode >= 300 || len(a.Token) == 0) {
+			err = fmt.Errorf("etcd authenticate: %d", rsp.StatusCode)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		c.token = a.Token
+		return c.token, nil
+	}
+
+	return "", err
+}
+
+// get returns the keys with the prefix
+func (c *client) get(ctx context.Context, prefix string) ([]*keyValue, error) {
+	var rsp rangeResponse
+	if err := c.do(ctx, "/v3/kv/range", &rangeRequest{Key: []byte(prefix), RangeEnd: prefixEnd(prefix)}, &rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Kvs, nil
+}
+
+// txn applies the operations atomically
+func (c *client) txn(ctx context.Context, ops []*requestOp) error {
+	var rsp txnResponse
+	if err := c.do(ctx, "/v3/kv/txn", &txnRequest{Success: ops}, &rsp); err != nil {
+		return err
+	}
+	if !rsp.Succeeded {
+		return errors.New("etcd transaction failed")
+	}
+	return nil
+}
+
+// grant returns a lease expiring after the ttl unless it's kept alive
+func (c *client) grant(ctx context.Context, ttl time.Duration) (int64, error) {
+	var rsp lease
+	if err := c.do(ctx, "/v3/lease/grant", &lease{TTL: num(ttl.Seconds())}, &rsp); err != nil {
+		return 0, err
+	}
+	return int64(rsp.ID), nil
+}
+
+// keepAlive renews the lease, it returns false if it has expired
+func (c *client) keepAlive(ctx context.Context, id int64) (bool, error) {
+	var rsp struct {
+		Result *lease       `json:"result"`
+		Error  *streamError `json:"error"`
+	}
+	if err := c.do(ctx, "/v3/lease/keepalive", &lease{ID: num(id)}, &rsp); err != nil {
+		return false, err
+	}
+	if rsp.Error != nil {
+		return false, errors.New("etcd keepalive: " + rsp.Error.Message)
+	}
+	return rsp.Result != nil && rsp.Result.TTL > 0, nil
+}
+
+// revoke the lease, deleting its keys
+func (c *client) revoke(ctx context.Context, id int64) error {
+	return c.do(ctx, "/v3/lease/revoke", &lease{ID: num(id)}, nil)
+}
+
+// watch calls fn with the events of the keys with the prefix until the
+// context is done or the stream ends, created is called once it's watching
+func (c *client) watch(ctx context.Context, prefix string, created func(), fn func(*watchEvent)) error {
+	rsp, err := c.request(ctx, "/v3/watch", &watchRequest{CreateRequest: &watchCreateRequest{
+		Key:      []byte(prefix),
+		RangeEnd: prefixEnd(prefix),
+		PrevKv:   true,
+	}})
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	dec := json.NewDecoder(rsp.Body)
+	for {
+		var msg struct {
+			Result *watchResponse `json:"result"`
+			Error  *streamError   `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return errors.New("etcd watch: " + msg.Error.Message)
+		}
+		if msg.Result == nil {
+			continue
+		}
+		if msg.Result.Canceled {
+			return errors.New("etcd watch canceled")
+		}
+		if msg.Result.Created {
+			created()
+		}
+		for _, e := range msg.Result.Events {
+			fn(e)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+// Package etcd provides a registry using etcd v3. The nodes are stored as
+// keys of the prefix, domain and service, attached to leases of the TTL they
+// are registered with so they expire unless they're registered again. The
+// registry talks to the json gateway of etcd, served on its client port.
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/registry"
+)
+
+var (
+	// DefaultAddress of etcd
+	DefaultAddress = "127.0.0.1:2379"
+	// DefaultPrefix of the keys
+	DefaultPrefix = "/micro/registry/"
+	// DefaultTimeout of requests
+	DefaultTimeout = time.Second * 5
+)
+
+type etcdRegistry struct {
+	sync.RWMutex
+	options registry.Options
+	client  *client
+	prefix  string
+	// leases of the registered nodes by key
+	leases map[string]int64
+	// values of the registered nodes by key, they're put again on changes
+	values map[string]string
+}
+
+func (e *etcdRegistry) configure() error {
+	addrs := e.options.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{DefaultAddress}
+	}
+
+	timeout := e.options.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	var username, password string
+	if a, ok := e.options.Context.Value(authKey{}).(*authCreds); ok {
+		username, password = a.Username, a.Password
+	}
+
+	prefix, ok := e.options.Context.Value(prefixKey{}).(string)
+	if !ok || len(prefix) == 0 {
+		prefix = DefaultPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	e.Lock()
+	e.client = newClient(addrs, username, password, e.options.Secure, e.options.TLSConfig, timeout)
+	e.prefix = prefix
+	e.Unlock()
+
+	return nil
+}
+
+// domain returns the domain of a call, the domain of the registry if it's
+// set or the default domain if neither is
+func (e *etcdRegistry) domain(d string) string {
+	if len(e.options.Domain) > 0 {
+		return e.options.Domain
+	}
+	if len(d) == 0 {
+		return registry.DefaultDomain
+	}
+	return d
+}
+
+// path returns the prefix of the keys of the domain and service, all the
+// domains or services if wildcard or empty
+func (e *etcdRegistry) path(domain, service string) string {
+	e.RLock()
+	defer e.RUnlock()
+
+	if domain == registry.WildcardDomain {
+		return e.prefix
+	}
+	if len(service) == 0 {
+		return e.prefix + domain + "/"
+	}
+	return e.prefix + domain + "/" + service + "/"
+}
+
+func (e *etcdRegistry) get() *client {
+	e.RLock()
+	defer e.RUnlock()
+	return e.client
+}
+
+// decode returns the service of the node stored at the key, with the domain
+// of the key in its metadata
+func (e *etcdRegistry) decode(kv *keyValue) *registry.Service {
+	var s *registry.Service
+	if err := json.Unmarshal(kv.Value, &s); err != nil || s == nil {
+		return nil
+	}
+
+	e.RLock()
+	prefix := e.prefix
+	e.RUnlock()
+
+	md := make(map[string]string, len(s.Metadata)+1)
+	for k, v := range s.Metadata {
+		md[k] = v
+	}
+	if parts := strings.SplitN(strings.TrimPrefix(string(kv.Key), prefix), "/", 2); len(parts) == 2 {
+		md["domain"] = parts[0]
+	}
+	s.Metadata = md
+
+	return s
+}
+
+// versions returns the versions of the services of the nodes, of the
+// service if set
+func (e *etcdRegistry) versions(kvs []*keyValue, service string) []*registry.Service {
+	versions := make(map[string]*registry.Service)
+
+	for _, kv := range kvs {
+		s := e.decode(kv)
+		if s == nil || len(service) > 0 && s.Name != service {
+			continue
+		}
+
+		id := s.Metadata["domain"] + "/" + s.Name + ":" + s.Version
+		if prev, ok := versions[id]; ok {
+			prev.Nodes = append(prev.Nodes, s.Nodes...)
+			continue
+		}
+		versions[id] = s
+	}
+
+	services := make([]*registry.Service, 0, len(versions))
+	for _, s := range versions {
+		sort.Slice(s.Nodes, func(i, j int) bool { return s.Nodes[i].Id < s.Nodes[j].Id })
+		services = append(services, s)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name == services[j].Name {
+			return services[i].Version < services[j].Version
+		}
+		return services[i].Name < services[j].Name
+	})
+
+	return services
+}
+
+func (e *etcdRegistry) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&e.options)
+	}
+	if e.options.Tombstone > 0 && logger.V(logger.WarnLevel, logger.DefaultLogger) {
+		logger.Warnf("etcd registry: tombstones are only kept by the memory registry, the Tombstone option is ignored")
+	}
+	return e.configure()
+}
+
+func (e *etcdRegistry) Options() registry.Options {
+	return e.options
+}
+
+// Register puts the nodes in a transaction, attached to leases of the TTL.
+// Nodes registered again without changes keep their lease alive.
+func (e *etcdRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("require at least one node")
+	}
+
+	options := registry.RegisterOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Context == nil {
+		options.Context = context.Background()
+	}
+
+	c := e.get()
+	path := e.path(e.domain(options.Domain), s.Name)
+
+	var ops []*requestOp
+	leases := make(map[string]int64)
+	values := make(map[string]string)
+
+	for _, n := range s.Nodes {
+		key := path + n.Id
+
+		b, err := json.Marshal(&registry.Service{
+			Name:      s.Name,
+			Version:   s.Version,
+			Metadata:  s.Metadata,
+			Endpoints: s.Endpoints,
+			Nodes:     []*registry.Node{n},
+		})
+		if err != nil {
+			return err
+		}
+
+		e.RLock()
+		id, leased := e.leases[key]
+		prev, registered := e.values[key]
+		e.RUnlock()
+
+		if leased && options.TTL > 0 {
+			alive, err := c.keepAlive(options.Context, id)
+			if err != nil {
+				return err
+			}
+			// the node is put again under a new lease once it expired
+			leased = alive
+		}
+
+		if registered && prev == string(b) && (leased || options.TTL == 0 && id == 0) {
+			continue
+		}
+
+		id = 0
+		if options.TTL > 0 {
+			if id, err = c.grant(options.Context, options.TTL); err != nil {
+				return err
+			}
+		}
+
+		ops = append(ops, &requestOp{RequestPut: &putRequest{Key: []byte(key), Value: b, Lease: num(id)}})
+		leases[key] = id
+		values[key] = string(b)
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if err := c.txn(options.Context, ops); err != nil {
+		return err
+	}
+
+	e.Lock()
+	for key, id := range leases {
+		e.leases[key] = id
+		e.values[key] = values[key]
+	}
+	e.Unlock()
+
+	return nil
+}
+
+// Deregister deletes the nodes in a transaction and revokes their leases
+func (e *etcdRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("require at least one node")
+	}
+
+	options := registry.DeregisterOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Context == nil {
+		options.Context = context.Background()
+	}
+
+	c := e.get()
+	path := e.path(e.domain(options.Domain), s.Name)
+
+	ops := make([]*requestOp, 0, len(s.Nodes))
+	for _, n := range s.Nodes {
+		ops = append(ops, &requestOp{RequestDeleteRange: &deleteRangeRequest{Key: []byte(path + n.Id)}})
+	}
+
+	if err := c.txn(options.Context, ops); err != nil {
+		return err
+	}
+
+	var revoke []int64
+
+	e.Lock()
+	for _, n := range s.Nodes {
+		key := path + n.Id
+		if id := e.leases[key]; id > 0 {
+			revoke = append(revoke, id)
+		}
+		delete(e.leases, key)
+		delete(e.values, key)
+	}
+	e.Unlock()
+
+	for _, id := range revoke {
+		c.revoke(options.Context, id)
+	}
+
+	for _, fn := range e.options.OnDeregister {
+		fn(s)
+	}
+
+	return nil
+}
+
+func (e *etcdRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	options := registry.GetOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Context == nil {
+		options.Context = context.Background()
+	}
+
+	kvs, err := e.get().get(options.Context, e.path(e.domain(options.Domain), name))
+	if err != nil {
+		return nil, err
+	}
+
+	services := e.versions(kvs, name)
+	if len(services) == 0 {
+		return nil, registry.ErrNotFound
+	}
+
+	return services, nil
+}
+
+func (e *etcdRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	options := registry.ListOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Context == nil {
+		options.Context = context.Background()
+	}
+
+	kvs, err := e.get().get(options.Context, e.path(e.domain(options.Domain), ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return e.versions(kvs, ""), nil
+}
+
+func (e *etcdRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	options := registry.WatchOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Context == nil {
+		options.Context = context.Background()
+	}
+
+	domain := e.domain(options.Domain)
+	service := options.Service
+	if domain == registry.WildcardDomain {
+		service = ""
+	}
+
+	return newWatcher(e, e.path(domain, service), options), nil
+}
+
+func (e *etcdRegistry) String() string {
+	return "etcd"
+}
+
+// NewRegistry returns a registry using etcd, at DefaultAddress if no
+// address is set
+func NewRegistry(opts ...registry.Option) registry.Registry {
+	e := &etcdRegistry{
+		options: registry.Options{
+			Context: context.Background(),
+		},
+		leases: make(map[string]int64),
+		values: make(map[string]string),
+	}
+	e.Init(opts...)
+	return e
+}
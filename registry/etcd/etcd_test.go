@@ -0,0 +1,317 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/registry"
+)
+
+// server is a fake of the etcd json gateway
+type server struct {
+	sync.Mutex
+	revision int64
+	kvs      map[string]*keyValue
+	// expiry of the leases by id
+	leases   map[int64]time.Time
+	ttls     map[int64]time.Duration
+	watchers []chan *watchEvent
+	txns     int
+}
+
+func newServer() *server {
+	return &server{
+		kvs:    make(map[string]*keyValue),
+		leases: make(map[int64]time.Time),
+		ttls:   make(map[int64]time.Duration),
+	}
+}
+
+// expire deletes the keys of the expired leases, it's called with the lock
+// held
+func (s *server) expire() {
+	for id, t := range s.leases {
+		if time.Now().Before(t) {
+			continue
+		}
+		delete(s.leases, id)
+		for k, kv := range s.kvs {
+			if int64(kv.Lease) == id {
+				s.remove(k)
+			}
+		}
+	}
+}
+
+func (s *server) remove(k string) {
+	prev, ok := s.kvs[k]
+	if !ok {
+		return
+	}
+	s.revision++
+	delete(s.kvs, k)
+	s.notify(&watchEvent{Type: "DELETE", Kv: &keyValue{Key: []byte(k), ModRevision: num(s.revision)}, PrevKv: prev})
+}
+
+func (s *server) notify(e *watchEvent) {
+	for _, w := range s.watchers {
+		select {
+		case w <- e:
+		default:
+		}
+	}
+}
+
+func (s *server) stats() (txns, keys int) {
+	s.Lock()
+	defer s.Unlock()
+	return s.txns, len(s.kvs)
+}
+
+func inRange(key string, start, end []byte) bool {
+	return bytes.Compare([]byte(key), start) >= 0 && (len(end) == 0 || bytes.Compare([]byte(key), end) < 0)
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v3/watch" {
+		s.watch(w, r)
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.expire()
+
+	enc := json.NewEncoder(w)
+
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var req rangeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var rsp rangeResponse
+		for k, kv := range s.kvs {
+			if inRange(k, req.Key, req.RangeEnd) {
+				rsp.Kvs = append(rsp.Kvs, kv)
+			}
+		}
+		enc.Encode(&rsp)
+	case "/v3/kv/txn":
+		var req txnRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		s.txns++
+
+		for _, op := range req.Success {
+			switch {
+			case op.RequestPut != nil:
+				if op.RequestPut.Lease > 0 {
+					if _, ok := s.leases[int64(op.RequestPut.Lease)]; !ok {
+						w.WriteHeader(http.StatusBadRequest)
+						enc.Encode(&streamError{Message: "etcdserver: requested lease not found"})
+						return
+					}
+				}
+			}
+		}
+
+		for _, op := range req.Success {
+			switch {
+			case op.RequestPut != nil:
+				s.revision++
+				k := string(op.RequestPut.Key)
+				kv := &keyValue{
+					Key:            op.RequestPut.Key,
+					Value:          op.RequestPut.Value,
+					CreateRevision: num(s.revision),
+					ModRevision:    num(s.revision),
+					Lease:          op.RequestPut.Lease,
+				}
+				prev, ok := s.kvs[k]
+				if ok {
+					kv.CreateRevision = prev.CreateRevision
+				}
+				s.kvs[k] = kv
+				s.notify(&watchEvent{Type: "PUT", Kv: kv, PrevKv: prev})
+			case op.RequestDeleteRange != nil:
+				s.remove(string(op.RequestDeleteRange.Key))
+			}
+		}
+		enc.Encode(&txnResponse{Succeeded: true})
+	case "/v3/lease/grant":
+		var req lease
+		json.NewDecoder(r.Body).Decode(&req)
+
+		s.revision++
+		id := s.revision
+		s.ttls[id] = time.Duration(req.TTL) * time.Second
+		s.leases[id] = time.Now().Add(s.ttls[id])
+		enc.Encode(&lease{ID: num(id), TTL: req.TTL})
+	case "/v3/lease/keepalive":
+		var req lease
+		json.NewDecoder(r.Body).Decode(&req)
+
+		rsp := &lease{ID: req.ID}
+		if _, ok := s.leases[int64(req.ID)]; ok {
+			s.leases[int64(req.ID)] = time.Now().Add(s.ttls[int64(req.ID)])
+			rsp.TTL = num(s.ttls[int64(req.ID)].Seconds())
+		}
+		enc.Encode(map[string]interface{}{"result": rsp})
+	case "/v3/lease/revoke":
+		var req lease
+		json.NewDecoder(r.Body).Decode(&req)
+
+		s.leases[int64(req.ID)] = time.Time{}
+		s.expire()
+		enc.Encode(map[string]interface{}{})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *server) watch(w http.ResponseWriter, r *http.Request) {
+	var req watchRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	ch := make(chan *watchEvent, 10)
+	s.Lock()
+	s.watchers = append(s.watchers, ch)
+	s.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.Encode(map[string]interface{}{"result": &watchResponse{Created: true}})
+	w.(http.Flusher).Flush()
+
+	for {
+		select {
+		case e := <-ch:
+			if !inRange(string(e.Kv.Key), req.CreateRequest.Key, req.CreateRequest.RangeEnd) {
+				continue
+			}
+			enc.Encode(map[string]interface{}{"result": &watchResponse{Events: []*watchEvent{e}}})
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func service(version string, nodes ...string) *registry.Service {
+	s := &registry.Service{Name: "foo", Version: version}
+	for _, n := range nodes {
+		s.Nodes = append(s.Nodes, &registry.Node{Id: n, Address: n + ":8080"})
+	}
+	return s
+}
+
+func TestEtcdRegistry(t *testing.T) {
+	s := newServer()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	r := NewRegistry(registry.Addrs(srv.URL))
+
+	w, err := r.Watch(registry.WatchService("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	next := func(action, id string) {
+		results := make(chan *registry.Result, 1)
+		go func() {
+			res, _ := w.Next()
+			results <- res
+		}()
+
+		select {
+		case res := <-results:
+			if res == nil || res.Action != action || res.Service.Nodes[0].Id != id {
+				t.Fatalf("expected %s of %s got %+v", action, id, res)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s of %s", action, id)
+		}
+	}
+
+	// the nodes are put in one transaction
+	if err := r.Register(service("1.0.0", "foo-1", "foo-2"), registry.RegisterTTL(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if txns, keys := s.stats(); txns != 1 || keys != 2 {
+		t.Fatalf("expected the nodes to be put in a transaction got %d txns %d keys", txns, keys)
+	}
+	next("create", "foo-1")
+	next("create", "foo-2")
+
+	if err := r.Register(service("2.0.0", "foo-3")); err != nil {
+		t.Fatal(err)
+	}
+	next("create", "foo-3")
+
+	services, err := r.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || len(services[0].Nodes) != 2 || services[1].Version != "2.0.0" {
+		t.Fatalf("unexpected services %+v", services)
+	}
+	if services[0].Metadata["domain"] != registry.DefaultDomain {
+		t.Fatalf("expected the domain in the metadata got %v", services[0].Metadata)
+	}
+
+	// registering again without changes only keeps the lease alive
+	if err := r.Register(service("1.0.0", "foo-1", "foo-2"), registry.RegisterTTL(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if txns, _ := s.stats(); txns != 2 {
+		t.Fatalf("expected no transaction got %d", txns)
+	}
+
+	if err := r.Deregister(service("1.0.0", "foo-1")); err != nil {
+		t.Fatal(err)
+	}
+	next("delete", "foo-1")
+
+	if _, err := r.GetService("foo", registry.GetDomain("other")); err != registry.ErrNotFound {
+		t.Fatalf("expected not found in another domain got %v", err)
+	}
+
+	list, err := r.ListServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "foo" {
+		t.Fatalf("unexpected services %+v", list)
+	}
+}
+
+func TestEtcdRegistryLease(t *testing.T) {
+	s := newServer()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	r := NewRegistry(registry.Addrs(srv.URL))
+
+	if err := r.Register(service("1.0.0", "foo-1"), registry.RegisterTTL(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lease expires without a keepalive
+	time.Sleep(time.Millisecond * 1100)
+	if _, err := r.GetService("foo"); err != registry.ErrNotFound {
+		t.Fatalf("expected the node to expire got %v", err)
+	}
+
+	// and the node is put under a new lease when registered again
+	if err := r.Register(service("1.0.0", "foo-1"), registry.RegisterTTL(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetService("foo"); err != nil {
+		t.Fatal(err)
+	}
+}
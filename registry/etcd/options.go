@@ -0,0 +1,35 @@
+package etcd
+
+import (
+	"context"
+
+	"github.com/asim/go-micro/v3/registry"
+)
+
+type authKey struct{}
+type prefixKey struct{}
+
+type authCreds struct {
+	Username string
+	Password string
+}
+
+func setRegistryOption(k, v interface{}) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// Auth sets the user authenticating to etcd
+func Auth(username, password string) registry.Option {
+	return setRegistryOption(authKey{}, &authCreds{Username: username, Password: password})
+}
+
+// Prefix sets the prefix of the keys of the registry, DefaultPrefix by
+// default
+func Prefix(p string) registry.Option {
+	return setRegistryOption(prefixKey{}, p)
+}
@@ -0,0 +1,153 @@
+package etcd
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/util/backoff"
+)
+
+var (
+	// DefaultWatchBackoff between the attempts to watch again, jittered so
+	// the watchers of many services don't retry together
+	DefaultWatchBackoff = backoff.ExpJitter(time.Millisecond*100, time.Minute)
+)
+
+// watcher watches the keys of the nodes, the watch is started again when
+// etcd closes it
+type watcher struct {
+	registry *etcdRegistry
+	path     string
+	opts     registry.WatchOptions
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results chan *registry.Result
+}
+
+func newWatcher(e *etcdRegistry, path string, opts registry.WatchOptions) *watcher {
+	ctx, cancel := context.WithCancel(opts.Context)
+
+	w := &watcher{
+		registry: e,
+		path:     path,
+		opts:     opts,
+		ctx:      ctx,
+		cancel:   cancel,
+		results:  make(chan *registry.Result),
+	}
+
+	// the changes are watched once it returns
+	ready := make(chan bool)
+	go w.run(ready)
+
+	select {
+	case <-ready:
+	case <-time.After(w.registry.get().timeout):
+	case <-ctx.Done():
+	}
+
+	return w
+}
+
+func (w *watcher) run(ready chan bool) {
+	var once sync.Once
+	created := func() {
+		once.Do(func() { close(ready) })
+	}
+
+	for i := 0; ; i++ {
+		err := w.registry.get().watch(w.ctx, w.path, created, func(e *watchEvent) {
+			i = 0
+			w.send(w.result(e))
+		})
+
+		select {
+		case <-w.ctx.Done():
+			return
+		default:
+		}
+
+		if err != nil && logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debugf("etcd registry watch %s: %v", w.path, err)
+		}
+
+		select {
+		case <-time.After(DefaultWatchBackoff(i + 1)):
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// result returns the result of the event, nil if it's not of a node
+func (w *watcher) result(e *watchEvent) *registry.Result {
+	if e.Type == "DELETE" {
+		if e.PrevKv != nil {
+			if s := w.registry.decode(e.PrevKv); s != nil {
+				return &registry.Result{Action: "delete", Service: s}
+			}
+		}
+		if e.Kv == nil {
+			return nil
+		}
+
+		w.registry.RLock()
+		prefix := w.registry.prefix
+		w.registry.RUnlock()
+
+		// the node is known by its key without the previous value
+		parts := strings.Split(strings.TrimPrefix(string(e.Kv.Key), prefix), "/")
+		if len(parts) != 3 {
+			return nil
+		}
+		return &registry.Result{Action: "delete", Service: &registry.Service{
+			Name:     parts[1],
+			Metadata: map[string]string{"domain": parts[0]},
+			Nodes:    []*registry.Node{{Id: parts[2]}},
+		}}
+	}
+
+	if e.Kv == nil {
+		return nil
+	}
+	s := w.registry.decode(e.Kv)
+	if s == nil {
+		return nil
+	}
+
+	action := "update"
+	if e.Kv.CreateRevision == e.Kv.ModRevision {
+		action = "create"
+	}
+	return &registry.Result{Action: action, Service: s}
+}
+
+func (w *watcher) send(r *registry.Result) {
+	// filter the service, version and metadata
+	if r = registry.Filter(r, w.opts); r == nil {
+		return
+	}
+
+	select {
+	case w.results <- r:
+	case <-w.ctx.Done():
+	}
+}
+
+func (w *watcher) Next() (*registry.Result, error) {
+	select {
+	case r := <-w.results:
+		return r, nil
+	case <-w.ctx.Done():
+		return nil, registry.ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() {
+	w.cancel()
+}
@@ -0,0 +1,282 @@
+package kubernetes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccountPath is where the service account of the pod is mounted
+var serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+type objectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+}
+
+type pod struct {
+	Metadata objectMeta `json:"metadata"`
+	Status   podStatus  `json:"status"`
+}
+
+type podStatus struct {
+	Phase      string         `json:"phase"`
+	PodIP      string         `json:"podIP"`
+	Conditions []podCondition `json:"conditions"`
+}
+
+type podCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+type podList struct {
+	Items []*pod `json:"items"`
+}
+
+type endpointSlice struct {
+	Metadata  objectMeta `json:"metadata"`
+	Endpoints []endpoint `json:"endpoints"`
+}
+
+type endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions endpointConditions `json:"conditions"`
+	TargetRef  *objectReference   `json:"targetRef,omitempty"`
+}
+
+type endpointConditions struct {
+	// nil is interpreted as ready
+	Ready *bool `json:"ready,omitempty"`
+}
+
+type objectReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type endpointSliceList struct {
+	Items []*endpointSlice `json:"items"`
+}
+
+type event struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type status struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// client is a minimal client of the kubernetes api used by the registry
+type client struct {
+	host    string
+	token   string
+	timeout time.Duration
+	http    *http.Client
+}
+
+// newClient returns a client of the api server at addr, the in cluster
+// api server if empty
+func newClient(addr, token string, secure bool, config *tls.Config, timeout time.Duration) (*client, error) {
+	if len(addr) == 0 {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if len(host) == 0 || len(port) == 0 {
+			return nil, errors.New("not running in a kubernetes cluster, set the api server address")
+		}
+		addr = "https://" + net.JoinHostPort(host, port)
+		secure = true
+
+		if config == nil {
+			ca, err := ioutil.ReadFile(serviceAccountPath + "/ca.crt")
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(ca)
+			config = &tls.Config{RootCAs: pool}
+		}
+	}
+
+	if !strings.Contains(addr, "://") {
+		if secure || config != nil {
+			addr = "https://" + addr
+		} else {
+			addr = "http://" + addr
+		}
+	}
+
+	if len(token) == 0 {
+		if b, err := ioutil.ReadFile(serviceAccountPath + "/token"); err == nil {
+			token = strings.TrimSpace(string(b))
+		}
+	}
+
+	return &client{
+		host:    strings.TrimSuffix(addr, "/"),
+		token:   token,
+		timeout: timeout,
+		http: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: config,
+			},
+		},
+	}, nil
+}
+
+// namespaced returns the path of the resource in the namespace, across
+// all namespaces if empty
+func namespaced(api, ns, resource string) string {
+	if len(ns) == 0 {
+		return fmt.Sprintf("%s/%s", api, resource)
+	}
+	return fmt.Sprintf("%s/namespaces/%s/%s", api, ns, resource)
+}
+
+func (c *client) do(ctx context.Context, method, path, contentType string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	rsp, err := c.request(ctx, method, path, contentType, r)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(rsp.Body).Decode(out)
+}
+
+func (c *client) request(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.host+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode >= 300 {
+		defer rsp.Body.Close()
+		var s status
+		b, _ := ioutil.ReadAll(rsp.Body)
+		if err := json.Unmarshal(b, &s); err != nil || len(s.Message) == 0 {
+			s.Message = string(b)
+		}
+		return nil, fmt.Errorf("kubernetes api %s %s: %d %s", method, path, rsp.StatusCode, s.Message)
+	}
+
+	return rsp, nil
+}
+
+func (c *client) getPod(ctx context.Context, ns, name string) (*pod, error) {
+	var p pod
+	if err := c.do(ctx, "GET", namespaced("/api/v1", ns, "pods/"+name), "", nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// patchPod applies the json merge patch to the pod, null values remove
+// the keys
+func (c *client) patchPod(ctx context.Context, ns, name string, patch interface{}) error {
+	return c.do(ctx, "PATCH", namespaced("/api/v1", ns, "pods/"+name), "application/merge-patch+json", patch, nil)
+}
+
+func (c *client) listPods(ctx context.Context, ns, selector string) ([]*pod, error) {
+	var l podList
+	path := namespaced("/api/v1", ns, "pods") + "?labelSelector=" + url.QueryEscape(selector)
+	if err := c.do(ctx, "GET", path, "", nil, &l); err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+func (c *client) listEndpointSlices(ctx context.Context, ns string) ([]*endpointSlice, error) {
+	var l endpointSliceList
+	if err := c.do(ctx, "GET", namespaced("/apis/discovery.k8s.io/v1", ns, "endpointslices"), "", nil, &l); err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+// watch calls fn for each event of the resources at path until the
+// context is done or the api server closes the stream
+func (c *client) watch(ctx context.Context, path string, fn func(*event)) error {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	rsp, err := c.request(ctx, "GET", path+sep+"watch=true", "", nil)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	r := bufio.NewReader(rsp.Body)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e event
+			if err := json.Unmarshal(line, &e); err != nil {
+				return err
+			}
+			if e.Type == "ERROR" {
+				var s status
+				json.Unmarshal(e.Object, &s)
+				return fmt.Errorf("kubernetes watch %s: %d %s", path, s.Code, s.Message)
+			}
+			fn(&e)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,469 @@
+// Package kubernetes provides a registry using the kubernetes api. Services
+// are registered as annotations on the pod they run in and discovered by
+// reading the annotations of the pods which are ready, as reported by the
+// EndpointSlices of the kubernetes services selecting them.
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/registry"
+)
+
+const (
+	// labelTypeKey marks the pods running micro services
+	labelTypeKey   = "micro.mu/type"
+	labelTypeValue = "service"
+	// labelSelectorPrefix is the label selecting the pods of a service
+	labelSelectorPrefix = "micro.mu/selector-"
+	// annotationPrefix is the annotation holding the service definition
+	annotationPrefix = "micro.mu/service-"
+)
+
+type kregistry struct {
+	sync.RWMutex
+	options   registry.Options
+	client    *client
+	namespace string
+	pod       string
+	// err configuring the client
+	err error
+}
+
+// key returns the name of the service as a valid label key name
+func key(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			b[i] = '-'
+		}
+	}
+	return string(b)
+}
+
+func (k *kregistry) configure() error {
+	var addr string
+	if len(k.options.Addrs) > 0 {
+		addr = k.options.Addrs[0]
+	}
+
+	token, _ := k.options.Context.Value(tokenKey{}).(string)
+
+	c, err := newClient(addr, token, k.options.Secure, k.options.TLSConfig, k.options.Timeout)
+
+	k.Lock()
+	k.client = c
+	k.err = err
+	k.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	ns, _ := k.options.Context.Value(namespaceKey{}).(string)
+	if len(ns) == 0 {
+		ns = os.Getenv("POD_NAMESPACE")
+	}
+	if len(ns) == 0 {
+		if b, err := ioutil.ReadFile(serviceAccountPath + "/namespace"); err == nil {
+			ns = strings.TrimSpace(string(b))
+		}
+	}
+	if len(ns) == 0 {
+		ns = "default"
+	}
+
+	name, _ := k.options.Context.Value(podNameKey{}).(string)
+	if len(name) == 0 {
+		name = os.Getenv("POD_NAME")
+	}
+	if len(name) == 0 {
+		name, _ = os.Hostname()
+	}
+
+	k.Lock()
+	k.namespace = ns
+	k.pod = name
+	k.Unlock()
+
+	return nil
+}
+
+// get returns the client and the namespace of the domain, all
+// namespaces for the wildcard domain
+func (k *kregistry) get(domain string) (*client, string, error) {
+	k.RLock()
+	defer k.RUnlock()
+
+	if k.err != nil {
+		return nil, "", k.err
+	}
+
+	// the domain of the registry is enforced
+	if len(k.options.Domain) > 0 {
+		domain = k.options.Domain
+	}
+
+	switch domain {
+	case "", registry.DefaultDomain:
+		return k.client, k.namespace, nil
+	case registry.WildcardDomain:
+		return k.client, "", nil
+	default:
+		return k.client, domain, nil
+	}
+}
+
+func (k *kregistry) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&k.options)
+	}
+	if k.options.Tombstone > 0 && logger.V(logger.WarnLevel, logger.DefaultLogger) {
+		logger.Warnf("kubernetes registry: tombstones are only kept by the memory registry, the Tombstone option is ignored")
+	}
+	return k.configure()
+}
+
+func (k *kregistry) Options() registry.Options {
+	return k.options
+}
+
+func (k *kregistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("require at least one node")
+	}
+
+	options := registry.RegisterOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c, ns, err := k.get(options.Domain)
+	if err != nil {
+		return err
+	}
+
+	k.RLock()
+	name := k.pod
+	k.RUnlock()
+
+	p, err := c.getPod(options.Context, ns, name)
+	if err != nil {
+		return err
+	}
+
+	svc := &registry.Service{
+		Name:      s.Name,
+		Version:   s.Version,
+		Metadata:  s.Metadata,
+		Endpoints: s.Endpoints,
+		Nodes:     s.Nodes,
+	}
+
+	// keep the other nodes of the version registered by the pod
+	if prev := podService(p, s.Name); prev != nil && prev.Version == s.Version {
+		svc.Nodes = mergeNodes(prev.Nodes, s.Nodes)
+	}
+
+	b, err := json.Marshal(svc)
+	if err != nil {
+		return err
+	}
+
+	return c.patchPod(options.Context, ns, name, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				labelTypeKey:                      labelTypeValue,
+				labelSelectorPrefix + key(s.Name): labelTypeValue,
+			},
+			"annotations": map[string]interface{}{
+				annotationPrefix + key(s.Name): string(b),
+			},
+		},
+	})
+}
+
+func (k *kregistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	options := registry.DeregisterOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c, ns, err := k.get(options.Domain)
+	if err != nil {
+		return err
+	}
+
+	k.RLock()
+	name := k.pod
+	k.RUnlock()
+
+	p, err := c.getPod(options.Context, ns, name)
+	if err != nil {
+		return err
+	}
+
+	prev := podService(p, s.Name)
+	if prev == nil {
+		return nil
+	}
+
+	remove := make(map[string]bool)
+	for _, n := range s.Nodes {
+		remove[n.Id] = true
+	}
+
+	var nodes []*registry.Node
+	for _, n := range prev.Nodes {
+		if !remove[n.Id] {
+			nodes = append(nodes, n)
+		}
+	}
+
+	// the value removes the label and annotation when no nodes are left
+	var label, annotation interface{}
+
+	if len(nodes) > 0 {
+		prev.Nodes = nodes
+		b, err := json.Marshal(prev)
+		if err != nil {
+			return err
+		}
+		label, annotation = labelTypeValue, string(b)
+	}
+
+	if err := c.patchPod(options.Context, ns, name, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				labelSelectorPrefix + key(s.Name): label,
+			},
+			"annotations": map[string]interface{}{
+				annotationPrefix + key(s.Name): annotation,
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	for _, fn := range k.options.OnDeregister {
+		fn(s)
+	}
+
+	return nil
+}
+
+func (k *kregistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	options := registry.GetOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c, ns, err := k.get(options.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	svcs, err := services(options.Context, c, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(svcs) == 0 {
+		return nil, registry.ErrNotFound
+	}
+
+	return svcs, nil
+}
+
+func (k *kregistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	options := registry.ListOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c, ns, err := k.get(options.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return services(options.Context, c, ns, "")
+}
+
+func (k *kregistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	options := registry.WatchOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c, ns, err := k.get(options.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWatcher(c, ns, options)
+}
+
+func (k *kregistry) String() string {
+	return "kubernetes"
+}
+
+// selector returns the label selector of the pods running the service,
+// all services if empty
+func selector(service string) string {
+	if len(service) == 0 {
+		return labelTypeKey + "=" + labelTypeValue
+	}
+	return labelSelectorPrefix + key(service) + "=" + labelTypeValue
+}
+
+// services returns the services registered by the ready pods in the
+// namespace, the versions of the service if set
+func services(ctx context.Context, c *client, ns, service string) ([]*registry.Service, error) {
+	pods, err := c.listPods(ctx, ns, selector(service))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pods) == 0 {
+		return nil, nil
+	}
+
+	slices, err := c.listEndpointSlices(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	// readiness of the pods backing kubernetes services
+	ready := make(map[string]bool)
+	for _, s := range slices {
+		for _, e := range s.Endpoints {
+			if e.TargetRef == nil || e.TargetRef.Kind != "Pod" {
+				continue
+			}
+			ref := e.TargetRef.Namespace + "/" + e.TargetRef.Name
+			if len(e.TargetRef.Namespace) == 0 {
+				ref = s.Metadata.Namespace + "/" + e.TargetRef.Name
+			}
+			ready[ref] = ready[ref] || e.Conditions.Ready == nil || *e.Conditions.Ready
+		}
+	}
+
+	versions := make(map[string]*registry.Service)
+
+	for _, p := range pods {
+		r, ok := ready[p.Metadata.Namespace+"/"+p.Metadata.Name]
+		if !ok {
+			// pods without a kubernetes service use their own readiness
+			r = podReady(p)
+		}
+		if !r {
+			continue
+		}
+
+		for k, v := range p.Metadata.Annotations {
+			if !strings.HasPrefix(k, annotationPrefix) {
+				continue
+			}
+
+			var s *registry.Service
+			if err := json.Unmarshal([]byte(v), &s); err != nil || s == nil {
+				continue
+			}
+			if len(service) > 0 && s.Name != service {
+				continue
+			}
+
+			id := s.Name + ":" + s.Version
+			if prev, ok := versions[id]; ok {
+				prev.Nodes = append(prev.Nodes, s.Nodes...)
+				continue
+			}
+			versions[id] = s
+		}
+	}
+
+	services := make([]*registry.Service, 0, len(versions))
+	for _, s := range versions {
+		sort.Slice(s.Nodes, func(i, j int) bool { return s.Nodes[i].Id < s.Nodes[j].Id })
+		services = append(services, s)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name == services[j].Name {
+			return services[i].Version < services[j].Version
+		}
+		return services[i].Name < services[j].Name
+	})
+
+	return services, nil
+}
+
+// podService returns the service registered by the pod
+func podService(p *pod, name string) *registry.Service {
+	v, ok := p.Metadata.Annotations[annotationPrefix+key(name)]
+	if !ok {
+		return nil
+	}
+	var s *registry.Service
+	if err := json.Unmarshal([]byte(v), &s); err != nil || s == nil || s.Name != name {
+		return nil
+	}
+	return s
+}
+
+func podReady(p *pod) bool {
+	if p.Status.Phase != "Running" {
+		return false
+	}
+	for _, c := range p.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// mergeNodes returns the nodes replacing those with the same id
+func mergeNodes(prev, nodes []*registry.Node) []*registry.Node {
+	ids := make(map[string]bool)
+	for _, n := range nodes {
+		ids[n.Id] = true
+	}
+
+	merged := append([]*registry.Node{}, nodes...)
+	for _, n := range prev {
+		if !ids[n.Id] {
+			merged = append(merged, n)
+		}
+	}
+	return merged
+}
+
+// NewRegistry returns a registry using the kubernetes api, the api server
+// of the cluster the pod runs in if no address is set
+func NewRegistry(opts ...registry.Option) registry.Registry {
+	k := &kregistry{
+		options: registry.Options{
+			Context: context.Background(),
+		},
+	}
+	k.Init(opts...)
+	return k
+}
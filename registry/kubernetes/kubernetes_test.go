@@ -0,0 +1,286 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/registry"
+)
+
+// api is a fake of the kubernetes api serving the pods and EndpointSlices
+// of the default namespace
+type api struct {
+	sync.Mutex
+	pods     map[string]*pod
+	slices   []*endpointSlice
+	watchers []chan string
+}
+
+func newAPI() *api {
+	return &api{pods: make(map[string]*pod)}
+}
+
+// changed notifies the watchers, it's called with the lock held
+func (a *api) changed() {
+	for _, w := range a.watchers {
+		select {
+		case w <- `{"type":"MODIFIED","object":{}}`:
+		default:
+		}
+	}
+}
+
+func matches(p *pod, selector string) bool {
+	parts := strings.SplitN(selector, "=", 2)
+	return len(parts) == 2 && p.Metadata.Labels[parts[0]] == parts[1]
+}
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("watch") == "true" {
+		ch := make(chan string, 10)
+		a.Lock()
+		a.watchers = append(a.watchers, ch)
+		a.Unlock()
+
+		// the existing objects are added when watching without a
+		// resource version
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"type":"ADDED","object":{}}` + "\n"))
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case e := <-ch:
+				w.Write([]byte(e + "\n"))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	switch path := r.URL.Path; {
+	case path == "/apis/discovery.k8s.io/v1/namespaces/default/endpointslices":
+		json.NewEncoder(w).Encode(&endpointSliceList{Items: a.slices})
+	case path == "/api/v1/namespaces/default/pods":
+		var l podList
+		for _, p := range a.pods {
+			if matches(p, r.URL.Query().Get("labelSelector")) {
+				l.Items = append(l.Items, p)
+			}
+		}
+		json.NewEncoder(w).Encode(&l)
+	case strings.HasPrefix(path, "/api/v1/namespaces/default/pods/"):
+		p, ok := a.pods[strings.TrimPrefix(path, "/api/v1/namespaces/default/pods/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(&status{Code: 404, Message: "pod not found"})
+			return
+		}
+
+		if r.Method == "PATCH" {
+			if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+
+			var patch struct {
+				Metadata struct {
+					Labels      map[string]*string `json:"labels"`
+					Annotations map[string]*string `json:"annotations"`
+				} `json:"metadata"`
+			}
+			json.NewDecoder(r.Body).Decode(&patch)
+
+			merge := func(m map[string]string, patch map[string]*string) map[string]string {
+				if m == nil {
+					m = make(map[string]string)
+				}
+				for k, v := range patch {
+					if v == nil {
+						delete(m, k)
+						continue
+					}
+					m[k] = *v
+				}
+				return m
+			}
+
+			p.Metadata.Labels = merge(p.Metadata.Labels, patch.Metadata.Labels)
+			p.Metadata.Annotations = merge(p.Metadata.Annotations, patch.Metadata.Annotations)
+			a.changed()
+		}
+
+		json.NewEncoder(w).Encode(p)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (a *api) addPod(name string, ready bool) {
+	a.Lock()
+	defer a.Unlock()
+
+	status := "False"
+	if ready {
+		status = "True"
+	}
+
+	a.pods[name] = &pod{
+		Metadata: objectMeta{Name: name, Namespace: "default"},
+		Status: podStatus{
+			Phase:      "Running",
+			Conditions: []podCondition{{Type: "Ready", Status: status}},
+		},
+	}
+}
+
+// setEndpoint sets the readiness of the pod in the EndpointSlice
+func (a *api) setEndpoint(name string, ready bool) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.slices = []*endpointSlice{{
+		Metadata: objectMeta{Name: "foo-abc", Namespace: "default"},
+		Endpoints: []endpoint{{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: endpointConditions{Ready: &ready},
+			TargetRef:  &objectReference{Kind: "Pod", Namespace: "default", Name: name},
+		}},
+	}}
+	a.changed()
+}
+
+func service(node string) *registry.Service {
+	return &registry.Service{
+		Name:    "go.micro.service.foo",
+		Version: "1.0.0",
+		Nodes:   []*registry.Node{{Id: node, Address: node + ":8080"}},
+	}
+}
+
+func nodes(t *testing.T, r registry.Registry) []string {
+	services, err := r.GetService("go.micro.service.foo")
+	if err == registry.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("expected 1 version got %d", len(services))
+	}
+
+	var ids []string
+	for _, n := range services[0].Nodes {
+		ids = append(ids, n.Id)
+	}
+	return ids
+}
+
+func TestKubernetesRegistry(t *testing.T) {
+	a := newAPI()
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	// a is selected by a kubernetes service, b isn't and is ready,
+	// c isn't ready
+	a.addPod("a", false)
+	a.addPod("b", true)
+	a.addPod("c", false)
+	a.setEndpoint("a", true)
+
+	ra := NewRegistry(registry.Addrs(srv.URL), Namespace("default"), PodName("a"))
+	rb := NewRegistry(registry.Addrs(srv.URL), Namespace("default"), PodName("b"))
+	rc := NewRegistry(registry.Addrs(srv.URL), Namespace("default"), PodName("c"))
+
+	for _, r := range []registry.Registry{ra, rb, rc} {
+		if err := r.Register(service(r.(*kregistry).pod)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ids := strings.Join(nodes(t, ra), ","); ids != "a,b" {
+		t.Fatalf("expected the ready nodes a,b got %s", ids)
+	}
+
+	if p := a.pods["a"]; p.Metadata.Labels["micro.mu/selector-go.micro.service.foo"] != "service" || p.Metadata.Labels["micro.mu/type"] != "service" {
+		t.Fatalf("unexpected labels %v", p.Metadata.Labels)
+	}
+
+	services, err := ra.ListServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Name != "go.micro.service.foo" {
+		t.Fatalf("unexpected services %+v", services)
+	}
+
+	w, err := ra.Watch(registry.WatchService("go.micro.service.foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	next := func(action string, ids string) {
+		results := make(chan *registry.Result, 1)
+		go func() {
+			r, err := w.Next()
+			if err != nil {
+				t.Error(err)
+			}
+			results <- r
+		}()
+
+		select {
+		case r := <-results:
+			var got []string
+			if r != nil {
+				for _, n := range r.Service.Nodes {
+					got = append(got, n.Id)
+				}
+			}
+			if r == nil || r.Action != action || strings.Join(got, ",") != ids {
+				t.Fatalf("expected %s of %s got %+v %v", action, ids, r, got)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expected %s of %s", action, ids)
+		}
+	}
+
+	// the pod is removed from the EndpointSlice once it fails its probes
+	a.setEndpoint("a", false)
+	next("update", "b")
+
+	if err := rb.Deregister(service("b")); err != nil {
+		t.Fatal(err)
+	}
+	next("delete", "b")
+
+	if _, ok := a.pods["b"].Metadata.Annotations["micro.mu/service-go.micro.service.foo"]; ok {
+		t.Fatal("expected the annotation to be removed")
+	}
+
+	a.setEndpoint("a", true)
+	next("create", "a")
+}
+
+func TestKubernetesRegistryNotInCluster(t *testing.T) {
+	if len(os.Getenv("KUBERNETES_SERVICE_HOST")) > 0 {
+		t.Skip("running in a kubernetes cluster")
+	}
+
+	r := NewRegistry()
+	if _, err := r.GetService("foo"); err == nil {
+		t.Fatal("expected an error outside a cluster")
+	}
+}
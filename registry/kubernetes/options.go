@@ -0,0 +1,38 @@
+package kubernetes
+
+import (
+	"context"
+
+	"github.com/asim/go-micro/v3/registry"
+)
+
+type namespaceKey struct{}
+type podNameKey struct{}
+type tokenKey struct{}
+
+func setRegistryOption(k, v interface{}) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// Namespace sets the namespace of the default domain, the namespace of
+// the pod by default. Other domains map to the namespace of their name.
+func Namespace(ns string) registry.Option {
+	return setRegistryOption(namespaceKey{}, ns)
+}
+
+// PodName sets the pod services are registered on, POD_NAME or the
+// hostname by default
+func PodName(name string) registry.Option {
+	return setRegistryOption(podNameKey{}, name)
+}
+
+// Token sets the bearer token used to authenticate to the api server,
+// the service account token by default
+func Token(token string) registry.Option {
+	return setRegistryOption(tokenKey{}, token)
+}
@@ -0,0 +1,176 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/util/backoff"
+)
+
+var (
+	// DefaultWatchBackoff between the attempts to watch again, jittered so
+	// the watchers of many services don't retry together
+	DefaultWatchBackoff = backoff.ExpJitter(time.Millisecond*100, time.Minute)
+)
+
+// watcher watches the pods running services and the EndpointSlices
+// reporting their readiness, the services are read again on changes and
+// compared with the previous ones
+type watcher struct {
+	client    *client
+	namespace string
+	opts      registry.WatchOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// notified of changes to pods or EndpointSlices
+	notify  chan struct{}
+	results chan *registry.Result
+	// services by name and version
+	services map[string]*registry.Service
+}
+
+func newWatcher(c *client, ns string, opts registry.WatchOptions) (*watcher, error) {
+	ctx, cancel := context.WithCancel(opts.Context)
+
+	w := &watcher{
+		client:    c,
+		namespace: ns,
+		opts:      opts,
+		ctx:       ctx,
+		cancel:    cancel,
+		notify:    make(chan struct{}, 1),
+		results:   make(chan *registry.Result),
+		services:  make(map[string]*registry.Service),
+	}
+
+	svcs, err := services(ctx, c, ns, opts.Service)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	for _, s := range svcs {
+		w.services[s.Name+":"+s.Version] = s
+	}
+
+	go w.watch(namespaced("/api/v1", ns, "pods") + "?labelSelector=" + url.QueryEscape(selector(opts.Service)))
+	go w.watch(namespaced("/apis/discovery.k8s.io/v1", ns, "endpointslices"))
+	go w.run()
+
+	return w, nil
+}
+
+// watch notifies of the events of the resources at path, the watch is
+// started again when the api server closes it
+func (w *watcher) watch(path string) {
+	for i := 0; ; i++ {
+		err := w.client.watch(w.ctx, path, func(*event) {
+			i = 0
+			w.changed()
+		})
+
+		select {
+		case <-w.ctx.Done():
+			return
+		default:
+		}
+
+		if err != nil && logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debugf("kubernetes registry watch %s: %v", path, err)
+		}
+
+		// changes may have been missed meanwhile
+		w.changed()
+
+		select {
+		case <-time.After(DefaultWatchBackoff(i + 1)):
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *watcher) changed() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run() {
+	for {
+		select {
+		case <-w.notify:
+		case <-w.ctx.Done():
+			return
+		}
+
+		svcs, err := services(w.ctx, w.client, w.namespace, w.opts.Service)
+		if err != nil {
+			if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+				logger.Debugf("kubernetes registry watch: %v", err)
+			}
+			continue
+		}
+
+		current := make(map[string]*registry.Service)
+		for _, s := range svcs {
+			current[s.Name+":"+s.Version] = s
+		}
+
+		var results []*registry.Result
+
+		for id, s := range current {
+			prev, ok := w.services[id]
+			switch {
+			case !ok:
+				results = append(results, &registry.Result{Action: "create", Service: s})
+			case !equal(prev, s):
+				results = append(results, &registry.Result{Action: "update", Service: s})
+			}
+		}
+		for id, s := range w.services {
+			if _, ok := current[id]; !ok {
+				results = append(results, &registry.Result{Action: "delete", Service: s})
+			}
+		}
+
+		w.services = current
+
+		for _, r := range results {
+			// filter the version and metadata
+			if r = registry.Filter(r, w.opts); r == nil {
+				continue
+			}
+
+			select {
+			case w.results <- r:
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func equal(a, b *registry.Service) bool {
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	return string(ab) == string(bb)
+}
+
+func (w *watcher) Next() (*registry.Result, error) {
+	select {
+	case r := <-w.results:
+		return r, nil
+	case <-w.ctx.Done():
+		return nil, registry.ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() {
+	w.cancel()
+}
@@ -3,6 +3,7 @@ package memory
 
 import (
 	"context"
+	"reflect"
 	"sync"
 	"time"
 
@@ -30,6 +31,12 @@ type record struct {
 	Endpoints []*registry.Endpoint
 }
 
+// tombstone is the deletion of a node sent to new watchers until it expires
+type tombstone struct {
+	result  *registry.Result
+	expires time.Time
+}
+
 type Registry struct {
 	options registry.Options
 
@@ -37,6 +44,8 @@ type Registry struct {
 	// records is a KV map with domain name as the key and a services map as the value
 	records  map[string]services
 	watchers map[string]*Watcher
+	// tombstones of the deregistered nodes by domain, name, version and id
+	tombstones map[string]*tombstone
 }
 
 // services is a KV map with service name as the key and a map of records as the value
@@ -58,10 +67,11 @@ func NewRegistry(opts ...registry.Option) registry.Registry {
 	}
 
 	reg := &Registry{
-		options:  options,
-		records:  map[string]services{registry.DefaultDomain: records},
-		watchers: make(map[string]*Watcher),
+		options:    options,
+		watchers:   make(map[string]*Watcher),
+		tombstones: make(map[string]*tombstone),
 	}
+	reg.records = map[string]services{reg.domain(""): records}
 
 	go reg.ttlPrune()
 
@@ -76,6 +86,11 @@ func (m *Registry) ttlPrune() {
 		select {
 		case <-prune.C:
 			m.Lock()
+			for id, t := range m.tombstones {
+				if time.Now().After(t.expires) {
+					delete(m.tombstones, id)
+				}
+			}
 			for domain, services := range m.records {
 				for service, versions := range services {
 					for version, record := range versions {
@@ -85,6 +100,14 @@ func (m *Registry) ttlPrune() {
 									logger.Debugf("Registry TTL expired for node %s of service %s", n.Id, service)
 								}
 								delete(m.records[domain][service][version].Nodes, id)
+
+								// watchers can tell the registration was lost
+								go m.sendEvent(&registry.Result{Action: "delete", Service: &registry.Service{
+									Name:     service,
+									Version:  version,
+									Metadata: map[string]string{"domain": domain},
+									Nodes:    []*registry.Node{n.Node},
+								}})
 							}
 						}
 					}
@@ -128,7 +151,8 @@ func (m *Registry) Init(opts ...registry.Option) error {
 	defer m.Unlock()
 
 	// get the existing services from the records
-	srvs, ok := m.records[registry.DefaultDomain]
+	domain := m.domain("")
+	srvs, ok := m.records[domain]
 	if !ok {
 		srvs = make(services)
 	}
@@ -150,10 +174,22 @@ func (m *Registry) Init(opts ...registry.Option) error {
 	}
 
 	// set the services in the registry
-	m.records[registry.DefaultDomain] = srvs
+	m.records[domain] = srvs
 	return nil
 }
 
+// domain returns the domain of a call, the domain of the registry if it's
+// set so other domains can't be seen, the default domain if neither is
+func (m *Registry) domain(d string) string {
+	if len(m.options.Domain) > 0 {
+		return m.options.Domain
+	}
+	if len(d) == 0 {
+		return registry.DefaultDomain
+	}
+	return d
+}
+
 func (m *Registry) Options() registry.Options {
 	return m.options
 }
@@ -162,14 +198,12 @@ func (m *Registry) Register(s *registry.Service, opts ...registry.RegisterOption
 	m.Lock()
 	defer m.Unlock()
 
-	// parse the options, fallback to the default domain
+	// parse the options, fallback to the registry or default domain
 	var options registry.RegisterOptions
 	for _, o := range opts {
 		o(&options)
 	}
-	if len(options.Domain) == 0 {
-		options.Domain = registry.DefaultDomain
-	}
+	options.Domain = m.domain(options.Domain)
 
 	// get the services for this domain from the registry
 	srvs, ok := m.records[options.Domain]
@@ -177,12 +211,17 @@ func (m *Registry) Register(s *registry.Service, opts ...registry.RegisterOption
 		srvs = make(services)
 	}
 
-	// domain is set in metadata so it can be passed to watchers
-	if s.Metadata == nil {
-		s.Metadata = map[string]string{"domain": options.Domain}
-	} else {
-		s.Metadata["domain"] = options.Domain
+	// domain is set in metadata so it can be passed to watchers, it's
+	// set on a copy as the service may be registered again meanwhile
+	md := make(map[string]string, len(s.Metadata)+1)
+	for k, v := range s.Metadata {
+		md[k] = v
 	}
+	md["domain"] = options.Domain
+
+	svc := *s
+	svc.Metadata = md
+	s = &svc
 
 	// ensure the service name exists
 	r := serviceToRecord(s, options.TTL)
@@ -202,11 +241,6 @@ func (m *Registry) Register(s *registry.Service, opts ...registry.RegisterOption
 	var addedNodes bool
 
 	for _, n := range s.Nodes {
-		// check if already exists
-		if _, ok := srvs[s.Name][s.Version].Nodes[n.Id]; ok {
-			continue
-		}
-
 		metadata := make(map[string]string)
 
 		// make copy of metadata
@@ -217,6 +251,25 @@ func (m *Registry) Register(s *registry.Service, opts ...registry.RegisterOption
 		// set the domain
 		metadata["domain"] = options.Domain
 
+		// the node is back
+		delete(m.tombstones, tombstoneID(options.Domain, s.Name, s.Version, n.Id))
+
+		// check if already exists, changes to the metadata e.g the
+		// health of the node are applied
+		if e, ok := srvs[s.Name][s.Version].Nodes[n.Id]; ok {
+			if !reflect.DeepEqual(e.Metadata, metadata) {
+				e.Node = &registry.Node{
+					Id:       e.Id,
+					Address:  e.Address,
+					Metadata: metadata,
+				}
+				e.TTL = options.TTL
+				e.LastSeen = time.Now()
+				addedNodes = true
+			}
+			continue
+		}
+
 		// add the node
 		srvs[s.Name][s.Version].Nodes[n.Id] = &node{
 			Node: &registry.Node{
@@ -245,6 +298,7 @@ func (m *Registry) Register(s *registry.Service, opts ...registry.RegisterOption
 			srvs[s.Name][s.Version].Nodes[n.Id].TTL = options.TTL
 			srvs[s.Name][s.Version].Nodes[n.Id].LastSeen = time.Now()
 		}
+		go m.sendEvent(&registry.Result{Action: "heartbeat", Service: s})
 	}
 
 	m.records[options.Domain] = srvs
@@ -252,58 +306,92 @@ func (m *Registry) Register(s *registry.Service, opts ...registry.RegisterOption
 }
 
 func (m *Registry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	// the hooks are only called if a node was removed
+	if !m.deregister(s, opts...) {
+		return nil
+	}
+
+	for _, fn := range m.options.OnDeregister {
+		fn(s)
+	}
+
+	return nil
+}
+
+// deregister removes the nodes of the service, it returns whether any
+// of them were registered
+func (m *Registry) deregister(s *registry.Service, opts ...registry.DeregisterOption) bool {
 	m.Lock()
 	defer m.Unlock()
 
-	// parse the options, fallback to the default domain
+	// parse the options, fallback to the registry or default domain
 	var options registry.DeregisterOptions
 	for _, o := range opts {
 		o(&options)
 	}
-	if len(options.Domain) == 0 {
-		options.Domain = registry.DefaultDomain
-	}
+	options.Domain = m.domain(options.Domain)
 
-	// domain is set in metadata so it can be passed to watchers
-	if s.Metadata == nil {
-		s.Metadata = map[string]string{"domain": options.Domain}
-	} else {
-		s.Metadata["domain"] = options.Domain
+	// domain is set in metadata so it can be passed to watchers, it's
+	// set on a copy as the service may be registered again meanwhile
+	md := make(map[string]string, len(s.Metadata)+1)
+	for k, v := range s.Metadata {
+		md[k] = v
 	}
+	md["domain"] = options.Domain
+
+	svc := *s
+	svc.Metadata = md
+	s = &svc
 
 	// if the domain doesn't exist, there is nothing to deregister
 	services, ok := m.records[options.Domain]
 	if !ok {
-		return nil
+		return false
 	}
 
 	// if no services with this name and version exist, there is nothing to deregister
 	versions, ok := services[s.Name]
 	if !ok {
-		return nil
+		return false
 	}
 
 	version, ok := versions[s.Version]
 	if !ok {
-		return nil
+		return false
 	}
 
 	// deregister all of the service nodes from this version
+	var removed bool
 	for _, n := range s.Nodes {
-		if _, ok := version.Nodes[n.Id]; ok {
+		if e, ok := version.Nodes[n.Id]; ok {
+			removed = true
 			if logger.V(logger.DebugLevel, logger.DefaultLogger) {
 				logger.Debugf("Registry removed node from service: %s, version: %s", s.Name, s.Version)
 			}
 			delete(version.Nodes, n.Id)
+
+			if m.options.Tombstone > 0 {
+				m.tombstones[tombstoneID(options.Domain, s.Name, s.Version, n.Id)] = &tombstone{
+					result: &registry.Result{Action: "delete", Service: &registry.Service{
+						Name:     s.Name,
+						Version:  s.Version,
+						Metadata: md,
+						Nodes:    []*registry.Node{e.Node},
+					}},
+					expires: time.Now().Add(m.options.Tombstone),
+				}
+			}
 		}
 	}
 
 	// if the nodes not empty, we replace the version in the store and exist, the rest of the logic
 	// is cleanup
 	if len(version.Nodes) > 0 {
-		m.records[options.Domain][s.Name][s.Version] = version
-		go m.sendEvent(&registry.Result{Action: "update", Service: s})
-		return nil
+		if removed {
+			m.records[options.Domain][s.Name][s.Version] = version
+			go m.sendEvent(&registry.Result{Action: "update", Service: s})
+		}
+		return removed
 	}
 
 	// if this version was the only version of the service, we can remove the whole service from the
@@ -315,7 +403,7 @@ func (m *Registry) Deregister(s *registry.Service, opts ...registry.DeregisterOp
 		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
 			logger.Debugf("Registry removed service: %s", s.Name)
 		}
-		return nil
+		return true
 	}
 
 	// there are other versions of the service running, so only remove this version of it
@@ -325,28 +413,30 @@ func (m *Registry) Deregister(s *registry.Service, opts ...registry.DeregisterOp
 		logger.Debugf("Registry removed service: %s, version: %s", s.Name, s.Version)
 	}
 
-	return nil
+	return true
 }
 
 func (m *Registry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
-	// parse the options, fallback to the default domain
+	// parse the options, fallback to the registry or default domain
 	var options registry.GetOptions
 	for _, o := range opts {
 		o(&options)
 	}
-	if len(options.Domain) == 0 {
-		options.Domain = registry.DefaultDomain
-	}
+	options.Domain = m.domain(options.Domain)
 
 	// if it's a wildcard domain, return from all domains
 	if options.Domain == registry.WildcardDomain {
+		// the domains are copied as the records change once unlocked
 		m.RLock()
-		recs := m.records
+		domains := make([]string, 0, len(m.records))
+		for domain := range m.records {
+			domains = append(domains, domain)
+		}
 		m.RUnlock()
 
 		var services []*registry.Service
 
-		for domain := range recs {
+		for _, domain := range domains {
 			srvs, err := m.GetService(name, append(opts, registry.GetDomain(domain))...)
 			if err == registry.ErrNotFound {
 				continue
@@ -391,24 +481,26 @@ func (m *Registry) GetService(name string, opts ...registry.GetOption) ([]*regis
 }
 
 func (m *Registry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
-	// parse the options, fallback to the default domain
+	// parse the options, fallback to the registry or default domain
 	var options registry.ListOptions
 	for _, o := range opts {
 		o(&options)
 	}
-	if len(options.Domain) == 0 {
-		options.Domain = registry.DefaultDomain
-	}
+	options.Domain = m.domain(options.Domain)
 
 	// if it's a wildcard domain, list from all domains
 	if options.Domain == registry.WildcardDomain {
+		// the domains are copied as the records change once unlocked
 		m.RLock()
-		recs := m.records
+		domains := make([]string, 0, len(m.records))
+		for domain := range m.records {
+			domains = append(domains, domain)
+		}
 		m.RUnlock()
 
 		var services []*registry.Service
 
-		for domain := range recs {
+		for _, domain := range domains {
 			srvs, err := m.ListServices(append(opts, registry.ListDomain(domain))...)
 			if err != nil {
 				return nil, err
@@ -441,14 +533,12 @@ func (m *Registry) ListServices(opts ...registry.ListOption) ([]*registry.Servic
 }
 
 func (m *Registry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
-	// parse the options, fallback to the default domain
+	// parse the options, fallback to the registry or default domain
 	var wo registry.WatchOptions
 	for _, o := range opts {
 		o(&wo)
 	}
-	if len(wo.Domain) == 0 {
-		wo.Domain = registry.DefaultDomain
-	}
+	wo.Domain = m.domain(wo.Domain)
 
 	// construct the watcher
 	w := &Watcher{
@@ -460,11 +550,35 @@ func (m *Registry) Watch(opts ...registry.WatchOption) (registry.Watcher, error)
 
 	m.Lock()
 	m.watchers[w.id] = w
+
+	// the deletions the watcher missed
+	var results []*registry.Result
+	for _, t := range m.tombstones {
+		if time.Now().Before(t.expires) {
+			results = append(results, t.result)
+		}
+	}
 	m.Unlock()
 
+	if len(results) > 0 {
+		go func() {
+			for _, r := range results {
+				select {
+				case w.res <- r:
+				case <-w.exit:
+					return
+				}
+			}
+		}()
+	}
+
 	return w, nil
 }
 
+func tombstoneID(domain, name, version, id string) string {
+	return domain + "/" + name + "/" + version + "/" + id
+}
+
 func (m *Registry) String() string {
 	return "memory"
 }
@@ -280,3 +280,179 @@ func TestMemoryWildcard(t *testing.T) {
 		t.Errorf("Expected 2 records, got %v", len(recs))
 	}
 }
+
+func TestMemoryWildcardConcurrent(t *testing.T) {
+	m := NewRegistry()
+	testSrv := &registry.Service{Name: "foo", Version: "1.0.0"}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			domain := fmt.Sprintf("domain-%d", i)
+			m.Register(testSrv, registry.RegisterDomain(domain))
+			m.Deregister(testSrv, registry.DeregisterDomain(domain))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if _, err := m.GetService(testSrv.Name, registry.GetDomain(registry.WildcardDomain)); err != nil && err != registry.ErrNotFound {
+			t.Fatalf("Get err: %v", err)
+		}
+		if _, err := m.ListServices(registry.ListDomain(registry.WildcardDomain)); err != nil {
+			t.Fatalf("List err: %v", err)
+		}
+	}
+}
+
+func TestMemoryDomain(t *testing.T) {
+	m := NewRegistry()
+	testSrv := &registry.Service{Name: "foo", Version: "1.0.0"}
+
+	if err := m.Register(testSrv, registry.RegisterDomain("prod")); err != nil {
+		t.Fatalf("Register err: %v", err)
+	}
+
+	// the registry is scoped to staging
+	m.Init(registry.Domain("staging"))
+
+	w, err := m.Watch(registry.WatchDomain(registry.WildcardDomain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	for _, domain := range []string{"prod", registry.WildcardDomain} {
+		if _, err := m.GetService(testSrv.Name, registry.GetDomain(domain)); err != registry.ErrNotFound {
+			t.Fatalf("Expected the prod service not to be found in %s, got %v", domain, err)
+		}
+	}
+
+	if err := m.Register(testSrv, registry.RegisterDomain("prod")); err != nil {
+		t.Fatalf("Register err: %v", err)
+	}
+	if recs, err := m.GetService(testSrv.Name); err != nil || len(recs) != 1 {
+		t.Fatalf("Expected the service to be registered in staging, got %v %v", recs, err)
+	}
+
+	res, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Service.Metadata["domain"] != "staging" {
+		t.Fatalf("Expected an event of the staging domain, got %v", res.Service.Metadata)
+	}
+
+	if recs, err := m.ListServices(registry.ListDomain("prod")); err != nil || len(recs) != 1 {
+		t.Fatalf("Expected only the staging service to be listed, got %v %v", recs, err)
+	}
+}
+
+func TestMemoryTombstone(t *testing.T) {
+	var deregistered []string
+
+	m := NewRegistry(
+		registry.Tombstone(time.Minute),
+		registry.OnDeregister(func(s *registry.Service) {
+			deregistered = append(deregistered, s.Name)
+		}),
+	)
+
+	testSrv := &registry.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*registry.Node{{Id: "foo-1", Address: "10.0.0.1:8080"}},
+	}
+
+	if err := m.Register(testSrv); err != nil {
+		t.Fatalf("Register err: %v", err)
+	}
+	if err := m.Deregister(testSrv); err != nil {
+		t.Fatalf("Deregister err: %v", err)
+	}
+
+	if len(deregistered) != 1 || deregistered[0] != "foo" {
+		t.Fatalf("Expected the deregister hook to be called, got %v", deregistered)
+	}
+
+	// nothing is removed deregistering again or unknown versions and nodes
+	unknown := []*registry.Service{
+		testSrv,
+		{Name: "foo", Version: "2.0.0", Nodes: testSrv.Nodes},
+		{Name: "bar", Version: "1.0.0", Nodes: testSrv.Nodes},
+	}
+	for _, s := range unknown {
+		if err := m.Deregister(s); err != nil {
+			t.Fatalf("Deregister err: %v", err)
+		}
+	}
+	if err := m.Register(testSrv); err != nil {
+		t.Fatalf("Register err: %v", err)
+	}
+	if err := m.Deregister(&registry.Service{Name: "foo", Version: "1.0.0", Nodes: []*registry.Node{{Id: "foo-2"}}}); err != nil {
+		t.Fatalf("Deregister err: %v", err)
+	}
+	if len(deregistered) != 1 {
+		t.Fatalf("Expected the deregister hook to only be called for removed nodes, got %v", deregistered)
+	}
+	if err := m.Deregister(testSrv); err != nil {
+		t.Fatalf("Deregister err: %v", err)
+	}
+
+	// watchers started after the deregistration are sent the deletion
+	time.Sleep(sendEventTime * 5)
+
+	w, err := m.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Action != "delete" || len(res.Service.Nodes) != 1 || res.Service.Nodes[0].Id != "foo-1" {
+		t.Fatalf("Expected the tombstone, got %s %+v", res.Action, res.Service)
+	}
+	w.Stop()
+
+	// until the node registers again
+	if err := m.Register(testSrv); err != nil {
+		t.Fatalf("Register err: %v", err)
+	}
+
+	// the events are sent before watching
+	time.Sleep(sendEventTime * 5)
+
+	w, err = m.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// the events of the registration may still be sent
+	results := make(chan *registry.Result, 10)
+	go func() {
+		for {
+			res, err := w.Next()
+			if err != nil {
+				return
+			}
+			if res.Action == "delete" {
+				results <- res
+			}
+		}
+	}()
+
+	select {
+	case res := <-results:
+		t.Fatalf("Expected no tombstone, got %s %+v", res.Action, res.Service)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
@@ -17,11 +17,9 @@ func (m *Watcher) Next() (*registry.Result, error) {
 	for {
 		select {
 		case r := <-m.res:
-			if r.Service == nil {
-				continue
-			}
-
-			if len(m.wo.Service) > 0 && m.wo.Service != r.Service.Name {
+			// filter the service, version and metadata
+			r = registry.Filter(r, m.wo)
+			if r == nil {
 				continue
 			}
 
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"testing"
+	"time"
 
 	"github.com/asim/go-micro/v3/registry"
 )
@@ -33,3 +34,60 @@ func TestWatcher(t *testing.T) {
 		t.Fatal("expected error on Next()")
 	}
 }
+
+func TestWatcherFilter(t *testing.T) {
+	r := NewRegistry()
+
+	w, err := r.Watch(registry.WatchVersion("2.0.0"), registry.WatchMetadata("zone", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	results := make(chan *registry.Result, 10)
+	go func() {
+		for {
+			res, err := w.Next()
+			if err != nil {
+				return
+			}
+			results <- res
+		}
+	}()
+
+	register := func(version, id, zone string) {
+		if err := r.Register(&registry.Service{
+			Name:    "foo",
+			Version: version,
+			Nodes:   []*registry.Node{{Id: id, Metadata: map[string]string{"zone": zone}}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next := func(action, id string) {
+		select {
+		case res := <-results:
+			if res.Action != action || len(res.Service.Nodes) != 1 || res.Service.Nodes[0].Id != id {
+				t.Fatalf("expected %s of %s got %s %+v", action, id, res.Action, res.Service.Nodes)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s of %s", action, id)
+		}
+	}
+
+	register("1.0.0", "foo-1", "a")
+	register("2.0.0", "foo-2", "b")
+	register("2.0.0", "foo-3", "a")
+	next("update", "foo-3")
+
+	// registering again without changes is a heartbeat
+	register("2.0.0", "foo-3", "a")
+	next("heartbeat", "foo-3")
+
+	select {
+	case res := <-results:
+		t.Fatalf("unexpected result %s %+v", res.Action, res.Service)
+	case <-time.After(sendEventTime * 2):
+	}
+}
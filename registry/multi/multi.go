@@ -0,0 +1,293 @@
+// Package multi provides a registry federating several registries, e.g those
+// of multiple datacenters or the old and new backends while migrating between
+// them. Services are registered to all the registries and read from all of
+// them, the nodes are labelled with the name of the registries they're in.
+// The domain of the registry is requested from all of them if it's set.
+package multi
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/registry"
+	util "github.com/asim/go-micro/v3/util/registry"
+)
+
+// LabelKey is the node metadata holding the names of the registries it's
+// in, separated by commas
+var LabelKey = "registry"
+
+type labelled struct {
+	registry.Registry
+	name string
+}
+
+type multiRegistry struct {
+	sync.RWMutex
+	options    registry.Options
+	registries []*labelled
+}
+
+func (m *multiRegistry) configure() error {
+	regs, _ := m.options.Context.Value(registriesKey{}).([]*labelled)
+
+	m.Lock()
+	m.registries = regs
+	m.Unlock()
+
+	return nil
+}
+
+func (m *multiRegistry) get() []*labelled {
+	m.RLock()
+	defer m.RUnlock()
+	return m.registries
+}
+
+// failures returns the errors of the registries prefixed with their names
+func failures(regs []*labelled, errs []error) error {
+	var msgs []string
+	for i, err := range errs {
+		if err != nil {
+			msgs = append(msgs, regs[i].name+": "+err.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New("multi registry: " + strings.Join(msgs, "; "))
+}
+
+// each calls fn with the registries concurrently and returns their errors
+func each(regs []*labelled, fn func(int, *labelled) error) []error {
+	errs := make([]error, len(regs))
+
+	var wg sync.WaitGroup
+	for i, r := range regs {
+		wg.Add(1)
+		go func(i int, r *labelled) {
+			defer wg.Done()
+			errs[i] = fn(i, r)
+		}(i, r)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// label returns copies of the services with the nodes labelled
+func label(name string, services []*registry.Service) []*registry.Service {
+	services = util.Copy(services)
+	for _, s := range services {
+		for _, n := range s.Nodes {
+			md := make(map[string]string, len(n.Metadata)+1)
+			for k, v := range n.Metadata {
+				md[k] = v
+			}
+			md[LabelKey] = name
+			n.Metadata = md
+		}
+	}
+	return services
+}
+
+// merge adds the services to the versions, the nodes in several registries
+// are labelled with all their names
+func merge(versions map[string]*registry.Service, services []*registry.Service) {
+	for _, s := range services {
+		id := s.Name + ":" + s.Version
+
+		prev, ok := versions[id]
+		if !ok {
+			versions[id] = s
+			continue
+		}
+
+		for _, n := range s.Nodes {
+			var seen bool
+			for _, p := range prev.Nodes {
+				if p.Id == n.Id {
+					p.Metadata[LabelKey] += "," + n.Metadata[LabelKey]
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				prev.Nodes = append(prev.Nodes, n)
+			}
+		}
+		if len(prev.Endpoints) == 0 {
+			prev.Endpoints = s.Endpoints
+		}
+	}
+}
+
+func sorted(versions map[string]*registry.Service) []*registry.Service {
+	services := make([]*registry.Service, 0, len(versions))
+	for _, s := range versions {
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name == services[j].Name {
+			return services[i].Version < services[j].Version
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+func (m *multiRegistry) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&m.options)
+	}
+	if m.options.Tombstone > 0 && logger.V(logger.WarnLevel, logger.DefaultLogger) {
+		logger.Warnf("multi registry: tombstones are only kept by the memory registry, the Tombstone option is ignored")
+	}
+	return m.configure()
+}
+
+func (m *multiRegistry) Options() registry.Options {
+	return m.options
+}
+
+// Register the service to all the registries, an error is returned if any
+// of them fails
+func (m *multiRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	if d := m.options.Domain; len(d) > 0 {
+		opts = append(opts, registry.RegisterDomain(d))
+	}
+
+	regs := m.get()
+	return failures(regs, each(regs, func(_ int, r *labelled) error {
+		return r.Register(s, opts...)
+	}))
+}
+
+// Deregister the service from all the registries, an error is returned if
+// any of them fails
+func (m *multiRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	if d := m.options.Domain; len(d) > 0 {
+		opts = append(opts, registry.DeregisterDomain(d))
+	}
+
+	regs := m.get()
+	if err := failures(regs, each(regs, func(_ int, r *labelled) error {
+		return r.Deregister(s, opts...)
+	})); err != nil {
+		return err
+	}
+
+	for _, fn := range m.options.OnDeregister {
+		fn(s)
+	}
+
+	return nil
+}
+
+// GetService merges the versions of the service from the registries, the
+// registries which fail are skipped unless all of them do
+func (m *multiRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	if d := m.options.Domain; len(d) > 0 {
+		opts = append(opts, registry.GetDomain(d))
+	}
+
+	regs := m.get()
+	if len(regs) == 0 {
+		return nil, errors.New("multi registry: no registries")
+	}
+
+	results := make([][]*registry.Service, len(regs))
+	errs := each(regs, func(i int, r *labelled) error {
+		services, err := r.GetService(name, opts...)
+		if err != nil {
+			return err
+		}
+		results[i] = label(r.name, services)
+		return nil
+	})
+
+	versions := make(map[string]*registry.Service)
+	var failed bool
+	for i, err := range errs {
+		switch err {
+		case nil:
+			merge(versions, results[i])
+		case registry.ErrNotFound:
+			errs[i] = nil
+		default:
+			failed = true
+		}
+	}
+
+	if len(versions) > 0 {
+		return sorted(versions), nil
+	}
+	if failed {
+		return nil, failures(regs, errs)
+	}
+	return nil, registry.ErrNotFound
+}
+
+// ListServices returns the services of the registries, the registries which
+// fail are skipped unless all of them do
+func (m *multiRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	if d := m.options.Domain; len(d) > 0 {
+		opts = append(opts, registry.ListDomain(d))
+	}
+
+	regs := m.get()
+
+	results := make([][]*registry.Service, len(regs))
+	errs := each(regs, func(i int, r *labelled) error {
+		services, err := r.ListServices(opts...)
+		if err != nil {
+			return err
+		}
+		results[i] = label(r.name, services)
+		return nil
+	})
+
+	versions := make(map[string]*registry.Service)
+	var ok bool
+	for i, err := range errs {
+		if err == nil {
+			merge(versions, results[i])
+			ok = true
+		}
+	}
+
+	if !ok && len(regs) > 0 {
+		return nil, failures(regs, errs)
+	}
+	return sorted(versions), nil
+}
+
+// Watch the registries, the nodes of the results are labelled with the name
+// of the registry they're from
+func (m *multiRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	if d := m.options.Domain; len(d) > 0 {
+		opts = append(opts, registry.WatchDomain(d))
+	}
+
+	return newWatcher(m.get(), opts...)
+}
+
+func (m *multiRegistry) String() string {
+	return "multi"
+}
+
+// NewRegistry returns a registry federating the registries added with the
+// Registry option
+func NewRegistry(opts ...registry.Option) registry.Registry {
+	m := &multiRegistry{
+		options: registry.Options{
+			Context: context.Background(),
+		},
+	}
+	m.Init(opts...)
+	return m
+}
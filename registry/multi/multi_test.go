@@ -0,0 +1,144 @@
+package multi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/registry/memory"
+)
+
+// down is a registry which is unavailable
+type down struct {
+	registry.Registry
+}
+
+var errDown = errors.New("registry unavailable")
+
+func (d *down) Register(*registry.Service, ...registry.RegisterOption) error {
+	return errDown
+}
+
+func (d *down) GetService(string, ...registry.GetOption) ([]*registry.Service, error) {
+	return nil, errDown
+}
+
+func (d *down) Watch(...registry.WatchOption) (registry.Watcher, error) {
+	return nil, errDown
+}
+
+func service(nodes ...string) *registry.Service {
+	s := &registry.Service{Name: "foo", Version: "1.0.0"}
+	for _, n := range nodes {
+		s.Nodes = append(s.Nodes, &registry.Node{Id: n, Address: n + ":8080"})
+	}
+	return s
+}
+
+func labels(t *testing.T, r registry.Registry) map[string]string {
+	services, err := r.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("expected the versions to be merged got %d", len(services))
+	}
+
+	labels := make(map[string]string)
+	for _, n := range services[0].Nodes {
+		labels[n.Id] = n.Metadata[LabelKey]
+	}
+	return labels
+}
+
+func TestMultiRegistry(t *testing.T) {
+	dc1 := memory.NewRegistry()
+	dc2 := memory.NewRegistry()
+
+	r := NewRegistry(Registry("dc1", dc1), Registry("dc2", dc2))
+
+	if err := r.Register(service("a")); err != nil {
+		t.Fatal(err)
+	}
+	for _, reg := range []registry.Registry{dc1, dc2} {
+		if _, err := reg.GetService("foo"); err != nil {
+			t.Fatalf("expected the service to be registered to all the registries: %v", err)
+		}
+	}
+
+	if err := dc1.Register(service("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc2.Register(service("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := labels(t, r)
+	if len(got) != 3 || got["a"] != "dc1,dc2" || got["b"] != "dc1" || got["c"] != "dc2" {
+		t.Fatalf("unexpected nodes %v", got)
+	}
+
+	// the labels aren't set on the services of the registries
+	services, _ := dc1.GetService("foo")
+	for _, n := range services[0].Nodes {
+		if _, ok := n.Metadata[LabelKey]; ok {
+			t.Fatal("expected the nodes of the registry to be unchanged")
+		}
+	}
+
+	if err := r.Deregister(service("a")); err != nil {
+		t.Fatal(err)
+	}
+	if got := labels(t, r); len(got) != 2 {
+		t.Fatalf("expected the node to be deregistered from all the registries got %v", got)
+	}
+
+	if _, err := r.GetService("bar"); err != registry.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+}
+
+func TestMultiRegistryFailure(t *testing.T) {
+	dc1 := memory.NewRegistry()
+
+	r := NewRegistry(Registry("dc1", dc1), Registry("dc2", &down{memory.NewRegistry()}))
+
+	// registering fails when any registry does
+	if err := r.Register(service("a")); err == nil {
+		t.Fatal("expected an error registering")
+	}
+
+	// the registries which are up are still read
+	if got := labels(t, r); len(got) != 1 || got["a"] != "dc1" {
+		t.Fatalf("unexpected nodes %v", got)
+	}
+
+	w, err := r.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	go dc1.Register(service("b"))
+
+	results := make(chan *registry.Result, 1)
+	go func() {
+		res, _ := w.Next()
+		results <- res
+	}()
+
+	select {
+	case res := <-results:
+		if res == nil || res.Service.Nodes[0].Metadata[LabelKey] != "dc1" {
+			t.Fatalf("expected a labelled result got %+v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a result")
+	}
+
+	r = NewRegistry(Registry("dc2", &down{memory.NewRegistry()}))
+	if _, err := r.GetService("foo"); err == nil || err == registry.ErrNotFound {
+		t.Fatalf("expected the error of the registry got %v", err)
+	}
+}
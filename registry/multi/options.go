@@ -0,0 +1,22 @@
+package multi
+
+import (
+	"context"
+
+	"github.com/asim/go-micro/v3/registry"
+)
+
+type registriesKey struct{}
+
+// Registry adds a registry labelled with the name, its nodes are labelled
+// with it in the LabelKey metadata
+func Registry(name string, r registry.Registry) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		regs, _ := o.Context.Value(registriesKey{}).([]*labelled)
+		regs = append(regs[:len(regs):len(regs)], &labelled{name: name, Registry: r})
+		o.Context = context.WithValue(o.Context, registriesKey{}, regs)
+	}
+}
@@ -0,0 +1,101 @@
+package multi
+
+import (
+	"sync"
+
+	"github.com/asim/go-micro/v3/registry"
+)
+
+// watcher forwards the results of the watchers of the registries
+type watcher struct {
+	watchers []registry.Watcher
+	results  chan *registry.Result
+	exit     chan bool
+	once     sync.Once
+	// done is closed once all the watchers have failed
+	done chan bool
+}
+
+// newWatcher watches the registries, those which fail to watch are skipped
+// unless all of them do
+func newWatcher(regs []*labelled, opts ...registry.WatchOption) (*watcher, error) {
+	w := &watcher{
+		results: make(chan *registry.Result),
+		exit:    make(chan bool),
+		done:    make(chan bool),
+	}
+
+	labels := make([]string, 0, len(regs))
+	errs := make([]error, len(regs))
+
+	for i, r := range regs {
+		rw, err := r.Watch(opts...)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		w.watchers = append(w.watchers, rw)
+		labels = append(labels, r.name)
+	}
+
+	if len(w.watchers) == 0 && len(regs) > 0 {
+		return nil, failures(regs, errs)
+	}
+
+	var wg sync.WaitGroup
+	for i, rw := range w.watchers {
+		wg.Add(1)
+		go func(name string, rw registry.Watcher) {
+			defer wg.Done()
+			w.forward(name, rw)
+		}(labels[i], rw)
+	}
+
+	go func() {
+		wg.Wait()
+		close(w.done)
+	}()
+
+	return w, nil
+}
+
+func (w *watcher) forward(name string, rw registry.Watcher) {
+	for {
+		r, err := rw.Next()
+		if err != nil {
+			return
+		}
+		if r.Service != nil {
+			r = &registry.Result{
+				Action:  r.Action,
+				Service: label(name, []*registry.Service{r.Service})[0],
+			}
+		}
+
+		select {
+		case w.results <- r:
+		case <-w.exit:
+			return
+		}
+	}
+}
+
+func (w *watcher) Next() (*registry.Result, error) {
+	select {
+	case r := <-w.results:
+		return r, nil
+	case <-w.exit:
+		return nil, registry.ErrWatcherStopped
+	case <-w.done:
+		return nil, registry.ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() {
+	w.once.Do(func() {
+		close(w.exit)
+		for _, rw := range w.watchers {
+			rw.Stop()
+		}
+	})
+}
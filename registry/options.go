@@ -11,6 +11,14 @@ type Options struct {
 	Timeout   time.Duration
 	Secure    bool
 	TLSConfig *tls.Config
+	// Domain the calls are scoped to, the domains
+	// requested are ignored if it's set
+	Domain string
+	// Tombstone is how long deregistered nodes are remembered,
+	// watchers started meanwhile are sent their deletion
+	Tombstone time.Duration
+	// OnDeregister is called with the services deregistered
+	OnDeregister []func(*Service)
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -34,6 +42,12 @@ type WatchOptions struct {
 	Context context.Context
 	// Domain to watch
 	Domain string
+	// Version of the service to watch
+	// If blank, the watch is for all versions
+	Version string
+	// Metadata the service or its nodes must have,
+	// other nodes are left out of the results
+	Metadata map[string]string
 }
 
 type DeregisterOptions struct {
@@ -61,6 +75,31 @@ func Addrs(addrs ...string) Option {
 	}
 }
 
+// Domain scopes the registry to the domain, it's enforced on all the
+// calls so environments sharing a registry don't see each other's nodes
+func Domain(d string) Option {
+	return func(o *Options) {
+		o.Domain = d
+	}
+}
+
+// Tombstone keeps deregistered nodes for the duration so watching caches
+// which missed the deletion still evict them rather than wait for the TTL.
+// It's only supported by the memory registry, the others log a warning.
+func Tombstone(d time.Duration) Option {
+	return func(o *Options) {
+		o.Tombstone = d
+	}
+}
+
+// OnDeregister adds a hook called after a service is deregistered e.g for
+// audit logging
+func OnDeregister(fn func(*Service)) Option {
+	return func(o *Options) {
+		o.OnDeregister = append(o.OnDeregister, fn)
+	}
+}
+
 func Timeout(t time.Duration) Option {
 	return func(o *Options) {
 		o.Timeout = t
@@ -118,6 +157,23 @@ func WatchDomain(d string) WatchOption {
 	}
 }
 
+// WatchVersion watches a version of the service
+func WatchVersion(v string) WatchOption {
+	return func(o *WatchOptions) {
+		o.Version = v
+	}
+}
+
+// WatchMetadata watches the services or nodes with the metadata
+func WatchMetadata(key, val string) WatchOption {
+	return func(o *WatchOptions) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]string)
+		}
+		o.Metadata[key] = val
+	}
+}
+
 func DeregisterContext(ctx context.Context) DeregisterOption {
 	return func(o *DeregisterOptions) {
 		o.Context = ctx
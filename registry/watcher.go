@@ -12,6 +12,7 @@ type Watcher interface {
 
 // Result is returned by a call to Next on
 // the watcher. Actions can be create, update, delete
+// or heartbeat
 type Result struct {
 	Action  string
 	Service *Service
@@ -27,6 +28,9 @@ const (
 	Delete
 	// Update is emitted when an existing service is updated
 	Update
+	// Heartbeat is emitted when the nodes of a service are registered
+	// again without changes, refreshing their TTL
+	Heartbeat
 )
 
 // String returns human readable event type
@@ -38,6 +42,8 @@ func (t EventType) String() string {
 		return "delete"
 	case Update:
 		return "update"
+	case Heartbeat:
+		return "heartbeat"
 	default:
 		return "unknown"
 	}
@@ -54,3 +60,53 @@ type Event struct {
 	// Service is registry service
 	Service *Service
 }
+
+// Filter returns the result with the nodes matching the service, version
+// and metadata of the watch options, nil if nothing matches. It's used by
+// the watchers of registries which can't filter the results themselves.
+func Filter(r *Result, o WatchOptions) *Result {
+	if r == nil || r.Service == nil {
+		return nil
+	}
+	if len(o.Service) > 0 && o.Service != r.Service.Name {
+		return nil
+	}
+	if len(o.Version) > 0 && o.Version != r.Service.Version {
+		return nil
+	}
+	if len(o.Metadata) == 0 {
+		return r
+	}
+
+	// the metadata of the service applies to all its nodes
+	match := func(md map[string]string) bool {
+		for k, v := range o.Metadata {
+			if r.Service.Metadata[k] != v && md[k] != v {
+				return false
+			}
+		}
+		return true
+	}
+
+	if len(r.Service.Nodes) == 0 {
+		if !match(nil) {
+			return nil
+		}
+		return r
+	}
+
+	var nodes []*Node
+	for _, n := range r.Service.Nodes {
+		if match(n.Metadata) {
+			nodes = append(nodes, n)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	// the result may be sent to other watchers, it's copied
+	s := *r.Service
+	s.Nodes = nodes
+	return &Result{Action: r.Action, Service: &s}
+}
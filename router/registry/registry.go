@@ -346,6 +346,11 @@ func (r *rtr) watchRegistry(w registry.Watcher) error {
 			continue
 		}
 
+		// heartbeats don't change the routes
+		if res.Action == registry.Heartbeat.String() {
+			continue
+		}
+
 		logger.Tracef("Router dealing with next route %s %+v\n", res.Action, res.Service)
 
 		// get the services domain from metadata. Fallback to wildcard.
@@ -0,0 +1,185 @@
+package selector
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+var (
+	// DefaultPenalty is how long the failing routes are ejected for
+	DefaultPenalty = time.Second * 30
+	// DefaultProbeRate is the share of the selections ejected routes are
+	// probed by once their penalty expired
+	DefaultProbeRate = 0.1
+	// maxPenalty is the multiple of the penalty the ejections grow up to
+	maxPenalty = 10
+)
+
+// EventType is the type of an event of a route
+type EventType string
+
+const (
+	// Ejected routes aren't selected until their penalty expires
+	Ejected EventType = "ejected"
+	// Restored routes succeeded after being ejected
+	Restored EventType = "restored"
+)
+
+// Event of a route ejected or restored by the selector
+type Event struct {
+	Type  EventType
+	Route string
+	// Failures in a row of the route
+	Failures int
+	// Penalty of the ejected route
+	Penalty time.Duration
+}
+
+type ejection struct {
+	failures int
+	// ejections of the route without recovering
+	ejections int
+	until     time.Time
+}
+
+// Ejector ejects the routes failing ErrorThreshold times in a row from the
+// selection for the penalty. Once it expires the route is probed by a share
+// of the selections, it's restored by a success and ejected again for twice
+// the penalty by a failure.
+type Ejector struct {
+	opts Options
+
+	sync.Mutex
+	rand   *rand.Rand
+	routes map[string]*ejection
+}
+
+// NewEjector returns the ejector of the options, routes are never ejected
+// without an error threshold
+func NewEjector(o Options) *Ejector {
+	if o.Penalty <= 0 {
+		o.Penalty = DefaultPenalty
+	}
+	if o.ProbeRate <= 0 {
+		o.ProbeRate = DefaultProbeRate
+	}
+	return &Ejector{
+		opts:   o,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		routes: make(map[string]*ejection),
+	}
+}
+
+func (e *Ejector) notify(ev Event) {
+	for _, fn := range e.opts.OnEvent {
+		fn(ev)
+	}
+}
+
+// failed returns whether the error is a failure of the route rather than of
+// the request
+func failed(err error) bool {
+	if err == nil {
+		return false
+	}
+	e := errors.FromError(err)
+	return e.Code == 0 || e.Code == http.StatusRequestTimeout || e.Code >= http.StatusInternalServerError
+}
+
+// Record the result of a call to the route, errors of the request such as
+// bad requests are successes of the route
+func (e *Ejector) Record(route string, err error) {
+	if e.opts.ErrorThreshold <= 0 {
+		return
+	}
+
+	e.Lock()
+
+	r, ok := e.routes[route]
+	if !failed(err) {
+		if !ok {
+			e.Unlock()
+			return
+		}
+		delete(e.routes, route)
+		e.Unlock()
+
+		if r.ejections > 0 {
+			e.notify(Event{Type: Restored, Route: route})
+		}
+		return
+	}
+
+	if !ok {
+		r = new(ejection)
+		e.routes[route] = r
+	}
+	r.failures++
+
+	// failing probes eject the route again at once
+	if r.failures < e.opts.ErrorThreshold && r.ejections == 0 || time.Now().Before(r.until) {
+		e.Unlock()
+		return
+	}
+
+	if r.ejections < maxPenalty {
+		r.ejections++
+	}
+	penalty := e.opts.Penalty * time.Duration(1<<uint(r.ejections-1))
+	if max := e.opts.Penalty * time.Duration(maxPenalty); penalty > max {
+		penalty = max
+	}
+	r.until = time.Now().Add(penalty)
+	ev := Event{Type: Ejected, Route: route, Failures: r.failures, Penalty: penalty}
+	e.Unlock()
+
+	e.notify(ev)
+}
+
+// Filter returns the routes which aren't ejected, with the routes probed by
+// the selection, or all the routes if they're all ejected
+func (e *Ejector) Filter(routes []string) []string {
+	if e.opts.ErrorThreshold <= 0 {
+		return routes
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	if len(e.routes) == 0 {
+		return routes
+	}
+
+	now := time.Now()
+	filtered := make([]string, 0, len(routes))
+
+	for _, route := range routes {
+		r, ok := e.routes[route]
+		if !ok || r.ejections == 0 {
+			filtered = append(filtered, route)
+			continue
+		}
+		if now.Before(r.until) {
+			continue
+		}
+		if e.rand.Float64() < e.opts.ProbeRate {
+			filtered = append(filtered, route)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return routes
+	}
+	return filtered
+}
+
+// Reset restores all the routes
+func (e *Ejector) Reset() {
+	e.Lock()
+	e.routes = make(map[string]*ejection)
+	e.Unlock()
+}
@@ -0,0 +1,75 @@
+package selector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	merrors "github.com/asim/go-micro/v3/errors"
+)
+
+func TestEjector(t *testing.T) {
+	r1 := "127.0.0.1:8000"
+	r2 := "127.0.0.1:8001"
+	routes := []string{r1, r2}
+	fail := errors.New("connection refused")
+
+	var events []Event
+	e := NewEjector(NewOptions(
+		ErrorThreshold(2),
+		Penalty(time.Millisecond*50),
+		ProbeRate(1),
+		OnEvent(func(ev Event) { events = append(events, ev) }),
+	))
+
+	// errors of the request don't count
+	e.Record(r1, merrors.BadRequest("go.micro.client", "bad request"))
+	e.Record(r1, fail)
+	if len(e.Filter(routes)) != 2 {
+		t.Fatal("expected the route to be ejected after the threshold")
+	}
+
+	e.Record(r1, fail)
+	if f := e.Filter(routes); len(f) != 1 || f[0] != r2 {
+		t.Fatalf("expected the failing route to be ejected got %v", f)
+	}
+	if len(events) != 1 || events[0].Type != Ejected || events[0].Penalty != time.Millisecond*50 {
+		t.Fatalf("expected an ejected event got %+v", events)
+	}
+
+	// the route is probed once the penalty expires and a failure ejects it
+	// for twice the penalty
+	time.Sleep(time.Millisecond * 60)
+	if len(e.Filter(routes)) != 2 {
+		t.Fatal("expected the route to be probed")
+	}
+	e.Record(r1, fail)
+	if len(e.Filter(routes)) != 1 || events[1].Penalty != time.Millisecond*100 {
+		t.Fatalf("expected the route to be ejected again got %+v", events)
+	}
+
+	// a success restores it
+	time.Sleep(time.Millisecond * 110)
+	e.Record(r1, nil)
+	if len(e.Filter(routes)) != 2 || len(events) != 3 || events[2].Type != Restored {
+		t.Fatalf("expected the route to be restored got %+v", events)
+	}
+
+	// all the routes are selected rather than none
+	for i := 0; i < 2; i++ {
+		e.Record(r1, fail)
+		e.Record(r2, fail)
+	}
+	if len(e.Filter(routes)) != 2 {
+		t.Fatal("expected all the routes when they're all ejected")
+	}
+
+	// and routes aren't ejected without a threshold
+	e = NewEjector(NewOptions())
+	for i := 0; i < 10; i++ {
+		e.Record(r1, fail)
+	}
+	if len(e.Filter(routes)) != 2 {
+		t.Fatal("expected no ejection without a threshold")
+	}
+}
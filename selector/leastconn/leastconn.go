@@ -0,0 +1,88 @@
+// Package leastconn is a selector picking the route with the fewest requests
+// in flight, which balances far better than round robin when response times
+// vary widely. The client tracks the requests as it's a selector.Tracker.
+package leastconn
+
+import (
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/selector"
+)
+
+// NewSelector returns a selector picking the route with the fewest requests in
+// flight
+func NewSelector(opts ...selector.Option) selector.Selector {
+	return &leastconn{
+		opts:    selector.NewOptions(opts...),
+		pending: make(map[string]int),
+	}
+}
+
+type leastconn struct {
+	opts selector.Options
+
+	sync.Mutex
+	// pending requests of the routes
+	pending map[string]int
+	// offset of the first route compared so ties are balanced
+	offset int
+}
+
+func (l *leastconn) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	if len(routes) == 0 {
+		return nil, selector.ErrNoneAvailable
+	}
+
+	// select from the routes in the zone of the caller and its subset
+	routes = selector.Candidates(routes, l.opts, selector.NewSelectOptions(opts...))
+
+	return func() string {
+		l.Lock()
+		defer l.Unlock()
+
+		l.offset++
+
+		best := routes[l.offset%len(routes)]
+		for i := 1; i < len(routes); i++ {
+			r := routes[(l.offset+i)%len(routes)]
+			if l.pending[r] < l.pending[best] {
+				best = r
+			}
+		}
+
+		return best
+	}, nil
+}
+
+func (l *leastconn) Start(route string) {
+	l.Lock()
+	l.pending[route]++
+	l.Unlock()
+}
+
+func (l *leastconn) Done(route string, latency time.Duration, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.pending[route] <= 1 {
+		delete(l.pending, route)
+		return
+	}
+	l.pending[route]--
+}
+
+func (l *leastconn) Record(addr string, err error) error { return nil }
+
+func (l *leastconn) Reset() error {
+	l.Lock()
+	l.pending = make(map[string]int)
+	l.Unlock()
+	return nil
+}
+
+func (l *leastconn) Locality() string { return l.opts.Locality }
+
+func (l *leastconn) String() string {
+	return "leastconn"
+}
@@ -0,0 +1,41 @@
+package leastconn
+
+import (
+	"testing"
+
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeastConn(t *testing.T) {
+	selector.Tests(t, NewSelector())
+
+	r1 := "127.0.0.1:8000"
+	r2 := "127.0.0.1:8001"
+	r3 := "127.0.0.1:8002"
+
+	sel := NewSelector()
+	tr := sel.(selector.Tracker)
+
+	// the routes are balanced without requests in flight
+	next, err := sel.Select([]string{r1, r2, r3})
+	assert.Nil(t, err, "Error should be nil")
+	seen := map[string]bool{next(): true, next(): true, next(): true}
+	assert.Len(t, seen, 3, "Expected the routes to be balanced")
+
+	// the route with the fewest requests in flight is picked
+	tr.Start(r1)
+	tr.Start(r1)
+	tr.Start(r2)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, r3, next(), "Expected the idle route")
+	}
+
+	tr.Start(r3)
+	tr.Start(r3)
+	assert.Equal(t, r2, next(), "Expected the route with one request in flight")
+
+	tr.Done(r1, 0, nil)
+	tr.Done(r1, 0, nil)
+	assert.Equal(t, r1, next(), "Expected the route whose requests completed")
+}
@@ -0,0 +1,35 @@
+package selector
+
+import "math"
+
+// Localized is implemented by the selectors preferring the routes in the zone
+// of the caller, the client passes the zones of the routes with the
+// Localities option when selecting
+type Localized interface {
+	Selector
+	// Locality returns the metadata of the nodes holding their zone, none
+	// if the routes aren't selected by locality
+	Locality() string
+}
+
+// Local returns the routes in the zone of the caller, or all the routes when
+// there are fewer than the spillover share of them in the zone
+func Local(routes []string, o Options, so SelectOptions) []string {
+	if len(o.Locality) == 0 || len(o.Zone) == 0 || len(so.Localities) == 0 {
+		return routes
+	}
+
+	var local []string
+	for _, r := range routes {
+		if so.Localities[r] == o.Zone {
+			local = append(local, r)
+		}
+	}
+
+	min := int(math.Ceil(o.Spillover * float64(len(routes))))
+	if len(local) == 0 || len(local) < min {
+		return routes
+	}
+
+	return local
+}
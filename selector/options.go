@@ -1,17 +1,133 @@
 package selector
 
+import "time"
+
 // Options used to configure a selector
-type Options struct{}
+type Options struct {
+	// Locality is the metadata of the nodes holding their zone, the routes
+	// in the Zone of the caller are preferred when set
+	Locality string
+	// Zone of the caller
+	Zone string
+	// Spillover is the share of the routes below which the routes of the
+	// zone are spilled over to the other zones
+	Spillover float64
+	// SubsetSize is the number of routes each client selects from, all the
+	// routes if zero
+	SubsetSize int
+	// SubsetID is the id of the client choosing its subset
+	SubsetID string
+	// ErrorThreshold is the number of consecutive errors after which a
+	// route is ejected, routes aren't ejected if zero
+	ErrorThreshold int
+	// Penalty is how long a route is ejected for, doubled each time it's
+	// ejected again without recovering
+	Penalty time.Duration
+	// ProbeRate is the share of the selections an ejected route is probed
+	// by once its penalty expired
+	ProbeRate float64
+	// OnEvent is called when routes are ejected and restored
+	OnEvent []func(Event)
+}
 
 // Option updates the options
 type Option func(*Options)
 
 // SelectOptions used to configure selection
-type SelectOptions struct{}
+type SelectOptions struct {
+	// Weights of the routes by address, used by the weighted selectors
+	Weights map[string]int
+	// Localities are the zones of the routes by address
+	Localities map[string]string
+	// Key of the request, the hashing selectors select the same route
+	// for the same key
+	Key string
+}
 
 // SelectOption updates the select options
 type SelectOption func(*SelectOptions)
 
+// NewOptions parses the options of a selector
+func NewOptions(opts ...Option) Options {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// PreferLocality prefers the routes in the zone of the caller, read from the
+// zoneKey metadata of the nodes e.g zone=eu-west-1a
+func PreferLocality(zoneKey string) Option {
+	return func(o *Options) {
+		o.Locality = zoneKey
+	}
+}
+
+// Zone sets the zone of the caller
+func Zone(z string) Option {
+	return func(o *Options) {
+		o.Zone = z
+	}
+}
+
+// Spillover sets the share of all the routes e.g 0.2 the routes of the zone
+// must reach to be preferred, otherwise they're spilled over to all zones.
+// By default they're only spilled over when the zone has no routes.
+func Spillover(s float64) Option {
+	return func(o *Options) {
+		o.Spillover = s
+	}
+}
+
+// Subset limits the routes of the client to a subset of the size, chosen
+// deterministically by the id of the client so the clients connect to a
+// bounded number of nodes while the load is spread evenly across them
+func Subset(size int) Option {
+	return func(o *Options) {
+		o.SubsetSize = size
+	}
+}
+
+// SubsetID sets the id of the client choosing its subset, a random id of the
+// process by default
+func SubsetID(id string) Option {
+	return func(o *Options) {
+		o.SubsetID = id
+	}
+}
+
+// ErrorThreshold ejects the routes failing n times in a row, the routes are
+// ejected by the random and round robin selectors
+func ErrorThreshold(n int) Option {
+	return func(o *Options) {
+		o.ErrorThreshold = n
+	}
+}
+
+// Penalty sets how long the routes are ejected for, DefaultPenalty by default
+func Penalty(d time.Duration) Option {
+	return func(o *Options) {
+		o.Penalty = d
+	}
+}
+
+// ProbeRate sets the share of the selections e.g 0.1 ejected routes are
+// probed by once their penalty expired, DefaultProbeRate by default
+func ProbeRate(r float64) Option {
+	return func(o *Options) {
+		o.ProbeRate = r
+	}
+}
+
+// OnEvent adds a func called when routes are ejected and restored
+func OnEvent(fn func(Event)) Option {
+	return func(o *Options) {
+		o.OnEvent = append(o.OnEvent, fn)
+	}
+}
+
 // NewSelectOptions parses select options
 func NewSelectOptions(opts ...SelectOption) SelectOptions {
 	var options SelectOptions
@@ -21,3 +137,26 @@ func NewSelectOptions(opts ...SelectOption) SelectOptions {
 
 	return options
 }
+
+// Weights sets the weights of the routes by address, routes without a
+// weight have DefaultWeight
+func Weights(w map[string]int) SelectOption {
+	return func(o *SelectOptions) {
+		o.Weights = w
+	}
+}
+
+// Localities sets the zones of the routes by address
+func Localities(l map[string]string) SelectOption {
+	return func(o *SelectOptions) {
+		o.Localities = l
+	}
+}
+
+// Key sets the key of the request e.g the id of the user, requests with the
+// same key are routed to the same node by the hashing selectors
+func Key(k string) SelectOption {
+	return func(o *SelectOptions) {
+		o.Key = k
+	}
+}
@@ -0,0 +1,183 @@
+// Package p2c is a selector using the power of two choices, it picks two
+// routes at random and selects the one with the lowest cost, the moving
+// average of its latency weighted by its requests in flight. Slow, pausing or
+// failing routes receive less traffic without being excluded. The client
+// tracks the requests as it's a selector.Tracker.
+package p2c
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/selector"
+)
+
+var (
+	// DefaultDecay is the time over which the latency of a route decays
+	DefaultDecay = time.Second * 10
+	// DefaultLatency of the routes without latency while none of the
+	// others have one
+	DefaultLatency = time.Millisecond * 10
+	// DefaultPenalty is the latency recorded for failed requests which
+	// fail faster, so failing routes aren't preferred for failing fast
+	DefaultPenalty = time.Second * 5
+)
+
+// NewSelector returns a power of two choices selector
+func NewSelector(opts ...selector.Option) selector.Selector {
+	return &p2c{
+		opts:  selector.NewOptions(opts...),
+		decay: DefaultDecay,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		stats: make(map[string]*stat),
+	}
+}
+
+// stat is the latency and load of a route
+type stat struct {
+	// ewma of the latency in nanoseconds
+	ewma    float64
+	pending int
+	updated time.Time
+}
+
+type p2c struct {
+	opts  selector.Options
+	decay time.Duration
+
+	sync.Mutex
+	rand  *rand.Rand
+	stats map[string]*stat
+}
+
+// cost returns the cost of the route, routes without latency cost the
+// average latency of the routes so they're probed without being flooded
+func (p *p2c) cost(route string, average float64) float64 {
+	s, ok := p.stats[route]
+	if !ok {
+		return average
+	}
+	if s.ewma == 0 {
+		return average * float64(s.pending+1)
+	}
+	return s.ewma * float64(s.pending+1)
+}
+
+// average returns the average latency of the routes with one
+func (p *p2c) average(routes []string) float64 {
+	var sum float64
+	var n int
+	for _, r := range routes {
+		if s, ok := p.stats[r]; ok && s.ewma > 0 {
+			sum += s.ewma
+			n++
+		}
+	}
+	if n == 0 {
+		return float64(DefaultLatency)
+	}
+	return sum / float64(n)
+}
+
+func (p *p2c) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	if len(routes) == 0 {
+		return nil, selector.ErrNoneAvailable
+	}
+
+	// select from the routes in the zone of the caller and its subset
+	routes = selector.Candidates(routes, p.opts, selector.NewSelectOptions(opts...))
+
+	return func() string {
+		if len(routes) == 1 {
+			return routes[0]
+		}
+
+		p.Lock()
+		defer p.Unlock()
+
+		// pick two distinct routes
+		i := p.rand.Intn(len(routes))
+		j := p.rand.Intn(len(routes) - 1)
+		if j >= i {
+			j++
+		}
+
+		avg := p.average(routes)
+		if p.cost(routes[j], avg) < p.cost(routes[i], avg) {
+			return routes[j]
+		}
+		return routes[i]
+	}, nil
+}
+
+func (p *p2c) Start(route string) {
+	p.Lock()
+	defer p.Unlock()
+
+	s, ok := p.stats[route]
+	if !ok {
+		s = &stat{updated: time.Now()}
+		p.stats[route] = s
+	}
+	s.pending++
+}
+
+// Done updates the moving average of the latency, it rises to the latency of
+// slower requests at once so pauses are avoided quickly, and decays with
+// faster ones. Failures record at least the DefaultPenalty.
+func (p *p2c) Done(route string, latency time.Duration, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	s, ok := p.stats[route]
+	if !ok {
+		s = &stat{pending: 1}
+		p.stats[route] = s
+	}
+	if s.pending > 0 {
+		s.pending--
+	}
+
+	now := time.Now()
+	rtt := float64(latency)
+	if failed(err) && latency < DefaultPenalty {
+		rtt = float64(DefaultPenalty)
+	}
+
+	if s.ewma == 0 || rtt > s.ewma {
+		s.ewma = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(s.updated)) / float64(p.decay))
+		s.ewma = s.ewma*w + rtt*(1-w)
+	}
+	s.updated = now
+}
+
+// failed returns true if the error is a timeout or server error, errors of
+// the request such as bad requests are successes of the route
+func failed(err error) bool {
+	if err == nil {
+		return false
+	}
+	e := errors.FromError(err)
+	return e.Code == 0 || e.Code == http.StatusRequestTimeout || e.Code >= http.StatusInternalServerError
+}
+
+func (p *p2c) Record(addr string, err error) error { return nil }
+
+func (p *p2c) Reset() error {
+	p.Lock()
+	p.stats = make(map[string]*stat)
+	p.Unlock()
+	return nil
+}
+
+func (p *p2c) Locality() string { return p.opts.Locality }
+
+func (p *p2c) String() string {
+	return "p2c"
+}
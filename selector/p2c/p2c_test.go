@@ -0,0 +1,109 @@
+package p2c
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestP2C(t *testing.T) {
+	selector.Tests(t, NewSelector())
+
+	r1 := "127.0.0.1:8000"
+	r2 := "127.0.0.1:8001"
+	r3 := "127.0.0.1:8002"
+
+	sel := NewSelector()
+	tr := sel.(selector.Tracker)
+
+	for _, r := range []string{r1, r2, r3} {
+		tr.Start(r)
+	}
+	tr.Done(r1, time.Millisecond, nil)
+	tr.Done(r2, time.Millisecond*2, nil)
+	tr.Done(r3, time.Millisecond*100, nil)
+
+	// the slowest route loses every choice it's part of
+	next, err := sel.Select([]string{r1, r2, r3})
+	assert.Nil(t, err, "Error should be nil")
+
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		counts[next()]++
+	}
+	assert.Equal(t, 0, counts[r3], "Expected the slow route to receive no requests")
+	assert.True(t, counts[r1] > counts[r2], "Expected the fastest route to receive the most requests")
+
+	// requests in flight raise the cost of the route
+	for i := 0; i < 3; i++ {
+		tr.Start(r1)
+	}
+	next, _ = sel.Select([]string{r1, r2})
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, r2, next(), "Expected the loaded route to be avoided")
+	}
+
+	// and the latency decays with faster requests
+	p := sel.(*p2c)
+	p.stats[r3].updated = time.Now().Add(-DefaultDecay * 10)
+	tr.Start(r3)
+	tr.Done(r3, time.Millisecond, nil)
+	assert.InDelta(t, float64(time.Millisecond), p.stats[r3].ewma, float64(time.Microsecond*10), "Expected the latency to decay")
+}
+
+func TestP2CFailures(t *testing.T) {
+	r1 := "127.0.0.1:8000"
+	r2 := "127.0.0.1:8001"
+
+	sel := NewSelector()
+	tr := sel.(selector.Tracker)
+
+	// r2 fails faster than r1 succeeds
+	for i := 0; i < 10; i++ {
+		tr.Start(r1)
+		tr.Done(r1, time.Millisecond*10, nil)
+		tr.Start(r2)
+		tr.Done(r2, time.Microsecond, errors.InternalServerError("test", "failed"))
+	}
+
+	next, err := sel.Select([]string{r1, r2})
+	assert.Nil(t, err, "Error should be nil")
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, r1, next(), "Expected the failing route to lose traffic")
+	}
+
+	// errors of the request don't penalise the route
+	sel = NewSelector()
+	tr = sel.(selector.Tracker)
+	tr.Start(r1)
+	tr.Done(r1, time.Millisecond*10, nil)
+	tr.Start(r2)
+	tr.Done(r2, time.Millisecond, errors.BadRequest("test", "bad"))
+
+	next, _ = sel.Select([]string{r1, r2})
+	assert.Equal(t, r2, next(), "Expected the bad request not to penalise the route")
+}
+
+func TestP2CPending(t *testing.T) {
+	r1 := "127.0.0.1:8000"
+	r2 := "127.0.0.1:8001"
+
+	sel := NewSelector()
+	tr := sel.(selector.Tracker)
+
+	tr.Start(r1)
+	tr.Done(r1, time.Millisecond, nil)
+
+	// a route without latency still costs its requests in flight
+	for i := 0; i < 5; i++ {
+		tr.Start(r2)
+	}
+
+	next, _ := sel.Select([]string{r1, r2})
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, r1, next(), "Expected the loaded route without latency to be avoided")
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"github.com/asim/go-micro/v3/selector"
 )
 
-type random struct{}
+type random struct {
+	opts   selector.Options
+	ejects *selector.Ejector
+}
 
 func (r *random) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
 	// we can't select from an empty pool of routes
@@ -14,6 +17,12 @@ func (r *random) Select(routes []string, opts ...selector.SelectOption) (selecto
 		return nil, selector.ErrNoneAvailable
 	}
 
+	// select from the routes in the zone of the caller and its subset
+	routes = selector.Candidates(routes, r.opts, selector.NewSelectOptions(opts...))
+
+	// skip the routes ejected for failing
+	routes = r.ejects.Filter(routes)
+
 	// return the next func
 	return func() string {
 		// if there is only one route provided we'll select it
@@ -27,18 +36,28 @@ func (r *random) Select(routes []string, opts ...selector.SelectOption) (selecto
 }
 
 func (r *random) Record(addr string, err error) error {
+	r.ejects.Record(addr, err)
 	return nil
 }
 
 func (r *random) Reset() error {
+	r.ejects.Reset()
 	return nil
 }
 
+func (r *random) Locality() string {
+	return r.opts.Locality
+}
+
 func (r *random) String() string {
 	return "random"
 }
 
 // NewSelector returns a random selector
 func NewSelector(opts ...selector.Option) selector.Selector {
-	return new(random)
+	options := selector.NewOptions(opts...)
+	return &random{
+		opts:   options,
+		ejects: selector.NewEjector(options),
+	}
 }
@@ -0,0 +1,151 @@
+// Package ringhash is a selector routing the requests by the hash of their
+// key on a consistent hash ring, so requests with the same key land on the
+// same route, and only the keys of a route move when it's added or removed.
+// The key is set with client.WithSelectKey, requests without a key are
+// balanced round robin.
+package ringhash
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/asim/go-micro/v3/selector"
+)
+
+var (
+	// DefaultReplicas is the number of points of each route on the ring
+	DefaultReplicas = 100
+	// maxRings is the number of rings of the sets of routes kept
+	maxRings = 64
+)
+
+// NewSelector returns a consistent hashing selector
+func NewSelector(opts ...selector.Option) selector.Selector {
+	return &ringhash{
+		opts:  selector.NewOptions(opts...),
+		rings: make(map[string]*ring),
+	}
+}
+
+type point struct {
+	hash  uint64
+	route string
+}
+
+// ring of the points of a set of routes ordered by hash
+type ring struct {
+	points []point
+	routes int
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	// mix the bits as fnv of similar strings are close
+	v := h.Sum64()
+	v ^= v >> 33
+	v *= 0xff51afd7ed558ccd
+	v ^= v >> 33
+	return v
+}
+
+func newRing(routes []string) *ring {
+	r := &ring{routes: len(routes)}
+	for _, route := range routes {
+		for i := 0; i < DefaultReplicas; i++ {
+			r.points = append(r.points, point{hash(route + "-" + strconv.Itoa(i)), route})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return r
+}
+
+// walk returns the distinct routes from the point of the key clockwise
+func (r *ring) walk(key string) []string {
+	h := hash(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+
+	seen := make(map[string]bool, r.routes)
+	routes := make([]string, 0, r.routes)
+	for i := 0; i < len(r.points) && len(routes) < r.routes; i++ {
+		p := r.points[(start+i)%len(r.points)]
+		if seen[p.route] {
+			continue
+		}
+		seen[p.route] = true
+		routes = append(routes, p.route)
+	}
+	return routes
+}
+
+type ringhash struct {
+	opts selector.Options
+
+	sync.Mutex
+	// rings by the set of routes
+	rings map[string]*ring
+}
+
+func (r *ringhash) ring(routes []string) *ring {
+	sorted := append([]string{}, routes...)
+	sort.Strings(sorted)
+	id := strings.Join(sorted, ",")
+
+	r.Lock()
+	defer r.Unlock()
+
+	if rg, ok := r.rings[id]; ok {
+		return rg
+	}
+	// the rings of past sets of routes are dropped
+	if len(r.rings) >= maxRings {
+		r.rings = make(map[string]*ring)
+	}
+	rg := newRing(sorted)
+	r.rings[id] = rg
+	return rg
+}
+
+func (r *ringhash) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	if len(routes) == 0 {
+		return nil, selector.ErrNoneAvailable
+	}
+
+	options := selector.NewSelectOptions(opts...)
+
+	// prefer the routes in the zone of the caller, the routes aren't
+	// subset so the clients agree on the route of a key
+	routes = selector.Local(routes, r.opts, options)
+
+	// the routes after the first are those the key moves to if it's not
+	// available, used when retrying
+	if len(options.Key) > 0 && len(routes) > 1 {
+		routes = r.ring(routes).walk(options.Key)
+	}
+
+	var i int
+
+	return func() string {
+		route := routes[i%len(routes)]
+		i++
+		return route
+	}, nil
+}
+
+func (r *ringhash) Record(addr string, err error) error { return nil }
+
+func (r *ringhash) Reset() error {
+	r.Lock()
+	r.rings = make(map[string]*ring)
+	r.Unlock()
+	return nil
+}
+
+func (r *ringhash) Locality() string { return r.opts.Locality }
+
+func (r *ringhash) String() string {
+	return "ringhash"
+}
@@ -0,0 +1,53 @@
+package ringhash
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingHash(t *testing.T) {
+	selector.Tests(t, NewSelector())
+
+	r1 := "127.0.0.1:8000"
+	r2 := "127.0.0.1:8001"
+	r3 := "127.0.0.1:8002"
+
+	sel := NewSelector()
+
+	route := func(key string, routes ...string) string {
+		next, err := sel.Select(routes, selector.Key(key))
+		assert.Nil(t, err, "Error should be nil")
+		return next()
+	}
+
+	// the same key lands on the same route whatever the order of the routes
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		owners[key] = route(key, r1, r2, r3)
+		assert.Equal(t, owners[key], route(key, r3, r1, r2), "Expected the same route for the key")
+		counts[owners[key]]++
+	}
+
+	// the keys are spread across the routes
+	for _, r := range []string{r1, r2, r3} {
+		assert.True(t, counts[r] > 600, "Expected %s to own a share of the keys got %d", r, counts[r])
+	}
+
+	// only the keys of a removed route move
+	for key, owner := range owners {
+		if owner == r3 {
+			continue
+		}
+		assert.Equal(t, owner, route(key, r1, r2), "Expected the key to stay on its route")
+	}
+
+	// retries move to the other routes
+	next, _ := sel.Select([]string{r1, r2, r3}, selector.Key("user-1"))
+	seen := map[string]bool{next(): true, next(): true, next(): true}
+	assert.Len(t, seen, 3, "Expected the retries to try the other routes")
+}
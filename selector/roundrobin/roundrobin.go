@@ -6,16 +6,29 @@ import (
 
 // NewSelector returns an initalised round robin selector
 func NewSelector(opts ...selector.Option) selector.Selector {
-	return new(roundrobin)
+	options := selector.NewOptions(opts...)
+	return &roundrobin{
+		opts:   options,
+		ejects: selector.NewEjector(options),
+	}
 }
 
-type roundrobin struct{}
+type roundrobin struct {
+	opts   selector.Options
+	ejects *selector.Ejector
+}
 
 func (r *roundrobin) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
 	if len(routes) == 0 {
 		return nil, selector.ErrNoneAvailable
 	}
 
+	// select from the routes in the zone of the caller and its subset
+	routes = selector.Candidates(routes, r.opts, selector.NewSelectOptions(opts...))
+
+	// skip the routes ejected for failing
+	routes = r.ejects.Filter(routes)
+
 	var i int
 
 	return func() string {
@@ -26,9 +39,17 @@ func (r *roundrobin) Select(routes []string, opts ...selector.SelectOption) (sel
 	}, nil
 }
 
-func (r *roundrobin) Record(addr string, err error) error { return nil }
+func (r *roundrobin) Record(addr string, err error) error {
+	r.ejects.Record(addr, err)
+	return nil
+}
+
+func (r *roundrobin) Reset() error {
+	r.ejects.Reset()
+	return nil
+}
 
-func (r *roundrobin) Reset() error { return nil }
+func (r *roundrobin) Locality() string { return r.opts.Locality }
 
 func (r *roundrobin) String() string {
 	return "roundrobin"
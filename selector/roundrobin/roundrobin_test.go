@@ -38,3 +38,28 @@ func TestRoundRobin(t *testing.T) {
 	assert.Equal(t, r3, n3, "Expected route to be r3")
 
 }
+
+func TestRoundRobinLocality(t *testing.T) {
+	r1 := "127.0.0.1:8000"
+	r2 := "127.0.0.1:8001"
+	r3 := "127.0.0.1:8002"
+	zones := selector.Localities(map[string]string{r1: "a", r2: "b", r3: "b"})
+
+	sel := NewSelector(selector.PreferLocality("zone"), selector.Zone("b"))
+	assert.Equal(t, "zone", sel.(selector.Localized).Locality())
+
+	// the routes of the zone are preferred
+	next, err := sel.Select([]string{r1, r2, r3}, zones)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, []string{r2, r3, r2}, []string{next(), next(), next()}, "Expected the routes of zone b")
+
+	// and spilled over when there are too few of them
+	sel = NewSelector(selector.PreferLocality("zone"), selector.Zone("a"), selector.Spillover(0.5))
+	next, _ = sel.Select([]string{r1, r2, r3}, zones)
+	assert.Equal(t, []string{r1, r2, r3}, []string{next(), next(), next()}, "Expected the routes of all zones")
+
+	// or none at all
+	sel = NewSelector(selector.PreferLocality("zone"), selector.Zone("c"))
+	next, _ = sel.Select([]string{r1, r2, r3}, zones)
+	assert.Equal(t, []string{r1, r2, r3}, []string{next(), next(), next()}, "Expected the routes of all zones")
+}
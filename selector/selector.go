@@ -3,11 +3,16 @@ package selector
 
 import (
 	"errors"
+	"time"
 )
 
 var (
 	// ErrNoneAvailable is returned by select when no routes were provided to select from
 	ErrNoneAvailable = errors.New("none available")
+	// WeightKey is the metadata of the nodes holding their weight e.g weight=30
+	WeightKey = "weight"
+	// DefaultWeight of the routes without a weight
+	DefaultWeight = 100
 )
 
 // Selector selects a route from a pool
@@ -24,3 +29,23 @@ type Selector interface {
 
 // Next returns the next node
 type Next func() string
+
+// Weighted is implemented by the selectors balancing by the weights of the
+// routes, the client passes them with the Weights option when selecting
+type Weighted interface {
+	Selector
+	// Weighted marks the selector as using the weights
+	Weighted()
+}
+
+// Tracker is implemented by the selectors balancing by the requests in flight
+// to the routes or their latency, the client calls Start before each request
+// and Done once it completes
+type Tracker interface {
+	Selector
+	// Start records a request in flight to the route
+	Start(route string)
+	// Done records the completion of a request to the route, its latency
+	// and error
+	Done(route string, latency time.Duration, err error)
+}
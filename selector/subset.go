@@ -0,0 +1,125 @@
+package selector
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// processID is the subset id of the clients without one
+var processID = uuid.New().String()
+
+// maxSubsets is the max number of route sets the shuffles are cached for
+const maxSubsets = 64
+
+// subsets caches the shuffled routes by route set and round, seeding the
+// source and shuffling on every select is expensive
+var subsets = struct {
+	sync.Mutex
+	m map[subsetKey]*subsetEntry
+}{m: make(map[subsetKey]*subsetEntry)}
+
+type subsetKey struct {
+	// hash of the route set whatever the order of the routes
+	hash  uint64
+	n     int
+	round uint64
+}
+
+type subsetEntry struct {
+	routes map[string]bool
+	// shuffled routes of the round
+	shuffled []string
+}
+
+// match returns true if the entry is of the routes rather than a collision
+func (e *subsetEntry) match(routes []string) bool {
+	for _, r := range routes {
+		if !e.routes[r] {
+			return false
+		}
+	}
+	return true
+}
+
+// hashRoutes returns the sum of the fnv-1a hashes of the routes
+func hashRoutes(routes []string) uint64 {
+	var sum uint64
+	for _, r := range routes {
+		h := uint64(14695981039346656037)
+		for i := 0; i < len(r); i++ {
+			h ^= uint64(r[i])
+			h *= 1099511628211
+		}
+		sum += h
+	}
+	return sum
+}
+
+// Candidates returns the routes the selector selects from, those in the zone
+// of the caller and its subset
+func Candidates(routes []string, o Options, so SelectOptions) []string {
+	return subset(Local(routes, o, so), o)
+}
+
+// subset returns the subset of the routes of the client. The clients are
+// split in rounds of as many clients as there are subsets, each round
+// shuffling the routes differently, so every route is in the subset of the
+// same number of clients.
+func subset(routes []string, o Options) []string {
+	if o.SubsetSize <= 0 || len(routes) <= o.SubsetSize {
+		return routes
+	}
+
+	id := o.SubsetID
+	if len(id) == 0 {
+		id = processID
+	}
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	client := h.Sum64()
+
+	count := uint64(len(routes) / o.SubsetSize)
+	round := client / count
+	start := int(client%count) * o.SubsetSize
+
+	key := subsetKey{
+		hash:  hashRoutes(routes),
+		n:     len(routes),
+		round: round,
+	}
+
+	subsets.Lock()
+	defer subsets.Unlock()
+
+	if e, ok := subsets.m[key]; ok && e.match(routes) {
+		return append([]string{}, e.shuffled[start:start+o.SubsetSize]...)
+	}
+
+	sorted := append([]string{}, routes...)
+	sort.Strings(sorted)
+	r := rand.New(rand.NewSource(int64(round)))
+	r.Shuffle(len(sorted), func(i, j int) { sorted[i], sorted[j] = sorted[j], sorted[i] })
+
+	e := &subsetEntry{
+		routes:   make(map[string]bool, len(routes)),
+		shuffled: sorted,
+	}
+	for _, r := range routes {
+		e.routes[r] = true
+	}
+
+	// make room by evicting any subset
+	if len(subsets.m) >= maxSubsets {
+		for k := range subsets.m {
+			delete(subsets.m, k)
+			break
+		}
+	}
+	subsets.m[key] = e
+
+	return append([]string{}, sorted[start:start+o.SubsetSize]...)
+}
@@ -0,0 +1,78 @@
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestSubset(t *testing.T) {
+	var routes []string
+	for i := 0; i < 12; i++ {
+		routes = append(routes, fmt.Sprintf("10.0.0.%d:8080", i))
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		o := NewOptions(Subset(3), SubsetID(fmt.Sprintf("client-%d", i)))
+		s := Candidates(routes, o, SelectOptions{})
+		if len(s) != 3 {
+			t.Fatalf("expected a subset of 3 routes got %v", s)
+		}
+		for _, r := range s {
+			counts[r]++
+		}
+
+		// the subset is the same whatever the order of the routes
+		rev := append([]string{}, routes...)
+		sort.Sort(sort.Reverse(sort.StringSlice(rev)))
+		if fmt.Sprint(Candidates(rev, o, SelectOptions{})) != fmt.Sprint(s) {
+			t.Fatalf("expected the same subset for client-%d", i)
+		}
+	}
+
+	// every route is in the subset of about a quarter of the clients
+	for _, r := range routes {
+		if counts[r] < 60 || counts[r] > 140 {
+			t.Fatalf("expected the routes to be spread evenly got %v", counts)
+		}
+	}
+
+	// all the routes are used without a subset size
+	if s := Candidates(routes, NewOptions(), SelectOptions{}); len(s) != len(routes) {
+		t.Fatalf("expected all the routes got %v", s)
+	}
+}
+
+func TestSubsetCache(t *testing.T) {
+	var routes []string
+	for i := 0; i < 12; i++ {
+		routes = append(routes, fmt.Sprintf("10.0.0.%d:8080", i))
+	}
+
+	o := NewOptions(Subset(3), SubsetID("client"))
+
+	s := Candidates(routes, o, SelectOptions{})
+	expect := fmt.Sprint(s)
+
+	// the cached subset isn't changed through the routes returned
+	s[0] = "changed"
+	if got := fmt.Sprint(Candidates(routes, o, SelectOptions{})); got != expect {
+		t.Fatalf("expected the cached subset %s got %s", expect, got)
+	}
+
+	// the shuffle isn't repeated once cached
+	if n := testing.AllocsPerRun(10, func() { Candidates(routes, o, SelectOptions{}) }); n > 1 {
+		t.Fatalf("expected the subset to be cached got %v allocations", n)
+	}
+
+	// a different route set gets its own subset
+	if s := Candidates(routes[1:], o, SelectOptions{}); len(s) != 3 {
+		t.Fatalf("expected a subset of 3 routes got %v", s)
+	}
+	for _, r := range Candidates(routes[:6], o, SelectOptions{}) {
+		if r == routes[11] {
+			t.Fatalf("expected the subset of the route set got %s", r)
+		}
+	}
+}
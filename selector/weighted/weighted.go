@@ -0,0 +1,102 @@
+// Package weighted is a selector balancing the routes by their weight with a
+// smooth weighted round robin, so routes of weight 30 and 10 receive three
+// times as many requests as the other one, interleaved rather than in bursts.
+package weighted
+
+import (
+	"sync"
+
+	"github.com/asim/go-micro/v3/selector"
+)
+
+// NewSelector returns an initialised weighted round robin selector
+func NewSelector(opts ...selector.Option) selector.Selector {
+	return &weighted{
+		opts:    selector.NewOptions(opts...),
+		current: make(map[string]int),
+	}
+}
+
+type weighted struct {
+	opts selector.Options
+
+	sync.Mutex
+	// current weights of the routes, kept across selections so the
+	// proportions hold for calls selecting once
+	current map[string]int
+}
+
+// weight returns the weight of the route, DefaultWeight if it has none
+func weight(w map[string]int, route string) int {
+	v, ok := w[route]
+	if !ok {
+		return selector.DefaultWeight
+	}
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+func (w *weighted) Select(routes []string, opts ...selector.SelectOption) (selector.Next, error) {
+	if len(routes) == 0 {
+		return nil, selector.ErrNoneAvailable
+	}
+
+	options := selector.NewSelectOptions(opts...)
+
+	// select from the routes in the zone of the caller and its subset
+	routes = selector.Candidates(routes, w.opts, options)
+
+	weights := make([]int, len(routes))
+	var total int
+	for i, r := range routes {
+		weights[i] = weight(options.Weights, r)
+		total += weights[i]
+	}
+
+	// routes are balanced evenly when none has a weight
+	if total == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = len(weights)
+	}
+
+	return func() string {
+		w.Lock()
+		defer w.Unlock()
+
+		best := -1
+		for i, r := range routes {
+			if weights[i] == 0 {
+				continue
+			}
+			w.current[r] += weights[i]
+			if best < 0 || w.current[r] > w.current[routes[best]] {
+				best = i
+			}
+		}
+
+		route := routes[best]
+		w.current[route] -= total
+		return route
+	}, nil
+}
+
+func (w *weighted) Record(addr string, err error) error { return nil }
+
+func (w *weighted) Reset() error {
+	w.Lock()
+	w.current = make(map[string]int)
+	w.Unlock()
+	return nil
+}
+
+func (w *weighted) Weighted() {}
+
+func (w *weighted) Locality() string { return w.opts.Locality }
+
+func (w *weighted) String() string {
+	return "weighted"
+}
@@ -0,0 +1,49 @@
+package weighted
+
+import (
+	"testing"
+
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeighted(t *testing.T) {
+	selector.Tests(t, NewSelector())
+
+	r1 := "127.0.0.1:8000"
+	r2 := "127.0.0.1:8001"
+	r3 := "127.0.0.1:8002"
+
+	sel := NewSelector()
+	weights := selector.Weights(map[string]int{r1: 30, r2: 10, r3: 0})
+
+	// the routes are selected in proportion of their weight, interleaved
+	next, err := sel.Select([]string{r1, r2, r3}, weights)
+	assert.Nil(t, err, "Error should be nil")
+
+	var seq []string
+	for i := 0; i < 4; i++ {
+		seq = append(seq, next())
+	}
+	assert.Equal(t, []string{r1, r1, r2, r1}, seq, "Expected the smooth weighted sequence")
+
+	// and the proportions hold when selecting once per call
+	counts := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		next, _ := sel.Select([]string{r1, r2, r3}, weights)
+		counts[next()]++
+	}
+	assert.Equal(t, 300, counts[r1], "Expected r1 to receive three quarters of the calls")
+	assert.Equal(t, 100, counts[r2], "Expected r2 to receive a quarter of the calls")
+	assert.Equal(t, 0, counts[r3], "Expected r3 of weight 0 to receive none")
+
+	// routes without a weight have the default weight
+	assert.Nil(t, sel.Reset())
+	next, _ = sel.Select([]string{r1, r2}, selector.Weights(map[string]int{r1: selector.DefaultWeight * 3}))
+	counts = make(map[string]int)
+	for i := 0; i < 8; i++ {
+		counts[next()]++
+	}
+	assert.Equal(t, 6, counts[r1], "Expected r1 to receive three times the calls of r2")
+	assert.Equal(t, 2, counts[r2], "Expected r2 to receive the default share")
+}
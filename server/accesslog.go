@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/metadata"
+)
+
+const (
+	// AccessLogMiddleware is the name of the access log middleware
+	AccessLogMiddleware = "access_log"
+
+	// FromServiceKey is the metadata key of the calling service
+	FromServiceKey = "Micro-From-Service"
+
+	// redacted replaces the value of redacted headers
+	redacted = "[redacted]"
+)
+
+var (
+	// DefaultAccessLogFields are logged unless set with AccessLogFields
+	DefaultAccessLogFields = []string{"endpoint", "caller", "latency", "status", "request_size"}
+)
+
+// AccessLogOptions configure the access log
+type AccessLogOptions struct {
+	// Logger the entries are written to
+	Logger logger.Logger
+	// Level the entries are logged at
+	Level logger.Level
+	// SampleRate is the fraction of successful requests logged.
+	// Failed requests are always logged.
+	SampleRate float64
+	// Fields of the entries, one of service, endpoint, caller, remote,
+	// latency, status, code, request_size and header
+	Fields []string
+	// Redact the values of these headers in the header field
+	Redact []string
+}
+
+// AccessLogOption sets values in AccessLogOptions
+type AccessLogOption func(*AccessLogOptions)
+
+// AccessLogLogger sets the logger the entries are written to
+func AccessLogLogger(l logger.Logger) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Logger = l
+	}
+}
+
+// AccessLogLevel sets the level the entries are logged at
+func AccessLogLevel(l logger.Level) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Level = l
+	}
+}
+
+// AccessLogSample logs the fraction of successful requests e.g 0.1
+func AccessLogSample(rate float64) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.SampleRate = rate
+	}
+}
+
+// AccessLogFields sets the fields of the entries
+func AccessLogFields(fields ...string) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Fields = fields
+	}
+}
+
+// AccessLogRedact redacts the values of the headers e.g Authorization
+func AccessLogRedact(headers ...string) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Redact = append(o.Redact, headers...)
+	}
+}
+
+// sizer is implemented by requests which know their encoded size
+type sizer interface {
+	Size() int
+}
+
+// AccessLog logs an entry for each request handled. It's added as the
+// outermost handler wrapper so the latency includes the other wrappers.
+func AccessLog(opts ...AccessLogOption) Option {
+	options := AccessLogOptions{
+		Logger:     logger.DefaultLogger,
+		Level:      logger.InfoLevel,
+		SampleRate: 1,
+		Fields:     DefaultAccessLogFields,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return func(o *Options) {
+		use(o, 0, Middleware{Name: AccessLogMiddleware, Wrapper: accessLog(options)})
+	}
+}
+
+func accessLog(opts AccessLogOptions) HandlerWrapper {
+	// header keys are matched case insensitively
+	redact := make(map[string]bool, len(opts.Redact))
+	for _, h := range opts.Redact {
+		redact[strings.ToLower(h)] = true
+	}
+
+	return func(fn HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req Request, rsp interface{}) error {
+			start := time.Now()
+
+			err := fn(ctx, req, rsp)
+
+			if err == nil && opts.SampleRate < 1 && rand.Float64() >= opts.SampleRate {
+				return err
+			}
+
+			code := int32(200)
+			status := "ok"
+			if err != nil {
+				e := errors.FromError(err)
+				code = e.Code
+				status = e.Status
+				if len(status) == 0 {
+					status = e.Detail
+				}
+			}
+
+			fields := make(map[string]interface{}, len(opts.Fields))
+
+			for _, f := range opts.Fields {
+				switch f {
+				case "service":
+					fields[f] = req.Service()
+				case "endpoint":
+					fields[f] = req.Endpoint()
+				case "caller":
+					caller, _ := metadata.Get(ctx, FromServiceKey)
+					fields[f] = caller
+				case "remote":
+					remote, _ := metadata.Get(ctx, "Remote")
+					fields[f] = remote
+				case "latency":
+					fields[f] = time.Since(start).String()
+				case "status":
+					fields[f] = status
+				case "code":
+					fields[f] = code
+				case "request_size":
+					if s, ok := req.(sizer); ok {
+						fields[f] = s.Size()
+					}
+				case "header":
+					hdr := make(map[string]string, len(req.Header()))
+					for k, v := range req.Header() {
+						if redact[strings.ToLower(k)] {
+							v = redacted
+						}
+						hdr[k] = v
+					}
+					fields[f] = hdr
+				}
+			}
+
+			opts.Logger.Fields(fields).Log(opts.Level, "access")
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/metadata"
+)
+
+type testLogger struct {
+	logger.Logger
+	entries []map[string]interface{}
+}
+
+func (l *testLogger) Fields(fields map[string]interface{}) logger.Logger {
+	return &fieldLogger{Logger: l, root: l, fields: fields}
+}
+
+type fieldLogger struct {
+	logger.Logger
+	root   *testLogger
+	fields map[string]interface{}
+}
+
+func (l *fieldLogger) Log(level logger.Level, v ...interface{}) {
+	l.root.entries = append(l.root.entries, l.fields)
+}
+
+type testRequest struct {
+	Request
+	header map[string]string
+}
+
+func (r *testRequest) Service() string           { return "test.service" }
+func (r *testRequest) Endpoint() string          { return "Test.Call" }
+func (r *testRequest) Header() map[string]string { return r.header }
+func (r *testRequest) Size() int                 { return 42 }
+
+func TestAccessLog(t *testing.T) {
+	l := new(testLogger)
+
+	var o Options
+	AccessLog(
+		AccessLogLogger(l),
+		AccessLogFields("endpoint", "caller", "code", "request_size", "header"),
+		AccessLogRedact("authorization", "X-Api-Key"),
+	)(&o)
+
+	if len(o.Middleware) != 1 || o.Middleware[0].Name != AccessLogMiddleware {
+		t.Fatalf("expected the access log middleware got %+v", o.Middleware)
+	}
+
+	fn := o.HdlrWrappers[0](func(ctx context.Context, req Request, rsp interface{}) error {
+		return errors.NotFound("test.service", "not found")
+	})
+
+	ctx := metadata.Set(context.Background(), FromServiceKey, "caller.service")
+	req := &testRequest{header: map[string]string{"Authorization": "Bearer secret", "x-api-key": "secret", "Tenant": "acme"}}
+
+	fn(ctx, req, nil)
+
+	if len(l.entries) != 1 {
+		t.Fatalf("expected 1 entry got %d", len(l.entries))
+	}
+
+	e := l.entries[0]
+	if e["endpoint"] != "Test.Call" || e["caller"] != "caller.service" || e["code"] != int32(404) || e["request_size"] != 42 {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if _, ok := e["latency"]; ok {
+		t.Fatal("expected only the configured fields")
+	}
+
+	hdr := e["header"].(map[string]string)
+	// whatever the case of the header keys
+	if hdr["Authorization"] != redacted || hdr["x-api-key"] != redacted || hdr["Tenant"] != "acme" {
+		t.Fatalf("expected the authorization and api key headers to be redacted got %+v", hdr)
+	}
+
+	// successful requests are sampled, errors are always logged
+	o = Options{}
+	AccessLog(AccessLogLogger(l), AccessLogSample(0))(&o)
+	fn = o.HdlrWrappers[0](func(ctx context.Context, req Request, rsp interface{}) error {
+		return nil
+	})
+	fn(ctx, req, nil)
+
+	if len(l.entries) != 1 {
+		t.Fatalf("expected the request not to be sampled got %d entries", len(l.entries))
+	}
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/asim/go-micro/v3/debug/stats"
+	hhttp "github.com/asim/go-micro/v3/health/http"
+	memHealth "github.com/asim/go-micro/v3/health/memory"
+	"github.com/asim/go-micro/v3/transport"
+)
+
+// AdminServer serves /healthz, /readyz, /metrics and /debug/pprof over
+// http next to the rpc server
+type AdminServer struct {
+	sync.Mutex
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewAdminServer returns the admin server for the options. The health
+// checks and stats are taken from the options if set.
+func NewAdminServer(opts Options) *AdminServer {
+	h := opts.Health
+	if h == nil {
+		h = memHealth.NewHealth()
+	}
+
+	mux := http.NewServeMux()
+
+	hh := hhttp.NewHandler(h)
+	mux.Handle("/healthz", hh)
+	mux.Handle("/readyz", hh)
+	var bm *broker.Metrics
+	if opts.Broker != nil {
+		bm = opts.Broker.Options().Metrics
+	}
+	var tm *transport.Metrics
+	if opts.Transport != nil {
+		tm = opts.Transport.Options().Metrics
+	}
+	mux.HandleFunc("/metrics", metrics(opts.Name, opts.Stats, bm, tm, opts.CodecMetrics))
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return &AdminServer{
+		server: &http.Server{
+			Addr:    opts.AdminAddress,
+			Handler: mux,
+		},
+	}
+}
+
+// metrics writes the stats, broker, transport and codec metrics in the
+// prometheus text format
+func metrics(name string, st stats.Stats, bm *broker.Metrics, tm *transport.Metrics, cm *codec.Metrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var stat *stats.Stat
+
+		if st != nil {
+			if s, err := st.Read(); err == nil && len(s) > 0 {
+				stat = s[len(s)-1]
+			}
+		}
+
+		if stat == nil {
+			var mstat runtime.MemStats
+			runtime.ReadMemStats(&mstat)
+
+			stat = &stats.Stat{
+				Memory:  mstat.Alloc,
+				GC:      mstat.PauseTotalNs,
+				Threads: uint64(runtime.NumGoroutine()),
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		for _, m := range []struct {
+			name  string
+			help  string
+			kind  string
+			value uint64
+		}{
+			{"micro_uptime_seconds", "Uptime of the service", "gauge", uint64(stat.Uptime)},
+			{"micro_memory_bytes", "Memory allocated", "gauge", stat.Memory},
+			{"micro_goroutines", "Number of goroutines", "gauge", stat.Threads},
+			{"micro_gc_pause_nanoseconds_total", "Garbage collection pause time", "counter", stat.GC},
+			{"micro_requests_total", "Requests handled", "counter", stat.Requests},
+			{"micro_errors_total", "Requests failed", "counter", stat.Errors},
+		} {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s{service=%q} %d\n", m.name, m.help, m.name, m.kind, m.name, name, m.value)
+		}
+
+		if tm != nil {
+			transportMetrics(w, name, tm)
+		}
+
+		if cm != nil {
+			codecMetrics(w, name, cm)
+		}
+
+		if bm == nil {
+			return
+		}
+
+		topics := bm.Stats()
+
+		for _, m := range []struct {
+			name  string
+			help  string
+			kind  string
+			value func(*broker.TopicStat) int64
+		}{
+			{"micro_broker_published_total", "Messages published", "counter", func(t *broker.TopicStat) int64 { return int64(t.Published) }},
+			{"micro_broker_publish_errors_total", "Messages failed to publish", "counter", func(t *broker.TopicStat) int64 { return int64(t.PublishErrors) }},
+			{"micro_broker_consumed_total", "Messages consumed", "counter", func(t *broker.TopicStat) int64 { return int64(t.Consumed) }},
+			{"micro_broker_consume_errors_total", "Messages failed to handle", "counter", func(t *broker.TopicStat) int64 { return int64(t.ConsumeErrors) }},
+			{"micro_broker_redelivered_total", "Messages redelivered", "counter", func(t *broker.TopicStat) int64 { return int64(t.Redelivered) }},
+			{"micro_broker_handler_nanoseconds_total", "Latency of the handlers", "counter", func(t *broker.TopicStat) int64 { return int64(t.Latency) }},
+			{"micro_broker_lag", "Messages not yet consumed", "gauge", func(t *broker.TopicStat) int64 { return t.Lag }},
+		} {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			for _, t := range topics {
+				fmt.Fprintf(w, "%s{service=%q,topic=%q} %d\n", m.name, name, t.Topic, m.value(t))
+			}
+		}
+	}
+}
+
+// codecMetrics writes the codec latency and histograms of the message sizes
+// of the endpoints
+func codecMetrics(w http.ResponseWriter, name string, cm *codec.Metrics) {
+	endpoints := cm.Stats()
+
+	for _, m := range []struct {
+		name  string
+		help  string
+		value func(*codec.EndpointStat) uint64
+	}{
+		{"micro_codec_marshal_nanoseconds_total", "Latency of the codec marshaling", func(e *codec.EndpointStat) uint64 { return uint64(e.MarshalLatency) }},
+		{"micro_codec_unmarshal_nanoseconds_total", "Latency of the codec unmarshaling", func(e *codec.EndpointStat) uint64 { return uint64(e.UnmarshalLatency) }},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", m.name, m.help, m.name)
+		for _, e := range endpoints {
+			fmt.Fprintf(w, "%s{service=%q,endpoint=%q} %d\n", m.name, name, e.Endpoint, m.value(e))
+		}
+	}
+
+	for _, m := range []struct {
+		name  string
+		help  string
+		value func(*codec.EndpointStat) codec.Histogram
+	}{
+		{"micro_codec_marshal_bytes", "Sizes of the messages marshaled", func(e *codec.EndpointStat) codec.Histogram { return e.MarshalSizes }},
+		{"micro_codec_unmarshal_bytes", "Sizes of the messages unmarshaled", func(e *codec.EndpointStat) codec.Histogram { return e.UnmarshalSizes }},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", m.name, m.help, m.name)
+		for _, e := range endpoints {
+			h := m.value(e)
+			// the buckets are cumulative
+			var count uint64
+			for i, bound := range h.Bounds {
+				count += h.Counts[i]
+				fmt.Fprintf(w, "%s_bucket{service=%q,endpoint=%q,le=\"%d\"} %d\n", m.name, name, e.Endpoint, bound, count)
+			}
+			fmt.Fprintf(w, "%s_bucket{service=%q,endpoint=%q,le=\"+Inf\"} %d\n", m.name, name, e.Endpoint, h.Count)
+			fmt.Fprintf(w, "%s_sum{service=%q,endpoint=%q} %d\n", m.name, name, e.Endpoint, h.Sum)
+			fmt.Fprintf(w, "%s_count{service=%q,endpoint=%q} %d\n", m.name, name, e.Endpoint, h.Count)
+		}
+	}
+}
+
+// transportMetrics writes the connection counters of the peers
+func transportMetrics(w http.ResponseWriter, name string, tm *transport.Metrics) {
+	peers := tm.Stats()
+
+	for _, m := range []struct {
+		name  string
+		help  string
+		kind  string
+		value func(*transport.PeerStat) uint64
+	}{
+		{"micro_transport_connections_opened_total", "Connections opened", "counter", func(p *transport.PeerStat) uint64 { return p.Opened }},
+		{"micro_transport_connections_closed_total", "Connections closed", "counter", func(p *transport.PeerStat) uint64 { return p.Closed }},
+		{"micro_transport_sent_bytes_total", "Bytes sent", "counter", func(p *transport.PeerStat) uint64 { return p.BytesSent }},
+		{"micro_transport_received_bytes_total", "Bytes received", "counter", func(p *transport.PeerStat) uint64 { return p.BytesReceived }},
+		{"micro_transport_handshakes_total", "Handshakes completed", "counter", func(p *transport.PeerStat) uint64 { return p.Handshakes }},
+		{"micro_transport_handshake_nanoseconds_total", "Latency of the handshakes", "counter", func(p *transport.PeerStat) uint64 { return uint64(p.HandshakeLatency) }},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, p := range peers {
+			fmt.Fprintf(w, "%s{service=%q,peer=%q} %d\n", m.name, name, p.Peer, m.value(p))
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP micro_transport_tls_info TLS version of the last handshake\n# TYPE micro_transport_tls_info gauge\n")
+	for _, p := range peers {
+		if len(p.TLSVersion) > 0 {
+			fmt.Fprintf(w, "micro_transport_tls_info{service=%q,peer=%q,version=%q} 1\n", name, p.Peer, p.TLSVersion)
+		}
+	}
+}
+
+// Start listening on the admin address
+func (a *AdminServer) Start() error {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.listener != nil {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	a.listener = l
+
+	go a.server.Serve(l)
+
+	return nil
+}
+
+// Address returns the address listened on
+func (a *AdminServer) Address() string {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.listener == nil {
+		return a.server.Addr
+	}
+
+	return a.listener.Addr().String()
+}
+
+// Stop the server
+func (a *AdminServer) Stop() error {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.listener == nil {
+		return nil
+	}
+
+	a.listener = nil
+
+	return a.server.Shutdown(context.TODO())
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	memBroker "github.com/asim/go-micro/v3/broker/memory"
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/asim/go-micro/v3/health"
+	memHealth "github.com/asim/go-micro/v3/health/memory"
+	"github.com/asim/go-micro/v3/transport"
+	tmem "github.com/asim/go-micro/v3/transport/memory"
+)
+
+func TestAdminServer(t *testing.T) {
+	h := memHealth.NewHealth()
+	h.Register("db", func(context.Context) error {
+		return errors.New("down")
+	}, health.CheckType(health.Readiness))
+
+	br := memBroker.NewBroker(broker.WithMetrics(broker.NewMetrics()))
+	if err := br.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := br.Publish("test.topic", &broker.Message{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tm := transport.NewMetrics()
+	tm.Handshake("10.0.0.1", time.Millisecond, tls.ConnectionState{HandshakeComplete: true, Version: tls.VersionTLS13})
+
+	cm := codec.NewMetrics()
+	cm.Marshaled("Greeter.Hello", 100, time.Millisecond)
+
+	a := NewAdminServer(Options{
+		Name:         "test.service",
+		CodecMetrics: cm,
+		AdminAddress: "127.0.0.1:0",
+		Health:       h,
+		Broker:       br,
+		Transport:    tmem.NewTransport(transport.WithMetrics(tm)),
+	})
+
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop()
+
+	for path, code := range map[string]int{
+		"/healthz":      http.StatusOK,
+		"/readyz":       http.StatusServiceUnavailable,
+		"/metrics":      http.StatusOK,
+		"/debug/pprof/": http.StatusOK,
+	} {
+		rsp, err := http.Get("http://" + a.Address() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if rsp.StatusCode != code {
+			t.Fatalf("expected %s to return %d got %d", path, code, rsp.StatusCode)
+		}
+
+		if path == "/metrics" && !strings.Contains(string(b), `micro_goroutines{service="test.service"}`) {
+			t.Fatalf("unexpected metrics %s", b)
+		}
+
+		if path == "/metrics" && !strings.Contains(string(b), `micro_broker_published_total{service="test.service",topic="test.topic"} 1`) {
+			t.Fatalf("expected the broker metrics got %s", b)
+		}
+
+		if path == "/metrics" && (!strings.Contains(string(b), `micro_transport_handshakes_total{service="test.service",peer="10.0.0.1"} 1`) ||
+			!strings.Contains(string(b), `micro_transport_tls_info{service="test.service",peer="10.0.0.1",version="1.3"} 1`)) {
+			t.Fatalf("expected the transport metrics got %s", b)
+		}
+
+		if path == "/metrics" && (!strings.Contains(string(b), `micro_codec_marshal_bytes_bucket{service="test.service",endpoint="Greeter.Hello",le="64"} 0`) ||
+			!strings.Contains(string(b), `micro_codec_marshal_bytes_bucket{service="test.service",endpoint="Greeter.Hello",le="256"} 1`) ||
+			!strings.Contains(string(b), `micro_codec_marshal_bytes_sum{service="test.service",endpoint="Greeter.Hello"} 100`)) {
+			t.Fatalf("expected the codec metrics got %s", b)
+		}
+	}
+
+	addr := a.Address()
+
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get("http://" + addr + "/healthz"); err == nil {
+		t.Fatal("expected the admin server to be stopped")
+	}
+}
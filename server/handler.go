@@ -1,12 +1,20 @@
 package server
 
-import "context"
+import (
+	"context"
+
+	"github.com/asim/go-micro/v3/codec"
+)
 
 type HandlerOption func(*HandlerOptions)
 
 type HandlerOptions struct {
 	Internal bool
 	Metadata map[string]map[string]string
+	// Wrappers applied to this handler only
+	Wrappers []HandlerWrapper
+	// Codecs of this handler by content type
+	Codecs map[string]codec.NewCodec
 }
 
 type SubscriberOption func(*SubscriberOptions)
@@ -18,6 +26,17 @@ type SubscriberOptions struct {
 	Queue    string
 	Internal bool
 	Context  context.Context
+	// PoolSize is the number of workers processing messages,
+	// zero leaves the concurrency to the broker
+	PoolSize int
+	// PoolQueue is the number of messages waiting for a worker
+	PoolQueue int
+	// DeadLetter is the topic messages are published to once
+	// the handler failed MaxRetries times
+	DeadLetter string
+	MaxRetries int
+	// Prefetch limits the messages in flight from the broker
+	Prefetch int
 }
 
 // EndpointMetadata is a Handler option that allows metadata to be added to
@@ -37,6 +56,26 @@ func InternalHandler(b bool) HandlerOption {
 	}
 }
 
+// WithMiddleware adds handler wrappers applied to this handler only. They're
+// executed after the server wrappers, the first being the outermost.
+func WithMiddleware(w ...HandlerWrapper) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Wrappers = append(o.Wrappers, w...)
+	}
+}
+
+// HandlerCodec sets the codec of this handler for the content type, the
+// bodies of its requests and responses are encoded with it rather than the
+// codec of the server e.g json options of its proto messages.
+func HandlerCodec(contentType string, c codec.NewCodec) HandlerOption {
+	return func(o *HandlerOptions) {
+		if o.Codecs == nil {
+			o.Codecs = make(map[string]codec.NewCodec)
+		}
+		o.Codecs[contentType] = c
+	}
+}
+
 // Internal Subscriber options specifies that a subscriber is not advertised
 // to the discovery system.
 func InternalSubscriber(b bool) SubscriberOption {
@@ -65,6 +104,34 @@ func DisableAutoAck() SubscriberOption {
 	}
 }
 
+// SubscriberPool processes messages with size workers whatever the
+// concurrency of the broker. Up to queueDepth messages wait for a worker,
+// beyond that delivery from the broker is blocked until a worker is free.
+// The messages are acked once they're queued, the errors of the handler
+// are logged rather than having the messages redelivered.
+func SubscriberPool(size, queueDepth int) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.PoolSize = size
+		o.PoolQueue = queueDepth
+	}
+}
+
+// SubscriberDeadLetter publishes messages to the topic once the handler
+// failed maxRetries times
+func SubscriberDeadLetter(topic string, maxRetries int) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.DeadLetter = topic
+		o.MaxRetries = maxRetries
+	}
+}
+
+// SubscriberPrefetch limits the messages in flight from the broker
+func SubscriberPrefetch(n int) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.Prefetch = n
+	}
+}
+
 // Shared queue name distributed messages across subscribers
 func SubscriberQueue(n string) SubscriberOption {
 	return func(o *SubscriberOptions) {
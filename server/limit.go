@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+const (
+	// LimitMiddleware is the name of the handler limit middleware
+	LimitMiddleware = "limit"
+)
+
+var (
+	// ErrHandlerLimit is returned when the queue of a limited handler is full
+	ErrHandlerLimit = errors.New("go.micro.server", "handler limit exceeded", http.StatusTooManyRequests)
+)
+
+type handlerLimit struct {
+	// slots for the requests being handled
+	sem   chan struct{}
+	queue int
+
+	sync.Mutex
+	waiting int
+}
+
+// acquire takes a slot, queueing if none are free. It returns
+// ErrHandlerLimit if the queue is full.
+func (l *handlerLimit) acquire(ctx context.Context) error {
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.Lock()
+	if l.waiting >= l.queue {
+		l.Unlock()
+		return ErrHandlerLimit
+	}
+	l.waiting++
+	l.Unlock()
+
+	defer func() {
+		l.Lock()
+		l.waiting--
+		l.Unlock()
+	}()
+
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errors.Timeout("go.micro.server", fmt.Sprintf("handler limit: %v", ctx.Err()))
+	}
+}
+
+func (l *handlerLimit) release() {
+	<-l.sem
+}
+
+// HandlerLimiter limits the concurrent requests of individual endpoints
+type HandlerLimiter struct {
+	sync.RWMutex
+	limits map[string]*handlerLimit
+}
+
+// NewHandlerLimiter returns a limiter without any limits
+func NewHandlerLimiter() *HandlerLimiter {
+	return &HandlerLimiter{
+		limits: make(map[string]*handlerLimit),
+	}
+}
+
+// Limit allows maxConcurrent requests to the endpoint at once with up to
+// maxQueue requests waiting for a slot
+func (h *HandlerLimiter) Limit(endpoint string, maxConcurrent, maxQueue int) {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+	if maxQueue < 0 {
+		maxQueue = 0
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	h.limits[endpoint] = &handlerLimit{
+		sem:   make(chan struct{}, maxConcurrent),
+		queue: maxQueue,
+	}
+}
+
+// Wrapper returns the handler wrapper enforcing the limits
+func (h *HandlerLimiter) Wrapper() HandlerWrapper {
+	return func(fn HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req Request, rsp interface{}) error {
+			h.RLock()
+			l, ok := h.limits[req.Endpoint()]
+			h.RUnlock()
+
+			if !ok {
+				return fn(ctx, req, rsp)
+			}
+
+			if err := l.acquire(ctx); err != nil {
+				return err
+			}
+			defer l.release()
+
+			return fn(ctx, req, rsp)
+		}
+	}
+}
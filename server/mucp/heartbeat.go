@@ -0,0 +1,210 @@
+package mucp
+
+import (
+	"time"
+
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/server"
+	"github.com/asim/go-micro/v3/util/backoff"
+	"github.com/asim/go-micro/v3/util/jitter"
+)
+
+// heartbeat schedules the registrations of the server on the interval,
+// sooner with backoff after failures
+type heartbeat struct {
+	opts     server.Options
+	failures int
+	timer    *time.Timer
+	// C fires when it's time to register, nil without an interval
+	C <-chan time.Time
+}
+
+func newHeartbeat(opts server.Options) *heartbeat {
+	h := &heartbeat{opts: opts}
+	if opts.RegisterInterval > time.Duration(0) {
+		h.timer = time.NewTimer(h.next())
+		h.C = h.timer.C
+	}
+	return h
+}
+
+// next returns the delay before registering again
+func (h *heartbeat) next() time.Duration {
+	interval := h.opts.RegisterInterval
+
+	if h.failures > 0 {
+		// exponential by default
+		strategy := h.opts.RegisterBackoff
+		if strategy == nil {
+			strategy = backoff.Do
+		}
+		if d := strategy(h.failures); d < interval {
+			return d
+		}
+		return interval
+	}
+
+	j := h.opts.RegisterJitter
+	if j == 0 {
+		j = interval / 10
+	}
+	if j > interval {
+		j = interval
+	}
+
+	return interval - jitter.Do(j)
+}
+
+// Reset schedules the next registration after the one which returned err
+func (h *heartbeat) Reset(err error) {
+	if err != nil {
+		h.failures++
+		if fn := h.opts.RegisterFailure; fn != nil {
+			fn(err, h.failures)
+		}
+	} else {
+		h.failures = 0
+	}
+
+	if h.timer == nil {
+		return
+	}
+	if !h.timer.Stop() {
+		select {
+		case <-h.timer.C:
+		default:
+		}
+	}
+	h.timer.Reset(h.next())
+}
+
+// Stop the heartbeat
+func (h *heartbeat) Stop() {
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+}
+
+// heartbeat registers the service unless the register check fails, it
+// returns the error registering
+func (s *rpcServer) heartbeat() error {
+	config := s.Options()
+
+	s.RLock()
+	registered := s.registered
+	s.RUnlock()
+
+	rerr := config.RegisterCheck(config.Context)
+	if rerr != nil && registered {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			log.Errorf("Server %s-%s register check error: %s, deregister it", config.Name, config.Id, rerr)
+		}
+		// deregister self in case of error
+		if err := s.Deregister(); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				log.Errorf("Server %s-%s deregister error: %s", config.Name, config.Id, err)
+			}
+		}
+	} else if rerr != nil && !registered {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			log.Errorf("Server %s-%s register check error: %s", config.Name, config.Id, rerr)
+		}
+		return nil
+	}
+
+	if err := s.Register(); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			log.Errorf("Server %s-%s register error: %s", config.Name, config.Id, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// watch returns a watcher of the registrations of the service
+func (s *rpcServer) watch() (registry.Watcher, error) {
+	config := s.Options()
+	return config.Registry.Watch(
+		registry.WatchService(config.Name),
+		registry.WatchDomain(config.Namespace),
+	)
+}
+
+// watchRegistration notifies lost when the node is deleted from the
+// registry or the watch fails, e.g the ttl expired or the registry was
+// restarted, so it's registered again without waiting for the interval.
+// The watcher is created again once the first fails.
+func (s *rpcServer) watchRegistration(w registry.Watcher, exit chan bool, lost chan bool) {
+	config := s.Options()
+	id := config.Name + "-" + config.Id
+
+	notify := func() {
+		s.RLock()
+		registered := s.registered
+		s.RUnlock()
+
+		if !registered {
+			return
+		}
+
+		select {
+		case lost <- true:
+		default:
+		}
+	}
+
+	for i := 0; ; i++ {
+		var err error
+		if w == nil {
+			w, err = s.watch()
+		}
+		if err == nil {
+			done := make(chan bool)
+			go func() {
+				select {
+				case <-exit:
+				case <-done:
+				}
+				w.Stop()
+			}()
+
+			for {
+				res, err := w.Next()
+				if err != nil {
+					break
+				}
+				// the watch works again
+				i = 0
+
+				if res.Action != "delete" || res.Service == nil {
+					continue
+				}
+				for _, n := range res.Service.Nodes {
+					if n.Id == id {
+						notify()
+					}
+				}
+			}
+
+			close(done)
+			w = nil
+		}
+
+		select {
+		case <-exit:
+			return
+		default:
+		}
+
+		// the registration may have been lost meanwhile
+		notify()
+
+		select {
+		case <-time.After(backoff.Do(i + 1)):
+		case <-exit:
+			return
+		}
+	}
+}
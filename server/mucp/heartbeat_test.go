@@ -0,0 +1,168 @@
+package mucp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/registry/memory"
+	"github.com/asim/go-micro/v3/server"
+	tmem "github.com/asim/go-micro/v3/transport/memory"
+)
+
+// failing is a registry which fails to register while down
+type failing struct {
+	registry.Registry
+
+	sync.Mutex
+	down bool
+}
+
+func (f *failing) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	f.Lock()
+	down := f.down
+	f.Unlock()
+
+	if down {
+		return errors.New("registry unavailable")
+	}
+	return f.Registry.Register(s, opts...)
+}
+
+func TestHeartbeatReregister(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	srv := NewServer(
+		server.Name("test.service"),
+		server.Registry(reg),
+		server.Transport(tmem.NewTransport()),
+		server.RegisterInterval(time.Hour),
+	)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	svcs, err := reg.GetService("test.service")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the registration is lost e.g the registry restarted
+	if err := reg.Deregister(svcs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if svcs, err := reg.GetService("test.service"); err == nil && len(svcs[0].Nodes) == 1 {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatal("expected the service to be registered again before the interval")
+}
+
+func TestHeartbeatFailure(t *testing.T) {
+	reg := &failing{Registry: memory.NewRegistry()}
+
+	var mtx sync.Mutex
+	var failures []int
+
+	srv := NewServer(
+		server.Name("test.service"),
+		server.Registry(reg),
+		server.Transport(tmem.NewTransport()),
+		server.RegisterInterval(time.Millisecond*50),
+		server.RegisterBackoff(func(int) time.Duration { return time.Millisecond }),
+		server.RegisterFailure(func(err error, n int) {
+			mtx.Lock()
+			failures = append(failures, n)
+			mtx.Unlock()
+		}),
+	)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	reg.Lock()
+	reg.down = true
+	reg.Unlock()
+
+	// failures are retried with backoff rather than on the interval
+	start := time.Now()
+	for {
+		mtx.Lock()
+		n := len(failures)
+		mtx.Unlock()
+		if n >= 3 {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected 3 failures got %d", n)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+
+	mtx.Lock()
+	if failures[0] != 1 || failures[1] != 2 || failures[2] != 3 {
+		t.Fatalf("expected the failures in a row got %v", failures)
+	}
+	mtx.Unlock()
+
+	if d := time.Since(start); d > time.Millisecond*150 {
+		t.Fatalf("expected the retries to back off from the interval got %v", d)
+	}
+
+	reg.Lock()
+	reg.down = false
+	reg.Unlock()
+
+	h := newHeartbeat(server.Options{RegisterInterval: time.Second})
+	defer h.Stop()
+
+	if d := h.next(); d > time.Second || d < time.Millisecond*900 {
+		t.Fatalf("expected at most a tenth of jitter got %v", d)
+	}
+}
+
+func TestHeartbeatDefaultBackoff(t *testing.T) {
+	// the options of the server back off by default
+	opts := newOptions(server.RegisterInterval(time.Minute))
+	if opts.RegisterBackoff == nil {
+		t.Fatal("expected a default register backoff")
+	}
+
+	// as does a heartbeat without a strategy
+	for _, o := range []server.Options{opts, {RegisterInterval: time.Minute}} {
+		h := newHeartbeat(o)
+
+		var prev time.Duration
+		for i := 1; i <= 3; i++ {
+			h.Reset(errors.New("registry unavailable"))
+			d := h.next()
+			if d <= prev || d >= time.Minute {
+				t.Fatalf("expected failure %d to back off exponentially below the interval got %v after %v", i, d, prev)
+			}
+			prev = d
+		}
+
+		// capped at the interval
+		for i := 0; i < 20; i++ {
+			h.Reset(errors.New("registry unavailable"))
+		}
+		if d := h.next(); d != time.Minute {
+			t.Fatalf("expected the backoff capped at the interval got %v", d)
+		}
+
+		// the interval is used again once it succeeds
+		h.Reset(nil)
+		if d := h.next(); d < time.Second*54 {
+			t.Fatalf("expected the interval after a success got %v", d)
+		}
+		h.Stop()
+	}
+}
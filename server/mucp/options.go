@@ -6,6 +6,7 @@ import (
 	"github.com/asim/go-micro/v3/registry/memory"
 	"github.com/asim/go-micro/v3/server"
 	tmem "github.com/asim/go-micro/v3/transport/memory"
+	"github.com/asim/go-micro/v3/util/backoff"
 )
 
 func newOptions(opt ...server.Option) server.Options {
@@ -14,6 +15,7 @@ func newOptions(opt ...server.Option) server.Options {
 		Metadata:         map[string]string{},
 		RegisterInterval: server.DefaultRegisterInterval,
 		RegisterTTL:      server.DefaultRegisterTTL,
+		RegisterBackoff:  backoff.Do,
 	}
 
 	for _, o := range opt {
@@ -3,12 +3,16 @@ package mucp
 import (
 	"bytes"
 	"sync"
+	"time"
 
 	"github.com/asim/go-micro/v3/codec"
 	raw "github.com/asim/go-micro/v3/codec/bytes"
+	"github.com/asim/go-micro/v3/codec/cbor"
+	"github.com/asim/go-micro/v3/codec/flatbuffers"
 	"github.com/asim/go-micro/v3/codec/grpc"
 	"github.com/asim/go-micro/v3/codec/json"
 	"github.com/asim/go-micro/v3/codec/jsonrpc"
+	"github.com/asim/go-micro/v3/codec/msgpack"
 	"github.com/asim/go-micro/v3/codec/proto"
 	"github.com/asim/go-micro/v3/codec/protorpc"
 	"github.com/asim/go-micro/v3/transport"
@@ -21,9 +25,17 @@ type rpcCodec struct {
 	codec    codec.Codec
 	protocol string
 
+	// content type and codec of the responses negotiated by the accept
+	// header, the writer is nil if it's the codec
+	accept string
+	writer codec.Codec
+
 	req *transport.Message
 	buf *readWriteCloser
 
+	// records the sizes and latency of the bodies
+	metrics *codec.Metrics
+
 	// check if we're the first
 	sync.RWMutex
 	first chan bool
@@ -39,11 +51,14 @@ var (
 	DefaultContentType = "application/protobuf"
 
 	DefaultCodecs = map[string]codec.NewCodec{
+		"application/cbor":         cbor.NewCodec,
 		"application/grpc":         grpc.NewCodec,
 		"application/grpc+json":    grpc.NewCodec,
 		"application/grpc+proto":   grpc.NewCodec,
 		"application/json":         json.NewCodec,
 		"application/json-rpc":     jsonrpc.NewCodec,
+		"application/msgpack":      msgpack.NewCodec,
+		"application/flatbuffers":  flatbuffers.NewCodec,
 		"application/protobuf":     proto.NewCodec,
 		"application/proto-rpc":    protorpc.NewCodec,
 		"application/octet-stream": raw.NewCodec,
@@ -164,7 +179,7 @@ func setupProtocol(msg *transport.Message) codec.NewCodec {
 	return nil
 }
 
-func newRpcCodec(req *transport.Message, socket transport.Socket, c codec.NewCodec) codec.Codec {
+func newRpcCodec(req *transport.Message, socket transport.Socket, c codec.NewCodec, accept string, ac codec.NewCodec, m *codec.Metrics) codec.Codec {
 	rwc := &readWriteCloser{
 		rbuf: bufferPool.Get(),
 		wbuf: bufferPool.Get(),
@@ -176,6 +191,8 @@ func newRpcCodec(req *transport.Message, socket transport.Socket, c codec.NewCod
 		req:      req,
 		socket:   socket,
 		protocol: "mucp",
+		accept:   accept,
+		metrics:  m,
 		first:    make(chan bool),
 	}
 
@@ -187,9 +204,15 @@ func newRpcCodec(req *transport.Message, socket transport.Socket, c codec.NewCod
 		rwc.rbuf.Write(req.Body)
 		// set the protocol
 		r.protocol = "grpc"
+		// the responses are grpc
+		r.accept = ""
 	default:
 		// first is not preloaded
 		close(r.first)
+		// the responses have their own codec
+		if ac != nil {
+			r.writer = ac(rwc)
+		}
 	}
 
 	return r
@@ -266,7 +289,52 @@ func (c *rpcCodec) ReadHeader(r *codec.Message, t codec.MessageType) error {
 	return nil
 }
 
+// withCodecs returns the rpc codec reading and writing the bodies of a
+// request with the codecs of its handler for the content types of the
+// request and responses, nil if it has neither
+func (c *rpcCodec) withCodecs(codecs map[string]codec.NewCodec) codec.Codec {
+	rf, rok := codecs[c.req.Header["Content-Type"]]
+	wf, wok := codecs[c.contentType()]
+	if !rok && !wok {
+		return nil
+	}
+
+	hc := &handlerCodec{rpcCodec: c, codec: c.codec, writer: c.responseCodec()}
+	if rok {
+		hc.codec = rf(c.buf)
+	}
+	switch {
+	case wok && c.writer == nil:
+		// the responses have the content type of the request
+		hc.writer = hc.codec
+	case wok:
+		hc.writer = wf(c.buf)
+	}
+
+	return hc
+}
+
+// contentType returns the content type of the responses
+func (c *rpcCodec) contentType() string {
+	if len(c.accept) > 0 {
+		return c.accept
+	}
+	return c.req.Header["Content-Type"]
+}
+
+// responseCodec returns the codec of the responses
+func (c *rpcCodec) responseCodec() codec.Codec {
+	if c.writer != nil {
+		return c.writer
+	}
+	return c.codec
+}
+
 func (c *rpcCodec) ReadBody(b interface{}) error {
+	return c.readBody(c.codec, b)
+}
+
+func (c *rpcCodec) readBody(cd codec.Codec, b interface{}) error {
 	// don't read empty body
 	if len(c.req.Body) == 0 {
 		return nil
@@ -277,10 +345,24 @@ func (c *rpcCodec) ReadBody(b interface{}) error {
 		return nil
 	}
 	// decode the usual way
-	return c.codec.ReadBody(b)
+	if c.metrics == nil || b == nil {
+		return cd.ReadBody(b)
+	}
+
+	start := time.Now()
+	if err := cd.ReadBody(b); err != nil {
+		return err
+	}
+	c.metrics.Unmarshaled(c.req.Header["Micro-Endpoint"], len(c.req.Body), time.Since(start))
+
+	return nil
 }
 
 func (c *rpcCodec) Write(r *codec.Message, b interface{}) error {
+	return c.write(c.responseCodec(), r, b)
+}
+
+func (c *rpcCodec) write(cd codec.Codec, r *codec.Message, b interface{}) error {
 	c.buf.wbuf.Reset()
 
 	// create a new message
@@ -310,14 +392,14 @@ func (c *rpcCodec) Write(r *codec.Message, b interface{}) error {
 	} else if len(r.Body) > 0 {
 		body = r.Body
 		// write the body to codec
-	} else if err := c.codec.Write(m, b); err != nil {
+	} else if err := c.encode(cd, m, b); err != nil {
 		c.buf.wbuf.Reset()
 
 		// write an error if it failed
 		m.Error = errors.Wrapf(err, "Unable to encode body").Error()
 		m.Header["Micro-Error"] = m.Error
 		// no body to write
-		if err := c.codec.Write(m, nil); err != nil {
+		if err := cd.Write(m, nil); err != nil {
 			return err
 		}
 	} else {
@@ -327,7 +409,7 @@ func (c *rpcCodec) Write(r *codec.Message, b interface{}) error {
 
 	// Set content type if theres content
 	if len(body) > 0 {
-		m.Header["Content-Type"] = c.req.Header["Content-Type"]
+		m.Header["Content-Type"] = c.contentType()
 	}
 
 	// send on the socket
@@ -337,9 +419,27 @@ func (c *rpcCodec) Write(r *codec.Message, b interface{}) error {
 	})
 }
 
+// encode writes the body with the codec recording its size and latency
+func (c *rpcCodec) encode(cd codec.Codec, m *codec.Message, b interface{}) error {
+	if c.metrics == nil || b == nil {
+		return cd.Write(m, b)
+	}
+
+	start := time.Now()
+	if err := cd.Write(m, b); err != nil {
+		return err
+	}
+	c.metrics.Marshaled(c.req.Header["Micro-Endpoint"], c.buf.wbuf.Len(), time.Since(start))
+
+	return nil
+}
+
 func (c *rpcCodec) Close() error {
-	// close the codec
+	// close the codecs
 	c.codec.Close()
+	if c.writer != nil {
+		c.writer.Close()
+	}
 	// close the socket
 	err := c.socket.Close()
 	// put back the buffers
@@ -352,3 +452,18 @@ func (c *rpcCodec) Close() error {
 func (c *rpcCodec) String() string {
 	return c.protocol
 }
+
+// handlerCodec is the rpc codec of a request with the codecs of its handler
+type handlerCodec struct {
+	*rpcCodec
+	codec  codec.Codec
+	writer codec.Codec
+}
+
+func (c *handlerCodec) ReadBody(b interface{}) error {
+	return c.readBody(c.codec, b)
+}
+
+func (c *handlerCodec) Write(r *codec.Message, b interface{}) error {
+	return c.write(c.writer, r, b)
+}
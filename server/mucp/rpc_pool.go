@@ -0,0 +1,119 @@
+package mucp
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/logger"
+)
+
+var errPoolStopped = errors.New("subscriber pool stopped")
+
+// subscriberPool processes the messages of a subscription with a fixed
+// number of workers. The messages are acked once they're queued so a
+// broker delivering them one at a time still has them processed in
+// parallel, and the handler blocks while the queue is full which applies
+// backpressure to the broker. The errors of the workers are logged as
+// the messages were already acked.
+type subscriberPool struct {
+	handler broker.Handler
+	queue   chan *broker.Message
+	// the messages queued and not yet processed
+	wg *waitGroup
+
+	// exit unblocks the messages waiting to be queued, done stops the
+	// workers once none can be queued
+	exit chan bool
+	done chan bool
+
+	sync.RWMutex
+	stopped bool
+}
+
+func newSubscriberPool(size, depth int, h broker.Handler, wg *waitGroup) *subscriberPool {
+	if depth < 0 {
+		depth = 0
+	}
+
+	p := &subscriberPool{
+		handler: h,
+		queue:   make(chan *broker.Message, depth),
+		wg:      wg,
+		exit:    make(chan bool),
+		done:    make(chan bool),
+	}
+
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+func (p *subscriberPool) work() {
+	for {
+		select {
+		case msg := <-p.queue:
+			p.process(msg)
+		case <-p.done:
+			// the messages queued were acked so they're processed
+			for {
+				select {
+				case msg := <-p.queue:
+					p.process(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *subscriberPool) process(msg *broker.Message) {
+	if p.wg != nil {
+		defer p.wg.Done()
+	}
+
+	if err := p.handler(msg); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			log.Errorf("Subscriber pool error handling %s: %v", msg.Header["Micro-Topic"], err)
+		}
+	}
+}
+
+// Handle queues the message, blocking while the queue is full, and returns
+// so the broker acks it and delivers the next one
+func (p *subscriberPool) Handle(msg *broker.Message) error {
+	p.RLock()
+	defer p.RUnlock()
+
+	if p.stopped {
+		return errPoolStopped
+	}
+
+	if p.wg != nil {
+		p.wg.Add(1)
+	}
+
+	select {
+	case p.queue <- msg:
+		return nil
+	case <-p.exit:
+		if p.wg != nil {
+			p.wg.Done()
+		}
+		return errPoolStopped
+	}
+}
+
+// Stop rejects the messages delivered from now on, the workers exit once
+// they processed the ones queued
+func (p *subscriberPool) Stop() {
+	close(p.exit)
+
+	p.Lock()
+	p.stopped = true
+	close(p.done)
+	p.Unlock()
+}
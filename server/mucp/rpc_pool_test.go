@@ -0,0 +1,149 @@
+package mucp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+)
+
+func TestSubscriberPool(t *testing.T) {
+	var mtx sync.Mutex
+	var inflight, peak, handled int
+
+	var wg waitGroup
+
+	p := newSubscriberPool(2, 1, func(msg *broker.Message) error {
+		mtx.Lock()
+		inflight++
+		if inflight > peak {
+			peak = inflight
+		}
+		mtx.Unlock()
+
+		time.Sleep(time.Millisecond * 10)
+
+		mtx.Lock()
+		inflight--
+		handled++
+		mtx.Unlock()
+
+		if msg.Header["fail"] == "true" {
+			return errors.New("failed")
+		}
+		return nil
+	}, &wg)
+
+	// delivered by a goroutine per message
+	var dwg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		dwg.Add(1)
+		go func(i int) {
+			defer dwg.Done()
+			msg := &broker.Message{Header: map[string]string{}}
+			if i == 0 {
+				msg.Header["fail"] = "true"
+			}
+			// the messages are acked once queued
+			if err := p.Handle(msg); err != nil {
+				t.Errorf("expected the message to be queued got %v", err)
+			}
+		}(i)
+	}
+
+	dwg.Wait()
+
+	// the messages queued are waited for
+	wg.Wait()
+
+	if peak > 2 {
+		t.Fatalf("expected at most 2 workers got %d", peak)
+	}
+	if handled != 10 {
+		t.Fatalf("expected 10 messages handled got %d", handled)
+	}
+
+	p.Stop()
+
+	if err := p.Handle(&broker.Message{}); err != errPoolStopped {
+		t.Fatalf("expected the pool to be stopped got %v", err)
+	}
+}
+
+func TestSubscriberPoolSerial(t *testing.T) {
+	release := make(chan bool)
+	started := make(chan bool, 4)
+
+	p := newSubscriberPool(4, 0, func(msg *broker.Message) error {
+		started <- true
+		<-release
+		return nil
+	}, nil)
+	defer p.Stop()
+
+	// a broker delivering the messages one at a time
+	go func() {
+		for i := 0; i < 4; i++ {
+			p.Handle(&broker.Message{})
+		}
+	}()
+
+	// the workers process them in parallel
+	for i := 0; i < 4; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("expected 4 messages in parallel got %d", i)
+		}
+	}
+	close(release)
+}
+
+func TestSubscriberPoolStop(t *testing.T) {
+	var mtx sync.Mutex
+	var handled int
+
+	release := make(chan bool)
+
+	p := newSubscriberPool(1, 2, func(msg *broker.Message) error {
+		<-release
+		mtx.Lock()
+		handled++
+		mtx.Unlock()
+		return nil
+	}, nil)
+
+	for i := 0; i < 3; i++ {
+		if err := p.Handle(&broker.Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a message waiting for room in the queue is rejected
+	errs := make(chan error, 1)
+	go func() {
+		errs <- p.Handle(&broker.Message{})
+	}()
+
+	time.Sleep(time.Millisecond * 10)
+	p.Stop()
+
+	if err := <-errs; err != errPoolStopped {
+		t.Fatalf("expected the pool to be stopped got %v", err)
+	}
+
+	// the messages queued are still processed
+	close(release)
+	for i := 0; i < 100; i++ {
+		mtx.Lock()
+		n := handled
+		mtx.Unlock()
+		if n == 3 {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("expected the 3 messages acked to be processed got %d", handled)
+}
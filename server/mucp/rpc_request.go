@@ -59,6 +59,11 @@ func (r *rpcRequest) Body() interface{} {
 	return r.rawBody
 }
 
+// Size is the encoded size of the request body
+func (r *rpcRequest) Size() int {
+	return len(r.body)
+}
+
 func (r *rpcRequest) Read() ([]byte, error) {
 	// got a body
 	if r.first {
@@ -45,11 +45,17 @@ type service struct {
 	rcvr   reflect.Value          // receiver of methods for the service
 	typ    reflect.Type           // type of the receiver
 	method map[string]*methodType // registered methods
+	// middleware of the handler
+	wrappers []server.HandlerWrapper
+	// codecs of the handler by content type
+	codecs map[string]codec.NewCodec
 }
 
 type request struct {
-	msg  *codec.Message
-	next *request // for free list in Server
+	msg *codec.Message
+	// codec of the handler if it has its own
+	codec codec.Codec
+	next  *request // for free list in Server
 }
 
 type response struct {
@@ -203,6 +209,11 @@ func (router *router) sendResponse(sending sync.Locker, req *request, reply inte
 func (s *service) call(ctx context.Context, router *router, sending *sync.Mutex, mtype *methodType, req *request, argv, replyv reflect.Value, cc codec.Writer) error {
 	defer router.freeRequest(req)
 
+	// the handler's own codec
+	if req.codec != nil {
+		cc = req.codec
+	}
+
 	function := mtype.method.Func
 	var returnValues []reflect.Value
 
@@ -232,7 +243,10 @@ func (s *service) call(ctx context.Context, router *router, sending *sync.Mutex,
 			return nil
 		}
 
-		// wrap the handler
+		// wrap the handler in its own middleware then the server's
+		for i := len(s.wrappers); i > 0; i-- {
+			fn = s.wrappers[i-1](fn)
+		}
 		for i := len(router.hdlrWrappers); i > 0; i-- {
 			fn = router.hdlrWrappers[i-1](fn)
 		}
@@ -270,7 +284,10 @@ func (s *service) call(ctx context.Context, router *router, sending *sync.Mutex,
 		}
 	}
 
-	// wrap the handler
+	// wrap the handler in its own middleware then the server's
+	for i := len(s.wrappers); i > 0; i-- {
+		fn = s.wrappers[i-1](fn)
+	}
 	for i := len(router.hdlrWrappers); i > 0; i-- {
 		fn = router.hdlrWrappers[i-1](fn)
 	}
@@ -341,6 +358,13 @@ func (router *router) readRequest(r server.Request) (service *service, mtype *me
 		cc.ReadBody(nil)
 		return
 	}
+	// does the handler have its own codecs?
+	if rc, ok := cc.(*rpcCodec); ok && len(service.codecs) > 0 {
+		if hc := rc.withCodecs(service.codecs); hc != nil {
+			req.codec = hc
+			cc = hc
+		}
+	}
 	// is it a streaming request? then we don't read the body
 	if mtype.stream {
 		if cc.(codec.Codec).String() != "grpc" {
@@ -442,6 +466,8 @@ func (router *router) Handle(h server.Handler) error {
 
 	s.name = h.Name()
 	s.method = make(map[string]*methodType)
+	s.wrappers = h.Options().Wrappers
+	s.codecs = h.Options().Codecs
 
 	// Install the methods
 	for m := 0; m < s.typ.NumMethod(); m++ {
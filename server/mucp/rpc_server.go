@@ -5,23 +5,27 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/asim/go-micro/v3/broker"
 	"github.com/asim/go-micro/v3/codec"
 	raw "github.com/asim/go-micro/v3/codec/bytes"
+	merrors "github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/health"
 	"github.com/asim/go-micro/v3/logger"
 	"github.com/asim/go-micro/v3/metadata"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/server"
 	"github.com/asim/go-micro/v3/transport"
-	"github.com/asim/go-micro/v3/util/addr"
 	"github.com/asim/go-micro/v3/util/backoff"
+	"github.com/asim/go-micro/v3/util/compress"
 	mnet "github.com/asim/go-micro/v3/util/net"
 	"github.com/asim/go-micro/v3/util/socket"
 )
@@ -34,6 +38,8 @@ type rpcServer struct {
 	opts        server.Options
 	handlers    map[string]server.Handler
 	subscribers map[server.Subscriber][]broker.Subscriber
+	// worker pools of the subscribers
+	pools map[server.Subscriber]*subscriberPool
 	// marks the serve as started
 	started bool
 	// used for first registration
@@ -42,6 +48,13 @@ type rpcServer struct {
 	subscriber broker.Subscriber
 	// graceful exit
 	wg *sync.WaitGroup
+	// in-flight requests waited for on drain
+	inflight int64
+	// messages handled or queued by the subscribers, waited for on drain
+	events   *waitGroup
+	handling int64
+	// readiness registered with the node
+	status health.Status
 
 	rsvc *registry.Service
 }
@@ -50,6 +63,10 @@ var (
 	log = logger.NewHelper(logger.DefaultLogger).WithFields(map[string]interface{}{"service": "server"})
 )
 
+// maxHeaderSize is the room left for the headers of the messages limited
+// by the transport
+const maxHeaderSize = 64 << 10
+
 func wait(ctx context.Context) *sync.WaitGroup {
 	if ctx == nil {
 		return nil
@@ -67,14 +84,18 @@ func newServer(opts ...server.Option) server.Server {
 	router.hdlrWrappers = options.HdlrWrappers
 	router.subWrappers = options.SubWrappers
 
-	return &rpcServer{
+	s := &rpcServer{
 		opts:        options,
 		router:      router,
 		handlers:    make(map[string]server.Handler),
 		subscribers: make(map[server.Subscriber][]broker.Subscriber),
+		pools:       make(map[server.Subscriber]*subscriberPool),
 		exit:        make(chan chan error),
 		wg:          wait(options.Context),
 	}
+	s.events = &waitGroup{gg: s.wg, n: &s.handling}
+
+	return s
 }
 
 // HandleEvent handles inbound messages to the service directly
@@ -150,6 +171,8 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 	// get global waitgroup
 	s.Lock()
 	gg := s.wg
+	maxRecv := s.opts.MaxRecvSize
+	minCompress := s.opts.CompressThreshold
 	s.Unlock()
 
 	// waitgroup to wait for processing to finish
@@ -214,6 +237,29 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 			id = msg.Header["Micro-Id"]
 		}
 
+		// reject oversized messages without processing them, the transport
+		// limits them while they're read first
+		if maxRecv > 0 && len(msg.Body) > maxRecv {
+			serr := &codec.MessageSizeError{Size: len(msg.Body), Limit: maxRecv}
+			if err := rejectMessage(sock, &msg, serr, http.StatusRequestEntityTooLarge); err != nil {
+				break
+			}
+			continue
+		}
+
+		// decompress the body, which is limited to the max size too or the
+		// default limit of the compressions without one
+		if err := compress.Decode(&msg, maxRecv); err != nil {
+			code := int32(http.StatusBadRequest)
+			if _, ok := err.(*codec.MessageSizeError); ok {
+				code = http.StatusRequestEntityTooLarge
+			}
+			if err := rejectMessage(sock, &msg, err, code); err != nil {
+				break
+			}
+			continue
+		}
+
 		// check stream id
 		var stream bool
 
@@ -266,6 +312,8 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 		to := msg.Header["Timeout"]
 		// we use this Content-Type header to identify the codec needed
 		ct := msg.Header["Content-Type"]
+		// the compression of the responses accepted by the client
+		compression := compress.Negotiate(msg.Header[compress.AcceptHeader])
 
 		// copy the message headers
 		hdr := make(map[string]string, len(msg.Header))
@@ -280,15 +328,36 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 		// create new context with the metadata
 		ctx := metadata.NewContext(context.Background(), hdr)
 
+		// pass the tls state of the peer to the handlers
+		if ts, ok := sock.(transport.TLSSocket); ok {
+			if state := ts.ConnectionState(); state.HandshakeComplete {
+				ctx = transport.NewPeerContext(ctx, state)
+			}
+		}
+
 		// set the timeout from the header if we have it
+		var deadline time.Time
 		if len(to) > 0 {
 			if n, err := strconv.ParseUint(to, 10, 64); err == nil {
-				var cancel context.CancelFunc
-				ctx, cancel = context.WithTimeout(ctx, time.Duration(n))
-				defer cancel()
+				deadline = time.Now().Add(time.Duration(n))
+			}
+		}
+
+		// the deadline propagated by the call tree applies if it's sooner
+		if dl := msg.Header["Micro-Deadline"]; len(dl) > 0 {
+			if n, err := strconv.ParseInt(dl, 10, 64); err == nil {
+				if d := time.Unix(0, n); deadline.IsZero() || d.Before(deadline) {
+					deadline = d
+				}
 			}
 		}
 
+		// cancelled once the request is served
+		cancel := func() {}
+		if !deadline.IsZero() {
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+		}
+
 		// if there's no content type default it
 		if len(ct) == 0 {
 			msg.Header["Content-Type"] = DefaultContentType
@@ -297,6 +366,7 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 
 		// setup old protocol
 		cf := setupProtocol(&msg)
+		legacy := cf != nil
 
 		// no legacy codec needed
 		if cf == nil {
@@ -313,13 +383,21 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 
 				// release the socket we just created
 				pool.Release(psock)
+				cancel()
 				// now continue
 				continue
 			}
 		}
 
+		// the content type of the responses accepted
+		var accept string
+		var af codec.NewCodec
+		if !legacy {
+			accept, af = s.accept(msg.Header["Accept"], ct)
+		}
+
 		// create a new rpc codec based on the pseudo socket and codec
-		rcodec := newRpcCodec(&msg, psock, cf)
+		rcodec := newRpcCodec(&msg, psock, cf, accept, af, s.opts.CodecMetrics)
 		// check the protocol as well
 		protocol := rcodec.String()
 
@@ -366,6 +444,15 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 		// serve the request and process the outbound messages
 		wg.Add(2)
 
+		// the request is in flight until it's served and its messages sent
+		atomic.AddInt64(&s.inflight, 1)
+		pending := int32(2)
+		served := func() {
+			if atomic.AddInt32(&pending, -1) == 0 {
+				atomic.AddInt64(&s.inflight, -1)
+			}
+		}
+
 		// process the outbound messages from the socket
 		go func(id string, psock *socket.Socket) {
 			defer func() {
@@ -379,6 +466,7 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 				pool.Release(psock)
 				// signal we're done
 				wg.Done()
+				served()
 
 				// recover any panics for outbound process
 				if r := recover(); r != nil {
@@ -396,6 +484,11 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 					return
 				}
 
+				// compress the message if it's large enough
+				if err := compress.Encode(m, compression, minCompress); err != nil {
+					return
+				}
+
 				// send the message back over the socket
 				if err := sock.Send(m); err != nil {
 					return
@@ -408,8 +501,11 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 			defer func() {
 				// release the socket
 				pool.Release(psock)
+				// release the deadline of the request
+				cancel()
 				// signal we're done
 				wg.Done()
+				served()
 
 				// recover any panics for call handler
 				if r := recover(); r != nil {
@@ -440,6 +536,19 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 	}
 }
 
+// rejectMessage responds to the message with an error without processing it
+func rejectMessage(sock transport.Socket, msg *transport.Message, err error, code int32) error {
+	hdr := map[string]string{
+		"Content-Type": msg.Header["Content-Type"],
+		"Micro-Id":     msg.Header["Micro-Id"],
+		"Micro-Error":  merrors.New("go.micro.server", err.Error(), code).Error(),
+	}
+	if v := msg.Header["Micro-Stream"]; len(v) > 0 {
+		hdr["Micro-Stream"] = v
+	}
+	return sock.Send(&transport.Message{Header: hdr})
+}
+
 func (s *rpcServer) newCodec(contentType string) (codec.NewCodec, error) {
 	if cf, ok := s.opts.Codecs[contentType]; ok {
 		return cf, nil
@@ -450,6 +559,27 @@ func (s *rpcServer) newCodec(contentType string) (codec.NewCodec, error) {
 	return nil, fmt.Errorf("Unsupported Content-Type: %s", contentType)
 }
 
+// accept returns the content type and codec of the responses, the first
+// type of the accept header the server has a codec for if it's not the
+// content type of the request. Otherwise the responses have the content
+// type of the request and the codec is nil.
+func (s *rpcServer) accept(header, ct string) (string, codec.NewCodec) {
+	for _, t := range strings.Split(header, ",") {
+		// the parameters e.g q=0.9 are ignored, the order is the preference
+		if i := strings.Index(t, ";"); i >= 0 {
+			t = t[:i]
+		}
+		t = strings.TrimSpace(t)
+		if t == ct || t == "*/*" {
+			break
+		}
+		if cf, err := s.newCodec(t); err == nil {
+			return t, cf
+		}
+	}
+	return "", nil
+}
+
 func (s *rpcServer) Options() server.Options {
 	s.RLock()
 	opts := s.opts
@@ -523,6 +653,9 @@ func (s *rpcServer) Register() error {
 	}
 
 	regFunc := func(service *registry.Service) error {
+		// selectors skip the node while it's not ready
+		service = s.withHealth(service)
+
 		// create registry options
 		rOpts := []registry.RegisterOption{
 			registry.RegisterTTL(config.RegisterTTL),
@@ -531,13 +664,18 @@ func (s *rpcServer) Register() error {
 
 		var regErr error
 
+		wait := config.RegisterBackoff
+		if wait == nil {
+			wait = backoff.Do
+		}
+
 		for i := 0; i < 3; i++ {
 			// attempt to register
 			if err := config.Registry.Register(service, rOpts...); err != nil {
 				// set the error
 				regErr = err
 				// backoff then retry
-				time.Sleep(backoff.Do(i + 1))
+				time.Sleep(wait(i + 1))
 				continue
 			}
 			// success so nil error
@@ -569,7 +707,7 @@ func (s *rpcServer) Register() error {
 		advt = config.Address
 	}
 
-	if cnt := strings.Count(advt, ":"); cnt >= 1 {
+	if cnt := strings.Count(advt, ":"); cnt >= 1 && !mnet.IsUnix(advt) {
 		// ipv6 address in format [host]:port or ipv4 host:port
 		host, port, err = net.SplitHostPort(advt)
 		if err != nil {
@@ -583,7 +721,7 @@ func (s *rpcServer) Register() error {
 		cacheService = true
 	}
 
-	addr, err := addr.Extract(host)
+	addr, err := extract(host)
 	if err != nil {
 		return err
 	}
@@ -608,6 +746,7 @@ func (s *rpcServer) Register() error {
 	node.Metadata["server"] = s.String()
 	node.Metadata["registry"] = config.Registry.String()
 	node.Metadata["protocol"] = "mucp"
+	node.Metadata["version"] = config.Version
 
 	s.RLock()
 
@@ -697,11 +836,33 @@ func (s *rpcServer) Register() error {
 			opts = append(opts, broker.Queue(queue))
 		}
 
+		if dl := sb.Options(); len(dl.DeadLetter) > 0 {
+			opts = append(opts, broker.DeadLetter(dl.DeadLetter, dl.MaxRetries))
+		}
+
+		if n := sb.Options().Prefetch; n > 0 {
+			opts = append(opts, broker.SubscribePrefetch(n))
+		}
+
 		if cx := sb.Options().Context; cx != nil {
 			opts = append(opts, broker.SubscribeContext(cx))
 		}
 
-		sub, err := config.Broker.Subscribe(sb.Topic(), s.HandleEvent, opts...)
+		// the messages are waited for on drain
+		handler := func(msg *broker.Message) error {
+			s.events.Add(1)
+			defer s.events.Done()
+			return s.HandleEvent(msg)
+		}
+
+		// bound the concurrency of the subscriber
+		if size := sb.Options().PoolSize; size > 0 {
+			p := newSubscriberPool(size, sb.Options().PoolQueue, s.HandleEvent, s.events)
+			s.pools[sb] = p
+			handler = p.Handle
+		}
+
+		sub, err := config.Broker.Subscribe(sb.Topic(), handler, opts...)
 		if err != nil {
 			return err
 		}
@@ -718,6 +879,58 @@ func (s *rpcServer) Register() error {
 	return nil
 }
 
+// checkHealth runs the readiness checks and reports whether the status
+// changed since they last ran
+func (s *rpcServer) checkHealth() bool {
+	s.RLock()
+	h := s.opts.Health
+	s.RUnlock()
+
+	if h == nil {
+		return false
+	}
+
+	status := health.StatusUp
+	if _, err := h.Ready(context.Background()); err != nil {
+		status = health.StatusDown
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	changed := s.status != status
+	s.status = status
+
+	return changed
+}
+
+// withHealth returns a copy of the service with the readiness set in the
+// metadata of its nodes
+func (s *rpcServer) withHealth(service *registry.Service) *registry.Service {
+	s.RLock()
+	status := s.status
+	s.RUnlock()
+
+	if len(status) == 0 {
+		return service
+	}
+
+	svc := *service
+	svc.Nodes = make([]*registry.Node, 0, len(service.Nodes))
+
+	for _, n := range service.Nodes {
+		md := metadata.Copy(n.Metadata)
+		md[health.MetadataKey] = string(status)
+		svc.Nodes = append(svc.Nodes, &registry.Node{
+			Id:       n.Id,
+			Address:  n.Address,
+			Metadata: md,
+		})
+	}
+
+	return &svc
+}
+
 func (s *rpcServer) Deregister() error {
 	var err error
 	var advt, host, port string
@@ -740,7 +953,7 @@ func (s *rpcServer) Deregister() error {
 		advt = config.Address
 	}
 
-	if cnt := strings.Count(advt, ":"); cnt >= 1 {
+	if cnt := strings.Count(advt, ":"); cnt >= 1 && !mnet.IsUnix(advt) {
 		// ipv6 address in format [host]:port or ipv4 host:port
 		host, port, err = net.SplitHostPort(advt)
 		if err != nil {
@@ -750,7 +963,7 @@ func (s *rpcServer) Deregister() error {
 		host = advt
 	}
 
-	addr, err := addr.Extract(host)
+	addr, err := extract(host)
 	if err != nil {
 		return err
 	}
@@ -802,6 +1015,11 @@ func (s *rpcServer) Deregister() error {
 			sub.Unsubscribe()
 		}
 		s.subscribers[sb] = nil
+
+		if p, ok := s.pools[sb]; ok {
+			p.Stop()
+			delete(s.pools, sb)
+		}
 	}
 
 	s.Unlock()
@@ -818,8 +1036,17 @@ func (s *rpcServer) Start() error {
 
 	config := s.Options()
 
+	// the messages are limited by the transport while they're read, the
+	// body may be base64 encoded with the headers on top of it
+	lopts := config.ListenOptions
+	if config.MaxRecvSize > 0 {
+		lopts = append([]transport.ListenOption{
+			transport.MaxMsgSize(config.MaxRecvSize*2 + maxHeaderSize),
+		}, lopts...)
+	}
+
 	// start listening on the transport
-	ts, err := config.Transport.Listen(config.Address)
+	ts, err := config.Transport.Listen(config.Address, lopts...)
 	if err != nil {
 		return err
 	}
@@ -834,6 +1061,14 @@ func (s *rpcServer) Start() error {
 	s.opts.Address = ts.Addr()
 	s.Unlock()
 
+	// stop listening so a retried start can listen again
+	abort := func() {
+		ts.Close()
+		s.Lock()
+		s.opts.Address = addr
+		s.Unlock()
+	}
+
 	bname := config.Broker.String()
 
 	// connect to the broker
@@ -841,6 +1076,7 @@ func (s *rpcServer) Start() error {
 		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
 			log.Errorf("Broker [%s] connect error: %v", bname, err)
 		}
+		abort()
 		return err
 	}
 
@@ -848,6 +1084,31 @@ func (s *rpcServer) Start() error {
 		log.Infof("Broker [%s] Connected to %s", bname, config.Broker.Address())
 	}
 
+	// start the admin listener
+	var admin *server.AdminServer
+	if len(config.AdminAddress) > 0 {
+		admin = server.NewAdminServer(config)
+		if err := admin.Start(); err != nil {
+			config.Broker.Disconnect()
+			abort()
+			return err
+		}
+
+		if logger.V(logger.InfoLevel, logger.DefaultLogger) {
+			log.Infof("Admin Listening on %s", admin.Address())
+		}
+	}
+
+	// the readiness is registered with the node
+	s.checkHealth()
+
+	// watch before registering so losing the registration isn't missed
+	var w registry.Watcher
+	watching := config.RegisterInterval > time.Duration(0) && config.Registry != nil && config.Registry.String() != "noop"
+	if watching {
+		w, _ = s.watch()
+	}
+
 	// use RegisterCheck func before register
 	if err = s.opts.RegisterCheck(s.opts.Context); err != nil {
 		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
@@ -864,6 +1125,39 @@ func (s *rpcServer) Start() error {
 
 	exit := make(chan bool)
 
+	// register again as soon as the readiness changes
+	if config.Health != nil && config.HealthInterval > 0 {
+		go func() {
+			t := time.NewTicker(config.HealthInterval)
+			defer t.Stop()
+
+			for {
+				select {
+				case <-t.C:
+					if !s.checkHealth() {
+						continue
+					}
+
+					s.RLock()
+					registered := s.registered
+					s.RUnlock()
+
+					if !registered {
+						continue
+					}
+
+					if err := s.Register(); err != nil {
+						if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+							log.Errorf("Server %s-%s register error: %s", config.Name, config.Id, err)
+						}
+					}
+				case <-exit:
+					return
+				}
+			}
+		}()
+	}
+
 	go func() {
 		for {
 			// listen for connections
@@ -892,14 +1186,14 @@ func (s *rpcServer) Start() error {
 		}
 	}()
 
-	go func() {
-		t := new(time.Ticker)
+	// registration lost e.g the ttl expired or the registry restarted
+	lost := make(chan bool, 1)
+	if watching {
+		go s.watchRegistration(w, exit, lost)
+	}
 
-		// only process if it exists
-		if s.opts.RegisterInterval > time.Duration(0) {
-			// new ticker
-			t = time.NewTicker(s.opts.RegisterInterval)
-		}
+	go func() {
+		hb := newHeartbeat(config)
 
 		// return error chan
 		var ch chan error
@@ -908,38 +1202,17 @@ func (s *rpcServer) Start() error {
 		for {
 			select {
 			// register self on interval
-			case <-t.C:
-				s.RLock()
-				registered := s.registered
-				s.RUnlock()
-				rerr := s.opts.RegisterCheck(s.opts.Context)
-				if rerr != nil && registered {
-					if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
-						log.Errorf("Server %s-%s register check error: %s, deregister it", config.Name, config.Id, rerr)
-					}
-					// deregister self in case of error
-					if err := s.Deregister(); err != nil {
-						if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
-							log.Errorf("Server %s-%s deregister error: %s", config.Name, config.Id, err)
-						}
-					}
-				} else if rerr != nil && !registered {
-					if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
-						log.Errorf("Server %s-%s register check error: %s", config.Name, config.Id, rerr)
-					}
-					continue
-				}
-				if err := s.Register(); err != nil {
-					if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
-						log.Errorf("Server %s-%s register error: %s", config.Name, config.Id, err)
-					}
-				}
+			case <-hb.C:
+			// register again as soon as it's lost
+			case <-lost:
 			// wait for exit
 			case ch = <-s.exit:
-				t.Stop()
+				hb.Stop()
 				close(exit)
 				break Loop
 			}
+
+			hb.Reset(s.heartbeat())
 		}
 
 		s.RLock()
@@ -956,11 +1229,43 @@ func (s *rpcServer) Start() error {
 
 		s.Lock()
 		swg := s.wg
+		events := s.events
+		drain := s.opts.DrainTimeout
+		grace := s.opts.DrainGrace
 		s.Unlock()
 
-		// wait for requests to finish
-		if swg != nil {
-			swg.Wait()
+		if drain <= 0 {
+			drain = grace
+		}
+		if grace > drain {
+			grace = drain
+		}
+
+		// keep serving until selectors stop sending traffic, then wait for
+		// requests and messages to finish by the same deadline
+		if drain > 0 {
+			deadline := time.Now().Add(drain)
+			time.Sleep(grace)
+			s.drain(deadline)
+		} else {
+			events.Wait()
+			if swg != nil {
+				swg.Wait()
+			}
+		}
+
+		// swap back address
+		s.Lock()
+		s.opts.Address = addr
+		s.Unlock()
+
+		// close the admin listener
+		if admin != nil {
+			if err := admin.Stop(); err != nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					log.Errorf("Admin stop error: %v", err)
+				}
+			}
 		}
 
 		// close transport listener
@@ -976,10 +1281,6 @@ func (s *rpcServer) Start() error {
 			}
 		}
 
-		// swap back address
-		s.Lock()
-		s.opts.Address = addr
-		s.Unlock()
 	}()
 
 	// mark the server as started
@@ -990,6 +1291,28 @@ func (s *rpcServer) Start() error {
 	return nil
 }
 
+// drain waits up to the timeout for the in-flight requests and the messages
+// of the subscribers to finish
+func (s *rpcServer) drain(deadline time.Time) {
+	t := time.NewTicker(time.Millisecond * 10)
+	defer t.Stop()
+
+	timeout := time.NewTimer(time.Until(deadline))
+	defer timeout.Stop()
+
+	for atomic.LoadInt64(&s.inflight) > 0 || atomic.LoadInt64(&s.handling) > 0 {
+		select {
+		case <-t.C:
+		case <-timeout.C:
+			if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+				opts := s.Options()
+				log.Warnf("Server %s-%s drain timeout with %d requests in flight and %d messages handled", opts.Name, opts.Id, atomic.LoadInt64(&s.inflight), atomic.LoadInt64(&s.handling))
+			}
+			return
+		}
+	}
+}
+
 func (s *rpcServer) Stop() error {
 	s.RLock()
 	if !s.started {
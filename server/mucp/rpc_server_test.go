@@ -0,0 +1,120 @@
+package mucp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/broker"
+	bmem "github.com/asim/go-micro/v3/broker/memory"
+	pb "github.com/asim/go-micro/v3/errors/proto"
+	"github.com/asim/go-micro/v3/registry/memory"
+	"github.com/asim/go-micro/v3/server"
+	tmem "github.com/asim/go-micro/v3/transport/memory"
+)
+
+// unreachable is a broker which fails to connect while down
+type unreachable struct {
+	broker.Broker
+
+	sync.Mutex
+	down bool
+}
+
+func (u *unreachable) Connect() error {
+	u.Lock()
+	defer u.Unlock()
+
+	if u.down {
+		return errors.New("broker unavailable")
+	}
+	return u.Broker.Connect()
+}
+
+func TestStartBrokerError(t *testing.T) {
+	// a free port for the admin server
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := l.Addr().String()
+	l.Close()
+
+	b := &unreachable{Broker: bmem.NewBroker(), down: true}
+
+	srv := NewServer(
+		server.Name("test.service"),
+		server.Registry(memory.NewRegistry()),
+		server.Transport(tmem.NewTransport()),
+		server.Broker(b),
+		server.AdminAddress(admin),
+	)
+
+	if err := srv.Start(); err == nil {
+		t.Fatal("expected the broker connect error")
+	}
+
+	b.Lock()
+	b.down = false
+	b.Unlock()
+
+	// nothing is left listening so starting again succeeds
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	c, err := net.Dial("tcp", admin)
+	if err != nil {
+		t.Fatalf("expected the admin server to listen: %v", err)
+	}
+	c.Close()
+}
+
+func TestStopWaitsForSubscribers(t *testing.T) {
+	for _, drain := range []time.Duration{0, time.Second} {
+		b := bmem.NewBroker()
+
+		srv := NewServer(
+			server.Name("test.service"),
+			server.Registry(memory.NewRegistry()),
+			server.Transport(tmem.NewTransport()),
+			server.Broker(b),
+			server.DrainTimeout(drain),
+		)
+
+		started := make(chan bool)
+		var handled int32
+
+		if err := srv.Subscribe(srv.NewSubscriber("test.topic", func(ctx context.Context, req *pb.Error) error {
+			close(started)
+			time.Sleep(time.Millisecond * 100)
+			atomic.StoreInt32(&handled, 1)
+			return nil
+		})); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := srv.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		go b.Publish("test.topic", &broker.Message{
+			Header: map[string]string{"Content-Type": "application/json", "Micro-Topic": "test.topic"},
+			Body:   []byte(`{}`),
+		})
+		<-started
+
+		if err := srv.Stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		if atomic.LoadInt32(&handled) != 1 {
+			t.Fatalf("expected the server to stop once the message was handled with drain timeout %v", drain)
+		}
+	}
+}
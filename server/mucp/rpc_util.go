@@ -2,6 +2,10 @@ package mucp
 
 import (
 	"sync"
+	"sync/atomic"
+
+	"github.com/asim/go-micro/v3/util/addr"
+	mnet "github.com/asim/go-micro/v3/util/net"
 )
 
 // waitgroup for global management of connections
@@ -10,10 +14,15 @@ type waitGroup struct {
 	lg sync.WaitGroup
 	// global waitgroup
 	gg *sync.WaitGroup
+	// global count of the work in progress
+	n *int64
 }
 
 func (w *waitGroup) Add(i int) {
 	w.lg.Add(i)
+	if w.n != nil {
+		atomic.AddInt64(w.n, int64(i))
+	}
 	if w.gg != nil {
 		w.gg.Add(i)
 	}
@@ -21,6 +30,9 @@ func (w *waitGroup) Add(i int) {
 
 func (w *waitGroup) Done() {
 	w.lg.Done()
+	if w.n != nil {
+		atomic.AddInt64(w.n, -1)
+	}
 	if w.gg != nil {
 		w.gg.Done()
 	}
@@ -30,3 +42,12 @@ func (w *waitGroup) Wait() {
 	// only wait on local group
 	w.lg.Wait()
 }
+
+// extract returns the address to advertise for the host. Unix socket
+// addresses keep their scheme so they're dialled correctly.
+func extract(host string) (string, error) {
+	if mnet.IsUnix(host) {
+		return host, nil
+	}
+	return addr.Extract(host)
+}
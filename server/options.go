@@ -10,11 +10,15 @@ import (
 	"github.com/asim/go-micro/v3/broker"
 	mbroker "github.com/asim/go-micro/v3/broker/memory"
 	"github.com/asim/go-micro/v3/codec"
+	"github.com/asim/go-micro/v3/debug/stats"
 	"github.com/asim/go-micro/v3/debug/trace"
+	"github.com/asim/go-micro/v3/health"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/registry/memory"
 	"github.com/asim/go-micro/v3/transport"
 	tmem "github.com/asim/go-micro/v3/transport/memory"
+	"github.com/asim/go-micro/v3/util/backoff"
+	mtls "github.com/asim/go-micro/v3/util/tls"
 )
 
 type Options struct {
@@ -33,6 +37,30 @@ type Options struct {
 	Version      string
 	HdlrWrappers []HandlerWrapper
 	SubWrappers  []SubscriberWrapper
+	// Middleware is the ordered chain the handler wrappers are built from
+	Middleware []Middleware
+	// Limiter limits the concurrent requests of individual endpoints
+	Limiter *HandlerLimiter
+	// Timeouts bound how long individual endpoints may run
+	Timeouts *HandlerTimeouts
+	// MaxRecvSize is the max size in bytes of a message received
+	MaxRecvSize int
+	// CompressThreshold is the min size in bytes of the responses compressed
+	CompressThreshold int
+	// CodecMetrics records the sizes and codec latency of the messages
+	CodecMetrics *codec.Metrics
+	// ListenOptions are passed to the transport when listening
+	ListenOptions []transport.ListenOption
+
+	// AdminAddress serves health checks, metrics and pprof over http
+	AdminAddress string
+	// Health checks served on the admin address, their readiness is
+	// registered with the node
+	Health health.Health
+	// HealthInterval the readiness checks are run on
+	HealthInterval time.Duration
+	// Stats served as metrics on the admin address
+	Stats stats.Stats
 
 	// RegisterCheck runs a check function before registering the service
 	RegisterCheck func(context.Context) error
@@ -40,6 +68,21 @@ type Options struct {
 	RegisterTTL time.Duration
 	// The interval on which to register
 	RegisterInterval time.Duration
+	// RegisterJitter is the most the interval is shortened by at random,
+	// a tenth of the interval by default
+	RegisterJitter time.Duration
+	// RegisterBackoff between failed register attempts, exponential and
+	// capped at the interval by default
+	RegisterBackoff backoff.Strategy
+	// RegisterFailure is called each time registering on the interval
+	// failed with the error and the number of failures in a row
+	RegisterFailure func(err error, failures int)
+	// DrainTimeout is the deadline between deregistering and closing
+	// the listener on stop
+	DrainTimeout time.Duration
+	// DrainGrace is how long the server keeps serving after deregistering
+	// before waiting for the requests in flight, within the DrainTimeout
+	DrainGrace time.Duration
 
 	// The router for requests
 	Router Router
@@ -58,6 +101,8 @@ func newOptions(opt ...Option) Options {
 		Metadata:         map[string]string{},
 		RegisterInterval: DefaultRegisterInterval,
 		RegisterTTL:      DefaultRegisterTTL,
+		RegisterBackoff:  backoff.Do,
+		HealthInterval:   DefaultHealthInterval,
 	}
 
 	for _, o := range opt {
@@ -220,6 +265,137 @@ func RegisterInterval(t time.Duration) Option {
 	}
 }
 
+// RegisterJitter shortens the register interval by up to d at random so
+// services started together don't register at once
+func RegisterJitter(d time.Duration) Option {
+	return func(o *Options) {
+		o.RegisterJitter = d
+	}
+}
+
+// RegisterFailure sets a hook called each time registering on the interval
+// fails, e.g to alert once the failures in a row pass a threshold
+func RegisterFailure(fn func(err error, failures int)) Option {
+	return func(o *Options) {
+		o.RegisterFailure = fn
+	}
+}
+
+// RegisterBackoff sets the backoff strategy between failed register
+// attempts, the waits are capped at the RegisterInterval
+func RegisterBackoff(s backoff.Strategy) Option {
+	return func(o *Options) {
+		o.RegisterBackoff = s
+	}
+}
+
+// DrainTimeout drains the server on stop. It deregisters then waits for
+// in-flight requests before closing the listener, stopping within the
+// timeout.
+func DrainTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.DrainTimeout = d
+	}
+}
+
+// DrainGrace keeps the server serving for the grace period after it
+// deregisters so selectors stop sending it traffic before it's drained. It
+// counts towards the DrainTimeout, which defaults to the grace period.
+func DrainGrace(d time.Duration) Option {
+	return func(o *Options) {
+		o.DrainGrace = d
+	}
+}
+
+// HandlerLimit limits the endpoint e.g "Users.Create" to maxConcurrent
+// requests at once. Up to maxQueue requests wait for a slot, beyond that
+// requests fail with ErrHandlerLimit.
+func HandlerLimit(endpoint string, maxConcurrent, maxQueue int) Option {
+	return func(o *Options) {
+		if o.Limiter == nil {
+			o.Limiter = NewHandlerLimiter()
+			use(o, -1, Middleware{Name: LimitMiddleware, Wrapper: o.Limiter.Wrapper()})
+		}
+		o.Limiter.Limit(endpoint, maxConcurrent, maxQueue)
+	}
+}
+
+// HandlerTimeout bounds how long the endpoint e.g "Orders.Export" may run.
+// The handler context is cancelled and a timeout error returned once the
+// timeout passes, regardless of the deadline set by the client.
+func HandlerTimeout(endpoint string, d time.Duration) Option {
+	return func(o *Options) {
+		if o.Timeouts == nil {
+			o.Timeouts = NewHandlerTimeouts()
+			use(o, -1, Middleware{Name: TimeoutMiddleware, Wrapper: o.Timeouts.Wrapper()})
+		}
+		o.Timeouts.Set(endpoint, d)
+	}
+}
+
+// MaxRecvSize limits the size of the messages received. The transport
+// drops the connections sending messages far larger while reading them,
+// the others are rejected with a 413 error without being decoded.
+func MaxRecvSize(n int) Option {
+	return func(o *Options) {
+		o.MaxRecvSize = n
+	}
+}
+
+// Compression compresses the responses of at least min bytes with the
+// compression preferred by the client, if it accepts one
+func Compression(min int) Option {
+	return func(o *Options) {
+		o.CompressThreshold = min
+	}
+}
+
+// CodecMetrics records the sizes and codec latency of the requests read
+// and responses written per endpoint
+func CodecMetrics(m *codec.Metrics) Option {
+	return func(o *Options) {
+		o.CodecMetrics = m
+	}
+}
+
+// ListenOptions are passed to the transport when listening
+// e.g transport.ReusePort() or transport.IPv6Only()
+func ListenOptions(opts ...transport.ListenOption) Option {
+	return func(o *Options) {
+		o.ListenOptions = append(o.ListenOptions, opts...)
+	}
+}
+
+// AdminAddress starts an http listener next to the rpc server serving
+// /healthz, /readyz, /metrics and /debug/pprof e.g :9090
+func AdminAddress(addr string) Option {
+	return func(o *Options) {
+		o.AdminAddress = addr
+	}
+}
+
+// Health sets the checks served on the admin address
+func Health(h health.Health) Option {
+	return func(o *Options) {
+		o.Health = h
+	}
+}
+
+// HealthInterval sets the interval the readiness checks are run on, the
+// node is registered again as soon as its status changes
+func HealthInterval(t time.Duration) Option {
+	return func(o *Options) {
+		o.HealthInterval = t
+	}
+}
+
+// Stats sets the stats served as metrics on the admin address
+func Stats(s stats.Stats) Option {
+	return func(o *Options) {
+		o.Stats = s
+	}
+}
+
 // TLSConfig specifies a *tls.Config
 func TLSConfig(t *tls.Config) Option {
 	return func(o *Options) {
@@ -240,6 +416,12 @@ func TLSConfig(t *tls.Config) Option {
 	}
 }
 
+// TLSReload serves with the certificate of the reloader, which is
+// stopped by the caller once the server is
+func TLSReload(r *mtls.Reloader) Option {
+	return TLSConfig(r.Config())
+}
+
 // WithRouter sets the request router
 func WithRouter(r Router) Option {
 	return func(o *Options) {
@@ -266,7 +448,7 @@ func Wait(wg *sync.WaitGroup) Option {
 // Adds a handler Wrapper to a list of options passed into the server
 func WrapHandler(w HandlerWrapper) Option {
 	return func(o *Options) {
-		o.HdlrWrappers = append(o.HdlrWrappers, w)
+		use(o, -1, Middleware{Wrapper: w})
 	}
 }
 
@@ -140,4 +140,5 @@ var (
 	DefaultRegisterCheck    = func(context.Context) error { return nil }
 	DefaultRegisterInterval = time.Second * 30
 	DefaultRegisterTTL      = time.Second * 90
+	DefaultHealthInterval   = time.Second * 10
 )
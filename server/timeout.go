@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+const (
+	// TimeoutMiddleware is the name of the handler timeout middleware
+	TimeoutMiddleware = "timeout"
+)
+
+// HandlerTimeouts bounds how long individual endpoints may run
+type HandlerTimeouts struct {
+	sync.RWMutex
+	timeouts map[string]time.Duration
+}
+
+// NewHandlerTimeouts returns handler timeouts without any timeouts
+func NewHandlerTimeouts() *HandlerTimeouts {
+	return &HandlerTimeouts{
+		timeouts: make(map[string]time.Duration),
+	}
+}
+
+// Set the timeout of the endpoint
+func (h *HandlerTimeouts) Set(endpoint string, d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	h.timeouts[endpoint] = d
+}
+
+// Wrapper returns the handler wrapper enforcing the timeouts. The handler
+// context is cancelled once the timeout passes and the handler is waited
+// for, so it holds its slot of the handler limit and is drained, and the
+// error reports whether the timeout or the caller ended it.
+func (h *HandlerTimeouts) Wrapper() HandlerWrapper {
+	return func(fn HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req Request, rsp interface{}) error {
+			h.RLock()
+			d, ok := h.timeouts[req.Endpoint()]
+			h.RUnlock()
+
+			if !ok || d <= 0 {
+				return fn(ctx, req, rsp)
+			}
+
+			tctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			err := fn(tctx, req, rsp)
+
+			switch {
+			case tctx.Err() == nil:
+				return err
+			case ctx.Err() != nil:
+				// the deadline or cancellation of the caller
+				return errors.Timeout("go.micro.server", "%s %v", req.Endpoint(), ctx.Err())
+			default:
+				return errors.Timeout("go.micro.server", "%s deadline exceeded after %v", req.Endpoint(), d)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerTimeouts(t *testing.T) {
+	h := NewHandlerTimeouts()
+	h.Set("Test.Call", time.Millisecond*20)
+
+	var finished bool
+	fn := h.Wrapper()(func(ctx context.Context, req Request, rsp interface{}) error {
+		<-ctx.Done()
+		time.Sleep(time.Millisecond * 10)
+		finished = true
+		return ctx.Err()
+	})
+
+	// the handler is waited for once it's cut off
+	err := fn(context.Background(), &testRequest{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded after 20ms") {
+		t.Fatalf("expected the handler timeout got %v", err)
+	}
+	if !finished {
+		t.Fatal("expected the handler to finish before returning")
+	}
+
+	// the cancellation of the caller is reported as such
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = fn(ctx, &testRequest{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Fatalf("expected the caller cancellation got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package mucp
+
+import (
+	"context"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/server"
+	"github.com/asim/go-micro/v3/util/pool"
+)
+
+// metadataClient adds the service metadata to outbound requests
+type metadataClient struct {
+	client.Client
+	s *mucpService
+}
+
+// context merges the service metadata without overwriting the
+// metadata already set by the caller and identifies the service
+func (c *metadataClient) context(ctx context.Context) context.Context {
+	ctx = metadata.Set(ctx, server.FromServiceKey, c.s.Server().Options().Name)
+
+	md := c.s.opts.Metadata
+	if len(md) == 0 {
+		return ctx
+	}
+	return metadata.MergeContext(ctx, md, false)
+}
+
+func (c *metadataClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return c.Client.Call(c.context(ctx), req, rsp, opts...)
+}
+
+func (c *metadataClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return c.Client.Stream(c.context(ctx), req, opts...)
+}
+
+func (c *metadataClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	return c.Client.Publish(c.context(ctx), msg, opts...)
+}
+
+// poolStats returns the connection pool stats func of the client if it has a pool
+func poolStats(c client.Client) func() pool.Stats {
+	for {
+		switch v := c.(type) {
+		case interface{ PoolStats() pool.Stats }:
+			return v.PoolStats
+		case *metadataClient:
+			c = v.Client
+		default:
+			return nil
+		}
+	}
+}
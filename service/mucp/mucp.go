@@ -2,22 +2,84 @@
 package mucp
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/auth"
 	"github.com/asim/go-micro/v3/client"
 	cmucp "github.com/asim/go-micro/v3/client/mucp"
+	"github.com/asim/go-micro/v3/debug/handler"
+	"github.com/asim/go-micro/v3/health"
+	hhttp "github.com/asim/go-micro/v3/health/http"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/server"
 	smucp "github.com/asim/go-micro/v3/server/mucp"
 	"github.com/asim/go-micro/v3/service"
+	"github.com/asim/go-micro/v3/util/backoff"
+	signalutil "github.com/asim/go-micro/v3/util/signal"
+	"github.com/asim/go-micro/v3/util/wrapper"
 )
 
 type mucpService struct {
 	opts service.Options
+
+	// serves the http health checks
+	health *hhttp.Server
+	// asynchronous errors terminating Run
+	errs chan error
+	// stops the monitor
+	exit chan bool
+
+	sync.RWMutex
+	// values stored with Set in insertion order
+	keys   []string
+	values map[string]interface{}
 }
 
 func newService(opts ...service.Option) service.Service {
 	options := service.NewOptions(opts...)
 
-	return &mucpService{
-		opts: options,
+	s := &mucpService{
+		opts:   options,
+		errs:   make(chan error, 1),
+		values: make(map[string]interface{}),
+	}
+
+	// recover panics in handlers
+	s.opts.Server.Init(server.UseNamed(wrapper.RecoverMiddleware, wrapper.RecoverHandler(s.recovered)))
+
+	// send the service metadata on outbound requests
+	s.wrapClient()
+
+	return s
+}
+
+// wrapClient wraps the client to send the service metadata unless it's
+// wrapped already
+func (s *mucpService) wrapClient() {
+	if _, ok := s.opts.Client.(*metadataClient); !ok {
+		s.opts.Client = &metadataClient{s.opts.Client, s}
+	}
+}
+
+// recovered passes a recovered panic to the panic handler
+func (s *mucpService) recovered(ctx context.Context, req server.Request, r interface{}) {
+	if s.opts.PanicHandler != nil {
+		s.opts.PanicHandler(ctx, req, r)
+		return
+	}
+
+	if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+		logger.Errorf("panic recovered in %s: %v", req.Endpoint(), r)
+		logger.Error(string(debug.Stack()))
 	}
 }
 
@@ -29,10 +91,17 @@ func (s *mucpService) Name() string {
 // which parses command line flags. cmd.Init is only called
 // on first Init.
 func (s *mucpService) Init(opts ...service.Option) {
+	c := s.opts.Client
+
 	// process options
 	for _, o := range opts {
 		o(&s.opts)
 	}
+
+	// the client was replaced or wrapped
+	if s.opts.Client != c {
+		s.wrapClient()
+	}
 }
 
 func (s *mucpService) Options() service.Options {
@@ -44,7 +113,57 @@ func (s *mucpService) Client() client.Client {
 }
 
 func (s *mucpService) Server() server.Server {
-	return s.opts.Server
+	if len(s.opts.Servers) == 0 {
+		return s.opts.Server
+	}
+	return &servers{primary: s.opts.Server, extra: s.opts.Servers}
+}
+
+// Set stores the value on the service. Values implementing
+// io.Closer are closed in reverse order when Run returns.
+func (s *mucpService) Set(key string, val interface{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.values[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.values[key] = val
+}
+
+func (s *mucpService) Get(key string) (interface{}, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	val, ok := s.values[key]
+	return val, ok
+}
+
+// teardown closes the values stored on the service
+func (s *mucpService) teardown() error {
+	s.Lock()
+	defer s.Unlock()
+
+	var gerr error
+
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		c, ok := s.values[s.keys[i]].(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			gerr = err
+		}
+	}
+
+	s.keys = nil
+	s.values = make(map[string]interface{})
+
+	return gerr
+}
+
+func (s *mucpService) Health() health.Health {
+	return s.opts.Health
 }
 
 func (s *mucpService) String() string {
@@ -52,35 +171,108 @@ func (s *mucpService) String() string {
 }
 
 func (s *mucpService) Start() error {
+	ctx := s.opts.Context
+
 	for _, fn := range s.opts.BeforeStart {
 		if err := fn(); err != nil {
 			return err
 		}
 	}
 
-	if err := s.opts.Server.Start(); err != nil {
+	for _, fn := range s.opts.BeforeStartCtx {
+		if err := fn(ctx, s); err != nil {
+			return err
+		}
+	}
+
+	// advertise the service type
+	if len(s.opts.Type) > 0 {
+		md := metadata.Copy(s.opts.Server.Options().Metadata)
+		md["type"] = s.opts.Type
+		s.opts.Server.Init(server.Metadata(md))
+	}
+
+	// the service runs in the domain its registry is scoped to, accounts
+	// are issued in the same namespace
+	if opts := s.opts.Server.Options(); opts.Registry != nil && len(opts.Registry.Options().Domain) > 0 {
+		domain := opts.Registry.Options().Domain
+		if len(opts.Namespace) == 0 {
+			s.opts.Server.Init(server.Namespace(domain))
+		}
+		if opts.Auth != nil && len(opts.Auth.Options().Issuer) == 0 {
+			opts.Auth.Init(auth.Issuer(domain))
+		}
+	}
+
+	// the service health checks are registered with the node and served
+	// on the admin address
+	if opts := s.opts.Server.Options(); opts.Health == nil {
+		s.opts.Server.Init(server.Health(s.opts.Health))
+	}
+
+	if err := s.Server().Start(); err != nil {
 		return err
 	}
 
+	if len(s.opts.HealthAddress) > 0 {
+		s.health = hhttp.NewServer(s.opts.Health, s.opts.HealthAddress)
+		if err := s.health.Start(); err != nil {
+			return err
+		}
+	}
+
+	// watch for asynchronous failures
+	s.exit = make(chan bool)
+	go s.monitor(s.exit, s.health)
+
 	for _, fn := range s.opts.AfterStart {
 		if err := fn(); err != nil {
 			return err
 		}
 	}
 
+	for _, fn := range s.opts.AfterStartCtx {
+		if err := fn(ctx, s); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (s *mucpService) Stop() error {
 	var gerr error
 
+	// the service context is likely done by now so
+	// the stop hooks get their own bounded context
+	ctx, cancel := context.WithTimeout(context.Background(), service.DefaultStopTimeout)
+	defer cancel()
+
 	for _, fn := range s.opts.BeforeStop {
 		if err := fn(); err != nil {
 			gerr = err
 		}
 	}
 
-	if err := s.opts.Server.Stop(); err != nil {
+	for _, fn := range s.opts.BeforeStopCtx {
+		if err := fn(ctx, s); err != nil {
+			gerr = err
+		}
+	}
+
+	if s.exit != nil {
+		close(s.exit)
+		s.exit = nil
+	}
+
+	if s.health != nil {
+		if err := s.health.Stop(); err != nil {
+			gerr = err
+		}
+		s.health = nil
+	}
+
+	if err := s.Server().Stop(); err != nil {
 		return err
 	}
 
@@ -90,18 +282,234 @@ func (s *mucpService) Stop() error {
 		}
 	}
 
+	for _, fn := range s.opts.AfterStopCtx {
+		if err := fn(ctx, s); err != nil {
+			gerr = err
+		}
+	}
+
 	return gerr
 }
 
+// Restart stops the service, processes the options and starts it again.
+// The lifecycle hooks are run as part of the stop and start.
+func (s *mucpService) Restart(opts ...service.Option) error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+
+	s.Init(opts...)
+
+	return s.Start()
+}
+
+// monitor reports errors from the health server and registry heartbeat
+func (s *mucpService) monitor(exit chan bool, hs *hhttp.Server) {
+	var herrs <-chan error
+	if hs != nil {
+		herrs = hs.Errors()
+	}
+
+	interval := s.opts.Server.Options().RegisterInterval
+	if interval <= 0 {
+		interval = server.DefaultRegisterInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case err := <-herrs:
+			s.fail(fmt.Errorf("health server error: %v", err))
+		case <-t.C:
+			if err := s.heartbeat(); err != nil {
+				s.fail(err)
+			}
+		}
+	}
+}
+
+// heartbeat checks the service is still found in the registry
+func (s *mucpService) heartbeat() error {
+	opts := s.opts.Server.Options()
+
+	if _, err := opts.Registry.GetService(opts.Name); err != nil {
+		return fmt.Errorf("registry heartbeat error: %v", err)
+	}
+
+	return nil
+}
+
+// fail logs the error and terminates Run if ExitOnError is set
+func (s *mucpService) fail(err error) {
+	if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+		logger.Errorf("Service %s error: %v", s.Name(), err)
+	}
+
+	if !s.opts.ExitOnError {
+		return
+	}
+
+	select {
+	case s.errs <- err:
+	default:
+	}
+}
+
+// shutdown returns true if the signal stops the service
+func shutdown(sig os.Signal) bool {
+	for _, s := range signalutil.Shutdown() {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// wait blocks until the dependencies are found in the registry
+func (s *mucpService) wait() error {
+	if len(s.opts.Dependencies) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(s.opts.Context, s.opts.DependencyTimeout)
+	defer cancel()
+
+	for _, name := range s.opts.Dependencies {
+		for i := 1; ; i++ {
+			if found(s.opts.Registry, name) {
+				break
+			}
+
+			if logger.V(logger.InfoLevel, logger.DefaultLogger) {
+				logger.Infof("Waiting for dependency %s", name)
+			}
+
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("dependency %s not found: %v", name, ctx.Err())
+			case <-time.After(backoff.Do(i)):
+			}
+		}
+	}
+
+	return nil
+}
+
+// found returns true if the service has nodes in the registry
+func found(r registry.Registry, name string) bool {
+	services, err := r.GetService(name)
+	if err != nil {
+		return false
+	}
+	for _, srv := range services {
+		if len(srv.Nodes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *mucpService) Run() error {
+	// validate the config without starting
+	if s.opts.Validate {
+		return s.validate()
+	}
+
+	// register the debug handler
+	if !s.opts.DisableDebug {
+		h := s.opts.DebugHandler
+		if h == nil {
+			hopts := []handler.Option{
+				handler.Health(s.opts.Health),
+				handler.Metadata(s.opts.Metadata),
+				handler.WithReport(s.report),
+				handler.Breaker(s.opts.Client.Options().Breaker),
+				handler.Pool(poolStats(s.opts.Client)),
+				handler.Shadows(s.opts.Client.Options().ShadowStats),
+				handler.RateLimiter(s.opts.Client.Options().RateLimiter),
+				handler.Concurrency(s.opts.Client.Options().Concurrency),
+				handler.TLSConfig(tlsConfig(s.opts.Server)),
+				handler.Broker(s.opts.Broker.Options().Metrics),
+				handler.Codec(s.opts.Server.Options().CodecMetrics),
+			}
+			if s.opts.Flags != nil {
+				hopts = append(hopts, handler.Flags(s.opts.Flags))
+			}
+			h = handler.NewHandler(hopts...)
+		}
+
+		if err := s.Server().Handle(
+			s.Server().NewHandler(h, server.InternalHandler(true)),
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := s.wait(); err != nil {
+		return err
+	}
+
+	// trap the shutdown and handled signals
+	var sigs []os.Signal
+
+	if s.opts.Signal {
+		sigs = append(sigs, signalutil.Shutdown()...)
+	}
+	for sig := range s.opts.Signals {
+		sigs = append(sigs, sig)
+	}
+
+	ch := make(chan os.Signal, 1)
+	if len(sigs) > 0 {
+		signal.Notify(ch, sigs...)
+		defer signal.Stop(ch)
+	}
+
 	if err := s.Start(); err != nil {
 		return err
 	}
 
-	// wait on context cancel
-	<-s.opts.Context.Done()
+	s.logReport()
+
+	// wait on context cancel, a shutdown signal or an error
+	var err error
 
-	return s.Stop()
+Loop:
+	for {
+		select {
+		case sig := <-ch:
+			if fn, ok := s.opts.Signals[sig]; ok {
+				fn(s)
+			}
+			if s.opts.Signal && shutdown(sig) {
+				if logger.V(logger.InfoLevel, logger.DefaultLogger) {
+					logger.Infof("Received signal %s", sig)
+				}
+				break Loop
+			}
+		case <-s.opts.Context.Done():
+			break Loop
+		case err = <-s.errs:
+			break Loop
+		}
+	}
+
+	// the values are closed even if stopping fails
+	serr := s.Stop()
+	terr := s.teardown()
+
+	if serr != nil {
+		return serr
+	}
+	if terr != nil {
+		return terr
+	}
+
+	return err
 }
 
 // NewService returns a new mucp service
@@ -115,3 +523,38 @@ func NewService(opts ...service.Option) service.Service {
 
 	return newService(options...)
 }
+
+// NewAPIService returns a mucp service of TypeAPI. It registers more
+// frequently with a shorter TTL so gateways route to fresh nodes.
+func NewAPIService(opts ...service.Option) service.Service {
+	options := []service.Option{
+		service.Type(service.TypeAPI),
+		service.RegisterTTL(time.Second * 30),
+		service.RegisterInterval(time.Second * 10),
+	}
+
+	return NewService(append(options, opts...)...)
+}
+
+// NewWebService returns a mucp service of TypeWeb. It registers more
+// frequently with a shorter TTL so proxies route to fresh nodes.
+func NewWebService(opts ...service.Option) service.Service {
+	options := []service.Option{
+		service.Type(service.TypeWeb),
+		service.RegisterTTL(time.Second * 30),
+		service.RegisterInterval(time.Second * 10),
+	}
+
+	return NewService(append(options, opts...)...)
+}
+
+// NewEventService returns a mucp service of TypeEvent. Its server waits
+// for in flight messages to be processed before stopping.
+func NewEventService(opts ...service.Option) service.Service {
+	options := []service.Option{
+		service.Server(smucp.NewServer(server.Wait(nil))),
+		service.Type(service.TypeEvent),
+	}
+
+	return NewService(append(options, opts...)...)
+}
@@ -0,0 +1,1276 @@
+package mucp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	cmucp "github.com/asim/go-micro/v3/client/mucp"
+	"github.com/asim/go-micro/v3/codec"
+	raw "github.com/asim/go-micro/v3/codec/bytes"
+	cjson "github.com/asim/go-micro/v3/codec/json"
+	"github.com/asim/go-micro/v3/debug/handler"
+	merrors "github.com/asim/go-micro/v3/errors"
+	pb "github.com/asim/go-micro/v3/errors/proto"
+	"github.com/asim/go-micro/v3/flags"
+	fstore "github.com/asim/go-micro/v3/flags/store"
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/proxy"
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/registry/memory"
+	"github.com/asim/go-micro/v3/server"
+	smucp "github.com/asim/go-micro/v3/server/mucp"
+	"github.com/asim/go-micro/v3/service"
+	"github.com/asim/go-micro/v3/store"
+	smemory "github.com/asim/go-micro/v3/store/memory"
+	tmem "github.com/asim/go-micro/v3/transport/memory"
+)
+
+func TestServiceHooks(t *testing.T) {
+	var called []string
+
+	hook := func(name string) service.HookFunc {
+		return func(ctx context.Context, s service.Service) error {
+			if ctx == nil {
+				t.Fatalf("%s: expected a context", name)
+			}
+			if s.Name() != "test.service" {
+				t.Fatalf("%s: expected service test.service got %s", name, s.Name())
+			}
+			called = append(called, name)
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	srv := NewService(
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.BeforeStartCtx(hook("before-start")),
+		service.AfterStartCtx(hook("after-start")),
+		service.BeforeStopCtx(hook("before-stop")),
+		service.AfterStopCtx(func(ctx context.Context, s service.Service) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Fatal("expected stop hook context to have a deadline")
+			}
+			called = append(called, "after-stop")
+			return nil
+		}),
+		service.AfterStartCtx(func(context.Context, service.Service) error {
+			cancel()
+			return nil
+		}),
+	)
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"before-start", "after-start", "before-stop", "after-stop"}
+	if len(called) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, called)
+	}
+	for i, name := range expected {
+		if called[i] != name {
+			t.Fatalf("expected %v got %v", expected, called)
+		}
+	}
+}
+
+func TestServiceType(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	srv := NewService(
+		service.Name("apidata"),
+		service.Registry(reg),
+		service.Type(service.TypeWeb),
+	)
+
+	if err := srv.(*mucpService).Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.(*mucpService).Stop()
+
+	services, err := reg.GetService("apidata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := services[0].Nodes[0].Metadata["type"]; v != service.TypeWeb {
+		t.Fatalf("expected type %s got %s", service.TypeWeb, v)
+	}
+}
+
+func TestServiceAddServer(t *testing.T) {
+	reg := memory.NewRegistry()
+	extra := smucp.NewServer()
+
+	srv := NewService(
+		service.Name("test.service"),
+		service.Registry(reg),
+		service.Metadata(map[string]string{"foo": "bar"}),
+		service.AddServer(extra),
+	)
+
+	if err := srv.(*mucpService).Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.(*mucpService).Stop()
+
+	if name := extra.Options().Name; name != "test.service" {
+		t.Fatalf("expected name test.service got %s", name)
+	}
+
+	services, err := reg.GetService("test.service")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := services[0].Nodes
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes got %d", len(nodes))
+	}
+	if nodes[0].Id == nodes[1].Id {
+		t.Fatalf("expected unique node ids got %s", nodes[0].Id)
+	}
+	for _, node := range nodes {
+		if v := node.Metadata["foo"]; v != "bar" {
+			t.Fatalf("expected metadata foo=bar got %s", v)
+		}
+	}
+}
+
+func TestServiceRestart(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	srv := NewService(
+		service.Name("test.service"),
+		service.Registry(reg),
+	)
+
+	if err := srv.(*mucpService).Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.(*mucpService).Stop()
+
+	if err := srv.Restart(service.Metadata(map[string]string{"foo": "bar"})); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := reg.GetService("test.service")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := services[0].Nodes[0].Metadata["foo"]; v != "bar" {
+		t.Fatalf("expected metadata foo=bar got %s", v)
+	}
+}
+
+func TestServiceDisableDebug(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var cerr error
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.DisableDebug(),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			req := s.Client().NewRequest("test.service", "Debug.Health", &handler.HealthRequest{})
+			cerr = s.Client().Call(ctx, req, new(handler.HealthResponse))
+			return nil
+		}),
+	)
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cerr == nil {
+		t.Fatal("expected the debug handler to be disabled")
+	}
+}
+
+func TestServiceDependsOn(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	srv := NewService(
+		service.Name("test.service"),
+		service.Registry(reg),
+		service.DependsOn("test.dependency"),
+		service.DependencyTimeout(time.Millisecond*200),
+		service.AfterStartCtx(func(context.Context, service.Service) error {
+			t.Fatal("expected the service not to start")
+			return nil
+		}),
+	)
+
+	if err := srv.Run(); err == nil {
+		t.Fatal("expected dependency timeout error")
+	}
+
+	// register the dependency and try again
+	if err := reg.Register(&registry.Service{
+		Name:  "test.dependency",
+		Nodes: []*registry.Node{{Id: "test.dependency-1", Address: "localhost:9999"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	srv = NewService(
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.Registry(reg),
+		service.DependsOn("test.dependency"),
+		service.AfterStartCtx(func(context.Context, service.Service) error {
+			cancel()
+			return nil
+		}),
+	)
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type Panicker struct{}
+
+func (t *Panicker) Call(ctx context.Context, req *handler.HealthRequest, rsp *handler.HealthResponse) error {
+	panic("boom")
+}
+
+func TestServicePanicHandler(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		cerr      error
+		recovered interface{}
+	)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.PanicHandler(func(ctx context.Context, req server.Request, r interface{}) {
+			recovered = r
+		}),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			req := s.Client().NewRequest("test.service", "Panicker.Call", &handler.HealthRequest{})
+			cerr = s.Client().Call(ctx, req, new(handler.HealthResponse), client.WithRetries(0))
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Panicker))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if recovered != "boom" {
+		t.Fatalf("expected boom got %v", recovered)
+	}
+	if cerr == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestServiceExitOnError(t *testing.T) {
+	// hold the address so the health server fails
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	srv := NewService(
+		service.Name("test.service"),
+		service.HealthAddress(l.Addr().String()),
+		service.ExitOnError(true),
+	)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Run()
+	}()
+
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected Run to exit")
+	}
+}
+
+type testCloser struct {
+	name   string
+	closed *[]string
+}
+
+func (c *testCloser) Close() error {
+	*c.closed = append(*c.closed, c.name)
+	return nil
+}
+
+func TestServiceValues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var closed []string
+
+	srv := NewService(
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			cancel()
+			return nil
+		}),
+	)
+
+	srv.Set("db", &testCloser{"db", &closed})
+	srv.Set("cache", &testCloser{"cache", &closed})
+	srv.Set("name", "foo")
+
+	if v, ok := srv.Get("name"); !ok || v != "foo" {
+		t.Fatalf("expected foo got %v", v)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(closed) != 2 || closed[0] != "cache" || closed[1] != "db" {
+		t.Fatalf("expected [cache db] closed got %v", closed)
+	}
+
+	if _, ok := srv.Get("db"); ok {
+		t.Fatal("expected values to be removed")
+	}
+
+	// values are closed when stopping fails
+	ctx, cancel = context.WithCancel(context.Background())
+	closed = nil
+
+	srv = NewService(
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			cancel()
+			return nil
+		}),
+		service.BeforeStop(func() error {
+			return errors.New("stop failed")
+		}),
+	)
+	srv.Set("db", &testCloser{"db", &closed})
+
+	if err := srv.Run(); err == nil || err.Error() != "stop failed" {
+		t.Fatalf("expected the stop error got %v", err)
+	}
+	if len(closed) != 1 {
+		t.Fatalf("expected [db] closed got %v", closed)
+	}
+}
+
+func TestServiceHandleSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var handled bool
+
+	srv := NewService(
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.HandleSignal(syscall.SIGHUP, func(service.Service) {
+			handled = true
+			cancel()
+		}),
+		service.AfterStartCtx(func(context.Context, service.Service) error {
+			p, err := os.FindProcess(os.Getpid())
+			if err != nil {
+				return err
+			}
+			return p.Signal(syscall.SIGHUP)
+		}),
+	)
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !handled {
+		t.Fatal("expected SIGHUP to be handled")
+	}
+}
+
+type Echo struct{}
+
+// Call returns the metadata of the request
+func (e *Echo) Call(ctx context.Context, req *handler.InfoRequest, rsp *handler.InfoResponse) error {
+	md, _ := metadata.FromContext(ctx)
+	rsp.Metadata = md
+	return nil
+}
+
+func TestServiceMetadata(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		echo = new(handler.InfoResponse)
+		info = new(handler.InfoResponse)
+		cerr error
+	)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.Metadata(map[string]string{"Region": "eu"}),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			req := s.Client().NewRequest("test.service", "Echo.Call", &handler.InfoRequest{})
+			if cerr = s.Client().Call(ctx, req, echo); cerr != nil {
+				return nil
+			}
+			req = s.Client().NewRequest("test.service", "Debug.Info", &handler.InfoRequest{})
+			cerr = s.Client().Call(ctx, req, info)
+			return nil
+		}),
+	)
+
+	// the client set on Init sends the metadata too
+	srv.Init(service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))))
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Echo))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cerr != nil {
+		t.Fatal(cerr)
+	}
+	if v := echo.Metadata["Region"]; v != "eu" {
+		t.Fatalf("expected outbound metadata Region=eu got %v", echo.Metadata)
+	}
+	if v := info.Metadata["Region"]; v != "eu" {
+		t.Fatalf("expected debug metadata Region=eu got %v", info.Metadata)
+	}
+	if info.Report == nil || info.Report.Name != "test.service" || len(info.Report.Address) == 0 {
+		t.Fatalf("expected startup report got %+v", info.Report)
+	}
+}
+
+type Flagged struct{}
+
+// Call returns the flags checked in the context
+func (f *Flagged) Call(ctx context.Context, req *handler.FlagsRequest, rsp *handler.FlagsResponse) error {
+	rsp.Flags = map[string]bool{"dark-mode": flags.Enabled(ctx, "dark-mode")}
+	return nil
+}
+
+func TestServiceFlags(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	st := smemory.NewStore()
+	if err := st.Write(&store.Record{Key: "flags/dark-mode", Value: []byte(`true`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		checked = new(handler.FlagsResponse)
+		listed  = new(handler.FlagsResponse)
+		cerr    error
+	)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.Flags(fstore.NewFlags(flags.Store(st))),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			req := s.Client().NewRequest("test.service", "Flagged.Call", &handler.FlagsRequest{})
+			if cerr = s.Client().Call(ctx, req, checked); cerr != nil {
+				return nil
+			}
+			req = s.Client().NewRequest("test.service", "Debug.Flags", &handler.FlagsRequest{})
+			cerr = s.Client().Call(ctx, req, listed)
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Flagged))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cerr != nil {
+		t.Fatal(cerr)
+	}
+	if !checked.Flags["dark-mode"] {
+		t.Fatalf("expected the handler to see dark-mode enabled got %v", checked.Flags)
+	}
+	if !listed.Flags["dark-mode"] {
+		t.Fatalf("expected the debug handler to report dark-mode got %v", listed.Flags)
+	}
+}
+
+func TestServicePresets(t *testing.T) {
+	presets := map[string]func(...service.Option) service.Service{
+		service.TypeAPI:   NewAPIService,
+		service.TypeWeb:   NewWebService,
+		service.TypeEvent: NewEventService,
+	}
+
+	for typ, fn := range presets {
+		reg := memory.NewRegistry()
+
+		srv := fn(
+			service.Name("test.service"),
+			service.Registry(reg),
+		)
+
+		if err := srv.(*mucpService).Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		services, err := reg.GetService("test.service")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v := services[0].Nodes[0].Metadata["type"]; v != typ {
+			t.Fatalf("expected type %s got %s", typ, v)
+		}
+
+		if err := srv.(*mucpService).Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestServiceValidate(t *testing.T) {
+	srv := NewService(
+		service.Name("test.service"),
+		service.Validate(),
+		service.AfterStartCtx(func(context.Context, service.Service) error {
+			t.Fatal("expected the service not to start")
+			return nil
+		}),
+	)
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Health().Register("db", func(context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	if err := srv.Run(); err == nil {
+		t.Fatal("expected validation to fail")
+	}
+}
+
+type Sleeper struct{}
+
+func (t *Sleeper) Call(ctx context.Context, req *handler.HealthRequest, rsp *handler.HealthResponse) error {
+	time.Sleep(time.Millisecond * 100)
+	rsp.Status = "ok"
+	return nil
+}
+
+func TestServiceDrainTimeout(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cerr := make(chan error, 1)
+	var registered bool
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.DrainTimeout(time.Millisecond*200),
+		service.DrainGrace(time.Millisecond*50),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			go func() {
+				req := s.Client().NewRequest("test.service", "Sleeper.Call", &handler.HealthRequest{})
+				cerr <- s.Client().Call(context.Background(), req, new(handler.HealthResponse), client.WithRetries(0))
+			}()
+			// stop while the request is in flight
+			time.Sleep(time.Millisecond * 10)
+			cancel()
+			return nil
+		}),
+		service.AfterStop(func() error {
+			svcs, _ := reg.GetService("test.service")
+			registered = len(svcs) > 0
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Sleeper))); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Since(start); d < time.Millisecond*60 {
+		t.Fatalf("expected the server to drain for the grace period got %v", d)
+	} else if d > time.Millisecond*180 {
+		// the grace period is part of the drain timeout
+		t.Fatalf("expected the server to stop once the request finished got %v", d)
+	}
+
+	// the in-flight request finished before the listener closed
+	if err := <-cerr; err != nil {
+		t.Fatal(err)
+	}
+
+	if registered {
+		t.Fatal("expected the service to be deregistered")
+	}
+}
+
+func TestServiceHandlerLimit(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errs := make(chan error, 3)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(
+			server.Registry(reg),
+			server.Transport(tr),
+			server.HandlerLimit("Sleeper.Call", 1, 1),
+		)),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			for i := 0; i < 3; i++ {
+				go func() {
+					req := s.Client().NewRequest("test.service", "Sleeper.Call", &handler.HealthRequest{})
+					errs <- s.Client().Call(context.Background(), req, new(handler.HealthResponse), client.WithRetries(0))
+				}()
+			}
+			// one request is handled, one queued and one rejected
+			var limited int
+			for i := 0; i < 3; i++ {
+				if err := <-errs; err != nil {
+					if e := merrors.FromError(err); e.Code != 429 {
+						t.Errorf("expected a 429 got %v", err)
+					}
+					limited++
+				}
+			}
+			if limited != 1 {
+				t.Errorf("expected 1 request to be limited got %d", limited)
+			}
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Sleeper))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServiceHandlerTimeout(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var cerr error
+
+	srv := NewService(
+		service.Server(smucp.NewServer(
+			server.Registry(reg),
+			server.Transport(tr),
+			server.HandlerTimeout("Sleeper.Call", time.Millisecond*20),
+		)),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			req := s.Client().NewRequest("test.service", "Sleeper.Call", &handler.HealthRequest{})
+			cerr = s.Client().Call(context.Background(), req, new(handler.HealthResponse), client.WithRetries(0))
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Sleeper))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client timeout is longer but the handler is cut off
+	if e := merrors.FromError(cerr); e.Code != 408 {
+		t.Fatalf("expected a 408 got %v", cerr)
+	}
+}
+
+func TestServiceMessageSize(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var recvErr, sendErr error
+
+	srv := NewService(
+		service.Server(smucp.NewServer(
+			server.Registry(reg),
+			server.Transport(tr),
+			server.MaxRecvSize(64),
+		)),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			body := &handler.HealthRequest{Type: string(make([]byte, 128))}
+
+			req := s.Client().NewRequest("test.service", "Sleeper.Call", body)
+			recvErr = s.Client().Call(context.Background(), req, new(handler.HealthResponse), client.WithRetries(0))
+
+			c := cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"), client.MaxSendSize(64))
+			sendErr = c.Call(context.Background(), c.NewRequest("test.service", "Sleeper.Call", body), new(handler.HealthResponse), client.WithRetries(0))
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Sleeper))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if e := merrors.FromError(recvErr); e.Code != 413 {
+		t.Fatalf("expected a 413 got %v", recvErr)
+	}
+
+	var serr *codec.MessageSizeError
+	if !errors.As(sendErr, &serr) || serr.Limit != 64 {
+		t.Fatalf("expected a message size error got %v", sendErr)
+	}
+}
+
+type Echoer struct{}
+
+func (e *Echoer) Call(ctx context.Context, req *handler.HealthRequest, rsp *handler.HealthResponse) error {
+	rsp.Status = req.Type
+	return nil
+}
+
+func TestServiceCompression(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	body := &handler.HealthRequest{Type: strings.Repeat("a", 4096)}
+	echoed := make(map[string]string)
+	var limitErr error
+
+	srv := NewService(
+		service.Server(smucp.NewServer(
+			server.Registry(reg),
+			server.Transport(tr),
+			server.MaxRecvSize(8192),
+			server.Compression(64),
+		)),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			for _, c := range []string{"", "gzip", "zstd", "snappy"} {
+				rsp := new(handler.HealthResponse)
+				req := s.Client().NewRequest("test.service", "Echoer.Call", body)
+				if err := s.Client().Call(context.Background(), req, rsp, client.WithCompression(c, 64)); err != nil {
+					return err
+				}
+				echoed[c] = rsp.Status
+			}
+
+			// the decompressed body is limited by the max size
+			large := &handler.HealthRequest{Type: strings.Repeat("a", 16384)}
+			req := s.Client().NewRequest("test.service", "Echoer.Call", large)
+			limitErr = s.Client().Call(context.Background(), req, new(handler.HealthResponse), client.WithCompression("gzip", 64), client.WithRetries(0))
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Echoer))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []string{"", "gzip", "zstd", "snappy"} {
+		if echoed[c] != body.Type {
+			t.Fatalf("expected the body echoed with compression %q", c)
+		}
+	}
+
+	if e := merrors.FromError(limitErr); e.Code != 413 {
+		t.Fatalf("expected a 413 got %v", limitErr)
+	}
+}
+
+func TestServiceCodecs(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	contentTypes := []string{"application/msgpack", "application/cbor"}
+	echoed := make(map[string]string)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			for _, ct := range contentTypes {
+				rsp := new(handler.HealthResponse)
+				req := s.Client().NewRequest("test.service", "Echoer.Call", &handler.HealthRequest{Type: ct}, client.WithContentType(ct))
+				if err := s.Client().Call(context.Background(), req, rsp); err != nil {
+					return err
+				}
+				echoed[ct] = rsp.Status
+			}
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Echoer))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ct := range contentTypes {
+		if echoed[ct] != ct {
+			t.Fatalf("expected the request echoed with %s got %q", ct, echoed[ct])
+		}
+	}
+}
+
+type Errors struct{}
+
+func (e *Errors) Call(ctx context.Context, req *pb.Error, rsp *pb.Error) error {
+	rsp.Id = req.Id
+	return nil
+}
+
+func TestServiceHandlerCodec(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	bodies := make(map[string]string)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			for _, endpoint := range []string{"Errors.Call", "Defaults.Call"} {
+				req := s.Client().NewRequest("test.service", endpoint, &raw.Frame{Data: []byte(`{"id":"test","unknown":1}`)})
+				rsp := new(raw.Frame)
+				if err := s.Client().Call(context.Background(), req, rsp); err != nil {
+					bodies[endpoint] = err.Error()
+					continue
+				}
+				bodies[endpoint] = strings.TrimSpace(string(rsp.Data))
+			}
+			return nil
+		}),
+	)
+
+	opts := cjson.Options{EmitDefaults: true, DiscardUnknown: true}
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Errors))); err != nil {
+		t.Fatal(err)
+	}
+	type Defaults struct{ Errors }
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Defaults), server.HandlerCodec("application/json", cjson.NewCodecWithOptions(opts)))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(bodies["Errors.Call"], "unknown") {
+		t.Fatalf("expected the unknown field to fail with the server codec got %s", bodies["Errors.Call"])
+	}
+	if expect := `{"id":"test","code":0,"detail":"","status":""}`; bodies["Defaults.Call"] != expect {
+		t.Fatalf("expected %s with the handler codec got %s", expect, bodies["Defaults.Call"])
+	}
+}
+
+func TestServiceAccept(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var body string
+	rsp := new(pb.Error)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			req := s.Client().NewRequest("test.service", "Errors.Call", &pb.Error{Id: "test"})
+			if err := s.Client().Call(context.Background(), req, rsp, client.WithAccept("application/json")); err != nil {
+				return err
+			}
+			frame := new(raw.Frame)
+			if err := s.Client().Call(context.Background(), req, frame, client.WithAccept("application/json")); err != nil {
+				return err
+			}
+			body = strings.TrimSpace(string(frame.Data))
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Errors))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.Id != "test" {
+		t.Fatalf("expected the json response read got %+v", rsp)
+	}
+	if body != `{"id":"test"}` {
+		t.Fatalf("expected a json response to the protobuf request got %q", body)
+	}
+}
+
+func TestServiceRelay(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	backend := smucp.NewServer(server.Name("test.service"), server.Registry(reg), server.Transport(tr))
+	if err := backend.Handle(backend.NewHandler(new(Errors))); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Stop()
+
+	cl := cmucp.NewClient(client.Registry(reg), client.Transport(tr))
+	rsp := new(pb.Error)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr), server.WithRouter(proxy.Relay(cl, "test.service")))),
+		service.Client(cl),
+		service.Name("test.relay"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			req := s.Client().NewRequest("test.relay", "Errors.Call", &pb.Error{Id: "test"})
+			return s.Client().Call(context.Background(), req, rsp)
+		}),
+	)
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.Id != "test" {
+		t.Fatalf("expected the response relayed got %+v", rsp)
+	}
+}
+
+func TestServiceCodecMetrics(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sm, cm := codec.NewMetrics(), codec.NewMetrics()
+	stats := new(handler.StatsResponse)
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr), server.CodecMetrics(sm))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.CodecMetrics(cm))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			req := s.Client().NewRequest("test.service", "Errors.Call", &pb.Error{Id: "test"})
+			if err := s.Client().Call(context.Background(), req, new(pb.Error)); err != nil {
+				return err
+			}
+			return s.Client().Call(context.Background(), s.Client().NewRequest("test.service", "Debug.Stats", &handler.StatsRequest{}, client.WithContentType("application/json")), stats)
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Errors))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, st := range [][]*codec.EndpointStat{sm.Stats(), cm.Stats(), stats.Codecs} {
+		var call *codec.EndpointStat
+		for _, e := range st {
+			if e.Endpoint == "Errors.Call" {
+				call = e
+			}
+		}
+		if call == nil || call.Marshaled != 1 || call.Unmarshaled != 1 || call.MarshalSizes.Sum == 0 {
+			t.Fatalf("expected the call recorded got %+v", st)
+		}
+	}
+}
+
+type Tagger struct{}
+
+func (t *Tagger) Call(ctx context.Context, req *handler.HealthRequest, rsp *handler.HealthResponse) error {
+	rsp.Status, _ = metadata.Get(ctx, "Tag")
+	return nil
+}
+
+func TestServiceHandlerMiddleware(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var tagged, untagged string
+
+	tag := func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			return fn(metadata.Set(ctx, "Tag", req.Endpoint()), req, rsp)
+		}
+	}
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			rsp := new(handler.HealthResponse)
+			if err := s.Client().Call(context.Background(), s.Client().NewRequest("test.service", "Tagger.Call", &handler.HealthRequest{}), rsp); err != nil {
+				return err
+			}
+			tagged = rsp.Status
+			rsp = new(handler.HealthResponse)
+			if err := s.Client().Call(context.Background(), s.Client().NewRequest("test.service", "Untagged.Call", &handler.HealthRequest{}), rsp); err != nil {
+				return err
+			}
+			untagged = rsp.Status
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Tagger), server.WithMiddleware(tag))); err != nil {
+		t.Fatal(err)
+	}
+
+	type Untagged struct{ Tagger }
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Untagged))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tagged != "Tagger.Call" {
+		t.Fatalf("expected the handler middleware to run got %q", tagged)
+	}
+	if len(untagged) > 0 {
+		t.Fatalf("expected the middleware to only apply to its handler got %q", untagged)
+	}
+}
+
+func TestServiceUnixAddress(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var address string
+	var cerr error
+
+	srv := NewService(
+		service.Server(smucp.NewServer(server.Registry(reg), server.Transport(tr), server.Address("unix:///var/run/test.sock"))),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+			if svcs, err := reg.GetService("test.service"); err == nil && len(svcs) > 0 && len(svcs[0].Nodes) > 0 {
+				address = svcs[0].Nodes[0].Address
+			}
+			req := s.Client().NewRequest("test.service", "Tagger.Call", &handler.HealthRequest{})
+			cerr = s.Client().Call(context.Background(), req, new(handler.HealthResponse))
+			return nil
+		}),
+	)
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Tagger))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the registry entry carries the scheme so the client dials the socket
+	if address != "unix:///var/run/test.sock" {
+		t.Fatalf("expected the unix address to be registered got %s", address)
+	}
+	if cerr != nil {
+		t.Fatal(cerr)
+	}
+}
+
+func TestServiceHealthRegistration(t *testing.T) {
+	reg := memory.NewRegistry()
+	tr := tmem.NewTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var failing int32
+	var errs []error
+
+	status := func() string {
+		svcs, err := reg.GetService("test.service")
+		if err != nil || len(svcs) == 0 || len(svcs[0].Nodes) == 0 {
+			return ""
+		}
+		return svcs[0].Nodes[0].Metadata["health"]
+	}
+
+	// waits for the registered status and the result of calling the service
+	wait := func(s service.Service, expect string, callErr bool) error {
+		var err error
+		for i := 0; i < 100; i++ {
+			req := s.Client().NewRequest("test.service", "Sleeper.Call", &handler.HealthRequest{})
+			err = s.Client().Call(context.Background(), req, new(handler.HealthResponse), client.WithRetries(0))
+			if status() == expect && (err != nil) == callErr {
+				return nil
+			}
+			time.Sleep(time.Millisecond * 20)
+		}
+		return fmt.Errorf("expected %s with call error %v got %s: %v", expect, callErr, status(), err)
+	}
+
+	srv := NewService(
+		service.Server(smucp.NewServer(
+			server.Registry(reg),
+			server.Transport(tr),
+			server.HealthInterval(time.Millisecond*10),
+		)),
+		service.Client(cmucp.NewClient(client.Registry(reg), client.Transport(tr), client.ContentType("application/json"))),
+		service.Name("test.service"),
+		service.Context(ctx),
+		service.AfterStartCtx(func(ctx context.Context, s service.Service) error {
+			defer cancel()
+
+			errs = append(errs, wait(s, "up", false))
+
+			// the node is excluded from selection while it's not ready
+			atomic.StoreInt32(&failing, 1)
+			errs = append(errs, wait(s, "down", true))
+
+			atomic.StoreInt32(&failing, 0)
+			errs = append(errs, wait(s, "up", false))
+
+			return nil
+		}),
+	)
+
+	srv.Health().Register("db", func(context.Context) error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(new(Sleeper))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package mucp
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+
+	"github.com/asim/go-micro/v3/debug/handler"
+	"github.com/asim/go-micro/v3/logger"
+	smucp "github.com/asim/go-micro/v3/server/mucp"
+)
+
+// report returns the effective configuration of the service
+func (s *mucpService) report() *handler.Report {
+	opts := s.opts.Server.Options()
+
+	rep := &handler.Report{
+		Name:      opts.Name,
+		Version:   opts.Version,
+		Id:        opts.Id,
+		Type:      s.opts.Type,
+		Address:   opts.Address,
+		Namespace: opts.Namespace,
+		Metadata:  opts.Metadata,
+	}
+
+	if opts.Transport != nil {
+		rep.Transport = opts.Transport.String()
+	}
+	if opts.Registry != nil {
+		rep.Registry = opts.Registry.String()
+	}
+	if opts.Broker != nil {
+		rep.Broker = opts.Broker.String()
+	}
+	if opts.Auth != nil {
+		rep.Auth = opts.Auth.String()
+	}
+
+	codecs := make(map[string]bool)
+	for ct := range smucp.DefaultCodecs {
+		codecs[ct] = true
+	}
+	for ct := range opts.Codecs {
+		codecs[ct] = true
+	}
+	for ct := range codecs {
+		rep.Codecs = append(rep.Codecs, ct)
+	}
+	sort.Strings(rep.Codecs)
+
+	for _, w := range opts.HdlrWrappers {
+		rep.Wrappers = append(rep.Wrappers, funcName(w))
+	}
+
+	return rep
+}
+
+// funcName returns the name of the func e.g a wrapper
+func funcName(fn interface{}) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	return f.Name()
+}
+
+// logReport logs the startup report as a single entry
+func (s *mucpService) logReport() {
+	if !logger.V(logger.InfoLevel, logger.DefaultLogger) {
+		return
+	}
+
+	rep := s.report()
+
+	logger.Fields(map[string]interface{}{
+		"name":      rep.Name,
+		"version":   rep.Version,
+		"id":        rep.Id,
+		"type":      rep.Type,
+		"address":   rep.Address,
+		"transport": rep.Transport,
+		"registry":  rep.Registry,
+		"broker":    rep.Broker,
+		"auth":      rep.Auth,
+		"namespace": rep.Namespace,
+		"codecs":    rep.Codecs,
+		"wrappers":  rep.Wrappers,
+		"metadata":  rep.Metadata,
+	}).Log(logger.InfoLevel, "Service started")
+}
@@ -0,0 +1,153 @@
+package mucp
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/asim/go-micro/v3/server"
+)
+
+// servers manages the primary server along with any additional servers.
+// Handlers are registered with all of them, subscribers only with the
+// primary so messages are not processed more than once.
+type servers struct {
+	primary server.Server
+	extra   []server.Server
+}
+
+// share copies the primary server options required for the
+// additional servers to appear as the same service
+func share(p server.Options, i int) server.Option {
+	return func(o *server.Options) {
+		o.Name = p.Name
+		o.Version = p.Version
+		o.Namespace = p.Namespace
+		o.Metadata = metadata.Copy(p.Metadata)
+		o.Registry = p.Registry
+		o.Broker = p.Broker
+		o.Auth = p.Auth
+		o.Tracer = p.Tracer
+		o.RegisterTTL = p.RegisterTTL
+		o.RegisterInterval = p.RegisterInterval
+		o.HdlrWrappers = append([]server.HandlerWrapper{}, p.HdlrWrappers...)
+		o.Middleware = append([]server.Middleware{}, p.Middleware...)
+		o.Limiter = p.Limiter
+		o.Timeouts = p.Timeouts
+		o.SubWrappers = append([]server.SubscriberWrapper{}, p.SubWrappers...)
+
+		// each server registers as its own node
+		if len(o.Id) == 0 || o.Id == p.Id {
+			o.Id = fmt.Sprintf("%s-%d", p.Id, i+1)
+		}
+	}
+}
+
+func (s *servers) Init(opts ...server.Option) error {
+	if err := s.primary.Init(opts...); err != nil {
+		return err
+	}
+	for _, srv := range s.extra {
+		if err := srv.Init(opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *servers) Options() server.Options {
+	return s.primary.Options()
+}
+
+func (s *servers) Handle(h server.Handler) error {
+	if err := s.primary.Handle(h); err != nil {
+		return err
+	}
+
+	// recreate the handler for each server
+	opts := []server.HandlerOption{
+		server.InternalHandler(h.Options().Internal),
+	}
+	for name, md := range h.Options().Metadata {
+		opts = append(opts, server.EndpointMetadata(name, md))
+	}
+
+	for _, srv := range s.extra {
+		if err := srv.Handle(srv.NewHandler(h.Handler(), opts...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *servers) NewHandler(h interface{}, opts ...server.HandlerOption) server.Handler {
+	return s.primary.NewHandler(h, opts...)
+}
+
+func (s *servers) NewSubscriber(topic string, h interface{}, opts ...server.SubscriberOption) server.Subscriber {
+	return s.primary.NewSubscriber(topic, h, opts...)
+}
+
+func (s *servers) Subscribe(sub server.Subscriber) error {
+	return s.primary.Subscribe(sub)
+}
+
+func (s *servers) Start() error {
+	popts := s.primary.Options()
+
+	for i, srv := range s.extra {
+		if err := srv.Init(share(popts, i)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.primary.Start(); err != nil {
+		return err
+	}
+
+	for i, srv := range s.extra {
+		if err := srv.Start(); err != nil {
+			// stop what we started
+			for j := i - 1; j >= 0; j-- {
+				s.extra[j].Stop()
+			}
+			s.primary.Stop()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *servers) Stop() error {
+	var gerr error
+
+	for _, srv := range s.extra {
+		if err := srv.Stop(); err != nil {
+			gerr = err
+		}
+	}
+
+	if err := s.primary.Stop(); err != nil {
+		return err
+	}
+
+	return gerr
+}
+
+func (s *servers) String() string {
+	return s.primary.String()
+}
+
+// tlsConfig returns the tls config the server is secured with
+func tlsConfig(s server.Server) *tls.Config {
+	opts := s.Options()
+	if opts.TLSConfig != nil {
+		return opts.TLSConfig
+	}
+	if opts.Transport != nil {
+		return opts.Transport.Options().TLSConfig
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package mucp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/asim/go-micro/v3/auth"
+	"github.com/asim/go-micro/v3/health"
+	mhealth "github.com/asim/go-micro/v3/health/memory"
+)
+
+// report is the result of validating the service
+type report struct {
+	Name   string           `json:"name"`
+	Status health.Status    `json:"status"`
+	Checks []*health.Result `json:"checks"`
+}
+
+// validate connects to the registry, broker, transport and auth and runs
+// the readiness checks without starting the server. The report is
+// printed to stdout and an error returned if any check failed.
+func (s *mucpService) validate() error {
+	opts := s.opts.Server.Options()
+	checks := mhealth.NewHealth()
+
+	checks.Register("registry", func(context.Context) error {
+		_, err := s.opts.Registry.ListServices()
+		return err
+	})
+
+	checks.Register("broker", func(context.Context) error {
+		if err := s.opts.Broker.Connect(); err != nil {
+			return err
+		}
+		return s.opts.Broker.Disconnect()
+	})
+
+	checks.Register("transport", func(context.Context) error {
+		l, err := opts.Transport.Listen(opts.Address)
+		if err != nil {
+			return err
+		}
+		return l.Close()
+	})
+
+	if opts.Auth != nil {
+		checks.Register("auth", func(context.Context) error {
+			aopts := opts.Auth.Options()
+			if len(aopts.ID) == 0 {
+				return nil
+			}
+			_, err := opts.Auth.Token(auth.WithCredentials(aopts.ID, aopts.Secret))
+			return err
+		})
+	}
+
+	ctx := s.opts.Context
+
+	results, err := checks.Ready(ctx)
+
+	// include the service readiness checks
+	ready, rerr := s.opts.Health.Ready(ctx)
+	results = append(results, ready...)
+	if rerr != nil {
+		err = rerr
+	}
+
+	rep := &report{
+		Name:   s.Name(),
+		Status: health.StatusUp,
+		Checks: results,
+	}
+	if err != nil {
+		rep.Status = health.StatusDown
+	}
+
+	b, jerr := json.MarshalIndent(rep, "", "  ")
+	if jerr != nil {
+		return jerr
+	}
+	fmt.Fprintln(os.Stdout, string(b))
+
+	if err != nil {
+		return fmt.Errorf("service %s failed validation: %v", s.Name(), err)
+	}
+
+	return nil
+}
@@ -2,16 +2,21 @@ package service
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/asim/go-micro/v3/broker"
 	mbroker "github.com/asim/go-micro/v3/broker/memory"
 	"github.com/asim/go-micro/v3/client"
 	mucpClient "github.com/asim/go-micro/v3/client/mucp"
+	"github.com/asim/go-micro/v3/flags"
+	"github.com/asim/go-micro/v3/health"
+	mhealth "github.com/asim/go-micro/v3/health/memory"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/registry/memory"
 	"github.com/asim/go-micro/v3/server"
 	mucpServer "github.com/asim/go-micro/v3/server/mucp"
+	"github.com/asim/go-micro/v3/util/wrapper"
 )
 
 type Options struct {
@@ -19,6 +24,55 @@ type Options struct {
 	Client   client.Client
 	Server   server.Server
 	Registry registry.Registry
+	// Servers are additional servers managed alongside
+	// the Server e.g to serve the handlers over http
+	Servers []server.Server
+	Health  health.Health
+
+	// Metadata is advertised on the registry node, added to
+	// the outbound requests and returned by the debug handler
+	Metadata map[string]string
+
+	// Type of service e.g api, web or service. It's
+	// advertised as the "type" node metadata.
+	Type string
+
+	// HealthAddress is the address to serve the
+	// http health checks on. Blank disables it.
+	HealthAddress string
+
+	// Dependencies are the services which must be
+	// found in the registry before the service starts
+	Dependencies []string
+	// DependencyTimeout is how long to wait for them
+	DependencyTimeout time.Duration
+
+	// Signal traps the shutdown signals in Run
+	Signal bool
+	// Signals are handlers called when a signal is received
+	Signals map[os.Signal]func(Service)
+
+	// Validate makes Run validate the configuration and
+	// return without starting the server
+	Validate bool
+
+	// ExitOnError terminates Run when an asynchronous
+	// error such as a registry heartbeat failure occurs
+	ExitOnError bool
+
+	// PanicHandler is called when a handler panics.
+	// Defaults to logging the panic and stack trace.
+	PanicHandler wrapper.PanicHandler
+
+	// DebugHandler is registered as an internal handler
+	// on Run. Defaults to the debug handler.
+	DebugHandler interface{}
+	// DisableDebug skips registering the DebugHandler
+	DisableDebug bool
+
+	// Flags are the feature flags reported by the debug handler
+	// and checked by the handlers with flags.Enabled
+	Flags flags.Flags
 
 	// Before and After funcs
 	BeforeStart []func() error
@@ -26,6 +80,12 @@ type Options struct {
 	AfterStart  []func() error
 	AfterStop   []func() error
 
+	// Context aware before and after funcs
+	BeforeStartCtx []HookFunc
+	BeforeStopCtx  []HookFunc
+	AfterStartCtx  []HookFunc
+	AfterStopCtx   []HookFunc
+
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -33,13 +93,24 @@ type Options struct {
 
 type Option func(*Options)
 
+// HookFunc is a lifecycle hook which is passed a context and the service
+// it is running for. The context passed to the stop hooks is bounded by
+// DefaultStopTimeout so cleanup can respect the shutdown deadline.
+type HookFunc func(ctx context.Context, s Service) error
+
 func NewOptions(opts ...Option) Options {
 	opt := Options{
 		Broker:   mbroker.NewBroker(),
 		Client:   mucpClient.NewClient(),
 		Server:   mucpServer.NewServer(),
 		Registry: memory.NewRegistry(),
+		Health:   mhealth.NewHealth(),
+		Type:     DefaultType,
 		Context:  context.Background(),
+		Signal:   true,
+		Signals:  make(map[os.Signal]func(Service)),
+
+		DependencyTimeout: DefaultDependencyTimeout,
 	}
 
 	for _, o := range opts {
@@ -80,6 +151,16 @@ func Server(s server.Server) Option {
 	}
 }
 
+// AddServer adds an additional server which is started and stopped with
+// the service. It shares the name, version, metadata, registry, broker,
+// auth and wrappers of the primary Server and serves the same handlers.
+// Subscribers are only registered with the primary Server.
+func AddServer(s server.Server) Option {
+	return func(o *Options) {
+		o.Servers = append(o.Servers, s)
+	}
+}
+
 // Registry sets the registry for the service
 // and the underlying components
 func Registry(r registry.Registry) Option {
@@ -94,8 +175,107 @@ func Registry(r registry.Registry) Option {
 	}
 }
 
+// DependsOn blocks Run until the named services are
+// found in the registry or the DependencyTimeout expires
+func DependsOn(services ...string) Option {
+	return func(o *Options) {
+		o.Dependencies = append(o.Dependencies, services...)
+	}
+}
+
+// DependencyTimeout sets how long to wait for the dependencies
+func DependencyTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.DependencyTimeout = t
+	}
+}
+
+// Health sets the health checks for the service
+func Health(h health.Health) Option {
+	return func(o *Options) {
+		o.Health = h
+	}
+}
+
+// HealthAddress serves the liveness and readiness checks
+// over http on the given address e.g. for kubernetes probes
+func HealthAddress(addr string) Option {
+	return func(o *Options) {
+		o.HealthAddress = addr
+	}
+}
+
+// Signal toggles trapping the TERM, INT and QUIT signals in Run
+// which stop the service. Defaults to true.
+func Signal(b bool) Option {
+	return func(o *Options) {
+		o.Signal = b
+	}
+}
+
+// HandleSignal calls fn when the signal is received e.g SIGHUP to reload
+// config. Handlers for the shutdown signals are called before stopping.
+func HandleSignal(sig os.Signal, fn func(Service)) Option {
+	return func(o *Options) {
+		o.Signals[sig] = fn
+	}
+}
+
+// Validate makes Run connect to the registry, broker, transport and auth
+// and run the readiness checks, printing a report without starting the
+// server. Run returns an error if validation fails.
+func Validate() Option {
+	return func(o *Options) {
+		o.Validate = true
+	}
+}
+
+// ExitOnError terminates Run with the error if the health server or
+// registry heartbeat fails after start. By default errors are logged.
+func ExitOnError(b bool) Option {
+	return func(o *Options) {
+		o.ExitOnError = b
+	}
+}
+
+// PanicHandler sets the func called with the value recovered
+// from a panicking handler e.g to report it
+func PanicHandler(fn wrapper.PanicHandler) Option {
+	return func(o *Options) {
+		o.PanicHandler = fn
+	}
+}
+
+// DebugHandler replaces the built-in debug handler registered on Run
+func DebugHandler(h interface{}) Option {
+	return func(o *Options) {
+		o.DebugHandler = h
+	}
+}
+
+// DisableDebug disables the debug handler so stats, trace
+// and logs are not exposed by the service
+func DisableDebug() Option {
+	return func(o *Options) {
+		o.DisableDebug = true
+	}
+}
+
 // Convenience options
 
+// Flags sets the feature flags of the service. They're reported by the
+// debug handler and passed to the handlers and subscribers in the context
+// so flags.Enabled checks them.
+func Flags(f flags.Flags) Option {
+	return func(o *Options) {
+		o.Flags = f
+		o.Server.Init(
+			server.UseNamed(flags.Middleware, flags.NewHandlerWrapper(f)),
+			server.WrapSubscriber(flags.NewSubscriberWrapper(f)),
+		)
+	}
+}
+
 // Address sets the address of the server
 func Address(addr string) Option {
 	return func(o *Options) {
@@ -110,6 +290,14 @@ func Name(n string) Option {
 	}
 }
 
+// Type of the service e.g api, web or service. This is explicit
+// rather than inferred from the name of the service.
+func Type(t string) Option {
+	return func(o *Options) {
+		o.Type = t
+	}
+}
+
 // Version of the service
 func Version(v string) Option {
 	return func(o *Options) {
@@ -117,9 +305,11 @@ func Version(v string) Option {
 	}
 }
 
-// Metadata associated with the service
+// Metadata associated with the service. It's advertised on the
+// registry node and sent as metadata on outbound requests.
 func Metadata(md map[string]string) Option {
 	return func(o *Options) {
+		o.Metadata = md
 		o.Server.Init(server.Metadata(md))
 	}
 }
@@ -138,6 +328,23 @@ func RegisterInterval(t time.Duration) Option {
 	}
 }
 
+// DrainTimeout gracefully drains the server on Stop. The service is
+// deregistered, then in-flight requests are waited for up to the timeout
+// before the listener is closed.
+func DrainTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Server.Init(server.DrainTimeout(d))
+	}
+}
+
+// DrainGrace keeps the server serving for the grace period after it's
+// deregistered on Stop, within the DrainTimeout
+func DrainGrace(d time.Duration) Option {
+	return func(o *Options) {
+		o.Server.Init(server.DrainGrace(d))
+	}
+}
+
 // WrapClient is a convenience method for wrapping a Client with
 // some middleware component. A list of wrappers can be provided.
 // Wrappers are applied in reverse order so the last is executed first.
@@ -210,3 +417,33 @@ func AfterStop(fn func() error) Option {
 		o.AfterStop = append(o.AfterStop, fn)
 	}
 }
+
+// Context aware Before and Afters
+
+// BeforeStartCtx runs the hook with the service context before the server is started
+func BeforeStartCtx(fn HookFunc) Option {
+	return func(o *Options) {
+		o.BeforeStartCtx = append(o.BeforeStartCtx, fn)
+	}
+}
+
+// BeforeStopCtx runs the hook with a bounded context before the server is stopped
+func BeforeStopCtx(fn HookFunc) Option {
+	return func(o *Options) {
+		o.BeforeStopCtx = append(o.BeforeStopCtx, fn)
+	}
+}
+
+// AfterStartCtx runs the hook with the service context after the server is started
+func AfterStartCtx(fn HookFunc) Option {
+	return func(o *Options) {
+		o.AfterStartCtx = append(o.AfterStartCtx, fn)
+	}
+}
+
+// AfterStopCtx runs the hook with a bounded context after the server is stopped
+func AfterStopCtx(fn HookFunc) Option {
+	return func(o *Options) {
+		o.AfterStopCtx = append(o.AfterStopCtx, fn)
+	}
+}
@@ -2,7 +2,10 @@
 package service
 
 import (
+	"time"
+
 	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/health"
 	"github.com/asim/go-micro/v3/server"
 )
 
@@ -18,8 +21,36 @@ type Service interface {
 	Client() client.Client
 	// Server is for handling requests and events
 	Server() server.Server
+	// Health is used to register health checks
+	Health() health.Health
+	// Set stores a shared value e.g a database pool on the service
+	Set(key string, val interface{})
+	// Get returns a value stored with Set
+	Get(key string) (interface{}, bool)
 	// Run the service
 	Run() error
+	// Restart stops the server, applies any options and starts it again
+	Restart(...Option) error
 	// The service implementation
 	String() string
 }
+
+const (
+	// TypeService is a standard rpc service
+	TypeService = "service"
+	// TypeAPI is a service which serves as an api gateway or backend
+	TypeAPI = "api"
+	// TypeWeb is a service which serves web content
+	TypeWeb = "web"
+	// TypeEvent is a service which consumes events
+	TypeEvent = "event"
+)
+
+var (
+	// DefaultType is the type of service if none is specified
+	DefaultType = TypeService
+	// DefaultStopTimeout bounds the context passed to the stop hooks
+	DefaultStopTimeout = time.Second * 10
+	// DefaultDependencyTimeout is how long to wait for the dependencies
+	DefaultDependencyTimeout = time.Minute
+)
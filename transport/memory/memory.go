@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/asim/go-micro/v3/codec"
 	"github.com/asim/go-micro/v3/transport"
 	maddr "github.com/asim/go-micro/v3/util/addr"
 	mnet "github.com/asim/go-micro/v3/util/net"
@@ -29,6 +30,8 @@ type memorySocket struct {
 	// for send/recv transport.Timeout
 	timeout time.Duration
 	ctx     context.Context
+	// the max size of the messages received
+	maxSize int
 	sync.RWMutex
 }
 
@@ -45,6 +48,8 @@ type memoryListener struct {
 	topts transport.Options
 	sync.RWMutex
 	ctx context.Context
+	// the transport the listener belongs to
+	tr *memoryTransport
 }
 
 type memoryTransport struct {
@@ -72,11 +77,25 @@ func (ms *memorySocket) Recv(m *transport.Message) error {
 	case <-ms.lexit:
 		return errors.New("server connection closed")
 	case cm := <-ms.recv:
+		if ms.maxSize > 0 {
+			if n := size(cm); n > ms.maxSize {
+				return &codec.MessageSizeError{Size: n, Limit: ms.maxSize}
+			}
+		}
 		*m = *cm
 	}
 	return nil
 }
 
+// size returns the size of the body and headers of the message
+func size(m *transport.Message) int {
+	n := len(m.Body)
+	for k, v := range m.Header {
+		n += len(k) + len(v)
+	}
+	return n
+}
+
 func (ms *memorySocket) Local() string {
 	return ms.local
 }
@@ -133,6 +152,14 @@ func (m *memoryListener) Close() error {
 	default:
 		close(m.exit)
 	}
+
+	// free the address for reuse
+	m.tr.Lock()
+	if m.tr.listeners[m.addr] == m {
+		delete(m.tr.listeners, m.addr)
+	}
+	m.tr.Unlock()
+
 	return nil
 }
 
@@ -151,6 +178,7 @@ func (m *memoryListener) Accept(fn func(transport.Socket)) error {
 				remote:  c.Local(),
 				timeout: m.topts.Timeout,
 				ctx:     m.topts.Context,
+				maxSize: m.lopts.MaxMsgSize,
 			})
 		}
 	}
@@ -203,24 +231,35 @@ func (m *memoryTransport) Listen(addr string, opts ...transport.ListenOption) (t
 		o(&options)
 	}
 
-	host, port, err := net.SplitHostPort(addr)
-	if err != nil {
-		return nil, err
-	}
+	// unix socket addresses are used as is
+	if !mnet.IsUnix(addr) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
 
-	addr, err = maddr.Extract(host)
-	if err != nil {
-		return nil, err
-	}
+		// explicit hosts of the other family can't be bound
+		if ip := net.ParseIP(host); ip != nil {
+			v4 := ip.To4() != nil
+			if (options.Network == mnet.IPv4Only && !v4) || (options.Network == mnet.IPv6Only && v4) {
+				return nil, fmt.Errorf("can't listen on %s with %s", addr, options.Network)
+			}
+		}
 
-	// if zero port then randomly assign one
-	if len(port) > 0 && port == "0" {
-		i := rand.Intn(20000)
-		port = fmt.Sprintf("%d", 10000+i)
-	}
+		addr, err = maddr.Extract(host)
+		if err != nil {
+			return nil, err
+		}
+
+		// if zero port then randomly assign one
+		if len(port) > 0 && port == "0" {
+			i := rand.Intn(20000)
+			port = fmt.Sprintf("%d", 10000+i)
+		}
 
-	// set addr with port
-	addr = mnet.HostPort(addr, port)
+		// set addr with port
+		addr = mnet.HostPort(addr, port)
+	}
 
 	if _, ok := m.listeners[addr]; ok {
 		return nil, errors.New("already listening on " + addr)
@@ -233,6 +272,7 @@ func (m *memoryTransport) Listen(addr string, opts ...transport.ListenOption) (t
 		conn:  make(chan *memorySocket),
 		exit:  make(chan bool),
 		ctx:   m.opts.Context,
+		tr:    m,
 	}
 
 	m.listeners[addr] = listener
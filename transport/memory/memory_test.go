@@ -92,3 +92,49 @@ func TestListener(t *testing.T) {
 		t.Fatal("Expected error binding to :8080 got nil")
 	}
 }
+
+func TestMemoryTransportNetwork(t *testing.T) {
+	tr := NewTransport()
+
+	if _, err := tr.Listen("[::1]:0", transport.IPv4Only()); err == nil {
+		t.Fatal("Expected an ipv6 address not to be bound on ipv4")
+	}
+	if _, err := tr.Listen("127.0.0.1:0", transport.IPv6Only()); err == nil {
+		t.Fatal("Expected an ipv4 address not to be bound on ipv6")
+	}
+
+	l, err := tr.Listen("127.0.0.1:0", transport.IPv4Only())
+	if err != nil {
+		t.Fatalf("Unexpected error listening %v", err)
+	}
+	l.Close()
+}
+
+func TestMemoryTransportMaxMsgSize(t *testing.T) {
+	tr := NewTransport()
+
+	l, err := tr.Listen("127.0.0.1:0", transport.MaxMsgSize(16))
+	if err != nil {
+		t.Fatalf("Unexpected error listening %v", err)
+	}
+	defer l.Close()
+
+	errs := make(chan error, 1)
+	go l.Accept(func(sock transport.Socket) {
+		var m transport.Message
+		errs <- sock.Recv(&m)
+	})
+
+	c, err := tr.Dial(l.Addr())
+	if err != nil {
+		t.Fatalf("Unexpected error dialing %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Send(&transport.Message{Body: make([]byte, 32)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err == nil {
+		t.Fatal("Expected the message to be rejected")
+	}
+}
@@ -0,0 +1,181 @@
+package transport
+
+import (
+	"crypto/tls"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PeerStat are the connection counters of a peer
+type PeerStat struct {
+	// Peer is the address dialled or the host of the connections accepted
+	Peer string `json:"peer"`
+	// Opened connections
+	Opened uint64 `json:"opened"`
+	// Closed connections
+	Closed uint64 `json:"closed"`
+	// BytesSent on the wire including the tls records
+	BytesSent uint64 `json:"bytes_sent"`
+	// BytesReceived on the wire including the tls records
+	BytesReceived uint64 `json:"bytes_received"`
+	// Handshakes completed
+	Handshakes uint64 `json:"handshakes"`
+	// HandshakeLatency is the total latency of the handshakes
+	HandshakeLatency time.Duration `json:"handshake_latency"`
+	// TLSVersion of the last handshake e.g 1.3, empty if it's insecure
+	TLSVersion string `json:"tls_version"`
+}
+
+// Metrics records the connection counters per peer. Set it with
+// WithMetrics for the transport to record its connections.
+type Metrics struct {
+	sync.Mutex
+	stats map[string]*PeerStat
+}
+
+type metricsListener struct {
+	net.Listener
+	m *Metrics
+}
+
+type metricsConn struct {
+	net.Conn
+	m *Metrics
+
+	once   sync.Once
+	closed sync.Once
+	peer   string
+	stat   *PeerStat
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "1.0",
+	tls.VersionTLS11: "1.1",
+	tls.VersionTLS12: "1.2",
+	tls.VersionTLS13: "1.3",
+}
+
+// NewMetrics returns an empty metrics recorder
+func NewMetrics() *Metrics {
+	return &Metrics{
+		stats: make(map[string]*PeerStat),
+	}
+}
+
+// PeerHost returns the host of the address, the peer of the connections
+// accepted as their ports churn
+func PeerHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// stat returns the stat of the peer, the lock must be held
+func (m *Metrics) stat(peer string) *PeerStat {
+	stat, ok := m.stats[peer]
+	if !ok {
+		stat = &PeerStat{Peer: peer}
+		m.stats[peer] = stat
+	}
+	return stat
+}
+
+// Listener returns a listener recording the connections accepted
+func (m *Metrics) Listener(l net.Listener) net.Listener {
+	return &metricsListener{Listener: l, m: m}
+}
+
+// Conn returns a connection recording its counters for the peer, the
+// host of its remote address if empty
+func (m *Metrics) Conn(c net.Conn, peer string) net.Conn {
+	return &metricsConn{Conn: c, m: m, peer: peer}
+}
+
+// Handshake records a handshake of the peer which took the duration
+func (m *Metrics) Handshake(peer string, d time.Duration, state tls.ConnectionState) {
+	m.Lock()
+	defer m.Unlock()
+
+	stat := m.stat(peer)
+	stat.Handshakes++
+	stat.HandshakeLatency += d
+	if state.HandshakeComplete {
+		stat.TLSVersion = tlsVersions[state.Version]
+	}
+}
+
+// Stats returns the stats of the peers sorted by peer
+func (m *Metrics) Stats() []*PeerStat {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := make([]*PeerStat, 0, len(m.stats))
+	for _, stat := range m.stats {
+		st := *stat
+		stats = append(stats, &st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Peer < stats[j].Peer
+	})
+
+	return stats
+}
+
+func (l *metricsListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.m.Conn(c, ""), nil
+}
+
+// open records the conn as opened on first use, the remote address may
+// only be known once it's read e.g with the proxy protocol
+func (c *metricsConn) open() {
+	c.once.Do(func() {
+		if len(c.peer) == 0 {
+			c.peer = PeerHost(c.Conn.RemoteAddr().String())
+		}
+
+		c.m.Lock()
+		c.stat = c.m.stat(c.peer)
+		c.stat.Opened++
+		c.m.Unlock()
+	})
+}
+
+func (c *metricsConn) Read(b []byte) (int, error) {
+	c.open()
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.m.Lock()
+		c.stat.BytesReceived += uint64(n)
+		c.m.Unlock()
+	}
+	return n, err
+}
+
+func (c *metricsConn) Write(b []byte) (int, error) {
+	c.open()
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.m.Lock()
+		c.stat.BytesSent += uint64(n)
+		c.m.Unlock()
+	}
+	return n, err
+}
+
+func (c *metricsConn) Close() error {
+	c.open()
+	c.closed.Do(func() {
+		c.m.Lock()
+		c.stat.Closed++
+		c.m.Unlock()
+	})
+	return c.Conn.Close()
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/asim/go-micro/v3/codec"
+	mnet "github.com/asim/go-micro/v3/util/net"
+	mtls "github.com/asim/go-micro/v3/util/tls"
 )
 
 type Options struct {
@@ -23,6 +25,8 @@ type Options struct {
 	TLSConfig *tls.Config
 	// Timeout sets the timeout for Send/Recv
 	Timeout time.Duration
+	// Metrics of the connections, set with WithMetrics
+	Metrics *Metrics
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -34,6 +38,8 @@ type DialOptions struct {
 	Stream bool
 	// Timeout for dialing
 	Timeout time.Duration
+	// KeepAlive is the tcp keepalive period of the connection
+	KeepAlive time.Duration
 
 	// TODO: add tls options when dialling
 	// Currently set in global options
@@ -47,6 +53,24 @@ type ListenOptions struct {
 	// TODO: add tls options when listening
 	// Currently set in global options
 
+	// ReusePort sets SO_REUSEPORT so multiple processes
+	// can listen on the same port
+	ReusePort bool
+	// Network to listen on, one of tcp for dual-stack,
+	// tcp4 for ipv4 only or tcp6 for ipv6 only
+	Network string
+	// KeepAlive is the tcp keepalive period of the connections accepted
+	KeepAlive time.Duration
+	// IdleTimeout closes the connections idle for longer
+	IdleTimeout time.Duration
+	// MaxConnAge closes the connections older than the age
+	MaxConnAge time.Duration
+	// ProxyProtocol reads the PROXY protocol header of the connections
+	ProxyProtocol bool
+	// MaxMsgSize is the max size in bytes of a message read from the
+	// connections accepted, encoding and headers included
+	MaxMsgSize int
+
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -89,6 +113,22 @@ func TLSConfig(t *tls.Config) Option {
 	}
 }
 
+// TLSReload secures the transport with the certificate of the reloader,
+// which is stopped by the caller once the transport is closed
+func TLSReload(r *mtls.Reloader) Option {
+	return func(o *Options) {
+		o.Secure = true
+		o.TLSConfig = r.Config()
+	}
+}
+
+// WithMetrics records the counters of every connection in the metrics
+func WithMetrics(m *Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+	}
+}
+
 // Indicates whether this is a streaming connection
 func WithStream() DialOption {
 	return func(o *DialOptions) {
@@ -102,3 +142,89 @@ func WithTimeout(d time.Duration) DialOption {
 		o.Timeout = d
 	}
 }
+
+// WithContext sets the context of the dial, waiting for a connection is
+// abandoned once it's done
+func WithContext(ctx context.Context) DialOption {
+	return func(o *DialOptions) {
+		o.Context = ctx
+	}
+}
+
+// WithKeepAlive sets the tcp keepalive period of the connection so it's not
+// dropped by NATs and load balancers, negative disables keepalives
+func WithKeepAlive(d time.Duration) DialOption {
+	return func(o *DialOptions) {
+		o.KeepAlive = d
+	}
+}
+
+// ReusePort lets multiple processes listen on the same port
+func ReusePort() ListenOption {
+	return func(o *ListenOptions) {
+		o.ReusePort = true
+	}
+}
+
+// DualStack accepts both ipv4 and ipv6 connections
+func DualStack() ListenOption {
+	return func(o *ListenOptions) {
+		o.Network = mnet.DualStack
+	}
+}
+
+// IPv4Only accepts only ipv4 connections
+func IPv4Only() ListenOption {
+	return func(o *ListenOptions) {
+		o.Network = mnet.IPv4Only
+	}
+}
+
+// IPv6Only accepts only ipv6 connections
+func IPv6Only() ListenOption {
+	return func(o *ListenOptions) {
+		o.Network = mnet.IPv6Only
+	}
+}
+
+// KeepAlive sets the tcp keepalive period of the connections accepted so
+// they're not dropped by NATs and load balancers, negative disables them
+func KeepAlive(d time.Duration) ListenOption {
+	return func(o *ListenOptions) {
+		o.KeepAlive = d
+	}
+}
+
+// IdleTimeout closes the connections accepted which have been idle for
+// longer than the timeout
+func IdleTimeout(d time.Duration) ListenOption {
+	return func(o *ListenOptions) {
+		o.IdleTimeout = d
+	}
+}
+
+// MaxConnAge closes the connections accepted once they're older than the
+// age so the clients reconnect, spreading them over new servers
+func MaxConnAge(d time.Duration) ListenOption {
+	return func(o *ListenOptions) {
+		o.MaxConnAge = d
+	}
+}
+
+// MaxMsgSize limits the size of the messages read from the connections
+// accepted, encoding and headers included. Larger messages are rejected
+// while they're read, before they're buffered, and close the connection.
+func MaxMsgSize(n int) ListenOption {
+	return func(o *ListenOptions) {
+		o.MaxMsgSize = n
+	}
+}
+
+// ProxyProtocol reads the HAProxy PROXY protocol header sent by L4 load
+// balancers on the connections accepted, so the remote address of the
+// requests is the client's. Connections without the header are rejected.
+func ProxyProtocol() ListenOption {
+	return func(o *ListenOptions) {
+		o.ProxyProtocol = true
+	}
+}
@@ -0,0 +1,27 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+)
+
+// TLSSocket is implemented by the sockets secured with tls
+type TLSSocket interface {
+	Socket
+	// ConnectionState returns the state of the tls connection
+	ConnectionState() tls.ConnectionState
+}
+
+type peerKey struct{}
+
+// NewPeerContext returns a context with the tls state of the connection of
+// the peer, servers pass it to the handlers of the requests
+func NewPeerContext(ctx context.Context, state tls.ConnectionState) context.Context {
+	return context.WithValue(ctx, peerKey{}, state)
+}
+
+// PeerFromContext returns the tls state of the connection of the peer
+func PeerFromContext(ctx context.Context) (tls.ConnectionState, bool) {
+	state, ok := ctx.Value(peerKey{}).(tls.ConnectionState)
+	return state, ok
+}
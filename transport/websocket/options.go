@@ -0,0 +1,32 @@
+package websocket
+
+import (
+	"context"
+
+	"github.com/asim/go-micro/v3/transport"
+)
+
+type pathKey struct{}
+type originsKey struct{}
+
+func setTransportOption(k, v interface{}) transport.Option {
+	return func(o *transport.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// Path sets the http path the websockets are served on, DefaultPath by
+// default
+func Path(p string) transport.Option {
+	return setTransportOption(pathKey{}, p)
+}
+
+// Origins sets the origins e.g https://app.example.com browsers may connect
+// from, "*" allows any origin. Only the origin of the host is allowed by
+// default, requests without an origin aren't from browsers and are allowed.
+func Origins(origins ...string) transport.Option {
+	return setTransportOption(originsKey{}, origins)
+}
@@ -0,0 +1,353 @@
+// Package websocket is a transport tunnelling the messages over websockets,
+// each message is a binary frame encoded with the codec of the transport,
+// json by default. It reaches services through http proxies and lets
+// browsers connect, secured as wss with the tls options of the transport.
+package websocket
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/codec/json"
+	"github.com/asim/go-micro/v3/transport"
+	maddr "github.com/asim/go-micro/v3/util/addr"
+	mnet "github.com/asim/go-micro/v3/util/net"
+	mtls "github.com/asim/go-micro/v3/util/tls"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// DefaultPath the websockets are served on
+	DefaultPath = "/micro"
+)
+
+// acceptedKey is the time the connection of a request was accepted
+type acceptedKey struct{}
+
+type wsTransport struct {
+	opts transport.Options
+}
+
+type wsSocket struct {
+	conn    *websocket.Conn
+	opts    transport.Options
+	timeout time.Duration
+
+	// a read and a write may run concurrently
+	rmtx sync.Mutex
+	wmtx sync.Mutex
+}
+
+type wsClient struct {
+	*wsSocket
+	dopts transport.DialOptions
+}
+
+type wsListener struct {
+	listener net.Listener
+	opts     transport.Options
+	path     string
+	upgrader *websocket.Upgrader
+	srv      *http.Server
+	maxSize  int
+}
+
+func (s *wsSocket) Local() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *wsSocket) Remote() string {
+	return s.conn.RemoteAddr().String()
+}
+
+// ConnectionState returns the tls state of the wss connections
+func (s *wsSocket) ConnectionState() tls.ConnectionState {
+	if c, ok := s.conn.UnderlyingConn().(*tls.Conn); ok {
+		return c.ConnectionState()
+	}
+	return tls.ConnectionState{}
+}
+
+func (s *wsSocket) Recv(m *transport.Message) error {
+	if m == nil {
+		return errors.New("message passed in is nil")
+	}
+
+	s.rmtx.Lock()
+	defer s.rmtx.Unlock()
+
+	if s.timeout > 0 {
+		s.conn.SetReadDeadline(time.Now().Add(s.timeout))
+	}
+
+	_, b, err := s.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	return s.opts.Codec.Unmarshal(b, m)
+}
+
+func (s *wsSocket) Send(m *transport.Message) error {
+	b, err := s.opts.Codec.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	s.wmtx.Lock()
+	defer s.wmtx.Unlock()
+
+	if s.timeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	}
+
+	return s.conn.WriteMessage(websocket.BinaryMessage, b)
+}
+
+func (s *wsSocket) Close() error {
+	s.wmtx.Lock()
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	s.wmtx.Unlock()
+	return s.conn.Close()
+}
+
+func (l *wsListener) Addr() string {
+	// unix sockets are dialled with their scheme
+	if a := l.listener.Addr(); a.Network() == "unix" {
+		return mnet.UnixScheme + a.String()
+	}
+	return l.listener.Addr().String()
+}
+
+func (l *wsListener) Close() error {
+	// the listener isn't served until accepting
+	l.srv.Close()
+	l.listener.Close()
+	return nil
+}
+
+func (l *wsListener) Accept(fn func(transport.Socket)) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(l.path, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := l.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		sock := &wsSocket{conn: conn, opts: l.opts, timeout: l.opts.Timeout}
+		defer sock.conn.Close()
+
+		// the frames are rejected from their length before they're read
+		if l.maxSize > 0 {
+			conn.SetReadLimit(int64(l.maxSize))
+		}
+
+		if accepted, ok := r.Context().Value(acceptedKey{}).(time.Time); ok && l.opts.Metrics != nil {
+			l.opts.Metrics.Handshake(transport.PeerHost(r.RemoteAddr), time.Since(accepted), sock.ConnectionState())
+		}
+
+		fn(sock)
+	})
+	l.srv.Handler = mux
+
+	if err := l.srv.Serve(l.listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// path returns the http path of the websockets
+func (t *wsTransport) path() string {
+	if p, ok := t.opts.Context.Value(pathKey{}).(string); ok && len(p) > 0 {
+		return p
+	}
+	return DefaultPath
+}
+
+// checkOrigin returns whether a browser may connect from the origin of the
+// request
+func (t *wsTransport) checkOrigin() func(*http.Request) bool {
+	origins, ok := t.opts.Context.Value(originsKey{}).([]string)
+	if !ok || len(origins) == 0 {
+		// the upgrader allows the origin of the host
+		return nil
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if len(origin) == 0 {
+			return true
+		}
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (t *wsTransport) Dial(addr string, opts ...transport.DialOption) (transport.Client, error) {
+	dopts := transport.DialOptions{
+		Timeout: transport.DefaultDialTimeout,
+	}
+	for _, o := range opts {
+		o(&dopts)
+	}
+
+	nd := &net.Dialer{KeepAlive: dopts.KeepAlive}
+	dial := mnet.Dial(addr, nd)
+
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: dopts.Timeout,
+		NetDialContext:   dial,
+	}
+
+	host := addr
+	if mnet.IsUnix(addr) {
+		// the socket is dialled on its path, the host is only in the url
+		dialer.Proxy = nil
+		host = "localhost"
+	}
+
+	if m := t.opts.Metrics; m != nil {
+		// the connections to a proxy are recorded for the address dialled
+		dialer.NetDialContext = func(ctx context.Context, network, a string) (net.Conn, error) {
+			c, err := dial(ctx, network, a)
+			if err != nil {
+				return nil, err
+			}
+			return m.Conn(c, addr), nil
+		}
+	}
+
+	scheme := "ws"
+	if t.opts.Secure || t.opts.TLSConfig != nil {
+		scheme = "wss"
+		dialer.TLSClientConfig = t.opts.TLSConfig
+		if dialer.TLSClientConfig == nil {
+			dialer.TLSClientConfig = &tls.Config{
+				InsecureSkipVerify: true,
+			}
+		}
+	}
+
+	u := url.URL{Scheme: scheme, Host: host, Path: t.path()}
+
+	ctx := context.Background()
+	if dopts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dopts.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+
+	conn, _, err := dialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sock := &wsSocket{conn: conn, opts: t.opts, timeout: t.opts.Timeout}
+
+	if t.opts.Metrics != nil {
+		t.opts.Metrics.Handshake(addr, time.Since(start), sock.ConnectionState())
+	}
+
+	return &wsClient{
+		wsSocket: sock,
+		dopts:    dopts,
+	}, nil
+}
+
+func (t *wsTransport) Listen(addr string, opts ...transport.ListenOption) (transport.Listener, error) {
+	var options transport.ListenOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	l, err := mnet.Listen(addr, mnet.ListenTCP(options.Network, options.ReusePort))
+	if err != nil {
+		return nil, err
+	}
+
+	// the connections are reaped below tls so the handshakes count as use
+	l = mnet.KeepAlive(l, options.KeepAlive)
+	if options.ProxyProtocol {
+		l = mnet.ProxyProtocol(l)
+	}
+	if t.opts.Metrics != nil {
+		l = t.opts.Metrics.Listener(l)
+	}
+	l = mnet.Reap(l, options.IdleTimeout, options.MaxConnAge)
+
+	if t.opts.Secure || t.opts.TLSConfig != nil {
+		config := t.opts.TLSConfig
+		if config == nil {
+			// generate a certificate for the hosts of the listener
+			var hosts []string
+			if h, _, err := net.SplitHostPort(l.Addr().String()); err == nil && len(h) > 0 && h != "::" && h != "0.0.0.0" {
+				hosts = append(hosts, h)
+			} else {
+				hosts = append(hosts, maddr.IPs()...)
+			}
+			cert, err := mtls.Certificate(hosts...)
+			if err != nil {
+				l.Close()
+				return nil, err
+			}
+			config = &tls.Config{Certificates: []tls.Certificate{cert}}
+		}
+		l = tls.NewListener(l, config)
+	}
+
+	return &wsListener{
+		listener: l,
+		opts:     t.opts,
+		path:     t.path(),
+		upgrader: &websocket.Upgrader{CheckOrigin: t.checkOrigin()},
+		maxSize:  options.MaxMsgSize,
+		srv: &http.Server{
+			// the handshakes are timed from accepting the connections
+			ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+				return context.WithValue(ctx, acceptedKey{}, time.Now())
+			},
+		},
+	}, nil
+}
+
+func (t *wsTransport) Init(opts ...transport.Option) error {
+	for _, o := range opts {
+		o(&t.opts)
+	}
+	return nil
+}
+
+func (t *wsTransport) Options() transport.Options {
+	return t.opts
+}
+
+func (t *wsTransport) String() string {
+	return "websocket"
+}
+
+// NewTransport returns a websocket transport
+func NewTransport(opts ...transport.Option) transport.Transport {
+	options := transport.Options{
+		Codec:   json.Marshaler{},
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &wsTransport{opts: options}
+}
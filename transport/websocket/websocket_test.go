@@ -0,0 +1,222 @@
+package websocket
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/transport"
+	mnet "github.com/asim/go-micro/v3/util/net"
+	"github.com/gorilla/websocket"
+)
+
+func serve(t *testing.T, tr transport.Transport) transport.Listener {
+	l, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error listening %v", err)
+	}
+
+	go func() {
+		if err := l.Accept(func(sock transport.Socket) {
+			for {
+				var m transport.Message
+				if err := sock.Recv(&m); err != nil {
+					return
+				}
+				if err := sock.Send(&transport.Message{
+					Header: map[string]string{"Micro-Id": m.Header["Micro-Id"]},
+					Body:   []byte(`pong`),
+				}); err != nil {
+					return
+				}
+			}
+		}); err != nil {
+			t.Errorf("Unexpected error accepting %v", err)
+		}
+	}()
+
+	return l
+}
+
+func ping(t *testing.T, tr transport.Transport, addr string) {
+	c, err := tr.Dial(addr)
+	if err != nil {
+		t.Fatalf("Unexpected error dialing %v", err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := c.Send(&transport.Message{
+			Header: map[string]string{"Micro-Id": "1"},
+			Body:   []byte(`ping`),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var m transport.Message
+		if err := c.Recv(&m); err != nil {
+			t.Fatal(err)
+		}
+		if string(m.Body) != "pong" || m.Header["Micro-Id"] != "1" {
+			t.Fatalf("Unexpected message %+v", m)
+		}
+	}
+}
+
+func TestWebsocketTransport(t *testing.T) {
+	tr := NewTransport()
+	l := serve(t, tr)
+	defer l.Close()
+
+	ping(t, tr, l.Addr())
+}
+
+func TestWebsocketTransportSecure(t *testing.T) {
+	tr := NewTransport(transport.Secure(true), Path("/rpc"))
+	l := serve(t, tr)
+	defer l.Close()
+
+	ping(t, tr, l.Addr())
+
+	// plain websockets aren't accepted
+	if _, err := NewTransport(Path("/rpc")).Dial(l.Addr()); err == nil {
+		t.Fatal("Expected an error dialing without tls")
+	}
+}
+
+func TestWebsocketOrigins(t *testing.T) {
+	tr := NewTransport(Origins("https://app.example.com"))
+	l := serve(t, tr)
+	defer l.Close()
+
+	dial := func(origin string) error {
+		conn, _, err := websocket.DefaultDialer.Dial("ws://"+l.Addr()+DefaultPath, http.Header{"Origin": {origin}})
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	if err := dial("https://app.example.com"); err != nil {
+		t.Fatalf("Expected the origin to be allowed got %v", err)
+	}
+	if err := dial("https://evil.example.com"); err == nil {
+		t.Fatal("Expected the origin to be rejected")
+	}
+}
+
+func TestWebsocketMetrics(t *testing.T) {
+	sm, cm := transport.NewMetrics(), transport.NewMetrics()
+
+	l := serve(t, NewTransport(transport.Secure(true), transport.WithMetrics(sm)))
+	defer l.Close()
+
+	ping(t, NewTransport(transport.Secure(true), transport.WithMetrics(cm)), l.Addr())
+
+	cs := cm.Stats()
+	if len(cs) != 1 || cs[0].Peer != l.Addr() || cs[0].Opened != 1 || cs[0].Closed != 1 || cs[0].Handshakes != 1 {
+		t.Fatalf("Unexpected client stats %+v", cs)
+	}
+	if cs[0].BytesSent == 0 || cs[0].BytesReceived == 0 || cs[0].TLSVersion != "1.3" {
+		t.Fatalf("Unexpected client stats %+v", cs[0])
+	}
+
+	// the server records the connection once it's closed on its side
+	var ss []*transport.PeerStat
+	for i := 0; i < 100; i++ {
+		if ss = sm.Stats(); len(ss) == 1 && ss[0].Closed == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(ss) != 1 || ss[0].Peer != "127.0.0.1" || ss[0].Opened != 1 || ss[0].Handshakes != 1 || ss[0].TLSVersion != "1.3" {
+		t.Fatalf("Unexpected server stats %+v", ss)
+	}
+}
+
+func TestWebsocketMaxMsgSize(t *testing.T) {
+	tr := NewTransport()
+	l, err := tr.Listen("127.0.0.1:0", transport.MaxMsgSize(1024))
+	if err != nil {
+		t.Fatalf("Unexpected error listening %v", err)
+	}
+	defer l.Close()
+
+	errs := make(chan error, 1)
+	go l.Accept(func(sock transport.Socket) {
+		for {
+			var m transport.Message
+			if err := sock.Recv(&m); err != nil {
+				errs <- err
+				return
+			}
+			sock.Send(&transport.Message{Body: []byte(`pong`)})
+		}
+	})
+
+	c, err := tr.Dial(l.Addr())
+	if err != nil {
+		t.Fatalf("Unexpected error dialing %v", err)
+	}
+	defer c.Close()
+
+	// small messages are read
+	if err := c.Send(&transport.Message{Body: []byte(`ping`)}); err != nil {
+		t.Fatal(err)
+	}
+	var m transport.Message
+	if err := c.Recv(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	// larger ones are rejected from the length of their frame
+	if err := c.Send(&transport.Message{Body: make([]byte, 4096)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err != websocket.ErrReadLimit {
+			t.Fatalf("Expected the read limit error got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the message to be rejected")
+	}
+}
+
+func TestWebsocketTransportUnix(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "go-micro-websocket-test.sock")
+	defer os.Remove(path)
+
+	tr := NewTransport()
+	l, err := tr.Listen(mnet.UnixScheme+path, transport.KeepAlive(time.Second))
+	if err != nil {
+		t.Fatalf("Unexpected error listening %v", err)
+	}
+	defer l.Close()
+
+	if l.Addr() != mnet.UnixScheme+path {
+		t.Fatalf("Expected the unix address got %s", l.Addr())
+	}
+
+	go l.Accept(func(sock transport.Socket) {
+		for {
+			var m transport.Message
+			if err := sock.Recv(&m); err != nil {
+				return
+			}
+			if err := sock.Send(&transport.Message{
+				Header: map[string]string{"Micro-Id": m.Header["Micro-Id"]},
+				Body:   []byte(`pong`),
+			}); err != nil {
+				return
+			}
+		}
+	})
+
+	// through an http proxy which isn't used for sockets
+	os.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+	defer os.Unsetenv("HTTP_PROXY")
+
+	ping(t, tr, l.Addr())
+}
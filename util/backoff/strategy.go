@@ -0,0 +1,84 @@
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Strategy returns the duration to wait before the given attempt
+type Strategy func(attempts int) time.Duration
+
+var (
+	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	mtx    sync.Mutex
+)
+
+// jitter returns a random duration in [min, max)
+func jitter(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	return min + time.Duration(random.Int63n(int64(max-min)))
+}
+
+// exp returns base * 2^attempts capped at max
+func exp(base, max time.Duration, attempts int) time.Duration {
+	d := float64(base) * math.Pow(2, float64(attempts))
+	if d > float64(max) || math.IsInf(d, 0) {
+		return max
+	}
+	return time.Duration(d)
+}
+
+// ExpJitter is exponential backoff with full jitter. The wait is
+// random between zero and base * 2^attempts capped at max.
+func ExpJitter(base, max time.Duration) Strategy {
+	return func(attempts int) time.Duration {
+		if attempts <= 0 {
+			return 0
+		}
+		return jitter(0, exp(base, max, attempts))
+	}
+}
+
+// Decorrelated is decorrelated jitter backoff. The wait is random between
+// base and base * 3^attempts capped at max. The range is derived from the
+// attempt alone rather than the previous wait so the strategy can be shared
+// by concurrent retry loops.
+func Decorrelated(base, max time.Duration) Strategy {
+	return func(attempts int) time.Duration {
+		if attempts <= 0 {
+			return 0
+		}
+
+		upper := float64(base) * math.Pow(3, float64(attempts))
+		if upper > float64(max) || math.IsInf(upper, 0) {
+			upper = float64(max)
+		}
+
+		d := jitter(base, time.Duration(upper))
+		if d > max {
+			d = max
+		}
+
+		return d
+	}
+}
+
+// Linear increases the wait by step for each attempt capped at max
+func Linear(step, max time.Duration) Strategy {
+	return func(attempts int) time.Duration {
+		if attempts <= 0 {
+			return 0
+		}
+		d := step * time.Duration(attempts)
+		if d > max || d < 0 {
+			return max
+		}
+		return d
+	}
+}
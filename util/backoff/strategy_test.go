@@ -0,0 +1,66 @@
+package backoff
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExpJitter(t *testing.T) {
+	s := ExpJitter(time.Millisecond, time.Second)
+
+	if d := s(0); d != 0 {
+		t.Fatalf("expected no wait for the first attempt got %v", d)
+	}
+
+	for i := 1; i < 100; i++ {
+		max := exp(time.Millisecond, time.Second, i)
+		if d := s(i); d < 0 || d >= max && max > 0 {
+			t.Fatalf("attempt %d wait %v not in [0, %v)", i, d, max)
+		}
+	}
+}
+
+func TestDecorrelated(t *testing.T) {
+	s := Decorrelated(time.Millisecond, time.Second)
+
+	if d := s(0); d != 0 {
+		t.Fatalf("expected no wait for the first attempt got %v", d)
+	}
+
+	upper := time.Millisecond
+	for i := 1; i < 100; i++ {
+		if upper *= 3; upper > time.Second {
+			upper = time.Second
+		}
+		if d := s(i); d < time.Millisecond || d > upper {
+			t.Fatalf("attempt %d wait %v not in [1ms, %v]", i, d, upper)
+		}
+	}
+
+	// concurrent loops don't reset the range of each other
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s(1)
+			}
+		}()
+	}
+	if d := s(10); d < time.Millisecond || d > time.Second {
+		t.Fatalf("wait %v out of range", d)
+	}
+	wg.Wait()
+}
+
+func TestLinear(t *testing.T) {
+	s := Linear(time.Second, time.Second*3)
+
+	for i, want := range []time.Duration{0, time.Second, time.Second * 2, time.Second * 3, time.Second * 3} {
+		if d := s(i); d != want {
+			t.Fatalf("attempt %d expected %v got %v", i, want, d)
+		}
+	}
+}
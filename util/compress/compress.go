@@ -0,0 +1,286 @@
+// Package compress compresses the bodies of the messages of the transport.
+// Clients list the compressions they accept in the AcceptHeader and the
+// compressed bodies have their compression in the EncodingHeader, so peers
+// which don't compress are still understood.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/asim/go-micro/v3/transport"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// AcceptHeader lists the compressions accepted by the client
+	AcceptHeader = "Micro-Accept-Encoding"
+	// EncodingHeader is the compression of the body
+	EncodingHeader = "Micro-Content-Encoding"
+)
+
+// Compressor compresses the bodies of the messages
+type Compressor interface {
+	Compress([]byte) ([]byte, error)
+	// Decompress the body, failing with a codec.MessageSizeError if it's
+	// larger than the limit, DefaultLimit if it's not set
+	Decompress(b []byte, limit int) ([]byte, error)
+	// String is the name of the compression in the headers
+	String() string
+}
+
+var (
+	// DefaultLimit is the max size of the bodies decompressed without a
+	// limit, so a small compressed body can't exhaust the memory
+	DefaultLimit = 32 << 20
+
+	mtx sync.RWMutex
+	// compressors by name in order of preference
+	compressors = map[string]Compressor{}
+	names       []string
+)
+
+func init() {
+	Register(new(gzipCompressor))
+	Register(new(zstdCompressor))
+	Register(new(snappyCompressor))
+}
+
+// Register a compressor, accepted after the registered ones
+func Register(c Compressor) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if _, ok := compressors[c.String()]; !ok {
+		names = append(names, c.String())
+	}
+	compressors[c.String()] = c
+}
+
+// Get returns the compressor of the name
+func Get(name string) (Compressor, bool) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	c, ok := compressors[name]
+	return c, ok
+}
+
+// Accept returns the value of the AcceptHeader listing the compressions,
+// the preferred one first
+func Accept(preferred string) string {
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	accept := make([]string, 0, len(names))
+	if _, ok := compressors[preferred]; ok {
+		accept = append(accept, preferred)
+	}
+	for _, n := range names {
+		if n != preferred {
+			accept = append(accept, n)
+		}
+	}
+	return strings.Join(accept, ", ")
+}
+
+// Negotiate returns the first compression of the AcceptHeader which is
+// supported, none if there's none
+func Negotiate(accept string) string {
+	for _, n := range strings.Split(accept, ",") {
+		n = strings.TrimSpace(n)
+		if _, ok := Get(n); ok {
+			return n
+		}
+	}
+	return ""
+}
+
+// Encode compresses the body of the message if it's at least min bytes,
+// it's left as is without a compression or if it's already compressed
+func Encode(m *transport.Message, compression string, min int) error {
+	if len(compression) == 0 || min <= 0 || len(m.Body) < min || len(m.Header[EncodingHeader]) > 0 {
+		return nil
+	}
+
+	c, ok := Get(compression)
+	if !ok {
+		return fmt.Errorf("unknown compression %s", compression)
+	}
+
+	b, err := c.Compress(m.Body)
+	if err != nil {
+		return err
+	}
+
+	// the headers may be shared by the messages of a stream
+	hdr := make(map[string]string, len(m.Header)+1)
+	for k, v := range m.Header {
+		hdr[k] = v
+	}
+	hdr[EncodingHeader] = compression
+
+	m.Header = hdr
+	m.Body = b
+
+	return nil
+}
+
+// Decode decompresses the body of the message if it's compressed, up to
+// limit bytes or DefaultLimit if it's not set
+func Decode(m *transport.Message, limit int) error {
+	compression := m.Header[EncodingHeader]
+	if len(compression) == 0 {
+		return nil
+	}
+
+	c, ok := Get(compression)
+	if !ok {
+		return fmt.Errorf("unknown compression %s", compression)
+	}
+
+	b, err := c.Decompress(m.Body, limit)
+	if err != nil {
+		return err
+	}
+
+	delete(m.Header, EncodingHeader)
+	m.Body = b
+
+	return nil
+}
+
+// bound returns the limit of the bodies decompressed
+func bound(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	return limit
+}
+
+// readAll reads r failing if it's larger than the limit
+func readAll(r io.Reader, limit int) ([]byte, error) {
+	limit = bound(limit)
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > limit {
+		return nil, &codec.MessageSizeError{Size: len(b), Limit: limit}
+	}
+	return b, nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(b []byte, limit int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readAll(r, limit)
+}
+
+func (gzipCompressor) String() string { return "gzip" }
+
+type zstdCompressor struct {
+	once sync.Once
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+	err  error
+}
+
+func (z *zstdCompressor) init() error {
+	z.once.Do(func() {
+		if z.enc, z.err = zstd.NewWriter(nil); z.err != nil {
+			return
+		}
+		// the bodies decoded at once are bounded too
+		z.dec, z.err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(DefaultLimit)))
+	})
+	return z.err
+}
+
+func (z *zstdCompressor) Compress(b []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	return z.enc.EncodeAll(b, nil), nil
+}
+
+func (z *zstdCompressor) Decompress(b []byte, limit int) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	limit = bound(limit)
+
+	// the size is checked against the frame header before decoding
+	var h zstd.Header
+	if err := h.Decode(b); err == nil && h.HasFCS && h.FrameContentSize > uint64(limit) {
+		return nil, &codec.MessageSizeError{Size: int(h.FrameContentSize), Limit: limit}
+	}
+
+	dec := z.dec
+	if limit > DefaultLimit {
+		d, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(limit)))
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		dec = d
+	}
+
+	d, err := dec.DecodeAll(b, nil)
+	if err == zstd.ErrDecoderSizeExceeded {
+		// the decoding stopped at the limit
+		return nil, &codec.MessageSizeError{Size: limit + 1, Limit: limit}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(d) > limit {
+		return nil, &codec.MessageSizeError{Size: len(d), Limit: limit}
+	}
+	return d, nil
+}
+
+func (z *zstdCompressor) String() string { return "zstd" }
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Compress(b []byte) ([]byte, error) {
+	return snappy.Encode(nil, b), nil
+}
+
+func (snappyCompressor) Decompress(b []byte, limit int) ([]byte, error) {
+	limit = bound(limit)
+	n, err := snappy.DecodedLen(b)
+	if err != nil {
+		return nil, err
+	}
+	if n > limit {
+		return nil, &codec.MessageSizeError{Size: n, Limit: limit}
+	}
+	return snappy.Decode(nil, b)
+}
+
+func (snappyCompressor) String() string { return "snappy" }
@@ -0,0 +1,107 @@
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/asim/go-micro/v3/codec"
+	"github.com/asim/go-micro/v3/transport"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiate(t *testing.T) {
+	if a := Accept("zstd"); a != "zstd, gzip, snappy" {
+		t.Fatalf("expected zstd first got %s", a)
+	}
+	if a := Accept("br"); a != "gzip, zstd, snappy" {
+		t.Fatalf("expected the registered order got %s", a)
+	}
+
+	testData := map[string]string{
+		"br, snappy, gzip": "snappy",
+		"gzip":             "gzip",
+		"br":               "",
+		"":                 "",
+	}
+
+	for accept, expect := range testData {
+		if c := Negotiate(accept); c != expect {
+			t.Fatalf("expected %q for %q got %q", expect, accept, c)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	body := bytes.Repeat([]byte("micro"), 1024)
+
+	for _, c := range []string{"gzip", "zstd", "snappy"} {
+		hdr := map[string]string{"Content-Type": "application/json"}
+		m := &transport.Message{Header: hdr, Body: body}
+
+		if err := Encode(m, c, 1024); err != nil {
+			t.Fatal(err)
+		}
+		if m.Header[EncodingHeader] != c || len(m.Body) >= len(body) {
+			t.Fatalf("expected the body compressed with %s", c)
+		}
+		if _, ok := hdr[EncodingHeader]; ok {
+			t.Fatal("expected the original header unchanged")
+		}
+
+		// the limit is checked against the decompressed size
+		lm := &transport.Message{Header: map[string]string{EncodingHeader: c}, Body: m.Body}
+		var serr *codec.MessageSizeError
+		if err := Decode(lm, 1024); !errors.As(err, &serr) {
+			t.Fatalf("expected a message size error for %s got %v", c, err)
+		}
+
+		if err := Decode(m, len(body)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m.Body, body) || len(m.Header[EncodingHeader]) > 0 {
+			t.Fatalf("expected the body decompressed with %s", c)
+		}
+	}
+
+	// small bodies aren't compressed
+	m := &transport.Message{Header: map[string]string{}, Body: []byte("micro")}
+	if err := Encode(m, "gzip", 1024); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Header[EncodingHeader]) > 0 {
+		t.Fatal("expected the small body left as is")
+	}
+}
+
+func TestDecodeDefaultLimit(t *testing.T) {
+	bomb := make([]byte, DefaultLimit+1)
+
+	for _, c := range []string{"gzip", "zstd", "snappy"} {
+		m := &transport.Message{Header: map[string]string{}, Body: bomb}
+		if err := Encode(m, c, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		// bodies are decompressed up to the default limit without one
+		var serr *codec.MessageSizeError
+		if err := Decode(m, 0); !errors.As(err, &serr) || serr.Limit != DefaultLimit {
+			t.Fatalf("expected a message size error for %s got %v", c, err)
+		}
+	}
+
+	// the zstd frames without a content size are bounded while decoding
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(bomb)
+	w.Close()
+
+	m := &transport.Message{Header: map[string]string{EncodingHeader: "zstd"}, Body: buf.Bytes()}
+	var serr *codec.MessageSizeError
+	if err := Decode(m, 0); !errors.As(err, &serr) {
+		t.Fatalf("expected a message size error got %v", err)
+	}
+}
@@ -3,15 +3,20 @@ package jitter
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
 var (
 	r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	// the source isn't safe for concurrent use
+	mtx sync.Mutex
 )
 
 // Do returns a random time to jitter with max cap specified
 func Do(d time.Duration) time.Duration {
+	mtx.Lock()
 	v := r.Float64() * float64(d.Nanoseconds())
+	mtx.Unlock()
 	return time.Duration(v)
 }
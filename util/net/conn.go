@@ -0,0 +1,137 @@
+package net
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeepAlive returns a listener setting the tcp keepalive period of the
+// connections accepted, a negative period disables keepalives and zero
+// leaves the default of the system
+func KeepAlive(l net.Listener, period time.Duration) net.Listener {
+	if period == 0 {
+		return l
+	}
+	return &keepAliveListener{Listener: l, period: period}
+}
+
+// Reap returns a listener closing the connections accepted once they've
+// been idle, neither reading nor writing, for longer than the idle timeout
+// or once they're older than the max age, so the clients reconnect e.g to
+// rebalance. Zero disables either. The idle timeout should be longer than
+// the requests take as a connection is idle while it waits for a response.
+func Reap(l net.Listener, idle, maxAge time.Duration) net.Listener {
+	if idle <= 0 && maxAge <= 0 {
+		return l
+	}
+	return &reapListener{Listener: l, idle: idle, maxAge: maxAge}
+}
+
+type keepAliveListener struct {
+	net.Listener
+	period time.Duration
+}
+
+func (l *keepAliveListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := c.(*net.TCPConn); ok {
+		if l.period < 0 {
+			tc.SetKeepAlive(false)
+		} else {
+			tc.SetKeepAlive(true)
+			tc.SetKeepAlivePeriod(l.period)
+		}
+	}
+	return c, nil
+}
+
+type reapListener struct {
+	net.Listener
+	idle   time.Duration
+	maxAge time.Duration
+}
+
+func (l *reapListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newReapConn(c, l.idle, l.maxAge), nil
+}
+
+type reapConn struct {
+	net.Conn
+	idle time.Duration
+	// last read or write in unix nanoseconds
+	last int64
+
+	sync.Mutex
+	closed bool
+	timers []*time.Timer
+}
+
+func newReapConn(c net.Conn, idle, maxAge time.Duration) *reapConn {
+	rc := &reapConn{Conn: c, idle: idle, last: time.Now().UnixNano()}
+
+	// the timers don't fire before they're set
+	rc.Lock()
+	defer rc.Unlock()
+
+	if idle > 0 {
+		rc.timers = append(rc.timers, time.AfterFunc(idle, rc.reapIdle))
+	}
+	if maxAge > 0 {
+		rc.timers = append(rc.timers, time.AfterFunc(maxAge, func() { rc.Close() }))
+	}
+
+	return rc
+}
+
+// reapIdle closes the conn if it's idle or waits for the rest of the idle
+// timeout since it was last used
+func (c *reapConn) reapIdle() {
+	since := time.Since(time.Unix(0, atomic.LoadInt64(&c.last)))
+	if since >= c.idle {
+		c.Close()
+		return
+	}
+
+	c.Lock()
+	if !c.closed {
+		c.timers[0].Reset(c.idle - since)
+	}
+	c.Unlock()
+}
+
+func (c *reapConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		atomic.StoreInt64(&c.last, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (c *reapConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		atomic.StoreInt64(&c.last, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (c *reapConn) Close() error {
+	c.Lock()
+	if !c.closed {
+		c.closed = true
+		for _, t := range c.timers {
+			t.Stop()
+		}
+	}
+	c.Unlock()
+	return c.Conn.Close()
+}
@@ -1,13 +1,110 @@
 package net
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// UnixScheme is the scheme of unix domain socket addresses
+// e.g unix:///var/run/service.sock
+const UnixScheme = "unix://"
+
+const (
+	// DualStack accepts both ipv4 and ipv6 connections
+	DualStack = "tcp"
+	// IPv4Only accepts ipv4 connections
+	IPv4Only = "tcp4"
+	// IPv6Only accepts ipv6 connections
+	IPv6Only = "tcp6"
+)
+
+var (
+	// ErrReusePort is returned where SO_REUSEPORT is not supported
+	ErrReusePort = errors.New("reuse port not supported")
+)
+
+// IsUnix returns true if the address is a unix domain socket
+func IsUnix(addr string) bool {
+	return strings.HasPrefix(addr, UnixScheme)
+}
+
+// Network returns the network and address to dial or listen on.
+// Unix addresses return the socket path.
+func Network(addr string) (string, string) {
+	if IsUnix(addr) {
+		return "unix", strings.TrimPrefix(addr, UnixScheme)
+	}
+	return "tcp", addr
+}
+
+// ListenTCP returns a listen func for Listen binding on the network, one
+// of DualStack, IPv4Only or IPv6Only. If reuse is true SO_REUSEPORT is set
+// so multiple processes can listen on the same port. The address is bound
+// as given, an empty host accepts both families on DualStack where the host
+// supports ipv6 and ipv4 hosts such as 0.0.0.0 only accept ipv4. Unix socket
+// addresses are listened on as unix sockets.
+func ListenTCP(network string, reuse bool) func(string) (net.Listener, error) {
+	if len(network) == 0 {
+		network = DualStack
+	}
+
+	var lc net.ListenConfig
+	if reuse {
+		lc.Control = reusePort
+	}
+
+	return func(addr string) (net.Listener, error) {
+		if IsUnix(addr) {
+			_, path := Network(addr)
+			return net.Listen("unix", path)
+		}
+		// go binds wildcard ipv4 hosts on both families
+		if network == DualStack {
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+					return lc.Listen(context.Background(), IPv4Only, addr)
+				}
+			}
+		}
+		return lc.Listen(context.Background(), network, addr)
+	}
+}
+
+// removeStale removes the socket file at the path if nothing listens on it
+// e.g it was left by a process which crashed
+func removeStale(path string) {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	c, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		// in use so listening fails
+		c.Close()
+		return
+	}
+	os.Remove(path)
+}
+
+// Dial returns a dial func for the address, unix socket addresses are
+// dialled on their path whatever the network and address dialled e.g by an
+// http client
+func Dial(addr string, d *net.Dialer) func(ctx context.Context, network, a string) (net.Conn, error) {
+	if !IsUnix(addr) {
+		return d.DialContext
+	}
+	_, path := Network(addr)
+	return func(ctx context.Context, network, a string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
 // HostPort format addr and port suitable for dial
 func HostPort(addr string, port interface{}) string {
 	host := addr
@@ -25,8 +122,14 @@ func HostPort(addr string, port interface{}) string {
 }
 
 // Listen takes addr:portmin-portmax and binds to the first available port
-// Example: Listen("localhost:5000-6000", fn)
+// Example: Listen("localhost:5000-6000", fn). Unix socket addresses are
+// passed to fn as is once a stale socket file is removed, see Network.
 func Listen(addr string, fn func(string) (net.Listener, error)) (net.Listener, error) {
+	if IsUnix(addr) {
+		_, path := Network(addr)
+		removeStale(path)
+		return fn(addr)
+	}
 
 	if strings.Count(addr, ":") == 1 && strings.Count(addr, "-") == 0 {
 		return fn(addr)
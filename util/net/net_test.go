@@ -1,8 +1,12 @@
 package net
 
 import (
+	"context"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestListen(t *testing.T) {
@@ -19,8 +23,156 @@ func TestListen(t *testing.T) {
 		defer l.Close()
 	}
 
+	// unix sockets are listened on by the listen func
+	path := filepath.Join(os.TempDir(), "go-micro-net-test.sock")
+	defer os.Remove(path)
+
+	l, err := Listen(UnixScheme+path, ListenTCP("", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Addr().Network() != "unix" {
+		t.Fatalf("expected a unix listener got %s", l.Addr().Network())
+	}
+
+	// a socket in use isn't removed
+	if _, err := Listen(UnixScheme+path, ListenTCP("", false)); err == nil {
+		t.Fatal("expected an error listening on a socket in use")
+	}
+
+	go func(l net.Listener) {
+		if c, err := l.Accept(); err == nil {
+			c.Close()
+		}
+	}(l)
+
+	c, err := Dial(UnixScheme+path, &net.Dialer{})(context.Background(), "tcp", "localhost:80")
+	if err != nil {
+		t.Fatalf("expected the socket to be dialled got %v", err)
+	}
+	c.Close()
+
+	// a stale socket file is replaced, go removes the file on close
+	ul := l.(*net.UnixListener)
+	ul.SetUnlinkOnClose(false)
+	l.Close()
+
+	l, err = Listen(UnixScheme+path, ListenTCP("", false))
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced got %v", err)
+	}
+	l.Close()
+
+	if n, a := Network(UnixScheme + path); n != "unix" || a != path {
+		t.Fatalf("unexpected network %s %s", n, a)
+	}
+
 	// TODO nats case test
 	// natsAddr := "_INBOX.bID2CMRvlNp0vt4tgNBHWf"
 	// Expect addr DO NOT has extra ":" at the end!
 
 }
+
+func TestListenTCP(t *testing.T) {
+	l, err := Listen("127.0.0.1:0", ListenTCP(IPv4Only, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// a second listener can share the port
+	l2, err := Listen(l.Addr().String(), ListenTCP(IPv4Only, true))
+	if err != nil {
+		t.Fatalf("expected the port to be shared got %v", err)
+	}
+	l2.Close()
+
+	// without reuse port the bind fails
+	if l3, err := Listen(l.Addr().String(), ListenTCP(IPv4Only, false)); err == nil {
+		l3.Close()
+		t.Fatal("expected the port to be in use")
+	}
+
+	// wildcard hosts are bound on both families
+	l4, err := Listen(":0", ListenTCP(DualStack, false))
+	if err != nil {
+		t.Skip("ipv6 not available: ", err)
+	}
+	defer l4.Close()
+
+	if host, _, _ := net.SplitHostPort(l4.Addr().String()); host != "::" {
+		t.Fatalf("expected a dual-stack listener got %s", l4.Addr())
+	}
+
+	// explicit hosts are bound as given
+	l5, err := Listen("0.0.0.0:0", ListenTCP(DualStack, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l5.Close()
+
+	if host, _, _ := net.SplitHostPort(l5.Addr().String()); host != "0.0.0.0" {
+		t.Fatalf("expected an ipv4 listener got %s", l5.Addr())
+	}
+}
+
+func TestReap(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = Reap(KeepAlive(l, time.Second), 50*time.Millisecond, 300*time.Millisecond)
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// echo until the conn is reaped
+			go func() {
+				b := make([]byte, 1)
+				for {
+					if _, err := c.Read(b); err != nil {
+						return
+					}
+					c.Write(b)
+				}
+			}()
+		}
+	}()
+
+	// reaps returns how long the conn lasted, pinging every interval
+	reaps := func(interval time.Duration) time.Duration {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		start := time.Now()
+		b := make([]byte, 1)
+		for time.Since(start) < time.Second {
+			c.SetDeadline(time.Now().Add(interval * 2))
+			if _, err := c.Write(b); err != nil {
+				break
+			}
+			if _, err := c.Read(b); err != nil {
+				break
+			}
+			time.Sleep(interval)
+		}
+		return time.Since(start)
+	}
+
+	// an idle conn is closed after the idle timeout
+	if d := reaps(100 * time.Millisecond); d > 250*time.Millisecond {
+		t.Fatalf("expected the idle conn reaped got %v", d)
+	}
+
+	// a conn in use is closed after the max age
+	if d := reaps(10 * time.Millisecond); d < 250*time.Millisecond || d > 700*time.Millisecond {
+		t.Fatalf("expected the conn reaped after its max age got %v", d)
+	}
+}
@@ -0,0 +1,221 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ProxyHeaderTimeout is how long reading the PROXY protocol header of
+	// a connection may take
+	ProxyHeaderTimeout = 10 * time.Second
+
+	// ErrProxyHeader is returned for connections without a valid header
+	ErrProxyHeader = errors.New("invalid proxy protocol header")
+
+	// signature of the v2 headers
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// ProxyProtocol returns a listener reading the HAProxy PROXY protocol v1 or
+// v2 header sent by load balancers on the connections accepted, so their
+// remote address is the address of the client. Connections without a
+// header fail so it should only be used behind proxies sending them.
+func ProxyProtocol(l net.Listener) net.Listener {
+	return &proxyListener{Listener: l}
+}
+
+type proxyListener struct {
+	net.Listener
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// the header is read on first use so slow clients don't block accepting
+	return &proxyConn{Conn: c, r: bufio.NewReader(c)}, nil
+}
+
+type proxyConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+
+	sync.Mutex
+	// read deadline set on the conn, restored once the header is read
+	deadline time.Time
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.Lock()
+		deadline := time.Now().Add(ProxyHeaderTimeout)
+		if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+			deadline = c.deadline
+		}
+		c.Conn.SetReadDeadline(deadline)
+		c.Unlock()
+
+		c.remote, c.err = readProxyHeader(c.r)
+
+		c.Lock()
+		c.Conn.SetReadDeadline(c.deadline)
+		c.Unlock()
+
+		if c.err != nil {
+			c.Conn.Close()
+		}
+	})
+}
+
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	c.Lock()
+	defer c.Unlock()
+	c.deadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	c.Lock()
+	defer c.Unlock()
+	c.deadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the address of the client, or of the proxy if it
+// didn't send one e.g for health checks
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads a v1 or v2 header returning the source address,
+// nil if the proxy sent none
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b[0] {
+	case 'P':
+		return readProxyV1(r)
+	case proxyV2Signature[0]:
+		return readProxyV2(r)
+	}
+
+	return nil, ErrProxyHeader
+}
+
+// readProxyV1 reads the text header e.g
+// PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	// the header is at most 107 bytes
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrProxyHeader
+	}
+
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+	if parts[0] != "PROXY" || len(parts) < 2 {
+		return nil, ErrProxyHeader
+	}
+
+	switch parts[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, ErrProxyHeader
+	}
+
+	if len(parts) != 6 {
+		return nil, ErrProxyHeader
+	}
+
+	ip := net.ParseIP(parts[2])
+	port, err := strconv.ParseUint(parts[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, ErrProxyHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2 reads the binary header
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr[:12], proxyV2Signature) || hdr[12]>>4 != 2 {
+		return nil, ErrProxyHeader
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	switch hdr[12] & 0xF {
+	case 0x0:
+		// LOCAL e.g health checks of the proxy
+		return nil, nil
+	case 0x1:
+		// PROXY
+	default:
+		return nil, fmt.Errorf("%v: unknown command %d", ErrProxyHeader, hdr[12]&0xF)
+	}
+
+	// the addresses are followed by TLVs which are skipped
+	switch hdr[13] >> 4 {
+	case 0x1:
+		if len(body) < 12 {
+			return nil, ErrProxyHeader
+		}
+		return &net.TCPAddr{IP: net.IP(body[:4]), Port: int(binary.BigEndian.Uint16(body[8:]))}, nil
+	case 0x2:
+		if len(body) < 36 {
+			return nil, ErrProxyHeader
+		}
+		return &net.TCPAddr{IP: net.IP(body[:16]), Port: int(binary.BigEndian.Uint16(body[32:]))}, nil
+	}
+
+	// unspecified or unix addresses
+	return nil, nil
+}
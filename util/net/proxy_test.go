@@ -0,0 +1,128 @@
+package net
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadProxyHeader(t *testing.T) {
+	v2 := func(cmd, fam byte, addrs ...byte) string {
+		b := append([]byte{}, proxyV2Signature...)
+		b = append(b, 0x20|cmd, fam, 0, byte(len(addrs)))
+		return string(append(b, addrs...))
+	}
+
+	testData := []struct {
+		header string
+		remote string
+		err    bool
+	}{
+		{"PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n", "192.168.0.1:56324", false},
+		{"PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", "[2001:db8::1]:56324", false},
+		{"PROXY UNKNOWN\r\n", "", false},
+		{"PROXY TCP4 192.168.0.1\r\n", "", true},
+		{"PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\n", "", true},
+		{v2(0x1, 0x11, 10, 0, 0, 1, 10, 0, 0, 2, 0x1f, 0x90, 0x01, 0xbb), "10.0.0.1:8080", false},
+		{v2(0x1, 0x21, append(append(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")...), 0x1f, 0x90, 0x01, 0xbb)...), "[2001:db8::1]:8080", false},
+		{v2(0x0, 0x00), "", false},
+		{v2(0x1, 0x11, 10, 0, 0, 1), "", true},
+		{"GET / HTTP/1.1\r\n", "", true},
+	}
+
+	for _, d := range testData {
+		addr, err := readProxyHeader(bufio.NewReader(strings.NewReader(d.header + "body")))
+		if d.err {
+			if err == nil {
+				t.Fatalf("expected an error for %q", d.header)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", d.header, err)
+		}
+		if (addr == nil && len(d.remote) > 0) || (addr != nil && addr.String() != d.remote) {
+			t.Fatalf("expected %q for %q got %v", d.remote, d.header, addr)
+		}
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = ProxyProtocol(l)
+	defer l.Close()
+
+	go func() {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\nping"))
+	}()
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if r := c.RemoteAddr().String(); r != "192.168.0.1:56324" {
+		t.Fatalf("expected the client address got %s", r)
+	}
+
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(c, b); err != nil || string(b) != "ping" {
+		t.Fatalf("expected the body after the header got %q %v", b, err)
+	}
+}
+
+func TestProxyDeadline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = ProxyProtocol(l)
+	defer l.Close()
+
+	go func() {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+		// nothing else is sent until the deadline passed
+		time.Sleep(time.Second)
+	}()
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the deadline set before the header is read is kept after it
+	if err := c.SetReadDeadline(time.Now().Add(time.Millisecond * 50)); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	b := make([]byte, 4)
+	if _, err := c.Read(b); err == nil {
+		t.Fatal("expected the read to time out")
+	} else if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		t.Fatalf("expected a timeout got %v", err)
+	}
+
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Fatalf("expected the read to time out at the deadline got %v", d)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package net
+
+import (
+	"syscall"
+)
+
+// reusePort is not supported on this platform
+func reusePort(network, address string, c syscall.RawConn) error {
+	return ErrReusePort
+}
@@ -1,6 +1,8 @@
 package pool
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -8,28 +10,54 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrTimeout is returned when no connection is released in time
+	// once the max connections are open
+	ErrTimeout = errors.New("timed out waiting for a connection")
+)
+
 type pool struct {
-	size int
-	ttl  time.Duration
-	tr   transport.Transport
+	size     int
+	ttl      time.Duration
+	idle     time.Duration
+	maxConns int
+	tr       transport.Transport
 
 	sync.Mutex
-	conns map[string][]*poolConn
+	// closed when a connection is released or closed
+	released chan bool
+	conns    map[string][]*poolConn
+	// open connections per host
+	open  map[string]int
+	stats Stats
 }
 
 type poolConn struct {
 	transport.Client
-	id      string
-	created time.Time
+	id       string
+	addr     string
+	created  time.Time
+	released time.Time
 }
 
 func newPool(options Options) *pool {
-	return &pool{
-		size:  options.Size,
-		tr:    options.Transport,
-		ttl:   options.TTL,
-		conns: make(map[string][]*poolConn),
+	p := &pool{
+		size:     options.Size,
+		tr:       options.Transport,
+		ttl:      options.TTL,
+		idle:     options.IdleTimeout,
+		maxConns: options.MaxConns,
+		conns:    make(map[string][]*poolConn),
+		open:     make(map[string]int),
+		released: make(chan bool),
 	}
+	return p
+}
+
+// notify wakes the Gets waiting for a connection. Must hold the lock.
+func (p *pool) notify() {
+	close(p.released)
+	p.released = make(chan bool)
 }
 
 func (p *pool) Close() error {
@@ -37,9 +65,11 @@ func (p *pool) Close() error {
 	for k, c := range p.conns {
 		for _, conn := range c {
 			conn.Client.Close()
+			p.open[k]--
 		}
 		delete(p.conns, k)
 	}
+	p.notify()
 	p.Unlock()
 	return nil
 }
@@ -57,58 +87,164 @@ func (p *poolConn) Created() time.Time {
 	return p.created
 }
 
+// expired returns true if the conn is past its ttl or idle timeout
+func (p *pool) expired(conn *poolConn) bool {
+	if time.Since(conn.Created()) > p.ttl {
+		return true
+	}
+	return p.idle > 0 && time.Since(conn.released) > p.idle
+}
+
+// close closes the conn and frees its place in the pool. Must hold the lock.
+func (p *pool) close(conn *poolConn) error {
+	p.open[conn.addr]--
+	p.notify()
+	return conn.Client.Close()
+}
+
 func (p *pool) Get(addr string, opts ...transport.DialOption) (Conn, error) {
 	p.Lock()
-	conns := p.conns[addr]
+	p.stats.Gets++
 
-	// while we have conns check age and then return one
-	// otherwise we'll create a new conn
-	for len(conns) > 0 {
-		conn := conns[len(conns)-1]
-		conns = conns[:len(conns)-1]
-		p.conns[addr] = conns
+	var start time.Time
+	var timeout <-chan time.Time
+	var ctx context.Context
 
-		// if conn is old kill it and move on
-		if d := time.Since(conn.Created()); d > p.ttl {
-			conn.Client.Close()
-			continue
+	for {
+		conns := p.conns[addr]
+
+		// while we have conns check age and then return one
+		// otherwise we'll create a new conn
+		for len(conns) > 0 {
+			conn := conns[len(conns)-1]
+			conns = conns[:len(conns)-1]
+			p.conns[addr] = conns
+
+			// if conn is old kill it and move on
+			if p.expired(conn) {
+				p.close(conn)
+				continue
+			}
+
+			p.waited(start)
+			// we got a good conn, lets unlock and return it
+			p.Unlock()
+
+			return conn, nil
+		}
+
+		if p.maxConns <= 0 || p.open[addr] < p.maxConns {
+			break
+		}
+
+		// wait for a conn to be released, up to the dial timeout
+		if start.IsZero() {
+			start = time.Now()
+			p.stats.Waits++
+
+			options := transport.DialOptions{
+				Timeout: transport.DefaultDialTimeout,
+			}
+			for _, o := range opts {
+				o(&options)
+			}
+			if options.Timeout > 0 {
+				t := time.NewTimer(options.Timeout)
+				defer t.Stop()
+				timeout = t.C
+			}
+			ctx = options.Context
+		}
+
+		var done <-chan struct{}
+		if ctx != nil {
+			done = ctx.Done()
 		}
 
-		// we got a good conn, lets unlock and return it
+		released := p.released
 		p.Unlock()
 
-		return conn, nil
+		var err error
+		select {
+		case <-released:
+		case <-timeout:
+			err = ErrTimeout
+		case <-done:
+			err = ctx.Err()
+		}
+
+		p.Lock()
+		if err != nil {
+			p.waited(start)
+			p.Unlock()
+			return nil, err
+		}
 	}
 
+	p.waited(start)
+	p.open[addr]++
+	p.stats.Dials++
 	p.Unlock()
 
 	// create new conn
 	c, err := p.tr.Dial(addr, opts...)
 	if err != nil {
+		p.Lock()
+		p.open[addr]--
+		p.notify()
+		p.Unlock()
 		return nil, err
 	}
 	return &poolConn{
 		Client:  c,
 		id:      uuid.New().String(),
+		addr:    addr,
 		created: time.Now(),
 	}, nil
 }
 
-func (p *pool) Release(conn Conn, err error) error {
+// waited records the time spent waiting since start. Must hold the lock.
+func (p *pool) waited(start time.Time) {
+	if !start.IsZero() {
+		p.stats.WaitTime += time.Since(start)
+	}
+}
+
+func (p *pool) Release(c Conn, err error) error {
+	conn := c.(*poolConn)
+
+	p.Lock()
+	defer p.Unlock()
+
 	// don't store the conn if it has errored
 	if err != nil {
-		return conn.(*poolConn).Client.Close()
+		return p.close(conn)
 	}
 
 	// otherwise put it back for reuse
-	p.Lock()
-	conns := p.conns[conn.Remote()]
+	conns := p.conns[conn.addr]
 	if len(conns) >= p.size {
-		p.Unlock()
-		return conn.(*poolConn).Client.Close()
+		return p.close(conn)
 	}
-	p.conns[conn.Remote()] = append(conns, conn.(*poolConn))
-	p.Unlock()
+	conn.released = time.Now()
+	p.conns[conn.addr] = append(conns, conn)
+	p.notify()
 
 	return nil
 }
+
+func (p *pool) Stats() Stats {
+	p.Lock()
+	defer p.Unlock()
+
+	stats := p.stats
+	for _, n := range p.open {
+		stats.Open += n
+	}
+	for _, conns := range p.conns {
+		stats.Idle += len(conns)
+	}
+	stats.InUse = stats.Open - stats.Idle
+
+	return stats
+}
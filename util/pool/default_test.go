@@ -1,6 +1,7 @@
 package pool
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -87,3 +88,140 @@ func TestClientPool(t *testing.T) {
 	testPool(t, 0, time.Minute)
 	testPool(t, 2, time.Minute)
 }
+
+func TestPoolMaxConns(t *testing.T) {
+	tr := memory.NewTransport()
+
+	l, err := tr.Listen(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go l.Accept(func(s transport.Socket) {})
+
+	p := newPool(Options{
+		TTL:       time.Minute,
+		Size:      1,
+		MaxConns:  1,
+		Transport: tr,
+	})
+
+	c, err := p.Get(l.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan Conn)
+	go func() {
+		c, err := p.Get(l.Addr())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- c
+	}()
+
+	select {
+	case <-got:
+		t.Fatal("expected get to wait for the conn to be released")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	if s := p.Stats(); s.Open != 1 || s.InUse != 1 || s.Waits != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	p.Release(c, nil)
+
+	if c2 := <-got; c2.Id() != c.Id() {
+		t.Fatalf("expected the released conn %s got %s", c.Id(), c2.Id())
+	}
+
+	s := p.Stats()
+	if s.Gets != 2 || s.Dials != 1 || s.WaitTime <= 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	tr := memory.NewTransport()
+
+	l, err := tr.Listen(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go l.Accept(func(s transport.Socket) {})
+
+	p := newPool(Options{
+		TTL:         time.Minute,
+		Size:        1,
+		IdleTimeout: time.Millisecond * 10,
+		Transport:   tr,
+	})
+
+	c, err := p.Get(l.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Release(c, nil)
+
+	time.Sleep(time.Millisecond * 20)
+
+	c2, err := p.Get(l.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c2.Id() == c.Id() {
+		t.Fatal("expected the idle conn to be closed")
+	}
+
+	if s := p.Stats(); s.Open != 1 || s.Dials != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestPoolMaxConnsTimeout(t *testing.T) {
+	tr := memory.NewTransport()
+
+	l, err := tr.Listen(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go l.Accept(func(s transport.Socket) {})
+
+	p := newPool(Options{
+		TTL:       time.Minute,
+		Size:      1,
+		MaxConns:  1,
+		Transport: tr,
+	})
+
+	if _, err := p.Get(l.Addr()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the wait is bounded by the dial timeout
+	start := time.Now()
+	if _, err := p.Get(l.Addr(), transport.WithTimeout(time.Millisecond*20)); err != ErrTimeout {
+		t.Fatalf("expected the get to time out got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected the get to time out after 20ms got %v", d)
+	}
+
+	// and by the context of the dial
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if _, err := p.Get(l.Addr(), transport.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Fatalf("expected the context deadline got %v", err)
+	}
+
+	if s := p.Stats(); s.Waits != 2 || s.Open != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
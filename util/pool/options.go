@@ -8,8 +8,14 @@ import (
 
 type Options struct {
 	Transport transport.Transport
-	TTL       time.Duration
-	Size      int
+	// TTL is the max lifetime of a connection
+	TTL time.Duration
+	// Size is the max number of idle connections per host
+	Size int
+	// IdleTimeout closes connections idle for longer
+	IdleTimeout time.Duration
+	// MaxConns limits the open connections per host, zero is no limit
+	MaxConns int
 }
 
 type Option func(*Options)
@@ -31,3 +37,19 @@ func TTL(t time.Duration) Option {
 		o.TTL = t
 	}
 }
+
+// IdleTimeout closes connections which have been idle for longer than the timeout
+func IdleTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.IdleTimeout = t
+	}
+}
+
+// MaxConns limits the number of open connections per host. Get waits up
+// to the dial timeout, or until the context of the dial is done, for a
+// connection to be released once the limit is reached.
+func MaxConns(i int) Option {
+	return func(o *Options) {
+		o.MaxConns = i
+	}
+}
@@ -17,6 +17,30 @@ type Pool interface {
 	Release(c Conn, status error) error
 }
 
+// Reporter is implemented by the pools reporting their utilisation
+type Reporter interface {
+	// Stats returns the utilisation of the pool
+	Stats() Stats
+}
+
+// Stats of the pool utilisation
+type Stats struct {
+	// Open connections
+	Open int `json:"open"`
+	// Idle connections
+	Idle int `json:"idle"`
+	// InUse connections
+	InUse int `json:"in_use"`
+	// Gets is the total number of connections requested
+	Gets uint64 `json:"gets"`
+	// Dials is the total number of connections created
+	Dials uint64 `json:"dials"`
+	// Waits is the total number of Gets which waited for a connection
+	Waits uint64 `json:"waits"`
+	// WaitTime is the total time spent waiting for connections
+	WaitTime time.Duration `json:"wait_time"`
+}
+
 type Conn interface {
 	// unique id of connection
 	Id() string
@@ -2,8 +2,12 @@
 package cache
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -23,6 +27,11 @@ type Cache interface {
 type Options struct {
 	// TTL is the cache TTL
 	TTL time.Duration
+	// Stale is how long after the TTL expired entries are served while
+	// they're refreshed in the background
+	Stale time.Duration
+	// Path the cache is persisted to and loaded from on start
+	Path string
 }
 
 type Option func(o *Options)
@@ -37,9 +46,15 @@ type cache struct {
 	ttls     map[string]ttls
 	watched  map[string]watched
 	running  map[string]bool
+	// services being refreshed in the background
+	refreshing map[string]bool
 
 	// used to stop the caches
 	exit chan bool
+	// notified of changes to persist
+	changed chan bool
+	// closed once persisted on stop
+	persisted chan bool
 
 	// indicate whether its running status of the registry used to hold onto the cache in failure state
 	status error
@@ -116,6 +131,8 @@ func (c *cache) del(domain, service string) {
 	if _, ok := c.ttls[domain]; ok {
 		delete(c.ttls[domain], service)
 	}
+
+	c.notify()
 }
 
 func (c *cache) get(domain, service string) ([]*registry.Service, error) {
@@ -137,6 +154,12 @@ func (c *cache) get(domain, service string) ([]*registry.Service, error) {
 		return util.Copy(services), nil
 	}
 
+	// expired but stale entries are served while they're refreshed
+	if len(services) > 0 && !ttl.IsZero() && time.Since(ttl) < c.opts.Stale {
+		c.refresh(domain, service)
+		return util.Copy(services), nil
+	}
+
 	// get does the actual request for a service and cache it
 	get := func(domain string, service string, cached []*registry.Service) ([]*registry.Service, error) {
 		// ask the registry
@@ -200,6 +223,40 @@ func (c *cache) get(domain, service string) ([]*registry.Service, error) {
 	return get(domain, service, services)
 }
 
+// refresh gets the service from the registry in the background, the
+// cached entries are kept if it fails
+func (c *cache) refresh(domain, service string) {
+	key := domain + "/" + service
+
+	c.Lock()
+	if c.refreshing[key] {
+		c.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.Unlock()
+
+	go func() {
+		defer func() {
+			c.Lock()
+			delete(c.refreshing, key)
+			c.Unlock()
+		}()
+
+		services, err := c.Registry.GetService(service, registry.GetDomain(domain))
+		if err != nil {
+			c.setStatus(err)
+			return
+		}
+
+		if err := c.getStatus(); err != nil {
+			c.setStatus(nil)
+		}
+
+		c.set(domain, service, util.Copy(services))
+	}()
+}
+
 func (c *cache) set(domain string, service string, srvs []*registry.Service) {
 	c.Lock()
 	defer c.Unlock()
@@ -213,6 +270,97 @@ func (c *cache) set(domain string, service string, srvs []*registry.Service) {
 
 	c.services[domain][service] = srvs
 	c.ttls[domain][service] = time.Now().Add(c.opts.TTL)
+
+	c.notify()
+}
+
+// notify the cache changed so it's persisted, it's called with the lock
+// held
+func (c *cache) notify() {
+	if c.changed == nil {
+		return
+	}
+	select {
+	case c.changed <- true:
+	default:
+	}
+}
+
+// load reads the persisted cache, the entries are expired so they're
+// refreshed before being served past the stale period
+func (c *cache) load() error {
+	b, err := ioutil.ReadFile(c.opts.Path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var domains map[string]services
+	if err := json.Unmarshal(b, &domains); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	c.Lock()
+	defer c.Unlock()
+
+	for domain, srvs := range domains {
+		c.services[domain] = srvs
+		c.ttls[domain] = make(ttls)
+		for service := range srvs {
+			c.ttls[domain][service] = now
+		}
+	}
+
+	return nil
+}
+
+// save writes the cache to the path, replacing the previous file
+func (c *cache) save() error {
+	c.RLock()
+	b, err := json.Marshal(c.services)
+	c.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(c.opts.Path), filepath.Base(c.opts.Path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.opts.Path)
+}
+
+// persist saves the cache on changes until stopped
+func (c *cache) persist() {
+	defer close(c.persisted)
+
+	for {
+		select {
+		case <-c.changed:
+		case <-c.exit:
+			if err := c.save(); err != nil && logger.V(logger.DebugLevel, logger.DefaultLogger) {
+				logger.Debug("rcache: failed to persist ", err)
+			}
+			return
+		}
+
+		if err := c.save(); err != nil && logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debug("rcache: failed to persist ", err)
+		}
+	}
 }
 
 func (c *cache) update(domain string, res *registry.Result) {
@@ -223,7 +371,7 @@ func (c *cache) update(domain string, res *registry.Result) {
 	// only save watched services since the service using the cache may only depend on a handful
 	// of other services
 	c.RLock()
-	if _, ok := c.watched[res.Service.Name]; !ok {
+	if _, ok := c.watched[domain][res.Service.Name]; !ok {
 		c.RUnlock()
 		return
 	}
@@ -237,6 +385,9 @@ func (c *cache) update(domain string, res *registry.Result) {
 
 	c.RUnlock()
 
+	// the cached entries may be read meanwhile so a copy is updated
+	services = util.Copy(services)
+
 	if len(res.Service.Nodes) == 0 {
 		switch res.Action {
 		case "delete":
@@ -483,14 +634,19 @@ func (c *cache) GetService(service string, opts ...registry.GetOption) ([]*regis
 
 func (c *cache) Stop() {
 	c.Lock()
-	defer c.Unlock()
-
 	select {
 	case <-c.exit:
+		c.Unlock()
 		return
 	default:
 		close(c.exit)
 	}
+	c.Unlock()
+
+	// wait for the cache to be persisted
+	if c.persisted != nil {
+		<-c.persisted
+	}
 }
 
 func (c *cache) String() string {
@@ -508,13 +664,26 @@ func New(r registry.Registry, opts ...Option) Cache {
 		o(&options)
 	}
 
-	return &cache{
-		Registry: r,
-		opts:     options,
-		running:  make(map[string]bool),
-		watched:  make(map[string]watched),
-		services: make(map[string]services),
-		ttls:     make(map[string]ttls),
-		exit:     make(chan bool),
+	c := &cache{
+		Registry:   r,
+		opts:       options,
+		running:    make(map[string]bool),
+		refreshing: make(map[string]bool),
+		watched:    make(map[string]watched),
+		services:   make(map[string]services),
+		ttls:       make(map[string]ttls),
+		exit:       make(chan bool),
 	}
+
+	// the persisted cache is served while the registry is unavailable
+	if len(options.Path) > 0 {
+		if err := c.load(); err != nil && logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debug("rcache: failed to load ", options.Path, ": ", err)
+		}
+		c.changed = make(chan bool, 1)
+		c.persisted = make(chan bool)
+		go c.persist()
+	}
+
+	return c
 }
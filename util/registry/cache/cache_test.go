@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/registry/memory"
+)
+
+// flaky is a registry which fails while down
+type flaky struct {
+	registry.Registry
+
+	sync.Mutex
+	down  bool
+	calls int
+}
+
+func (f *flaky) setDown(down bool) {
+	f.Lock()
+	f.down = down
+	f.Unlock()
+}
+
+func (f *flaky) getCalls() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.calls
+}
+
+func (f *flaky) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	f.Lock()
+	f.calls++
+	down := f.down
+	f.Unlock()
+
+	if down {
+		return nil, errors.New("registry unavailable")
+	}
+	return f.Registry.GetService(name, opts...)
+}
+
+func (f *flaky) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	f.Lock()
+	down := f.down
+	f.Unlock()
+
+	if down {
+		return nil, errors.New("registry unavailable")
+	}
+	return f.Registry.Watch(opts...)
+}
+
+func newFlaky(t *testing.T) *flaky {
+	r := memory.NewRegistry()
+	if err := r.Register(&registry.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*registry.Node{{Id: "foo-1", Address: "10.0.0.1:8080"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return &flaky{Registry: r}
+}
+
+func TestCacheStale(t *testing.T) {
+	r := newFlaky(t)
+
+	c := New(r, WithTTL(time.Millisecond*10), WithStale(time.Hour))
+	defer c.Stop()
+
+	if _, err := c.GetService("foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	r.setDown(true)
+
+	// the stale entries are served while the refresh fails
+	services, err := c.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 1 {
+		t.Fatalf("expected the stale service got %+v", services)
+	}
+
+	// and refreshed in the background once the registry is back
+	r.setDown(false)
+
+	calls := r.getCalls()
+	for i := 0; i < 100 && r.getCalls() == calls; i++ {
+		if _, err := c.GetService("foo"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if r.getCalls() == calls {
+		t.Fatal("expected the stale service to be refreshed")
+	}
+}
+
+func TestCachePersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "registry.json")
+
+	c := New(newFlaky(t), WithPath(path))
+	if _, err := c.GetService("foo"); err != nil {
+		t.Fatal(err)
+	}
+	c.Stop()
+
+	// a new cache starts while the registry is down
+	r := newFlaky(t)
+	r.setDown(true)
+
+	c = New(r, WithPath(path))
+	defer c.Stop()
+
+	services, err := c.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Nodes[0].Address != "10.0.0.1:8080" {
+		t.Fatalf("expected the persisted service got %+v", services)
+	}
+
+	if _, err := c.GetService("bar"); err == nil {
+		t.Fatal("expected an error for a service which wasn't cached")
+	}
+}
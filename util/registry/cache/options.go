@@ -10,3 +10,19 @@ func WithTTL(t time.Duration) Option {
 		o.TTL = t
 	}
 }
+
+// WithStale serves entries up to d after their TTL expired while they're
+// refreshed in the background
+func WithStale(d time.Duration) Option {
+	return func(o *Options) {
+		o.Stale = d
+	}
+}
+
+// WithPath persists the cache to the file at path. It's loaded on start
+// so services can be routed to while the registry is unavailable.
+func WithPath(path string) Option {
+	return func(o *Options) {
+		o.Path = path
+	}
+}
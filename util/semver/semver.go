@@ -0,0 +1,232 @@
+// Package semver parses semantic versions and checks them against constraints
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidVersion is returned when a version can't be parsed
+	ErrInvalidVersion = errors.New("invalid version")
+	// ErrInvalidConstraint is returned when a constraint can't be parsed
+	ErrInvalidConstraint = errors.New("invalid constraint")
+)
+
+// Version is a semantic version e.g 2.3.0-beta.1
+type Version struct {
+	Major, Minor, Patch uint64
+	Prerelease          string
+}
+
+// Parse a version. The leading v and missing minor or patch
+// numbers are allowed e.g v2 and 2.3 are valid versions.
+func Parse(v string) (Version, error) {
+	var ver Version
+
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+
+	// build metadata is ignored
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+
+	if i := strings.Index(v, "-"); i >= 0 {
+		ver.Prerelease = v[i+1:]
+		v = v[:i]
+		if len(ver.Prerelease) == 0 {
+			return ver, ErrInvalidVersion
+		}
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 {
+		return ver, ErrInvalidVersion
+	}
+
+	nums := []*uint64{&ver.Major, &ver.Minor, &ver.Patch}
+
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return ver, ErrInvalidVersion
+		}
+		*nums[i] = n
+	}
+
+	return ver, nil
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to or greater than o
+func (v Version) Compare(o Version) int {
+	if c := compare(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compare(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compare(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+func compare(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares the dot separated identifiers,
+// a version without a prerelease has the higher precedence
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if len(a) == 0 {
+		return 1
+	}
+	if len(b) == 0 {
+		return -1
+	}
+
+	ap := strings.Split(a, ".")
+	bp := strings.Split(b, ".")
+
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		an, aerr := strconv.ParseUint(ap[i], 10, 64)
+		bn, berr := strconv.ParseUint(bp[i], 10, 64)
+
+		switch {
+		case aerr == nil && berr == nil:
+			if c := compare(an, bn); c != 0 {
+				return c
+			}
+		case aerr == nil:
+			// numeric identifiers have lower precedence
+			return -1
+		case berr == nil:
+			return 1
+		default:
+			if c := strings.Compare(ap[i], bp[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compare(uint64(len(ap)), uint64(len(bp)))
+}
+
+type condition struct {
+	op  string
+	ver Version
+}
+
+func (c condition) check(v Version) bool {
+	n := v.Compare(c.ver)
+
+	switch c.op {
+	case "=":
+		return n == 0
+	case "!=":
+		return n != 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	}
+
+	return false
+}
+
+// Constraint is a set of conditions a version must satisfy
+type Constraint struct {
+	// conditions which are ORed, within which they're ANDed
+	any [][]condition
+	raw string
+}
+
+// NewConstraint parses a constraint. Conditions separated by spaces must
+// all match, groups of conditions are separated by || e.g ">=2.0.0 <3.0.0"
+// or "1.2.3 || >=2.0.0". The operators are =, !=, >, >=, < and <=.
+func NewConstraint(c string) (*Constraint, error) {
+	con := &Constraint{raw: c}
+
+	for _, group := range strings.Split(c, "||") {
+		var all []condition
+
+		for _, f := range strings.Fields(group) {
+			cond, err := parseCondition(f)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, cond)
+		}
+
+		if len(all) == 0 {
+			return nil, ErrInvalidConstraint
+		}
+
+		con.any = append(con.any, all)
+	}
+
+	return con, nil
+}
+
+func parseCondition(c string) (condition, error) {
+	op := "="
+
+	for _, o := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(c, o) {
+			op = o
+			c = c[len(o):]
+			break
+		}
+	}
+
+	v, err := Parse(c)
+	if err != nil {
+		return condition{}, ErrInvalidConstraint
+	}
+
+	return condition{op: op, ver: v}, nil
+}
+
+// Check returns true if the version satisfies the constraint
+func (c *Constraint) Check(v Version) bool {
+	for _, all := range c.any {
+		ok := true
+		for _, cond := range all {
+			if !cond.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Constraint) String() string {
+	return c.raw
+}
@@ -0,0 +1,49 @@
+package semver
+
+import (
+	"testing"
+)
+
+func TestConstraint(t *testing.T) {
+	testData := []struct {
+		constraint string
+		version    string
+		match      bool
+	}{
+		{">=2.0.0 <3.0.0", "v2.3.0", true},
+		{">=2.0.0 <3.0.0", "3.0.0", false},
+		{">=2.0.0 <3.0.0", "1.9.9", false},
+		{">=2.0.0 <3.0.0", "2.0.0-beta.1", false},
+		{"1.2.3 || >=2.0.0", "1.2.3", true},
+		{"1.2.3 || >=2.0.0", "1.2.4", false},
+		{"!=1.0.0", "1.0", false},
+		{">1.0.0-alpha", "1.0.0-beta", true},
+		{"<1.0.0-alpha.2", "1.0.0-alpha.1", true},
+	}
+
+	for _, d := range testData {
+		c, err := NewConstraint(d.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := Parse(d.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := c.Check(v); m != d.match {
+			t.Fatalf("expected %s check %s to be %v", d.constraint, d.version, d.match)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, v := range []string{"", "latest", "1.2.3.4", "1.x", "1.0.0-"} {
+		if _, err := Parse(v); err == nil {
+			t.Fatalf("expected %q to be invalid", v)
+		}
+	}
+
+	if _, err := NewConstraint(">=1.0 ||"); err == nil {
+		t.Fatal("expected invalid constraint")
+	}
+}
@@ -0,0 +1,261 @@
+// Package spiffe secures transports with mTLS using SPIFFE identities. The
+// SVID of the workload and the trust bundle are read from the files written
+// by the SPIRE agent or spiffe-helper and reloaded when they're rotated. The
+// peers are verified against the bundle and their SPIFFE ID authorized, the
+// ID of the peer is passed to the handlers in the context.
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/transport"
+	mtls "github.com/asim/go-micro/v3/util/tls"
+)
+
+var (
+	// ErrNoID is returned for certificates without a SPIFFE ID
+	ErrNoID = errors.New("certificate has no spiffe id")
+)
+
+// Authorizer returns an error if the peer of the SPIFFE ID isn't allowed
+type Authorizer func(id string) error
+
+// AuthorizeAny allows the peers of any SPIFFE ID of the trust bundle
+func AuthorizeAny() Authorizer {
+	return func(id string) error {
+		return nil
+	}
+}
+
+// AuthorizeID allows the peers of the SPIFFE IDs
+func AuthorizeID(ids ...string) Authorizer {
+	return func(id string) error {
+		for _, i := range ids {
+			if i == id {
+				return nil
+			}
+		}
+		return fmt.Errorf("spiffe id %s is not authorized", id)
+	}
+}
+
+// AuthorizeMemberOf allows the peers of the trust domain e.g example.org
+func AuthorizeMemberOf(trustDomain string) Authorizer {
+	return func(id string) error {
+		u, err := url.Parse(id)
+		if err != nil || !strings.EqualFold(u.Host, trustDomain) {
+			return fmt.Errorf("spiffe id %s is not a member of %s", id, trustDomain)
+		}
+		return nil
+	}
+}
+
+// AuthorizeServices allows the services of the names in the trust domain,
+// whose ID is spiffe://<trust domain>/<name>
+func AuthorizeServices(trustDomain string, names ...string) Authorizer {
+	ids := make([]string, 0, len(names))
+	for _, n := range names {
+		ids = append(ids, "spiffe://"+trustDomain+"/"+n)
+	}
+	return AuthorizeID(ids...)
+}
+
+// IDFromCertificate returns the SPIFFE ID of the certificate, its spiffe uri
+func IDFromCertificate(cert *x509.Certificate) (string, error) {
+	var id string
+	for _, u := range cert.URIs {
+		if u.Scheme != "spiffe" {
+			continue
+		}
+		// an SVID has exactly one SPIFFE ID
+		if len(id) > 0 {
+			return "", errors.New("certificate has more than one spiffe id")
+		}
+		id = u.String()
+	}
+	if len(id) == 0 {
+		return "", ErrNoID
+	}
+	return id, nil
+}
+
+// PeerID returns the SPIFFE ID of the peer of the request
+func PeerID(ctx context.Context) (string, bool) {
+	state, ok := transport.PeerFromContext(ctx)
+	if !ok || len(state.PeerCertificates) == 0 {
+		return "", false
+	}
+	id, err := IDFromCertificate(state.PeerCertificates[0])
+	if err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// Source is the SVID of the workload and the trust bundle its peers are
+// verified with, both reloaded when their files change
+type Source struct {
+	svid       *mtls.Reloader
+	bundleFile string
+
+	sync.RWMutex
+	roots   *x509.CertPool
+	modTime time.Time
+}
+
+// NewSource loads the SVID, its key and the trust bundle from the pem files,
+// checking them for changes on the interval
+func NewSource(svidFile, keyFile, bundleFile string, interval time.Duration) (*Source, error) {
+	svid, err := mtls.NewReloader(svidFile, keyFile, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Source{
+		svid:       svid,
+		bundleFile: bundleFile,
+	}
+
+	if _, err := s.bundle(); err != nil {
+		s.svid.Stop()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// bundle returns the trust bundle, loaded again if the file changed
+func (s *Source) bundle() (*x509.CertPool, error) {
+	fi, err := os.Stat(s.bundleFile)
+
+	s.RLock()
+	roots, mod := s.roots, s.modTime
+	s.RUnlock()
+
+	// the previous bundle is kept if the file can't be read
+	if err != nil || !fi.ModTime().After(mod) {
+		if roots == nil {
+			if err == nil {
+				err = errors.New("no trust bundle loaded")
+			}
+			return nil, err
+		}
+		return roots, nil
+	}
+
+	b, err := ioutil.ReadFile(s.bundleFile)
+	if err != nil {
+		return roots, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		if roots == nil {
+			return nil, errors.New("no certificates in the trust bundle")
+		}
+		return roots, nil
+	}
+
+	s.Lock()
+	s.roots = pool
+	s.modTime = fi.ModTime()
+	s.Unlock()
+
+	return pool, nil
+}
+
+// ID returns the SPIFFE ID of the workload
+func (s *Source) ID() (string, error) {
+	cert, err := s.svid.Certificate()
+	if err != nil {
+		return "", err
+	}
+	return IDFromCertificate(cert.Leaf)
+}
+
+// verify returns the func verifying the certificates of the peers against
+// the trust bundle and authorizing their SPIFFE ID
+func (s *Source) verify(authorize Authorizer) func([][]byte, [][]*x509.Certificate) error {
+	return func(raw [][]byte, _ [][]*x509.Certificate) error {
+		if len(raw) == 0 {
+			return errors.New("peer sent no certificate")
+		}
+
+		certs := make([]*x509.Certificate, 0, len(raw))
+		for _, r := range raw {
+			c, err := x509.ParseCertificate(r)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, c)
+		}
+
+		roots, err := s.bundle()
+		if err != nil {
+			return err
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+
+		// SVIDs have no dns names so the chain is verified without the host
+		if _, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return err
+		}
+
+		id, err := IDFromCertificate(certs[0])
+		if err != nil {
+			return err
+		}
+		return authorize(id)
+	}
+}
+
+// Config returns a tls config presenting the SVID and requiring the peers
+// to present one authorized by the authorizer, for both the clients and
+// servers of a transport
+func (s *Source) Config(authorize Authorizer) *tls.Config {
+	if authorize == nil {
+		authorize = AuthorizeAny()
+	}
+
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		GetCertificate:       s.svid.GetCertificate,
+		GetClientCertificate: s.svid.GetClientCertificate,
+		ClientAuth:           tls.RequireAnyClientCert,
+		// the certificates are verified against the trust bundle rather
+		// than the host name
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: s.verify(authorize),
+	}
+}
+
+// Stop checking the files for changes
+func (s *Source) Stop() {
+	s.svid.Stop()
+}
+
+// Transport secures the transport with the SVID of the source, the peers
+// are authorized by the authorizer, any of the trust bundle if nil
+func Transport(s *Source, authorize Authorizer) transport.Option {
+	return func(o *transport.Options) {
+		o.Secure = true
+		o.TLSConfig = s.Config(authorize)
+	}
+}
@@ -0,0 +1,154 @@
+package spiffe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/transport"
+	"github.com/asim/go-micro/v3/transport/websocket"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newAuthority(t *testing.T) *authority {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &authority{cert: cert, key: key}
+}
+
+// write writes the bundle of the authority and an SVID of the id to dir
+func (a *authority) write(t *testing.T, dir, id string) (string, string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	u, _ := url.Parse(id)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{u},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, _ := x509.MarshalECPrivateKey(key)
+
+	svid, keyFile, bundle := filepath.Join(dir, "svid.pem"), filepath.Join(dir, "svid_key.pem"), filepath.Join(dir, "bundle.pem")
+	ioutil.WriteFile(svid, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+	ioutil.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.cert.Raw}), 0600)
+	return svid, keyFile, bundle
+}
+
+func (a *authority) source(t *testing.T, id string) *Source {
+	dir, err := ioutil.TempDir("", "spiffe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	svid, key, bundle := a.write(t, dir, id)
+	s, err := NewSource(svid, key, bundle, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestTransport(t *testing.T) {
+	ca := newAuthority(t)
+
+	server := ca.source(t, "spiffe://example.org/greeter")
+	defer server.Stop()
+	if id, err := server.ID(); err != nil || id != "spiffe://example.org/greeter" {
+		t.Fatalf("unexpected id %s %v", id, err)
+	}
+
+	str := websocket.NewTransport(Transport(server, AuthorizeMemberOf("example.org")))
+	l, err := str.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	peers := make(chan string, 1)
+	go l.Accept(func(sock transport.Socket) {
+		var m transport.Message
+		if err := sock.Recv(&m); err != nil {
+			return
+		}
+		ctx := transport.NewPeerContext(context.TODO(), sock.(transport.TLSSocket).ConnectionState())
+		id, _ := PeerID(ctx)
+		peers <- id
+		sock.Send(&m)
+	})
+
+	dial := func(src *Source, authorize Authorizer) error {
+		c, err := websocket.NewTransport(Transport(src, authorize)).Dial(l.Addr())
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if err := c.Send(&transport.Message{Body: []byte("hello")}); err != nil {
+			return err
+		}
+		var m transport.Message
+		return c.Recv(&m)
+	}
+
+	client := ca.source(t, "spiffe://example.org/caller")
+	defer client.Stop()
+
+	if err := dial(client, AuthorizeServices("example.org", "greeter")); err != nil {
+		t.Fatalf("expected the call to succeed got %v", err)
+	}
+	if id := <-peers; id != "spiffe://example.org/caller" {
+		t.Fatalf("expected the id of the caller got %s", id)
+	}
+
+	// the client rejects a server of another name
+	if err := dial(client, AuthorizeServices("example.org", "other")); err == nil {
+		t.Fatal("expected the server to be rejected")
+	}
+
+	// and the server rejects clients of another trust domain or authority
+	other := ca.source(t, "spiffe://other.org/caller")
+	defer other.Stop()
+	if err := dial(other, nil); err == nil {
+		t.Fatal("expected the client of another trust domain to be rejected")
+	}
+
+	untrusted := newAuthority(t).source(t, "spiffe://example.org/caller")
+	defer untrusted.Stop()
+	if err := dial(untrusted, nil); err == nil {
+		t.Fatal("expected the client of another authority to be rejected")
+	}
+}
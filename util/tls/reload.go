@@ -0,0 +1,186 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader serves a certificate loaded from files and reloads it when the
+// files change so rotated certificates are used without a restart
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+	err     error
+
+	once sync.Once
+	exit chan bool
+}
+
+// NewReloader loads the key pair and checks the files for changes on the
+// interval until it's stopped. A zero interval disables reloading.
+func NewReloader(certFile, keyFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		exit:     make(chan bool),
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go r.watch(interval)
+	}
+
+	return r, nil
+}
+
+// modified returns the latest modification time of the files
+func (r *Reloader) modified() (time.Time, error) {
+	var t time.Time
+
+	for _, f := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return t, err
+		}
+		if fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+
+	return t, nil
+}
+
+func (r *Reloader) watch(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-r.exit:
+			return
+		case <-t.C:
+			mod, err := r.modified()
+			if err != nil {
+				continue
+			}
+
+			r.RLock()
+			changed := mod.After(r.modTime)
+			r.RUnlock()
+
+			if changed {
+				r.Reload()
+			}
+		}
+	}
+}
+
+// Reload loads the key pair from the files. The previous certificate
+// is kept if loading fails.
+func (r *Reloader) Reload() error {
+	mod, _ := r.modified()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err == nil {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.err = err
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.modTime = mod
+
+	return nil
+}
+
+// Certificate returns the current certificate
+func (r *Reloader) Certificate() (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.cert == nil {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, errors.New("no certificate loaded")
+	}
+
+	return r.cert, nil
+}
+
+// GetCertificate can be set as the tls.Config GetCertificate func
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate()
+}
+
+// GetClientCertificate can be set as the tls.Config GetClientCertificate func
+func (r *Reloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate()
+}
+
+// Config returns a tls config serving the current certificate
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{
+		GetCertificate:       r.GetCertificate,
+		GetClientCertificate: r.GetClientCertificate,
+	}
+}
+
+// Stop checking the files for changes
+func (r *Reloader) Stop() {
+	r.once.Do(func() {
+		close(r.exit)
+	})
+}
+
+// Expiry returns when the certificate of the config expires
+func Expiry(cfg *tls.Config) (time.Time, bool) {
+	if cfg == nil {
+		return time.Time{}, false
+	}
+
+	var cert *tls.Certificate
+
+	if cfg.GetCertificate != nil {
+		c, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil || c == nil {
+			return time.Time{}, false
+		}
+		cert = c
+	} else if len(cfg.Certificates) > 0 {
+		cert = &cfg.Certificates[0]
+	} else {
+		return time.Time{}, false
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		if len(cert.Certificate) == 0 {
+			return time.Time{}, false
+		}
+		l, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return time.Time{}, false
+		}
+		leaf = l
+	}
+
+	return leaf.NotAfter, true
+}
@@ -0,0 +1,89 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeyPair(t *testing.T, certFile, keyFile string, mod time.Time) []byte {
+	cert, err := Certificate("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return cert.Certificate[0]
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	now := time.Now()
+	writeKeyPair(t, certFile, keyFile, now)
+
+	if _, err := NewReloader(filepath.Join(dir, "missing.pem"), keyFile, 0); err == nil {
+		t.Fatal("expected the load error")
+	}
+
+	r, err := NewReloader(certFile, keyFile, time.Millisecond*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if exp, ok := Expiry(r.Config()); !ok || exp.Before(now) {
+		t.Fatalf("expected the certificate expiry got %v", exp)
+	}
+
+	// rotate the certificate
+	der := writeKeyPair(t, certFile, keyFile, now.Add(time.Minute))
+
+	time.Sleep(time.Millisecond * 50)
+
+	cert, err := r.Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cert.Certificate[0]) != string(der) {
+		t.Fatal("expected the rotated certificate to be loaded")
+	}
+
+	// a failed reload keeps the current certificate
+	os.Remove(keyFile)
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected a reload error")
+	}
+	if _, err := r.Certificate(); err != nil {
+		t.Fatal(err)
+	}
+}
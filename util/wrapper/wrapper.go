@@ -0,0 +1,78 @@
+// Package wrapper provides common client and server wrappers
+package wrapper
+
+import (
+	"context"
+
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/server"
+)
+
+// RecoverMiddleware is the name the service registers the RecoverHandler with
+const RecoverMiddleware = "recover"
+
+// PanicHandler is called with the value recovered from a panicking handler
+type PanicHandler func(ctx context.Context, req server.Request, recovered interface{})
+
+// RecoverHandler recovers a panic in the handler and returns it as an
+// internal server error after passing it to the panic handler
+func RecoverHandler(fn PanicHandler) server.HandlerWrapper {
+	return func(h server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if fn != nil {
+					fn(ctx, req, r)
+				}
+				err = errors.InternalServerError("go.micro.server", "panic recovered: %v", r)
+			}()
+
+			return h(ctx, req, rsp)
+		}
+	}
+}
+
+// ValidateMiddleware is the name to register the ValidateHandler with
+const ValidateMiddleware = "validate"
+
+// validator is implemented by messages generated by protoc-gen-validate
+// and types validated with ozzo-validation
+type validator interface {
+	Validate() error
+}
+
+// ValidateHandler rejects requests whose body implements Validate() error
+// and fails validation with a bad request error before calling the handler.
+// Only the given endpoints e.g "Users.Create" are validated, or all if none.
+func ValidateHandler(endpoints ...string) server.HandlerWrapper {
+	only := make(map[string]bool, len(endpoints))
+	for _, e := range endpoints {
+		only[e] = true
+	}
+
+	return func(h server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			if len(only) > 0 && !only[req.Endpoint()] {
+				return h(ctx, req, rsp)
+			}
+
+			v, ok := req.Body().(validator)
+			if !ok {
+				return h(ctx, req, rsp)
+			}
+
+			if err := v.Validate(); err != nil {
+				// keep client errors returned by the validator
+				if e, ok := err.(*errors.Error); ok && e.Code >= 400 && e.Code < 500 {
+					return e
+				}
+				return errors.BadRequest("go.micro.server", "invalid request: %v", err)
+			}
+
+			return h(ctx, req, rsp)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/server"
+)
+
+func TestRecoverHandler(t *testing.T) {
+	var recovered interface{}
+
+	fn := RecoverHandler(func(ctx context.Context, req server.Request, r interface{}) {
+		recovered = r
+	})(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		panic("boom")
+	})
+
+	err := fn(context.TODO(), nil, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if e := errors.Parse(err.Error()); e.Code != 500 {
+		t.Fatalf("expected 500 got %d", e.Code)
+	}
+	if recovered != "boom" {
+		t.Fatalf("expected boom got %v", recovered)
+	}
+}
+
+type testRequest struct {
+	server.Request
+	endpoint string
+	body     interface{}
+}
+
+func (r *testRequest) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *testRequest) Body() interface{} {
+	return r.body
+}
+
+type testMessage struct {
+	Name string
+}
+
+func (m *testMessage) Validate() error {
+	if len(m.Name) == 0 {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+func TestValidateHandler(t *testing.T) {
+	var called int
+
+	fn := ValidateHandler("Users.Create")(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		called++
+		return nil
+	})
+
+	// invalid requests are rejected before the handler
+	err := fn(context.TODO(), &testRequest{endpoint: "Users.Create", body: &testMessage{}}, nil)
+	if e := errors.Parse(err.Error()); e.Code != 400 {
+		t.Fatalf("expected 400 got %v", err)
+	}
+	if called != 0 {
+		t.Fatal("expected the handler not to be called")
+	}
+
+	if err := fn(context.TODO(), &testRequest{endpoint: "Users.Create", body: &testMessage{Name: "john"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// other endpoints aren't validated
+	if err := fn(context.TODO(), &testRequest{endpoint: "Users.Update", body: &testMessage{}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if called != 2 {
+		t.Fatalf("expected the handler to be called twice got %d", called)
+	}
+}